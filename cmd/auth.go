@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	"github.com/nchapman/myrient-dl/internal/auth"
+	"github.com/spf13/cobra"
+)
+
+// authEnv holds the --auth spec when the flag isn't given, keeping secrets out
+// of the process list and shell history
+const authEnv = "MYRIENT_DL_AUTH"
+
+var (
+	authSpec    string
+	credentials auth.Provider // Parsed from --auth before any command runs
+	scoped      *auth.Scoped  // credentials, limited to the listings' hosts
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&authSpec, "auth", "", "Credentials for private mirrors: header:NAME: VALUE, bearer-cmd:COMMAND, or exec:COMMAND (default $"+authEnv+")")
+	rootCmd.PersistentPreRunE = func(c *cobra.Command, args []string) error {
+		if err := applyConfigDefaults(c); err != nil {
+			return err
+		}
+		if err := applyQuiet(); err != nil {
+			return err
+		}
+		if err := loadCredentials(args); err != nil {
+			return err
+		}
+		return loadIdentity()
+	}
+}
+
+// loadCredentials parses --auth, falling back to the environment. The
+// credentials only go to the hosts of URLs given as arguments and of listings
+// fetched later (trustListing), never to redirect targets or mirrors.
+func loadCredentials(args []string) error {
+	spec := authSpec
+	if spec == "" {
+		spec = os.Getenv(authEnv)
+	}
+	if spec == "" {
+		return nil
+	}
+	p, err := auth.Parse(spec)
+	if err != nil {
+		return err
+	}
+	scoped = auth.NewScoped(p)
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://") {
+			scoped.Trust(arg)
+		}
+	}
+	credentials = scoped
+	return nil
+}
+
+// trustListing lets --auth credentials go to the host of a listing the run
+// fetches, such as one recorded in a plan or saved search
+func trustListing(rawURL string) {
+	if scoped != nil {
+		scoped.Trust(rawURL)
+	}
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/nchapman/myrient-dl/internal/blocklist"
+	"github.com/nchapman/myrient-dl/internal/dat"
+	"github.com/nchapman/myrient-dl/internal/downloader"
+	"github.com/nchapman/myrient-dl/internal/parser"
+	"github.com/spf13/cobra"
+)
+
+var (
+	blocklistFile string
+	blocked       *blocklist.List // Loaded once by loadBlocklist
+)
+
+// addBlocklistFlag registers --blocklist, which applies both when selecting and after downloading
+func addBlocklistFlag(c *cobra.Command) {
+	c.Flags().StringVar(&blocklistFile, "blocklist", "", "File of hashes (CRC32/MD5/SHA-1/SHA-256) and filename globs to never download")
+}
+
+// loadBlocklist reads --blocklist, returning nil when it isn't set
+func loadBlocklist() (*blocklist.List, error) {
+	if blocklistFile == "" || blocked != nil {
+		return blocked, nil
+	}
+	list, err := blocklist.Load(blocklistFile)
+	if err != nil {
+		return nil, err
+	}
+	blocked = list
+	return blocked, nil
+}
+
+// applyBlocklist drops files whose name is blocklisted, or whose DAT entry has a
+// blocklisted ROM hash
+func applyBlocklist(list *blocklist.List, datfile *dat.Datafile, files []parser.FileInfo) []parser.FileInfo {
+	var kept, dropped []parser.FileInfo
+	for _, f := range files {
+		reason := ""
+		if list.MatchName(f.Name) {
+			reason = "name"
+		} else if datfile != nil {
+			if game, ok := datfile.Lookup(f.Name); ok {
+				for _, rom := range game.ROMs {
+					if d, ok := list.MatchHash(rom.CRC, rom.MD5, rom.SHA1); ok {
+						reason = fmt.Sprintf("%s hash %s", rom.Name, d)
+						break
+					}
+				}
+			}
+		}
+
+		if reason == "" {
+			kept = append(kept, f)
+			continue
+		}
+		dropped = append(dropped, f)
+		if verbose {
+			fmt.Printf("  - %s (blocklisted %s)\n", f.Name, reason)
+		}
+	}
+
+	if len(dropped) > 0 {
+		fmt.Printf("Blocklist: leaving out %d files (%s)\n", len(dropped), formatBytes(totalSize(dropped)))
+	}
+	return kept
+}
+
+// blocklistVerifier rejects downloads whose hash is blocklisted
+func blocklistVerifier(list *blocklist.List) downloader.Verifier {
+	return downloader.VerifierFunc(func(file parser.FileInfo, path string) error {
+		digest, ok, err := list.CheckFile(path)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return fmt.Errorf("%w: %s matches blocklisted hash %s", downloader.ErrRejected, filepath.Base(file.Name), digest)
+		}
+		return nil
+	})
+}
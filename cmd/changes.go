@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nchapman/myrient-dl/internal/parser"
+	"github.com/nchapman/myrient-dl/internal/snapshot"
+	"github.com/spf13/cobra"
+)
+
+var changesCmd = &cobra.Command{
+	Use:   "changes [URL]",
+	Short: "Show what changed in a listing since it was last seen",
+	Long: `Compare the current listing to the snapshot cached the last time it was fetched
+and print added, removed, and resized files. Nothing is downloaded.
+
+Snapshots are cached in the user cache directory whenever a listing is fetched,
+by this command or by a download, so the first run only records a baseline.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runChanges,
+}
+
+func init() {
+	addListingFlags(changesCmd)
+
+	rootCmd.AddCommand(changesCmd)
+}
+
+func runChanges(_ *cobra.Command, args []string) error {
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	_, _, err := checkListing(ctx, args[0])
+	return err
+}
+
+// checkListing fetches a listing, prints how it changed since its cached snapshot,
+// and replaces the snapshot. previous is nil when there was no snapshot yet.
+func checkListing(ctx context.Context, listingURL string) (previous *snapshot.Snapshot, changes snapshot.Changes, err error) {
+	dir, err := snapshot.DefaultDir()
+	if err != nil {
+		return nil, changes, err
+	}
+	previous, err = snapshot.Load(dir, listingURL)
+	if err != nil {
+		return nil, changes, err
+	}
+
+	trustListing(listingURL)
+	files, err := parser.ParseDirectoryListing(ctx, listingURL, listingOptions())
+	if errors.Is(err, parser.ErrEmpty) {
+		// More likely a server hiccup than every file going away; don't record it
+		return nil, changes, fmt.Errorf("%w; keeping the previous snapshot", err)
+	}
+	if err != nil {
+		return nil, changes, fmt.Errorf("failed to parse directory listing: %w", err)
+	}
+	if err := snapshot.Save(dir, listingURL, files); err != nil {
+		return nil, changes, err
+	}
+
+	if previous == nil {
+		fmt.Printf("No previous snapshot; recorded %s files as the baseline\n", formatCount(len(files)))
+		return nil, changes, nil
+	}
+
+	changes = snapshot.Diff(previous.Files, files)
+	since := previous.Taken.Local().Format("2006-01-02 15:04")
+	if changes.Empty() {
+		fmt.Printf("No changes since %s (%s files)\n", since, formatCount(len(files)))
+		return previous, changes, nil
+	}
+
+	fmt.Printf("Changes since %s:\n", since)
+	for _, f := range changes.Added {
+		fmt.Printf("  + %s (%s)\n", f.Name, formatBytes(f.Size))
+	}
+	for _, f := range changes.Removed {
+		fmt.Printf("  - %s (%s)\n", f.Name, formatBytes(f.Size))
+	}
+	for _, c := range changes.Changed {
+		fmt.Printf("  ~ %s (%s → %s)\n", c.New.Name, formatBytes(c.Old.Size), formatBytes(c.New.Size))
+	}
+	fmt.Printf("%d added, %d removed, %d changed\n", len(changes.Added), len(changes.Removed), len(changes.Changed))
+	return previous, changes, nil
+}
+
+// rememberListing caches a fetched listing for "myrient-dl changes"; failures only warn
+func rememberListing(listingURL string, files []parser.FileInfo) {
+	dir, err := snapshot.DefaultDir()
+	if err == nil {
+		err = snapshot.Save(dir, listingURL, files)
+	}
+	if err != nil && verbose {
+		fmt.Printf("  ⚠ Failed to cache listing snapshot: %v\n", err)
+	}
+}
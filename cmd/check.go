@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/downloader"
+	"github.com/nchapman/myrient-dl/internal/parser"
+	"github.com/spf13/cobra"
+)
+
+// slowResponse is the request latency above which check suggests more parallelism
+const slowResponse = time.Second
+
+var checkCmd = &cobra.Command{
+	Use:   "check URL",
+	Short: "Check that a listing URL is ready for a download job",
+	Long: `Run a quick preflight against a listing URL before configuring a big job.
+
+check fetches and parses the listing, reports how many files it has, then asks
+the server about one file with a HEAD request and a one-byte Range request to
+see whether sizes, ETags, and resuming work. Nothing is downloaded.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCheck,
+}
+
+func init() {
+	addListingFlags(checkCmd)
+
+	rootCmd.AddCommand(checkCmd)
+}
+
+func runCheck(_ *cobra.Command, args []string) error {
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	target := args[0]
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid URL %q: expected an http(s) listing URL", target)
+	}
+
+	var warnings []string
+	warn := func(format string, args ...any) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	fmt.Printf("Checking %s\n\n", target)
+
+	start := time.Now()
+	trustListing(target)
+	files, err := parser.ParseDirectoryListing(ctx, target, listingOptions())
+	if err != nil && !errors.Is(err, parser.ErrEmpty) {
+		return fmt.Errorf("not a usable listing: %w", err)
+	}
+	listingLatency := time.Since(start)
+	fmt.Printf("  ✓ Listing: %s files, %s (fetched in %s)\n",
+		formatCount(len(files)), formatBytes(totalSize(files)), listingLatency.Round(time.Millisecond))
+
+	if len(files) == 0 {
+		warn("The listing has no files; if it only has subdirectories, point the URL at one of them")
+		printCheckResult(warnings)
+		return nil
+	}
+	if label := files[0].SystemLabel(); label != "" {
+		fmt.Printf("  ✓ System: %s\n", label)
+	}
+	unsized := 0
+	for _, f := range files {
+		if f.Size <= 0 {
+			unsized++
+		}
+	}
+	if unsized > 0 {
+		warn("%s files have no listed size; --max-total and size guards count them as 0 bytes", formatCount(unsized))
+	}
+
+	sample := files[0]
+	probe, err := downloader.New(downloader.Config{Auth: credentials, Identity: identity}).Probe(ctx, sample.URL)
+	switch {
+	case err != nil && probe.Host == "":
+		fmt.Printf("  ✗ HEAD %s: %v\n", sample.Name, err)
+		warn("HEAD requests fail; downloads use them to learn sizes and skip finished files")
+	default:
+		fmt.Printf("  ✓ HEAD %s: %s in %s\n", sample.Name, formatBytes(probe.Size), probe.HeadLatency.Round(time.Millisecond))
+		if probe.Redirected {
+			fmt.Printf("  ✓ Files are served from %s\n", probe.Host)
+		}
+		switch {
+		case probe.Size < 0:
+			warn("HEAD doesn't report a size, so existing files can't be checked for completeness")
+		case sample.Size > 0 && sizeDiffers(sample.Size, probe.Size):
+			warn("Listed size of %s (%s) differs from the served size (%s); the listing may be stale",
+				sample.Name, formatBytes(sample.Size), formatBytes(probe.Size))
+		}
+		if probe.ETag == "" {
+			warn("No ETag header; --post-verify can only compare sizes")
+		}
+
+		switch {
+		case err != nil:
+			fmt.Printf("  ✗ Range requests: %v\n", err)
+			warn("Range requests fail; interrupted files restart from scratch")
+		case probe.Ranges:
+			advertised := "advertised"
+			if probe.AcceptRanges != "bytes" {
+				advertised = "though not advertised"
+			}
+			fmt.Printf("  ✓ Range requests: supported, %s (%s)\n", advertised, probe.RangeLatency.Round(time.Millisecond))
+		default:
+			fmt.Println("  ✗ Range requests: not honored")
+			warn("Range requests aren't honored; interrupted files restart from scratch and --continue-existing re-downloads")
+		}
+
+		if probe.HeadLatency > slowResponse {
+			warn("Slow responses (%s per request); each file costs a HEAD round trip, so a little more --parallel helps with many small files",
+				probe.HeadLatency.Round(time.Millisecond))
+		}
+	}
+
+	printCheckResult(warnings)
+	return nil
+}
+
+// sizeDiffers reports whether a listed size, rounded by the listing to a few
+// significant digits, can't be the served size
+func sizeDiffers(listed, served int64) bool {
+	diff := listed - served
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff > served/50+1024 // More than 2% plus a KiB of rounding
+}
+
+// printCheckResult prints the collected warnings, or that the URL looks ready
+func printCheckResult(warnings []string) {
+	if len(warnings) == 0 {
+		fmt.Println("\n✓ Ready to download")
+		return
+	}
+	fmt.Println()
+	for _, w := range warnings {
+		fmt.Printf("  ⚠ %s\n", w)
+	}
+}
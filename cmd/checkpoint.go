@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/checkpoint"
+	"github.com/nchapman/myrient-dl/internal/downloader"
+	"github.com/nchapman/myrient-dl/internal/history"
+	"github.com/nchapman/myrient-dl/internal/state"
+)
+
+// checkpointEvery is how many finished files make a batch; 0 disables checkpoints
+var checkpointEvery int
+
+// checkpointer brings a long run's bookkeeping up to date after every batch
+type checkpointer struct {
+	batches *checkpoint.Batcher
+	queue   *state.Queue
+	history *history.Log // nil when history is disabled
+	usage   *usageMeter  // Set once the downloader exists
+
+	warnOnce sync.Once
+}
+
+// openCheckpoints starts batch logs for a run in dir
+func openCheckpoints(dir, source string, total int, queue *state.Queue) (*checkpointer, error) {
+	batches, err := checkpoint.Open(state.Dir(dir), source, checkpointEvery, total)
+	if err != nil {
+		return nil, err
+	}
+	if verbose {
+		fmt.Printf("Checkpointing every %d files to %s\n", checkpointEvery, batches.Path())
+	}
+	return &checkpointer{batches: batches, queue: queue}, nil
+}
+
+// recorder returns a download event handler that logs finished files to the
+// open batch and checkpoints when it fills up
+func (c *checkpointer) recorder() func(downloader.Event) {
+	return func(e downloader.Event) {
+		if e.Type == downloader.EventStarted {
+			return
+		}
+		record := checkpoint.Record{Time: time.Now().UTC(), Name: e.File.Name, Result: string(e.Type), Bytes: e.Bytes}
+		if e.Err != nil {
+			record.Error = e.Err.Error()
+		}
+		closed, err := c.batches.Add(record)
+		if err != nil {
+			c.warnOnce.Do(func() { fmt.Printf("  ⚠ Failed to write checkpoint: %v\n", err) })
+		}
+		if closed {
+			c.checkpoint()
+		}
+	}
+}
+
+// checkpoint writes out the queue's pending changes, flushes the history and
+// usage logs, and prints an interim summary
+func (c *checkpointer) checkpoint() {
+	if err := c.queue.Flush(); err != nil {
+		fmt.Printf("  ⚠ %v\n", err)
+	}
+	if c.history != nil {
+		if err := c.history.Sync(); err != nil {
+			fmt.Printf("  ⚠ %v\n", err)
+		}
+	}
+	if c.usage != nil {
+		c.usage.record()
+	}
+
+	s := c.batches.Summary()
+	fmt.Printf("\n  ✓ Checkpoint %d: %s of %s files finished (%d completed, %d skipped, %d failed), %s downloaded\n",
+		s.Batches, formatCount(s.Totals.Finished), formatCount(s.Total),
+		s.Totals.Completed, s.Totals.Skipped, s.Totals.Failed, formatBytes(s.Totals.Bytes))
+}
+
+// close ends the batch logs
+func (c *checkpointer) close() {
+	if err := c.batches.Close(); err != nil {
+		fmt.Printf("  ⚠ %v\n", err)
+	}
+}
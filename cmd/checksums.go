@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nchapman/myrient-dl/internal/checksums"
+	"github.com/nchapman/myrient-dl/internal/downloader"
+	"github.com/nchapman/myrient-dl/internal/parser"
+	"github.com/nchapman/myrient-dl/internal/plan"
+)
+
+// maxChecksumFile is the largest checksum file fetched into memory
+const maxChecksumFile = 16 << 20
+
+var noChecksums bool
+
+// listingChecksums are the checksum files in the listing that cover the
+// current selection
+var listingChecksums []checksums.Source
+
+// pinnedChecksums are the digests recorded in an applied plan; they're used
+// instead of fetching the listing's checksum files
+var pinnedChecksums *checksums.Set
+
+// loadChecksums fetches the checksum files found while selecting and returns
+// the digests they publish for files, or nil if there are none. Checksum files
+// that can't be fetched or parsed only produce a warning.
+func loadChecksums(ctx context.Context, files []parser.FileInfo) *checksums.Set {
+	if noChecksums {
+		return nil
+	}
+	set := pinnedChecksums
+	if set == nil {
+		set = fetchChecksums(ctx)
+	}
+	if set == nil {
+		return nil
+	}
+
+	covered := 0
+	for _, f := range files {
+		if _, ok := set.Lookup(f.Name); ok {
+			covered++
+		}
+	}
+	if covered == 0 {
+		return nil
+	}
+	fmt.Printf("Checksums: verifying %s of %s files against published digests\n", formatCount(covered), formatCount(len(files)))
+	return set
+}
+
+// fetchChecksums fetches and parses the checksum files found while selecting,
+// or returns nil if there are none or the run was interrupted
+func fetchChecksums(ctx context.Context) *checksums.Set {
+	if len(listingChecksums) == 0 {
+		return nil
+	}
+
+	fmt.Printf("Fetching %d checksum files...\n", len(listingChecksums))
+	dl := downloader.New(downloader.Config{Auth: credentials, Identity: identity, RequestInterval: requestInterval})
+	set := checksums.NewSet()
+	for _, src := range listingChecksums {
+		data, err := dl.Get(ctx, src.File.URL, maxChecksumFile)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			fmt.Printf("  ⚠ Failed to fetch %s: %v\n", src.File.Name, err)
+			continue
+		}
+		n, err := set.Add(src, data)
+		if err != nil {
+			fmt.Printf("  ⚠ %v\n", err)
+			continue
+		}
+		if verbose {
+			fmt.Printf("  ✓ %s: %d digests\n", src.File.Name, n)
+		}
+	}
+	return set
+}
+
+// pinChecksums records the SHA-1 digests the listing publishes for a plan's
+// files, so apply verifies against what was published when it was made
+func pinChecksums(ctx context.Context, p *plan.Plan) {
+	if noChecksums {
+		return
+	}
+	if n := p.Pin(fetchChecksums(ctx)); n > 0 {
+		fmt.Printf("Pinned SHA-1 digests for %s of %s files\n", formatCount(n), formatCount(len(p.Files)))
+	}
+}
+
+// checksumLookup adapts a checksum set to the downloader
+func checksumLookup(set *checksums.Set) func(parser.FileInfo) (downloader.Checksum, bool) {
+	return func(f parser.FileInfo) (downloader.Checksum, bool) {
+		sum, ok := set.Lookup(f.Name)
+		return downloader.Checksum{Algorithm: sum.Algorithm, Digest: sum.Digest}, ok
+	}
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/cleanup"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cleanYes    bool
+	cleanDryRun bool
+	cleanMinAge time.Duration
+)
+
+var cleanCmd = &cobra.Command{
+	Use:   "clean [DIR]",
+	Short: "Remove leftover temp files, stale locks, and quarantined files",
+	Long: `Find artifacts left behind by interrupted runs in a download directory:
+orphaned download temp files and their journals, stale run locks, and the
+contents of the quarantine. Other files ending in .tmp or .lock are left alone.
+
+Their sizes are reported and they are removed after confirmation. A directory
+a download is still running in is refused.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runClean,
+}
+
+func init() {
+	cleanCmd.Flags().BoolVarP(&cleanYes, "yes", "y", false, "Remove without asking for confirmation")
+	cleanCmd.Flags().BoolVar(&cleanDryRun, "dry-run", false, "Only list what would be removed")
+	cleanCmd.Flags().DurationVar(&cleanMinAge, "min-age", time.Hour, "Ignore temp and lock files modified more recently than this (they may belong to a running download)")
+
+	rootCmd.AddCommand(cleanCmd)
+}
+
+func runClean(_ *cobra.Command, args []string) error {
+	dir := args[0]
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("not a directory: %s", dir)
+	}
+
+	artifacts, err := cleanup.Scan(dir, cleanMinAge)
+	if errors.Is(err, cleanup.ErrRunning) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+
+	if len(artifacts) == 0 {
+		fmt.Println("Nothing to clean")
+		return nil
+	}
+
+	counts := make(map[cleanup.Kind]int)
+	sizes := make(map[cleanup.Kind]int64)
+	for _, a := range artifacts {
+		counts[a.Kind]++
+		sizes[a.Kind] += a.Size
+		if verbose || cleanDryRun {
+			rel, err := filepath.Rel(dir, a.Path)
+			if err != nil {
+				rel = a.Path
+			}
+			fmt.Printf("  %-10s %10s  %s\n", a.Kind, formatBytes(a.Size), rel)
+		}
+	}
+
+	fmt.Printf("\nFound %d leftover files (%s):\n", len(artifacts), formatBytes(cleanup.TotalSize(artifacts)))
+	for _, kind := range []cleanup.Kind{cleanup.KindTemp, cleanup.KindLock, cleanup.KindQuarantine} {
+		if counts[kind] > 0 {
+			fmt.Printf("  - %d %s files (%s)\n", counts[kind], kind, formatBytes(sizes[kind]))
+		}
+	}
+
+	if cleanDryRun {
+		return nil
+	}
+
+	if !cleanYes && !confirm("Remove them?") {
+		fmt.Println("Aborted")
+		return nil
+	}
+
+	removed, err := cleanup.Remove(dir, artifacts)
+	fmt.Printf("Removed %d files\n", removed)
+	return err
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/nchapman/myrient-dl/internal/config"
+	"github.com/nchapman/myrient-dl/internal/useragent"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// configPath is --config; empty means the default location
+var configPath string
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Config file to read (default ~/.config/myrient-dl/config.yaml)")
+	rootCmd.PersistentFlags().StringVar(&userAgent, "user-agent", "", "Send this User-Agent instead of myrient-dl's own (which includes the config's contact)")
+	rootCmd.PersistentFlags().StringArrayVar(&requestHeaders, "header", []string{}, "Send an extra header with every listing and download request, e.g. \"X-Token: abc\" (repeatable)")
+}
+
+// loadUserConfig reads the user's config file. The default one may not exist;
+// one named with --config must.
+func loadUserConfig() (*config.Config, error) {
+	if configPath != "" {
+		if _, err := os.Stat(configPath); err != nil {
+			return nil, fmt.Errorf("failed to read config: %w", err)
+		}
+		return config.Load(configPath)
+	}
+	path, err := config.DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return config.Load(path)
+}
+
+// applyConfigDefaults gives the command's flags that weren't set on the
+// command line the values from the config's defaults section. Options the
+// command doesn't have are left for the commands that do.
+func applyConfigDefaults(c *cobra.Command) error {
+	cfg, err := loadUserConfig()
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool)
+	collectFlags(rootCmd, known)
+
+	names := make([]string, 0, len(cfg.Defaults))
+	for name := range cfg.Defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !known[name] {
+			return fmt.Errorf("config: unknown option %q in defaults", name)
+		}
+		f := c.Flags().Lookup(name)
+		if f == nil || f.Changed {
+			continue
+		}
+		values := cfg.Defaults[name]
+		if list, ok := f.Value.(interface{ Replace([]string) error }); ok {
+			if err := list.Replace(values); err != nil {
+				return fmt.Errorf("config: invalid %s %q: %w", name, values, err)
+			}
+			continue
+		}
+		if len(values) != 1 {
+			return fmt.Errorf("config: %s takes a single value", name)
+		}
+		if err := f.Value.Set(values[0]); err != nil {
+			return fmt.Errorf("config: invalid %s %q: %w", name, values[0], err)
+		}
+	}
+	return nil
+}
+
+// collectFlags records the flags of a command and its subcommands that a
+// config may set
+func collectFlags(c *cobra.Command, known map[string]bool) {
+	c.Flags().VisitAll(func(f *pflag.Flag) {
+		known[f.Name] = true
+	})
+	c.PersistentFlags().VisitAll(func(f *pflag.Flag) {
+		known[f.Name] = true
+	})
+	for _, sub := range c.Commands() {
+		collectFlags(sub, known)
+	}
+	delete(known, "config")
+	delete(known, "help")
+	delete(known, "version")
+}
+
+// identity is how requests identify themselves, from the config's contact,
+// --user-agent, and --header
+var (
+	identity       useragent.Identity
+	userAgent      string
+	requestHeaders []string
+)
+
+// loadIdentity builds the request identification from the user's config and flags
+func loadIdentity() error {
+	cfg, err := loadUserConfig()
+	if err != nil {
+		return err
+	}
+	identity, err = useragent.New(cfg.Contact)
+	if err != nil {
+		return err
+	}
+	if err := identity.SetAgent(userAgent); err != nil {
+		return fmt.Errorf("invalid --user-agent: %w", err)
+	}
+	for _, h := range requestHeaders {
+		if err := identity.AddHeader(h); err != nil {
+			return fmt.Errorf("invalid --header: %w", err)
+		}
+	}
+	return nil
+}
+
+// resolveOutputDir picks the output directory for a listing when -o wasn't given:
+// a configured output root if one matches, otherwise a name derived from the URL
+func resolveOutputDir(targetURL string, u *url.URL) (string, error) {
+	dir := getDefaultOutputDir(u)
+
+	cfg, err := loadUserConfig()
+	if err != nil {
+		return "", err
+	}
+	if configured, ok := cfg.OutputDir(targetURL, filepath.Clean(dir)); ok {
+		return configured, nil
+	}
+	return dir, nil
+}
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/nchapman/myrient-dl/internal/dat"
+	"github.com/nchapman/myrient-dl/internal/downloader"
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+var datVerify string
+
+// selectionDAT is the DAT loaded while selecting, used again to verify downloads
+var selectionDAT *dat.Datafile
+
+// listingDATs are the DAT files published next to the selection, tried for
+// verification when --dat isn't given
+var listingDATs []parser.FileInfo
+
+// maxDATFile is the largest listing DAT fetched into memory
+const maxDATFile = 64 << 20
+
+// loadListingDAT fetches the DATs found while selecting and returns the first
+// that describes any of files, so downloads are verified against it without
+// --dat. DATs that can't be fetched or parsed only produce a warning.
+func loadListingDAT(ctx context.Context, files []parser.FileInfo) *dat.Datafile {
+	if len(listingDATs) == 0 {
+		return nil
+	}
+
+	dl := downloader.New(downloader.Config{Auth: credentials, Identity: identity, RequestInterval: requestInterval})
+	for _, f := range listingDATs {
+		data, err := dl.Get(ctx, f.URL, maxDATFile)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			fmt.Printf("  ⚠ Failed to fetch %s: %v\n", f.Name, err)
+			continue
+		}
+		datfile, err := dat.Parse(bytes.NewReader(data))
+		if err != nil {
+			if verbose {
+				fmt.Printf("  ⚠ %s: %v\n", f.Name, err)
+			}
+			continue
+		}
+		covered := datfile.Covers(files)
+		if covered == 0 {
+			if verbose {
+				fmt.Printf("  %s doesn't describe the selected files\n", f.Name)
+			}
+			continue
+		}
+		fmt.Printf("DAT: verifying %s of %s files against %s from the listing\n", formatCount(covered), formatCount(len(files)), f.Name)
+		return datfile
+	}
+	return nil
+}
+
+// applyDATFilters narrows the pattern-matched selection using the DAT: category and
+// status criteria, serial filtering and deduplication, and MAME set dependencies.
+// The full listing is needed to pull in parent, BIOS, and device sets.
+func applyDATFilters(datfile *dat.Datafile, listing, selected []parser.FileInfo, criteria dat.Criteria, setType dat.SetType) []parser.FileInfo {
+	selected, decisions := datfile.FilterByMetadata(selected, criteria)
+	if explain {
+		fmt.Println("\nDAT decisions:")
+		for _, d := range decisions {
+			verdict := "keep"
+			if !d.Keep {
+				verdict = "drop"
+			}
+			fmt.Printf("  %s  %s  [%s]\n", verdict, d.File.Name, d.Reason)
+		}
+		fmt.Println()
+	}
+
+	selected = datfile.FilterBySerial(selected, serialPatterns)
+	if dedupeSerial {
+		before, beforeSize := len(selected), totalSize(selected)
+		selected = datfile.DedupeBySerial(selected)
+		if verbose && before != len(selected) {
+			fmt.Printf("Dropped %d files (%s) sharing a serial with another title\n",
+				before-len(selected), formatBytes(beforeSize-totalSize(selected)))
+		}
+	}
+
+	if datfile.IsMAME() {
+		if withDeps {
+			var missing []dat.MissingSet
+			before := len(selected)
+			selected, missing = datfile.WithDependencies(selected, listing, setType)
+			if added := len(selected) - before; added > 0 {
+				fmt.Printf("Added %d required parent/BIOS/device sets\n", added)
+			}
+			for _, m := range missing {
+				fmt.Printf("  ⚠ %s requires %s, which is not in the listing\n", m.Game, m.Required)
+			}
+		}
+		if withDeps || verbose {
+			printSetReport(datfile.CheckSet(selected, setType))
+		}
+	}
+
+	return selected
+}
+
+// printSetReport prints whether the selection forms a working MAME set
+func printSetReport(report dat.SetReport) {
+	if len(report.Incomplete) == 0 {
+		fmt.Printf("Set check (%s): all %d games have their required sets\n", report.Type, report.Working)
+		return
+	}
+
+	fmt.Printf("Set check (%s): %d working, %d incomplete\n", report.Type, report.Working, len(report.Incomplete))
+	if verbose {
+		names := make([]string, 0, len(report.Incomplete))
+		for name := range report.Incomplete {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("  - %s (missing: %s)\n", name, strings.Join(report.Incomplete[name], ", "))
+		}
+	} else if !withDeps {
+		fmt.Println("  Use --with-deps to pull required sets, or --verbose for details")
+	}
+}
+
+// datChecker verifies downloads against the DAT and tallies the results
+type datChecker struct {
+	datfile *dat.Datafile
+	mode    dat.VerifyMode
+
+	mu         sync.Mutex
+	verified   int
+	mismatched int // Kept despite not matching, with --dat-verify warn
+	unknown    int // Not in the DAT
+	skipped    int // Couldn't be checked
+}
+
+// verifier returns a downloader verifier that checks each download's contents
+// against its DAT entry. In strict mode a mismatch is a corrupt download.
+func (c *datChecker) verifier() downloader.Verifier {
+	return downloader.VerifierFunc(func(file parser.FileInfo, path string) error {
+		v, err := c.datfile.Verify(file.Name, path)
+		if err != nil {
+			return err
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		name := filepath.Base(file.Name)
+		switch {
+		case v.Game == "":
+			c.unknown++
+			if verbose {
+				fmt.Printf("  ⚠ %s is not in the DAT, not verified\n", name)
+			}
+		case v.Skipped != "":
+			c.skipped++
+			if verbose {
+				fmt.Printf("  ⚠ %s not verified: %s\n", name, v.Skipped)
+			}
+		case !v.OK():
+			problems := strings.Join(v.Problems, "; ")
+			if c.mode == dat.VerifyStrict {
+				return fmt.Errorf("%w: %s doesn't match the DAT: %s", downloader.ErrCorrupt, name, problems)
+			}
+			c.mismatched++
+			fmt.Printf("  ⚠ %s doesn't match the DAT: %s\n", name, problems)
+		default:
+			c.verified++
+			if verbose {
+				fmt.Printf("  ✓ Verified against the DAT (%d ROMs)\n", v.Verified)
+			}
+		}
+		return nil
+	})
+}
+
+// printSummary reports how the downloads compared with the DAT
+func (c *datChecker) printSummary() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Printf("  DAT: %d files verified", c.verified)
+	if c.mismatched > 0 {
+		fmt.Printf(", %d kept despite not matching", c.mismatched)
+	}
+	if c.unknown > 0 {
+		fmt.Printf(", %d not in the DAT", c.unknown)
+	}
+	if c.skipped > 0 {
+		fmt.Printf(", %d could not be checked", c.skipped)
+	}
+	fmt.Println()
+}
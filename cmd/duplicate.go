@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/history"
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// duplicateRunAge is how far back the history is searched for a finished run
+// of the same selection
+const duplicateRunAge = 30 * 24 * time.Hour
+
+// selectionFilters describes the selection flags in effect, for the plan hash
+func selectionFilters() []string {
+	return []string{
+		"include=" + strings.Join(includePatterns, ","),
+		"exclude=" + strings.Join(excludePatterns, ","),
+		"include-regex=" + strings.Join(includeRegexps, ","),
+		"exclude-regex=" + strings.Join(excludeRegexps, ","),
+		"match-scope=" + matchScope,
+		"exclude-kinds=" + joinKinds(excludedKinds()),
+		"dat=" + datFile,
+		"serial=" + strings.Join(serialPatterns, ","),
+		fmt.Sprintf("dedupe-serial=%t", dedupeSerial),
+		fmt.Sprintf("with-deps=%t", withDeps),
+		"set-type=" + setType,
+		fmt.Sprintf("with-bios=%t", withBIOS),
+		"category=" + strings.Join(categories, ","),
+		"exclude-category=" + strings.Join(excludeCategories, ","),
+		"status=" + strings.Join(statuses, ","),
+		"exclude-status=" + strings.Join(excludeStatuses, ","),
+		"on-duplicate=" + onDuplicate,
+		fmt.Sprintf("prefer-smallest=%t", preferSmallest),
+		fmt.Sprintf("limit=%d", limit),
+		"min-size=" + minSize,
+		"max-size=" + maxSize,
+		"newer-than=" + newerThan,
+		"older-than=" + olderThan,
+		"max-total=" + maxTotal,
+		"skip-over=" + skipOver,
+		fmt.Sprintf("recursive=%t", recursive),
+		fmt.Sprintf("max-depth=%d", maxDepth),
+	}
+}
+
+// warnDuplicateRun warns when the same selection recently finished
+// downloading into another directory, which usually means a repeated command
+// with the wrong -o. In the same directory its files are skipped anyway.
+func warnDuplicateRun(hash, dir string) {
+	path, err := history.DefaultPath()
+	if err != nil {
+		return
+	}
+	entries, err := history.Read(path, time.Now().Add(-duplicateRunAge))
+	if err != nil {
+		return
+	}
+	run, ok := history.LastRun(entries, hash)
+	if !ok {
+		return
+	}
+	if abs, err := filepath.Abs(dir); err != nil || abs == run.Path {
+		return
+	}
+	fmt.Printf("  ⚠ This exact selection finished downloading into %s on %s; check -o if you didn't mean to download it again\n",
+		run.Path, run.Time.Local().Format("2006-01-02 15:04"))
+}
+
+// recordRun logs a run that downloaded every file of its selection, so a
+// repeat into another directory can be spotted
+func recordRun(log *history.Log, hash, source, dir string, files []parser.FileInfo) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		abs = dir
+	}
+	err = log.Append(history.Entry{
+		Time:   time.Now().UTC(),
+		Source: source,
+		Path:   abs,
+		Bytes:  totalSize(files),
+		Result: history.ResultFinished,
+		Plan:   hash,
+	})
+	if err != nil {
+		fmt.Printf("  ⚠ Failed to write history: %v\n", err)
+	}
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/nchapman/myrient-dl/internal/downloader"
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// exactSizeWorkers is how many HEAD requests --exact-sizes has in flight
+const exactSizeWorkers = 4
+
+// exactSizes replaces listed sizes with the ones the server reports
+var exactSizes bool
+
+// reconcileSizes asks the server for the exact size of each file with a HEAD
+// request, so budgets, size limits, skip decisions, and progress totals don't
+// rely on the listing's rounded (or missing) sizes. Files the server reports
+// no size for keep their listed one, and files a JSON listing already gave
+// byte counts for are left alone. Returns nil if interrupted.
+func reconcileSizes(ctx context.Context, files []parser.FileInfo) []parser.FileInfo {
+	var pending []int
+	for i, f := range files {
+		if !f.Exact {
+			pending = append(pending, i)
+		}
+	}
+	if len(pending) == 0 {
+		return files
+	}
+	fmt.Printf("Checking the exact sizes of %s files...\n", formatCount(len(pending)))
+	dl := downloader.New(downloader.Config{Auth: credentials, Identity: identity, RequestInterval: requestInterval})
+
+	exact := make([]parser.FileInfo, len(files))
+	copy(exact, files)
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		unknown  int
+		listed   int64
+		reported int64
+	)
+	work := make(chan int)
+	for range min(exactSizeWorkers, len(pending)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				size, err := dl.Size(ctx, files[i].URL)
+				mu.Lock()
+				if err != nil {
+					unknown++
+					if verbose && ctx.Err() == nil {
+						fmt.Printf("  ⚠ %s: %v\n", files[i].Name, err)
+					}
+				} else {
+					exact[i].Size, exact[i].Exact = size, true
+					listed += files[i].Size
+					reported += size
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, i := range pending {
+		if ctx.Err() != nil {
+			break
+		}
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	if verbose {
+		fmt.Printf("Exact sizes: %s listed as %s\n", formatBytes(reported), formatBytes(listed))
+	}
+	if unknown > 0 {
+		fmt.Printf("  ⚠ Couldn't get the exact size of %s files; using their listed sizes\n", formatCount(unknown))
+	}
+	return exact
+}
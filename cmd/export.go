@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/nchapman/myrient-dl/internal/export"
+	"github.com/nchapman/myrient-dl/internal/hashing"
+	"github.com/nchapman/myrient-dl/internal/manifest"
+	"github.com/nchapman/myrient-dl/internal/version"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportFormat string
+	exportOutput string
+	exportScan   bool
+	exportName   string
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export DIR",
+	Short: "Export a collection for ROM managers",
+	Long: `Describe the sets in a download directory in a format ROM managers read.
+
+  have  ClrMamePro have-list: one set name per line
+  dat   Logiqx XML DAT of the sets and their ROMs, for auditing in RomVault or
+        ClrMamePro
+
+Sets come from the directory's manifest (.myrient-dl.json) when it has one, or
+from scanning the directory with --scan. For the dat format, zip members are
+read from each zip's index, so zips must be present; loose files use the
+manifest's digest when it is CRC32, MD5, or SHA-1, and are hashed with SHA-1
+when scanning.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runExport,
+}
+
+func init() {
+	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", "have", "Export format: have (ClrMamePro) or dat (Logiqx XML, for RomVault)")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Write the export to this file instead of stdout")
+	exportCmd.Flags().BoolVar(&exportScan, "scan", false, "Scan the directory instead of reading its manifest")
+	exportCmd.Flags().StringVar(&exportName, "name", "", "Collection name in the DAT header (default: directory name)")
+	exportCmd.Flags().IntVarP(&hashWorkers, "workers", "w", 0, "Number of files hashed at once when scanning (0 = one per CPU)")
+
+	rootCmd.AddCommand(exportCmd)
+}
+
+func runExport(_ *cobra.Command, args []string) error {
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	format, err := export.ParseFormat(exportFormat)
+	if err != nil {
+		return err
+	}
+	dir := args[0]
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("not a directory: %s", dir)
+	}
+
+	var entries []manifest.Entry
+	source := ""
+	if !exportScan {
+		m, err := manifest.Load(dir)
+		if err != nil {
+			return err
+		}
+		entries, source = m.Entries(), m.Source
+		if len(entries) == 0 {
+			fmt.Fprintf(os.Stderr, "No manifest in %s; scanning the directory instead\n", dir)
+		}
+	}
+	if len(entries) == 0 {
+		if entries, err = scanEntries(ctx, dir, format); err != nil {
+			return err
+		}
+	}
+
+	sets, warnings := exportSets(dir, entries, format)
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "  ⚠ %s\n", w)
+	}
+
+	out := io.Writer(os.Stdout)
+	if exportOutput != "" {
+		f, err := os.Create(exportOutput) //nolint:gosec // Output path is provided by the user
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", exportOutput, err)
+		}
+		defer func() { _ = f.Close() }()
+		out = f
+	}
+
+	switch format {
+	case export.FormatHave:
+		err = export.WriteHaveList(out, sets)
+	case export.FormatDAT:
+		name := exportName
+		if name == "" {
+			abs, _ := filepath.Abs(dir)
+			name = filepath.Base(abs)
+		}
+		err = export.WriteDAT(out, export.Header{
+			Name:        name,
+			Description: name + " (have)",
+			Author:      "myrient-dl " + version.Version,
+			URL:         source,
+		}, sets)
+	}
+	if err != nil {
+		return err
+	}
+
+	// The summary goes to stderr so stdout stays a clean export
+	fmt.Fprintf(os.Stderr, "Exported %d sets as %s\n", len(sets), format)
+	return nil
+}
+
+// scanEntries describes the files on disk as manifest entries. Loose files are
+// hashed with SHA-1 for DATs; zips are described by their members instead.
+func scanEntries(ctx context.Context, dir string, format export.Format) ([]manifest.Entry, error) {
+	paths, _, err := downloadedFiles(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+
+	entries := make([]manifest.Entry, 0, len(paths))
+	var loose []string
+	for _, p := range paths {
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, manifest.Entry{Path: filepath.ToSlash(rel), Size: info.Size()})
+		if format == export.FormatDAT && !export.IsArchive(rel) {
+			loose = append(loose, p)
+		}
+	}
+	if len(loose) == 0 {
+		return entries, nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Hashing %d loose files with %s...\n", len(loose), hashing.SHA1)
+	results, err := hashing.Files(ctx, loose, hashing.SHA1, hashWorkers)
+	if err != nil {
+		return nil, err
+	}
+	digests := make(map[string]string, len(results))
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(os.Stderr, "  ⚠ %v\n", r.Err)
+			continue
+		}
+		rel, _ := filepath.Rel(dir, r.Path)
+		digests[filepath.ToSlash(rel)] = r.Digest
+	}
+	for i, e := range entries {
+		if digest, ok := digests[e.Path]; ok {
+			entries[i].Algorithm, entries[i].Hash = hashing.SHA1, digest
+		}
+	}
+	return entries, nil
+}
+
+// exportSets turns entries into sets, reading zip members for DATs
+func exportSets(dir string, entries []manifest.Entry, format export.Format) ([]export.Set, []string) {
+	var sets []export.Set
+	var warnings []string
+	unhashed := 0
+	for _, e := range entries {
+		switch {
+		case format == export.FormatHave:
+			sets = append(sets, export.Set{Name: export.SetName(e.Path)})
+		case export.IsArchive(e.Path):
+			set, err := export.ZipSet(filepath.Join(dir, filepath.FromSlash(e.Path)), e.Path)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("Left out %s: %v", e.Path, err))
+				continue
+			}
+			sets = append(sets, set)
+		default:
+			set := export.FileSet(e.Path, e.Size, e.Algorithm, e.Hash)
+			if rom := set.ROMs[0]; rom.CRC == "" && rom.MD5 == "" && rom.SHA1 == "" {
+				unhashed++
+			}
+			sets = append(sets, set)
+		}
+	}
+	if unhashed > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d loose files have no CRC32, MD5, or SHA-1 in the manifest and are listed by size only; use --scan to hash them", unhashed))
+	}
+	return sets, warnings
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/nchapman/myrient-dl/internal/archive"
+	"github.com/nchapman/myrient-dl/internal/downloader"
+	"github.com/nchapman/myrient-dl/internal/manifest"
+	"github.com/nchapman/myrient-dl/internal/parser"
+	"github.com/nchapman/myrient-dl/internal/sidecar"
+)
+
+var (
+	extractArchives bool
+	extractTo       string
+	deleteArchives  bool
+)
+
+// extractor unpacks zip archives as a run finishes them, for emulators that
+// want loose ROMs
+type extractor struct {
+	dir  string       // Output directory
+	root string       // Where archives are unpacked, keeping their subdirectory
+	log  *manifestLog // Set once the run's manifest is open
+
+	mu       sync.Mutex
+	archives int
+	files    int
+	bytes    int64
+	failed   int
+	other    int // 7z, RAR, and other archives that can't be unpacked
+}
+
+// newExtractor checks the extraction flags, returning nil without --extract
+func newExtractor(dir string) (*extractor, error) {
+	if !extractArchives {
+		if extractTo != "" || deleteArchives {
+			return nil, fmt.Errorf("--extract-to and --delete-archives require --extract")
+		}
+		return nil, nil
+	}
+	root := dir
+	if extractTo != "" {
+		root = extractTo
+	}
+	return &extractor{dir: dir, root: root}, nil
+}
+
+// recorder unpacks each archive once it's downloaded and verified, or found
+// complete, then deletes it with --delete-archives. It must come after the
+// manifest's recorder, which records the archive first.
+func (x *extractor) recorder() func(downloader.Event) {
+	return func(e downloader.Event) {
+		if (e.Type != downloader.EventCompleted && e.Type != downloader.EventSkipped) || e.Path == "" {
+			return
+		}
+		if !archive.IsZip(e.Path) {
+			if archive.IsOther(e.Path) {
+				x.mu.Lock()
+				x.other++
+				x.mu.Unlock()
+			}
+			return
+		}
+
+		archivePath := filepath.Join(x.dir, e.Path)
+		paths, size, err := archive.Extract(archivePath, filepath.Join(x.root, filepath.Dir(e.Path)))
+		if err != nil {
+			fmt.Printf("  ✗ Failed to extract %s: %v\n", e.Path, err)
+			x.mu.Lock()
+			x.failed++
+			x.mu.Unlock()
+			return
+		}
+
+		recorded := make([]string, len(paths))
+		for i, p := range paths {
+			recorded[i] = x.recordedPath(filepath.Join(x.root, filepath.Dir(e.Path), p))
+		}
+		deleted := false
+		if deleteArchives {
+			if err := os.Remove(archivePath); err != nil {
+				fmt.Printf("  ⚠ Failed to delete %s after extracting it: %v\n", e.Path, err)
+			} else {
+				_ = os.Remove(sidecar.Path(archivePath))
+				deleted = true
+			}
+		}
+		x.log.extracted(e.Path, recorded, deleted)
+		if verbose {
+			fmt.Printf("  ✓ Extracted %d files (%s) from %s\n", len(paths), formatBytes(size), e.Path)
+		}
+
+		x.mu.Lock()
+		defer x.mu.Unlock()
+		x.archives++
+		x.files += len(paths)
+		x.bytes += size
+	}
+}
+
+// recordedPath is how the manifest records an extracted file: relative to the
+// output directory when it's inside it, absolute otherwise
+func (x *extractor) recordedPath(path string) string {
+	if rel, err := filepath.Rel(x.dir, path); err == nil && filepath.IsLocal(rel) {
+		return filepath.ToSlash(rel)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return filepath.ToSlash(path)
+}
+
+// finish reports what was extracted
+func (x *extractor) finish() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	fmt.Printf("  Extracted %s files (%s) from %s archives", formatCount(x.files), formatBytes(x.bytes), formatCount(x.archives))
+	if x.root != x.dir {
+		fmt.Printf(" into %s", x.root)
+	}
+	if deleteArchives {
+		fmt.Print(", deleting the archives")
+	}
+	fmt.Println()
+	if x.other > 0 {
+		fmt.Printf("  ⚠ %d 7z, RAR, or other archives were left as they are; only zips can be extracted\n", x.other)
+	}
+	if x.failed > 0 {
+		return fmt.Errorf("%d archives failed to extract", x.failed)
+	}
+	return nil
+}
+
+// extractedArchives returns the manifest entries, by URL, of archives that an
+// earlier run extracted and deleted and whose files are all still there
+func extractedArchives(dir string) map[string]manifest.Entry {
+	man, err := manifest.Load(dir)
+	if err != nil {
+		return nil
+	}
+	done := make(map[string]manifest.Entry)
+	for _, e := range man.Entries() {
+		if e.ArchiveDeleted && e.URL != "" && extractedPresent(dir, e.Extracted) {
+			done[e.URL] = e
+		}
+	}
+	return done
+}
+
+func extractedPresent(dir string, paths []string) bool {
+	for _, p := range paths {
+		local := filepath.FromSlash(p)
+		if !filepath.IsAbs(local) {
+			local = filepath.Join(dir, local)
+		}
+		if _, err := os.Stat(local); err != nil {
+			return false
+		}
+	}
+	return len(paths) > 0
+}
+
+// skipExtracted leaves out archives that were extracted and deleted, so they
+// aren't downloaded again; --refresh downloads them anyway
+func skipExtracted(dir string, files []parser.FileInfo) []parser.FileInfo {
+	if refresh {
+		return files
+	}
+	done := extractedArchives(dir)
+	if len(done) == 0 {
+		return files
+	}
+	kept := make([]parser.FileInfo, 0, len(files))
+	for _, f := range files {
+		if _, ok := done[f.URL]; !ok {
+			kept = append(kept, f)
+		}
+	}
+	if skipped := len(files) - len(kept); skipped > 0 {
+		fmt.Printf("Skipping %s archives already extracted and deleted (--refresh downloads them again)\n", formatCount(skipped))
+	}
+	return kept
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/nchapman/myrient-dl/internal/archive"
+	"github.com/nchapman/myrient-dl/internal/downloader"
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// extractedWorkers is how many zip directories are read at once
+const extractedWorkers = 4
+
+// extractedSizes reports the selection's size once unzipped
+var extractedSizes bool
+
+// extractedEstimate is what a selection takes up once its zips are extracted
+type extractedEstimate struct {
+	sizes  map[string]int64 // Extracted size of each zip that could be read, by URL
+	total  int64            // Counting unread archives and other files at their download size
+	unread int              // Archives whose contents couldn't be read
+}
+
+// add counts another estimate in this one
+func (e *extractedEstimate) add(o *extractedEstimate) {
+	for u, size := range o.sizes {
+		e.sizes[u] = size
+	}
+	e.total += o.total
+	e.unread += o.unread
+}
+
+// estimateExtracted reads the central directory of every selected zip: from
+// the local copy when it's already complete in dir, otherwise with Range
+// requests for the end of the remote file. Returns nil if interrupted.
+func estimateExtracted(ctx context.Context, dir string, files []parser.FileInfo) *extractedEstimate {
+	est := &extractedEstimate{sizes: map[string]int64{}}
+	var zips []parser.FileInfo
+	for _, f := range files {
+		switch {
+		case archive.IsZip(f.Name):
+			zips = append(zips, f)
+		case archive.IsOther(f.Name):
+			est.unread++
+			est.total += f.Size
+		default:
+			est.total += f.Size
+		}
+	}
+	if len(zips) == 0 {
+		return est
+	}
+
+	fmt.Printf("Reading the contents of %s zip archives...\n", formatCount(len(zips)))
+	dl := downloader.New(downloader.Config{Auth: credentials, Identity: identity, RequestInterval: requestInterval})
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		noRange bool
+	)
+	work := make(chan parser.FileInfo)
+	for range min(extractedWorkers, len(zips)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range work {
+				size, err := zipExtractedSize(ctx, dl, dir, f)
+				mu.Lock()
+				switch {
+				case err == nil:
+					est.sizes[f.URL] = size
+					est.total += size
+				default:
+					est.unread++
+					est.total += f.Size
+					noRange = noRange || errors.Is(err, downloader.ErrNoRanges)
+					if verbose && ctx.Err() == nil && !errors.Is(err, downloader.ErrNoRanges) {
+						fmt.Printf("  ⚠ %s: %v\n", f.Name, err)
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, f := range zips {
+		if ctx.Err() != nil {
+			break
+		}
+		work <- f
+	}
+	close(work)
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	if noRange {
+		fmt.Println("  ⚠ The server doesn't support range requests, so zips not downloaded yet can't be read")
+	}
+	if est.unread > 0 {
+		fmt.Printf("  ⚠ Couldn't read the contents of %s archives; counting them at their download size\n", formatCount(est.unread))
+	}
+	return est
+}
+
+// zipExtractedSize reads one zip's extracted size, locally if it's been downloaded
+func zipExtractedSize(ctx context.Context, dl *downloader.Downloader, dir string, f parser.FileInfo) (int64, error) {
+	if file, err := os.Open(filepath.Join(dir, f.Name)); err == nil { //nolint:gosec // Path is inside the download directory
+		defer func() { _ = file.Close() }()
+		if info, err := file.Stat(); err == nil {
+			if size, err := archive.ExtractedSize(file, info.Size()); err == nil {
+				return size, nil
+			}
+		}
+	}
+	r, size, err := dl.RemoteFile(ctx, f.URL)
+	if err != nil {
+		return 0, err
+	}
+	return archive.ExtractedSize(r, size)
+}
+
+// describe formats a file's size, with its extracted size when known
+func (e *extractedEstimate) describe(f parser.FileInfo) string {
+	if e == nil {
+		return formatBytes(f.Size)
+	}
+	if size, ok := e.sizes[f.URL]; ok {
+		return fmt.Sprintf("%s, %s extracted", formatBytes(f.Size), formatBytes(size))
+	}
+	return formatBytes(f.Size)
+}
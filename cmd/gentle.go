@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/downloader"
+	"github.com/nchapman/myrient-dl/internal/units"
+	"github.com/spf13/cobra"
+)
+
+// The --gentle profile: one download at a time, paced requests, a bandwidth cap,
+// long backoff, and new files only starting at night
+const (
+	gentleRequestInterval = time.Second
+	gentleRateLimit       = 2 * 1024 * 1024
+	gentleBackoffBase     = 10 * time.Second
+	gentleBackoffMax      = 5 * time.Minute
+	gentleWindow          = "01:00-07:00"
+)
+
+var (
+	gentle         bool
+	limitRate      string
+	downloadWindow string
+
+	// Set by profiles such as --gentle
+	requestInterval time.Duration
+	rateLimit       int64
+	backoffBase     time.Duration
+	backoffMax      time.Duration
+	window          *downloader.Window
+	suspend         bool // Pause running downloads when the window closes
+)
+
+// applyLimitRate parses --limit-rate into the bandwidth cap. It runs before
+// profiles such as --gentle, which keep an explicit cap.
+func applyLimitRate() error {
+	if limitRate == "" {
+		return nil
+	}
+	limit, err := units.ParseRate(limitRate)
+	if err != nil {
+		return fmt.Errorf("invalid --limit-rate: %w", err)
+	}
+	rateLimit = limit
+	return nil
+}
+
+// applyWindow parses --window, which suspends running downloads when it
+// closes rather than only holding back new ones. Like --limit-rate, it runs
+// before --gentle, which keeps it.
+func applyWindow() error {
+	if downloadWindow == "" {
+		return nil
+	}
+	w, err := downloader.ParseWindow(downloadWindow)
+	if err != nil {
+		return fmt.Errorf("invalid --window: %w", err)
+	}
+	window = &w
+	suspend = true
+	return nil
+}
+
+// applyGentle turns on the --gentle profile. Flags given explicitly, such as
+// --parallel, keep their values.
+func applyGentle(c *cobra.Command) error {
+	if !gentle {
+		return nil
+	}
+
+	if !c.Flags().Changed("parallel") {
+		parallel = 1
+	}
+	if !c.Flags().Changed("segments") {
+		segments = 1
+	}
+	requestInterval = gentleRequestInterval
+	if !c.Flags().Changed("limit-rate") {
+		rateLimit = gentleRateLimit
+	}
+	backoffBase = gentleBackoffBase
+	backoffMax = gentleBackoffMax
+	if !c.Flags().Changed("window") {
+		w, err := downloader.ParseWindow(gentleWindow)
+		if err != nil {
+			return err
+		}
+		window = &w
+	}
+
+	fmt.Printf("Gentle mode: %d at a time, at most 1 request/s and %s/s, retries back off from %v, new files start only between %s\n",
+		parallel, formatBytes(rateLimit), backoffBase, window)
+	return nil
+}
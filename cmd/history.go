@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/downloader"
+	"github.com/nchapman/myrient-dl/internal/history"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historySince  string
+	historyFailed bool
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show past downloads",
+	Long: `List downloads recorded in the history log, with timestamps, sizes,
+durations, and results.
+
+Every completed or failed download is appended to history.jsonl in the
+user config directory, independently of any download directory.`,
+	Args: cobra.NoArgs,
+	RunE: runHistory,
+}
+
+func init() {
+	historyCmd.Flags().StringVar(&historySince, "since", "", "Only show downloads since a lookback (7d, 2w, 36h) or UTC date (2024-05-01)")
+	historyCmd.Flags().BoolVar(&historyFailed, "failed", false, "Only show failed downloads")
+
+	rootCmd.AddCommand(historyCmd)
+}
+
+func runHistory(_ *cobra.Command, _ []string) error {
+	var since time.Time
+	if historySince != "" {
+		t, err := history.ParseSince(historySince, time.Now())
+		if err != nil {
+			return err
+		}
+		since = t
+	}
+
+	path, err := history.DefaultPath()
+	if err != nil {
+		return err
+	}
+	entries, err := history.Read(path, since)
+	if err != nil {
+		return err
+	}
+
+	var shown, failed int
+	var bytes int64
+	for _, e := range entries {
+		if e.Result == history.ResultFinished {
+			continue // A whole run, not a download
+		}
+		if historyFailed && e.Result != history.ResultFailed {
+			continue
+		}
+		shown++
+		mark := "✓"
+		if e.Result == history.ResultFailed {
+			mark = "✗"
+			failed++
+		} else {
+			bytes += e.Bytes
+		}
+		fmt.Printf("%s %s %10s %8s  %s\n", e.Time.Local().Format("2006-01-02 15:04"), mark,
+			formatBytes(e.Bytes), e.Duration.Round(time.Second), e.Name)
+		if verbose {
+			fmt.Printf("      %s -> %s\n", e.URL, e.Path)
+		}
+		if e.Error != "" && (verbose || historyFailed) {
+			fmt.Printf("      %s\n", e.Error)
+		}
+	}
+
+	if shown == 0 {
+		fmt.Println("No downloads recorded")
+		return nil
+	}
+	fmt.Printf("\n%d downloads (%d failed), %s downloaded\n", shown, failed, formatBytes(bytes))
+	return nil
+}
+
+// openHistory opens the default history log for appending
+func openHistory() (*history.Log, error) {
+	path, err := history.DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return history.Open(path)
+}
+
+// historyRecorder returns a download event handler that logs finished files
+func historyRecorder(log *history.Log, source, dir string) func(downloader.Event) {
+	var warnOnce sync.Once
+	return func(e downloader.Event) {
+		result := history.ResultCompleted
+		switch e.Type {
+		case downloader.EventCompleted:
+		case downloader.EventFailed:
+			result = history.ResultFailed
+		default:
+			return // Skipped files weren't downloaded this time
+		}
+
+		local := e.Path
+		if local == "" {
+			local = e.File.Name
+		}
+		entry := history.Entry{
+			Time:     time.Now().UTC(),
+			Source:   source,
+			Name:     e.File.Name,
+			URL:      e.File.URL,
+			Mirror:   e.Mirror,
+			Path:     filepath.Join(dir, local),
+			Bytes:    e.Bytes,
+			Duration: e.Duration,
+			Result:   result,
+		}
+		if entry.Bytes == 0 {
+			entry.Bytes = e.File.Size // Failed before the server reported a size
+		}
+		if e.Err != nil {
+			entry.Error = e.Err.Error()
+		}
+		if err := log.Append(entry); err != nil {
+			warnOnce.Do(func() { fmt.Printf("  ⚠ Failed to write history: %v\n", err) })
+		}
+	}
+}
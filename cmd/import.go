@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nchapman/myrient-dl/internal/hashing"
+	"github.com/nchapman/myrient-dl/internal/manifest"
+	"github.com/nchapman/myrient-dl/internal/parser"
+	"github.com/spf13/cobra"
+)
+
+var importSource string
+
+var importCmd = &cobra.Command{
+	Use:   "import DIR --source URL",
+	Short: "Record an existing collection in the manifest",
+	Long: `Scan a directory filled by another tool, hash its files, and record them in
+the directory's manifest (.myrient-dl.json) along with their URLs in the source
+listing, as if myrient-dl had downloaded them.
+
+Files are matched to the listing by their path relative to DIR. Files that are
+not in the listing, or whose size clearly differs from it, are still recorded
+but without a URL.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runImport,
+}
+
+func init() {
+	importCmd.Flags().StringVar(&importSource, "source", "", "Listing URL the files came from (required)")
+	importCmd.Flags().StringVarP(&hashAlgorithm, "algo", "a", "sha1", "Hash algorithm: crc32, md5, sha1, sha256, xxh64, or blake3")
+	importCmd.Flags().IntVarP(&hashWorkers, "workers", "w", 0, "Number of files hashed at once (0 = one per CPU)")
+	_ = importCmd.MarkFlagRequired("source")
+	addListingFlags(importCmd)
+
+	rootCmd.AddCommand(importCmd)
+}
+
+func runImport(_ *cobra.Command, args []string) error {
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	algorithm, err := hashing.Parse(hashAlgorithm)
+	if err != nil {
+		return err
+	}
+	dir := args[0]
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("not a directory: %s", dir)
+	}
+
+	fmt.Println("Fetching directory listing...")
+	trustListing(importSource)
+	listing, err := parser.ParseDirectoryListing(ctx, importSource, listingOptions())
+	if err != nil {
+		return fmt.Errorf("failed to parse directory listing: %w", err)
+	}
+	listed := make(map[string]parser.FileInfo, len(listing))
+	for _, f := range listing {
+		listed[filepath.ToSlash(f.Name)] = f
+	}
+
+	m, err := manifest.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	paths, bytes, err := downloadedFiles(dir)
+	if err != nil {
+		return fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+	fmt.Printf("Hashing %d files (%s) with %s...\n", len(paths), formatBytes(bytes), algorithm)
+	results, err := hashing.Files(ctx, paths, algorithm, hashWorkers)
+	if err != nil {
+		return err
+	}
+
+	var matched, unlisted, differ, failed int
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			fmt.Printf("  ⚠ %v\n", r.Err)
+			continue
+		}
+		info, err := os.Stat(r.Path)
+		if err != nil {
+			failed++
+			fmt.Printf("  ⚠ %v\n", err)
+			continue
+		}
+		rel, err := filepath.Rel(dir, r.Path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		entry := manifest.Entry{
+			Path:        rel,
+			Size:        info.Size(),
+			Algorithm:   algorithm,
+			Hash:        r.Digest,
+			CompletedAt: info.ModTime().UTC(),
+			Imported:    true,
+		}
+		remote, ok := listed[rel]
+		switch {
+		case !ok:
+			unlisted++
+			if verbose {
+				fmt.Printf("  - %s (not in the listing)\n", rel)
+			}
+		case remote.Size > 0 && sizeDiffers(remote.Size, info.Size()):
+			differ++
+			fmt.Printf("  ⚠ %s: local size %s differs from listed %s\n", rel, formatBytes(info.Size()), formatBytes(remote.Size))
+		default:
+			matched++
+			entry.URL = remote.URL
+		}
+		m.Put(entry)
+	}
+
+	m.Source = importSource
+	if err := m.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n✓ Recorded %d files in %s\n", len(results)-failed, manifest.Path(dir))
+	fmt.Printf("  %d match the listing, %d not in the listing, %d differ in size\n", matched, unlisted, differ)
+	if missing := len(listing) - matched - differ; missing > 0 {
+		fmt.Printf("  %d listed files are not in %s yet\n", missing, dir)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to hash %d files", failed)
+	}
+	return nil
+}
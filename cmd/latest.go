@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/nchapman/myrient-dl/internal/downloader"
+	"github.com/nchapman/myrient-dl/internal/latest"
+)
+
+var latestLinks bool
+
+// openLatest brings the latest/ links of a download directory up to date with
+// what is already there and returns an event handler that moves them as newer
+// revisions arrive
+func openLatest(dir string) (func(downloader.Event), error) {
+	paths, _, err := downloadedFiles(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+	rels := make([]string, 0, len(paths))
+	for _, p := range paths {
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return nil, err
+		}
+		rels = append(rels, filepath.ToSlash(rel))
+	}
+
+	view, err := latest.Open(dir, rels)
+	if err != nil {
+		return nil, err
+	}
+	if verbose {
+		fmt.Printf("Linked the newest revision of %d releases in %s\n", view.Len(), filepath.Join(dir, latest.Dir))
+	}
+
+	return func(e downloader.Event) {
+		if e.Type != downloader.EventCompleted {
+			return
+		}
+		local := e.Path
+		if local == "" {
+			local = e.File.Name
+		}
+		if _, err := view.Add(filepath.ToSlash(local)); err != nil {
+			fmt.Printf("  ⚠ %v\n", err)
+		}
+	}, nil
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/downloader"
+	"github.com/nchapman/myrient-dl/internal/logging"
+)
+
+var (
+	logFile   string
+	logLevel  string
+	logFormat string
+)
+
+// openRunLog opens --log-file, returning nil without it
+func openRunLog() (*logging.File, error) {
+	if logFile == "" {
+		return nil, nil
+	}
+	level, err := logging.ParseLevel(logLevel)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --log-level: %w", err)
+	}
+	format, err := logging.ParseFormat(logFormat)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --log-format: %w", err)
+	}
+	return logging.Open(logFile, level, format)
+}
+
+// logRecorder returns a download event handler that logs each file's outcome:
+// completions and skips at info, placeholders and discarded files at warn,
+// and failures at error
+func logRecorder(log *slog.Logger, dir string) func(downloader.Event) {
+	return func(e downloader.Event) {
+		attrs := []any{"file", e.File.Name, "url", e.File.URL}
+		switch e.Type {
+		case downloader.EventStarted:
+			log.Debug("started", attrs...)
+		case downloader.EventCompleted:
+			attrs = append(attrs, "path", filepath.Join(dir, e.Path), "bytes", e.Bytes, "duration", e.Duration.Round(time.Millisecond))
+			if e.Mirror != "" {
+				attrs = append(attrs, "mirror", e.Mirror)
+			}
+			if e.SHA256 != "" {
+				attrs = append(attrs, "sha256", e.SHA256)
+			}
+			log.Info("completed", attrs...)
+		case downloader.EventSkipped:
+			log.Info("skipped", append(attrs, "path", filepath.Join(dir, e.Path), "reason", "already downloaded")...)
+		case downloader.EventPlaceholder:
+			log.Warn("placeholder", attrs...)
+		case downloader.EventRejected:
+			log.Warn("rejected", append(attrs, "error", e.Err.Error())...)
+		case downloader.EventGone:
+			log.Warn("gone", append(attrs, "error", e.Err.Error())...)
+		case downloader.EventFailed:
+			log.Error("failed", append(attrs, "duration", e.Duration.Round(time.Millisecond), "error", e.Err.Error())...)
+		}
+	}
+}
+
+// logRun records how a run ended
+func logRun(log *slog.Logger, summary downloader.Summary, err error) {
+	attrs := []any{"completed", summary.Completed, "skipped", summary.Skipped, "gone", summary.Gone, "failed", summary.Failed, "downloaded_bytes", summary.DownloadedBytes}
+	switch {
+	case errors.Is(err, downloader.ErrStopped):
+		log.Warn("run stopped", attrs...)
+	case err != nil:
+		log.Error("run failed", append(attrs, "error", err.Error())...)
+	default:
+		log.Info("run finished", attrs...)
+	}
+}
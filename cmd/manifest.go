@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/downloader"
+	"github.com/nchapman/myrient-dl/internal/hashing"
+	"github.com/nchapman/myrient-dl/internal/manifest"
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// refresh is --refresh: check every file with the server even if the manifest
+// records it as complete
+var refresh bool
+
+// manifestLog records a run's completed files in the directory's manifest
+type manifestLog struct {
+	man     *manifest.Manifest
+	dir     string
+	changed atomic.Bool
+}
+
+func openManifestLog(dir, source string) (*manifestLog, error) {
+	man, err := manifest.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	man.Source = source
+	return &manifestLog{man: man, dir: dir}, nil
+}
+
+// lookup returns where the manifest says each URL was saved, for
+// Config.Recorded, as of the start of the run
+func (l *manifestLog) lookup() func(parser.FileInfo) (string, int64, bool) {
+	byURL := make(map[string]manifest.Entry)
+	for _, e := range l.man.Entries() {
+		if e.URL != "" {
+			byURL[e.URL] = e
+		}
+	}
+	return func(f parser.FileInfo) (string, int64, bool) {
+		e, ok := byURL[f.URL]
+		return e.Path, e.Size, ok
+	}
+}
+
+// recorder adds downloaded files, and files found complete, to the manifest
+func (l *manifestLog) recorder() func(downloader.Event) {
+	return func(e downloader.Event) {
+		if e.Path == "" {
+			return
+		}
+		rel := filepath.ToSlash(e.Path)
+		switch e.Type {
+		case downloader.EventPlaceholder, downloader.EventRejected:
+			if l.man.Remove(rel) {
+				l.changed.Store(true)
+			}
+			return
+		case downloader.EventCompleted, downloader.EventSkipped:
+		default:
+			return
+		}
+
+		info, err := os.Stat(filepath.Join(l.dir, e.Path))
+		if err != nil {
+			return
+		}
+		previous, known := l.man.Get(rel)
+		if e.Type == downloader.EventSkipped && known && previous.URL == e.File.URL && previous.Size == info.Size() {
+			return // Still as recorded, digest and all
+		}
+
+		entry := manifest.Entry{Path: rel, URL: e.File.URL, Size: info.Size(), CompletedAt: time.Now().UTC()}
+		if e.SHA256 != "" {
+			entry.Algorithm, entry.Hash = hashing.SHA256, e.SHA256
+		}
+		l.man.Put(entry)
+		l.changed.Store(true)
+	}
+}
+
+// extracted records the files unpacked from an archive, and whether the
+// archive was deleted afterwards
+func (l *manifestLog) extracted(path string, files []string, deleted bool) {
+	e, ok := l.man.Get(path)
+	if !ok {
+		return
+	}
+	e.Extracted, e.ArchiveDeleted = files, deleted
+	l.man.Put(e)
+	l.changed.Store(true)
+}
+
+// forget drops a file set aside after the run from the manifest
+func (l *manifestLog) forget(path string) {
+	if l.man.Remove(filepath.ToSlash(path)) {
+		l.changed.Store(true)
+	}
+}
+
+// save writes the manifest if the run changed it
+func (l *manifestLog) save() {
+	if !l.changed.Load() {
+		return
+	}
+	if err := l.man.Save(); err != nil {
+		fmt.Printf("  ⚠ Failed to update the manifest: %v\n", err)
+	}
+}
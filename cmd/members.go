@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/archive"
+	"github.com/nchapman/myrient-dl/internal/cleanup"
+	"github.com/nchapman/myrient-dl/internal/downloader"
+	"github.com/nchapman/myrient-dl/internal/matcher"
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// extractMembers are globs for the zip members to fetch instead of whole archives
+var extractMembers []string
+
+// extractMemberFiles fetches only the members matching --extract-member from
+// each selected zip: the central directory, then one Range request per
+// member for its compressed bytes, which are inflated and checked against the
+// CRC32 as they're written. Members land in dir under the archive's directory.
+func extractMemberFiles(ctx context.Context, dir string, files []parser.FileInfo) error {
+	m := matcher.New(extractMembers, nil)
+	dl := downloader.New(downloader.Config{Auth: credentials, Identity: identity, RequestInterval: requestInterval, RateLimit: rateLimit})
+
+	fmt.Printf("\nExtracting members matching %v from %s archives...\n", extractMembers, formatCount(len(files)))
+	var extracted, failed int
+	var fetched, archived int64
+	for _, f := range files {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !archive.IsZip(f.Name) {
+			fmt.Printf("  ⚠ %s is not a zip archive, skipping\n", f.Name)
+			continue
+		}
+
+		r, size, err := dl.RemoteFile(ctx, f.URL)
+		if errors.Is(err, downloader.ErrNoRanges) {
+			return fmt.Errorf("--extract-member needs range requests: %w", err)
+		}
+		if err != nil {
+			fmt.Printf("  ✗ %s: %v\n", f.Name, err)
+			failed++
+			continue
+		}
+		members, err := archive.List(r, size)
+		if err != nil {
+			if errors.Is(err, downloader.ErrNoRanges) {
+				return fmt.Errorf("--extract-member needs range requests: %w", err)
+			}
+			fmt.Printf("  ✗ %s: %v\n", f.Name, err)
+			failed++
+			continue
+		}
+
+		var wanted []archive.Member
+		var wantedSize int64
+		for _, member := range members {
+			if m.Match(parser.FileInfo{Name: member.Name}) {
+				wanted = append(wanted, member)
+				wantedSize += member.Compressed
+			}
+		}
+		if len(wanted) == 0 {
+			fmt.Printf("  ⚠ %s: no members match\n", f.Name)
+			continue
+		}
+		fmt.Printf("%s: %d of %d members (%s of %s)\n", f.Name, len(wanted), len(members), formatBytes(wantedSize), formatBytes(size))
+		archived += size
+
+		base := filepath.Join(dir, filepath.Dir(filepath.FromSlash(f.Name)))
+		for _, member := range wanted {
+			dest := filepath.Join(base, archive.LocalPath(member.Name))
+			if info, err := os.Stat(dest); err == nil && info.Size() == member.Size {
+				if verbose {
+					fmt.Printf("  ✓ %s already extracted\n", member.Name)
+				}
+				continue
+			}
+			if err := extractMemberWithRetry(ctx, dl, f, member, dest); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				fmt.Printf("  ✗ %s: %v\n", member.Name, err)
+				failed++
+				continue
+			}
+			fmt.Printf("  ✓ %s (%s)\n", member.Name, formatBytes(member.Size))
+			extracted++
+			fetched += member.Compressed
+		}
+	}
+
+	fmt.Printf("\n✓ Extracted %d members, downloading %s", extracted, formatBytes(fetched))
+	if archived > 0 {
+		fmt.Printf(" of %s in archives", formatBytes(archived))
+	}
+	fmt.Println()
+	if failed > 0 {
+		return fmt.Errorf("%d archives or members failed", failed)
+	}
+	return nil
+}
+
+// extractMemberWithRetry extracts a member, retrying per --retry
+func extractMemberWithRetry(ctx context.Context, dl *downloader.Downloader, f parser.FileInfo, member archive.Member, dest string) error {
+	var err error
+	for attempt := 1; attempt <= max(retryAttempts, 1); attempt++ {
+		if err = extractMember(ctx, dl, f, member, dest); err == nil || ctx.Err() != nil || errors.Is(err, downloader.ErrNoRanges) {
+			return err
+		}
+		if attempt < retryAttempts {
+			if verbose {
+				fmt.Printf("  ⚠ %s: %v (attempt %d/%d)\n", member.Name, err, attempt, retryAttempts)
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(attempt) * time.Second):
+			}
+		}
+	}
+	return err
+}
+
+// extractMember fetches one member's compressed bytes and writes it to dest
+func extractMember(ctx context.Context, dl *downloader.Downloader, f parser.FileInfo, member archive.Member, dest string) error {
+	offset, err := member.DataOffset()
+	if err != nil {
+		return err
+	}
+	body, err := dl.OpenRange(ctx, f.URL, offset, member.Compressed)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = body.Close() }()
+
+	bar := progressReporter().New(member.Compressed, "  "+filepath.Base(dest))
+	contents, err := member.Decompress(io.TeeReader(body, bar))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = contents.Close() }()
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil { //nolint:gosec // Download directories are not sensitive
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	tempPath := dest + cleanup.TempSuffix
+	out, err := os.Create(tempPath) //nolint:gosec // Path is sanitized by archive.LocalPath
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	if _, err := io.Copy(out, contents); err != nil {
+		_ = out.Close()
+		_ = os.Remove(tempPath)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(tempPath, dest); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to move file into place: %w", err)
+	}
+	if !member.Modified.IsZero() {
+		_ = os.Chtimes(dest, member.Modified, member.Modified)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/nchapman/myrient-dl/internal/downloader"
+	"github.com/nchapman/myrient-dl/internal/tagcache"
+)
+
+var ignoreMissing bool
+
+// missingFiles collects the listings whose files turned out to be gone
+// upstream with --ignore-missing
+type missingFiles struct {
+	mu       sync.Mutex
+	listings map[string]bool
+}
+
+// recorder notes the listing of each file reported gone
+func (m *missingFiles) recorder() func(downloader.Event) {
+	return func(e downloader.Event) {
+		if e.Type != downloader.EventGone {
+			return
+		}
+		// File URLs are their listing's URL plus the escaped name
+		i := strings.LastIndex(e.File.URL, "/")
+		if i < 0 {
+			return
+		}
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.listings == nil {
+			m.listings = make(map[string]bool)
+		}
+		m.listings[e.File.URL[:i+1]] = true
+	}
+}
+
+// forgetListings drops what is cached about listings that still named removed
+// files, so the next run parses them afresh
+func (m *missingFiles) forgetListings() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.listings) == 0 {
+		return
+	}
+	dir, err := tagcache.DefaultDir()
+	if err != nil {
+		return
+	}
+	for listing := range m.listings {
+		if err := tagcache.Invalidate(dir, listing); err != nil && verbose {
+			fmt.Printf("  ⚠ %v\n", err)
+		}
+	}
+}
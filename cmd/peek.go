@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+
+	"github.com/nchapman/myrient-dl/internal/archive"
+	"github.com/nchapman/myrient-dl/internal/downloader"
+	"github.com/spf13/cobra"
+)
+
+var peekCmd = &cobra.Command{
+	Use:   "peek URL",
+	Short: "List what a remote zip holds without downloading it",
+	Long: `List the files in a remote zip archive with their sizes and CRC32s.
+
+Only the archive's central directory is fetched, with Range requests for the
+end of the file (usually a single request of at most 64 KiB), so a multi-gigabyte
+set can be checked before committing to the download. The server must support
+Range requests.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPeek,
+}
+
+func init() {
+	rootCmd.AddCommand(peekCmd)
+}
+
+func runPeek(_ *cobra.Command, args []string) error {
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	target := args[0]
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid URL %q: expected an http(s) URL of a zip file", target)
+	}
+	name := path.Base(u.Path)
+	if !archive.IsZip(name) {
+		return fmt.Errorf("%s is not a zip archive", name)
+	}
+
+	dl := downloader.New(downloader.Config{Auth: credentials, Identity: identity, RequestInterval: requestInterval})
+	r, size, err := dl.RemoteFile(ctx, target)
+	if errors.Is(err, downloader.ErrNoRanges) {
+		return fmt.Errorf("%w; download the file to see its contents", err)
+	}
+	if err != nil {
+		return err
+	}
+	members, err := archive.List(r, size)
+	if errors.Is(err, downloader.ErrNoRanges) {
+		return fmt.Errorf("%w; download the file to see its contents", downloader.ErrNoRanges)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s (%s)\n\n", name, formatBytes(size))
+	if len(members) == 0 {
+		fmt.Println("The archive is empty")
+		return nil
+	}
+
+	var extracted int64
+	fmt.Printf("  %10s  %10s  %-8s  %-16s  %s\n", "Size", "Packed", "CRC32", "Modified", "Name")
+	for _, m := range members {
+		modified := ""
+		if !m.Modified.IsZero() {
+			modified = m.Modified.Format("2006-01-02 15:04")
+		}
+		fmt.Printf("  %10s  %10s  %08x  %-16s  %s\n", formatBytes(m.Size), formatBytes(m.Compressed), m.CRC32, modified, m.Name)
+		extracted += m.Size
+	}
+	fmt.Printf("\n%s files, %s extracted\n", formatCount(len(members)), formatBytes(extracted))
+	return nil
+}
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/nchapman/myrient-dl/internal/parser"
+	"github.com/nchapman/myrient-dl/internal/plan"
+	"github.com/spf13/cobra"
+)
+
+var (
+	planFile   string
+	planDir    string
+	applyForce bool
+)
+
+var planCmd = &cobra.Command{
+	Use:   "plan [URL]",
+	Short: "Resolve a selection and save it as a plan file",
+	Long: `Resolve the listing and filters into a frozen plan of URLs, sizes, destinations,
+and the SHA-1 digests the listing publishes in SHA1SUMS files or .sha1 sidecars.
+
+The plan can be reviewed and later executed exactly with "myrient-dl apply".`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPlan,
+}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply [PLAN]",
+	Short: "Download exactly the files recorded in a plan file",
+	Long: `Execute a plan created by "myrient-dl plan".
+
+The remote listings are checked first; if any planned file was removed or changed
+size since the plan was made, apply refuses to run unless --force is given.
+Downloads are verified against the SHA-1 digests pinned in the plan.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runApply,
+}
+
+func init() {
+	planCmd.Flags().StringVarP(&planFile, "output", "o", "plan.json", "Plan file to write")
+	planCmd.Flags().StringVar(&planDir, "dir", "", "Download directory recorded in the plan (defaults to a configured output root or the last path component of URL)")
+	addSelectionFlags(planCmd)
+	addOutputNameFlags(planCmd)
+
+	applyCmd.Flags().BoolVar(&applyForce, "force", false, "Apply the plan even if the remote listing has drifted")
+	applyCmd.Flags().StringVar(&planDir, "dir", "", "Override the download directory recorded in the plan")
+	addDownloadFlags(applyCmd)
+	addListingFlags(applyCmd)
+	addBlocklistFlag(applyCmd)
+
+	rootCmd.AddCommand(planCmd, applyCmd)
+}
+
+func runPlan(_ *cobra.Command, args []string) error {
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	targetURL := args[0]
+	parsedURL, err := url.Parse(targetURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+
+	if planDir == "" {
+		planDir, err = resolveOutputDir(targetURL, parsedURL)
+		if err != nil {
+			return err
+		}
+	}
+
+	filtered, err := selectFiles(ctx, targetURL)
+	if err != nil {
+		return err
+	}
+	if filtered, err = applyLimits(preferSmallestVariants(filtered)); err != nil {
+		return err
+	}
+
+	if len(filtered) == 0 {
+		fmt.Println("No files match the specified patterns")
+		return nil
+	}
+
+	p := plan.New(targetURL, planDir, filtered)
+	p.Variants = formatVariants
+	pinChecksums(ctx, p)
+	if err := p.Save(planFile); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nPlanned %d files (total size: %s) into %s\n", len(p.Files), formatBytes(p.TotalSize()), p.OutputDir)
+	printLocalState(p.OutputDir, filtered)
+	printTagSummary(filtered)
+	fmt.Printf("Plan written to %s\n", planFile)
+	return nil
+}
+
+func runApply(c *cobra.Command, args []string) error {
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	if err := applyLimitRate(); err != nil {
+		return err
+	}
+	if err := applyWindow(); err != nil {
+		return err
+	}
+	if err := applyGentle(c); err != nil {
+		return err
+	}
+	if err := applyTimeouts(); err != nil {
+		return err
+	}
+
+	p, err := plan.Load(args[0])
+	if err != nil {
+		return err
+	}
+
+	dir := p.OutputDir
+	if planDir != "" {
+		dir = planDir
+	}
+
+	fmt.Printf("Plan: %d files (total size: %s) from %s\n", len(p.Files), formatBytes(p.TotalSize()), p.Source)
+	pinnedChecksums = p.Checksums()
+
+	if err := checkPlanDrift(ctx, p); err != nil {
+		if !applyForce {
+			return err
+		}
+		fmt.Printf("  ⚠ %v (continuing because of --force)\n", err)
+	}
+
+	return downloadFiles(ctx, p.Source, dir, p.FileInfos())
+}
+
+// printLocalState says how much of a selection dir already has, going by
+// names and listed sizes; nothing is printed for a fresh directory
+func printLocalState(dir string, files []parser.FileInfo) {
+	complete, partial := 0, 0
+	var pending int64
+	for i, state := range plan.CheckLocal(dir, files) {
+		switch state {
+		case plan.LocalComplete:
+			complete++
+			continue
+		case plan.LocalPartial:
+			partial++
+		}
+		pending += files[i].Size
+	}
+	if complete == 0 && partial == 0 {
+		return
+	}
+	fmt.Printf("Already in %s: %d complete, %d partial; %s left to download\n", dir, complete, partial, formatBytes(pending))
+}
+
+// checkPlanDrift re-fetches the plan's listings and reports files that changed since planning
+func checkPlanDrift(ctx context.Context, p *plan.Plan) error {
+	fmt.Println("Checking remote listing for changes...")
+
+	var current []parser.FileInfo
+	for _, listing := range p.Listings() {
+		trustListing(listing)
+		files, err := parser.ParseDirectoryListing(ctx, listing, listingOptions())
+		if err != nil && !errors.Is(err, parser.ErrEmpty) {
+			return fmt.Errorf("failed to parse directory listing: %w", err)
+		}
+		current = append(current, files...)
+	}
+
+	changes := p.Drift(current)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	for _, c := range changes {
+		fmt.Printf("  - %s: %s\n", c.Name, c.Reason)
+	}
+	return fmt.Errorf("remote has drifted since the plan was made (%d files changed)", len(changes))
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/parser"
+	"github.com/nchapman/myrient-dl/internal/politeness"
+)
+
+// pacing is how many connections a download opens and how it spaces requests
+type pacing struct {
+	parallel, segments      int
+	interval                time.Duration
+	backoffBase, backoffMax time.Duration
+}
+
+// politePacing fits the pacing flags to the politeness profiles of the hosts
+// the files come from, built in or set under hosts: in the config. Limits are
+// only ever tightened, and lowering --parallel or --segments is announced.
+func politePacing(files []parser.FileInfo) pacing {
+	p := pacing{parallel: parallel, segments: segments, interval: requestInterval, backoffBase: backoffBase, backoffMax: backoffMax}
+
+	var overrides map[string]politeness.Profile
+	if cfg, err := loadUserConfig(); err == nil {
+		overrides = cfg.Hosts
+	}
+	seen := make(map[string]bool)
+	var profiles []politeness.Profile
+	var strictest string // Name of the profile allowing the fewest connections
+	var fewest int
+	for _, f := range files {
+		u, err := url.Parse(f.URL)
+		if err != nil || seen[u.Hostname()] {
+			continue
+		}
+		seen[u.Hostname()] = true
+		if profile, name, ok := politeness.For(u.Hostname(), overrides); ok && !seen["profile:"+name] {
+			seen["profile:"+name] = true
+			profiles = append(profiles, profile)
+			if profile.MaxParallel > 0 && (strictest == "" || profile.MaxParallel < fewest) {
+				strictest, fewest = name, profile.MaxParallel
+			}
+			if verbose {
+				fmt.Printf("Politeness profile for %s: %s\n", name, profile)
+			}
+		}
+	}
+	if len(profiles) == 0 {
+		return p
+	}
+	limit := politeness.Strictest(profiles...)
+
+	if limit.MaxParallel > 0 && p.parallel > limit.MaxParallel {
+		fmt.Printf("  ⚠ %s allows at most %d connections; using --parallel %d instead of %d\n", strictest, limit.MaxParallel, limit.MaxParallel, p.parallel)
+		p.parallel = limit.MaxParallel
+	}
+	if limit.MaxParallel > 0 && p.parallel*p.segments > limit.MaxParallel {
+		fitted := max(limit.MaxParallel/p.parallel, 1)
+		fmt.Printf("  ⚠ %s allows at most %d connections; using --segments %d instead of %d\n", strictest, limit.MaxParallel, fitted, p.segments)
+		p.segments = fitted
+	}
+	p.interval = max(p.interval, limit.RequestInterval)
+	p.backoffBase = max(p.backoffBase, limit.BackoffBase)
+	p.backoffMax = max(p.backoffMax, limit.BackoffMax)
+	return p
+}
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nchapman/myrient-dl/internal/downloader"
+	"github.com/nchapman/myrient-dl/internal/parser"
+	"github.com/nchapman/myrient-dl/internal/picker"
+	"github.com/nchapman/myrient-dl/internal/plan"
+)
+
+// stdin is shared by all prompts so buffered input isn't lost between questions
+var stdin = bufio.NewReader(os.Stdin)
+
+// confirm asks a yes/no question on stdin, defaulting to no
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, err := stdin.ReadString('\n')
+	if err != nil {
+		fmt.Println()
+		return false
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+// pickFiles lets the user tick files in a terminal checkbox list
+func pickFiles(files []parser.FileInfo) ([]parser.FileInfo, error) {
+	items := make([]picker.Item, len(files))
+	for i, f := range files {
+		items[i] = picker.Item{Label: f.Name, Detail: formatBytes(f.Size), Weight: f.Size}
+	}
+	m := picker.NewModel(fmt.Sprintf("Select files to download (%s matched)", formatCount(len(files))), items)
+	m.FormatWeight = formatBytes
+
+	picked, err := picker.Run(m, os.Stdin, os.Stdout)
+	if err != nil {
+		return nil, err
+	}
+	chosen := make([]parser.FileInfo, len(picked))
+	for i, p := range picked {
+		chosen[i] = files[p]
+	}
+	return chosen, nil
+}
+
+// chooseVariants asks which files of a duplicate title to keep, defaulting to the first
+func chooseVariants(d plan.Duplicate) []parser.FileInfo {
+	fmt.Printf("\n%d variants of %q:\n", len(d.Files), d.Title)
+	for i, f := range d.Files {
+		fmt.Printf("  %d) %s (%s)\n", i+1, f.Name, formatBytes(f.Size))
+	}
+
+	for {
+		fmt.Printf("Keep which? (numbers separated by spaces, a = all, n = none) [1] ")
+		answer, err := stdin.ReadString('\n')
+		if err != nil {
+			fmt.Println()
+			return d.Files[:1]
+		}
+
+		chosen, ok := parseChoice(strings.TrimSpace(answer), d.Files)
+		if ok {
+			return chosen
+		}
+		fmt.Printf("  Please enter numbers between 1 and %d, a, or n\n", len(d.Files))
+	}
+}
+
+// parseChoice interprets an answer to chooseVariants
+func parseChoice(answer string, files []parser.FileInfo) ([]parser.FileInfo, bool) {
+	switch strings.ToLower(answer) {
+	case "":
+		return files[:1], true
+	case "a", "all":
+		return files, true
+	case "n", "none":
+		return nil, true
+	}
+
+	var chosen []parser.FileInfo
+	for _, field := range strings.Fields(strings.ReplaceAll(answer, ",", " ")) {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 1 || n > len(files) {
+			return nil, false
+		}
+		chosen = append(chosen, files[n-1])
+	}
+	return chosen, true
+}
+
+// conflictPrompt asks how to resolve a conflict: overwrite, skip, or rename.
+// A capitalized answer is remembered and applied to the rest of the run.
+type conflictPrompt struct {
+	mu       sync.Mutex
+	fallback string // Answer used for an empty line or closed stdin
+	always   string // Remembered answer, if any
+}
+
+// ask prints the question and returns "o", "s", or "r"
+func (p *conflictPrompt) ask(question string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.always != "" {
+		return p.always
+	}
+
+	fmt.Printf("\n%s\n", question)
+	for {
+		fmt.Printf("Overwrite, skip, or rename? (o/s/r, or O/S/R for all remaining) [%s] ", p.fallback)
+		answer, err := stdin.ReadString('\n')
+		if err != nil {
+			fmt.Println()
+			return p.fallback
+		}
+
+		switch answer = strings.TrimSpace(answer); answer {
+		case "":
+			return p.fallback
+		case "o", "s", "r":
+			return answer
+		case "O", "S", "R":
+			p.always = strings.ToLower(answer)
+			return p.always
+		}
+		fmt.Println("  Please enter o, s, r, O, S, or R")
+	}
+}
+
+// mismatchPrompt asks what to do with local files whose size differs from the remote
+var mismatchPrompt = &conflictPrompt{fallback: "o"}
+
+// askMismatch is the downloader's AskMismatch for --on-mismatch ask
+func askMismatch(file parser.FileInfo, localSize, remoteSize int64) downloader.MismatchPolicy {
+	question := fmt.Sprintf("%s already exists with a different size (local: %s, remote: %s)",
+		file.Name, formatBytes(localSize), formatBytes(remoteSize))
+	switch mismatchPrompt.ask(question) {
+	case "s":
+		return downloader.MismatchSkip
+	case "r":
+		return downloader.MismatchRename
+	default:
+		return downloader.MismatchOverwrite
+	}
+}
+
+// collisionPrompt asks which of several files mapping to one local name to keep
+var collisionPrompt = &conflictPrompt{fallback: "r"}
+
+// askCollision is the collision chooser for --on-collision ask
+func askCollision(kept, incoming parser.FileInfo) plan.CollisionPolicy {
+	question := fmt.Sprintf("%s would be saved over %s (overwrite keeps %s instead)", incoming.Name, kept.Name, incoming.Name)
+	switch collisionPrompt.ask(question) {
+	case "o":
+		return plan.CollisionOverwrite
+	case "s":
+		return plan.CollisionSkip
+	default:
+		return plan.CollisionRename
+	}
+}
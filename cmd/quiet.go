@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nchapman/myrient-dl/internal/progress"
+)
+
+// quiet is --quiet: only errors are printed, for cron jobs
+var quiet bool
+
+func init() {
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Print only errors: no progress bars, per-file messages, or summaries (for cron jobs)")
+}
+
+// applyQuiet sends normal output to the null device for --quiet, leaving
+// errors, which go to stderr, as the only output
+func applyQuiet() error {
+	if !quiet {
+		return nil
+	}
+	if verbose {
+		return fmt.Errorf("--quiet and --verbose can't be used together")
+	}
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", os.DevNull, err)
+	}
+	os.Stdout = devNull
+	return nil
+}
+
+// progressReporter draws download progress on the terminal, and nothing with
+// --quiet or when stderr is redirected, as in a cron job's log
+func progressReporter() progress.Reporter {
+	if quiet {
+		return progress.Silent{}
+	}
+	return progress.Default()
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/nchapman/myrient-dl/internal/cleanup"
+	"github.com/nchapman/myrient-dl/internal/downloader"
+	"github.com/nchapman/myrient-dl/internal/matcher"
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+var forceRedownload []string
+
+// setAside tracks complete files moved to the quarantine by --force-redownload
+// until their replacements are downloaded and verified
+type setAside struct {
+	dir   string
+	mu    sync.Mutex
+	files map[string]bool // Relative paths still in the quarantine
+}
+
+// setAsideForced quarantines the existing local copies of files matching
+// --force-redownload so they are downloaded again
+func setAsideForced(dir string, files []parser.FileInfo) (*setAside, error) {
+	if err := matcher.Validate("--force-redownload", forceRedownload); err != nil {
+		return nil, err
+	}
+
+	s := &setAside{dir: dir, files: make(map[string]bool)}
+	if len(forceRedownload) == 0 {
+		return s, nil
+	}
+
+	var bytes int64
+	for _, f := range matcher.New(forceRedownload, nil).Filter(files) {
+		info, err := os.Stat(filepath.Join(dir, f.Name))
+		if err != nil || info.IsDir() {
+			continue // Nothing local to replace
+		}
+		if _, err := cleanup.Quarantine(dir, f.Name); err != nil {
+			s.restoreAll()
+			return nil, err
+		}
+		s.files[f.Name] = true
+		bytes += info.Size()
+		if verbose {
+			fmt.Printf("  ↻ %s (set aside for re-download)\n", f.Name)
+		}
+	}
+
+	if len(s.files) > 0 {
+		fmt.Printf("Re-downloading %d existing files (%s); old copies are kept in %s until the new ones verify\n",
+			len(s.files), formatBytes(bytes), cleanup.QuarantineDir)
+	} else {
+		fmt.Println("  ⚠ --force-redownload matched no existing files")
+	}
+	return s, nil
+}
+
+// recorder returns a download event handler that discards an old copy once its
+// replacement completes and puts it back if the replacement fails
+func (s *setAside) recorder() func(downloader.Event) {
+	return func(e downloader.Event) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if !s.files[e.File.Name] {
+			return
+		}
+		switch e.Type {
+		case downloader.EventCompleted:
+			delete(s.files, e.File.Name)
+			old := cleanup.Artifact{Path: filepath.Join(s.dir, filepath.FromSlash(cleanup.QuarantineDir), e.File.Name), Kind: cleanup.KindQuarantine}
+			if _, err := cleanup.Remove(s.dir, []cleanup.Artifact{old}); err != nil {
+				fmt.Printf("  ⚠ Failed to remove old copy of %s: %v\n", e.File.Name, err)
+			}
+		case downloader.EventFailed, downloader.EventRejected:
+			delete(s.files, e.File.Name)
+			s.restore(e.File.Name)
+		}
+	}
+}
+
+// restoreAll puts back old copies whose replacements never finished
+func (s *setAside) restoreAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name := range s.files {
+		s.restore(name)
+	}
+	s.files = make(map[string]bool)
+}
+
+func (s *setAside) restore(name string) {
+	if err := cleanup.Restore(s.dir, name); err != nil {
+		fmt.Printf("  ⚠ Old copy left in quarantine: %v\n", err)
+		return
+	}
+	fmt.Printf("  ↺ Restored previous copy of %s\n", name)
+}
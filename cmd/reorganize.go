@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nchapman/myrient-dl/internal/latest"
+	"github.com/nchapman/myrient-dl/internal/layout"
+	"github.com/nchapman/myrient-dl/internal/manifest"
+	"github.com/nchapman/myrient-dl/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reorganizeTemplate string
+	reorganizeDryRun   bool
+	reorganizeYes      bool
+)
+
+var reorganizeCmd = &cobra.Command{
+	Use:   "reorganize DIR --template TEMPLATE",
+	Short: "Move a downloaded library into a new directory layout",
+	Long: `Move the files of a download directory into a new layout without downloading
+them again. The template is a path built from placeholders:
+
+  {name}    the file's name (required in the last component)
+  {title}   its title without tags, e.g. "Sonic"
+  {letter}  the title's first letter, or # for anything else
+  {region}  its first parenthesized tag, e.g. "USA, Europe"
+  {dir}     the directory the listing puts it in (only known if the current
+            layout keeps it)
+
+or one of the presets flat ({dir}/{name}), letter ({letter}/{name}),
+region ({region}/{name}), and title ({title}/{name}).
+
+Sidecars move with their files, the manifest and latest/ links are updated, and
+the layout is recorded so later downloads into DIR are saved the same way. The
+moves are journaled first: if the command is interrupted, run it again to finish.`,
+	Example: `  myrient-dl reorganize ./roms --template letter --dry-run
+  myrient-dl reorganize ./roms --template "{region}/{letter}/{name}"`,
+	Args: cobra.ExactArgs(1),
+	RunE: runReorganize,
+}
+
+func init() {
+	reorganizeCmd.Flags().StringVarP(&reorganizeTemplate, "template", "t", "", "Layout to move the files into: a path template or a preset (flat, letter, region, title)")
+	reorganizeCmd.Flags().BoolVar(&reorganizeDryRun, "dry-run", false, "Only list the moves")
+	reorganizeCmd.Flags().BoolVarP(&reorganizeYes, "yes", "y", false, "Move without asking for confirmation")
+
+	rootCmd.AddCommand(reorganizeCmd)
+}
+
+func runReorganize(_ *cobra.Command, args []string) error {
+	dir := args[0]
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("not a directory: %s", dir)
+	}
+	if pid, running := state.Running(dir); running {
+		return fmt.Errorf("a download is running in %s (pid %d); reorganize it once that finishes", dir, pid)
+	}
+
+	pending, err := layout.Pending(dir)
+	switch {
+	case err == nil && !reorganizeDryRun:
+		fmt.Printf("Finishing an interrupted reorganization into %s...\n", pending.Layout)
+		if err := pending.Finish(dir); err != nil {
+			return err
+		}
+		if err := refreshLatest(dir); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Moved %d files\n", len(pending.Moves))
+		if reorganizeTemplate == "" {
+			return nil
+		}
+		fmt.Println()
+	case err == nil:
+		return fmt.Errorf("an interrupted reorganization into %s is pending; run without --dry-run to finish it", pending.Layout)
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	if reorganizeTemplate == "" {
+		return errors.New("--template is required")
+	}
+	tmpl, err := layout.Parse(reorganizeTemplate)
+	if err != nil {
+		return err
+	}
+
+	paths, _, err := downloadedFiles(dir)
+	if err != nil {
+		return fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+	rels := make([]string, 0, len(paths))
+	for _, p := range paths {
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rels = append(rels, filepath.ToSlash(rel))
+	}
+
+	current, err := recordedLayout(dir)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		if current, err = layout.Parse(layout.Flat); err != nil {
+			return err
+		}
+	}
+	moves, err := layout.Plan(current, tmpl, rels)
+	if err != nil {
+		return err
+	}
+	if len(moves) == 0 {
+		fmt.Printf("All %d files are already laid out as %s\n", len(rels), tmpl)
+		if reorganizeDryRun || current.String() == tmpl.String() {
+			return nil
+		}
+		return layout.Apply(dir, tmpl.String(), nil)
+	}
+
+	if verbose || reorganizeDryRun {
+		for _, m := range moves {
+			fmt.Printf("  %s → %s\n", m.From, m.To)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("Moving %d of %d files into %s\n", len(moves), len(rels), tmpl)
+	if reorganizeDryRun {
+		return nil
+	}
+	if !reorganizeYes && !confirm("Move them?") {
+		fmt.Println("Aborted")
+		return nil
+	}
+
+	if err := layout.Apply(dir, tmpl.String(), moves); err != nil {
+		return err
+	}
+	if err := refreshLatest(dir); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Moved %d files; later downloads into %s are saved the same way\n", len(moves), dir)
+	return nil
+}
+
+// refreshLatest relinks latest/ after files moved, if the directory has one
+func refreshLatest(dir string) error {
+	if _, err := os.Stat(filepath.Join(dir, latest.Dir)); err != nil {
+		return nil
+	}
+	_, err := openLatest(dir)
+	return err
+}
+
+// recordedLayout returns the layout reorganize recorded for a download
+// directory, or nil if files are saved where the listing puts them
+func recordedLayout(dir string) (*layout.Template, error) {
+	if _, err := layout.Pending(dir); err == nil {
+		return nil, fmt.Errorf("a reorganization of %s was interrupted; run \"myrient-dl reorganize %s\" to finish it first", dir, dir)
+	}
+	m, err := manifest.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	if m.Layout == "" {
+		return nil, nil
+	}
+	tmpl, err := layout.Parse(m.Layout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid layout in %s: %w", manifest.Path(dir), err)
+	}
+	return tmpl, nil
+}
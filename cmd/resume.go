@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/nchapman/myrient-dl/internal/catalog"
+	"github.com/nchapman/myrient-dl/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume [DIR]",
+	Short: "Pick up the last download run in a directory where it stopped",
+	Long: `Continue the run recorded in a directory's download queue after it was
+interrupted, killed, or crashed, without fetching the listing or re-applying
+the selection flags.
+
+Files that failed or were left in progress are retried first, then the ones not
+started yet; partial downloads continue from their journals. Files the queue
+or manifest records as done are skipped without asking the server. Download
+flags such as --parallel are not saved with the queue, so give them again.`,
+	Example: `  myrient-dl resume ./Nintendo\ -\ Game\ Boy
+  myrient-dl resume /nas/roms/gb -p 4`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runResume,
+}
+
+func init() {
+	addDownloadFlags(resumeCmd)
+	addBlocklistFlag(resumeCmd)
+
+	rootCmd.AddCommand(resumeCmd)
+}
+
+func runResume(c *cobra.Command, args []string) error {
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	if err := applyLimitRate(); err != nil {
+		return err
+	}
+	if err := applyWindow(); err != nil {
+		return err
+	}
+	if err := applyGentle(c); err != nil {
+		return err
+	}
+	if err := applyTimeouts(); err != nil {
+		return err
+	}
+
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	queue, err := state.Load(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("no download queue found in %s to resume", dir)
+	}
+	if err != nil {
+		return err
+	}
+	if pid, ok := state.Running(dir); ok {
+		return fmt.Errorf("the download (pid %d) in %s is still running", pid, dir)
+	}
+
+	tallies := queue.Tallies()
+	var left state.Tally
+	for _, s := range []state.Status{state.StatusPending, state.StatusInProgress, state.StatusFailed} {
+		left.Count += tallies[s].Count
+		left.Bytes += tallies[s].Bytes
+	}
+	files := queue.Files()
+	if left.Count == 0 {
+		fmt.Printf("✓ Nothing to resume: all %s files in %s are done\n", formatCount(len(files)), dir)
+		return nil
+	}
+
+	if sys, ok := catalog.Detect(queue.Source); ok {
+		for i := range files {
+			files[i].Collection, files[i].System = sys.Collection, sys.Name
+		}
+	}
+	fmt.Printf("Resuming %s: %s of %s files left (%s)\n", queue.Source, formatCount(left.Count), formatCount(len(files)), formatBytes(left.Bytes))
+	return downloadFiles(ctx, queue.Source, dir, files)
+}
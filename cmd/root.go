@@ -3,40 +3,66 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/url"
 	"os"
 	"os/signal"
-	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
+	"github.com/nchapman/myrient-dl/internal/cleanup"
+	"github.com/nchapman/myrient-dl/internal/dat"
 	"github.com/nchapman/myrient-dl/internal/downloader"
-	"github.com/nchapman/myrient-dl/internal/matcher"
+	"github.com/nchapman/myrient-dl/internal/fsutil"
+	"github.com/nchapman/myrient-dl/internal/history"
 	"github.com/nchapman/myrient-dl/internal/parser"
+	"github.com/nchapman/myrient-dl/internal/picker"
+	"github.com/nchapman/myrient-dl/internal/plan"
+	"github.com/nchapman/myrient-dl/internal/state"
+	"github.com/nchapman/myrient-dl/internal/units"
 	"github.com/nchapman/myrient-dl/internal/version"
 	"github.com/spf13/cobra"
 )
 
 var (
-	outputDir       string
-	includePatterns []string
-	excludePatterns []string
-	parallel        int
-	dryRun          bool
-	verbose         bool
-	retryAttempts   int
+	outputDir     string
+	parallel      int
+	segments      int
+	smallSlots    int
+	largeSize     string
+	dryRun        bool
+	dryRunOut     string
+	verbose       bool
+	retryAttempts int
+	startupRamp   time.Duration
+	verifyRetries int
+	continueFiles bool
+	honorServed   bool
+	placeholders  string
+	onMismatch    string
+	dirDepth      int
+	slugify       bool
+	postVerify    string
+	interactive   bool
 )
 
 var rootCmd = &cobra.Command{
-	Use:     "myrient-dl [URL]",
+	Use:     "myrient-dl [URL...]",
 	Short:   "Download files from Myrient directory listings",
 	Version: version.Version,
 	Long: `A fast and friendly CLI tool to download files from Myrient.
 
 Downloads files from Myrient directory listings with support for include/exclude patterns,
-parallel downloads, and beautiful progress tracking.`,
-	Args: cobra.ExactArgs(1),
+parallel downloads, and beautiful progress tracking. Several listings can be
+given at once, as arguments or with --url-file; each is downloaded into its own
+directory.`,
+	Args: cobra.ArbitraryArgs,
 	RunE: run,
 }
 
@@ -49,169 +75,814 @@ func Execute() {
 }
 
 func init() {
-	rootCmd.Flags().StringVarP(&outputDir, "output", "o", "", "Output directory (defaults to last path component of URL)")
-	rootCmd.Flags().StringArrayVarP(&includePatterns, "include", "i", []string{"*"}, "Include pattern (glob syntax, repeatable)")
-	rootCmd.Flags().StringArrayVarP(&excludePatterns, "exclude", "e", []string{}, "Exclude pattern (glob syntax, repeatable)")
-	rootCmd.Flags().IntVarP(&parallel, "parallel", "p", 1, "Number of parallel downloads")
+	rootCmd.Flags().StringVarP(&outputDir, "output", "o", "", "Output directory (defaults to a configured output root or the last path component of URL); with several URLs, the directory their own directories are made in")
+	rootCmd.Flags().StringVar(&urlFile, "url-file", "", "Also download the listings in this file, one URL per line (# starts a comment)")
 	rootCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be downloaded without downloading")
-	rootCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Verbose output")
-	rootCmd.Flags().IntVarP(&retryAttempts, "retry", "r", 3, "Number of retry attempts for failed downloads")
+	rootCmd.Flags().StringVar(&dryRunOut, "out", "", "With --dry-run, also save the selection as a plan file for \"myrient-dl apply\"")
+	rootCmd.Flags().BoolVar(&extractedSizes, "extracted-sizes", false, "Read each zip's directory (a Range request for its last 64 KiB, or the local copy) to report sizes once extracted")
+	rootCmd.Flags().BoolVar(&interactive, "interactive", false, "After filtering, pick the files to download from a checkbox list with fuzzy search")
+	rootCmd.Flags().StringArrayVar(&extractMembers, "extract-member", []string{}, "Instead of whole zips, fetch only the members matching this glob (e.g. \"*.cue\") with Range requests (repeatable)")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Verbose output")
+	addOutputNameFlags(rootCmd)
+	addSelectionFlags(rootCmd)
+	addDownloadFlags(rootCmd)
 
 	// Custom version template with more details
 	rootCmd.SetVersionTemplate("{{.Version}}\n" + version.Info() + "\n")
 }
 
-func run(_ *cobra.Command, args []string) error {
-	// Set up context with cancellation
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+// addOutputNameFlags registers the flags that shape the default output directory name
+func addOutputNameFlags(c *cobra.Command) {
+	c.Flags().IntVar(&dirDepth, "dir-depth", 1, "Number of trailing URL path components used for the default output directory")
+	c.Flags().BoolVar(&slugify, "slugify", false, "Use lowercase, dash-separated default output directory names (e.g. nintendo-nes)")
+}
 
-	// Handle signals for graceful shutdown
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		sig := <-sigCh
-		fmt.Printf("\n\nReceived signal %v, shutting down gracefully...\n", sig)
-		cancel()
-	}()
+// addDownloadFlags registers the flags that control how files are transferred
+func addDownloadFlags(c *cobra.Command) {
+	c.Flags().IntVarP(&parallel, "parallel", "p", 1, "Number of parallel downloads")
+	c.Flags().StringVar(&limitRate, "limit-rate", "", "Cap the combined download speed, e.g. 2M or 500KB/s (0 = unlimited)")
+	c.Flags().StringVar(&downloadWindow, "window", "", "Only transfer during this daily window, e.g. 22:00-06:00; downloads outside it are suspended and continue when it opens")
+	c.Flags().IntVar(&segments, "segments", 1, "Split files of 32 MiB or more into up to N byte ranges downloaded over separate connections (each parallel download may open N)")
+	c.Flags().IntVar(&smallSlots, "small-slots", 1, "With --parallel, keep this many downloads for files under --large-size while larger ones download (0 = no reservation)")
+	c.Flags().StringVar(&largeSize, "large-size", "1GiB", "Size from which files count as large for --small-slots")
+	c.Flags().IntVarP(&retryAttempts, "retry", "r", 3, "Number of attempts for failed downloads and listing fetches")
+	c.Flags().DurationVar(&connectTimeout, "connect-timeout", 30*time.Second, "Give up connecting to a server (TLS included) after this long")
+	c.Flags().DurationVar(&stallTimeout, "stall-timeout", time.Minute, "Abort and retry a request that gets no response, or whose download slows below --stall-speed, for this long (0 = never)")
+	c.Flags().StringVar(&stallSpeedFlag, "stall-speed", "", "With --stall-timeout, the slowest download speed tolerated, e.g. 10K (default: any data at all)")
+	c.Flags().DurationVar(&startupRamp, "ramp", time.Second, "Delay between starting each parallel worker")
+	c.Flags().StringVar(&datVerify, "dat-verify", "strict", "With --dat or a DAT found in the listing, what to do with downloads whose contents don't match it: strict (re-download, then fail), warn, or off")
+	c.Flags().IntVar(&verifyRetries, "verify-retries", 2, "Number of re-downloads for files that fail verification")
+	c.Flags().BoolVar(&ignoreMissing, "ignore-missing", false, "Record files the server answers 404 or 410 for as gone upstream instead of retrying them and failing the run")
+	c.Flags().BoolVar(&honorServed, "honor-content-disposition", false, "Save files under the name the server sends in Content-Disposition instead of the listed name")
+	c.Flags().StringVar(&placeholders, "placeholders", "warn", "What to do with zero-byte files and HTML pages served in place of a file: skip, warn, or download")
+	c.Flags().StringVar(&onMismatch, "on-mismatch", "overwrite", "What to do with existing files whose size differs from the remote: overwrite, skip, rename (save the download as \"name (2)\"), or ask")
+	c.Flags().BoolVar(&refresh, "refresh", false, "Check every file with the server, even ones the manifest records as complete")
+	c.Flags().BoolVar(&continueFiles, "continue-existing", false, "Complete existing files smaller than the remote with a Range request instead of re-downloading")
+	c.Flags().StringVar(&postVerify, "post-verify", "", "After downloading, re-check all (or N random) downloaded files against the server and quarantine those that changed")
+	c.Flags().Lookup("post-verify").NoOptDefVal = "all"
+	c.Flags().StringVar(&logFile, "log-file", "", "Append a timestamped record of every request, retry, skip, and failure to this file")
+	c.Flags().StringVar(&logLevel, "log-level", "info", "What --log-file records: debug (every request), info (each file), warn (retries), or error (failures)")
+	c.Flags().StringVar(&logFormat, "log-format", "json", "--log-file format: json (one object per line) or logfmt")
+	c.Flags().BoolVar(&verifyArchives, "verify-archives", false, "Test each downloaded zip (every member's CRC32) and 7z (its headers) before keeping it; corrupt ones are downloaded again")
+	c.Flags().BoolVar(&extractArchives, "extract", false, "Unzip each archive once it's downloaded and verified, next to it in the output directory")
+	c.Flags().StringVar(&extractTo, "extract-to", "", "With --extract, unzip into this directory instead, keeping subdirectories")
+	c.Flags().BoolVar(&deleteArchives, "delete-archives", false, "With --extract, delete each archive once it's extracted; later runs skip it while its files are there")
+	c.Flags().StringVar(&spotCheck, "spot-check", "", "After the run, test this percentage of its zip archives, picked at random, by decompressing them and checking each member's CRC32, e.g. 5%")
+	c.Flags().StringArrayVar(&forceRedownload, "force-redownload", []string{}, "Re-download matching files even if they are complete locally, keeping the old copies until the new ones verify (glob syntax, repeatable)")
+	c.Flags().IntVar(&checkpointEvery, "checkpoint-every", 0, "Every N finished files, flush the queue and logs, write an interim summary, and start a new batch log in .myrient-dl/batches (0 = off)")
+	c.Flags().BoolVar(&noChecksums, "no-checksums", false, "Don't verify downloads against SHA1SUMS/MD5SUMS files and .sha1/.md5 sidecars found in the listing")
+	c.Flags().BoolVar(&sidecars, "sidecar", false, "Write a .meta.json next to each downloaded file with its source URL, size, SHA-256, download time, and collection")
+	c.Flags().BoolVar(&latestLinks, "latest", false, "Keep a latest/ directory of links to the newest revision of each release, updated as files arrive")
+	c.Flags().BoolVar(&gentle, "gentle", false, "Be as polite to the server as possible: 1 download at a time, paced requests, a bandwidth cap, long backoff, and off-peak (01:00-07:00) starts")
+}
+
+func run(c *cobra.Command, args []string) error {
+	ctx, cancel := signalContext()
+	defer cancel()
 
-	targetURL := args[0]
+	if err := applyLimitRate(); err != nil {
+		return err
+	}
+	if err := applyWindow(); err != nil {
+		return err
+	}
+	if err := applyGentle(c); err != nil {
+		return err
+	}
+	if err := applyTimeouts(); err != nil {
+		return err
+	}
 
-	// Validate URL
-	parsedURL, err := url.Parse(targetURL)
+	urls, err := targetURLs(args)
 	if err != nil {
-		return fmt.Errorf("invalid URL: %w", err)
+		return err
+	}
+
+	if dryRunOut != "" && !dryRun {
+		return fmt.Errorf("--out requires --dry-run")
+	}
+	if dryRunOut != "" && len(urls) > 1 {
+		return fmt.Errorf("--out saves a plan for a single URL")
+	}
+	if interactive {
+		if err := picker.CheckTerminal(os.Stdin, os.Stdout); err != nil {
+			return fmt.Errorf("--interactive: %w", err)
+		}
 	}
 
-	// Determine output directory if not specified
-	if outputDir == "" {
-		outputDir = getDefaultOutputDir(parsedURL)
+	sources, err := resolveSources(urls)
+	if err != nil {
+		return err
 	}
 
 	if verbose {
-		fmt.Printf("Target URL: %s\n", targetURL)
-		fmt.Printf("Output directory: %s\n", outputDir)
-		fmt.Printf("Include patterns: %v\n", includePatterns)
-		if len(excludePatterns) > 0 {
-			fmt.Printf("Exclude patterns: %v\n", excludePatterns)
+		for _, s := range sources {
+			fmt.Printf("Target URL: %s\n", s.url)
+			fmt.Printf("Output directory: %s\n", s.dir)
 		}
+		printSelectionSettings()
 		fmt.Printf("Parallel downloads: %d\n", parallel)
+		if segments > 1 {
+			fmt.Printf("Segments per large file: %d\n", segments)
+		}
+		if parallel > 1 && smallSlots > 0 {
+			fmt.Printf("Downloads kept for files under %s: %d\n", largeSize, min(smallSlots, parallel-1))
+		}
+		if rateLimit > 0 {
+			fmt.Printf("Bandwidth limit: %s/s\n", formatBytes(rateLimit))
+		}
+		fmt.Printf("Identifying as: %s\n", identity)
 		fmt.Println()
 	}
 
-	// Parse directory listing
-	fmt.Println("Fetching directory listing...")
-	files, err := parser.ParseDirectoryListing(ctx, targetURL)
+	filtered, err := selectSources(ctx, sources)
 	if err != nil {
-		return fmt.Errorf("failed to parse directory listing: %w", err)
+		return err
 	}
 
-	if len(files) == 0 {
-		return fmt.Errorf("no files found in directory listing")
+	if len(filtered) == 0 {
+		fmt.Println("No files match the specified patterns")
+		return nil
+	}
+	sources = assign(sources, filtered)
+
+	var extracted *extractedEstimate
+	if extractedSizes {
+		extracted = &extractedEstimate{sizes: map[string]int64{}}
+		for _, s := range sources {
+			est := estimateExtracted(ctx, s.dir, s.files)
+			if est == nil {
+				return ctx.Err()
+			}
+			extracted.add(est)
+		}
 	}
 
-	if verbose {
-		fmt.Printf("Found %d files\n", len(files))
+	if extracted != nil {
+		fmt.Printf("\nMatched %d files (total size: %s, about %s extracted)\n", len(filtered), formatBytes(totalSize(filtered)), formatBytes(extracted.total))
+	} else {
+		fmt.Printf("\nMatched %d files (total size: %s)\n", len(filtered), formatBytes(totalSize(filtered)))
+	}
+	if label := filtered[0].SystemLabel(); verbose && label != "" && len(sources) == 1 {
+		fmt.Printf("System: %s\n", label)
 	}
+	printTagSummary(filtered)
 
-	// Filter files based on patterns
-	m := matcher.New(includePatterns, excludePatterns)
-	filtered := m.Filter(files)
+	if interactive {
+		filtered, err = pickFiles(filtered)
+		if errors.Is(err, picker.ErrCancelled) {
+			fmt.Println("\nSelection cancelled, nothing downloaded")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(filtered) == 0 {
+			fmt.Println("\nNo files selected")
+			return nil
+		}
+		fmt.Printf("\nSelected %d files (total size: %s)\n", len(filtered), formatBytes(totalSize(filtered)))
+		sources = assign(sources, filtered)
+	}
 
-	if len(filtered) == 0 {
-		fmt.Println("No files match the specified patterns")
+	if dryRun {
+		fmt.Println("\nFiles to download (dry-run mode):")
+		for _, s := range sources {
+			if len(sources) > 1 {
+				fmt.Printf("  %s:\n", s.dir)
+			}
+			for _, f := range s.files {
+				fmt.Printf("  - %s (%s)\n", f.Name, extracted.describe(f))
+			}
+		}
+		for _, s := range sources {
+			printLocalState(s.dir, s.files)
+		}
+		if dryRunOut != "" {
+			p := plan.New(sources[0].url, sources[0].dir, sources[0].files)
+			p.Variants = formatVariants
+			listingChecksums = sources[0].checksums
+			pinChecksums(ctx, p)
+			if err := p.Save(dryRunOut); err != nil {
+				return err
+			}
+			fmt.Printf("\nPlan written to %s (run it with: myrient-dl apply %s)\n", dryRunOut, dryRunOut)
+		}
 		return nil
 	}
 
-	// Calculate total size
-	var totalSize int64
-	for _, f := range filtered {
-		totalSize += f.Size
+	if len(extractMembers) > 0 {
+		for _, s := range sources {
+			if err := extractMemberFiles(ctx, s.dir, s.files); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
+	return downloadSources(ctx, sources)
+}
 
-	fmt.Printf("\nMatched %d files (total size: %s)\n", len(filtered), formatBytes(totalSize))
+// signalContext returns a context that is cancelled on SIGINT/SIGTERM
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
 
-	if dryRun {
-		fmt.Println("\nFiles to download (dry-run mode):")
-		for _, f := range filtered {
-			fmt.Printf("  - %s (%s)\n", f.Name, formatBytes(f.Size))
+	// Handle signals for graceful shutdown
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-sigCh
+		if drain := drainHandler(); drain != nil {
+			fmt.Printf("\n\nReceived signal %v, finishing in-flight downloads (press Ctrl-C again to abort)...\n", sig)
+			drain()
+			sig = <-sigCh
 		}
+		fmt.Printf("\n\nReceived signal %v, shutting down gracefully...\n", sig)
+		cancel()
+	}()
+
+	return ctx, cancel
+}
+
+// onDrain, when set, handles the first interrupt by letting in-flight work finish;
+// a second interrupt always cancels
+var (
+	drainMu sync.Mutex
+	onDrain func()
+)
+
+// setDrainHandler makes the first interrupt call drain instead of cancelling and
+// returns a function that restores the previous behavior
+func setDrainHandler(drain func()) func() {
+	drainMu.Lock()
+	defer drainMu.Unlock()
+	onDrain = drain
+	return func() { setDrainHandler(nil) }
+}
+
+func drainHandler() func() {
+	drainMu.Lock()
+	defer drainMu.Unlock()
+	return onDrain
+}
+
+// printLimitedEstimate tells how long downloading bytes takes at best under the
+// bandwidth cap and download window, so overnight jobs are planned realistically
+func printLimitedEstimate(bytes int64) {
+	eta, ok := downloader.Estimate(bytes, 0, rateLimit, window, time.Now())
+	if !ok || eta < time.Minute {
+		return
+	}
+	limits := fmt.Sprintf("a %s/s cap", formatBytes(rateLimit))
+	if window != nil {
+		limits += fmt.Sprintf(" and the %s window", window)
+	}
+	fmt.Printf("With %s this takes at least %s (done around %s at the earliest)\n",
+		limits, downloader.FormatETA(eta), time.Now().Add(eta).Format("Mon 15:04"))
+}
+
+// downloadFiles creates the output directory and downloads the selection into it
+func downloadFiles(ctx context.Context, source, dir string, files []parser.FileInfo) error {
+	placeholderPolicy, err := downloader.ParsePlaceholderPolicy(placeholders)
+	if err != nil {
+		return err
+	}
+	mismatchPolicy, err := downloader.ParseMismatchPolicy(onMismatch)
+	if err != nil {
+		return err
+	}
+	verifySample, err := parsePostVerify(postVerify)
+	if err != nil {
+		return err
+	}
+	verifyMode, err := dat.ParseVerifyMode(datVerify)
+	if err != nil {
+		return err
+	}
+	spots, err := newSpotChecker()
+	if err != nil {
+		return err
+	}
+	if segments < 1 {
+		return fmt.Errorf("--segments must be at least 1")
+	}
+	if smallSlots < 0 {
+		return fmt.Errorf("--small-slots must not be negative")
+	}
+	largeFile, err := units.ParseSize(largeSize)
+	if err != nil {
+		return fmt.Errorf("invalid --large-size: %w", err)
+	}
+	if checkpointEvery < 0 {
+		return fmt.Errorf("--checkpoint-every must not be negative")
+	}
+	list, err := loadBlocklist()
+	if err != nil {
+		return err
+	}
+	userConfig, err := loadUserConfig()
+	if err != nil {
+		return err
+	}
+	tmpl, err := recordedLayout(dir)
+	if err != nil {
+		return err
+	}
+	runLog := slog.New(slog.DiscardHandler)
+	if file, err := openRunLog(); err != nil {
+		return err
+	} else if file != nil {
+		defer func() { _ = file.Close() }()
+		runLog = file.Logger
+	}
+
+	extract, err := newExtractor(dir)
+	if err != nil {
+		return err
+	}
+	runPlan := plan.Hash(source, selectionFilters(), files)
+	warnDuplicateRun(runPlan, dir)
+	if files = skipExtracted(dir, files); len(files) == 0 {
+		fmt.Println("✓ Nothing left to download")
 		return nil
 	}
 
 	// Create output directory
-	if err := os.MkdirAll(outputDir, 0755); err != nil { //nolint:gosec // 0755 is appropriate for download directories
+	if err := os.MkdirAll(dir, 0755); err != nil { //nolint:gosec // 0755 is appropriate for download directories
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
+	// Record the queue so "myrient-dl status" can report on this run
+	lock, err := state.Acquire(dir)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	// Picking up an earlier run of the same selection: retry its failures first
+	// and make quick progress on the rest instead of replaying the old order
+	previous, err := state.Load(dir)
+	if err != nil || previous.Source != source {
+		previous = nil
+	}
+	var retried, untouched int
+	if previous != nil && !prioritize {
+		files, retried, untouched = previous.ResumeOrder(files)
+	}
+
+	queue, err := state.Create(dir, source, files)
+	if err != nil {
+		return err
+	}
+	// What earlier attempts got onto disk counts as transferred, not as work left
+	carried := make(map[string]int64, len(files))
+	if previous != nil {
+		queue.Carry(previous)
+		for _, f := range files {
+			carried[f.Name] = queue.Transferred(f.Name)
+		}
+	}
+	if retried > 0 || (untouched > 0 && untouched < len(files)) {
+		fmt.Printf("Resuming earlier run: %d failed or interrupted, %d not yet started, %d already done",
+			retried, untouched, len(files)-retried-untouched)
+		if done := queue.TotalTransferred(); done > 0 {
+			fmt.Printf(" (%s of %s already transferred)", formatBytes(done), formatBytes(totalSize(files)))
+		}
+		fmt.Println()
+	}
+	defer saveProgress(queue)()
+	manifestLog, err := openManifestLog(dir, source)
+	if err != nil {
+		return err
+	}
+	defer manifestLog.save()
+	handlers := []func(downloader.Event){queueRecorder(queue), manifestLog.recorder(), logRecorder(runLog, dir)}
+	var missing *missingFiles
+	if ignoreMissing {
+		missing = &missingFiles{}
+		handlers = append(handlers, missing.recorder())
+		defer missing.forgetListings()
+	}
+
+	forced, err := setAsideForced(dir, files)
+	if err != nil {
+		return err
+	}
+	defer forced.restoreAll()
+	handlers = append(handlers, forced.recorder())
+
+	if sidecars {
+		handlers = append(handlers, sidecarRecorder(source, dir))
+	}
+	if spots != nil {
+		handlers = append(handlers, spots.recorder())
+	}
+	if latestLinks {
+		recorder, err := openLatest(dir)
+		if err != nil {
+			return err
+		}
+		handlers = append(handlers, recorder)
+	}
+
+	// History is a convenience; a broken log shouldn't stop downloads
+	var historyLog *history.Log
+	if log, err := openHistory(); err != nil {
+		fmt.Printf("  ⚠ History disabled: %v\n", err)
+	} else {
+		historyLog = log
+		defer func() { _ = log.Close() }()
+		handlers = append(handlers, historyRecorder(log, source, dir))
+	}
+
+	// Week-long runs bring their bookkeeping up to date every batch of files
+	var checkpoints *checkpointer
+	if checkpointEvery > 0 {
+		checkpoints, err = openCheckpoints(dir, source, len(files), queue)
+		if err != nil {
+			return err
+		}
+		defer checkpoints.close()
+		checkpoints.history = historyLog
+		handlers = append(handlers, checkpoints.recorder())
+	}
+	// Last, so the archive is recorded in the manifest before it's extracted
+	if extract != nil {
+		extract.log = manifestLog
+		handlers = append(handlers, extract.recorder())
+	}
+
+	sums := loadChecksums(ctx, files)
+	verifyDAT := selectionDAT
+	if verifyDAT == nil && verifyMode != dat.VerifyOff {
+		verifyDAT = loadListingDAT(ctx, files)
+	}
+	printLimitedEstimate(totalSize(files) - queue.TotalTransferred())
+
+	pace := politePacing(files)
+
 	// Download files
 	fmt.Println("\nStarting downloads...")
-	dl := downloader.New(downloader.Config{
-		OutputDir:     outputDir,
-		Parallel:      parallel,
-		RetryAttempts: retryAttempts,
-		Verbose:       verbose,
-	})
+	config := downloader.Config{
+		OutputDir:               dir,
+		Parallel:                pace.parallel,
+		Segments:                pace.segments,
+		SmallSlots:              smallSlots,
+		LargeFile:               largeFile,
+		RetryAttempts:           retryAttempts,
+		Verbose:                 verbose,
+		StartupRamp:             startupRamp,
+		RequestInterval:         pace.interval,
+		RateLimit:               rateLimit,
+		BackoffBase:             pace.backoffBase,
+		BackoffMax:              pace.backoffMax,
+		Window:                  window,
+		Suspend:                 suspend,
+		ConnectTimeout:          connectTimeout,
+		StallTimeout:            stallTimeoutConfig(),
+		StallSpeed:              stallSpeed,
+		IgnoreMissing:           ignoreMissing,
+		VerifyRetries:           verifyRetries,
+		ContinueExisting:        continueFiles,
+		OnEvent:                 fanOut(handlers),
+		OnProgress:              func(f parser.FileInfo, onDisk int64) { queue.Progress(f.Name, onDisk) },
+		Carried:                 func(f parser.FileInfo) int64 { return carried[f.Name] },
+		HonorContentDisposition: honorServed,
+		Placeholders:            placeholderPolicy,
+		Mismatches:              mismatchPolicy,
+		AskMismatch:             askMismatch,
+		Auth:                    credentials,
+		Identity:                identity,
+		Progress:                progressReporter(),
+		Logger:                  runLog,
+	}
+	// A download must be a sound archive and match its DAT entry before the
+	// blocklist looks at it
+	var verifiers []downloader.Verifier
+	var archiveCheck *archiveChecker
+	if verifyArchives {
+		archiveCheck = &archiveChecker{}
+		verifiers = append(verifiers, archiveCheck.verifier())
+	}
+	var datCheck *datChecker
+	if verifyDAT != nil && verifyMode != dat.VerifyOff {
+		datCheck = &datChecker{datfile: verifyDAT, mode: verifyMode}
+		verifiers = append(verifiers, datCheck.verifier())
+	}
+	if list != nil {
+		verifiers = append(verifiers, blocklistVerifier(list))
+	}
+	if len(verifiers) > 0 {
+		config.Verifier = chainVerifiers(verifiers)
+	}
+	if sums != nil {
+		config.Checksums = checksumLookup(sums)
+	}
+	if len(userConfig.Mirrors) > 0 {
+		config.Mirrors = userConfig.Alternates
+	}
+	if !refresh {
+		config.Recorded = manifestLog.lookup()
+	}
+	if tmpl != nil {
+		config.Layout = tmpl.Path
+		fmt.Printf("Saving into the %s layout set by reorganize\n", tmpl)
+	}
+	dl := downloader.New(config)
 
-	if err := dl.DownloadAll(ctx, filtered); err != nil {
+	meter := newUsageMeter(dl, source)
+	defer meter.record()
+	if checkpoints != nil {
+		checkpoints.usage = meter
+	}
+	defer setDrainHandler(dl.Drain)()
+
+	runLog.Info("run started", "source", source, "dir", dir, "files", len(files), "bytes", totalSize(files))
+	err = dl.DownloadAll(ctx, files)
+	summary := dl.Summary()
+	logRun(runLog, summary, err)
+	if errors.Is(err, downloader.ErrStopped) {
+		remaining := queue.Tallies()[state.StatusPending]
+		fmt.Printf("\nStopped after %d of %d files; %s files (%s) were not started\n",
+			summary.Completed, summary.Total, formatCount(remaining.Count), formatBytes(remaining.Bytes))
+		printSavings(summary)
+		fmt.Printf("  Run the same command again to continue, or see \"myrient-dl status %s -v\"\n", dir)
+		return err
+	}
+	if err != nil {
+		fmt.Printf("\nCompleted %d of %d files (%d failed: %d corrupt, %d network)\n",
+			summary.Completed, summary.Total, summary.Failed, summary.Corrupt, summary.Failed-summary.Corrupt)
+		printSavings(summary)
+		printWorkers(summary)
 		return fmt.Errorf("download failed: %w", err)
 	}
 
-	fmt.Println("\n✓ All downloads completed!")
+	fmt.Printf("\n✓ All downloads completed! (%d/%d files)\n", summary.Completed, summary.Total)
+	if historyLog != nil {
+		recordRun(historyLog, runPlan, source, dir, files)
+	}
+	printSavings(summary)
+	if summary.Placeholders > 0 {
+		action := "saved"
+		if placeholderPolicy == downloader.PlaceholderSkip {
+			action = "skipped"
+		}
+		fmt.Printf("  ⚠ %d zero-byte or placeholder files (%s)\n", summary.Placeholders, action)
+	}
+	if summary.Rejected > 0 {
+		fmt.Printf("  ⚠ %d files discarded after download because their hash is blocklisted\n", summary.Rejected)
+	}
+	if summary.Gone > 0 {
+		fmt.Printf("  ⚠ %d files gone upstream since the listing was fetched\n", summary.Gone)
+	}
+	if summary.Mirrored > 0 {
+		fmt.Printf("  %d files downloaded from a mirror after the listed URL failed\n", summary.Mirrored)
+	}
+	if summary.Continued > 0 {
+		fmt.Printf("  %d partial files completed with range requests\n", summary.Continued)
+	}
+	if summary.VerifyRetries > 0 {
+		fmt.Printf("  %d re-downloads after failed verification\n", summary.VerifyRetries)
+	}
+	if archiveCheck != nil {
+		archiveCheck.printSummary()
+	}
+	if datCheck != nil {
+		datCheck.printSummary()
+	}
+	printWorkers(summary)
+	if extract != nil {
+		if err := extract.finish(); err != nil {
+			return err
+		}
+	}
+
+	if verifySample >= 0 {
+		if err := runPostVerify(ctx, dl, dir, queue, verifySample); err != nil {
+			return err
+		}
+	}
+	if spots != nil {
+		return spots.run(ctx, dir, queue, manifestLog)
+	}
 	return nil
 }
 
-// getDefaultOutputDir extracts the last meaningful path component from the URL
-func getDefaultOutputDir(u *url.URL) string {
-	// Clean the path and remove trailing slashes
-	cleanPath := strings.TrimSuffix(u.Path, "/")
-
-	// Get the last component
-	lastComponent := path.Base(cleanPath)
+// parsePostVerify interprets --post-verify: "" disables it (-1), "all" checks
+// every file (0), and a number checks that many random files
+func parsePostVerify(value string) (int, error) {
+	switch value {
+	case "":
+		return -1, nil
+	case "all":
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid --post-verify value %q: use all or a number of files", value)
+	}
+	return n, nil
+}
 
-	// Decode URL encoding (e.g., %20 -> space)
-	decoded, err := url.QueryUnescape(lastComponent)
+// runPostVerify re-checks downloaded files against the server and quarantines the
+// ones that changed mid-run, marking them failed so the next run fetches them again
+func runPostVerify(ctx context.Context, dl *downloader.Downloader, dir string, queue *state.Queue, sample int) error {
+	mismatches, checked, err := dl.PostVerify(ctx, sample)
 	if err != nil {
-		decoded = lastComponent
+		return fmt.Errorf("post-verify failed: %w", err)
 	}
 
-	// Sanitize for filesystem
-	sanitized := sanitizeFilename(decoded)
+	changed := 0
+	for _, m := range mismatches {
+		if m.Err != nil {
+			fmt.Printf("  ⚠ %s: could not re-check: %v\n", m.Path, m.Err)
+			continue
+		}
 
-	// Fallback if we got nothing useful
-	if sanitized == "" || sanitized == "." || sanitized == "/" {
-		return "myrient-downloads"
+		changed++
+		if _, err := cleanup.Quarantine(dir, m.Path); err != nil {
+			fmt.Printf("  ⚠ %s: %s (%v)\n", m.Path, m.Reason, err)
+		} else {
+			fmt.Printf("  ⚠ %s: %s (quarantined, will re-download)\n", m.Path, m.Reason)
+		}
+		if err := queue.Update(m.File.Name, state.StatusFailed, fmt.Errorf("remote changed after download: %s", m.Reason)); err != nil {
+			fmt.Printf("  ⚠ Failed to update queue state: %v\n", err)
+		}
+	}
+
+	fmt.Printf("Post-verify: checked %s files, %d changed on the server\n", formatCount(checked), changed)
+	if changed > 0 {
+		return fmt.Errorf("%d files changed on the server during the run; run again to re-download them", changed)
+	}
+	return nil
+}
+
+// queueRecorder returns a download event handler that mirrors progress into the queue
+func queueRecorder(queue *state.Queue) func(downloader.Event) {
+	statuses := map[downloader.EventType]state.Status{
+		downloader.EventStarted:     state.StatusInProgress,
+		downloader.EventCompleted:   state.StatusCompleted,
+		downloader.EventSkipped:     state.StatusSkipped,
+		downloader.EventPlaceholder: state.StatusPlaceholder,
+		downloader.EventRejected:    state.StatusRejected,
+		downloader.EventGone:        state.StatusGone,
+		downloader.EventFailed:      state.StatusFailed,
 	}
 
-	return "./" + sanitized
+	var warnOnce sync.Once
+	return func(e downloader.Event) {
+		if e.Mirror != "" {
+			queue.SetMirror(e.File.Name, e.Mirror)
+		}
+		switch e.Type {
+		case downloader.EventCompleted, downloader.EventSkipped:
+			if e.Bytes > 0 {
+				queue.Progress(e.File.Name, e.Bytes)
+			}
+		case downloader.EventPlaceholder, downloader.EventRejected, downloader.EventGone:
+			queue.Progress(e.File.Name, 0)
+		}
+		if err := queue.Update(e.File.Name, statuses[e.Type], e.Err); err != nil {
+			warnOnce.Do(func() { fmt.Printf("  ⚠ Failed to update queue state: %v\n", err) })
+		}
+	}
 }
 
-// sanitizeFilename removes or replaces characters that are problematic for filenames
-func sanitizeFilename(name string) string {
-	// Replace problematic characters with underscores
-	replacer := strings.NewReplacer(
-		":", "_",
-		"|", "_",
-		"<", "_",
-		">", "_",
-		"\"", "_",
-		"?", "_",
-		"*", "_",
-		"/", "_",
-		"\\", "_",
-		"\x00", "_", // null byte
-	)
-	result := replacer.Replace(name)
+// progressInterval is how often the queue saves how far running downloads got
+const progressInterval = 10 * time.Second
 
-	// Remove leading dots to prevent hidden files
-	result = strings.TrimLeft(result, ".")
+// saveProgress saves the queue's file states and download progress every
+// progressInterval, so a run that crashes can be resumed with accurate totals.
+// The returned function stops it and flushes the queue, which also covers Ctrl-C.
+func saveProgress(queue *state.Queue) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				_ = queue.SaveProgress()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+		if err := queue.Flush(); err != nil {
+			fmt.Printf("  ⚠ Failed to save download progress: %v\n", err)
+		}
+	}
+}
+
+// printSavings reports the bytes downloaded and the bytes skipping and continuing avoided
+func printSavings(summary downloader.Summary) {
+	fmt.Printf("  Downloaded %s", formatBytes(summary.DownloadedBytes))
+	if summary.Skipped > 0 {
+		fmt.Printf(", saved %s by skipping %s existing files", formatBytes(summary.SkippedBytes), formatCount(summary.Skipped))
+	}
+	if summary.ReusedBytes > 0 {
+		fmt.Printf(", reused %s of partial files", formatBytes(summary.ReusedBytes))
+	}
+	fmt.Println()
+}
 
-	// Prevent directory traversal
-	if strings.Contains(result, "..") {
-		result = strings.ReplaceAll(result, "..", "_")
+// printWorkers shows how each worker spent the run in verbose mode, to help tune
+// --parallel: idle workers mean more parallelism won't help, and per-worker speeds
+// that fall as workers are added point to server throttling
+func printWorkers(summary downloader.Summary) {
+	if !verbose || len(summary.Workers) == 0 {
+		return
+	}
+	fmt.Println("  Workers:")
+	for i, w := range summary.Workers {
+		fmt.Printf("    #%d  %3d files  %10s  downloading %-10s %12s  waiting %s\n",
+			i+1, w.Files, formatBytes(w.Bytes), w.Downloading.Round(time.Second),
+			formatBytes(int64(w.Speed()))+"/s", w.Waiting.Round(time.Second))
 	}
+}
 
-	return result
+// fanOut returns an event handler that calls each handler in turn
+func fanOut(handlers []func(downloader.Event)) func(downloader.Event) {
+	return func(e downloader.Event) {
+		for _, h := range handlers {
+			h(e)
+		}
+	}
+}
+
+// chainVerifiers returns a verifier that runs each verifier in turn, stopping at the first error
+func chainVerifiers(verifiers []downloader.Verifier) downloader.Verifier {
+	return downloader.VerifierFunc(func(file parser.FileInfo, path string) error {
+		for _, v := range verifiers {
+			if err := v.Verify(file, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// totalSize sums the listed sizes of the files
+func totalSize(files []parser.FileInfo) int64 {
+	var total int64
+	for _, f := range files {
+		total += f.Size
+	}
+	return total
+}
+
+// getDefaultOutputDir builds an output directory from the last dirDepth path
+// components of the URL, e.g. "No-Intro/Nintendo - NES" for a depth of 2
+func getDefaultOutputDir(u *url.URL) string {
+	// Split the escaped path so encoded slashes stay inside their component
+	segments := strings.Split(strings.Trim(u.EscapedPath(), "/"), "/")
+	start := max(len(segments)-max(dirDepth, 1), 0)
+
+	// Never climb above the collection on Myrient's /files/<Collection>/ layout
+	for i, segment := range segments[:len(segments)-1] {
+		if segment == "files" {
+			start = max(start, i+1)
+			break
+		}
+	}
+
+	var parts []string
+	for _, segment := range segments[start:] {
+		// Decode URL encoding (e.g., %20 -> space)
+		decoded, err := url.PathUnescape(segment)
+		if err != nil {
+			decoded = segment
+		}
+
+		// Sanitize for filesystem
+		name := fsutil.SanitizeFilename(decoded)
+		if slugify {
+			name = fsutil.Slugify(decoded)
+		}
+		if name != "" && name != "." {
+			parts = append(parts, name)
+		}
+	}
+
+	// Fallback if we got nothing useful
+	if len(parts) == 0 {
+		return "myrient-downloads"
+	}
+
+	return "./" + filepath.Join(parts...)
+}
+
+// formatCount formats a count with thousands separators, e.g. 1,204
+func formatCount(n int) string {
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0 && s[i-1] != '-'; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
 }
 
 // formatBytes formats byte sizes in human-readable format
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/searches"
+	"github.com/spf13/cobra"
+)
+
+var saveCmd = &cobra.Command{
+	Use:   "save NAME URL [flags]",
+	Short: "Save a selection under a name to re-run later",
+	Long: `Save a listing URL with its filters and options under a name, to re-run with
+"myrient-dl run NAME".
+
+Everything after NAME is what you would pass to myrient-dl itself, e.g.
+
+  myrient-dl save nes-usa https://myrient.erista.me/files/No-Intro/Nintendo%20-%20Nintendo%20Entertainment%20System%20%28Headered%29/ -i "*(USA)*" -p 4
+
+Saving under an existing name replaces it. Searches are stored in
+searches.yaml in the user config directory.`,
+	DisableFlagParsing: true,
+	RunE:               runSave,
+}
+
+var runSavedCmd = &cobra.Command{
+	Use:   "run NAME [flags]",
+	Short: "Re-run a saved selection",
+	Long: `Run a selection saved with "myrient-dl save".
+
+Flags given after NAME are added to the saved ones, and take precedence for
+flags that hold a single value, e.g. "myrient-dl run nes-usa --dry-run".`,
+	DisableFlagParsing: true,
+	RunE:               runSaved,
+}
+
+var searchesCmd = &cobra.Command{
+	Use:   "searches",
+	Short: "List saved selections",
+	Args:  cobra.NoArgs,
+	RunE:  runSearches,
+}
+
+var searchesRmCmd = &cobra.Command{
+	Use:   "rm NAME...",
+	Short: "Delete saved selections",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runSearchesRm,
+}
+
+func init() {
+	searchesCmd.AddCommand(searchesRmCmd)
+	rootCmd.AddCommand(saveCmd, runSavedCmd, searchesCmd)
+}
+
+// wantsHelp reports whether unparsed arguments ask for help
+func wantsHelp(args []string) bool {
+	return len(args) == 0 || slices.Contains(args, "-h") || slices.Contains(args, "--help")
+}
+
+// loadSearches opens the saved searches in the user config directory
+func loadSearches() (*searches.Store, error) {
+	path, err := searches.DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return searches.Load(path)
+}
+
+func runSave(c *cobra.Command, args []string) error {
+	if wantsHelp(args) {
+		return c.Help()
+	}
+	name, rest := args[0], args[1:]
+	if err := searches.ValidateName(name); err != nil {
+		return err
+	}
+
+	// Parse the way "myrient-dl URL ..." would, so mistakes show up now rather
+	// than on the next run
+	if err := rootCmd.ParseFlags(rest); err != nil {
+		return err
+	}
+	positional := rootCmd.Flags().Args()
+	if len(positional) != 1 {
+		return fmt.Errorf("expected one listing URL after the name, got %d arguments", len(positional))
+	}
+	target := positional[0]
+	if u, err := url.Parse(target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid URL %q", target)
+	}
+	flags := slices.Clone(rest)
+	flags = slices.Delete(flags, slices.Index(flags, target), slices.Index(flags, target)+1)
+
+	store, err := loadSearches()
+	if err != nil {
+		return err
+	}
+	replaced, err := store.Put(name, searches.Search{URL: target, Flags: flags, SavedAt: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	if err := store.Save(); err != nil {
+		return err
+	}
+
+	verb := "Saved"
+	if replaced {
+		verb = "Updated"
+	}
+	fmt.Printf("✓ %s %q; run it with: myrient-dl run %s\n", verb, name, name)
+	return nil
+}
+
+func runSaved(c *cobra.Command, args []string) error {
+	if wantsHelp(args[:min(len(args), 1)]) {
+		return c.Help()
+	}
+	name, extra := args[0], args[1:]
+
+	store, err := loadSearches()
+	if err != nil {
+		return err
+	}
+	search, ok := store.Get(name)
+	if !ok {
+		return fmt.Errorf("no saved search named %q (see \"myrient-dl searches\")", name)
+	}
+
+	all := append(search.Args(), extra...)
+	if err := rootCmd.ParseFlags(all); err != nil {
+		return err
+	}
+	positional := rootCmd.Flags().Args()
+	if len(positional) != 1 {
+		return fmt.Errorf("expected only flags after the name, got %d arguments", len(positional)-1)
+	}
+	// Flag parsing was deferred to here, so --auth and friends apply only now
+	if err := rootCmd.PersistentPreRunE(rootCmd, positional); err != nil {
+		return err
+	}
+
+	fmt.Printf("Running %q: myrient-dl %s\n\n", name, shellJoin(all))
+	search.LastRun = time.Now().UTC()
+	if _, err := store.Put(name, search); err == nil {
+		if err := store.Save(); err != nil {
+			fmt.Printf("  ⚠ %v\n", err)
+		}
+	}
+	return run(rootCmd, positional)
+}
+
+func runSearches(_ *cobra.Command, _ []string) error {
+	store, err := loadSearches()
+	if err != nil {
+		return err
+	}
+	names := store.Names()
+	if len(names) == 0 {
+		fmt.Println("No saved searches; create one with \"myrient-dl save NAME URL [flags]\"")
+		return nil
+	}
+
+	for _, name := range names {
+		s, _ := store.Get(name)
+		lastRun := "never run"
+		if !s.LastRun.IsZero() {
+			lastRun = "last run " + s.LastRun.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Printf("%s (%s)\n", name, lastRun)
+		fmt.Printf("  %s\n", s.URL)
+		if len(s.Flags) > 0 {
+			fmt.Printf("  %s\n", shellJoin(s.Flags))
+		}
+	}
+	return nil
+}
+
+func runSearchesRm(_ *cobra.Command, args []string) error {
+	store, err := loadSearches()
+	if err != nil {
+		return err
+	}
+	for _, name := range args {
+		if !store.Delete(name) {
+			return fmt.Errorf("no saved search named %q", name)
+		}
+	}
+	if err := store.Save(); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Deleted %s\n", strings.Join(args, ", "))
+	return nil
+}
+
+// shellJoin joins arguments into a line that can be pasted into a shell
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\$`*?[]{}()<>|&;#~!") {
+			quoted[i] = arg
+			continue
+		}
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,721 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/catalog"
+	"github.com/nchapman/myrient-dl/internal/checksums"
+	"github.com/nchapman/myrient-dl/internal/dat"
+	"github.com/nchapman/myrient-dl/internal/downloader"
+	"github.com/nchapman/myrient-dl/internal/history"
+	"github.com/nchapman/myrient-dl/internal/matcher"
+	"github.com/nchapman/myrient-dl/internal/naming"
+	"github.com/nchapman/myrient-dl/internal/parser"
+	"github.com/nchapman/myrient-dl/internal/plan"
+	"github.com/nchapman/myrient-dl/internal/retry"
+	"github.com/nchapman/myrient-dl/internal/tagcache"
+	"github.com/nchapman/myrient-dl/internal/units"
+	"github.com/spf13/cobra"
+)
+
+// Selection flags shared by every command that resolves a listing into files
+var (
+	includePatterns []string
+	excludePatterns []string
+	includeRegexps  []string
+	excludeRegexps  []string
+	matchScope      string
+	noBeta          bool
+	noProto         bool
+	noDemo          bool
+	noUnlicensed    bool
+	noBIOS          bool
+	datFile         string
+	serialPatterns  []string
+	dedupeSerial    bool
+	withDeps        bool
+	setType         string
+	withBIOS        bool
+
+	categories        []string
+	excludeCategories []string
+	statuses          []string
+	excludeStatuses   []string
+	explain           bool
+	onCollision       string
+	onDuplicate       string
+
+	prioritize     bool
+	preferSmallest bool
+	limit          int
+	maxTotal       string
+	minSize        string
+	maxSize        string
+	newerThan      string
+	olderThan      string
+	warnOver       string
+	skipOver       string
+
+	allowCrossHost bool
+	recursive      bool
+	maxDepth       int
+)
+
+// patternList is a repeatable flag whose values are also split into patterns
+// at commas, following matcher.SplitList
+type patternList struct {
+	patterns *[]string
+	changed  bool
+}
+
+func newPatternList(patterns *[]string, defaults []string) *patternList {
+	*patterns = defaults
+	return &patternList{patterns: patterns}
+}
+
+func (l *patternList) Set(value string) error {
+	if !l.changed {
+		*l.patterns, l.changed = nil, true // Replace the default
+	}
+	*l.patterns = append(*l.patterns, matcher.SplitList(value)...)
+	return nil
+}
+
+func (l *patternList) String() string {
+	if len(*l.patterns) == 0 {
+		return "" // No default shown in the help
+	}
+	return "[" + strings.Join(*l.patterns, ",") + "]"
+}
+
+func (l *patternList) Type() string {
+	return "stringArray"
+}
+
+// addSelectionFlags registers the flags that decide which files are selected
+func addSelectionFlags(c *cobra.Command) {
+	c.Flags().VarP(newPatternList(&includePatterns, []string{"*"}), "include", "i", "Include pattern (glob syntax, repeatable or comma-separated; \\, for a literal comma)")
+	c.Flags().VarP(newPatternList(&excludePatterns, []string{}), "exclude", "e", "Exclude pattern (glob syntax, repeatable or comma-separated; \\, for a literal comma)")
+	c.Flags().StringArrayVar(&includeRegexps, "include-regex", []string{}, "Include files matching this regular expression anywhere in the name (repeatable; combines with --include)")
+	c.Flags().StringArrayVar(&excludeRegexps, "exclude-regex", []string{}, "Exclude files matching this regular expression anywhere in the name (repeatable)")
+	c.Flags().BoolVar(&noBeta, "no-beta", false, "Exclude betas, tagged (Beta) or (Beta 2)")
+	c.Flags().BoolVar(&noProto, "no-proto", false, "Exclude prototypes, tagged (Proto) or (Prototype)")
+	c.Flags().BoolVar(&noDemo, "no-demo", false, "Exclude demos, tagged (Demo), (Sample), (Kiosk), or (Trial)")
+	c.Flags().BoolVar(&noUnlicensed, "no-unlicensed", false, "Exclude unlicensed releases, tagged (Unl) or (Unlicensed)")
+	c.Flags().BoolVar(&noBIOS, "no-bios", false, "Exclude BIOS files, tagged [BIOS]")
+	c.Flags().StringVar(&matchScope, "match-scope", "name", "What --include and --exclude match: name (the base name) or path (the path below the listing, e.g. SNES/*.zip)")
+	c.Flags().StringVar(&datFile, "dat", "", "Logiqx XML DAT file (No-Intro/Redump) describing the set")
+	c.Flags().StringArrayVar(&serialPatterns, "serial", []string{}, "Include only titles whose DAT serial matches (glob syntax, repeatable, requires --dat)")
+	c.Flags().BoolVar(&dedupeSerial, "dedupe-serial", false, "Keep only the first title of each DAT serial (requires --dat)")
+	c.Flags().BoolVar(&withDeps, "with-deps", false, "Also download parent sets, BIOSes, and device ROMs required by MAME games (requires --dat)")
+	c.Flags().StringVar(&setType, "set-type", "", "MAME set type: split, merged, or non-merged (auto-detected from URL)")
+	c.Flags().BoolVar(&withBIOS, "with-bios", false, "Also download the BIOS/firmware files for the detected system")
+	c.Flags().StringArrayVar(&categories, "category", []string{}, "Include only DAT entries in this category, e.g. Games (repeatable, requires --dat)")
+	c.Flags().StringArrayVar(&excludeCategories, "exclude-category", []string{}, "Exclude DAT entries in this category (repeatable, requires --dat)")
+	c.Flags().StringArrayVar(&statuses, "status", []string{}, "Include only DAT entries with this dump status: verified, good, baddump, nodump (repeatable, requires --dat)")
+	c.Flags().StringArrayVar(&excludeStatuses, "exclude-status", []string{}, "Exclude DAT entries with this dump status (repeatable, requires --dat)")
+	c.Flags().BoolVar(&explain, "explain", false, "Print the DAT-derived keep/drop decision for every file (requires --dat)")
+	c.Flags().StringVar(&onDuplicate, "on-duplicate", "all", "What to do when several files share a base title (regional variants, revisions): all, first, or ask")
+	c.Flags().BoolVar(&prioritize, "prioritize", false, "Order files by the first --include pattern they match, so earlier patterns download first")
+	c.Flags().BoolVar(&preferSmallest, "prefer-smallest", false, "When a title is offered in several compressed formats (e.g. .zip and .7z), keep only the smallest")
+	c.Flags().IntVar(&limit, "limit", 0, "Select at most this many files (0 = no limit)")
+	c.Flags().StringVar(&minSize, "min-size", "", "Only select files of at least this size, e.g. 1M (files without a listed size are kept)")
+	c.Flags().StringVar(&maxSize, "max-size", "", "Only select files of at most this size, e.g. 4G (files without a listed size are kept)")
+	c.Flags().StringVar(&newerThan, "newer-than", "", "Only select files the listing dates at or after this, e.g. 2024-01-01 (UTC) or 7d (files without a date are kept)")
+	c.Flags().StringVar(&olderThan, "older-than", "", "Only select files the listing dates before this, e.g. 2024-01-01 (UTC) or 30d (files without a date are kept)")
+	c.Flags().StringVar(&maxTotal, "max-total", "", "Select files until their total size would exceed this budget, e.g. 50GiB")
+	c.Flags().StringVar(&warnOver, "warn-over", "", "Warn about selected files larger than this size, e.g. 20GiB")
+	c.Flags().BoolVar(&exactSizes, "exact-sizes", false, "Ask the server for each selected file's exact size (a HEAD request each) instead of using the listing's rounded sizes")
+	c.Flags().StringVar(&skipOver, "skip-over", "", "Leave out files larger than this size, e.g. 50GiB")
+	c.Flags().StringVar(&onCollision, "on-collision", "rename", "What to do when remote names map to the same local file: rename, skip, overwrite (keep the last), error, or ask")
+	addBlocklistFlag(c)
+	addListingFlags(c)
+}
+
+// addListingFlags registers the flags that control which listing links are followed
+func addListingFlags(c *cobra.Command) {
+	c.Flags().BoolVar(&allowCrossHost, "allow-cross-host", false, "Follow listing links and redirects to other hosts, e.g. mirror redirect pages")
+	c.Flags().BoolVarP(&recursive, "recursive", "R", false, "Also list subdirectories, mirroring their structure locally")
+	c.Flags().IntVar(&maxDepth, "max-depth", 0, "How many levels of subdirectories to list (implies --recursive; 0 = no limit)")
+}
+
+// listingOptions returns the parser options selected by the listing flags
+func listingOptions() parser.Options {
+	opts := parser.Options{
+		AllowCrossHost: allowCrossHost,
+		Auth:           credentials,
+		Client:         downloader.NewClient(downloader.Config{ConnectTimeout: connectTimeout, StallTimeout: stallTimeoutConfig()}),
+		StallTimeout:   stallTimeoutConfig(),
+		Identity:       identity,
+		Recursive:      recursive || maxDepth > 0,
+		MaxDepth:       maxDepth,
+		Retry:          retry.Policy{Attempts: retryAttempts, Base: backoffBase, Max: backoffMax},
+		OnRetry: func(pageURL string, attempt int, delay time.Duration, err error) {
+			fmt.Printf("  ⚠ Listing attempt %d failed (%v), retrying in %v...\n", attempt, err, delay.Round(time.Millisecond))
+		},
+	}
+	if verbose && opts.Recursive {
+		opts.OnListing = func(pageURL string, files int) {
+			fmt.Printf("  Listed %s (%d files)\n", pageURL, files)
+		}
+	}
+	return opts
+}
+
+// printSelectionSettings prints the active selection flags in verbose mode
+func printSelectionSettings() {
+	if len(includeRegexps) == 0 || !slices.Equal(includePatterns, []string{"*"}) {
+		fmt.Printf("Include patterns: %v\n", includePatterns)
+	}
+	if len(excludePatterns) > 0 {
+		fmt.Printf("Exclude patterns: %v\n", excludePatterns)
+	}
+	if len(includeRegexps) > 0 {
+		fmt.Printf("Include expressions: %v\n", includeRegexps)
+	}
+	if len(excludeRegexps) > 0 {
+		fmt.Printf("Exclude expressions: %v\n", excludeRegexps)
+	}
+	if kinds := excludedKinds(); len(kinds) > 0 {
+		fmt.Printf("Excluding: %s\n", joinKinds(kinds))
+	}
+	if matchScope != "name" {
+		fmt.Printf("Patterns match: %s\n", matchScope)
+	}
+	if minSize != "" || maxSize != "" {
+		fmt.Printf("File sizes: %s to %s\n", cmp.Or(minSize, "any"), cmp.Or(maxSize, "any"))
+	}
+	if after, before, err := dateBounds(); err == nil && (!after.IsZero() || !before.IsZero()) {
+		fmt.Printf("Listed dates: %s to %s\n", formatBound(after), formatBound(before))
+	}
+	switch {
+	case maxDepth > 0:
+		fmt.Printf("Recursive: up to %d levels\n", maxDepth)
+	case recursive:
+		fmt.Println("Recursive: all levels")
+	}
+}
+
+// validatePatterns checks every pattern flag before anything is fetched,
+// reporting all the malformed ones at once
+func validatePatterns() error {
+	return errors.Join(
+		matcher.Validate("--include", includePatterns),
+		matcher.Validate("--exclude", excludePatterns),
+		matcher.ValidateRegexps("--include-regex", includeRegexps),
+		matcher.ValidateRegexps("--exclude-regex", excludeRegexps),
+		matcher.Validate("--serial", serialPatterns),
+		matcher.Validate("--force-redownload", forceRedownload),
+		matcher.Validate("--extract-member", extractMembers),
+	)
+}
+
+// listingFiles is every file of the listing last selected from, filtered or not
+var listingFiles []parser.FileInfo
+
+// selectFiles fetches the listing at targetURL and applies all selection flags to it
+func selectFiles(ctx context.Context, targetURL string) ([]parser.FileInfo, error) {
+	if err := validatePatterns(); err != nil {
+		return nil, err
+	}
+
+	criteria := dat.Criteria{
+		Categories:        categories,
+		ExcludeCategories: excludeCategories,
+		Statuses:          statuses,
+		ExcludeStatuses:   excludeStatuses,
+	}
+	if (len(serialPatterns) > 0 || dedupeSerial || withDeps || !criteria.IsZero() || explain) && datFile == "" {
+		return nil, fmt.Errorf("--serial, --dedupe-serial, --with-deps, --category, --status, and --explain require --dat")
+	}
+	if err := errors.Join(
+		dat.ValidateStatuses("--status", statuses),
+		dat.ValidateStatuses("--exclude-status", excludeStatuses),
+	); err != nil {
+		return nil, err
+	}
+
+	if noBIOS && withBIOS {
+		return nil, fmt.Errorf("--no-bios and --with-bios can't be used together")
+	}
+
+	collisionPolicy, err := plan.ParseCollisionPolicy(onCollision)
+	if err != nil {
+		return nil, err
+	}
+
+	duplicatePolicy, err := plan.ParseDuplicatePolicy(onDuplicate)
+	if err != nil {
+		return nil, err
+	}
+
+	scope, err := matcher.ParseScope(matchScope)
+	if err != nil {
+		return nil, err
+	}
+	globs := includePatterns
+	if len(includeRegexps) > 0 && slices.Equal(globs, []string{"*"}) {
+		globs = nil // The default --include would let everything through
+	}
+	minBytes, maxBytes, err := sizeBounds()
+	if err != nil {
+		return nil, err
+	}
+	after, before, err := dateBounds()
+	if err != nil {
+		return nil, err
+	}
+	m, err := matcher.New(globs, excludePatterns).WithScope(scope).WithSizes(minBytes, maxBytes).WithModTimes(after, before).WithoutKinds(excludedKinds()).WithRegexps(includeRegexps, excludeRegexps)
+	if err != nil {
+		return nil, err
+	}
+
+	// Checked now so a bad value fails before the listing is fetched
+	if _, err := selectionBudget(); err != nil {
+		return nil, err
+	}
+
+	var warnSize, skipSize int64
+	if warnOver != "" {
+		if warnSize, err = units.ParseSize(warnOver); err != nil {
+			return nil, fmt.Errorf("invalid --warn-over: %w", err)
+		}
+	}
+	if skipOver != "" {
+		if skipSize, err = units.ParseSize(skipOver); err != nil {
+			return nil, fmt.Errorf("invalid --skip-over: %w", err)
+		}
+	}
+	if maxDepth < 0 {
+		return nil, fmt.Errorf("--max-depth must not be negative")
+	}
+
+	mameSetType := dat.DetectSetType(targetURL)
+	if setType != "" {
+		mameSetType, err = dat.ParseSetType(setType)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	list, err := loadBlocklist()
+	if err != nil {
+		return nil, err
+	}
+
+	// Load DAT before touching the network so a bad path fails fast
+	var datfile *dat.Datafile
+	if datFile != "" {
+		datfile, err = dat.Load(datFile)
+		if err != nil {
+			return nil, err
+		}
+		if verbose {
+			fmt.Printf("Loaded DAT: %s (%d entries)\n", datfile.Header.Name, len(datfile.Games))
+		}
+	}
+
+	// Parse directory listing
+	fmt.Println("Fetching directory listing...")
+	trustListing(targetURL)
+	listing, err := parser.FetchListing(ctx, targetURL, listingOptions())
+	if errors.Is(err, parser.ErrEmpty) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse directory listing: %w", err)
+	}
+	files := listing.Files
+	rememberListing(targetURL, files)
+	loadListingTags(listing)
+	if len(listing.Unsafe) > 0 {
+		fmt.Printf("  ⚠ Ignored %d listing entries whose names would be saved outside the output directory\n", len(listing.Unsafe))
+		if verbose {
+			for _, name := range listing.Unsafe {
+				fmt.Printf("    %q\n", name)
+			}
+		}
+	}
+
+	if verbose {
+		fmt.Printf("Found %d files\n", len(files))
+	}
+	if (newerThan != "" || olderThan != "") && !slices.ContainsFunc(files, func(f parser.FileInfo) bool { return !f.ModTime.IsZero() }) {
+		fmt.Println("  ⚠ The listing shows no dates, so --newer-than and --older-than keep every file")
+	}
+
+	// Filter files based on patterns
+	filtered := m.Filter(files)
+
+	// Apply DAT-based filtering, deduplication, and set completion
+	if datfile != nil {
+		filtered = applyDATFilters(datfile, files, filtered, criteria, mameSetType)
+	}
+
+	if withBIOS {
+		filtered, err = addBIOSFiles(ctx, targetURL, files, filtered)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if list != nil {
+		filtered = applyBlocklist(list, datfile, filtered)
+	}
+
+	filtered = resolveDuplicates(filtered, duplicatePolicy)
+	if exactSizes && len(filtered) > 0 {
+		if filtered = reconcileSizes(ctx, filtered); filtered == nil {
+			return nil, ctx.Err()
+		}
+	}
+	filtered = guardFileSizes(filtered, warnSize, skipSize)
+
+	// Make sure no two files land on the same local path
+	choose := func(parser.FileInfo, parser.FileInfo) plan.CollisionPolicy { return collisionPolicy }
+	if collisionPolicy == plan.CollisionAsk {
+		choose = askCollision
+	}
+	filtered, collisions, err := plan.ResolveCollisionsFunc(filtered, choose)
+	printCollisions(collisions, collisionPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	if prioritize {
+		filtered = m.Prioritize(filtered)
+	}
+
+	listingChecksums = checksums.Find(files, filtered)
+	listingDATs = nil
+	if datfile == nil {
+		listingDATs = dat.Find(files, filtered)
+	}
+	listingFiles = files
+	selectionDAT = datfile
+	return filtered, nil
+}
+
+// excludedKinds returns the release kinds the --no-* flags leave out
+func excludedKinds() []naming.Kind {
+	var kinds []naming.Kind
+	for _, f := range []struct {
+		kind     naming.Kind
+		excluded bool
+	}{
+		{naming.Beta, noBeta},
+		{naming.Proto, noProto},
+		{naming.Demo, noDemo},
+		{naming.Unlicensed, noUnlicensed},
+		{naming.BIOS, noBIOS},
+	} {
+		if f.excluded {
+			kinds = append(kinds, f.kind)
+		}
+	}
+	return kinds
+}
+
+// joinKinds lists release kinds for messages, e.g. "beta, proto"
+func joinKinds(kinds []naming.Kind) string {
+	names := make([]string, len(kinds))
+	for i, k := range kinds {
+		names[i] = string(k)
+	}
+	return strings.Join(names, ", ")
+}
+
+// sizeBounds parses --min-size and --max-size; zero leaves a bound off
+func sizeBounds() (minBytes, maxBytes int64, err error) {
+	if minSize != "" {
+		if minBytes, err = units.ParseSize(minSize); err != nil {
+			return 0, 0, fmt.Errorf("invalid --min-size: %w", err)
+		}
+	}
+	if maxSize != "" {
+		if maxBytes, err = units.ParseSize(maxSize); err != nil {
+			return 0, 0, fmt.Errorf("invalid --max-size: %w", err)
+		}
+	}
+	if maxBytes > 0 && minBytes > maxBytes {
+		return 0, 0, fmt.Errorf("--min-size %s is larger than --max-size %s", minSize, maxSize)
+	}
+	return minBytes, maxBytes, nil
+}
+
+// dateBounds parses --newer-than and --older-than; zero leaves a bound off
+func dateBounds() (after, before time.Time, err error) {
+	now := time.Now()
+	if newerThan != "" {
+		if after, err = history.ParseSince(newerThan, now); err != nil {
+			return after, before, fmt.Errorf("invalid --newer-than: %w", err)
+		}
+	}
+	if olderThan != "" {
+		if before, err = history.ParseSince(olderThan, now); err != nil {
+			return after, before, fmt.Errorf("invalid --older-than: %w", err)
+		}
+	}
+	if !after.IsZero() && !before.IsZero() && !after.Before(before) {
+		return after, before, fmt.Errorf("--newer-than %s is not before --older-than %s", newerThan, olderThan)
+	}
+	return after, before, nil
+}
+
+// formatBound formats a date bound in UTC, the time zone listings are read in
+func formatBound(t time.Time) string {
+	if t.IsZero() {
+		return "any"
+	}
+	return t.UTC().Format("2006-01-02 15:04") + " UTC"
+}
+
+// selectionBudget validates --limit and returns the --max-total size cap
+func selectionBudget() (int64, error) {
+	if limit < 0 {
+		return 0, fmt.Errorf("--limit must not be negative")
+	}
+	if maxTotal == "" {
+		return 0, nil
+	}
+	budget, err := units.ParseSize(maxTotal)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --max-total: %w", err)
+	}
+	return budget, nil
+}
+
+// formatVariants are the choices --prefer-smallest made for the selection,
+// recorded in plans
+var formatVariants []plan.FormatVariant
+
+// preferSmallestVariants applies --prefer-smallest to a selection and reports
+// what it saved
+func preferSmallestVariants(files []parser.FileInfo) []parser.FileInfo {
+	formatVariants = nil
+	if !preferSmallest {
+		return files
+	}
+	kept, variants := plan.PreferSmallest(files)
+	if len(variants) == 0 {
+		return files
+	}
+
+	names := make(map[string]string, len(files))
+	for _, f := range files {
+		names[f.URL] = f.Name
+	}
+	var saved int64
+	for _, v := range variants {
+		saved += v.Saved
+		if verbose {
+			fmt.Printf("  %s instead of", names[v.Kept])
+			for _, u := range v.Dropped {
+				fmt.Printf(" %s", names[u])
+			}
+			fmt.Println()
+		}
+	}
+	fmt.Printf("Smallest formats: %d titles offered in several, leaving out %d files (saving %s)\n", len(variants), len(files)-len(kept), formatBytes(saved))
+	formatVariants = variants
+	return kept
+}
+
+// applyLimits caps a selection at --limit files and --max-total bytes, after
+// every listing of the run has been selected from
+func applyLimits(files []parser.FileInfo) ([]parser.FileInfo, error) {
+	budget, err := selectionBudget()
+	if err != nil || (limit == 0 && budget == 0) {
+		return files, err
+	}
+	kept, dropped := matcher.Budget(files, limit, budget)
+	if len(dropped) > 0 {
+		fmt.Printf("Limit reached: leaving out %d files (%s)\n", len(dropped), formatBytes(totalSize(dropped)))
+	}
+	return kept, nil
+}
+
+// listingTags holds the parsed name metadata of the current listing, cached by
+// name so re-planning a listing only parses the names it hasn't seen
+var listingTags *tagcache.Cache
+
+// loadListingTags parses the names in listing, reusing the metadata cached for
+// names seen before. The cache only saves work, so failures are ignored.
+func loadListingTags(listing *parser.Listing) {
+	dir, err := tagcache.DefaultDir()
+	if err != nil {
+		return
+	}
+	listingTags = tagcache.Load(dir, listing.URL)
+
+	names := make([]string, len(listing.Files))
+	for i, f := range listing.Files {
+		names[i] = f.Name
+	}
+	if cached := listingTags.Fill(names); verbose && cached > 0 {
+		fmt.Printf("Reusing parsed tags for %s of %s files\n", formatCount(cached), formatCount(len(names)))
+	}
+	if err := listingTags.Save(); err != nil && verbose {
+		fmt.Printf("  ⚠ %v\n", err)
+	}
+}
+
+// maxTagSummary is how many tags printTagSummary shows without --verbose
+const maxTagSummary = 10
+
+// printTagSummary groups the selection by name tag (region, language, revision,
+// Beta, ...) so filters can be sanity-checked at a glance
+func printTagSummary(files []parser.FileInfo) {
+	type group struct {
+		label string
+		count int
+		bytes int64
+	}
+
+	index := make(map[string]int)
+	var groups []group
+	for _, f := range files {
+		for _, label := range listingTags.Describe(f.Name).Labels {
+			i, ok := index[label]
+			if !ok {
+				i = len(groups)
+				index[label] = i
+				groups = append(groups, group{label: label})
+			}
+			groups[i].count++
+			groups[i].bytes += f.Size
+		}
+	}
+	if len(groups) == 0 {
+		return
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].count > groups[j].count })
+
+	shown := groups
+	if !verbose && len(shown) > maxTagSummary {
+		shown = shown[:maxTagSummary]
+	}
+	fmt.Println("By tag:")
+	for _, g := range shown {
+		fmt.Printf("  %-24s %6s files  %10s\n", g.label, formatCount(g.count), formatBytes(g.bytes))
+	}
+	if hidden := len(groups) - len(shown); hidden > 0 {
+		fmt.Printf("  ... and %d more tags (--verbose shows all)\n", hidden)
+	}
+}
+
+// guardFileSizes drops files over skipSize and warns about files over warnSize, so a
+// broad pattern doesn't silently queue a multi-day download. Zero disables a check.
+func guardFileSizes(files []parser.FileInfo, warnSize, skipSize int64) []parser.FileInfo {
+	if skipSize > 0 {
+		var skipped []parser.FileInfo
+		files, skipped = matcher.SplitOver(files, skipSize)
+		if len(skipped) > 0 {
+			fmt.Printf("Skipping %d files over %s (%s):\n", len(skipped), formatBytes(skipSize), formatBytes(totalSize(skipped)))
+			for _, f := range skipped {
+				fmt.Printf("  - %s (%s)\n", f.Name, formatBytes(f.Size))
+			}
+		}
+	}
+
+	if warnSize > 0 {
+		if _, large := matcher.SplitOver(files, warnSize); len(large) > 0 {
+			fmt.Printf("  ⚠ %d files are over %s (use --skip-over to leave them out):\n", len(large), formatBytes(warnSize))
+			for _, f := range large {
+				fmt.Printf("    - %s (%s)\n", f.Name, formatBytes(f.Size))
+			}
+		}
+	}
+
+	return files
+}
+
+// resolveDuplicates applies the duplicate-title policy to the selection
+func resolveDuplicates(files []parser.FileInfo, policy plan.DuplicatePolicy) []parser.FileInfo {
+	switch policy {
+	case plan.DuplicateFirst:
+		resolved := plan.ResolveDuplicatesFunc(files, listingTags.Describe, plan.KeepFirst)
+		if dropped := len(files) - len(resolved); dropped > 0 {
+			fmt.Printf("Kept the first variant of duplicate titles, dropping %d files (%s)\n",
+				dropped, formatBytes(totalSize(files)-totalSize(resolved)))
+		}
+		return resolved
+	case plan.DuplicateAsk:
+		return plan.ResolveDuplicatesFunc(files, listingTags.Describe, chooseVariants)
+	default:
+		return files
+	}
+}
+
+// printCollisions reports remote files that map to the same local path
+func printCollisions(collisions []plan.Collision, policy plan.CollisionPolicy) {
+	if len(collisions) == 0 {
+		return
+	}
+
+	fmt.Printf("  ⚠ %d local filename collisions (policy: %s)\n", len(collisions), policy)
+	for _, c := range collisions {
+		for i, name := range c.Names {
+			switch {
+			case policy == plan.CollisionError:
+				fmt.Printf("    - %s\n", name)
+			case c.Resolved[i] == "":
+				fmt.Printf("    - %s (dropped)\n", name)
+			case c.Resolved[i] != name:
+				fmt.Printf("    - %s -> %s\n", name, c.Resolved[i])
+			default:
+				fmt.Printf("    - %s\n", name)
+			}
+		}
+	}
+}
+
+// addBIOSFiles appends the BIOS files of the system detected from the URL to the selection
+func addBIOSFiles(ctx context.Context, targetURL string, listing, selected []parser.FileInfo) ([]parser.FileInfo, error) {
+	sys, ok := catalog.Detect(targetURL)
+	sources := sys.BIOSSources()
+	if !ok || len(sources) == 0 {
+		fmt.Println("  ⚠ No known BIOS location for this collection, --with-bios ignored")
+		return selected, nil
+	}
+
+	included := make(map[string]bool, len(selected))
+	for _, f := range selected {
+		included[f.URL] = true
+	}
+
+	added := 0
+	for _, src := range sources {
+		candidates := listing
+		if src.URL != "" {
+			if verbose {
+				fmt.Printf("Fetching BIOS listing: %s\n", src.URL)
+			}
+			var err error
+			trustListing(src.URL)
+			candidates, err = parser.ParseDirectoryListing(ctx, src.URL, listingOptions())
+			if err != nil && !errors.Is(err, parser.ErrEmpty) {
+				return nil, fmt.Errorf("failed to fetch BIOS listing: %w", err)
+			}
+		}
+
+		for _, f := range matcher.New(src.Patterns, nil).Filter(candidates) {
+			if !included[f.URL] {
+				included[f.URL] = true
+				selected = append(selected, f)
+				added++
+			}
+		}
+	}
+
+	fmt.Printf("Added %d BIOS files for %s\n", added, sys)
+	return selected, nil
+}
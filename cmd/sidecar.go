@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/downloader"
+	"github.com/nchapman/myrient-dl/internal/hashing"
+	"github.com/nchapman/myrient-dl/internal/sidecar"
+)
+
+var sidecars bool
+
+// sidecarRecorder returns a download event handler that writes a provenance
+// sidecar next to each downloaded file
+func sidecarRecorder(source, dir string) func(downloader.Event) {
+	return func(e downloader.Event) {
+		if e.Type != downloader.EventCompleted {
+			return
+		}
+		local := e.Path
+		if local == "" {
+			local = e.File.Name
+		}
+		path := filepath.Join(dir, local)
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Printf("  ⚠ No sidecar for %s: %v\n", local, err)
+			return
+		}
+
+		// Files completed with --continue-existing weren't hashed on the way in
+		digest := e.SHA256
+		if digest == "" {
+			if digest, err = hashing.File(path, hashing.SHA256); err != nil {
+				fmt.Printf("  ⚠ Sidecar for %s has no hash: %v\n", local, err)
+			}
+		}
+
+		s := sidecar.Sidecar{
+			Name:         e.File.Name,
+			URL:          e.File.URL,
+			Mirror:       e.Mirror,
+			Source:       source,
+			Size:         info.Size(),
+			SHA256:       digest,
+			DownloadedAt: time.Now().UTC(),
+			Collection:   e.File.Collection,
+			System:       e.File.System,
+		}
+		if err := sidecar.Write(path, s); err != nil {
+			fmt.Printf("  ⚠ %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nchapman/myrient-dl/internal/checksums"
+	"github.com/nchapman/myrient-dl/internal/downloader"
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// urlFile is --url-file
+var urlFile string
+
+// source is one listing of a run: where its files go and what was selected
+type source struct {
+	url       string
+	dir       string
+	files     []parser.FileInfo
+	checksums []checksums.Source
+	dats      []parser.FileInfo // DATs in the listing, for verification without --dat
+}
+
+// targetURLs returns the listing URLs given as arguments and in --url-file, in
+// order and without repeats
+func targetURLs(args []string) ([]string, error) {
+	urls := args
+	if urlFile != "" {
+		listed, err := readURLFile(urlFile)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls[:len(urls):len(urls)], listed...)
+	}
+	if len(urls) == 0 {
+		return nil, errors.New("requires a URL or --url-file")
+	}
+
+	seen := make(map[string]bool, len(urls))
+	var unique []string
+	for _, u := range urls {
+		if !seen[u] {
+			seen[u] = true
+			unique = append(unique, u)
+		}
+	}
+	return unique, nil
+}
+
+// readURLFile reads one URL per line; blank lines and lines starting with #
+// are skipped
+func readURLFile(path string) ([]string, error) {
+	f, err := os.Open(path) //nolint:gosec // The user names the file
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --url-file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	var urls []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read --url-file: %w", err)
+	}
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("no URLs in %s", path)
+	}
+	return urls, nil
+}
+
+// resolveSources validates the URLs and picks each one's output directory.
+// With several URLs, -o is the directory their own directories are made in.
+func resolveSources(urls []string) ([]*source, error) {
+	sources := make([]*source, 0, len(urls))
+	for _, target := range urls {
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL %q: %w", target, err)
+		}
+
+		dir := outputDir
+		switch {
+		case dir != "" && len(urls) > 1:
+			dir = filepath.Join(dir, getDefaultOutputDir(u))
+		case dir == "":
+			if dir, err = resolveOutputDir(target, u); err != nil {
+				return nil, err
+			}
+		}
+		sources = append(sources, &source{url: target, dir: dir})
+	}
+	return sources, nil
+}
+
+// selectSources fetches every listing and applies the selection flags to each,
+// then --prefer-smallest and the limits to them all together, returning the combined selection. A
+// file selected from an earlier listing isn't selected again from a later one.
+func selectSources(ctx context.Context, sources []*source) ([]parser.FileInfo, error) {
+	seen := make(map[string]bool)
+	var all []parser.FileInfo
+	for i, s := range sources {
+		if len(sources) > 1 {
+			fmt.Printf("\nListing %d of %d: %s\n", i+1, len(sources), s.url)
+		}
+		files, err := selectFiles(ctx, s.url)
+		if err != nil {
+			if len(sources) > 1 {
+				return nil, fmt.Errorf("%s: %w", s.url, err)
+			}
+			return nil, err
+		}
+		s.files = s.files[:0]
+		for _, f := range files {
+			if !seen[f.URL] {
+				seen[f.URL] = true
+				s.files = append(s.files, f)
+			}
+		}
+		if len(sources) > 1 {
+			fmt.Printf("  %d files selected (%s) for %s\n", len(s.files), formatBytes(totalSize(s.files)), s.dir)
+		}
+		s.checksums = listingChecksums
+		s.dats = listingDATs
+		all = append(all, s.files...)
+	}
+	return applyLimits(preferSmallestVariants(all))
+}
+
+// assign narrows each source's files to those in the final selection, dropping
+// sources left with nothing to do
+func assign(sources []*source, files []parser.FileInfo) []*source {
+	kept := make(map[string]bool, len(files))
+	for _, f := range files {
+		kept[f.URL] = true
+	}
+
+	var active []*source
+	for _, s := range sources {
+		var remaining []parser.FileInfo
+		for _, f := range s.files {
+			if kept[f.URL] {
+				remaining = append(remaining, f)
+			}
+		}
+		if s.files = remaining; len(s.files) > 0 {
+			active = append(active, s)
+		}
+	}
+	return active
+}
+
+// downloadSources downloads each source's files into its directory in turn.
+// A listing with failures doesn't stop the ones after it; an interruption does.
+func downloadSources(ctx context.Context, sources []*source) error {
+	if len(sources) == 1 {
+		listingChecksums, listingDATs = sources[0].checksums, sources[0].dats
+		return downloadFiles(ctx, sources[0].url, sources[0].dir, sources[0].files)
+	}
+
+	var failed []string
+	for i, s := range sources {
+		fmt.Printf("\nListing %d of %d: downloading %d files (%s) into %s\n", i+1, len(sources), len(s.files), formatBytes(totalSize(s.files)), s.dir)
+		listingChecksums, listingDATs = s.checksums, s.dats
+		err := downloadFiles(ctx, s.url, s.dir, s.files)
+		if err == nil {
+			continue
+		}
+		if ctx.Err() != nil || errors.Is(err, downloader.ErrStopped) {
+			return err
+		}
+		fmt.Printf("  ✗ %s: %v\n", s.url, err)
+		failed = append(failed, s.url)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("downloads failed for %d of %d listings", len(failed), len(sources))
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/nchapman/myrient-dl/internal/archive"
+	"github.com/nchapman/myrient-dl/internal/cleanup"
+	"github.com/nchapman/myrient-dl/internal/downloader"
+	"github.com/nchapman/myrient-dl/internal/parser"
+	"github.com/nchapman/myrient-dl/internal/spotcheck"
+	"github.com/nchapman/myrient-dl/internal/state"
+)
+
+// spotCheck is --spot-check: the percentage of a run's zip archives to test after it
+var spotCheck string
+
+// spotChecker collects the zip archives a run leaves on disk and tests a
+// random sample of them once it finishes
+type spotChecker struct {
+	percent float64
+	mu      sync.Mutex
+	zips    []spotFile
+}
+
+type spotFile struct {
+	file parser.FileInfo
+	path string // Relative to the output directory
+}
+
+// newSpotChecker parses --spot-check, returning nil when it isn't set
+func newSpotChecker() (*spotChecker, error) {
+	if spotCheck == "" {
+		return nil, nil
+	}
+	percent, err := spotcheck.ParsePercent(spotCheck)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --spot-check: %w", err)
+	}
+	return &spotChecker{percent: percent}, nil
+}
+
+// recorder collects downloaded and already complete zip archives
+func (s *spotChecker) recorder() func(downloader.Event) {
+	return func(e downloader.Event) {
+		if (e.Type != downloader.EventCompleted && e.Type != downloader.EventSkipped) || e.Path == "" || !archive.IsZip(e.Path) {
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.zips = append(s.zips, spotFile{file: e.File, path: e.Path})
+	}
+}
+
+// run tests the sample, quarantining corrupt archives and marking them failed
+// so the next run downloads them again
+func (s *spotChecker) run(ctx context.Context, dir string, queue *state.Queue, manifestLog *manifestLog) error {
+	if len(s.zips) == 0 {
+		fmt.Println("\nSpot check: no zip archives to test")
+		return nil
+	}
+	sample := spotcheck.Sample(s.zips, spotcheck.SampleSize(len(s.zips), s.percent))
+	fmt.Printf("\nSpot check: testing %s of %s zip archives (%g%%)...\n", formatCount(len(sample)), formatCount(len(s.zips)), s.percent)
+
+	var tested, corrupt, untestable int
+	var bytes int64
+	for _, f := range sample {
+		if ctx.Err() != nil {
+			break
+		}
+		size, err := testZip(filepath.Join(dir, f.path))
+		switch {
+		case errors.Is(err, archive.ErrUnsupported) || errors.Is(err, os.ErrNotExist):
+			untestable++
+			if verbose {
+				fmt.Printf("  ⚠ %s: not tested: %v\n", f.path, err)
+			}
+			continue
+		case err != nil:
+			corrupt++
+			if _, qerr := cleanup.Quarantine(dir, f.path); qerr != nil {
+				fmt.Printf("  ✗ %s: %v (%v)\n", f.path, err, qerr)
+			} else {
+				fmt.Printf("  ✗ %s: %v (quarantined, will re-download)\n", f.path, err)
+			}
+			manifestLog.forget(f.path)
+			queue.Progress(f.file.Name, 0)
+			if err := queue.Update(f.file.Name, state.StatusFailed, fmt.Errorf("spot check: %w", err)); err != nil {
+				fmt.Printf("  ⚠ Failed to update queue state: %v\n", err)
+			}
+		case verbose:
+			fmt.Printf("  ✓ %s\n", f.path)
+		}
+		tested++
+		bytes += size
+	}
+
+	if untestable > 0 {
+		fmt.Printf("  ⚠ %d archives could not be tested (missing or using an unsupported compression method)\n", untestable)
+	}
+	if tested == 0 {
+		return ctx.Err()
+	}
+	bound := spotcheck.UpperBound(tested, corrupt, len(s.zips))
+	if corrupt == 0 {
+		fmt.Printf("  ✓ All %s tested archives passed (%s read)", formatCount(tested), formatBytes(bytes))
+	} else {
+		fmt.Printf("  ✗ %d of %s tested archives are corrupt (%s read)", corrupt, formatCount(tested), formatBytes(bytes))
+	}
+	if tested < len(s.zips) {
+		fmt.Printf("; with 95%% confidence no more than %.1f%% of all %s are corrupt", bound*100, formatCount(len(s.zips)))
+	}
+	fmt.Println()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if corrupt > 0 {
+		return fmt.Errorf("%d spot-checked archives are corrupt; run again to re-download them, and consider checking the rest", corrupt)
+	}
+	return nil
+}
+
+// testZip decompresses every member of a zip archive and returns its size
+func testZip(path string) (int64, error) {
+	f, err := os.Open(path) //nolint:gosec // Path is a download in the user's output directory
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	_, err = archive.Test(f, info.Size())
+	return info.Size(), err
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/nchapman/myrient-dl/internal/manifest"
+	"github.com/nchapman/myrient-dl/internal/state"
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status [DIR]",
+	Short: "Show the state of the last download run in a directory",
+	Long: `Read the download queue recorded in a directory and report pending,
+in-progress, completed, skipped, and failed files with their sizes.
+
+If no download is currently running there, files left in progress are
+reported as interrupted. Bytes already on disk, including partial downloads,
+are saved every few seconds. Use --verbose to list every file that is not done.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runStatus,
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+}
+
+func runStatus(_ *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	queue, err := state.Load(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		if !printManifestSummary(dir) {
+			fmt.Printf("No download queue found in %s\n", dir)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	pid, running := state.Running(dir)
+	fmt.Printf("Source:  %s\n", queue.Source)
+	fmt.Printf("Started: %s\n", queue.StartedAt.Local().Format("2006-01-02 15:04:05"))
+	if running {
+		fmt.Printf("Running: yes (pid %d, last update %s)\n", pid, queue.UpdatedAt.Local().Format("15:04:05"))
+	} else {
+		fmt.Println("Running: no")
+	}
+	fmt.Println()
+
+	tallies := queue.Tallies()
+	var total state.Tally
+	for _, s := range state.Statuses {
+		t := tallies[s]
+		total.Count += t.Count
+		total.Bytes += t.Bytes
+		if t.Count == 0 {
+			continue
+		}
+		label := string(s)
+		if s == state.StatusInProgress && !running {
+			label = "interrupted"
+		}
+		fmt.Printf("  %-12s %6d files  %10s\n", label, t.Count, formatBytes(t.Bytes))
+	}
+	fmt.Printf("  %-12s %6d files  %10s\n", "total", total.Count, formatBytes(total.Bytes))
+	if done := queue.TotalTransferred(); done > 0 {
+		fmt.Printf("\nTransferred: %s of %s\n", formatBytes(done), formatBytes(total.Bytes))
+	}
+
+	if verbose && total.Count > tallies[state.StatusCompleted].Count+tallies[state.StatusSkipped].Count {
+		fmt.Println("\nNot done:")
+		for _, item := range queue.Items {
+			switch item.Status {
+			case state.StatusCompleted, state.StatusSkipped:
+			case state.StatusFailed, state.StatusRejected, state.StatusGone:
+				fmt.Printf("  ✗ %s: %s\n", item.Name, item.Error)
+			default:
+				if item.Transferred > 0 {
+					fmt.Printf("  - %s (%s, %s of %s)\n", item.Name, item.Status, formatBytes(item.Transferred), formatBytes(item.Size))
+					continue
+				}
+				fmt.Printf("  - %s (%s, %s)\n", item.Name, item.Status, formatBytes(item.Size))
+			}
+		}
+	}
+
+	fmt.Println()
+	printManifestSummary(dir)
+	return nil
+}
+
+// printManifestSummary reports what the directory's manifest records, returning
+// false when there is no manifest
+func printManifestSummary(dir string) bool {
+	m, err := manifest.Load(dir)
+	if err != nil {
+		fmt.Printf("  ⚠ %v\n", err)
+		return false
+	}
+	if m.Len() == 0 {
+		return false
+	}
+
+	var bytes int64
+	var imported, unlisted int
+	for _, e := range m.Entries() {
+		bytes += e.Size
+		if e.Imported {
+			imported++
+		}
+		if e.URL == "" {
+			unlisted++
+		}
+	}
+	fmt.Printf("Manifest: %d files (%s), %d imported, %d without a source URL\n", m.Len(), formatBytes(bytes), imported, unlisted)
+	return true
+}
@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nchapman/myrient-dl/internal/layout"
+	"github.com/nchapman/myrient-dl/internal/mirror"
+	"github.com/nchapman/myrient-dl/internal/parser"
+	"github.com/spf13/cobra"
+)
+
+var (
+	syncDelete bool
+	syncDryRun bool
+	syncYes    bool
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync URL",
+	Short: "Bring a download directory up to date with its listing",
+	Long: `Compare a listing with its download directory and download the files that are
+new or changed, like a one-way rsync. Files already complete are skipped; files
+whose size no longer matches the server are replaced (see --on-mismatch).
+
+With --delete, local files the listing no longer has are deleted afterwards,
+along with their sidecars. Files still in the listing are kept even if the
+selection flags leave them out, myrient-dl's own files are never touched, and
+nothing is deleted if a download failed. Subdirectories are only looked at with
+--recursive or a layout set by reorganize.`,
+	Example: `  myrient-dl sync "https://myrient.erista.me/files/No-Intro/Nintendo%20-%20Game%20Boy/" -o /nas/roms/gb --dry-run --delete
+  myrient-dl sync "https://myrient.erista.me/files/No-Intro/Nintendo%20-%20Game%20Boy/" -o /nas/roms/gb --delete -y`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSync,
+}
+
+func init() {
+	syncCmd.Flags().StringVarP(&outputDir, "output", "o", "", "Directory to keep in sync (defaults to a configured output root or the last path component of URL)")
+	syncCmd.Flags().BoolVar(&syncDelete, "delete", false, "Delete local files the listing no longer has")
+	syncCmd.Flags().BoolVar(&syncDryRun, "dry-run", false, "Only report what would be downloaded and deleted")
+	syncCmd.Flags().BoolVarP(&syncYes, "yes", "y", false, "Delete without asking for confirmation")
+	addOutputNameFlags(syncCmd)
+	addSelectionFlags(syncCmd)
+	addDownloadFlags(syncCmd)
+
+	rootCmd.AddCommand(syncCmd)
+}
+
+func runSync(c *cobra.Command, args []string) error {
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	if err := applyLimitRate(); err != nil {
+		return err
+	}
+	if err := applyWindow(); err != nil {
+		return err
+	}
+	if err := applyGentle(c); err != nil {
+		return err
+	}
+	if err := applyTimeouts(); err != nil {
+		return err
+	}
+
+	sources, err := resolveSources(args)
+	if err != nil {
+		return err
+	}
+	s := sources[0]
+	tmpl, err := recordedLayout(s.dir)
+	if err != nil {
+		return err
+	}
+
+	selected, err := selectSources(ctx, sources)
+	if err != nil {
+		return err
+	}
+	s.files = selected
+
+	extracted := extractedArchives(s.dir)
+	var missing []parser.FileInfo
+	for _, f := range selected {
+		if _, ok := extracted[f.URL]; ok && !refresh {
+			continue // Unpacked by --extract and deleted
+		}
+		if _, err := os.Stat(filepath.Join(s.dir, filepath.FromSlash(syncPath(tmpl, f.Name)))); err != nil {
+			missing = append(missing, f)
+		}
+	}
+	fmt.Printf("\nListing: %d files selected (%s), %d not downloaded yet (%s)\n",
+		len(selected), formatBytes(totalSize(selected)), len(missing), formatBytes(totalSize(missing)))
+
+	var extras []mirror.File
+	if syncDelete {
+		expected := make([]string, 0, len(listingFiles)+len(selected))
+		for _, f := range append(listingFiles[:len(listingFiles):len(listingFiles)], selected...) {
+			expected = append(expected, syncPath(tmpl, f.Name))
+		}
+		// Listed files saved under another name, and what --extract unpacked,
+		// are kept while their URL is listed
+		urls := make([]string, len(listingFiles))
+		for i, f := range listingFiles {
+			urls[i] = f.URL
+		}
+		recorded, err := mirror.Recorded(s.dir, urls)
+		if err != nil {
+			return err
+		}
+		expected = append(expected, recorded...)
+		extras, err = mirror.Extraneous(s.dir, expected, recursive || maxDepth > 0 || tmpl != nil)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to scan %s: %w", s.dir, err)
+		}
+		fmt.Printf("Local: %d files no longer listed (%s)\n", len(extras), formatBytes(mirror.TotalSize(extras)))
+	}
+
+	if syncDryRun {
+		if len(missing) > 0 {
+			fmt.Println("\nTo download:")
+			for _, f := range missing {
+				fmt.Printf("  + %s (%s)\n", f.Name, formatBytes(f.Size))
+			}
+		}
+		if len(extras) > 0 {
+			fmt.Println("\nTo delete:")
+			for _, f := range extras {
+				fmt.Printf("  - %s (%s)\n", f.Path, formatBytes(f.Size))
+			}
+		}
+		if len(selected) > len(missing) {
+			fmt.Printf("\nThe other %d files are checked against the server when syncing\n", len(selected)-len(missing))
+		}
+		return nil
+	}
+
+	if len(selected) > 0 {
+		if err := downloadSources(ctx, sources); err != nil {
+			if len(extras) > 0 {
+				fmt.Printf("  ⚠ Not deleting %d files no longer listed because the sync didn't finish\n", len(extras))
+			}
+			return err
+		}
+	}
+
+	if len(extras) == 0 {
+		fmt.Printf("✓ %s is in sync with %s\n", s.dir, s.url)
+		return nil
+	}
+	if verbose || !syncYes {
+		fmt.Println()
+		for _, f := range extras {
+			fmt.Printf("  - %s (%s)\n", f.Path, formatBytes(f.Size))
+		}
+	}
+	if !syncYes && !confirm(fmt.Sprintf("Delete these %d files no longer listed?", len(extras))) {
+		fmt.Println("Kept them")
+		return nil
+	}
+	removed, err := mirror.Remove(s.dir, extras)
+	fmt.Printf("✓ Deleted %d files no longer listed\n", removed)
+	if err != nil {
+		return err
+	}
+	return refreshLatest(s.dir)
+}
+
+// syncPath is where a listed file is kept in the directory, slash-separated
+func syncPath(tmpl *layout.Template, name string) string {
+	name = filepath.ToSlash(name)
+	if tmpl != nil {
+		return tmpl.Path(name)
+	}
+	return name
+}
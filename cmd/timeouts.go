@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/units"
+)
+
+var (
+	connectTimeout time.Duration
+	stallTimeout   time.Duration
+	stallSpeedFlag string
+	stallSpeed     int64
+)
+
+// applyTimeouts checks the timeouts and parses --stall-speed
+func applyTimeouts() error {
+	if stallTimeout < 0 || connectTimeout < 0 {
+		return fmt.Errorf("timeouts can't be negative")
+	}
+	stallSpeed = 0
+	if stallSpeedFlag == "" {
+		return nil
+	}
+	speed, err := units.ParseRate(stallSpeedFlag)
+	if err != nil {
+		return fmt.Errorf("invalid --stall-speed: %w", err)
+	}
+	stallSpeed = speed
+	return nil
+}
+
+// stallTimeoutConfig turns --stall-timeout into downloader.Config.StallTimeout,
+// where 0 means the default and a negative value disables it
+func stallTimeoutConfig() time.Duration {
+	if stallTimeout == 0 {
+		return -1
+	}
+	return stallTimeout
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/downloader"
+	"github.com/nchapman/myrient-dl/internal/history"
+	"github.com/nchapman/myrient-dl/internal/usage"
+	"github.com/spf13/cobra"
+)
+
+var usageSince string
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show how much has been downloaded from each host",
+	Long: `Show bytes transferred per host over the last 7 and 30 days and in total.
+
+Every run appends its per-host traffic, including retries and failed attempts,
+to usage.jsonl in the user config directory. Use --verbose to list runs.`,
+	Args: cobra.NoArgs,
+	RunE: runUsage,
+}
+
+func init() {
+	usageCmd.Flags().StringVar(&usageSince, "since", "", "Only count traffic since a lookback (7d, 2w, 36h) or UTC date (2024-05-01)")
+
+	rootCmd.AddCommand(usageCmd)
+}
+
+func runUsage(_ *cobra.Command, _ []string) error {
+	now := time.Now()
+	windows := []usage.Window{
+		{Label: "7 days", Since: now.AddDate(0, 0, -7)},
+		{Label: "30 days", Since: now.AddDate(0, 0, -30)},
+		{Label: "total"},
+	}
+	if usageSince != "" {
+		since, err := history.ParseSince(usageSince, now)
+		if err != nil {
+			return err
+		}
+		windows = []usage.Window{{Label: "since " + since.Format("2006-01-02"), Since: since}}
+	}
+
+	path, err := usage.DefaultPath()
+	if err != nil {
+		return err
+	}
+	records, err := usage.Read(path)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		fmt.Println("No usage recorded")
+		return nil
+	}
+
+	fmt.Printf("%-32s", "Host")
+	for _, w := range windows {
+		fmt.Printf(" %14s", w.Label)
+	}
+	fmt.Println()
+	for _, h := range usage.Summarize(records, windows) {
+		fmt.Printf("%-32s", h.Host)
+		for _, total := range h.Totals {
+			fmt.Printf(" %14s", formatBytes(total))
+		}
+		fmt.Println()
+	}
+
+	if verbose {
+		fmt.Println("\nRuns:")
+		for _, r := range records {
+			if r.End.Before(windows[len(windows)-1].Since) {
+				continue
+			}
+			fmt.Printf("  %s %10s  %-24s %s\n", r.End.Local().Format("2006-01-02 15:04"), formatBytes(r.Bytes), r.Host, r.Source)
+		}
+	}
+
+	return nil
+}
+
+// usageMeter appends a run's per-host traffic to the usage log, in pieces when
+// checkpoints record it before the run ends
+type usageMeter struct {
+	dl     *downloader.Downloader
+	source string
+
+	mu     sync.Mutex
+	start  time.Time
+	logged map[string]int64 // Traffic already in the log
+}
+
+func newUsageMeter(dl *downloader.Downloader, source string) *usageMeter {
+	return &usageMeter{dl: dl, source: source, start: time.Now(), logged: make(map[string]int64)}
+}
+
+// record appends the traffic since the last record. It is safe for concurrent use.
+func (m *usageMeter) record() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	traffic := m.dl.Traffic()
+	delta := make(map[string]int64, len(traffic))
+	for host, bytes := range traffic {
+		delta[host] = bytes - m.logged[host]
+	}
+
+	path, err := usage.DefaultPath()
+	if err == nil {
+		err = usage.Append(path, usage.Records(m.start, now, m.source, delta))
+	}
+	if err != nil {
+		fmt.Printf("  ⚠ Failed to record usage: %v\n", err)
+		return // Try again with the next record
+	}
+	m.start, m.logged = now, traffic
+}
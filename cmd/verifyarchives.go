@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/nchapman/myrient-dl/internal/archive"
+	"github.com/nchapman/myrient-dl/internal/downloader"
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// verifyArchives is --verify-archives: test each downloaded zip and 7z before keeping it
+var verifyArchives bool
+
+// archiveChecker tests archives as they're downloaded, so a corrupt transfer
+// that matches the Content-Length is downloaded again instead of kept
+type archiveChecker struct {
+	mu          sync.Mutex
+	checked     int
+	unsupported int
+}
+
+func (c *archiveChecker) verifier() downloader.Verifier {
+	return downloader.VerifierFunc(func(file parser.FileInfo, path string) error {
+		checked, err := archive.Check(file.Name, path)
+		name := filepath.Base(file.Name)
+		if errors.Is(err, archive.ErrUnsupported) {
+			if verbose {
+				fmt.Printf("  ⚠ %s not fully tested: %v\n", name, err)
+			}
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.unsupported++
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s is not a valid archive: %v", downloader.ErrCorrupt, name, err)
+		}
+		if checked {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.checked++
+		}
+		return nil
+	})
+}
+
+func (c *archiveChecker) printSummary() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.checked > 0 {
+		fmt.Printf("  %s downloaded archives passed their integrity check\n", formatCount(c.checked))
+	}
+	if c.unsupported > 0 {
+		fmt.Printf("  ⚠ %d archives use a compression method that can't be tested\n", c.unsupported)
+	}
+}
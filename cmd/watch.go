@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/feed"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchInterval time.Duration
+	watchOnce     bool
+	watchFeed     string
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch [URL...]",
+	Short: "Poll listings and report files as they appear",
+	Long: `Check each listing for changes every --interval, like running "myrient-dl changes"
+on a timer. Nothing is downloaded.
+
+With --feed, newly added files are also published to an Atom feed file that
+feed readers (or a static web server) can pick up.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runWatch,
+}
+
+func init() {
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", time.Hour, "Time between checks")
+	watchCmd.Flags().BoolVar(&watchOnce, "once", false, "Check each listing once and exit (for cron)")
+	watchCmd.Flags().StringVar(&watchFeed, "feed", "", "Atom feed file to add newly appearing files to")
+	addListingFlags(watchCmd)
+
+	rootCmd.AddCommand(watchCmd)
+}
+
+func runWatch(_ *cobra.Command, args []string) error {
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	if watchInterval < time.Minute {
+		return fmt.Errorf("--interval must be at least 1m to be polite to the server")
+	}
+
+	for {
+		for _, listingURL := range args {
+			fmt.Printf("\n%s %s\n", time.Now().Format("2006-01-02 15:04"), listingURL)
+			if err := watchListing(ctx, listingURL); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				fmt.Printf("  ⚠ %v\n", err)
+			}
+		}
+
+		if watchOnce {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(watchInterval):
+		}
+	}
+}
+
+// watchListing checks one listing and adds its new files to the feed
+func watchListing(ctx context.Context, listingURL string) error {
+	previous, changes, err := checkListing(ctx, listingURL)
+	if err != nil || previous == nil || len(changes.Added) == 0 || watchFeed == "" {
+		return err
+	}
+
+	f, err := feed.Load(watchFeed)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(changes.Added))
+	for _, file := range changes.Added {
+		names = append(names, file.Name)
+	}
+	f.Add(listingURL, listingTitle(listingURL), names, time.Now())
+	return f.Save(watchFeed)
+}
+
+// listingTitle names a listing by the last component of its path, e.g. "Nintendo - NES"
+func listingTitle(listingURL string) string {
+	u, err := url.Parse(listingURL)
+	if err != nil {
+		return listingURL
+	}
+	title := path.Base(strings.TrimSuffix(u.Path, "/"))
+	if title == "/" || title == "." {
+		return u.Host
+	}
+	return title
+}
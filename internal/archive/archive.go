@@ -0,0 +1,385 @@
+// Package archive reads what zip archives hold from their central directory,
+// without decompressing or, for remote archives, downloading them, extracts
+// single members from just their compressed bytes, and tests and unpacks
+// downloaded archives.
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/cleanup"
+	"github.com/nchapman/myrient-dl/internal/fsutil"
+)
+
+// ErrChecksum means extracted data doesn't match the size or CRC32 its archive records
+var ErrChecksum = errors.New("checksum mismatch")
+
+// ErrUnsupported means a member uses a compression method that can't be read,
+// so it can't be tested
+var ErrUnsupported = errors.New("unsupported compression method")
+
+// tailSize is how much of the end of an archive is read up front. It holds the
+// end-of-central-directory record and, for all but the largest sets, the whole
+// central directory, so a remote archive costs one request.
+const tailSize = 64 << 10
+
+// IsZip reports whether a file name is a zip archive
+func IsZip(name string) bool {
+	return strings.EqualFold(path.Ext(name), ".zip")
+}
+
+// IsOther reports whether a file name is an archive format whose contents
+// can't be read, such as 7-Zip or RAR
+func IsOther(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".7z", ".rar":
+		return true
+	default:
+		return false
+	}
+}
+
+// Member is a file in a zip archive, as its central directory records it
+type Member struct {
+	Name       string
+	Size       int64 // Uncompressed
+	Compressed int64
+	CRC32      uint32
+	Modified   time.Time
+
+	file *zip.File
+}
+
+// List returns a zip archive's members, reading only its central directory
+func List(r io.ReaderAt, size int64) ([]Member, error) {
+	zr, err := zip.NewReader(newTailReader(r, size), size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zip directory: %w", err)
+	}
+	members := make([]Member, 0, len(zr.File))
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		members = append(members, Member{
+			Name:       f.Name,
+			Size:       int64(f.UncompressedSize64), //nolint:gosec // Member sizes fit in an int64
+			Compressed: int64(f.CompressedSize64),   //nolint:gosec // Member sizes fit in an int64
+			CRC32:      f.CRC32,
+			Modified:   f.Modified,
+			file:       f,
+		})
+	}
+	return members, nil
+}
+
+// Test decompresses every member of a zip archive, checking it against the
+// size and CRC32 the archive records, and returns how many members it read
+func Test(r io.ReaderAt, size int64) (int, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read zip directory: %w", err)
+	}
+	tested := 0
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if err := testMember(f); err != nil {
+			return tested, err
+		}
+		tested++
+	}
+	return tested, nil
+}
+
+func testMember(f *zip.File) error {
+	rc, err := f.Open()
+	if errors.Is(err, zip.ErrAlgorithm) {
+		return fmt.Errorf("%w: %s uses method %d", ErrUnsupported, f.Name, f.Method)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+	defer func() { _ = rc.Close() }()
+
+	if _, err := io.Copy(io.Discard, rc); err != nil {
+		if errors.Is(err, zip.ErrChecksum) {
+			return fmt.Errorf("%w: %s doesn't match its CRC32", ErrChecksum, f.Name)
+		}
+		return fmt.Errorf("failed to decompress %s: %w", f.Name, err)
+	}
+	return nil
+}
+
+// sevenZipSignature starts every 7z archive
+var sevenZipSignature = []byte{'7', 'z', 0xBC, 0xAF, 0x27, 0x1C}
+
+// Check7z checks a 7z archive's start header and the header at its end
+// against their CRC32s. The packed streams use compression this package can't
+// read, but a truncated or damaged transfer almost always breaks these.
+func Check7z(r io.ReaderAt, size int64) error {
+	var start [32]byte
+	if _, err := r.ReadAt(start[:], 0); err != nil {
+		return fmt.Errorf("not a 7z archive: %w", err)
+	}
+	if !bytes.Equal(start[:6], sevenZipSignature) {
+		return errors.New("not a 7z archive: missing signature")
+	}
+	if crc32.ChecksumIEEE(start[12:32]) != binary.LittleEndian.Uint32(start[8:12]) {
+		return fmt.Errorf("%w: 7z start header", ErrChecksum)
+	}
+
+	offset := binary.LittleEndian.Uint64(start[12:20])
+	length := binary.LittleEndian.Uint64(start[20:28])
+	if offset > uint64(size) || length > uint64(size) || 32+offset+length > uint64(size) { //nolint:gosec // size is a file size, never negative
+		return fmt.Errorf("7z archive is truncated: header ends at %d of %d bytes", 32+offset+length, size)
+	}
+	if length == 0 {
+		return nil // Empty archive
+	}
+	crc := crc32.NewIEEE()
+	header := io.NewSectionReader(r, int64(32+offset), int64(length)) //nolint:gosec // Bounded by size above
+	var kind [1]byte
+	if _, err := io.ReadFull(io.TeeReader(header, crc), kind[:]); err != nil {
+		return fmt.Errorf("failed to read 7z header: %w", err)
+	}
+	if kind[0] != 0x01 && kind[0] != 0x17 { // Header or EncodedHeader
+		return fmt.Errorf("%w: 7z header has unknown type %#x", ErrChecksum, kind[0])
+	}
+	if _, err := io.Copy(crc, header); err != nil {
+		return fmt.Errorf("failed to read 7z header: %w", err)
+	}
+	if crc.Sum32() != binary.LittleEndian.Uint32(start[28:32]) {
+		return fmt.Errorf("%w: 7z header", ErrChecksum)
+	}
+	return nil
+}
+
+// Check tests a downloaded archive at path, named name: every member of a
+// zip is decompressed and checked against its CRC32, and a 7z has its headers
+// checked with Check7z. It reports false for files that aren't zip or 7z
+// archives.
+func Check(name, path string) (bool, error) {
+	sevenZip := strings.EqualFold(filepath.Ext(name), ".7z")
+	if !IsZip(name) && !sevenZip {
+		return false, nil
+	}
+	f, err := os.Open(path) //nolint:gosec // Path is a download in the user's output directory
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	if sevenZip {
+		return true, Check7z(f, info.Size())
+	}
+	_, err = Test(f, info.Size())
+	return true, err
+}
+
+// DataOffset returns where the member's compressed data starts in the
+// archive. It reads the member's local header.
+func (m Member) DataOffset() (int64, error) {
+	offset, err := m.file.DataOffset()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read local header of %s: %w", m.Name, err)
+	}
+	return offset, nil
+}
+
+// Decompress returns the member's contents given its compressed data, such as
+// a Range request for Compressed bytes at DataOffset. The size and CRC32 are
+// checked as the end is reached.
+func (m Member) Decompress(compressed io.Reader) (io.ReadCloser, error) {
+	compressed = io.LimitReader(compressed, m.Compressed)
+	var rc io.ReadCloser
+	switch m.file.Method {
+	case zip.Store:
+		rc = io.NopCloser(compressed)
+	case zip.Deflate:
+		rc = flate.NewReader(compressed)
+	default:
+		return nil, fmt.Errorf("%s uses unsupported compression method %d", m.Name, m.file.Method)
+	}
+	return &checkedReader{ReadCloser: rc, member: m, crc: crc32.NewIEEE()}, nil
+}
+
+// checkedReader verifies a member's size and CRC32 at EOF
+type checkedReader struct {
+	io.ReadCloser
+	member Member
+	crc    hash.Hash32
+	n      int64
+}
+
+func (c *checkedReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	_, _ = c.crc.Write(p[:n])
+	if c.n > c.member.Size {
+		return n, fmt.Errorf("%w: %s is larger than the %d bytes recorded", ErrChecksum, c.member.Name, c.member.Size)
+	}
+	if err == io.EOF {
+		if c.n != c.member.Size {
+			return n, fmt.Errorf("%w: %s is %d bytes, expected %d", ErrChecksum, c.member.Name, c.n, c.member.Size)
+		}
+		if sum := c.crc.Sum32(); sum != c.member.CRC32 {
+			return n, fmt.Errorf("%w: %s has CRC32 %08x, expected %08x", ErrChecksum, c.member.Name, sum, c.member.CRC32)
+		}
+	}
+	return n, err
+}
+
+// LocalPath maps a member name onto a relative local path, sanitizing each
+// component so no name can escape the directory it's extracted into
+func LocalPath(name string) string {
+	var parts []string
+	for _, part := range strings.Split(strings.ReplaceAll(name, `\`, "/"), "/") {
+		if part = fsutil.SanitizeFilename(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return filepath.Join(parts...)
+}
+
+// Extract unpacks every member of a local zip archive into destDir, checking
+// each against its CRC32 as it's written, and returns the paths written,
+// relative to destDir, with their total size. Members already there at their
+// recorded size are left alone but still returned.
+func Extract(path, destDir string) ([]string, int64, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read zip directory: %w", err)
+	}
+	defer func() { _ = zr.Close() }()
+
+	var written []string
+	var total int64
+	for _, f := range zr.File {
+		rel := LocalPath(f.Name)
+		if f.FileInfo().IsDir() || rel == "" {
+			continue
+		}
+		dest := filepath.Join(destDir, rel)
+		size := int64(f.UncompressedSize64) //nolint:gosec // Member sizes fit in an int64
+		if info, err := os.Stat(dest); err != nil || !info.Mode().IsRegular() || info.Size() != size {
+			if err := extractFile(f, dest); err != nil {
+				return written, total, err
+			}
+		}
+		written = append(written, rel)
+		total += size
+	}
+	return written, total, nil
+}
+
+// extractFile writes a member to dest through a temp file, so a failed check
+// never leaves a partial file under the real name
+func extractFile(f *zip.File, dest string) error {
+	rc, err := f.Open()
+	if errors.Is(err, zip.ErrAlgorithm) {
+		return fmt.Errorf("%w: %s uses method %d", ErrUnsupported, f.Name, f.Method)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+	defer func() { _ = rc.Close() }()
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil { //nolint:gosec // Extracted files sit with the downloads
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	tempPath := dest + cleanup.TempSuffix
+	out, err := os.Create(tempPath) //nolint:gosec // Path is sanitized by LocalPath
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	if _, err := io.Copy(out, rc); err != nil {
+		_ = out.Close()
+		_ = os.Remove(tempPath)
+		if errors.Is(err, zip.ErrChecksum) {
+			return fmt.Errorf("%w: %s doesn't match its CRC32", ErrChecksum, f.Name)
+		}
+		return fmt.Errorf("failed to extract %s: %w", f.Name, err)
+	}
+	if err := out.Close(); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to write %s: %w", dest, err)
+	}
+	if err := os.Rename(tempPath, dest); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to move %s into place: %w", dest, err)
+	}
+	if !f.Modified.IsZero() {
+		_ = os.Chtimes(dest, f.Modified, f.Modified)
+	}
+	return nil
+}
+
+// ExtractedSize returns the total uncompressed size of a zip archive's members
+func ExtractedSize(r io.ReaderAt, size int64) (int64, error) {
+	members, err := List(r, size)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, m := range members {
+		total += m.Size
+	}
+	return total, nil
+}
+
+// tailReader serves reads near the end of a file from one up-front read of
+// its tail, leaving the rest to the underlying reader
+type tailReader struct {
+	r     io.ReaderAt
+	start int64 // Offset of the tail
+	tail  []byte
+	err   error
+	read  bool
+}
+
+func newTailReader(r io.ReaderAt, size int64) *tailReader {
+	return &tailReader{r: r, start: max(size-tailSize, 0), tail: make([]byte, min(size, tailSize))}
+}
+
+func (t *tailReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < t.start {
+		return t.r.ReadAt(p, off)
+	}
+	if !t.read {
+		t.read = true
+		n, err := t.r.ReadAt(t.tail, t.start)
+		if err != nil && !(err == io.EOF && n == len(t.tail)) {
+			t.err = err
+		}
+	}
+	if t.err != nil {
+		return 0, t.err
+	}
+	rel := off - t.start
+	if rel >= int64(len(t.tail)) {
+		return 0, io.EOF
+	}
+	n := copy(p, t.tail[rel:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
@@ -0,0 +1,372 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingReader counts the reads made of it
+type countingReader struct {
+	*bytes.Reader
+	reads int
+}
+
+func (c *countingReader) ReadAt(p []byte, off int64) (int, error) {
+	c.reads++
+	return c.Reader.ReadAt(p, off)
+}
+
+func makeZip(t *testing.T, members map[string]int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	rng := rand.New(rand.NewPCG(1, 2))
+	for name, size := range members {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(rng.IntN(4)) // Compressible, so the sizes differ
+		}
+		if _, err := f.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractedSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		members map[string]int
+		want    int64
+	}{
+		{"single ROM", map[string]int{"Game (USA).nes": 40976}, 40976},
+		{"disc set", map[string]int{"Game (Track 1).bin": 300000, "Game (Track 2).bin": 90000, "Game.cue": 120}, 390120},
+		{"empty", map[string]int{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := makeZip(t, tt.members)
+			r := &countingReader{Reader: bytes.NewReader(data)}
+			got, err := ExtractedSize(r, int64(len(data)))
+			if err != nil {
+				t.Fatalf("ExtractedSize failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %d bytes, got %d", tt.want, got)
+			}
+			if r.reads != 1 {
+				t.Errorf("expected the directory in one read, got %d", r.reads)
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	if _, err := w.Create("Game (Disc 1)/"); err != nil {
+		t.Fatal(err)
+	}
+	f, err := w.Create("Game (Disc 1)/Game.cue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	members, err := List(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(members) != 1 {
+		t.Fatalf("expected directories to be left out, got %+v", members)
+	}
+	m := members[0]
+	if m.Name != "Game (Disc 1)/Game.cue" || m.Size != 5 || m.CRC32 != 0x3610a686 || m.Compressed <= 0 {
+		t.Errorf("unexpected member %+v", m)
+	}
+}
+
+func TestMember_Decompress(t *testing.T) {
+	stored := []byte("CUE FILE")
+	deflated := bytes.Repeat([]byte("track data "), 500)
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, m := range []struct {
+		name   string
+		method uint16
+		data   []byte
+	}{{"Game.cue", zip.Store, stored}, {"Game (Track 1).bin", zip.Deflate, deflated}} {
+		f, err := w.CreateHeader(&zip.FileHeader{Name: m.name, Method: m.method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write(m.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	members, err := List(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range [][]byte{stored, deflated} {
+		m := members[i]
+		offset, err := m.DataOffset()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Only the member's compressed bytes are handed over, as a Range request would
+		rc, err := m.Decompress(bytes.NewReader(data[offset : offset+m.Compressed]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("%s: got %d bytes, %v", m.Name, len(got), err)
+		}
+
+		// Corrupt data fails the CRC check
+		corrupt := bytes.Clone(data[offset : offset+m.Compressed])
+		corrupt[len(corrupt)/2] ^= 0xff
+		rc, err = m.Decompress(bytes.NewReader(corrupt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadAll(rc); err == nil {
+			t.Errorf("%s: expected corrupt data to fail", m.Name)
+		} else if i == 0 && !errors.Is(err, ErrChecksum) { // Stored data has nothing else to fail on
+			t.Errorf("%s: expected ErrChecksum, got %v", m.Name, err)
+		}
+	}
+}
+
+func TestTest(t *testing.T) {
+	data := makeZip(t, map[string]int{"Game (Track 1).bin": 300000, "Game.cue": 120})
+	if n, err := Test(bytes.NewReader(data), int64(len(data))); err != nil || n != 2 {
+		t.Errorf("expected 2 members tested, got %d (%v)", n, err)
+	}
+
+	// Stored data, so a flipped byte reaches the CRC32 check
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, err := w.CreateHeader(&zip.FileHeader{Name: "Game.nes", Method: zip.Store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.Write(bytes.Repeat([]byte("NES\x1a"), 1000))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	corrupt := buf.Bytes()
+	corrupt[100] ^= 0xff
+	if _, err := Test(bytes.NewReader(corrupt), int64(len(corrupt))); !errors.Is(err, ErrChecksum) {
+		t.Errorf("expected ErrChecksum, got %v", err)
+	}
+
+	buf.Reset()
+	w = zip.NewWriter(&buf)
+	w.RegisterCompressor(99, func(out io.Writer) (io.WriteCloser, error) { return nopWriteCloser{out}, nil })
+	if f, err = w.CreateHeader(&zip.FileHeader{Name: "Game.nes", Method: 99}); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.Write([]byte("data"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Test(bytes.NewReader(buf.Bytes()), int64(buf.Len())); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+
+	if _, err := Test(bytes.NewReader([]byte("not a zip")), 9); err == nil || errors.Is(err, ErrChecksum) {
+		t.Errorf("expected a directory error, got %v", err)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "Game (USA).zip")
+	data := makeZip(t, map[string]int{"Game (Track 1).bin": 3000, "Game.cue": 120, "../escape.txt": 10})
+	if err := os.WriteFile(zipPath, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(dir, "out")
+	paths, total, err := Extract(zipPath, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 3 || total != 3130 {
+		t.Errorf("expected 3 files of 3130 bytes, got %v (%d)", paths, total)
+	}
+	for _, rel := range paths {
+		if strings.Contains(rel, "..") {
+			t.Errorf("expected %q to stay inside the destination", rel)
+		}
+	}
+	info, err := os.Stat(filepath.Join(dest, "Game (Track 1).bin"))
+	if err != nil || info.Size() != 3000 {
+		t.Fatalf("expected the extracted track, got %v", err)
+	}
+
+	// Already extracted members are kept as they are
+	if err := os.Chtimes(filepath.Join(dest, "Game.cue"), time.Unix(1, 0), time.Unix(1, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if paths, _, err = Extract(zipPath, dest); err != nil || len(paths) != 3 {
+		t.Fatalf("expected a second extraction to succeed, got %v (%v)", paths, err)
+	}
+	if info, _ := os.Stat(filepath.Join(dest, "Game.cue")); info.ModTime().Unix() != 1 {
+		t.Error("expected an existing member to be left alone")
+	}
+
+	if _, _, err := Extract(filepath.Join(dir, "missing.zip"), dest); err == nil {
+		t.Error("expected an error for a missing archive")
+	}
+}
+
+// make7z builds the smallest 7z archive: packed data followed by a plain
+// header, with both CRCs filled in
+func make7z(packed, header []byte) []byte {
+	start := make([]byte, 32)
+	copy(start, sevenZipSignature)
+	start[6], start[7] = 0, 4
+	binary.LittleEndian.PutUint64(start[12:], uint64(len(packed)))
+	binary.LittleEndian.PutUint64(start[20:], uint64(len(header)))
+	binary.LittleEndian.PutUint32(start[28:], crc32.ChecksumIEEE(header))
+	binary.LittleEndian.PutUint32(start[8:], crc32.ChecksumIEEE(start[12:32]))
+	return append(append(start, packed...), header...)
+}
+
+func TestCheck7z(t *testing.T) {
+	good := make7z(bytes.Repeat([]byte{0x5d}, 500), []byte{0x01, 0x04, 0x06, 0x00, 0x00})
+
+	damagedHeader := bytes.Clone(good)
+	damagedHeader[len(damagedHeader)-2] ^= 0xff
+	damagedStart := bytes.Clone(good)
+	damagedStart[14] ^= 0xff
+
+	tests := []struct {
+		name     string
+		data     []byte
+		wantErr  bool
+		checksum bool
+	}{
+		{"good", good, false, false},
+		{"empty archive", make7z(nil, nil), false, false},
+		{"truncated", good[:len(good)-3], true, false},
+		{"damaged header", damagedHeader, true, true},
+		{"damaged start header", damagedStart, true, true},
+		{"not 7z", []byte("PK\x03\x04 and more than thirty-two bytes of data"), true, false},
+		{"too short", []byte("7z"), true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check7z(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check7z() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.checksum && !errors.Is(err, ErrChecksum) {
+				t.Errorf("expected ErrChecksum, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	zipData := makeZip(t, map[string]int{"Game.nes": 4000})
+
+	// Downloads are checked under their temp name
+	if checked, err := Check("Game.zip", write("Game.zip.tmp", zipData)); !checked || err != nil {
+		t.Errorf("expected a checked zip, got %v, %v", checked, err)
+	}
+	if checked, err := Check("Game.ZIP", write("trunc.tmp", zipData[:len(zipData)-10])); !checked || err == nil {
+		t.Errorf("expected a truncated zip to fail, got %v, %v", checked, err)
+	}
+	if checked, err := Check("Game.7z", write("Game.7z.tmp", make7z([]byte("data"), []byte{0x01, 0x00}))); !checked || err != nil {
+		t.Errorf("expected a checked 7z, got %v, %v", checked, err)
+	}
+	if checked, err := Check("Game.nes", write("Game.nes", []byte("rom"))); checked || err != nil {
+		t.Errorf("expected other files to be left unchecked, got %v, %v", checked, err)
+	}
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestLocalPath(t *testing.T) {
+	tests := map[string]string{
+		"Game (Track 1).bin":   "Game (Track 1).bin",
+		"Disc 1/Game.cue":      filepath.Join("Disc 1", "Game.cue"),
+		"../../etc/passwd":     filepath.Join("etc", "passwd"),
+		"/abs/Game.bin":        filepath.Join("abs", "Game.bin"),
+		`Disc 2\Game.bin`:      filepath.Join("Disc 2", "Game.bin"),
+		"./.hidden/./file.txt": filepath.Join("hidden", "file.txt"),
+	}
+	for name, want := range tests {
+		if got := LocalPath(name); got != want {
+			t.Errorf("LocalPath(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestExtractedSize_NotZip(t *testing.T) {
+	data := []byte("<html>not found</html>")
+	if _, err := ExtractedSize(bytes.NewReader(data), int64(len(data))); err == nil {
+		t.Error("expected an error for a file that isn't a zip")
+	}
+}
+
+func TestIsZip(t *testing.T) {
+	tests := map[string][2]bool{
+		"Game (USA).zip":     {true, false},
+		"Game (USA).ZIP":     {true, false},
+		"Game (Japan).7z":    {false, true},
+		"Game (Europe).rar":  {false, true},
+		"Game (Europe).chd":  {false, false},
+		"Extras/readme.txt":  {false, false},
+		"Disc 1/Game.7Z.zip": {true, false},
+	}
+	for name, want := range tests {
+		if got := [2]bool{IsZip(name), IsOther(name)}; got != want {
+			t.Errorf("%s: IsZip, IsOther = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+// Package auth adds credentials to requests for private mirrors, refreshing
+// expiring tokens so long jobs can run unattended.
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider adds credentials to outgoing requests
+type Provider interface {
+	// Apply sets the credentials on a request, fetching them first if needed
+	Apply(req *http.Request) error
+	// Refresh discards the current credentials after the server rejected them
+	Refresh(ctx context.Context) error
+}
+
+// Parse builds a provider from a spec as accepted on the command line:
+//
+//	header:NAME: VALUE   send a fixed header
+//	bearer-cmd:COMMAND   send "Authorization: Bearer <output of COMMAND>"
+//	exec:COMMAND         run COMMAND for an OAuth-style JSON token response
+func Parse(spec string) (Provider, error) {
+	kind, rest, ok := strings.Cut(spec, ":")
+	rest = strings.TrimSpace(rest)
+	if !ok || rest == "" {
+		return nil, fmt.Errorf("invalid auth %q (expected header:NAME: VALUE, bearer-cmd:COMMAND, or exec:COMMAND)", spec)
+	}
+
+	switch kind {
+	case "header":
+		name, value, ok := strings.Cut(rest, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid auth header %q (expected NAME: VALUE)", rest)
+		}
+		return Static{Name: strings.TrimSpace(name), Value: strings.TrimSpace(value)}, nil
+	case "bearer-cmd":
+		return &Command{Command: rest}, nil
+	case "exec":
+		return &Command{Command: rest, JSON: true}, nil
+	default:
+		return nil, fmt.Errorf("unknown auth provider %q (expected header, bearer-cmd, or exec)", kind)
+	}
+}
+
+// Static sends the same header with every request
+type Static struct {
+	Name  string
+	Value string
+}
+
+// Apply sets the header
+func (s Static) Apply(req *http.Request) error {
+	req.Header.Set(s.Name, s.Value)
+	return nil
+}
+
+// Refresh fails because a fixed header can't be renewed
+func (s Static) Refresh(context.Context) error {
+	return fmt.Errorf("the server rejected the %s header, and fixed credentials can't be refreshed", s.Name)
+}
+
+// refreshMargin is how long before expiry a token is renewed
+const refreshMargin = time.Minute
+
+// Command runs a shell command for a token and sends it as a bearer token. The
+// token is kept until the server rejects it or, for JSON output, shortly
+// before it expires. It is safe for concurrent use.
+type Command struct {
+	Command string
+	// JSON parses the output as an OAuth token response with access_token,
+	// token_type, and expires_in fields instead of a bare token
+	JSON bool
+
+	mu      sync.Mutex
+	header  string
+	expires time.Time // Zero when the token doesn't expire
+	now     func() time.Time
+}
+
+// tokenResponse is the subset of an OAuth 2.0 token response that is used
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"` // Seconds
+}
+
+// Apply sets the Authorization header, running the command when there is no
+// valid token
+func (c *Command) Apply(req *http.Request) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.header == "" || (!c.expires.IsZero() && c.clock().After(c.expires.Add(-refreshMargin))) {
+		if err := c.fetch(req.Context()); err != nil {
+			return err
+		}
+	}
+	req.Header.Set("Authorization", c.header)
+	return nil
+}
+
+// Refresh forgets the token so the next request runs the command again
+func (c *Command) Refresh(context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.header = ""
+	return nil
+}
+
+// fetch runs the command and stores its token. Callers must hold c.mu.
+func (c *Command) fetch(ctx context.Context) error {
+	out, err := run(ctx, c.Command)
+	if err != nil {
+		return err
+	}
+
+	if !c.JSON {
+		token := strings.TrimSpace(string(out))
+		if token == "" {
+			return fmt.Errorf("auth command printed no token")
+		}
+		c.header, c.expires = "Bearer "+token, time.Time{}
+		return nil
+	}
+
+	var t tokenResponse
+	if err := json.Unmarshal(out, &t); err != nil {
+		return fmt.Errorf("failed to parse auth command output: %w", err)
+	}
+	if t.AccessToken == "" {
+		return fmt.Errorf("auth command output has no access_token")
+	}
+	scheme := "Bearer"
+	if t.TokenType != "" && !strings.EqualFold(t.TokenType, "bearer") {
+		scheme = t.TokenType
+	}
+	c.header = scheme + " " + t.AccessToken
+	c.expires = time.Time{}
+	if t.ExpiresIn > 0 {
+		c.expires = c.clock().Add(time.Duration(t.ExpiresIn) * time.Second)
+	}
+	return nil
+}
+
+func (c *Command) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+// run runs a command line through the shell and returns its standard output
+func run(ctx context.Context, command string) ([]byte, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command) //nolint:gosec // The command is configured by the user
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command) //nolint:gosec // The command is configured by the user
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("auth command failed: %w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("auth command failed: %w", err)
+	}
+	return out, nil
+}
+
+// Scoped sends a provider's credentials only to trusted hosts, so they don't
+// follow learned redirects, mirrors, or links to other servers. It is safe for
+// concurrent use.
+type Scoped struct {
+	Provider Provider
+
+	mu    sync.RWMutex
+	hosts map[string]bool
+}
+
+// NewScoped returns p limited to the hosts of the given URLs
+func NewScoped(p Provider, urls ...string) *Scoped {
+	s := &Scoped{Provider: p, hosts: make(map[string]bool)}
+	for _, u := range urls {
+		s.Trust(u)
+	}
+	return s
+}
+
+// Trust lets the credentials go to the host of rawURL
+func (s *Scoped) Trust(rawURL string) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return
+	}
+	s.mu.Lock()
+	s.hosts[strings.ToLower(u.Host)] = true
+	s.mu.Unlock()
+}
+
+// Trusts reports whether credentials may be sent to host
+func (s *Scoped) Trusts(host string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.hosts[strings.ToLower(host)]
+}
+
+// Apply sets the credentials on requests for trusted hosts only
+func (s *Scoped) Apply(req *http.Request) error {
+	if !s.Trusts(req.URL.Host) {
+		return nil
+	}
+	return s.Provider.Apply(req)
+}
+
+// Refresh refreshes the underlying provider
+func (s *Scoped) Refresh(ctx context.Context) error {
+	return s.Provider.Refresh(ctx)
+}
+
+// header returns the header a provider sets
+func header(p Provider) string {
+	switch p := p.(type) {
+	case Static:
+		return p.Name
+	case *Scoped:
+		return header(p.Provider)
+	default:
+		return "Authorization"
+	}
+}
+
+// maxRedirects matches net/http's default redirect limit
+const maxRedirects = 10
+
+// scopedClient returns a copy of client that drops s's header when a
+// redirect leaves the trusted hosts; net/http only does that for
+// Authorization and only across domains
+func scopedClient(client *http.Client, s *Scoped) *http.Client {
+	c := *client
+	check := client.CheckRedirect
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if !s.Trusts(req.URL.Host) {
+			req.Header.Del(header(s))
+		}
+		if check != nil {
+			return check(req, via)
+		}
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		return nil
+	}
+	return &c
+}
+
+// Do sends a request with the provider's credentials. If the server answers
+// 401, the credentials are refreshed and the request is sent once more. A nil
+// provider sends the request as is, and a Scoped one sends untrusted hosts
+// nothing.
+func Do(client *http.Client, req *http.Request, p Provider) (*http.Response, error) {
+	if p == nil {
+		return client.Do(req)
+	}
+	if s, ok := p.(*Scoped); ok {
+		if !s.Trusts(req.URL.Host) {
+			return client.Do(req)
+		}
+		client = scopedClient(client, s)
+	}
+	if err := p.Apply(req); err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	_ = resp.Body.Close()
+	if err := p.Refresh(req.Context()); err != nil {
+		return nil, err
+	}
+	retry := req.Clone(req.Context())
+	if err := p.Apply(retry); err != nil {
+		return nil, err
+	}
+	return client.Do(retry)
+}
@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Provider
+		wantErr bool
+	}{
+		{"header:X-Api-Key: secret", Static{Name: "X-Api-Key", Value: "secret"}, false},
+		{"header:Authorization: Basic dXNlcjpwYXNz", Static{Name: "Authorization", Value: "Basic dXNlcjpwYXNz"}, false},
+		{"bearer-cmd:pass show mirror", &Command{Command: "pass show mirror"}, false},
+		{"exec: ./refresh-token.sh", &Command{Command: "./refresh-token.sh", JSON: true}, false},
+		{"header:novalue", nil, true},
+		{"bearer-cmd:", nil, true},
+		{"cookie:a=b", nil, true},
+		{"secret", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := Parse(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if fmt.Sprintf("%+v", got) != fmt.Sprintf("%+v", tt.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+// counterCommand returns a shell command printing a new token each run
+func counterCommand(t *testing.T, format string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	counter := filepath.Join(t.TempDir(), "n")
+	return fmt.Sprintf(`n=$(cat %[1]q 2>/dev/null || echo 0); n=$((n+1)); echo $n > %[1]q; printf '%[2]s' "$n"`, counter, format)
+}
+
+func authorization(t *testing.T, p Provider) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "http://mirror.example/", nil)
+	if err := p.Apply(req); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	return req.Header.Get("Authorization")
+}
+
+func TestCommand_Bearer(t *testing.T) {
+	c := &Command{Command: counterCommand(t, `tok%s\n`)}
+	if got := authorization(t, c); got != "Bearer tok1" {
+		t.Fatalf("expected Bearer tok1, got %q", got)
+	}
+	if got := authorization(t, c); got != "Bearer tok1" {
+		t.Errorf("expected the token to be reused, got %q", got)
+	}
+	if err := c.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := authorization(t, c); got != "Bearer tok2" {
+		t.Errorf("expected a new token after Refresh, got %q", got)
+	}
+}
+
+func TestCommand_JSON(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	c := &Command{
+		Command: counterCommand(t, `{"access_token": "tok%s", "token_type": "bearer", "expires_in": 600}`),
+		JSON:    true,
+		now:     func() time.Time { return now },
+	}
+	if got := authorization(t, c); got != "Bearer tok1" {
+		t.Fatalf("expected Bearer tok1, got %q", got)
+	}
+	now = now.Add(8 * time.Minute)
+	if got := authorization(t, c); got != "Bearer tok1" {
+		t.Errorf("expected the token to last until near expiry, got %q", got)
+	}
+	now = now.Add(time.Minute + time.Second) // Inside the refresh margin
+	if got := authorization(t, c); got != "Bearer tok2" {
+		t.Errorf("expected the token to be renewed before expiry, got %q", got)
+	}
+}
+
+func TestCommand_Errors(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	tests := []struct {
+		name string
+		cmd  *Command
+	}{
+		{"failing command", &Command{Command: "echo nope >&2; exit 3"}},
+		{"no token", &Command{Command: "true"}},
+		{"bad JSON", &Command{Command: "echo token", JSON: true}},
+		{"no access token", &Command{Command: `echo '{"expires_in": 60}'`, JSON: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://mirror.example/", nil)
+			if err := tt.cmd.Apply(req); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestDo(t *testing.T) {
+	// The server accepts only the second token, like one whose first token expired
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") != "Bearer tok2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	c := &Command{Command: counterCommand(t, `tok%s`)}
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := Do(server.Client(), req, c)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(requests) != 2 {
+		t.Errorf("expected one refresh and retry, got status %d after %v", resp.StatusCode, requests)
+	}
+
+	// Fixed credentials can't be refreshed, so a 401 is an error
+	req, err = http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Do(server.Client(), req, Static{Name: "Authorization", Value: "Bearer wrong"}); err == nil {
+		t.Error("expected an error for rejected fixed credentials")
+	}
+
+	// Without a provider the request is sent as is
+	req, err = http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = Do(server.Client(), req, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401 without credentials, got %d", resp.StatusCode)
+	}
+}
+
+func TestScoped(t *testing.T) {
+	var cdnKey string
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cdnKey = r.Header.Get("X-Api-Key")
+	}))
+	defer cdn.Close()
+	var originKey string
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		originKey = r.Header.Get("X-Api-Key")
+		http.Redirect(w, r, cdn.URL+r.URL.Path, http.StatusFound)
+	}))
+	defer origin.Close()
+
+	p := NewScoped(Static{Name: "X-Api-Key", Value: "secret"}, origin.URL+"/files/")
+
+	// A redirect to another host drops the header
+	req, _ := http.NewRequest(http.MethodGet, origin.URL+"/a.zip", nil)
+	resp, err := Do(http.DefaultClient, req, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if originKey != "secret" || cdnKey != "" {
+		t.Errorf("expected the key only at the origin, got origin %q, cdn %q", originKey, cdnKey)
+	}
+
+	// Requests straight to an untrusted host get nothing
+	req, _ = http.NewRequest(http.MethodGet, cdn.URL+"/b.zip", nil)
+	resp, err = Do(http.DefaultClient, req, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if cdnKey != "" || req.Header.Get("X-Api-Key") != "" {
+		t.Errorf("expected no key for an untrusted host, got %q", cdnKey)
+	}
+
+	p.Trust(cdn.URL)
+	if !p.Trusts(strings.TrimPrefix(cdn.URL, "http://")) {
+		t.Error("expected a trusted host after Trust")
+	}
+}
@@ -0,0 +1,181 @@
+// Package blocklist excludes files by name or hash, e.g. known bad dumps or items
+// already archived elsewhere.
+package blocklist
+
+import (
+	"archive/zip"
+	"bufio"
+	"crypto/md5"  //nolint:gosec // MD5 is only used to match published dump hashes
+	"crypto/sha1" //nolint:gosec // SHA-1 is only used to match published dump hashes
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// Hash lengths in hex digits, which identify the algorithm of a blocklisted hash
+const (
+	crcLen    = 8
+	md5Len    = 32
+	sha1Len   = 40
+	sha256Len = 64
+)
+
+// List is a set of blocked names and hashes
+type List struct {
+	names  []string        // Lowercase glob patterns
+	hashes map[string]bool // Lowercase hex digests
+}
+
+// Load reads a blocklist file. Each line is a CRC32, MD5, SHA-1, or SHA-256 hash
+// (optionally followed by a name, as in sha1sum output) or a filename glob.
+// Blank lines and lines starting with # are ignored.
+func Load(path string) (*List, error) {
+	f, err := os.Open(path) //nolint:gosec // Blocklist path is provided by the user
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blocklist: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	return Parse(f)
+}
+
+// Parse reads a blocklist in the format described by Load
+func Parse(r io.Reader) (*List, error) {
+	l := &List{hashes: make(map[string]bool)}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if fields := strings.Fields(line); isHash(fields[0]) {
+			l.hashes[strings.ToLower(fields[0])] = true
+			continue
+		}
+
+		pattern := strings.ToLower(line)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid blocklist pattern %q on line %d: %w", line, n, err)
+		}
+		l.names = append(l.names, pattern)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read blocklist: %w", err)
+	}
+	return l, nil
+}
+
+// isHash reports whether s looks like a hex digest of a supported algorithm
+func isHash(s string) bool {
+	switch len(s) {
+	case crcLen, md5Len, sha1Len, sha256Len:
+	default:
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// Len returns the number of blocked names and hashes
+func (l *List) Len() int {
+	return len(l.names) + len(l.hashes)
+}
+
+// MatchName reports whether a filename is blocked, case-insensitively
+func (l *List) MatchName(name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range l.names {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchHash reports whether any of the hex digests is blocked
+func (l *List) MatchHash(digests ...string) (string, bool) {
+	for _, d := range digests {
+		if d != "" && l.hashes[strings.ToLower(d)] {
+			return strings.ToLower(d), true
+		}
+	}
+	return "", false
+}
+
+// CheckFile hashes a downloaded file with every algorithm the blocklist uses and
+// reports the first blocked digest. Members of zip archives are also checked by
+// the CRC32 recorded in the archive, which needs no decompression.
+func (l *List) CheckFile(filePath string) (string, bool, error) {
+	if len(l.hashes) == 0 {
+		return "", false, nil
+	}
+
+	hashers := make(map[int]hash.Hash)
+	for digest := range l.hashes {
+		if _, ok := hashers[len(digest)]; ok {
+			continue
+		}
+		switch len(digest) {
+		case crcLen:
+			hashers[crcLen] = crc32.NewIEEE()
+		case md5Len:
+			hashers[md5Len] = md5.New() //nolint:gosec // See import
+		case sha1Len:
+			hashers[sha1Len] = sha1.New() //nolint:gosec // See import
+		case sha256Len:
+			hashers[sha256Len] = sha256.New()
+		}
+	}
+
+	f, err := os.Open(filePath) //nolint:gosec // Path is a file we just downloaded
+	if err != nil {
+		return "", false, fmt.Errorf("failed to open %s: %w", filePath, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	writers := make([]io.Writer, 0, len(hashers))
+	for _, h := range hashers {
+		writers = append(writers, h)
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), f); err != nil {
+		return "", false, fmt.Errorf("failed to hash %s: %w", filePath, err)
+	}
+
+	digests := make([]string, 0, len(hashers))
+	for _, h := range hashers {
+		digests = append(digests, hex.EncodeToString(h.Sum(nil)))
+	}
+	if d, ok := l.MatchHash(digests...); ok {
+		return d, true, nil
+	}
+
+	if _, ok := hashers[crcLen]; ok {
+		if d, ok := l.matchZipMembers(filePath); ok {
+			return d, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// matchZipMembers checks the CRC32 of each member if the file is a zip archive
+func (l *List) matchZipMembers(filePath string) (string, bool) {
+	r, err := zip.OpenReader(filePath)
+	if err != nil {
+		return "", false // Not a zip archive
+	}
+	defer func() { _ = r.Close() }()
+
+	for _, member := range r.File {
+		if d, ok := l.MatchHash(fmt.Sprintf("%08x", member.CRC32)); ok {
+			return d, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,100 @@
+package blocklist
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const list = `# Known bad dumps
+Bad Game (USA).zip
+*(Beta)*
+
+da39a3ee5e6b4b0d3255bfef95601890afd80709  empty.bin
+0CC175B9C0F1B6A831C399E269772661
+352441c2
+`
+
+func TestParse(t *testing.T) {
+	l, err := Parse(strings.NewReader(list))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if l.Len() != 5 {
+		t.Errorf("expected 5 entries, got %d", l.Len())
+	}
+
+	names := map[string]bool{
+		"Bad Game (USA).zip":      true,
+		"bad game (usa).ZIP":      true,
+		"Other (Japan) (Beta).7z": true,
+		"Good Game (USA).zip":     false,
+	}
+	for name, want := range names {
+		if got := l.MatchName(name); got != want {
+			t.Errorf("MatchName(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	if d, ok := l.MatchHash("", "0cc175b9c0f1b6a831c399e269772661"); !ok || d != "0cc175b9c0f1b6a831c399e269772661" {
+		t.Errorf("expected MD5 match, got %q %v", d, ok)
+	}
+	if _, ok := l.MatchHash("ffffffff"); ok {
+		t.Error("unexpected match")
+	}
+
+	if _, err := Parse(strings.NewReader("# comment\n\n[bad")); err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("expected an error naming line 3 for invalid pattern, got %v", err)
+	}
+}
+
+func TestCheckFile(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Parse(strings.NewReader(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// MD5 of "a"
+	blocked := filepath.Join(dir, "a.bin")
+	if err := os.WriteFile(blocked, []byte("a"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if d, ok, err := l.CheckFile(blocked); err != nil || !ok || d != "0cc175b9c0f1b6a831c399e269772661" {
+		t.Errorf("expected whole-file match, got %q %v %v", d, ok, err)
+	}
+
+	fine := filepath.Join(dir, "b.bin")
+	if err := os.WriteFile(fine, []byte("b"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := l.CheckFile(fine); err != nil || ok {
+		t.Errorf("expected no match, got %v %v", ok, err)
+	}
+
+	// A zip whose member has the blocklisted CRC32 of "abc"
+	archive := filepath.Join(dir, "game.zip")
+	out, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(out)
+	w, err := zw.Create("game.rom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write([]byte("abc"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_ = out.Close()
+	if d, ok, err := l.CheckFile(archive); err != nil || !ok || d != "352441c2" {
+		t.Errorf("expected zip member match, got %q %v %v", d, ok, err)
+	}
+
+	if _, _, err := l.CheckFile(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
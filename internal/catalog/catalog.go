@@ -0,0 +1,90 @@
+// Package catalog knows Myrient's collection layout and the systems it hosts.
+package catalog
+
+import (
+	"net/url"
+	"strings"
+)
+
+// System identifies a collection and system inferred from a Myrient URL
+type System struct {
+	Collection string // e.g. "No-Intro" or "Redump"
+	Name       string // e.g. "Nintendo - Game Boy Advance"
+
+	root *url.URL // URL of the /files/ root the system was found under
+}
+
+// BIOSSource describes where a system's BIOS files live on Myrient.
+// An empty URL means the BIOS files sit in the same listing as the games.
+type BIOSSource struct {
+	URL      string
+	Patterns []string
+}
+
+// redumpBIOS maps Redump systems to their sibling BIOS image directories
+var redumpBIOS = map[string]string{
+	"Microsoft - Xbox":     "Microsoft - Xbox - BIOS Images",
+	"Nintendo - GameCube":  "Nintendo - GameCube - BIOS Images",
+	"Sony - PlayStation":   "Sony - PlayStation - BIOS Images",
+	"Sony - PlayStation 2": "Sony - PlayStation 2 - BIOS Images",
+}
+
+// Detect infers the collection and system from a Myrient listing URL of the form
+// https://myrient.erista.me/files/<Collection>/<System>/...
+func Detect(rawURL string) (System, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return System{}, false
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i, seg := range segments {
+		if seg != "files" || i+1 >= len(segments) {
+			continue
+		}
+
+		sys := System{
+			Collection: segments[i+1],
+			root:       &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/" + strings.Join(segments[:i+1], "/") + "/"},
+		}
+		if i+2 < len(segments) {
+			sys.Name = segments[i+2]
+		}
+		return sys, true
+	}
+
+	return System{}, false
+}
+
+// String returns a human-readable "Collection / System" label
+func (s System) String() string {
+	if s.Name == "" {
+		return s.Collection
+	}
+	return s.Collection + " / " + s.Name
+}
+
+// BIOSSources returns the known BIOS locations for the system
+func (s System) BIOSSources() []BIOSSource {
+	switch s.Collection {
+	case "No-Intro":
+		// No-Intro ships BIOS dumps inside each system's directory with a [BIOS] prefix
+		// ("[[]" matches a literal bracket without relying on backslash escapes)
+		return []BIOSSource{{Patterns: []string{"[[]BIOS]*"}}}
+	case "Redump":
+		dir, ok := redumpBIOS[s.Name]
+		if !ok {
+			return nil
+		}
+		return []BIOSSource{{URL: s.dirURL(s.Collection, dir), Patterns: []string{"*"}}}
+	default:
+		return nil
+	}
+}
+
+// dirURL builds the listing URL for a directory below the /files/ root
+func (s System) dirURL(parts ...string) string {
+	u := *s.root
+	u.Path += strings.Join(parts, "/") + "/"
+	return u.String()
+}
@@ -0,0 +1,78 @@
+package catalog
+
+import (
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		url        string
+		found      bool
+		collection string
+		system     string
+	}{
+		{"https://myrient.erista.me/files/No-Intro/Nintendo%20-%20Game%20Boy%20Advance/", true, "No-Intro", "Nintendo - Game Boy Advance"},
+		{"https://myrient.erista.me/files/Redump/Sony%20-%20PlayStation/", true, "Redump", "Sony - PlayStation"},
+		{"https://myrient.erista.me/files/Redump/", true, "Redump", ""},
+		{"https://myrient.erista.me/files/", false, "", ""},
+		{"https://example.com/roms/", false, "", ""},
+		{"://bad", false, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			sys, ok := Detect(tt.url)
+			if ok != tt.found {
+				t.Fatalf("expected found=%v, got %v", tt.found, ok)
+			}
+			if sys.Collection != tt.collection {
+				t.Errorf("expected collection %q, got %q", tt.collection, sys.Collection)
+			}
+			if sys.Name != tt.system {
+				t.Errorf("expected system %q, got %q", tt.system, sys.Name)
+			}
+		})
+	}
+}
+
+func TestSystem_String(t *testing.T) {
+	sys := System{Collection: "No-Intro", Name: "Nintendo - Game Boy"}
+	if got := sys.String(); got != "No-Intro / Nintendo - Game Boy" {
+		t.Errorf("unexpected label %q", got)
+	}
+	if got := (System{Collection: "Redump"}).String(); got != "Redump" {
+		t.Errorf("unexpected label %q", got)
+	}
+}
+
+func TestSystem_BIOSSources(t *testing.T) {
+	t.Run("no-intro uses same listing", func(t *testing.T) {
+		sys, _ := Detect("https://myrient.erista.me/files/No-Intro/Nintendo%20-%20Game%20Boy%20Advance/")
+		sources := sys.BIOSSources()
+		if len(sources) != 1 || sources[0].URL != "" {
+			t.Fatalf("expected a same-listing source, got %+v", sources)
+		}
+		if sources[0].Patterns[0] != "[[]BIOS]*" {
+			t.Errorf("unexpected pattern %q", sources[0].Patterns[0])
+		}
+	})
+
+	t.Run("redump uses sibling directory", func(t *testing.T) {
+		sys, _ := Detect("https://myrient.erista.me/files/Redump/Sony%20-%20PlayStation%202/")
+		sources := sys.BIOSSources()
+		if len(sources) != 1 {
+			t.Fatalf("expected one source, got %+v", sources)
+		}
+		expected := "https://myrient.erista.me/files/Redump/Sony%20-%20PlayStation%202%20-%20BIOS%20Images/"
+		if sources[0].URL != expected {
+			t.Errorf("expected URL %q, got %q", expected, sources[0].URL)
+		}
+	})
+
+	t.Run("unknown system has no sources", func(t *testing.T) {
+		sys, _ := Detect("https://myrient.erista.me/files/Redump/Panasonic%20-%203DO/")
+		if sources := sys.BIOSSources(); len(sources) != 0 {
+			t.Errorf("expected no sources, got %+v", sources)
+		}
+	})
+}
@@ -0,0 +1,241 @@
+// Package checkpoint splits long runs into batches of finished files. Each
+// batch gets its own log in the state directory, and an interim summary is
+// rewritten whenever a batch closes, so a crash loses at most one batch of
+// bookkeeping.
+package checkpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/cleanup"
+)
+
+// Dir is where batch logs are kept inside the state directory, one
+// subdirectory per run
+const Dir = "batches"
+
+// SummaryFile is the interim summary of a run, next to its batch logs
+const SummaryFile = "summary.json"
+
+// Record is one finished file in a batch log
+type Record struct {
+	Time   time.Time `json:"time"`
+	Name   string    `json:"name"`
+	Result string    `json:"result"` // A downloader event type: completed, skipped, failed, ...
+	Bytes  int64     `json:"bytes"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// Totals count the files finished so far by result
+type Totals struct {
+	Finished  int   `json:"finished"`
+	Completed int   `json:"completed"`
+	Skipped   int   `json:"skipped"`
+	Failed    int   `json:"failed"`
+	Other     int   `json:"other,omitempty"` // Placeholders and rejected files
+	Bytes     int64 `json:"bytes"`           // Of completed files
+}
+
+// add counts a record
+func (t *Totals) add(r Record) {
+	t.Finished++
+	switch r.Result {
+	case "completed":
+		t.Completed++
+		t.Bytes += r.Bytes
+	case "skipped":
+		t.Skipped++
+	case "failed":
+		t.Failed++
+	default:
+		t.Other++
+	}
+}
+
+// Summary is the interim summary written at every checkpoint
+type Summary struct {
+	Source    string    `json:"source"`
+	StartedAt time.Time `json:"started_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Batches   int       `json:"batches"` // Closed batches
+	Total     int       `json:"total"`   // Files in the run
+	Totals    Totals    `json:"totals"`
+	Done      bool      `json:"done"` // The run ended; false after a crash
+}
+
+// Batcher writes finished files to rotating batch logs. It is safe for
+// concurrent use.
+type Batcher struct {
+	mu      sync.Mutex
+	dir     string
+	every   int
+	file    *os.File
+	batch   int // Number of the open batch, from 1
+	inBatch int
+	summary Summary
+}
+
+// Open starts batch logs for a run of total files in stateDir, closing a batch
+// every N finished files
+func Open(stateDir, source string, every, total int) (*Batcher, error) {
+	if every < 1 {
+		return nil, fmt.Errorf("checkpoint interval must be at least 1, got %d", every)
+	}
+	now := time.Now().UTC()
+	b := &Batcher{
+		dir:     filepath.Join(stateDir, Dir, now.Format("20060102-150405")),
+		every:   every,
+		summary: Summary{Source: source, StartedAt: now, UpdatedAt: now, Total: total},
+	}
+	if err := os.MkdirAll(b.dir, 0755); err != nil { //nolint:gosec // State lives alongside downloads
+		return nil, fmt.Errorf("failed to create batch directory: %w", err)
+	}
+	if err := b.writeSummary(); err != nil {
+		return nil, err
+	}
+	if err := b.rotate(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Path returns the directory holding this run's batch logs
+func (b *Batcher) Path() string {
+	return b.dir
+}
+
+// Add logs a finished file and reports whether it closed a batch, in which
+// case the interim summary was rewritten and a new batch log started
+func (b *Batcher) Add(r Record) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return false, err
+	}
+	if _, err := b.file.Write(append(data, '\n')); err != nil {
+		return false, fmt.Errorf("failed to write batch log: %w", err)
+	}
+	b.summary.Totals.add(r)
+	b.inBatch++
+	if b.inBatch < b.every {
+		return false, nil
+	}
+
+	b.summary.Batches++
+	if err := b.writeSummary(); err != nil {
+		return true, err
+	}
+	return true, b.rotate()
+}
+
+// Summary returns the totals so far
+func (b *Batcher) Summary() Summary {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.summary
+}
+
+// Close ends the run, closing the open batch and marking the summary done
+func (b *Batcher) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.inBatch > 0 {
+		b.summary.Batches++
+	}
+	err := b.closeBatch()
+	b.summary.Done = true
+	if serr := b.writeSummary(); err == nil {
+		err = serr
+	}
+	return err
+}
+
+// rotate closes the open batch log, dropping it if nothing was written to it,
+// and starts the next one; callers must hold b.mu
+func (b *Batcher) rotate() error {
+	if err := b.closeBatch(); err != nil {
+		return err
+	}
+	b.batch++
+	b.inBatch = 0
+	file, err := os.Create(filepath.Join(b.dir, batchName(b.batch))) //nolint:gosec // Path is derived from the state directory
+	if err != nil {
+		return fmt.Errorf("failed to create batch log: %w", err)
+	}
+	b.file = file
+	return nil
+}
+
+// closeBatch syncs and closes the open batch log; callers must hold b.mu
+func (b *Batcher) closeBatch() error {
+	if b.file == nil {
+		return nil
+	}
+	file := b.file
+	b.file = nil
+	if b.inBatch == 0 {
+		_ = file.Close()
+		return os.Remove(file.Name())
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to flush batch log: %w", err)
+	}
+	return file.Close()
+}
+
+// writeSummary atomically replaces the interim summary; callers must hold b.mu
+func (b *Batcher) writeSummary() error {
+	b.summary.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(b.summary, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(b.dir, SummaryFile)
+	temp, err := os.Create(path + cleanup.TempSuffix) //nolint:gosec // Path is derived from the state directory
+	if err != nil {
+		return fmt.Errorf("failed to write summary: %w", err)
+	}
+	_, err = temp.Write(append(data, '\n'))
+	if err == nil {
+		err = temp.Sync()
+	}
+	if cerr := temp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(temp.Name())
+		return fmt.Errorf("failed to write summary: %w", err)
+	}
+	return nil
+}
+
+// batchName names the log of a batch so logs sort in order
+func batchName(n int) string {
+	return fmt.Sprintf("batch-%04d.jsonl", n)
+}
+
+// ReadSummary reads the interim summary of a run's batch directory
+func ReadSummary(dir string) (Summary, error) {
+	data, err := os.ReadFile(filepath.Join(dir, SummaryFile)) //nolint:gosec // Path is derived from the state directory
+	if err != nil {
+		return Summary{}, err
+	}
+	var s Summary
+	if err := json.Unmarshal(data, &s); err != nil {
+		return Summary{}, fmt.Errorf("failed to parse summary: %w", err)
+	}
+	return s, nil
+}
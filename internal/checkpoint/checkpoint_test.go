@@ -0,0 +1,81 @@
+package checkpoint
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// countLines returns the number of lines in a batch log
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	f, err := os.Open(path) //nolint:gosec // Test file path is safe (from t.TempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	n := 0
+	for s := bufio.NewScanner(f); s.Scan(); {
+		n++
+	}
+	return n
+}
+
+func TestBatcher(t *testing.T) {
+	b, err := Open(t.TempDir(), "https://example.com/files/", 2, 5)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	results := []string{"completed", "skipped", "failed", "completed", "placeholder"}
+	var checkpoints int
+	for i, result := range results {
+		closed, err := b.Add(Record{Name: string(rune('a' + i)), Result: result, Bytes: 100})
+		if err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if closed {
+			checkpoints++
+		}
+	}
+	if checkpoints != 2 {
+		t.Errorf("expected a checkpoint every 2 files, got %d", checkpoints)
+	}
+
+	// The interim summary covers closed batches only until the run ends
+	s, err := ReadSummary(b.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Batches != 2 || s.Totals.Finished != 4 || s.Done {
+		t.Errorf("unexpected interim summary %+v", s)
+	}
+
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	s, err = ReadSummary(b.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Totals{Finished: 5, Completed: 2, Skipped: 1, Failed: 1, Other: 1, Bytes: 200}
+	if s.Batches != 3 || s.Totals != expected || !s.Done || s.Total != 5 {
+		t.Errorf("unexpected final summary %+v", s)
+	}
+
+	for i, lines := range []int{2, 2, 1} {
+		if got := countLines(t, filepath.Join(b.Path(), batchName(i+1))); got != lines {
+			t.Errorf("expected %d lines in batch %d, got %d", lines, i+1, got)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(b.Path(), batchName(4))); !os.IsNotExist(err) {
+		t.Error("expected no empty batch log to remain")
+	}
+}
+
+func TestOpen_InvalidInterval(t *testing.T) {
+	if _, err := Open(t.TempDir(), "", 0, 1); err == nil {
+		t.Error("expected an error for a zero interval")
+	}
+}
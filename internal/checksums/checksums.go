@@ -0,0 +1,210 @@
+// Package checksums finds the checksum files published in a listing (SHA1SUMS,
+// MD5SUMS, and per-file .sha1/.md5 sidecars) and looks up the digest each
+// download must match.
+package checksums
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/nchapman/myrient-dl/internal/hashing"
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// Source is a checksum file found in a listing
+type Source struct {
+	File      parser.FileInfo
+	Algorithm hashing.Algorithm
+	// For is the file a per-file sidecar covers, e.g. "Game.zip" for
+	// "Game.zip.sha1"; empty for SUMS files covering their whole directory
+	For string
+}
+
+// strength ranks the supported algorithms; stronger digests win when a file
+// is covered more than once
+var strength = map[hashing.Algorithm]int{
+	hashing.MD5:    1,
+	hashing.SHA1:   2,
+	hashing.SHA256: 3,
+}
+
+// digestLen is the length in hex digits of each supported algorithm's digest
+var digestLen = map[hashing.Algorithm]int{
+	hashing.MD5:    32,
+	hashing.SHA1:   40,
+	hashing.SHA256: 64,
+}
+
+// algorithm returns the algorithm of a checksum file name: SHA1SUMS, MD5SUMS,
+// and SHA256SUMS (optionally with .txt), or anything ending in .sha1, .md5,
+// or .sha256
+func algorithm(name string) (hashing.Algorithm, bool) {
+	base := strings.ToLower(path.Base(name))
+	base = strings.TrimSuffix(base, ".txt")
+	for a := range strength {
+		if base == string(a)+"sums" || strings.HasSuffix(base, "."+string(a)) {
+			return a, true
+		}
+	}
+	return "", false
+}
+
+// IsChecksumFile reports whether a listed file is a checksum file
+func IsChecksumFile(name string) bool {
+	_, ok := algorithm(name)
+	return ok
+}
+
+// Find returns the checksum files in listing that cover any of the selected
+// files. A .sha1/.md5/.sha256 file named after a listed file is a sidecar for
+// it; other checksum files cover their directory.
+func Find(listing, selected []parser.FileInfo) []Source {
+	listed := make(map[string]bool, len(listing))
+	for _, f := range listing {
+		listed[f.Name] = true
+	}
+	wanted := make(map[string]bool, len(selected))
+	dirs := make(map[string]bool)
+	for _, f := range selected {
+		wanted[f.Name] = true
+		dirs[path.Dir(f.Name)] = true
+	}
+
+	var sources []Source
+	for _, f := range listing {
+		a, ok := algorithm(f.Name)
+		if !ok {
+			continue
+		}
+		stem, sidecar := "", false
+		if i := strings.LastIndex(f.Name, "."); i > 0 {
+			stem = f.Name[:i]
+			sidecar = listed[stem]
+		}
+		switch {
+		case sidecar:
+			if wanted[stem] {
+				sources = append(sources, Source{File: f, Algorithm: a, For: stem})
+			}
+		case dirs[path.Dir(f.Name)]:
+			sources = append(sources, Source{File: f, Algorithm: a})
+		}
+	}
+	return sources
+}
+
+// Parse reads the digests in a checksum file, keyed by the names it lists.
+// Lines may be in sha1sum style ("digest  name", "digest *name"), BSD style
+// ("SHA1 (name) = digest"), or a bare digest, which is keyed by "". Blank
+// lines and lines starting with # or ; are ignored.
+func Parse(data []byte, a hashing.Algorithm) (map[string]string, error) {
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		var digest, name string
+		if rest, ok := cutBSD(line, a); ok {
+			i := strings.LastIndex(rest, ") = ")
+			if i < 0 {
+				return nil, fmt.Errorf("line %d: malformed %s line", n, strings.ToUpper(string(a)))
+			}
+			name, digest = rest[:i], rest[i+len(") = "):]
+		} else {
+			digest, name, _ = strings.Cut(line, " ")
+			name = strings.TrimPrefix(strings.TrimLeft(name, " "), "*")
+		}
+
+		digest = strings.ToLower(strings.TrimSpace(digest))
+		if _, err := hex.DecodeString(digest); err != nil || len(digest) != digestLen[a] {
+			return nil, fmt.Errorf("line %d: %q is not a %s digest", n, digest, strings.ToUpper(string(a)))
+		}
+		name = strings.TrimPrefix(strings.ReplaceAll(name, `\`, "/"), "./")
+		sums[name] = digest
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read checksum file: %w", err)
+	}
+	return sums, nil
+}
+
+// cutBSD strips the "SHA1 (" prefix of a BSD-style line
+func cutBSD(line string, a hashing.Algorithm) (string, bool) {
+	prefix := strings.ToUpper(string(a))
+	if len(line) < len(prefix)+2 || !strings.EqualFold(line[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.CutPrefix(strings.TrimLeft(line[len(prefix):], " "), "(")
+}
+
+// Sum is a published digest
+type Sum struct {
+	Algorithm hashing.Algorithm
+	Digest    string // Lowercase hex
+	Source    string // Name of the checksum file it came from
+}
+
+// Set holds the strongest published digest of each file
+type Set struct {
+	sums map[string]Sum
+}
+
+// NewSet returns an empty set
+func NewSet() *Set {
+	return &Set{sums: make(map[string]Sum)}
+}
+
+// Add parses a fetched checksum file and records its digests against the
+// listing names they cover, returning how many were recorded
+func (s *Set) Add(src Source, data []byte) (int, error) {
+	parsed, err := Parse(data, src.Algorithm)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", src.File.Name, err)
+	}
+
+	dir := path.Dir(src.File.Name)
+	added := 0
+	for name, digest := range parsed {
+		key := path.Join(dir, name)
+		if src.For != "" && (name == "" || path.Base(name) == path.Base(src.For)) {
+			key = src.For // Sidecars often name the file without its directory, or not at all
+		} else if name == "" {
+			continue
+		}
+		if old, ok := s.sums[key]; ok && strength[old.Algorithm] >= strength[src.Algorithm] {
+			continue
+		}
+		s.sums[key] = Sum{Algorithm: src.Algorithm, Digest: digest, Source: src.File.Name}
+		added++
+	}
+	return added, nil
+}
+
+// Put records a digest for a listed file, replacing any it had
+func (s *Set) Put(name string, sum Sum) {
+	s.sums[name] = sum
+}
+
+// Lookup returns the published digest of a listed file
+func (s *Set) Lookup(name string) (Sum, bool) {
+	if s == nil {
+		return Sum{}, false
+	}
+	sum, ok := s.sums[name]
+	return sum, ok
+}
+
+// Len returns the number of files with a published digest
+func (s *Set) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.sums)
+}
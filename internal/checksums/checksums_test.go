@@ -0,0 +1,147 @@
+package checksums
+
+import (
+	"testing"
+
+	"github.com/nchapman/myrient-dl/internal/hashing"
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+const (
+	helloSHA1 = "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"
+	helloMD5  = "5d41402abc4b2a76b9719d911017c592"
+)
+
+func TestIsChecksumFile(t *testing.T) {
+	tests := map[string]bool{
+		"SHA1SUMS":            true,
+		"md5sums.txt":         true,
+		"SHA256SUMS":          true,
+		"Disc 1/SHA1SUMS":     true,
+		"Game (USA).zip.sha1": true,
+		"Game (USA).zip.MD5":  true,
+		"checksums.sha256":    true,
+		"Game (USA).zip":      false,
+		"SHA1SUMS.sig":        false,
+		"readme.txt":          false,
+	}
+	for name, want := range tests {
+		if got := IsChecksumFile(name); got != want {
+			t.Errorf("IsChecksumFile(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		algo    hashing.Algorithm
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "sha1sum output",
+			data: "# comment\n" + helloSHA1 + "  Game (USA).zip\n" + helloSHA1 + " *./Disc 1/b.bin\n",
+			algo: hashing.SHA1,
+			want: map[string]string{"Game (USA).zip": helloSHA1, "Disc 1/b.bin": helloSHA1},
+		},
+		{
+			name: "BSD style",
+			data: "MD5 (Game (USA).zip) = " + helloMD5 + "\n",
+			algo: hashing.MD5,
+			want: map[string]string{"Game (USA).zip": helloMD5},
+		},
+		{
+			name: "bare digest",
+			data: "AAF4C61DDCC5E8A2DABEDE0F3B482CD9AEA9434D\r\n",
+			algo: hashing.SHA1,
+			want: map[string]string{"": helloSHA1},
+		},
+		{
+			name:    "wrong algorithm",
+			data:    helloMD5 + "  a.zip\n",
+			algo:    hashing.SHA1,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.data), tt.algo)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+			for name, digest := range tt.want {
+				if got[name] != digest {
+					t.Errorf("%q: expected %s, got %s", name, digest, got[name])
+				}
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	listing := []parser.FileInfo{
+		{Name: "a.zip"},
+		{Name: "a.zip.md5"},
+		{Name: "b.zip"},
+		{Name: "b.zip.md5"},
+		{Name: "SHA1SUMS"},
+		{Name: "Other/c.zip"},
+		{Name: "Other/SHA1SUMS"},
+	}
+	selected := []parser.FileInfo{{Name: "a.zip"}}
+
+	sources := Find(listing, selected)
+	if len(sources) != 2 {
+		t.Fatalf("expected the sidecar of a.zip and the top-level SHA1SUMS, got %+v", sources)
+	}
+	if sources[0].File.Name != "a.zip.md5" || sources[0].For != "a.zip" || sources[0].Algorithm != hashing.MD5 {
+		t.Errorf("unexpected sidecar %+v", sources[0])
+	}
+	if sources[1].File.Name != "SHA1SUMS" || sources[1].For != "" || sources[1].Algorithm != hashing.SHA1 {
+		t.Errorf("unexpected SUMS file %+v", sources[1])
+	}
+}
+
+func TestSet(t *testing.T) {
+	set := NewSet()
+	sums := Source{File: parser.FileInfo{Name: "Disc 1/SHA1SUMS"}, Algorithm: hashing.SHA1}
+	if n, err := set.Add(sums, []byte(helloSHA1+"  a.bin\n"+helloSHA1+"  b.bin\n")); err != nil || n != 2 {
+		t.Fatalf("expected 2 digests, got %d (%v)", n, err)
+	}
+
+	// A weaker sidecar doesn't replace a SHA-1
+	sidecar := Source{File: parser.FileInfo{Name: "Disc 1/a.bin.md5"}, Algorithm: hashing.MD5, For: "Disc 1/a.bin"}
+	if n, err := set.Add(sidecar, []byte(helloMD5+"\n")); err != nil || n != 0 {
+		t.Fatalf("expected the MD5 to be ignored, got %d (%v)", n, err)
+	}
+	// A sidecar naming its file without the directory still applies
+	sidecar = Source{File: parser.FileInfo{Name: "c.bin.md5"}, Algorithm: hashing.MD5, For: "c.bin"}
+	if n, err := set.Add(sidecar, []byte(helloMD5+"  c.bin\n")); err != nil || n != 1 {
+		t.Fatalf("expected the sidecar's digest, got %d (%v)", n, err)
+	}
+
+	if sum, ok := set.Lookup("Disc 1/a.bin"); !ok || sum.Algorithm != hashing.SHA1 || sum.Digest != helloSHA1 || sum.Source != "Disc 1/SHA1SUMS" {
+		t.Errorf("unexpected digest for a.bin: %+v", sum)
+	}
+	if sum, ok := set.Lookup("c.bin"); !ok || sum.Digest != helloMD5 {
+		t.Errorf("unexpected digest for c.bin: %+v", sum)
+	}
+	if _, ok := set.Lookup("a.bin"); ok {
+		t.Error("expected names to be relative to the listing, not the SUMS file")
+	}
+	if set.Len() != 3 {
+		t.Errorf("expected 3 files, got %d", set.Len())
+	}
+
+	if _, err := set.Add(sums, []byte("not a digest  a.bin\n")); err == nil {
+		t.Error("expected an error for a malformed file")
+	}
+}
@@ -0,0 +1,191 @@
+// Package cleanup finds and removes artifacts left behind by interrupted runs.
+package cleanup
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/state"
+)
+
+// Artifact locations and suffixes used by myrient-dl
+const (
+	TempSuffix = ".tmp"
+	// JournalSuffix names the journal kept next to a download's temp file,
+	// e.g. "Game.zip.journal.tmp" for "Game.zip.tmp"
+	JournalSuffix = ".journal" + TempSuffix
+	LockSuffix    = ".lock"
+	StateDir      = state.DirName
+	QuarantineDir = StateDir + "/quarantine"
+)
+
+// ErrRunning is returned by Scan for a directory a download is running in
+var ErrRunning = errors.New("a download is running")
+
+// Kind classifies a leftover artifact
+type Kind string
+
+// Artifact kinds
+const (
+	KindTemp       Kind = "temp"
+	KindLock       Kind = "lock"
+	KindQuarantine Kind = "quarantine"
+)
+
+// Artifact is a leftover file that can be removed
+type Artifact struct {
+	Path    string
+	Kind    Kind
+	Size    int64
+	ModTime time.Time
+}
+
+// Scan walks dir and returns orphaned temp files, stale run locks, and
+// quarantined files. Only myrient-dl's own temp files count: journals, the
+// temp files they describe, temp files of files in a run's queue, and temp
+// files in the state directory, so a user's "notes.tmp" is never touched.
+// Temp files and locks modified within minAge are left alone; quarantined
+// files are always reported. A directory with a live run fails the scan with
+// ErrRunning, since its temp files and quarantine are still in use.
+func Scan(dir string, minAge time.Duration) ([]Artifact, error) {
+	cutoff := time.Now().Add(-minAge)
+	quarantine := filepath.Join(dir, filepath.FromSlash(QuarantineDir))
+	queued := make(map[string]bool) // Temp paths of files in run queues
+
+	var artifacts []Artifact
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if pid, ok := state.Running(path); ok {
+				return fmt.Errorf("%w in %s (pid %d); clean it after the run finishes", ErrRunning, path, pid)
+			}
+			// Directories are visited before their contents, including the
+			// subdirectories a recursive run's names lead into
+			if q, err := state.Load(path); err == nil {
+				for _, f := range q.Files() {
+					queued[filepath.Join(path, filepath.FromSlash(f.Name))+TempSuffix] = true
+				}
+			}
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		name := entry.Name()
+		inState := filepath.Base(filepath.Dir(path)) == StateDir
+		var kind Kind
+		switch {
+		case strings.HasPrefix(path, quarantine+string(filepath.Separator)):
+			kind = KindQuarantine
+		case !info.ModTime().Before(cutoff):
+			return nil
+		case inState && name == state.LockFile:
+			kind = KindLock
+		case strings.HasSuffix(name, TempSuffix) && (inState || queued[path] || journaled(path)):
+			kind = KindTemp
+		default:
+			return nil
+		}
+
+		artifacts = append(artifacts, Artifact{
+			Path:    path,
+			Kind:    kind,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+
+	return artifacts, err
+}
+
+// journaled reports whether path is a download journal or a temp file with one
+func journaled(path string) bool {
+	if strings.HasSuffix(path, JournalSuffix) {
+		return true
+	}
+	_, err := os.Stat(strings.TrimSuffix(path, TempSuffix) + JournalSuffix)
+	return err == nil
+}
+
+// Quarantine moves a file inside dir into the quarantine, keeping its relative path,
+// so it is downloaded again on the next run and can be inspected or removed with clean.
+// It returns the quarantined path.
+func Quarantine(dir, rel string) (string, error) {
+	target := filepath.Join(dir, filepath.FromSlash(QuarantineDir), rel)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil { //nolint:gosec // Quarantine lives alongside downloads
+		return "", fmt.Errorf("failed to create quarantine: %w", err)
+	}
+	if err := os.Rename(filepath.Join(dir, rel), target); err != nil {
+		return "", fmt.Errorf("failed to quarantine %s: %w", rel, err)
+	}
+	return target, nil
+}
+
+// Restore moves a quarantined file back to its place in dir, unless something
+// already took its place, and prunes quarantine directories left empty
+func Restore(dir, rel string) error {
+	quarantine := filepath.Join(dir, filepath.FromSlash(QuarantineDir))
+	target := filepath.Join(dir, rel)
+	if _, err := os.Stat(target); err == nil {
+		return fmt.Errorf("failed to restore %s: a file already exists there", rel)
+	}
+	if err := os.Rename(filepath.Join(quarantine, rel), target); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", rel, err)
+	}
+	pruneEmptyDirs(quarantine)
+	return nil
+}
+
+// TotalSize returns the combined size of the artifacts
+func TotalSize(artifacts []Artifact) int64 {
+	var total int64
+	for _, a := range artifacts {
+		total += a.Size
+	}
+	return total
+}
+
+// Remove deletes the artifacts and prunes quarantine directories left empty.
+// It returns the number of files removed and the first error encountered.
+func Remove(dir string, artifacts []Artifact) (int, error) {
+	var firstErr error
+	removed := 0
+	for _, a := range artifacts {
+		if err := os.Remove(a.Path); err != nil && !os.IsNotExist(err) {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		removed++
+	}
+
+	pruneEmptyDirs(filepath.Join(dir, filepath.FromSlash(QuarantineDir)))
+	return removed, firstErr
+}
+
+// pruneEmptyDirs removes empty directories below root, deepest first
+func pruneEmptyDirs(root string) {
+	var dirs []string
+	_ = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && entry.IsDir() && path != root {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		_ = os.Remove(dirs[i]) // Fails harmlessly when not empty
+	}
+}
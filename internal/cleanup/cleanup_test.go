@@ -0,0 +1,208 @@
+package cleanup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/parser"
+	"github.com/nchapman/myrient-dl/internal/state"
+)
+
+func writeFile(t *testing.T, path string, size int, age time.Duration) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, make([]byte, size), 0600); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now().Add(-age)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func setup(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	// A queued file of a recursive run, whose temp file has no journal yet
+	if _, err := state.Create(dir, "https://example.com/", []parser.FileInfo{{Name: "sub/nested.zip"}}); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "game.zip"), 100, 48*time.Hour)
+	writeFile(t, filepath.Join(dir, "old.zip.tmp"), 200, 48*time.Hour)
+	writeFile(t, filepath.Join(dir, "old.zip.journal.tmp"), 5, 48*time.Hour)
+	writeFile(t, filepath.Join(dir, "active.zip.tmp"), 300, time.Minute)
+	writeFile(t, filepath.Join(dir, "active.zip.journal.tmp"), 5, time.Minute)
+	writeFile(t, filepath.Join(dir, "sub", "nested.zip.tmp"), 400, 48*time.Hour)
+	writeFile(t, filepath.Join(dir, StateDir, state.LockFile), 10, 48*time.Hour)
+	writeFile(t, filepath.Join(dir, QuarantineDir, "2024-01-01", "game.zip"), 500, time.Minute)
+	// The user's own files that merely share the suffixes
+	writeFile(t, filepath.Join(dir, "notes.tmp"), 50, 48*time.Hour)
+	writeFile(t, filepath.Join(dir, "yarn.lock"), 60, 48*time.Hour)
+	return dir
+}
+
+func TestScan(t *testing.T) {
+	dir := setup(t)
+
+	artifacts, err := Scan(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got []string
+	for _, a := range artifacts {
+		rel, _ := filepath.Rel(dir, a.Path)
+		got = append(got, string(a.Kind)+":"+filepath.ToSlash(rel))
+	}
+	sort.Strings(got)
+
+	expected := []string{
+		"lock:.myrient-dl/run.lock",
+		"quarantine:.myrient-dl/quarantine/2024-01-01/game.zip",
+		"temp:old.zip.journal.tmp",
+		"temp:old.zip.tmp",
+		"temp:sub/nested.zip.tmp",
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("expected %s, got %s", expected[i], got[i])
+		}
+	}
+
+	if total := TotalSize(artifacts); total != 1115 {
+		t.Errorf("expected total size 1115, got %d", total)
+	}
+}
+
+func TestScan_ZeroMinAge(t *testing.T) {
+	dir := setup(t)
+
+	artifacts, err := Scan(dir, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(artifacts) != 7 {
+		t.Errorf("expected active temp file and journal to be included, got %d artifacts", len(artifacts))
+	}
+	for _, a := range artifacts {
+		if name := filepath.Base(a.Path); name == "notes.tmp" || name == "yarn.lock" {
+			t.Errorf("expected the user's %s to be left alone", name)
+		}
+	}
+}
+
+func TestScan_LiveRun(t *testing.T) {
+	for _, sub := range []string{"", "SNES"} {
+		t.Run("dir="+sub, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, sub, "old.zip.tmp"), 200, 48*time.Hour)
+			writeFile(t, filepath.Join(dir, sub, "old.zip.journal.tmp"), 5, 48*time.Hour)
+
+			lock, err := state.Acquire(filepath.Join(dir, sub))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Scan(dir, time.Hour); !errors.Is(err, ErrRunning) {
+				t.Errorf("expected ErrRunning while a run holds the lock, got %v", err)
+			}
+
+			lock.Release()
+			artifacts, err := Scan(dir, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(artifacts) != 2 {
+				t.Errorf("expected the temp file and journal once the run ended, got %+v", artifacts)
+			}
+		})
+	}
+}
+
+func TestRemove(t *testing.T) {
+	dir := setup(t)
+
+	artifacts, err := Scan(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := Remove(dir, artifacts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if removed != 5 {
+		t.Errorf("expected 5 removed, got %d", removed)
+	}
+
+	for _, a := range artifacts {
+		if _, err := os.Stat(a.Path); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed", a.Path)
+		}
+	}
+
+	for _, keep := range []string{"game.zip", "active.zip.tmp", "notes.tmp", "yarn.lock"} {
+		if _, err := os.Stat(filepath.Join(dir, keep)); err != nil {
+			t.Errorf("expected %s to be kept: %v", keep, err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, QuarantineDir, "2024-01-01")); !os.IsNotExist(err) {
+		t.Error("expected empty quarantine subdirectory to be pruned")
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "sub", "game.zip"), 10, 0)
+
+	target, err := Quarantine(dir, filepath.Join("sub", "game.zip"))
+	if err != nil {
+		t.Fatalf("failed to quarantine: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sub", "game.zip")); !os.IsNotExist(err) {
+		t.Error("expected file to be moved out of place")
+	}
+	if target != filepath.Join(dir, ".myrient-dl", "quarantine", "sub", "game.zip") {
+		t.Errorf("unexpected quarantine path %s", target)
+	}
+
+	artifacts, err := Scan(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(artifacts) != 1 || artifacts[0].Kind != KindQuarantine {
+		t.Errorf("expected quarantined file to be found by Scan, got %+v", artifacts)
+	}
+
+	if _, err := Quarantine(dir, "missing.zip"); err == nil {
+		t.Error("expected error for missing file")
+	}
+
+	if err := Restore(dir, filepath.Join("sub", "game.zip")); err != nil {
+		t.Fatalf("failed to restore: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sub", "game.zip")); err != nil {
+		t.Errorf("expected file to be back in place: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".myrient-dl", "quarantine", "sub")); !os.IsNotExist(err) {
+		t.Error("expected empty quarantine directories to be pruned")
+	}
+
+	// A file that took the original's place is never overwritten
+	if _, err := Quarantine(dir, filepath.Join("sub", "game.zip")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "sub", "game.zip"), 20, 0)
+	if err := Restore(dir, filepath.Join("sub", "game.zip")); err == nil {
+		t.Error("expected error when restoring over an existing file")
+	}
+}
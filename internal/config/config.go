@@ -0,0 +1,217 @@
+// Package config loads user defaults from ~/.config/myrient-dl/config.yaml.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nchapman/myrient-dl/internal/catalog"
+	"github.com/nchapman/myrient-dl/internal/politeness"
+	"github.com/nchapman/myrient-dl/internal/useragent"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds user defaults
+type Config struct {
+	OutputRoots []OutputRoot `yaml:"output_roots"`
+	// Mirrors lists groups of equivalent URL prefixes, primary first, e.g.
+	// [https://myrient.erista.me/files/, https://mirror.example.org/myrient/].
+	// Files that keep failing under one prefix are retried under the next.
+	Mirrors [][]string `yaml:"mirrors"`
+	// Contact is an email address or URL appended to the User-Agent so archive
+	// operators can reach you; an email address is also sent as the From header
+	Contact string `yaml:"contact"`
+	// Hosts replaces the built-in politeness profile of a host, or adds one,
+	// keyed by host name; a profile also covers the host's subdomains
+	Hosts map[string]politeness.Profile `yaml:"hosts"`
+	// Defaults sets command-line options that weren't given, keyed by their
+	// long flag name ("parallel", "limit-rate", or "limit_rate")
+	Defaults map[string]Setting `yaml:"defaults"`
+}
+
+// Setting is the value of a default: a scalar, or a list for repeatable options
+type Setting []string
+
+// UnmarshalYAML accepts a scalar or a list of scalars
+func (s *Setting) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		*s = Setting{node.Value}
+		return nil
+	case yaml.SequenceNode:
+		values := make(Setting, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return fmt.Errorf("line %d: expected a list of plain values", item.Line)
+			}
+			values = append(values, item.Value)
+		}
+		*s = values
+		return nil
+	default:
+		return fmt.Errorf("line %d: expected a value or a list of values", node.Line)
+	}
+}
+
+// OutputRoot maps a set of listings to the directory their downloads land under
+type OutputRoot struct {
+	// Match is a URL prefix ("https://myrient.erista.me/files/No-Intro/") or a
+	// catalog ID: a collection ("No-Intro") or collection and system
+	// ("Redump/Sony - PlayStation"), compared case-insensitively
+	Match string `yaml:"match"`
+	// Root is the directory downloads go under. "{collection}" and "{system}" are
+	// expanded; without either, the default directory name is appended.
+	Root string `yaml:"root"`
+}
+
+// DefaultPath returns the config file location in the user's config directory
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+	return filepath.Join(dir, "myrient-dl", "config.yaml"), nil
+}
+
+// Load reads a config file. A missing file yields an empty config.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // Config path is the user's own file
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var c Config
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	for i, r := range c.OutputRoots {
+		if r.Match == "" || r.Root == "" {
+			return nil, fmt.Errorf("config %s: output_roots entry %d needs both match and root", path, i+1)
+		}
+	}
+
+	for i, group := range c.Mirrors {
+		if len(group) < 2 {
+			return nil, fmt.Errorf("config %s: mirrors entry %d needs at least two URL prefixes", path, i+1)
+		}
+		for _, prefix := range group {
+			if u, err := url.Parse(prefix); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, fmt.Errorf("config %s: invalid mirror URL %q", path, prefix)
+			}
+		}
+	}
+
+	if _, err := useragent.New(c.Contact); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+
+	for host, p := range c.Hosts {
+		if host == "" || strings.ContainsAny(host, ":/") {
+			return nil, fmt.Errorf("config %s: hosts entry %q should be a bare host name", path, host)
+		}
+		if err := p.Validate(); err != nil {
+			return nil, fmt.Errorf("config %s: hosts entry %s: %w", path, host, err)
+		}
+	}
+
+	defaults := make(map[string]Setting, len(c.Defaults))
+	for name, values := range c.Defaults {
+		flag := strings.ReplaceAll(name, "_", "-")
+		if _, dup := defaults[flag]; dup {
+			return nil, fmt.Errorf("config %s: defaults sets %s twice", path, flag)
+		}
+		for i, v := range values {
+			values[i] = expandHome(v)
+		}
+		defaults[flag] = values
+	}
+	c.Defaults = defaults
+
+	return &c, nil
+}
+
+// Alternates returns the file's URL under each other prefix of the first mirror
+// group it belongs to, in configured order starting after the matching prefix
+func (c *Config) Alternates(fileURL string) []string {
+	for _, group := range c.Mirrors {
+		for i, prefix := range group {
+			if !strings.HasPrefix(fileURL, prefix) {
+				continue
+			}
+			rest := strings.TrimPrefix(fileURL, prefix)
+
+			var alternates []string
+			for j := 1; j < len(group); j++ {
+				alternates = append(alternates, group[(i+j)%len(group)]+rest)
+			}
+			return alternates
+		}
+	}
+	return nil
+}
+
+// OutputDir returns the output directory configured for a listing URL, given the
+// directory name that would be used by default. The first matching root wins.
+func (c *Config) OutputDir(listingURL, defaultName string) (string, bool) {
+	sys, detected := catalog.Detect(listingURL)
+
+	for _, r := range c.OutputRoots {
+		if !r.matches(listingURL, sys, detected) {
+			continue
+		}
+
+		root := expandHome(r.Root)
+		if !strings.Contains(root, "{collection}") && !strings.Contains(root, "{system}") {
+			return filepath.Join(root, defaultName), true
+		}
+		root = strings.NewReplacer("{collection}", sys.Collection, "{system}", sys.Name).Replace(root)
+		return filepath.Clean(root), true
+	}
+
+	return "", false
+}
+
+// matches reports whether the root applies to the listing
+func (r OutputRoot) matches(listingURL string, sys catalog.System, detected bool) bool {
+	if strings.Contains(r.Match, "://") {
+		return strings.HasPrefix(unescape(listingURL), unescape(r.Match))
+	}
+	if !detected {
+		return false
+	}
+
+	collection, system, hasSystem := strings.Cut(strings.Trim(r.Match, "/"), "/")
+	if !strings.EqualFold(collection, sys.Collection) {
+		return false
+	}
+	return !hasSystem || strings.EqualFold(system, sys.Name)
+}
+
+// unescape decodes percent-escapes so "Nintendo%20-%20NES" and "Nintendo - NES" compare equal
+func unescape(s string) string {
+	if decoded, err := url.PathUnescape(s); err == nil {
+		return decoded
+	}
+	return s
+}
+
+// expandHome replaces a leading "~" with the user's home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
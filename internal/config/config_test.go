@@ -0,0 +1,201 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testConfig = `
+output_roots:
+  - match: Redump/Sony - PlayStation
+    root: /mnt/psx
+  - match: no-intro
+    root: /mnt/roms/no-intro
+  - match: https://example.com/files/Custom%20Set/
+    root: /mnt/custom/{collection}-{system}
+`
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	c, err := Load(writeConfig(t, testConfig))
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if len(c.OutputRoots) != 3 {
+		t.Errorf("expected 3 output roots, got %d", len(c.OutputRoots))
+	}
+
+	missing, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err != nil || len(missing.OutputRoots) != 0 {
+		t.Errorf("expected empty config for missing file, got %+v (%v)", missing, err)
+	}
+
+	if _, err := Load(writeConfig(t, "output_roots: [{match: No-Intro}]")); err == nil {
+		t.Error("expected error for output root without a root")
+	}
+	if _, err := Load(writeConfig(t, "output_roots: {")); err == nil {
+		t.Error("expected error for invalid YAML")
+	}
+	if _, err := Load(writeConfig(t, "mirrors: [[https://a.example/files/]]")); err == nil {
+		t.Error("expected error for a mirror group with one prefix")
+	}
+	if _, err := Load(writeConfig(t, "mirrors: [[https://a.example/files/, a.example/files/]]")); err == nil {
+		t.Error("expected error for a mirror prefix without a scheme")
+	}
+	if c, err := Load(writeConfig(t, "contact: you@example.com")); err != nil || c.Contact != "you@example.com" {
+		t.Errorf("expected contact to load, got %+v (%v)", c, err)
+	}
+	if _, err := Load(writeConfig(t, "contact: somebody")); err == nil {
+		t.Error("expected error for a contact that is neither an email address nor a URL")
+	}
+	c, err = Load(writeConfig(t, "hosts: {myrient.erista.me: {max_parallel: 8, request_interval: 100ms}}"))
+	if err != nil {
+		t.Fatalf("failed to load hosts: %v", err)
+	}
+	if p := c.Hosts["myrient.erista.me"]; p.MaxParallel != 8 || p.RequestInterval != 100*time.Millisecond {
+		t.Errorf("unexpected host profile %+v", p)
+	}
+	if _, err := Load(writeConfig(t, "hosts: {https://myrient.erista.me/: {max_parallel: 8}}")); err == nil {
+		t.Error("expected error for a hosts entry that is a URL")
+	}
+	if _, err := Load(writeConfig(t, "hosts: {myrient.erista.me: {backoff_max: -1s}}")); err == nil {
+		t.Error("expected error for a negative limit")
+	}
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+
+	c, err := Load(writeConfig(t, `
+defaults:
+  parallel: 4
+  limit_rate: 2M
+  output: ~/roms
+  exclude: ["*(Beta)*", "*(Proto)*"]
+  include: []
+`))
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	want := map[string]Setting{
+		"parallel":   {"4"},
+		"limit-rate": {"2M"},
+		"output":     {filepath.Join(home, "roms")},
+		"exclude":    {"*(Beta)*", "*(Proto)*"},
+		"include":    {},
+	}
+	if !reflect.DeepEqual(c.Defaults, want) {
+		t.Errorf("expected defaults %v, got %v", want, c.Defaults)
+	}
+
+	for _, content := range []string{
+		"defaults: {exclude: [[a]]}",
+		"defaults: {retry: {max: 3}}",
+		"defaults: {limit-rate: 1M, limit_rate: 2M}",
+	} {
+		if _, err := Load(writeConfig(t, content)); err == nil {
+			t.Errorf("expected error for %q", content)
+		}
+	}
+}
+
+func TestAlternates(t *testing.T) {
+	c, err := Load(writeConfig(t, `
+mirrors:
+  - - https://primary.example/files/
+    - https://one.example/myrient/
+    - https://two.example/
+`))
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	got := c.Alternates("https://primary.example/files/No-Intro/a.zip")
+	want := []string{"https://one.example/myrient/No-Intro/a.zip", "https://two.example/No-Intro/a.zip"}
+	if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	// A mirror URL falls back to the others, primary included
+	got = c.Alternates("https://one.example/myrient/No-Intro/a.zip")
+	if len(got) != 2 || got[0] != "https://two.example/No-Intro/a.zip" || got[1] != "https://primary.example/files/No-Intro/a.zip" {
+		t.Errorf("unexpected alternates %v", got)
+	}
+
+	if got := c.Alternates("https://other.example/files/a.zip"); got != nil {
+		t.Errorf("expected no alternates for an unmirrored URL, got %v", got)
+	}
+}
+
+func TestOutputDir(t *testing.T) {
+	c, err := Load(writeConfig(t, testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		url         string
+		defaultName string
+		expected    string
+		ok          bool
+	}{
+		{
+			name:        "collection match appends default name",
+			url:         "https://myrient.erista.me/files/No-Intro/Nintendo%20-%20Game%20Boy/",
+			defaultName: "Nintendo - Game Boy",
+			expected:    "/mnt/roms/no-intro/Nintendo - Game Boy",
+			ok:          true,
+		},
+		{
+			name:        "system match",
+			url:         "https://myrient.erista.me/files/Redump/Sony%20-%20PlayStation/",
+			defaultName: "Sony - PlayStation",
+			expected:    "/mnt/psx/Sony - PlayStation",
+			ok:          true,
+		},
+		{
+			name:        "URL prefix with placeholders",
+			url:         "https://example.com/files/Custom Set/Thing/",
+			defaultName: "Thing",
+			expected:    "/mnt/custom/Custom Set-Thing",
+			ok:          true,
+		},
+		{
+			name: "other system in matched collection",
+			url:  "https://myrient.erista.me/files/Redump/Sega%20-%20Saturn/",
+			ok:   false,
+		},
+		{
+			name: "unrelated URL",
+			url:  "https://example.com/downloads/",
+			ok:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, ok := c.OutputDir(tt.url, tt.defaultName)
+			if ok != tt.ok {
+				t.Fatalf("expected match %v, got %v (%s)", tt.ok, ok, dir)
+			}
+			if ok && dir != filepath.FromSlash(tt.expected) {
+				t.Errorf("expected %s, got %s", tt.expected, dir)
+			}
+		})
+	}
+}
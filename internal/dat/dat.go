@@ -0,0 +1,115 @@
+// Package dat provides parsing for Logiqx XML DAT files (No-Intro, Redump, MAME).
+package dat
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Datafile represents a parsed Logiqx XML DAT file
+type Datafile struct {
+	Header   Header `xml:"header"`
+	Games    []Game `xml:"game"`
+	Machines []Game `xml:"machine"` // MAME -listxml style entries, merged into Games
+
+	byName map[string]*Game
+}
+
+// Header holds the descriptive metadata of a DAT file
+type Header struct {
+	Name        string `xml:"name"`
+	Description string `xml:"description"`
+	Version     string `xml:"version"`
+}
+
+// Game represents a single game (or disc) entry in a DAT file
+type Game struct {
+	Name        string      `xml:"name,attr"`
+	Description string      `xml:"description"`
+	Category    string      `xml:"category"`
+	Serial      string      `xml:"serial"`
+	CloneOf     string      `xml:"cloneof,attr"`
+	RomOf       string      `xml:"romof,attr"`
+	IsBIOS      string      `xml:"isbios,attr"`
+	IsDevice    string      `xml:"isdevice,attr"`
+	ROMs        []ROM       `xml:"rom"`
+	DeviceRefs  []DeviceRef `xml:"device_ref"`
+}
+
+// DeviceRef references a MAME device machine required by a game
+type DeviceRef struct {
+	Name string `xml:"name,attr"`
+}
+
+// ROM represents a single ROM or track within a game entry
+type ROM struct {
+	Name string `xml:"name,attr"`
+	Size int64  `xml:"size,attr"`
+	CRC  string `xml:"crc,attr"`
+	MD5  string `xml:"md5,attr"`
+	SHA1 string `xml:"sha1,attr"`
+	// Merge names the parent ROM this entry shares in MAME parent/clone sets
+	Merge string `xml:"merge,attr"`
+	// Status is the dump status, e.g. "verified", "baddump", or "nodump"
+	Status string `xml:"status,attr"`
+}
+
+// Load reads and parses a DAT file from disk
+func Load(path string) (*Datafile, error) {
+	f, err := os.Open(path) //nolint:gosec // DAT path is provided by the user
+	if err != nil {
+		return nil, fmt.Errorf("failed to open DAT file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	return Parse(f)
+}
+
+// Parse parses a Logiqx XML DAT from the given reader
+func Parse(r io.Reader) (*Datafile, error) {
+	var d Datafile
+	if err := xml.NewDecoder(r).Decode(&d); err != nil {
+		return nil, fmt.Errorf("failed to parse DAT: %w", err)
+	}
+
+	d.Games = append(d.Games, d.Machines...)
+	d.Machines = nil
+
+	d.byName = make(map[string]*Game, len(d.Games))
+	for i := range d.Games {
+		d.byName[d.Games[i].Name] = &d.Games[i]
+	}
+
+	return &d, nil
+}
+
+// Lookup finds the game entry for a listing filename.
+// Myrient names archives after the DAT game name, so "Game (USA).zip"
+// resolves to the game named "Game (USA)".
+func (d *Datafile) Lookup(filename string) (*Game, bool) {
+	if g, ok := d.byName[filename]; ok {
+		return g, true
+	}
+
+	g, ok := d.byName[strings.TrimSuffix(filename, filepath.Ext(filename))]
+	return g, ok
+}
+
+// Serials returns the individual serials of a game.
+// Redump lists multiple serials for one disc separated by commas.
+func (g *Game) Serials() []string {
+	var serials []string
+	for _, s := range strings.Split(g.Serial, ",") {
+		s = strings.TrimSpace(s)
+		if s != "" {
+			serials = append(serials, s)
+		}
+	}
+	return serials
+}
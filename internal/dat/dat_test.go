@@ -0,0 +1,619 @@
+package dat
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+const redumpDAT = `<?xml version="1.0"?>
+<!DOCTYPE datafile PUBLIC "-//Logiqx//DTD ROM Management Datafile//EN" "http://www.logiqx.com/Dats/datafile.dtd">
+<datafile>
+	<header>
+		<name>Sony - PlayStation</name>
+		<description>Sony - PlayStation - Discs (10853) (2024-01-01 00-00-00)</description>
+		<version>2024-01-01 00-00-00</version>
+	</header>
+	<game name="Crash Bandicoot (USA)">
+		<category>Games</category>
+		<description>Crash Bandicoot (USA)</description>
+		<serial>SCUS-94900</serial>
+		<rom name="Crash Bandicoot (USA).cue" size="87" crc="1ba8ba5e" md5="d41d8cd98f00b204e9800998ecf8427e" sha1="da39a3ee5e6b4b0d3255bfef95601890afd80709"/>
+		<rom name="Crash Bandicoot (USA).bin" size="504886752" crc="e2fa8e5d"/>
+	</game>
+	<game name="Crash Bandicoot (USA) (Rev 1)">
+		<category>Games</category>
+		<description>Crash Bandicoot (USA) (Rev 1)</description>
+		<serial>SCUS-94900</serial>
+		<rom name="Crash Bandicoot (USA) (Rev 1).bin" size="504886752" crc="0a4b1c2d"/>
+	</game>
+	<game name="Final Fantasy VII (USA) (Disc 1)">
+		<category>Games</category>
+		<description>Final Fantasy VII (USA) (Disc 1)</description>
+		<serial>SCUS-94163, SCUS-94163GH</serial>
+		<rom name="Final Fantasy VII (USA) (Disc 1).bin" size="747435024" crc="1459cbef"/>
+	</game>
+	<game name="Demo Disc (Europe)">
+		<category>Demos</category>
+		<description>Demo Disc (Europe)</description>
+		<rom name="Demo Disc (Europe).bin" size="1024" crc="00000000"/>
+	</game>
+</datafile>`
+
+func mustParse(t *testing.T, data string) *Datafile {
+	t.Helper()
+	d, err := Parse(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse DAT: %v", err)
+	}
+	return d
+}
+
+func TestParse(t *testing.T) {
+	d := mustParse(t, redumpDAT)
+
+	if d.Header.Name != "Sony - PlayStation" {
+		t.Errorf("expected header name %q, got %q", "Sony - PlayStation", d.Header.Name)
+	}
+
+	if len(d.Games) != 4 {
+		t.Fatalf("expected 4 games, got %d", len(d.Games))
+	}
+
+	crash := d.Games[0]
+	if crash.Serial != "SCUS-94900" {
+		t.Errorf("expected serial SCUS-94900, got %q", crash.Serial)
+	}
+	if len(crash.ROMs) != 2 {
+		t.Fatalf("expected 2 roms, got %d", len(crash.ROMs))
+	}
+	if crash.ROMs[1].Size != 504886752 {
+		t.Errorf("expected rom size 504886752, got %d", crash.ROMs[1].Size)
+	}
+	if crash.ROMs[0].SHA1 != "da39a3ee5e6b4b0d3255bfef95601890afd80709" {
+		t.Errorf("unexpected sha1 %q", crash.ROMs[0].SHA1)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	if _, err := Parse(strings.NewReader("<datafile><game>")); err == nil {
+		t.Error("expected error for truncated DAT, got nil")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	d := mustParse(t, redumpDAT)
+
+	tests := []struct {
+		filename string
+		expected string
+		found    bool
+	}{
+		{"Crash Bandicoot (USA).zip", "Crash Bandicoot (USA)", true},
+		{"Crash Bandicoot (USA).7z", "Crash Bandicoot (USA)", true},
+		{"Crash Bandicoot (USA)", "Crash Bandicoot (USA)", true},
+		{"Unknown Game (USA).zip", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			game, ok := d.Lookup(tt.filename)
+			if ok != tt.found {
+				t.Fatalf("expected found=%v, got %v", tt.found, ok)
+			}
+			if ok && game.Name != tt.expected {
+				t.Errorf("expected game %q, got %q", tt.expected, game.Name)
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	listing := []parser.FileInfo{
+		{Name: "Crash Bandicoot (USA).zip"},
+		{Name: "Sony - PlayStation (2024-01-01).dat"},
+		{Name: "Extras/index.XML"},
+		{Name: "Other/Other.dat"},
+		{Name: "readme.txt"},
+	}
+	selected := []parser.FileInfo{{Name: "Crash Bandicoot (USA).zip"}, {Name: "Extras/Manual.pdf"}}
+
+	found := Find(listing, selected)
+	if len(found) != 2 || found[0].Name != "Sony - PlayStation (2024-01-01).dat" || found[1].Name != "Extras/index.XML" {
+		t.Errorf("expected the DATs next to the selection, got %+v", found)
+	}
+}
+
+func TestCovers(t *testing.T) {
+	d := mustParse(t, redumpDAT)
+	files := []parser.FileInfo{
+		{Name: "Crash Bandicoot (USA).zip"},
+		{Name: "Disc 1/Final Fantasy VII (USA) (Disc 1).zip"},
+		{Name: "Unknown Game (USA).zip"},
+	}
+	if got := d.Covers(files); got != 2 {
+		t.Errorf("expected 2 files covered, got %d", got)
+	}
+}
+
+func TestGame_Serials(t *testing.T) {
+	tests := []struct {
+		serial   string
+		expected []string
+	}{
+		{"", nil},
+		{"SCUS-94900", []string{"SCUS-94900"}},
+		{"SCUS-94163, SCUS-94163GH", []string{"SCUS-94163", "SCUS-94163GH"}},
+		{" SLES-00001 ,, ", []string{"SLES-00001"}},
+	}
+
+	for _, tt := range tests {
+		g := Game{Serial: tt.serial}
+		got := g.Serials()
+		if len(got) != len(tt.expected) {
+			t.Errorf("Serials(%q) = %v, expected %v", tt.serial, got, tt.expected)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.expected[i] {
+				t.Errorf("Serials(%q)[%d] = %q, expected %q", tt.serial, i, got[i], tt.expected[i])
+			}
+		}
+	}
+}
+
+func listing() []parser.FileInfo {
+	return []parser.FileInfo{
+		{Name: "Crash Bandicoot (USA).zip"},
+		{Name: "Crash Bandicoot (USA) (Rev 1).zip"},
+		{Name: "Final Fantasy VII (USA) (Disc 1).zip"},
+		{Name: "Demo Disc (Europe).zip"},
+		{Name: "Not In DAT (Japan).zip"},
+	}
+}
+
+func names(files []parser.FileInfo) []string {
+	var out []string
+	for _, f := range files {
+		out = append(out, f.Name)
+	}
+	return out
+}
+
+func TestFilterBySerial(t *testing.T) {
+	d := mustParse(t, redumpDAT)
+
+	tests := []struct {
+		name     string
+		patterns []string
+		expected []string
+	}{
+		{
+			name:     "no patterns keeps everything",
+			patterns: nil,
+			expected: names(listing()),
+		},
+		{
+			name:     "exact serial",
+			patterns: []string{"SCUS-94163"},
+			expected: []string{"Final Fantasy VII (USA) (Disc 1).zip"},
+		},
+		{
+			name:     "secondary serial",
+			patterns: []string{"scus-94163gh"},
+			expected: []string{"Final Fantasy VII (USA) (Disc 1).zip"},
+		},
+		{
+			name:     "glob serial",
+			patterns: []string{"SCUS-949*"},
+			expected: []string{"Crash Bandicoot (USA).zip", "Crash Bandicoot (USA) (Rev 1).zip"},
+		},
+		{
+			name:     "no match",
+			patterns: []string{"SLPS-*"},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := names(d.FilterBySerial(listing(), tt.patterns))
+			if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestDedupeBySerial(t *testing.T) {
+	d := mustParse(t, redumpDAT)
+
+	got := names(d.DedupeBySerial(listing()))
+	expected := []string{
+		"Crash Bandicoot (USA).zip",
+		"Final Fantasy VII (USA) (Disc 1).zip",
+		"Demo Disc (Europe).zip",
+		"Not In DAT (Japan).zip",
+	}
+
+	if strings.Join(got, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+const mameDAT = `<?xml version="1.0"?>
+<mame build="0.261">
+	<machine name="sf2">
+		<description>Street Fighter II: The World Warrior</description>
+		<rom name="sf2e_30g.11e" size="131072" crc="fe39ee33"/>
+		<device_ref name="qsound"/>
+		<device_ref name="z80"/>
+	</machine>
+	<machine name="sf2ua" cloneof="sf2" romof="sf2">
+		<description>Street Fighter II: The World Warrior (USA 910206)</description>
+		<rom name="sf2u_30a.11e" size="131072" crc="08beb861"/>
+		<rom name="sf2e_30g.11e" merge="sf2e_30g.11e" size="131072" crc="fe39ee33"/>
+		<device_ref name="qsound"/>
+	</machine>
+	<machine name="neogeo" isbios="yes">
+		<description>Neo-Geo</description>
+		<rom name="sp-s2.sp1" size="131072" crc="9036d879"/>
+	</machine>
+	<machine name="mslug" romof="neogeo">
+		<description>Metal Slug</description>
+		<rom name="201-p1.p1" size="2097152" crc="08d8daa5"/>
+		<device_ref name="ng_memcard"/>
+	</machine>
+	<machine name="qsound" isdevice="yes">
+		<description>Q-Sound</description>
+		<rom name="dl-1425.bin" size="8192" crc="d6cf5ef5"/>
+	</machine>
+	<machine name="z80" isdevice="yes">
+		<description>Zilog Z80</description>
+	</machine>
+	<machine name="ng_memcard" isdevice="yes">
+		<description>Neo Geo Memory Card</description>
+	</machine>
+</mame>`
+
+func TestParse_MAMEMachines(t *testing.T) {
+	d := mustParse(t, mameDAT)
+
+	if len(d.Games) != 7 {
+		t.Fatalf("expected 7 machines, got %d", len(d.Games))
+	}
+	if !d.IsMAME() {
+		t.Error("expected MAME DAT to be detected")
+	}
+	if mustParse(t, redumpDAT).IsMAME() {
+		t.Error("expected Redump DAT not to be detected as MAME")
+	}
+}
+
+func TestDetectSetType(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected SetType
+	}{
+		{"https://myrient.erista.me/files/MAME/ROMs%20(merged)/", SetMerged},
+		{"https://myrient.erista.me/files/MAME/ROMs%20(non-merged)/", SetNonMerged},
+		{"https://example.com/fbnarcade-fullnonmerged/arcade/", SetNonMerged},
+		{"https://myrient.erista.me/files/MAME/ROMs%20(split)/", SetSplit},
+		{"https://myrient.erista.me/files/MAME/", SetSplit},
+	}
+
+	for _, tt := range tests {
+		if got := DetectSetType(tt.name); got != tt.expected {
+			t.Errorf("DetectSetType(%q) = %s, expected %s", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestParseSetType(t *testing.T) {
+	if st, err := ParseSetType("Non-Merged"); err != nil || st != SetNonMerged {
+		t.Errorf("expected non-merged, got %s (err %v)", st, err)
+	}
+	if _, err := ParseSetType("bogus"); err == nil {
+		t.Error("expected error for unknown set type")
+	}
+}
+
+func TestRequirements(t *testing.T) {
+	d := mustParse(t, mameDAT)
+
+	tests := []struct {
+		game     string
+		setType  SetType
+		expected []string
+	}{
+		{"sf2ua", SetSplit, []string{"sf2", "qsound"}},
+		{"sf2", SetSplit, []string{"qsound"}},
+		{"mslug", SetMerged, []string{"neogeo"}},
+		{"mslug", SetNonMerged, nil},
+		{"sf2ua", SetNonMerged, []string{"qsound"}},
+		{"unknown", SetSplit, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.game+"/"+string(tt.setType), func(t *testing.T) {
+			got := d.Requirements(tt.game, tt.setType)
+			if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestWithDependencies(t *testing.T) {
+	d := mustParse(t, mameDAT)
+
+	listing := []parser.FileInfo{
+		{Name: "sf2.zip"},
+		{Name: "sf2ua.zip"},
+		{Name: "mslug.zip"},
+		{Name: "neogeo.zip"},
+	}
+	selected := []parser.FileInfo{{Name: "sf2ua.zip"}, {Name: "mslug.zip"}}
+
+	got, missing := d.WithDependencies(selected, listing, SetSplit)
+
+	expected := []string{"sf2ua.zip", "mslug.zip", "sf2.zip", "neogeo.zip"}
+	if strings.Join(names(got), "|") != strings.Join(expected, "|") {
+		t.Errorf("expected %v, got %v", expected, names(got))
+	}
+
+	if len(missing) != 1 || missing[0].Required != "qsound" || missing[0].Game != "sf2ua" {
+		t.Errorf("expected qsound to be missing for sf2ua, got %+v", missing)
+	}
+}
+
+func TestCheckSet(t *testing.T) {
+	d := mustParse(t, mameDAT)
+
+	selected := []parser.FileInfo{
+		{Name: "sf2ua.zip"},
+		{Name: "sf2.zip"},
+		{Name: "qsound.zip"},
+		{Name: "mslug.zip"},
+	}
+
+	report := d.CheckSet(selected, SetSplit)
+	if report.Working != 3 {
+		t.Errorf("expected 3 working games, got %d", report.Working)
+	}
+	if missing := report.Incomplete["mslug"]; len(missing) != 1 || missing[0] != "neogeo" {
+		t.Errorf("expected mslug to miss neogeo, got %v", report.Incomplete)
+	}
+}
+
+func TestGame_Status(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []string
+		expected string
+	}{
+		{"no roms", nil, StatusGood},
+		{"unmarked", []string{"", ""}, StatusGood},
+		{"all verified", []string{"verified", "Verified"}, StatusVerified},
+		{"partially verified", []string{"verified", ""}, StatusGood},
+		{"bad dump", []string{"verified", "baddump"}, StatusBadDump},
+		{"no dump wins", []string{"baddump", "nodump"}, StatusNoDump},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var g Game
+			for _, s := range tt.statuses {
+				g.ROMs = append(g.ROMs, ROM{Status: s})
+			}
+			if got := g.Status(); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+const statusDAT = `<datafile>
+	<game name="Good Game (USA)"><category>Games</category><rom name="a.bin" status="verified"/></game>
+	<game name="Bad Game (USA)"><category>Games</category><rom name="b.bin" status="baddump"/></game>
+	<game name="Missing Game (USA)"><category>Games</category><rom name="c.bin" status="nodump"/></game>
+	<game name="Some Demo (USA)"><category>Demos</category><rom name="d.bin"/></game>
+</datafile>`
+
+func TestValidateStatuses(t *testing.T) {
+	if err := ValidateStatuses("--status", []string{"verified", "Good", "BADDUMP", "nodump"}); err != nil {
+		t.Errorf("expected known statuses to be accepted, got %v", err)
+	}
+	err := ValidateStatuses("--exclude-status", []string{"good", "bad-dump", "verfied"})
+	if err == nil {
+		t.Fatal("expected error for unknown statuses")
+	}
+	for _, want := range []string{`"bad-dump"`, `"verfied"`, "--exclude-status", "verified, good, baddump, or nodump"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %q", want, err)
+		}
+	}
+}
+
+func TestFilterByMetadata(t *testing.T) {
+	d := mustParse(t, statusDAT)
+
+	files := []parser.FileInfo{
+		{Name: "Good Game (USA).zip"},
+		{Name: "Bad Game (USA).zip"},
+		{Name: "Missing Game (USA).zip"},
+		{Name: "Some Demo (USA).zip"},
+		{Name: "Unknown (USA).zip"},
+	}
+
+	tests := []struct {
+		name     string
+		criteria Criteria
+		expected []string
+	}{
+		{
+			name:     "no criteria",
+			criteria: Criteria{},
+			expected: names(files),
+		},
+		{
+			name:     "only verified",
+			criteria: Criteria{Statuses: []string{"verified"}},
+			expected: []string{"Good Game (USA).zip"},
+		},
+		{
+			name:     "exclude bad and missing dumps",
+			criteria: Criteria{ExcludeStatuses: []string{"baddump", "NODUMP"}},
+			expected: []string{"Good Game (USA).zip", "Some Demo (USA).zip", "Unknown (USA).zip"},
+		},
+		{
+			name:     "only games category",
+			criteria: Criteria{Categories: []string{"games"}},
+			expected: []string{"Good Game (USA).zip", "Bad Game (USA).zip", "Missing Game (USA).zip"},
+		},
+		{
+			name:     "exclude demos",
+			criteria: Criteria{ExcludeCategories: []string{"Demos"}},
+			expected: []string{"Good Game (USA).zip", "Bad Game (USA).zip", "Missing Game (USA).zip", "Unknown (USA).zip"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, decisions := d.FilterByMetadata(files, tt.criteria)
+			if strings.Join(names(kept), "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("expected %v, got %v", tt.expected, names(kept))
+			}
+			if len(decisions) != len(files) {
+				t.Errorf("expected %d decisions, got %d", len(files), len(decisions))
+			}
+		})
+	}
+}
+
+func TestEvaluate_Reason(t *testing.T) {
+	d := mustParse(t, statusDAT)
+
+	decision := d.Evaluate(parser.FileInfo{Name: "Bad Game (USA).zip"}, Criteria{ExcludeStatuses: []string{"baddump"}})
+	if decision.Keep {
+		t.Error("expected bad dump to be dropped")
+	}
+	if !strings.Contains(decision.Reason, "status=baddump") || !strings.Contains(decision.Reason, "excluded") {
+		t.Errorf("unexpected reason %q", decision.Reason)
+	}
+}
+
+const verifyDAT = `<?xml version="1.0"?>
+<datafile>
+	<game name="Game (USA)">
+		<rom name="Game (USA).nes" size="5" crc="3610a686" sha1="aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"/>
+	</game>
+	<game name="Disc (USA)">
+		<rom name="Disc (USA).cue" size="5" crc="3610a686"/>
+		<rom name="Disc (USA).bin" size="5" crc="3a771143"/>
+		<rom name="Disc (USA) (Track 2).bin" size="0" status="nodump"/>
+	</game>
+</datafile>`
+
+// writeZip creates a zip archive of the given members in dir
+func writeZip(t *testing.T, dir, name string, members map[string]string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	f, err := os.Create(p) //nolint:gosec // Test file path is safe (from t.TempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	for member, content := range members {
+		fw, err := w.Create(member)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestVerify(t *testing.T) {
+	d := mustParse(t, verifyDAT)
+	dir := t.TempDir()
+	bare := filepath.Join(dir, "bare.tmp")
+	if err := os.WriteFile(bare, []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		filename string
+		path     string
+		verified int
+		problems []string
+		skipped  bool
+	}{
+		{
+			name:     "matching zip",
+			filename: "Game (USA).zip",
+			path:     writeZip(t, dir, "good.zip", map[string]string{"Game (USA).nes": "hello"}),
+			verified: 1,
+		},
+		{
+			name:     "wrong contents",
+			filename: "Game (USA).zip",
+			path:     writeZip(t, dir, "bad.zip", map[string]string{"Game (USA).nes": "world"}),
+			problems: []string{"Game (USA).nes has CRC32 3a771143, expected 3610a686"},
+		},
+		{
+			name:     "missing and unexpected members",
+			filename: "Disc (USA).zip",
+			path:     writeZip(t, dir, "disc.zip", map[string]string{"Disc (USA).cue": "hello", "readme.txt": "hi"}),
+			verified: 1,
+			problems: []string{"Disc (USA).bin is missing", "readme.txt is not in the DAT"},
+		},
+		{
+			name:     "bare file",
+			filename: "Game (USA).nes",
+			path:     bare,
+			verified: 1,
+		},
+		{
+			name:     "other archive",
+			filename: "Game (USA).7z",
+			path:     bare,
+			skipped:  true,
+		},
+		{
+			name:     "no DAT entry",
+			filename: "Other (USA).zip",
+			path:     bare,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := d.Verify(tt.filename, tt.path)
+			if err != nil {
+				t.Fatalf("Verify failed: %v", err)
+			}
+			if v.Verified != tt.verified || (v.Skipped != "") != tt.skipped {
+				t.Errorf("unexpected verification %+v", v)
+			}
+			if strings.Join(v.Problems, "|") != strings.Join(tt.problems, "|") {
+				t.Errorf("expected problems %q, got %q", tt.problems, v.Problems)
+			}
+			if v.OK() != (len(tt.problems) == 0) {
+				t.Errorf("expected OK() = %v", len(tt.problems) == 0)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package dat
+
+import (
+	"path"
+	"strings"
+
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// IsDATFile reports whether a listed file looks like a Logiqx XML DAT
+func IsDATFile(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".dat", ".xml":
+		return true
+	default:
+		return false
+	}
+}
+
+// Find returns the DAT files in listing that sit in the same directory as any
+// of the selected files, where a collection publishes the DAT for its set
+func Find(listing, selected []parser.FileInfo) []parser.FileInfo {
+	dirs := make(map[string]bool)
+	for _, f := range selected {
+		dirs[path.Dir(f.Name)] = true
+	}
+
+	var found []parser.FileInfo
+	for _, f := range listing {
+		if IsDATFile(f.Name) && dirs[path.Dir(f.Name)] {
+			found = append(found, f)
+		}
+	}
+	return found
+}
+
+// Covers returns how many of files have an entry in the DAT
+func (d *Datafile) Covers(files []parser.FileInfo) int {
+	n := 0
+	for _, f := range files {
+		if _, ok := d.Lookup(path.Base(f.Name)); ok {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,196 @@
+package dat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// SetType describes how ROMs are distributed across archives in a MAME set
+type SetType string
+
+// Supported MAME set types
+const (
+	SetSplit     SetType = "split"      // Clones hold only their unique ROMs; parents and BIOSes are separate archives
+	SetMerged    SetType = "merged"     // Clones live inside their parent's archive; BIOSes are separate archives
+	SetNonMerged SetType = "non-merged" // Every archive is self-contained apart from device ROMs
+)
+
+// ParseSetType parses a set type name as accepted on the command line
+func ParseSetType(s string) (SetType, error) {
+	switch strings.ToLower(s) {
+	case "split":
+		return SetSplit, nil
+	case "merged":
+		return SetMerged, nil
+	case "non-merged", "nonmerged":
+		return SetNonMerged, nil
+	default:
+		return "", fmt.Errorf("unknown set type %q (expected split, merged, or non-merged)", s)
+	}
+}
+
+// DetectSetType guesses the set type from a collection URL or name.
+// Myrient labels MAME collections like "MAME - ROMs (merged)" or "fullnonmerged";
+// split is assumed when nothing matches since it is the most common layout.
+func DetectSetType(name string) SetType {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.Contains(lower, "non-merged"), strings.Contains(lower, "nonmerged"),
+		strings.Contains(lower, "non%20merged"):
+		return SetNonMerged
+	case strings.Contains(lower, "merged"):
+		return SetMerged
+	default:
+		return SetSplit
+	}
+}
+
+// IsMAME reports whether the DAT carries parent/clone, BIOS, or device relationships
+func (d *Datafile) IsMAME() bool {
+	for i := range d.Games {
+		g := &d.Games[i]
+		if g.CloneOf != "" || g.RomOf != "" || g.IsBIOS == "yes" || len(g.DeviceRefs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Requirements returns the names of the archives that must be present for a game
+// to work in the given set type: its parent chain and BIOS (split and merged sets)
+// plus any referenced devices that carry ROMs of their own.
+func (d *Datafile) Requirements(name string, t SetType) []string {
+	var required []string
+	seen := map[string]bool{name: true}
+
+	add := func(n string) bool {
+		if n == "" || seen[n] {
+			return false
+		}
+		seen[n] = true
+		required = append(required, n)
+		return true
+	}
+
+	game, ok := d.byName[name]
+	if !ok {
+		return nil
+	}
+
+	// Parent and BIOS chain (romof points at the parent, which in turn points at the BIOS)
+	if t != SetNonMerged {
+		for g := game; g != nil && g.RomOf != ""; {
+			if !add(g.RomOf) {
+				break
+			}
+			g = d.byName[g.RomOf]
+		}
+	}
+
+	// Device ROMs are never merged into game archives
+	queue := []*Game{game}
+	for len(queue) > 0 {
+		g := queue[0]
+		queue = queue[1:]
+		for _, ref := range g.DeviceRefs {
+			dev, ok := d.byName[ref.Name]
+			if !ok || seen[ref.Name] {
+				continue
+			}
+			if len(dev.ROMs) > 0 {
+				add(ref.Name)
+			} else {
+				seen[ref.Name] = true
+			}
+			queue = append(queue, dev)
+		}
+	}
+
+	return required
+}
+
+// MissingSet records a required archive that is absent from the remote listing
+type MissingSet struct {
+	Game     string
+	Required string
+}
+
+// WithDependencies extends the selection with the parent, BIOS, and device archives its
+// games require, taken from the full listing. Requirements that the listing does not
+// contain are returned as missing.
+func (d *Datafile) WithDependencies(selected, listing []parser.FileInfo, t SetType) ([]parser.FileInfo, []MissingSet) {
+	byGame := make(map[string]parser.FileInfo, len(listing))
+	for _, f := range listing {
+		if g, ok := d.Lookup(f.Name); ok {
+			byGame[g.Name] = f
+		}
+	}
+
+	result := append([]parser.FileInfo(nil), selected...)
+	included := make(map[string]bool, len(selected))
+	for _, f := range selected {
+		included[f.Name] = true
+	}
+
+	var missing []MissingSet
+	for _, f := range selected {
+		game, ok := d.Lookup(f.Name)
+		if !ok {
+			continue
+		}
+		for _, req := range d.Requirements(game.Name, t) {
+			dep, ok := byGame[req]
+			if !ok {
+				missing = append(missing, MissingSet{Game: game.Name, Required: req})
+				continue
+			}
+			if !included[dep.Name] {
+				included[dep.Name] = true
+				result = append(result, dep)
+			}
+		}
+	}
+
+	return result, missing
+}
+
+// SetReport summarizes whether a selection forms a working set
+type SetReport struct {
+	Type       SetType
+	Working    int
+	Incomplete map[string][]string // game name -> missing archives
+}
+
+// CheckSet reports, for every selected game known to the DAT, whether all archives it
+// needs in the given set type are part of the selection.
+func (d *Datafile) CheckSet(selected []parser.FileInfo, t SetType) SetReport {
+	present := make(map[string]bool, len(selected))
+	for _, f := range selected {
+		if g, ok := d.Lookup(f.Name); ok {
+			present[g.Name] = true
+		}
+	}
+
+	report := SetReport{Type: t, Incomplete: make(map[string][]string)}
+	for _, f := range selected {
+		game, ok := d.Lookup(f.Name)
+		if !ok {
+			continue
+		}
+		var absent []string
+		for _, req := range d.Requirements(game.Name, t) {
+			if !present[req] {
+				absent = append(absent, req)
+			}
+		}
+		if len(absent) > 0 {
+			report.Incomplete[game.Name] = absent
+		} else {
+			report.Working++
+		}
+	}
+
+	return report
+}
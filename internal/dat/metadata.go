@@ -0,0 +1,139 @@
+package dat
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// Dump statuses derived from ROM entries
+const (
+	StatusGood     = "good"
+	StatusVerified = "verified"
+	StatusBadDump  = "baddump"
+	StatusNoDump   = "nodump"
+)
+
+// ValidateStatuses checks the dump statuses given to flag, reporting every
+// unknown one with the statuses it accepts
+func ValidateStatuses(flag string, values []string) error {
+	var errs []error
+	for _, v := range values {
+		switch strings.ToLower(v) {
+		case StatusVerified, StatusGood, StatusBadDump, StatusNoDump:
+		default:
+			errs = append(errs, fmt.Errorf("invalid %s value %q (expected %s, %s, %s, or %s)",
+				flag, v, StatusVerified, StatusGood, StatusBadDump, StatusNoDump))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Status summarizes the dump status of a game from its ROMs.
+// The worst status wins: any nodump ROM makes the game "nodump", then "baddump";
+// a game is "verified" only when every ROM is, and "good" otherwise.
+func (g *Game) Status() string {
+	verified := len(g.ROMs) > 0
+	bad := false
+	for _, rom := range g.ROMs {
+		switch strings.ToLower(rom.Status) {
+		case StatusNoDump:
+			return StatusNoDump
+		case StatusBadDump:
+			bad = true
+		case StatusVerified:
+		default:
+			verified = false
+		}
+	}
+
+	switch {
+	case bad:
+		return StatusBadDump
+	case verified:
+		return StatusVerified
+	default:
+		return StatusGood
+	}
+}
+
+// Criteria selects files by DAT category and dump status.
+// Values are compared case-insensitively; empty include lists accept everything.
+type Criteria struct {
+	Categories        []string
+	ExcludeCategories []string
+	Statuses          []string
+	ExcludeStatuses   []string
+}
+
+// IsZero reports whether no category or status filters are set
+func (c Criteria) IsZero() bool {
+	return len(c.Categories) == 0 && len(c.ExcludeCategories) == 0 &&
+		len(c.Statuses) == 0 && len(c.ExcludeStatuses) == 0
+}
+
+// Decision records why a file was kept or dropped by DAT metadata filtering
+type Decision struct {
+	File   parser.FileInfo
+	Keep   bool
+	Reason string
+}
+
+// Evaluate decides whether a file passes the criteria based on its DAT entry
+func (d *Datafile) Evaluate(file parser.FileInfo, c Criteria) Decision {
+	game, ok := d.Lookup(file.Name)
+	if !ok {
+		if len(c.Categories) > 0 || len(c.Statuses) > 0 {
+			return Decision{File: file, Keep: false, Reason: "not in DAT"}
+		}
+		return Decision{File: file, Keep: true, Reason: "not in DAT"}
+	}
+
+	category := game.Category
+	status := game.Status()
+	facts := fmt.Sprintf("category=%q status=%s", category, status)
+
+	switch {
+	case len(c.Categories) > 0 && !containsFold(c.Categories, category):
+		return Decision{File: file, Keep: false, Reason: facts + ": category not included"}
+	case containsFold(c.ExcludeCategories, category):
+		return Decision{File: file, Keep: false, Reason: facts + ": category excluded"}
+	case len(c.Statuses) > 0 && !containsFold(c.Statuses, status):
+		return Decision{File: file, Keep: false, Reason: facts + ": status not included"}
+	case containsFold(c.ExcludeStatuses, status):
+		return Decision{File: file, Keep: false, Reason: facts + ": status excluded"}
+	default:
+		return Decision{File: file, Keep: true, Reason: facts}
+	}
+}
+
+// FilterByMetadata applies the criteria to a list of files, returning the kept files
+// and the decision made for every input file
+func (d *Datafile) FilterByMetadata(files []parser.FileInfo, c Criteria) ([]parser.FileInfo, []Decision) {
+	var (
+		kept      []parser.FileInfo
+		decisions = make([]Decision, 0, len(files))
+	)
+
+	for _, file := range files {
+		decision := d.Evaluate(file, c)
+		decisions = append(decisions, decision)
+		if decision.Keep {
+			kept = append(kept, file)
+		}
+	}
+
+	return kept, decisions
+}
+
+// containsFold checks if the list contains the value, ignoring case
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
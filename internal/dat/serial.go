@@ -0,0 +1,81 @@
+package dat
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// FilterBySerial keeps only files whose DAT entry has a serial matching any of the patterns.
+// Patterns use glob syntax and are matched case-insensitively. Files without a DAT entry
+// or without a serial are dropped, since their serial cannot be known.
+func (d *Datafile) FilterBySerial(files []parser.FileInfo, patterns []string) []parser.FileInfo {
+	if len(patterns) == 0 {
+		return files
+	}
+
+	var filtered []parser.FileInfo
+	for _, file := range files {
+		game, ok := d.Lookup(file.Name)
+		if !ok {
+			continue
+		}
+		if matchesSerial(game.Serials(), patterns) {
+			filtered = append(filtered, file)
+		}
+	}
+
+	return filtered
+}
+
+// DedupeBySerial drops files that share a serial with an earlier file in the list.
+// Disc-based sets often carry the same disc under several regional names; the first
+// occurrence wins. Files without a DAT entry or serial are always kept.
+func (d *Datafile) DedupeBySerial(files []parser.FileInfo) []parser.FileInfo {
+	seen := make(map[string]bool)
+	var deduped []parser.FileInfo
+
+	for _, file := range files {
+		game, ok := d.Lookup(file.Name)
+		if !ok {
+			deduped = append(deduped, file)
+			continue
+		}
+
+		serials := game.Serials()
+		duplicate := false
+		for _, s := range serials {
+			if seen[strings.ToUpper(s)] {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+
+		for _, s := range serials {
+			seen[strings.ToUpper(s)] = true
+		}
+		deduped = append(deduped, file)
+	}
+
+	return deduped
+}
+
+// matchesSerial checks if any serial matches any of the glob patterns
+func matchesSerial(serials, patterns []string) bool {
+	for _, serial := range serials {
+		for _, pattern := range patterns {
+			matched, err := filepath.Match(strings.ToUpper(pattern), strings.ToUpper(serial))
+			if err != nil {
+				continue // Skip invalid patterns
+			}
+			if matched {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,195 @@
+package dat
+
+import (
+	"archive/zip"
+	"crypto/sha1" //nolint:gosec // SHA-1 is what DATs record, not used for security
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// VerifyMode decides what happens to downloads that don't match their DAT entry
+type VerifyMode string
+
+// Supported verify modes
+const (
+	VerifyStrict VerifyMode = "strict" // Treat the download as corrupt: fetch it again, then fail
+	VerifyWarn   VerifyMode = "warn"   // Keep it, with a warning
+	VerifyOff    VerifyMode = "off"    // Don't check downloads
+)
+
+// ParseVerifyMode parses a verify mode name as accepted on the command line
+func ParseVerifyMode(s string) (VerifyMode, error) {
+	switch m := VerifyMode(strings.ToLower(s)); m {
+	case VerifyStrict, VerifyWarn, VerifyOff:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown DAT verify mode %q (expected strict, warn, or off)", s)
+	}
+}
+
+// Verification is the result of checking a downloaded file against its DAT entry
+type Verification struct {
+	Game     string   // DAT game name; empty if the file has no entry
+	Verified int      // ROMs whose size and hashes match
+	Problems []string // Missing, unexpected, or mismatched ROMs
+	Skipped  string   // Why the file couldn't be checked, e.g. an unsupported archive
+}
+
+// OK reports whether every ROM matched
+func (v Verification) OK() bool {
+	return len(v.Problems) == 0
+}
+
+// member is a file inside a download, with what the archive already knows of it
+type member struct {
+	name string
+	size int64
+	crc  uint32
+	open func() (io.ReadCloser, error)
+}
+
+// Verify checks a downloaded file against the DAT entry for its listing name.
+// Zip archives must hold exactly the entry's ROMs: each member's size and the
+// CRC32 stored in the archive are compared without decompressing, and the SHA-1
+// is computed when the DAT has one. Any other file must be the entry's only ROM;
+// other archive formats are skipped. ROMs marked nodump are not expected. A
+// file without an entry returns a zero Verification.
+func (d *Datafile) Verify(filename, filePath string) (Verification, error) {
+	game, ok := d.Lookup(path.Base(filename))
+	if !ok {
+		return Verification{}, nil
+	}
+	v := Verification{Game: game.Name}
+
+	members, closeFile, err := openMembers(filePath)
+	if err != nil {
+		return v, err
+	}
+	defer closeFile()
+	if members == nil {
+		switch ext := strings.ToLower(path.Ext(filename)); {
+		case otherArchives[ext]:
+			v.Skipped = ext + " archives can't be checked"
+			return v, nil
+		case len(game.ROMs) != 1:
+			v.Skipped = fmt.Sprintf("not a zip archive, but the DAT lists %d ROMs", len(game.ROMs))
+			return v, nil
+		}
+		members = []member{bareFile(filePath)}
+	}
+
+	byName := make(map[string]member, len(members))
+	for _, m := range members {
+		byName[strings.ToLower(m.name)] = m
+	}
+	expected := make(map[string]bool, len(game.ROMs))
+	for _, rom := range game.ROMs {
+		if rom.Status == "nodump" {
+			continue
+		}
+		name := strings.ReplaceAll(rom.Name, `\`, "/")
+		expected[strings.ToLower(name)] = true
+
+		m, ok := byName[strings.ToLower(name)]
+		if !ok && len(members) == 1 && members[0].size < 0 {
+			m, ok = members[0], true // A bare file is named after the listing, not its ROM
+		}
+		if !ok {
+			v.Problems = append(v.Problems, fmt.Sprintf("%s is missing", rom.Name))
+			continue
+		}
+		expected[strings.ToLower(m.name)] = true
+		if problem, err := checkROM(rom, m); err != nil {
+			return v, err
+		} else if problem != "" {
+			v.Problems = append(v.Problems, problem)
+			continue
+		}
+		v.Verified++
+	}
+	for _, m := range members {
+		if !expected[strings.ToLower(m.name)] {
+			v.Problems = append(v.Problems, fmt.Sprintf("%s is not in the DAT", m.name))
+		}
+	}
+	return v, nil
+}
+
+// checkROM compares a member against a ROM entry and describes any difference
+func checkROM(rom ROM, m member) (string, error) {
+	if rom.Size > 0 && m.size >= 0 && m.size != rom.Size {
+		return fmt.Sprintf("%s is %d bytes, expected %d", rom.Name, m.size, rom.Size), nil
+	}
+	if rom.CRC != "" && m.size >= 0 {
+		if got := fmt.Sprintf("%08x", m.crc); !strings.EqualFold(got, rom.CRC) {
+			return fmt.Sprintf("%s has CRC32 %s, expected %s", rom.Name, got, strings.ToLower(rom.CRC)), nil
+		}
+	}
+	if m.size >= 0 && rom.CRC != "" && rom.SHA1 == "" {
+		return "", nil
+	}
+
+	// Bare files and SHA-1s need the contents
+	r, err := m.open()
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = r.Close() }()
+	sha, crc := sha1.New(), crc32.NewIEEE() //nolint:gosec // See import
+	size, err := io.Copy(io.MultiWriter(sha, crc), r)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", m.name, err)
+	}
+	switch {
+	case rom.Size > 0 && size != rom.Size:
+		return fmt.Sprintf("%s is %d bytes, expected %d", rom.Name, size, rom.Size), nil
+	case rom.CRC != "" && !strings.EqualFold(hex.EncodeToString(crc.Sum(nil)), rom.CRC):
+		return fmt.Sprintf("%s has CRC32 %x, expected %s", rom.Name, crc.Sum(nil), strings.ToLower(rom.CRC)), nil
+	case rom.SHA1 != "" && !strings.EqualFold(hex.EncodeToString(sha.Sum(nil)), rom.SHA1):
+		return fmt.Sprintf("%s has SHA-1 %x, expected %s", rom.Name, sha.Sum(nil), strings.ToLower(rom.SHA1)), nil
+	}
+	return "", nil
+}
+
+// otherArchives are archive formats whose members can't be checked
+var otherArchives = map[string]bool{".7z": true, ".rar": true}
+
+// bareFile is a download that isn't an archive, checked as a single ROM. Its
+// size is -1 until read.
+func bareFile(filePath string) member {
+	open := func() (io.ReadCloser, error) { return os.Open(filePath) } //nolint:gosec // Path is a file we just downloaded
+	return member{name: filepath.Base(filePath), size: -1, open: open}
+}
+
+// openMembers lists the members of a zip archive, or returns nil members if
+// the file isn't one
+func openMembers(filePath string) ([]member, func(), error) {
+	r, err := zip.OpenReader(filePath)
+	if errors.Is(err, zip.ErrFormat) {
+		return nil, func() {}, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open %s: %w", filePath, err)
+	}
+
+	members := []member{}
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		members = append(members, member{
+			name: f.Name,
+			size: int64(f.UncompressedSize64), //nolint:gosec // ROM sizes fit in an int64
+			crc:  f.CRC32,
+			open: f.Open,
+		})
+	}
+	return members, func() { _ = r.Close() }, nil
+}
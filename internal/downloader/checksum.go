@@ -0,0 +1,108 @@
+package downloader
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/nchapman/myrient-dl/internal/hashing"
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// Checksum is a published digest a download must match
+type Checksum struct {
+	Algorithm hashing.Algorithm
+	Digest    string // Lowercase hex
+}
+
+// checksum returns the published digest of a file, if any
+func (d *Downloader) checksum(file parser.FileInfo) (Checksum, bool) {
+	if d.config.Checksums == nil {
+		return Checksum{}, false
+	}
+	return d.config.Checksums(file)
+}
+
+// checksumHash returns a hash for the file's published digest, already fed
+// the first offset bytes of the temp file, or nil if the file has none
+func (d *Downloader) checksumHash(file parser.FileInfo, tempPath string, offset int64) (hash.Hash, error) {
+	sum, ok := d.checksum(file)
+	if !ok {
+		return nil, nil
+	}
+	h := sum.Algorithm.New()
+	if offset == 0 {
+		return h, nil
+	}
+
+	f, err := os.Open(tempPath) //nolint:gosec // Path is derived from the output directory
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	if _, err := io.CopyN(h, f, offset); err != nil {
+		return nil, fmt.Errorf("failed to hash %s: %w", tempPath, err)
+	}
+	return h, nil
+}
+
+// verifyChecksum compares a download against its published digest, taken
+// from h if it was computed while streaming or else from the file at path
+func (d *Downloader) verifyChecksum(file parser.FileInfo, path string, h hash.Hash) error {
+	sum, ok := d.checksum(file)
+	if !ok {
+		return nil
+	}
+
+	var got string
+	if h != nil {
+		got = hex.EncodeToString(h.Sum(nil))
+	} else {
+		var err error
+		if got, err = hashing.File(path, sum.Algorithm); err != nil {
+			return err
+		}
+	}
+	if got != sum.Digest {
+		return fmt.Errorf("%w: %s %s is %s, expected %s", ErrCorrupt, filepath.Base(file.Name), sum.Algorithm, got, sum.Digest)
+	}
+	if d.config.Verbose {
+		fmt.Printf("  ✓ %s matches the published checksum\n", sum.Algorithm)
+	}
+	return nil
+}
+
+// Get fetches a small file, such as a checksum file, into memory. Files larger
+// than limit bytes are an error.
+func (d *Downloader) Get(ctx context.Context, fileURL string, limit int64) ([]byte, error) {
+	req, err := d.newRequest(ctx, http.MethodGet, fileURL)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("file is larger than %d bytes", limit)
+	}
+	return data, nil
+}
@@ -0,0 +1,60 @@
+package downloader
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+
+	"github.com/nchapman/myrient-dl/internal/fsutil"
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// dispositionFilename returns the filename from a Content-Disposition header, if any
+func dispositionFilename(h http.Header) string {
+	header := h.Get("Content-Disposition")
+	if header == "" {
+		return ""
+	}
+
+	_, params, err := mime.ParseMediaType(header)
+	if err != nil {
+		return ""
+	}
+	// filename* (RFC 5987) is decoded into "filename" by ParseMediaType
+	return params["filename"]
+}
+
+// localName decides where a file is saved: its served name placed by the
+// configured layout
+func (d *Downloader) localName(file parser.FileInfo, served string) string {
+	name := d.servedName(file, served)
+	if d.config.Layout != nil {
+		name = filepath.FromSlash(d.config.Layout(filepath.ToSlash(name)))
+	}
+	return name
+}
+
+// servedName decides what a file is called when the server names it differently
+// from the listing. The served name is sanitized and kept in the listed file's directory.
+func (d *Downloader) servedName(file parser.FileInfo, served string) string {
+	listed := filepath.Base(file.Name)
+	if served == "" {
+		return file.Name
+	}
+
+	sanitized := fsutil.SanitizeFilename(served)
+	if sanitized == "" || sanitized == listed {
+		return file.Name
+	}
+
+	if !d.config.HonorContentDisposition {
+		fmt.Printf("  ⚠ Server names this file %q (saving as listed: %q)\n", served, listed)
+		return file.Name
+	}
+
+	if d.config.Verbose {
+		fmt.Printf("  Saving as %q (server-provided name)\n", sanitized)
+	}
+	return filepath.Join(filepath.Dir(file.Name), sanitized)
+}
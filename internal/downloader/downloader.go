@@ -3,18 +3,27 @@ package downloader
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"math"
-	"math/rand/v2"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/nchapman/myrient-dl/internal/auth"
+	"github.com/nchapman/myrient-dl/internal/cleanup"
+	"github.com/nchapman/myrient-dl/internal/fsutil"
 	"github.com/nchapman/myrient-dl/internal/parser"
-	"github.com/schollz/progressbar/v3"
+	"github.com/nchapman/myrient-dl/internal/progress"
+	"github.com/nchapman/myrient-dl/internal/retry"
+	"github.com/nchapman/myrient-dl/internal/useragent"
 )
 
 // Config holds the downloader configuration
@@ -23,34 +32,373 @@ type Config struct {
 	Parallel      int
 	RetryAttempts int
 	Verbose       bool
+	// VerifyRetries is how many times a download that fails verification is
+	// discarded and fetched again, independent of RetryAttempts
+	VerifyRetries int
+	// Verifier, if set, checks each download before it is moved into place
+	Verifier Verifier
+	// Checksums, if set, returns the published digest of a file. It is computed
+	// as the file streams in and checked before the file is moved into place; a
+	// mismatch counts as a corrupt download and is retried like one.
+	Checksums func(file parser.FileInfo) (Checksum, bool)
+	// ContinueExisting treats existing files smaller than the remote as partial
+	// downloads and completes them with a Range request
+	ContinueExisting bool
+	// HonorContentDisposition saves files under the name the server gives in a
+	// Content-Disposition header instead of the listed name. Otherwise a differing
+	// name only produces a warning.
+	HonorContentDisposition bool
+	// Layout maps a slash-separated local name to where the file is saved in
+	// the output directory, e.g. a layout set by reorganize; nil keeps the name
+	Layout func(name string) string
+	// Placeholders decides what happens to zero-byte files and small HTML pages
+	// served in place of a listed file
+	Placeholders PlaceholderPolicy
+	// Mismatches decides what happens to an existing local file whose size
+	// differs from the remote. With MismatchAsk, AskMismatch is called for each
+	// such file; it is called from worker goroutines and must be safe for
+	// concurrent use.
+	Mismatches  MismatchPolicy
+	AskMismatch func(file parser.FileInfo, localSize, remoteSize int64) MismatchPolicy
+	// OnEvent, if set, is called as each file starts, completes, is skipped, or
+	// fails. It is called from worker goroutines and must be safe for concurrent use.
+	OnEvent func(Event)
+	// OnProgress, if set, is called each time a download's journal records more
+	// of the file as safely on disk, with the bytes so far. It is called from
+	// worker goroutines and must be safe for concurrent use.
+	OnProgress func(file parser.FileInfo, onDisk int64)
+	// Recorded, if set, returns where an earlier run saved a file and how big
+	// it was. A file still there at that size is skipped without asking the
+	// server, unless the listed size says it changed.
+	Recorded func(file parser.FileInfo) (path string, size int64, ok bool)
+	// Carried, if set, returns how much of a file an earlier run already got
+	// onto disk, which the ETA leaves out of the bytes still to go
+	Carried func(file parser.FileInfo) int64
+	// StartupRamp staggers the first download of each parallel worker by this
+	// much, so a burst of new connections doesn't trip server throttling
+	StartupRamp time.Duration
+	// RequestInterval is the minimum time between any two requests; 0 disables pacing
+	RequestInterval time.Duration
+	// RateLimit caps the combined download speed in bytes per second; 0 is unlimited
+	RateLimit int64
+	// BackoffBase and BackoffMax shape the exponential backoff between network
+	// retries (retry.Policy); zero values mean 1s and 30s
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// Mirrors, if set, returns alternate URLs for a file, tried in order once
+	// retries against its listed URL are exhausted
+	Mirrors func(fileURL string) []string
+	// Window, if set, only lets new files start within this daily time range.
+	// Downloads already running when it closes are finished, unless Suspend is set.
+	Window *Window
+	// IgnoreMissing reports files the server answers 404 or 410 for as gone
+	// upstream instead of retrying them and failing the run
+	IgnoreMissing bool
+	// Suspend pauses downloads still running when Window closes, keeping their
+	// temp files, and continues them once it opens again
+	Suspend bool
+	// ConnectTimeout bounds setting up a connection, TLS included; zero means 30s
+	ConnectTimeout time.Duration
+	// StallTimeout aborts a request when no response arrives within it, or when
+	// its body delivers less than StallSpeed bytes per second (any bytes at all
+	// for 0) over a whole StallTimeout. The attempt fails and is retried. Zero
+	// means one minute; a negative value disables it. There is no overall
+	// deadline, so huge files on slow links are never cut off.
+	StallTimeout time.Duration
+	StallSpeed   int64
+	// Auth, if set, adds credentials to every request and refreshes them when
+	// the server answers 401
+	Auth auth.Provider
+	// Identity sets every request's User-Agent, From, and extra headers
+	Identity useragent.Identity
+	// Progress makes each download's progress bar; nil draws them on the terminal
+	Progress progress.Reporter
+	// Logger, if set, records every request at debug level and each retry
+	// and suspension at warn and info
+	Logger *slog.Logger
+	// Segments, if over 1, splits large files into up to this many byte ranges
+	// downloaded over separate connections at once
+	Segments int
+	// SmallSlots keeps this many parallel workers for files under LargeFile
+	// bytes while any are waiting, so small files keep completing during long
+	// downloads; 0 or a LargeFile of 0 disables it
+	SmallSlots int
+	LargeFile  int64
+}
+
+// ErrStopped is returned by DownloadAll when Drain stopped it before every file was started
+var ErrStopped = errors.New("stopped before all files were downloaded")
+
+// ErrCorrupt marks downloads whose content failed verification, as opposed to network errors
+var ErrCorrupt = errors.New("corrupt download")
+
+// ErrRejected marks downloads a Verifier refuses to keep, e.g. blocklisted files. They are
+// discarded without retrying and reported separately from failures.
+var ErrRejected = errors.New("rejected")
+
+// ErrGone marks files the server no longer has (404 or 410), e.g. removed since
+// the listing was fetched
+var ErrGone = errors.New("gone upstream")
+
+// Verifier checks a fully downloaded temp file before it is moved into place.
+// Errors wrapping ErrCorrupt cause the file to be discarded and downloaded again;
+// errors wrapping ErrRejected discard it for good.
+type Verifier interface {
+	Verify(file parser.FileInfo, path string) error
+}
+
+// VerifierFunc adapts a function to the Verifier interface
+type VerifierFunc func(file parser.FileInfo, path string) error
+
+// Verify calls f(file, path)
+func (f VerifierFunc) Verify(file parser.FileInfo, path string) error {
+	return f(file, path)
+}
+
+// Summary reports the outcome of the most recent DownloadAll call
+type Summary struct {
+	Total         int
+	Completed     int // Includes skipped files
+	Skipped       int // Files already present locally
+	Placeholders  int // Zero-byte or placeholder files, not counted as completed
+	Rejected      int // Files the Verifier discarded with ErrRejected
+	Gone          int // Files missing upstream, with IgnoreMissing
+	Failed        int
+	Corrupt       int // Failed files whose last error was a verification failure
+	VerifyRetries int // Re-downloads triggered by failed verification
+	Continued     int // Existing partial files completed with a Range request
+	Mirrored      int // Files served by a mirror after the listed URL failed
+
+	DownloadedBytes int64 // Bytes actually transferred for completed files
+	SkippedBytes    int64 // Size of files skipped because they were already present
+	ReusedBytes     int64 // Bytes of partial files kept instead of downloaded again
+
+	Workers []WorkerStats // One entry per parallel worker
+}
+
+// SavedBytes returns how many bytes skipping and continuing avoided downloading
+func (s Summary) SavedBytes() int64 {
+	return s.SkippedBytes + s.ReusedBytes
 }
 
 // Downloader manages file downloads
 type Downloader struct {
 	config Config
 	client *http.Client
+
+	mu        sync.Mutex
+	summary   Summary
+	idleSince []time.Time  // When each worker last finished a file
+	downloads []downloaded // Files fetched (not skipped) for PostVerify
+	// URLs whose partial files failed verification once continued
+	distrusted map[string]bool
+
+	headMu sync.Mutex
+	heads  map[string]remoteFile // HEAD results by URL, reused across retries within a run
+
+	redirects redirects
+	ranges    hostRanges
+	traffic   traffic
+
+	drain     chan struct{} // Closed by Drain
+	drainOnce sync.Once
+
+	pacer        pacer
+	bandwidth    bandwidth
+	windowNotice time.Time // When the last "waiting for window" message said downloads resume
+	pending      int64     // Listed bytes of files not yet finished, for ETA
 }
 
 // New creates a new Downloader with the given config
 func New(config Config) *Downloader {
 	return &Downloader{
 		config: config,
-		client: &http.Client{
-			Timeout: 30 * time.Minute, // Long timeout for large files
-		},
+		client: NewClient(config),
+		heads:  make(map[string]remoteFile),
+		drain:  make(chan struct{}),
+	}
+}
+
+// log returns Config.Logger, or one that discards everything without it
+func (d *Downloader) log() *slog.Logger {
+	if d.config.Logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return d.config.Logger
+}
+
+// progressBar returns a bar from Config.Progress, or a terminal one without it
+func (d *Downloader) progressBar(total int64, description string) progress.Bar {
+	if d.config.Progress == nil {
+		return progress.New(total, description)
+	}
+	return d.config.Progress.New(total, description)
+}
+
+// Drain stops DownloadAll from starting new files while letting in-flight downloads
+// finish. It is safe to call from any goroutine, more than once.
+func (d *Downloader) Drain() {
+	d.drainOnce.Do(func() { close(d.drain) })
+}
+
+// draining reports whether Drain has been called
+func (d *Downloader) draining() bool {
+	select {
+	case <-d.drain:
+		return true
+	default:
+		return false
+	}
+}
+
+// Summary returns the outcome of the most recent DownloadAll call
+func (d *Downloader) Summary() Summary {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := d.summary
+	s.Workers = append([]WorkerStats(nil), s.Workers...)
+	return s
+}
+
+// recordResult updates the summary after a file finishes and returns the completed count
+func (d *Downloader) recordResult(res result, err error) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err != nil {
+		d.summary.Failed++
+		if errors.Is(err, ErrCorrupt) {
+			d.summary.Corrupt++
+		}
+		return d.summary.Completed
+	}
+
+	if res.gone != "" {
+		d.summary.Gone++
+		return d.summary.Completed
+	}
+	d.summary.DownloadedBytes += res.transferred
+	if res.rejected != "" {
+		d.summary.Rejected++
+		return d.summary.Completed
+	}
+	if res.placeholder != "" {
+		d.summary.Placeholders++
+		return d.summary.Completed
+	}
+
+	d.summary.Completed++
+	if res.mirror != "" {
+		d.summary.Mirrored++
+	}
+	switch res.outcome {
+	case outcomeSkipped:
+		d.summary.Skipped++
+		d.summary.SkippedBytes += res.size
+	case outcomeContinued:
+		d.summary.Continued++
+		d.summary.ReusedBytes += res.size - res.transferred
 	}
+	return d.summary.Completed
+}
+
+// downloadOne downloads a file with retries on the given worker, recording the result
+// and emitting events. It returns the completed count after this file and the
+// download error, if any.
+func (d *Downloader) downloadOne(ctx context.Context, file parser.FileInfo, worker int) (int, error) {
+	d.emit(Event{Type: EventStarted, File: file})
+	d.workerBusy(worker)
+	start := time.Now()
+
+	res, err := d.downloadFileWithRetry(ctx, file)
+	if err != nil && d.config.Mirrors != nil && !errors.Is(err, ErrStopped) {
+		res, err = d.tryMirrors(ctx, file, err)
+	}
+	if d.config.IgnoreMissing && errors.Is(err, ErrGone) {
+		fmt.Printf("  ⚠ %s is gone upstream, skipping\n", file.Name)
+		res, err = result{gone: err.Error()}, nil
+	}
+	d.workerIdle(worker, res, time.Since(start))
+	d.settle(d.remaining(file))
+	completed := d.recordResult(res, err)
+	if err == nil && res.outcome != outcomeSkipped && res.placeholder == "" && res.rejected == "" && res.gone == "" {
+		served := file
+		if res.mirror != "" {
+			served.URL = res.mirror
+		}
+		d.rememberDownload(served, res)
+	}
+
+	event := Event{Type: EventCompleted, File: file, Path: res.name, Bytes: res.size, Duration: time.Since(start), Err: err, Mirror: res.mirror, SHA256: res.sha256}
+	switch {
+	case err != nil:
+		event.Type = EventFailed
+	case res.rejected != "":
+		event.Type = EventRejected
+		event.Err = errors.New(res.rejected)
+	case res.gone != "":
+		event.Type = EventGone
+		event.Err = errors.New(res.gone)
+	case res.placeholder != "":
+		event.Type = EventPlaceholder
+	case res.outcome == outcomeSkipped:
+		event.Type = EventSkipped
+	}
+	d.emit(event)
+
+	return completed, err
+}
+
+// tryMirrors downloads a file from each of its mirrors in turn after the listed URL
+// failed with err, returning the first success or the last failure
+func (d *Downloader) tryMirrors(ctx context.Context, file parser.FileInfo, err error) (result, error) {
+	for _, alternate := range d.config.Mirrors(file.URL) {
+		if ctx.Err() != nil {
+			break
+		}
+		host := alternate
+		if u, perr := url.Parse(alternate); perr == nil {
+			host = u.Host
+		}
+		fmt.Printf("  ⚠ %v; trying mirror %s\n", err, host)
+
+		mirrored := file
+		mirrored.URL = alternate
+		var res result
+		res, err = d.downloadFileWithRetry(ctx, mirrored)
+		if err == nil {
+			res.mirror = alternate
+			return res, nil
+		}
+	}
+	return result{}, err
 }
 
 // DownloadAll downloads all files with progress tracking
 func (d *Downloader) DownloadAll(ctx context.Context, files []parser.FileInfo) error {
 	total := len(files)
 
+	d.mu.Lock()
+	d.summary = Summary{Total: total}
+	d.pending = 0
+	for _, f := range files {
+		d.pending += d.remaining(f)
+	}
+	d.startWorkers(max(d.config.Parallel, 1))
+	d.mu.Unlock()
+	defer d.stopWorkers()
+
 	if d.config.Parallel == 1 {
 		// Serial downloads with detailed progress
 		for i, file := range files {
-			fmt.Printf("\n[%d/%d] Downloading: %s\n", i+1, total, file.Name)
+			if err := d.waitForWindow(ctx); err != nil {
+				return err
+			}
+			if d.draining() {
+				return ErrStopped
+			}
+			fmt.Printf("\n[%d/%d] Downloading: %s%s\n", i+1, total, file.Name, d.etaNote())
 
-			if err := d.downloadFileWithRetry(ctx, file); err != nil {
+			if _, err := d.downloadOne(ctx, file, 0); err != nil {
 				return fmt.Errorf("failed to download %s: %w", file.Name, err)
 			}
 		}
@@ -62,148 +410,438 @@ func (d *Downloader) DownloadAll(ctx context.Context, files []parser.FileInfo) e
 	return nil
 }
 
-// downloadFileWithRetry downloads a single file with retry logic using exponential backoff with jitter
-func (d *Downloader) downloadFileWithRetry(ctx context.Context, file parser.FileInfo) error {
+// downloadFileWithRetry downloads a single file with retry logic using exponential backoff with jitter.
+// Network failures and failed verification are retried separately: corrupt downloads are
+// discarded and fetched again up to VerifyRetries times without consuming network attempts.
+func (d *Downloader) downloadFileWithRetry(ctx context.Context, file parser.FileInfo) (result, error) {
+	// A name that escapes the output directory won't get better with retries
+	if _, err := fsutil.Within(d.config.OutputDir, d.localName(file, "")); err != nil {
+		return result{}, err
+	}
+	if res, ok := d.recordedCopy(file); ok {
+		return res, nil
+	}
+
 	var lastErr error
+	attempt, corrupt := 0, 0
 
-	for attempt := 1; attempt <= d.config.RetryAttempts; attempt++ {
-		err := d.downloadFile(ctx, file)
+	for {
+		res, err := d.fetch(ctx, file)
 		if err == nil {
-			return nil
+			return res, nil
+		}
+		if errors.Is(err, ErrRejected) {
+			fmt.Printf("  ⚠ %v, discarding\n", err)
+			return result{transferred: res.transferred, rejected: err.Error()}, nil
+		}
+		if errors.Is(err, errSuspended) {
+			// Not a failure: wait for the window and continue from the temp file
+			fmt.Printf("\n  Download window closed, suspending %s\n", file.Name)
+			d.log().Info("suspended", "file", file.Name, "window", d.config.Window.String())
+			if err := d.waitForWindow(ctx); err != nil {
+				return result{}, err
+			}
+			if d.draining() {
+				return result{}, fmt.Errorf("suspended outside the download window: %w", ErrStopped)
+			}
+			continue
 		}
 
 		lastErr = err
-		if attempt < d.config.RetryAttempts {
-			// Exponential backoff with jitter
-			// Base delay: 1s, exponentially increases with each attempt
-			// Jitter: ±25% randomization to prevent thundering herd
-			baseDelay := time.Second * time.Duration(math.Pow(2, float64(attempt-1)))
-			jitter := time.Duration(float64(baseDelay) * 0.25 * (2*rand.Float64() - 1)) //nolint:gosec // Non-cryptographic random for backoff jitter is acceptable
-			backoff := baseDelay + jitter
-
-			// Cap at 30 seconds
-			if backoff > 30*time.Second {
-				backoff = 30 * time.Second
+		if errors.Is(err, ErrCorrupt) {
+			// The file may have changed upstream, so ask for its size again,
+			// and a partial file it was continued from may be the bad part
+			d.forgetHead(file.URL)
+			d.distrustPartial(file.URL)
+			corrupt++
+			if corrupt > d.config.VerifyRetries {
+				return result{}, fmt.Errorf("still corrupt after %d verification retries: %w", d.config.VerifyRetries, err)
 			}
+			d.mu.Lock()
+			d.summary.VerifyRetries++
+			d.mu.Unlock()
+			fmt.Printf("  ⚠ Verification failed (%v), re-downloading (%d/%d)...\n", err, corrupt, d.config.VerifyRetries)
+			d.log().Warn("verification failed", "file", file.Name, "retry", corrupt, "of", d.config.VerifyRetries, "error", err.Error())
+			if ctx.Err() != nil {
+				return result{}, ctx.Err()
+			}
+			continue
+		}
+		if d.config.IgnoreMissing && errors.Is(err, ErrGone) {
+			return result{}, err // Retrying won't bring it back
+		}
 
-			fmt.Printf("  ⚠ Attempt %d failed, retrying in %v...\n", attempt, backoff.Round(time.Millisecond))
+		// A learned CDN host may be the problem; retry through the origin
+		d.redirects.forget(file.URL)
 
-			// Wait with context support
-			select {
-			case <-time.After(backoff):
-			case <-ctx.Done():
-				return ctx.Err()
-			}
+		attempt++
+		if attempt >= d.config.RetryAttempts {
+			break
+		}
+
+		backoff := retry.Policy{Base: d.config.BackoffBase, Max: d.config.BackoffMax}.Delay(attempt)
+		fmt.Printf("  ⚠ Attempt %d failed, retrying in %v...\n", attempt, backoff.Round(time.Millisecond))
+		d.log().Warn("retrying", "file", file.Name, "attempt", attempt, "delay", backoff.Round(time.Millisecond), "error", err.Error())
+		if err := retry.Wait(ctx, backoff); err != nil {
+			return result{}, err
 		}
 	}
 
-	return fmt.Errorf("failed after %d attempts: %w", d.config.RetryAttempts, lastErr)
+	return result{}, fmt.Errorf("failed after %d attempts: %w", attempt, lastErr)
 }
 
 // downloadFile downloads a single file with progress bar
 func (d *Downloader) downloadFile(ctx context.Context, file parser.FileInfo) error {
-	outputPath := filepath.Join(d.config.OutputDir, file.Name)
+	_, err := d.fetch(ctx, file)
+	return err
+}
 
+// fetch makes a single attempt at downloading a file and reports how it went
+func (d *Downloader) fetch(ctx context.Context, file parser.FileInfo) (result, error) {
 	// Get the actual file size from the server
-	actualSize, err := d.getRemoteFileSize(ctx, file.URL)
+	remote, err := d.cachedHead(ctx, file.URL)
+	if err != nil {
+		return result{}, fmt.Errorf("failed to get file size: %w", err)
+	}
+	actualSize := remote.size
+
+	name := d.localName(file, remote.filename)
+	outputPath, err := fsutil.Within(d.config.OutputDir, name)
 	if err != nil {
-		return fmt.Errorf("failed to get file size: %w", err)
+		return result{}, err
+	}
+	res := result{outcome: outcomeDownloaded, size: actualSize, name: name}
+	var checked int64 // Bytes fetched to check a partial file's tail
+
+	if actualSize == 0 {
+		res.placeholder = "zero bytes"
+		if d.notePlaceholder(res.placeholder) {
+			return result{outcome: outcomeSkipped, name: name, placeholder: res.placeholder}, nil
+		}
 	}
 
 	// Check if file already exists with the correct size
 	if info, err := os.Stat(outputPath); err == nil {
+		if info.Size() == actualSize && res.placeholder == "" && placeholderFile(outputPath, name, actualSize) {
+			res.placeholder = "HTML page instead of file"
+		}
+		if info.Size() == actualSize && res.placeholder != "" {
+			return result{outcome: outcomeSkipped, name: name, placeholder: res.placeholder}, nil
+		}
 		if info.Size() == actualSize {
 			fmt.Printf("  ✓ Already downloaded (skipping)\n")
-			return nil
+			return result{outcome: outcomeSkipped, size: actualSize, name: name}, nil
 		}
-		if d.config.Verbose {
-			fmt.Printf("  ⚠ File exists but size mismatch (local: %d, remote: %d), re-downloading\n",
-				info.Size(), actualSize)
+		partial := d.config.ContinueExisting && info.Size() > 0 && info.Size() < actualSize && d.continuable(file.URL)
+		switch {
+		case partial && d.ranges.support(remote.host) == rangesUnsupported:
+			fmt.Printf("  ⚠ %s does not support range requests, re-downloading instead of continuing\n", remote.host)
+		case partial:
+			// Continued in the temp file, so the partial file stays intact
+			// until the completed copy verifies
+			checked, err = d.seedTemp(ctx, file, remote, outputPath, info.Size())
+			if err != nil && !errors.Is(err, errCannotContinue) {
+				return result{}, err
+			}
+			if err != nil {
+				fmt.Printf("  ⚠ Cannot continue existing file (%v), re-downloading\n", err)
+			}
+		default:
+			switch d.mismatchPolicy(file, info.Size(), actualSize) {
+			case MismatchSkip:
+				fmt.Printf("  ⚠ Keeping existing file despite size mismatch (local: %d, remote: %d)\n", info.Size(), actualSize)
+				return result{outcome: outcomeSkipped, size: info.Size(), name: name}, nil
+			case MismatchRename:
+				name = d.freeName(name)
+				if outputPath, err = fsutil.Within(d.config.OutputDir, name); err != nil {
+					return result{}, err
+				}
+				res.name = name
+				fmt.Printf("  Keeping existing file, saving download as %q\n", filepath.Base(name))
+			default:
+				if d.config.Verbose {
+					fmt.Printf("  ⚠ File exists but size mismatch (local: %d, remote: %d), re-downloading\n",
+						info.Size(), actualSize)
+				}
+			}
 		}
 	}
 
-	// Create the request with context
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, file.URL, nil)
+	// An earlier attempt may have left a temp file worth resuming
+	tempPath := outputPath + cleanup.TempSuffix
+	offset, hasher, err := d.resumeTemp(tempPath, file.URL, remote)
+	segmentedTemp := errors.Is(err, errSegmentedTemp)
 	if err != nil {
-		return err
+		if d.config.Verbose && !errors.Is(err, os.ErrNotExist) && !segmentedTemp {
+			fmt.Printf("  ⚠ Discarding partial temp file: %v\n", err)
+		}
+		if !segmentedTemp {
+			removeTemp(tempPath)
+		}
+		offset, hasher = 0, sha256.New()
+	}
+
+	// Files from a recursive listing land in the remote directory structure
+	if err := os.MkdirAll(filepath.Dir(tempPath), 0755); err != nil { //nolint:gosec // 0755 is appropriate for download directories
+		return result{}, fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	// Set User-Agent for polite web scraping
-	req.Header.Set("User-Agent", "myrient-dl/1.0 (https://github.com/nchapman/myrient-dl)")
+	// Large files can be fetched over several connections at once
+	if segments := splitSegments(actualSize, d.config.Segments); offset == 0 && segments != nil && d.ranges.support(remote.host) != rangesUnsupported {
+		res, err := d.fetchSegmented(ctx, file, res, remote, tempPath, outputPath, segments)
+		if !errors.Is(err, errCannotSegment) {
+			return res, err
+		}
+		fmt.Printf("  ⚠ %v, downloading over one connection\n", err)
+	} else if segmentedTemp {
+		removeTemp(tempPath) // Left by a segmented download that can't continue as one
+	}
 
-	resp, err := d.client.Do(req)
+	// Create the request with context
+	req, err := d.newRequest(ctx, http.MethodGet, file.URL)
 	if err != nil {
-		return err
+		return result{}, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := d.do(req)
+	if err != nil {
+		return result{}, err
 	}
 	defer func() {
 		_ = resp.Body.Close()
 	}()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("server returned status %d", resp.StatusCode)
+	expectedRange := fmt.Sprintf("bytes %d-%d/%d", offset, actualSize-1, actualSize)
+	switch {
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent && resp.Header.Get("Content-Range") == expectedRange:
+		fmt.Printf("  ↻ Resuming temp file from %d of %d bytes\n", offset, actualSize)
+	case resp.StatusCode == http.StatusOK:
+		if offset > 0 {
+			d.ranges.refuse(resp.Request.URL.Host) // Ignored the Range header entirely
+			offset, hasher = 0, sha256.New()
+		}
+		if resp.ContentLength >= 0 && resp.ContentLength != actualSize {
+			d.forgetHead(file.URL) // Changed since the HEAD; don't trust the cached size again
+		}
+	default:
+		// A server error says nothing about the temp file, so it is kept for
+		// the next attempt or a mirror; anything else starts over
+		if resp.StatusCode < http.StatusInternalServerError && resp.StatusCode != http.StatusTooManyRequests {
+			removeTemp(tempPath)
+		}
+		return result{}, statusError(resp.StatusCode)
 	}
 
-	// Create temp file for atomic write
-	tempPath := outputPath + ".tmp"
-	out, err := os.Create(tempPath) //nolint:gosec // File path is controlled by config and filename from server
+	// A published checksum is computed alongside the SHA-256
+	check, err := d.checksumHash(file, tempPath, offset)
 	if err != nil {
-		return err
+		return result{}, err
 	}
+
+	if offset == 0 && res.placeholder == "" && placeholderPage(file.Name, resp) {
+		res.placeholder = "HTML page instead of file"
+		if d.notePlaceholder(res.placeholder) {
+			return result{outcome: outcomeSkipped, name: name, placeholder: res.placeholder}, nil
+		}
+	}
+
+	// Redirected CDN links sometimes only name the file on the GET. What's
+	// already on disk under that name decides whether the download is skipped,
+	// continued, or kept alongside, so start over knowing it.
+	if served := dispositionFilename(resp.Header); served != "" && served != remote.filename {
+		renamed := d.localName(file, served)
+		if renamed != d.localName(file, remote.filename) {
+			servedPath, err := fsutil.Within(d.config.OutputDir, renamed)
+			if err != nil {
+				return result{}, err
+			}
+			if offset > 0 {
+				moveTemp(tempPath, servedPath+cleanup.TempSuffix)
+			}
+			remote.filename = served
+			d.rememberHead(file.URL, remote)
+			_ = resp.Body.Close()
+			return d.fetch(ctx, file)
+		}
+	}
+
+	// Write to a temp file for an atomic rename, appending to the confirmed
+	// part of a resumed one
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_WRONLY | os.O_APPEND
+	}
+	out, err := os.OpenFile(tempPath, flags, 0666) //nolint:gosec // File path is controlled by config and filename from server
+	if err != nil {
+		return result{}, err
+	}
+	w := &journalWriter{
+		file:    out,
+		path:    journalPath(tempPath),
+		hash:    hasher,
+		journal: journal{URL: file.URL, Size: actualSize, ETag: remote.etag, Offset: offset},
+	}
+	if d.config.OnProgress != nil {
+		w.onCheckpoint = func(onDisk int64) { d.config.OnProgress(file, onDisk) }
+	}
+	keep := false
 	defer func() {
 		_ = out.Close()
-		// Clean up temp file if it still exists
-		_ = os.Remove(tempPath)
+		// Clean up the temp file unless it was kept for resuming
+		if !keep {
+			removeTemp(tempPath)
+		}
 	}()
 
 	// Create progress bar
-	bar := progressbar.DefaultBytes(
+	bar := d.progressBar(
 		resp.ContentLength,
 		"  downloading",
 	)
 
 	// Copy with progress tracking
-	_, err = io.Copy(io.MultiWriter(out, bar), resp.Body)
+	writers := []io.Writer{w, bar}
+	if check != nil {
+		writers = append(writers, check)
+	}
+	written, err := io.Copy(io.MultiWriter(writers...), resp.Body)
 	if err != nil {
-		return err
+		// Interrupted mid-transfer: record what arrived so a retry or later run can resume
+		keep = w.journal.Offset > 0 && w.checkpoint() == nil
+		return result{}, err
 	}
 
-	// Close before rename
+	// A body that ends early without a transport error is bad data, not a network failure
+	if resp.ContentLength >= 0 && written != resp.ContentLength {
+		return result{}, fmt.Errorf("%w: received %d of %d bytes", ErrCorrupt, written, resp.ContentLength)
+	}
+
+	// Close before verification and rename
 	if err := out.Close(); err != nil {
-		return err
+		return result{}, err
+	}
+
+	if check != nil {
+		if err := d.verifyChecksum(file, tempPath, check); err != nil {
+			return result{transferred: written}, err
+		}
+	}
+	if d.config.Verifier != nil {
+		if err := d.config.Verifier.Verify(file, tempPath); err != nil {
+			return result{transferred: written}, err
+		}
 	}
 
 	// Atomic rename
 	if err := os.Rename(tempPath, outputPath); err != nil {
-		return err
+		return result{}, err
 	}
 
 	fmt.Println() // New line after progress bar
-	return nil
+	if offset > 0 {
+		res.outcome = outcomeContinued
+	}
+	res.transferred = checked + written
+	res.sha256 = hex.EncodeToString(w.hash.Sum(nil))
+	return res, nil
+}
+
+// remoteFile is what a HEAD request tells us about a file
+type remoteFile struct {
+	size     int64
+	etag     string
+	filename string // From Content-Disposition, if the server sent one
+	host     string // Host that answered, after redirects
+}
+
+// statusError describes an unexpected response status, wrapping ErrGone for 404 and 410
+func statusError(code int) error {
+	if code == http.StatusNotFound || code == http.StatusGone {
+		return fmt.Errorf("server returned status %d: %w", code, ErrGone)
+	}
+	return fmt.Errorf("server returned status %d", code)
 }
 
 // getRemoteFileSize makes a HEAD request to get the actual file size from the server
 func (d *Downloader) getRemoteFileSize(ctx context.Context, url string) (int64, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	remote, err := d.headFile(ctx, url)
+	return remote.size, err
+}
+
+// Size returns a file's exact size from a HEAD request, failing when the
+// server doesn't report one
+func (d *Downloader) Size(ctx context.Context, fileURL string) (int64, error) {
+	size, err := d.getRemoteFileSize(ctx, fileURL)
 	if err != nil {
 		return 0, err
 	}
+	if size < 0 {
+		return 0, errors.New("server did not report a size")
+	}
+	return size, nil
+}
 
-	// Set User-Agent for polite web scraping
-	req.Header.Set("User-Agent", "myrient-dl/1.0 (https://github.com/nchapman/myrient-dl)")
+// headFile makes a HEAD request for the file's size and server-side name
+func (d *Downloader) headFile(ctx context.Context, url string) (remoteFile, error) {
+	req, err := d.newRequest(ctx, http.MethodHead, url)
+	if err != nil {
+		return remoteFile{}, err
+	}
 
-	resp, err := d.client.Do(req)
+	resp, err := d.do(req)
 	if err != nil {
-		return 0, err
+		return remoteFile{}, err
 	}
 	defer func() {
 		_ = resp.Body.Close()
 	}()
 
 	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("server returned status %d", resp.StatusCode)
+		return remoteFile{}, statusError(resp.StatusCode)
 	}
+	d.noteRanges(resp)
+
+	return remoteFile{
+		size:     resp.ContentLength,
+		etag:     resp.Header.Get("ETag"),
+		filename: dispositionFilename(resp.Header),
+		host:     resp.Request.URL.Host,
+	}, nil
+}
 
-	return resp.ContentLength, nil
+// cachedHead returns the HEAD result for url, only asking the server the first time
+// so retries don't double the request volume against a struggling server
+func (d *Downloader) cachedHead(ctx context.Context, url string) (remoteFile, error) {
+	d.headMu.Lock()
+	remote, ok := d.heads[url]
+	d.headMu.Unlock()
+	if ok {
+		return remote, nil
+	}
+
+	remote, err := d.headFile(ctx, url)
+	if err != nil {
+		return remoteFile{}, err
+	}
+	d.rememberHead(url, remote)
+	return remote, nil
+}
+
+// rememberHead caches what is known about url for later attempts in the run
+func (d *Downloader) rememberHead(url string, remote remoteFile) {
+	d.headMu.Lock()
+	d.heads[url] = remote
+	d.headMu.Unlock()
+}
+
+// forgetHead drops the cached HEAD result for url, e.g. when the file seems to have changed
+func (d *Downloader) forgetHead(url string) {
+	d.headMu.Lock()
+	delete(d.heads, url)
+	d.headMu.Unlock()
 }
 
 // downloadParallel downloads files in parallel
@@ -212,18 +850,25 @@ func (d *Downloader) downloadParallel(ctx context.Context, files []parser.FileIn
 	defer cancel()
 
 	var (
-		wg        sync.WaitGroup
-		errCh     = make(chan error, len(files))
-		semaphore = make(chan struct{}, d.config.Parallel)
+		wg    sync.WaitGroup
+		errCh = make(chan error, len(files))
+		// Free worker IDs; taking one is the semaphore
+		workers = make(chan int, d.config.Parallel)
 	)
+	for i := 0; i < d.config.Parallel; i++ {
+		workers <- i
+	}
 
 	total := len(files)
-	completed := 0
+	started := 0
 	var mu sync.Mutex
+	var stopped atomic.Bool // Some file was never started because of Drain
+	classes := newSizeClasses(files, d.config)
 
-	for _, file := range files {
+	for i, file := range files {
 		wg.Add(1)
-		go func(f parser.FileInfo) {
+		// Each file keeps its position in the list as a stable ordinal
+		go func(ordinal int, f parser.FileInfo) {
 			defer wg.Done()
 
 			// Check if context is cancelled
@@ -233,30 +878,78 @@ func (d *Downloader) downloadParallel(ctx context.Context, files []parser.FileIn
 			default:
 			}
 
-			// Acquire semaphore
+			// Large files first take one of the slots not kept for small ones
+			large := classes.large(f)
+			if large {
+				held, ok := classes.acquire(ctx, d.drain)
+				if !ok {
+					if ctx.Err() == nil {
+						stopped.Store(true)
+					}
+					return
+				}
+				if held {
+					defer classes.release()
+				}
+			}
+
+			// Acquire a worker
+			var worker int
 			select {
-			case semaphore <- struct{}{}:
+			case worker = <-workers:
 			case <-ctx.Done():
 				return
+			case <-d.drain:
+				stopped.Store(true)
+				return
+			}
+			defer func() { workers <- worker }()
+			if !large {
+				classes.started()
+			}
+			if d.draining() {
+				stopped.Store(true)
+				return
 			}
-			defer func() { <-semaphore }()
 
 			mu.Lock()
-			current := completed + 1
+			slot := started
+			started++
 			mu.Unlock()
 
-			fmt.Printf("\n[%d/%d] Downloading: %s\n", current, total, f.Name)
+			// Stagger the initial wave of workers
+			if slot < d.config.Parallel && d.config.StartupRamp > 0 {
+				select {
+				case <-time.After(time.Duration(slot) * d.config.StartupRamp):
+				case <-ctx.Done():
+					return
+				case <-d.drain:
+					stopped.Store(true)
+					return
+				}
+			}
 
-			if err := d.downloadFileWithRetry(ctx, f); err != nil {
+			if d.waitForWindow(ctx) != nil {
+				return
+			}
+			if d.draining() {
+				stopped.Store(true)
+				return
+			}
+
+			fmt.Printf("\n[%d/%d] Downloading: %s%s\n", ordinal, total, f.Name, d.etaNote())
+
+			completed, err := d.downloadOne(ctx, f, worker)
+			if err != nil {
 				errCh <- fmt.Errorf("failed to download %s: %w", f.Name, err)
 				cancel() // Cancel all other downloads on first error
 				return
 			}
 
-			mu.Lock()
-			completed++
-			mu.Unlock()
-		}(file)
+			if d.config.Verbose {
+				fmt.Printf("  ✓ [%d/%d] %s finished (%d/%d complete)\n", ordinal, total, f.Name, completed, total)
+			}
+		}(i+1, file)
 	}
 
 	// Wait for all downloads to complete
@@ -277,6 +970,9 @@ func (d *Downloader) downloadParallel(ctx context.Context, files []parser.FileIn
 		// Multiple errors - return first with count
 		return fmt.Errorf("%w (and %d other error(s))", errs[0], len(errs)-1)
 	}
+	if stopped.Load() {
+		return ErrStopped
+	}
 
 	return nil
 }
@@ -1,13 +1,31 @@
 package downloader
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/nchapman/myrient-dl/internal/auth"
+	"github.com/nchapman/myrient-dl/internal/cleanup"
+	"github.com/nchapman/myrient-dl/internal/fsutil"
+	"github.com/nchapman/myrient-dl/internal/hashing"
 	"github.com/nchapman/myrient-dl/internal/parser"
 )
 
@@ -45,6 +63,25 @@ func TestDownloader_GetRemoteFileSize_ServerError(t *testing.T) {
 	}
 }
 
+func TestDownloader_Size(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunked" {
+			w.Header().Set("Transfer-Encoding", "chunked")
+			return
+		}
+		w.Header().Set("Content-Length", "72191")
+	}))
+	defer server.Close()
+
+	dl := New(Config{})
+	if size, err := dl.Size(context.Background(), server.URL+"/file.zip"); err != nil || size != 72191 {
+		t.Errorf("expected 72191 bytes, got %d (%v)", size, err)
+	}
+	if _, err := dl.Size(context.Background(), server.URL+"/chunked"); err == nil {
+		t.Error("expected an error when the server reports no size")
+	}
+}
+
 func TestDownloader_DownloadFile(t *testing.T) {
 	testContent := []byte("test file content")
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -92,6 +129,25 @@ func TestDownloader_DownloadFile(t *testing.T) {
 	}
 }
 
+func TestDownloader_NestedName(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Length", "4")
+		_, _ = w.Write([]byte("data"))
+	}))
+	defer server.Close()
+
+	// Files from a recursive listing are named by their path below it
+	tmpDir := t.TempDir()
+	dl := New(Config{OutputDir: tmpDir, RetryAttempts: 1})
+	file := parser.FileInfo{Name: "Disc 1/deeper/test.zip", URL: server.URL + "/Disc%201/deeper/test.zip", Size: 4}
+	if err := dl.downloadFile(context.Background(), file); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "Disc 1", "deeper", "test.zip")); err != nil {
+		t.Errorf("expected the remote directories to be mirrored: %v", err)
+	}
+}
+
 func TestDownloader_SkipExistingFile(t *testing.T) {
 	testContent := []byte("existing content")
 
@@ -270,3 +326,2318 @@ func TestNew(t *testing.T) {
 		t.Error("expected client to be initialized")
 	}
 }
+
+func TestDownloader_StartupRamp(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		starts []time.Time
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			mu.Lock()
+			starts = append(starts, time.Now())
+			mu.Unlock()
+		}
+		w.Header().Set("Content-Length", "5")
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte("hello"))
+		}
+	}))
+	defer server.Close()
+
+	ramp := 100 * time.Millisecond
+	dl := New(Config{
+		OutputDir:     t.TempDir(),
+		Parallel:      3,
+		RetryAttempts: 1,
+		StartupRamp:   ramp,
+	})
+
+	files := []parser.FileInfo{
+		{Name: "file1.zip", URL: server.URL + "/file1.zip", Size: 5},
+		{Name: "file2.zip", URL: server.URL + "/file2.zip", Size: 5},
+		{Name: "file3.zip", URL: server.URL + "/file3.zip", Size: 5},
+	}
+
+	begin := time.Now()
+	if err := dl.DownloadAll(context.Background(), files); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(starts) != 3 {
+		t.Fatalf("expected 3 downloads, got %d", len(starts))
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+
+	// The last of three workers must wait for two ramp intervals
+	if elapsed := starts[2].Sub(begin); elapsed < 2*ramp {
+		t.Errorf("expected third worker to start after %v, started after %v", 2*ramp, elapsed)
+	}
+}
+
+func TestDownloader_Summary(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.zip" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", "5")
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte("hello"))
+		}
+	}))
+	defer server.Close()
+
+	t.Run("all succeed", func(t *testing.T) {
+		dl := New(Config{OutputDir: t.TempDir(), Parallel: 3, RetryAttempts: 1})
+		files := []parser.FileInfo{
+			{Name: "a.zip", URL: server.URL + "/a.zip"},
+			{Name: "b.zip", URL: server.URL + "/b.zip"},
+			{Name: "c.zip", URL: server.URL + "/c.zip"},
+			{Name: "d.zip", URL: server.URL + "/d.zip"},
+		}
+
+		if err := dl.DownloadAll(context.Background(), files); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		summary := dl.Summary()
+		if summary.Total != 4 || summary.Completed != 4 || summary.Failed != 0 {
+			t.Errorf("unexpected summary %+v", summary)
+		}
+	})
+
+	t.Run("failure is counted", func(t *testing.T) {
+		dl := New(Config{OutputDir: t.TempDir(), Parallel: 1, RetryAttempts: 1})
+		files := []parser.FileInfo{
+			{Name: "a.zip", URL: server.URL + "/a.zip"},
+			{Name: "missing.zip", URL: server.URL + "/missing.zip"},
+			{Name: "c.zip", URL: server.URL + "/c.zip"},
+		}
+
+		if err := dl.DownloadAll(context.Background(), files); err == nil {
+			t.Fatal("expected error for missing file")
+		}
+
+		summary := dl.Summary()
+		if summary.Total != 3 || summary.Completed != 1 || summary.Failed != 1 {
+			t.Errorf("unexpected summary %+v", summary)
+		}
+	})
+
+	t.Run("skipped bytes are saved", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "a.zip"), []byte("hello"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		dl := New(Config{OutputDir: dir, Parallel: 1, RetryAttempts: 1})
+		files := []parser.FileInfo{
+			{Name: "a.zip", URL: server.URL + "/a.zip"},
+			{Name: "b.zip", URL: server.URL + "/b.zip"},
+		}
+		if err := dl.DownloadAll(context.Background(), files); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		summary := dl.Summary()
+		if summary.Skipped != 1 || summary.SkippedBytes != 5 || summary.DownloadedBytes != 5 || summary.SavedBytes() != 5 {
+			t.Errorf("unexpected summary %+v", summary)
+		}
+	})
+}
+
+func TestDownloader_Checksums(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "5")
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte("hello"))
+		}
+	}))
+	defer server.Close()
+
+	file := parser.FileInfo{Name: "game.zip", URL: server.URL + "/game.zip", Size: 5}
+	tests := []struct {
+		name     string
+		checksum Checksum
+		wantErr  bool
+	}{
+		{"matching SHA-1", Checksum{Algorithm: hashing.SHA1, Digest: "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"}, false},
+		{"matching MD5", Checksum{Algorithm: hashing.MD5, Digest: "5d41402abc4b2a76b9719d911017c592"}, false},
+		{"mismatch", Checksum{Algorithm: hashing.SHA1, Digest: "0000000000000000000000000000000000000000"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			dl := New(Config{
+				OutputDir:     tmpDir,
+				Parallel:      1,
+				RetryAttempts: 1,
+				VerifyRetries: 1,
+				Checksums: func(f parser.FileInfo) (Checksum, bool) {
+					return tt.checksum, f.Name == file.Name
+				},
+			})
+
+			err := dl.DownloadAll(context.Background(), []parser.FileInfo{file})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			_, statErr := os.Stat(filepath.Join(tmpDir, "game.zip"))
+			if tt.wantErr {
+				if !errors.Is(err, ErrCorrupt) || dl.Summary().VerifyRetries != 1 {
+					t.Errorf("expected a retried corrupt download, got %v (%+v)", err, dl.Summary())
+				}
+				if !os.IsNotExist(statErr) {
+					t.Error("expected a mismatched file not to be moved into place")
+				}
+			} else if statErr != nil {
+				t.Errorf("expected the file to be saved: %v", statErr)
+			}
+		})
+	}
+}
+
+func TestDownloader_VerifyRetries(t *testing.T) {
+	var gets int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "5")
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			gets++
+			_, _ = w.Write([]byte("hello"))
+		}
+	}))
+	defer server.Close()
+
+	file := parser.FileInfo{Name: "game.zip", URL: server.URL + "/game.zip", Size: 5}
+
+	t.Run("recovers after corrupt download", func(t *testing.T) {
+		gets = 0
+		tmpDir := t.TempDir()
+		calls := 0
+		dl := New(Config{
+			OutputDir:     tmpDir,
+			Parallel:      1,
+			RetryAttempts: 1,
+			VerifyRetries: 2,
+			Verifier: VerifierFunc(func(_ parser.FileInfo, path string) error {
+				calls++
+				if _, err := os.Stat(path); err != nil {
+					t.Errorf("expected temp file to exist during verification: %v", err)
+				}
+				if calls == 1 {
+					return fmt.Errorf("%w: checksum mismatch", ErrCorrupt)
+				}
+				return nil
+			}),
+		})
+
+		if err := dl.DownloadAll(context.Background(), []parser.FileInfo{file}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if gets != 2 {
+			t.Errorf("expected 2 GET requests, got %d", gets)
+		}
+		summary := dl.Summary()
+		if summary.VerifyRetries != 1 || summary.Completed != 1 || summary.Corrupt != 0 {
+			t.Errorf("unexpected summary %+v", summary)
+		}
+		if _, err := os.Stat(filepath.Join(tmpDir, "game.zip.tmp")); !os.IsNotExist(err) {
+			t.Error("expected temp file to be cleaned up")
+		}
+	})
+
+	t.Run("gives up as corrupt", func(t *testing.T) {
+		gets = 0
+		tmpDir := t.TempDir()
+		dl := New(Config{
+			OutputDir:     tmpDir,
+			Parallel:      1,
+			RetryAttempts: 1,
+			VerifyRetries: 2,
+			Verifier: VerifierFunc(func(parser.FileInfo, string) error {
+				return fmt.Errorf("%w: checksum mismatch", ErrCorrupt)
+			}),
+		})
+
+		err := dl.DownloadAll(context.Background(), []parser.FileInfo{file})
+		if !errors.Is(err, ErrCorrupt) {
+			t.Fatalf("expected corrupt error, got %v", err)
+		}
+		// One initial download plus two verification retries, without using network retries
+		if gets != 3 {
+			t.Errorf("expected 3 GET requests, got %d", gets)
+		}
+		summary := dl.Summary()
+		if summary.Failed != 1 || summary.Corrupt != 1 || summary.VerifyRetries != 2 {
+			t.Errorf("unexpected summary %+v", summary)
+		}
+		if _, err := os.Stat(filepath.Join(tmpDir, "game.zip")); !os.IsNotExist(err) {
+			t.Error("expected corrupt file not to be moved into place")
+		}
+	})
+}
+
+func TestDownloader_ContinueExisting(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 10000) // 160,000 bytes
+	modTime := time.Now()
+
+	rangeServer := func(ranges bool, served *atomic.Int64) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !ranges {
+				r.Header.Del("Range")
+			}
+			cw := &countingWriter{ResponseWriter: w}
+			http.ServeContent(cw, r, "game.zip", modTime, bytes.NewReader(content))
+			if r.Method == http.MethodGet {
+				served.Add(cw.n)
+			}
+		}))
+	}
+
+	tests := []struct {
+		name         string
+		ranges       bool
+		partial      []byte
+		expectServed int64 // 0 skips the check
+		continued    int
+	}{
+		{
+			name:         "completes matching partial with range",
+			ranges:       true,
+			partial:      content[:100000],
+			expectServed: int64(len(content)) - 100000 + overlapSize,
+			continued:    1,
+		},
+		{
+			name:         "small partial overlaps whole file",
+			ranges:       true,
+			partial:      content[:10],
+			expectServed: int64(len(content)),
+			continued:    1,
+		},
+		{
+			name:         "mismatched tail falls back to full download",
+			ranges:       true,
+			partial:      bytes.Repeat([]byte("x"), 100000),
+			expectServed: overlapSize + int64(len(content)), // Only the tail is fetched to check it
+		},
+		{
+			name:    "no range support falls back to full download",
+			ranges:  false,
+			partial: content[:100000],
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var served atomic.Int64
+			server := rangeServer(tt.ranges, &served)
+
+			tmpDir := t.TempDir()
+			path := filepath.Join(tmpDir, "game.zip")
+			if err := os.WriteFile(path, tt.partial, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			dl := New(Config{OutputDir: tmpDir, Parallel: 1, RetryAttempts: 1, ContinueExisting: true})
+			file := parser.FileInfo{Name: "game.zip", URL: server.URL + "/game.zip"}
+			err := dl.DownloadAll(context.Background(), []parser.FileInfo{file})
+			server.Close() // Waits for handlers so the served count is final
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got, err := os.ReadFile(path) //nolint:gosec // Test file path is safe (from t.TempDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("file content mismatch (got %d bytes)", len(got))
+			}
+			if tt.expectServed > 0 && served.Load() != tt.expectServed {
+				t.Errorf("expected %d bytes served, got %d", tt.expectServed, served.Load())
+			}
+			if dl.Summary().Continued != tt.continued {
+				t.Errorf("expected %d continued files, got %d", tt.continued, dl.Summary().Continued)
+			}
+			if tt.continued > 0 && dl.Summary().DownloadedBytes != tt.expectServed {
+				t.Errorf("expected %d downloaded bytes, got %d", tt.expectServed, dl.Summary().DownloadedBytes)
+			}
+		})
+	}
+}
+
+// corruptOnce fails verification of the first file it sees
+type corruptOnce struct{ calls atomic.Int32 }
+
+func (v *corruptOnce) Verify(_ parser.FileInfo, _ string) error {
+	if v.calls.Add(1) == 1 {
+		return fmt.Errorf("%w: bad prefix", ErrCorrupt)
+	}
+	return nil
+}
+
+func TestDownloader_ContinueExistingKeepsPartial(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 10000)
+	partial := append(bytes.Repeat([]byte("x"), 20000), content[20000:100000]...) // Bad data before a good tail
+
+	t.Run("verification failure", func(t *testing.T) {
+		var partialOnRetry []byte
+		var gets, continued atomic.Int32
+		dir := t.TempDir()
+		path := filepath.Join(dir, "game.zip")
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet && r.Header.Get("Range") == "" && gets.Add(1) == 1 {
+				partialOnRetry, _ = os.ReadFile(path) //nolint:gosec // Test file path is safe (from t.TempDir)
+			}
+			if r.Header.Get("Range") == "bytes=100000-" {
+				continued.Add(1)
+			}
+			http.ServeContent(w, r, "game.zip", time.Now(), bytes.NewReader(content))
+		}))
+		defer server.Close()
+		if err := os.WriteFile(path, partial, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		dl := New(Config{OutputDir: dir, Parallel: 1, RetryAttempts: 1, VerifyRetries: 1, ContinueExisting: true, Verifier: &corruptOnce{}})
+		if err := dl.DownloadAll(context.Background(), []parser.FileInfo{{Name: "game.zip", URL: server.URL + "/game.zip"}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if continued.Load() != 1 || gets.Load() != 1 {
+			t.Errorf("expected one continuation, then one fresh download; got %d and %d", continued.Load(), gets.Load())
+		}
+		if !bytes.Equal(partialOnRetry, partial) {
+			t.Errorf("expected the partial file to be intact when the fresh download started, got %d bytes", len(partialOnRetry))
+		}
+		if got, _ := os.ReadFile(path); !bytes.Equal(got, content) { //nolint:gosec // Test file path is safe (from t.TempDir)
+			t.Errorf("expected the fresh download to replace the partial file, got %d bytes", len(got))
+		}
+	})
+
+	t.Run("interrupted", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet && strings.HasPrefix(r.Header.Get("Range"), "bytes=100000-") {
+				// Part of the rest, then the connection drops
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes 100000-%d/%d", len(content)-1, len(content)))
+				w.Header().Set("Content-Length", fmt.Sprint(len(content)-100000))
+				w.WriteHeader(http.StatusPartialContent)
+				_, _ = w.Write(content[100000:120000])
+				return
+			}
+			http.ServeContent(w, r, "game.zip", time.Now(), bytes.NewReader(content))
+		}))
+		defer server.Close()
+		dir := t.TempDir()
+		path := filepath.Join(dir, "game.zip")
+		if err := os.WriteFile(path, content[:100000], 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		dl := New(Config{OutputDir: dir, Parallel: 1, RetryAttempts: 1, ContinueExisting: true})
+		if err := dl.DownloadAll(context.Background(), []parser.FileInfo{{Name: "game.zip", URL: server.URL + "/game.zip"}}); err == nil {
+			t.Fatal("expected the dropped connection to fail the download")
+		}
+		if got, _ := os.ReadFile(path); !bytes.Equal(got, content[:100000]) { //nolint:gosec // Test file path is safe (from t.TempDir)
+			t.Errorf("expected the partial file to be left as it was, got %d bytes", len(got))
+		}
+		if _, err := os.Stat(path + ".journal.tmp"); err != nil {
+			t.Errorf("expected the continuation to be journaled for the next run: %v", err)
+		}
+	})
+}
+
+// countingWriter counts body bytes written through a ResponseWriter
+type countingWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func TestDownloader_ContentDisposition(t *testing.T) {
+	content := []byte("game data")
+
+	tests := []struct {
+		name        string
+		header      string
+		headOnly    bool // Only the HEAD response names the file
+		honor       bool
+		expectSaved string
+	}{
+		{"warns and keeps listed name", `attachment; filename="Game (Rev 1).zip"`, false, false, "game.zip"},
+		{"honors server name", `attachment; filename="Game (Rev 1).zip"`, false, true, "Game (Rev 1).zip"},
+		{"honors name from HEAD only", `attachment; filename="Game (Rev 1).zip"`, true, true, "Game (Rev 1).zip"},
+		{"decodes RFC 5987 names", `attachment; filename*=UTF-8''Pok%C3%A9mon.zip`, false, true, "Pokémon.zip"},
+		{"sanitizes traversal", `attachment; filename="../../evil.zip"`, false, true, "___evil.zip"},
+		{"same name is a no-op", `attachment; filename="game.zip"`, false, true, "game.zip"},
+		{"malformed header ignored", `attachment; filename="unterminated`, false, true, "game.zip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !tt.headOnly || r.Method == http.MethodHead {
+					w.Header().Set("Content-Disposition", tt.header)
+				}
+				w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+				if r.Method == http.MethodGet {
+					_, _ = w.Write(content)
+				}
+			}))
+			defer server.Close()
+
+			dir := t.TempDir()
+			var events []Event
+			dl := New(Config{
+				OutputDir:               dir,
+				Parallel:                1,
+				RetryAttempts:           1,
+				HonorContentDisposition: tt.honor,
+				OnEvent:                 func(e Event) { events = append(events, e) },
+			})
+
+			files := []parser.FileInfo{{Name: "game.zip", URL: server.URL + "/game.zip"}}
+			if err := dl.DownloadAll(context.Background(), files); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 || entries[0].Name() != tt.expectSaved {
+				t.Fatalf("expected only %s, got %v", tt.expectSaved, entries)
+			}
+			if last := events[len(events)-1]; last.Path != tt.expectSaved {
+				t.Errorf("expected event path %s, got %s", tt.expectSaved, last.Path)
+			}
+		})
+	}
+}
+
+func TestDownloader_ContentDispositionCollision(t *testing.T) {
+	content := []byte("game data")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+		if r.Method == http.MethodGet {
+			// Only the GET names the file, as with redirected CDN links
+			w.Header().Set("Content-Disposition", `attachment; filename="Game (Rev 1).zip"`)
+			_, _ = w.Write(content)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		existing []byte
+		expect   map[string]string // Saved file contents by name
+	}{
+		{"keeps a mismatched file and renames the download", []byte("older"), map[string]string{
+			"Game (Rev 1).zip":     "older",
+			"Game (Rev 1) (2).zip": "game data",
+		}},
+		{"skips a complete file", []byte("game DATA"), map[string]string{
+			"Game (Rev 1).zip": "game DATA",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "Game (Rev 1).zip"), tt.existing, 0644); err != nil {
+				t.Fatal(err)
+			}
+			var events []Event
+			dl := New(Config{
+				OutputDir:               dir,
+				Parallel:                1,
+				RetryAttempts:           1,
+				HonorContentDisposition: true,
+				Mismatches:              MismatchRename,
+				OnEvent:                 func(e Event) { events = append(events, e) },
+			})
+
+			files := []parser.FileInfo{{Name: "game.zip", URL: server.URL + "/game.zip"}}
+			if err := dl.DownloadAll(context.Background(), files); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(tt.expect) {
+				t.Errorf("expected %d files, got %v", len(tt.expect), entries)
+			}
+			for name, want := range tt.expect {
+				if got, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(got) != want {
+					t.Errorf("%s: expected %q, got %q (%v)", name, want, got, err)
+				}
+			}
+			if last := events[len(events)-1]; len(tt.expect) == 2 && last.Path != "Game (Rev 1) (2).zip" {
+				t.Errorf("expected the event to name the renamed download, got %s", last.Path)
+			}
+		})
+	}
+}
+
+func TestDownloader_UnsafeNames(t *testing.T) {
+	content := []byte("payload")
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path == "/served.zip" {
+			w.Header().Set("Content-Disposition", `attachment; filename="../../served.zip"`)
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(content)
+		}
+	}))
+	defer server.Close()
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "out")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	dl := New(Config{OutputDir: dir, Parallel: 1, RetryAttempts: 3, BackoffBase: time.Millisecond, HonorContentDisposition: true})
+
+	// Names from a tampered plan file or a hostile listing
+	for _, name := range []string{"../escape.zip", "sub/../../escape.zip", "/tmp/escape.zip"} {
+		err := dl.DownloadAll(context.Background(), []parser.FileInfo{{Name: name, URL: server.URL + "/escape.zip"}})
+		if !errors.Is(err, fsutil.ErrUnsafePath) {
+			t.Errorf("%s: expected ErrUnsafePath, got %v", name, err)
+		}
+	}
+	if requests.Load() != 0 {
+		t.Errorf("expected no requests for unsafe names, got %d", requests.Load())
+	}
+	if _, err := os.Stat(filepath.Join(root, "escape.zip")); !os.IsNotExist(err) {
+		t.Error("expected nothing written outside the output directory")
+	}
+
+	// A served name is sanitized to a base name, so it stays inside
+	if err := dl.DownloadAll(context.Background(), []parser.FileInfo{{Name: "listed.zip", URL: server.URL + "/served.zip"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "out" {
+		t.Errorf("expected only the output directory in %s, got %v", root, entries)
+	}
+}
+
+func TestDownloader_Recorded(t *testing.T) {
+	content := []byte("recorded game data")
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(content)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	for _, name := range []string{"kept.zip", "shrunk.zip", "relisted.zip"} {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Truncate(filepath.Join(dir, "shrunk.zip"), 3); err != nil {
+		t.Fatal(err)
+	}
+	size := int64(len(content))
+	recorded := map[string]int64{"kept.zip": size, "shrunk.zip": size, "relisted.zip": size, "gone.zip": size}
+
+	var skipped []string
+	dl := New(Config{
+		OutputDir: dir, Parallel: 1, RetryAttempts: 1,
+		Recorded: func(f parser.FileInfo) (string, int64, bool) {
+			n, ok := recorded[f.Name]
+			return f.Name, n, ok
+		},
+		OnEvent: func(e Event) {
+			if e.Type == EventSkipped {
+				skipped = append(skipped, e.Path)
+			}
+		},
+	})
+	files := []parser.FileInfo{
+		{Name: "kept.zip", URL: server.URL + "/kept.zip", Size: size},
+		{Name: "shrunk.zip", URL: server.URL + "/shrunk.zip", Size: size},
+		{Name: "relisted.zip", URL: server.URL + "/relisted.zip", Size: 10 << 20}, // The listing says it grew
+		{Name: "gone.zip", URL: server.URL + "/gone.zip"},
+	}
+	if err := dl.DownloadAll(context.Background(), files); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(skipped) == 0 || skipped[0] != "kept.zip" {
+		t.Errorf("expected kept.zip skipped from the manifest, got %v", skipped)
+	}
+	// kept.zip needed no requests; relisted.zip is only checked (HEAD), the
+	// other two are checked and downloaded
+	if got := requests.Load(); got != 5 {
+		t.Errorf("expected 5 requests, got %d", got)
+	}
+}
+
+func TestDownloader_Layout(t *testing.T) {
+	content := []byte("game data")
+	var gets atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+		if r.Method == http.MethodGet {
+			gets.Add(1)
+			_, _ = w.Write(content)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	// Already moved into the layout, so it isn't downloaded again
+	if err := os.MkdirAll(filepath.Join(dir, "A"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "A", "alpha.zip"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dl := New(Config{
+		OutputDir:     dir,
+		Parallel:      1,
+		RetryAttempts: 1,
+		Layout:        func(name string) string { return strings.ToUpper(name[:1]) + "/" + name },
+	})
+	files := []parser.FileInfo{
+		{Name: "alpha.zip", URL: server.URL + "/alpha.zip"},
+		{Name: "beta.zip", URL: server.URL + "/beta.zip"},
+	}
+	if err := dl.DownloadAll(context.Background(), files); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if gets.Load() != 1 {
+		t.Errorf("expected only beta.zip to be downloaded, got %d downloads", gets.Load())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "B", "beta.zip")); err != nil {
+		t.Errorf("expected beta.zip saved in the layout: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "beta.zip")); !os.IsNotExist(err) {
+		t.Error("expected nothing saved under the listed name")
+	}
+}
+
+func TestDownloader_HeadCache(t *testing.T) {
+	content := []byte("game data")
+	var heads, gets atomic.Int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			heads.Add(1)
+			w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+			return
+		}
+		if gets.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(content)
+	}))
+
+	dl := New(Config{OutputDir: t.TempDir(), Parallel: 1, RetryAttempts: 3})
+	files := []parser.FileInfo{{Name: "game.zip", URL: server.URL + "/game.zip"}}
+	if err := dl.DownloadAll(context.Background(), files); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// A second pass in the same run skips the file without asking again
+	if err := dl.DownloadAll(context.Background(), files); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	server.Close()
+
+	if gets.Load() != 2 {
+		t.Errorf("expected 2 GETs (one failed), got %d", gets.Load())
+	}
+	if heads.Load() != 1 {
+		t.Errorf("expected a single HEAD across retries and passes, got %d", heads.Load())
+	}
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	if traffic := dl.Traffic(); traffic[host] != int64(len(content)) {
+		t.Errorf("expected %d bytes from %s, got %v", len(content), host, traffic)
+	}
+}
+
+func TestDownloader_RedirectCache(t *testing.T) {
+	content := []byte("game data")
+
+	var cdnHits atomic.Int64
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cdnHits.Add(1)
+		if !strings.HasPrefix(r.URL.Path, "/mirror/files/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(content)
+		}
+	}))
+	defer cdn.Close()
+
+	var originHits atomic.Int64
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		originHits.Add(1)
+		http.Redirect(w, r, cdn.URL+"/mirror"+r.URL.Path, http.StatusFound)
+	}))
+	defer origin.Close()
+
+	dir := t.TempDir()
+	dl := New(Config{OutputDir: dir, Parallel: 1, RetryAttempts: 1})
+	files := []parser.FileInfo{
+		{Name: "a.zip", URL: origin.URL + "/files/a.zip"},
+		{Name: "b.zip", URL: origin.URL + "/files/b.zip"},
+		{Name: "c.zip", URL: origin.URL + "/files/c.zip"},
+	}
+	if err := dl.DownloadAll(context.Background(), files); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if originHits.Load() != 1 {
+		t.Errorf("expected only the first request to hit the origin, got %d", originHits.Load())
+	}
+	if cdnHits.Load() != 6 {
+		t.Errorf("expected a HEAD and GET per file on the CDN, got %d", cdnHits.Load())
+	}
+	for _, f := range files {
+		if _, err := os.Stat(filepath.Join(dir, f.Name)); err != nil {
+			t.Errorf("expected %s to be downloaded: %v", f.Name, err)
+		}
+	}
+}
+
+func TestRedirects_Learn(t *testing.T) {
+	parse := func(s string) *url.URL {
+		u, err := url.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+
+	tests := []struct {
+		name      string
+		requested string
+		final     string
+		rewritten string // Expected rewrite of another file on the same origin
+	}{
+		{"host swap", "https://myrient.example/files/a.zip", "https://cdn.example/files/a.zip", "https://cdn.example/files/b%20c.zip"},
+		{"host swap with prefix", "https://myrient.example/files/a.zip", "https://cdn.example/m1/files/a.zip", "https://cdn.example/m1/files/b%20c.zip"},
+		{"signed url", "https://myrient.example/files/a.zip", "https://cdn.example/files/a.zip?token=x", "https://myrient.example/files/b%20c.zip"},
+		{"different path", "https://myrient.example/files/a.zip", "https://cdn.example/download/123", "https://myrient.example/files/b%20c.zip"},
+		{"no redirect", "https://myrient.example/files/a.zip", "https://myrient.example/files/a.zip", "https://myrient.example/files/b%20c.zip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r redirects
+			r.learn(parse(tt.requested), parse(tt.final))
+			if got := r.rewrite("https://myrient.example/files/b%20c.zip"); got != tt.rewritten {
+				t.Errorf("expected %s, got %s", tt.rewritten, got)
+			}
+
+			r.forget(tt.requested)
+			if got := r.rewrite("https://myrient.example/files/b%20c.zip"); got != "https://myrient.example/files/b%20c.zip" {
+				t.Errorf("expected rule to be forgotten, got %s", got)
+			}
+		})
+	}
+}
+
+func TestDownloader_RangeSupport(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 10000)
+
+	tests := []struct {
+		name         string
+		acceptRanges string
+		expectRanged int64 // GETs that carried a Range header
+	}{
+		// Not advertised: the first file tries, the refusal is remembered for the second
+		{"not advertised and ignored", "", 1},
+		// Explicitly unsupported: never tried
+		{"advertised none", "none", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rangeGets atomic.Int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet && r.Header.Get("Range") != "" {
+					rangeGets.Add(1)
+				}
+				if tt.acceptRanges != "" {
+					w.Header().Set("Accept-Ranges", tt.acceptRanges)
+				}
+				w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+				if r.Method == http.MethodGet {
+					_, _ = w.Write(content) // Always the full body, ignoring Range
+				}
+			}))
+
+			dir := t.TempDir()
+			files := []parser.FileInfo{
+				{Name: "a.zip", URL: server.URL + "/a.zip"},
+				{Name: "b.zip", URL: server.URL + "/b.zip"},
+			}
+			for _, f := range files {
+				if err := os.WriteFile(filepath.Join(dir, f.Name), content[:1000], 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			dl := New(Config{OutputDir: dir, Parallel: 1, RetryAttempts: 1, ContinueExisting: true})
+			if err := dl.DownloadAll(context.Background(), files); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			server.Close()
+
+			if rangeGets.Load() != tt.expectRanged {
+				t.Errorf("expected %d range requests, got %d", tt.expectRanged, rangeGets.Load())
+			}
+			for _, f := range files {
+				data, err := os.ReadFile(filepath.Join(dir, f.Name))
+				if err != nil || !bytes.Equal(data, content) {
+					t.Errorf("expected %s to be re-downloaded in full", f.Name)
+				}
+			}
+			if s := dl.Summary(); s.Continued != 0 || s.Completed != 2 {
+				t.Errorf("unexpected summary %+v", s)
+			}
+		})
+	}
+}
+
+func TestDownloader_Placeholders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/empty.zip":
+			w.Header().Set("Content-Length", "0")
+		case "/moved.zip", "/page.html":
+			page := "<html><body>This file has moved</body></html>"
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Content-Length", fmt.Sprint(len(page)))
+			if r.Method == http.MethodGet {
+				_, _ = w.Write([]byte(page))
+			}
+		default:
+			w.Header().Set("Content-Type", "application/zip")
+			w.Header().Set("Content-Length", "5")
+			if r.Method == http.MethodGet {
+				_, _ = w.Write([]byte("hello"))
+			}
+		}
+	}))
+	defer server.Close()
+
+	files := []parser.FileInfo{
+		{Name: "empty.zip", URL: server.URL + "/empty.zip"},
+		{Name: "moved.zip", URL: server.URL + "/moved.zip"},
+		{Name: "page.html", URL: server.URL + "/page.html"},
+		{Name: "real.zip", URL: server.URL + "/real.zip"},
+	}
+
+	tests := []struct {
+		policy    PlaceholderPolicy
+		saved     []string
+		completed int
+	}{
+		{PlaceholderSkip, []string{"page.html", "real.zip"}, 2},
+		{PlaceholderWarn, []string{"empty.zip", "moved.zip", "page.html", "real.zip"}, 2},
+		{PlaceholderDownload, []string{"empty.zip", "moved.zip", "page.html", "real.zip"}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			dir := t.TempDir()
+			var mu sync.Mutex
+			placeholders := 0
+			dl := New(Config{
+				OutputDir:     dir,
+				Parallel:      1,
+				RetryAttempts: 1,
+				Placeholders:  tt.policy,
+				OnEvent: func(e Event) {
+					mu.Lock()
+					defer mu.Unlock()
+					if e.Type == EventPlaceholder {
+						placeholders++
+					}
+				},
+			})
+			if err := dl.DownloadAll(context.Background(), files); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var saved []string
+			for _, e := range entries {
+				saved = append(saved, e.Name())
+			}
+			if fmt.Sprint(saved) != fmt.Sprint(tt.saved) {
+				t.Errorf("expected %v saved, got %v", tt.saved, saved)
+			}
+
+			summary := dl.Summary()
+			if summary.Completed != tt.completed || summary.Placeholders != 2 || placeholders != 2 {
+				t.Errorf("unexpected summary %+v (%d placeholder events)", summary, placeholders)
+			}
+
+			// A second run doesn't turn an existing placeholder into a success
+			if err := dl.DownloadAll(context.Background(), files); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if summary := dl.Summary(); summary.Placeholders != 2 {
+				t.Errorf("expected placeholders to stay flagged on rerun, got %+v", summary)
+			}
+		})
+	}
+}
+
+func TestParsePlaceholderPolicy(t *testing.T) {
+	if p, err := ParsePlaceholderPolicy("SKIP"); err != nil || p != PlaceholderSkip {
+		t.Errorf("expected skip, got %s (err %v)", p, err)
+	}
+	if _, err := ParsePlaceholderPolicy("ignore"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
+
+func TestParseMismatchPolicy(t *testing.T) {
+	if p, err := ParseMismatchPolicy("Rename"); err != nil || p != MismatchRename {
+		t.Errorf("expected rename, got %s (err %v)", p, err)
+	}
+	if _, err := ParseMismatchPolicy("replace"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
+
+func TestDownloader_PostVerify(t *testing.T) {
+	var mu sync.Mutex
+	etags := map[string]string{"/a.zip": `"a1"`, "/b.zip": `"b1"`, "/c.zip": `"c1"`}
+	sizes := map[string]int{"/a.zip": 5, "/b.zip": 5, "/c.zip": 5}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		etag, size := etags[r.URL.Path], sizes[r.URL.Path]
+		mu.Unlock()
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Length", fmt.Sprint(size))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(bytes.Repeat([]byte("x"), size))
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "c.zip"), []byte("xxxxx"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	dl := New(Config{OutputDir: dir, Parallel: 1, RetryAttempts: 1})
+	files := []parser.FileInfo{
+		{Name: "a.zip", URL: server.URL + "/a.zip"},
+		{Name: "b.zip", URL: server.URL + "/b.zip"},
+		{Name: "c.zip", URL: server.URL + "/c.zip"}, // Skipped, so never re-checked
+	}
+	if err := dl.DownloadAll(context.Background(), files); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The remote changes after the batch
+	mu.Lock()
+	etags["/a.zip"] = `"a2"`
+	sizes["/b.zip"] = 6
+	etags["/c.zip"] = `"c2"`
+	mu.Unlock()
+
+	mismatches, checked, err := dl.PostVerify(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if checked != 2 || len(mismatches) != 2 {
+		t.Fatalf("expected 2 of 2 checked files to mismatch, got %d of %d: %+v", len(mismatches), checked, mismatches)
+	}
+	if mismatches[0].Path != "a.zip" || !strings.Contains(mismatches[0].Reason, "ETag") {
+		t.Errorf("unexpected mismatch %+v", mismatches[0])
+	}
+	if mismatches[1].Path != "b.zip" || !strings.Contains(mismatches[1].Reason, "size") {
+		t.Errorf("unexpected mismatch %+v", mismatches[1])
+	}
+
+	_, checked, err = dl.PostVerify(context.Background(), 1)
+	if err != nil || checked != 1 {
+		t.Errorf("expected a sample of 1, got %d (%v)", checked, err)
+	}
+}
+
+func TestDownloader_WorkerStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		if r.Method == http.MethodGet {
+			time.Sleep(10 * time.Millisecond)
+			_, _ = w.Write(bytes.Repeat([]byte("x"), 100))
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	var files []parser.FileInfo
+	for i := 0; i < 4; i++ {
+		name := fmt.Sprintf("file%d.zip", i)
+		files = append(files, parser.FileInfo{Name: name, URL: server.URL + "/" + name, Size: 100})
+	}
+
+	dl := New(Config{OutputDir: dir, Parallel: 2, RetryAttempts: 1})
+	if err := dl.DownloadAll(context.Background(), files); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	workers := dl.Summary().Workers
+	if len(workers) != 2 {
+		t.Fatalf("expected 2 workers, got %d", len(workers))
+	}
+	var count int
+	var transferred int64
+	for i, w := range workers {
+		count += w.Files
+		transferred += w.Bytes
+		if w.Files > 0 && (w.Downloading <= 0 || w.Speed() <= 0) {
+			t.Errorf("worker %d: expected download time and speed, got %+v", i, w)
+		}
+	}
+	if count != 4 || transferred != 400 {
+		t.Errorf("expected 4 files and 400 bytes across workers, got %d and %d", count, transferred)
+	}
+
+	if (WorkerStats{}).Speed() != 0 {
+		t.Error("expected idle worker speed of 0")
+	}
+}
+
+func TestDownloader_Drain(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		if r.Method == http.MethodGet {
+			time.Sleep(20 * time.Millisecond)
+			_, _ = w.Write(bytes.Repeat([]byte("x"), 100))
+		}
+	}))
+	defer server.Close()
+
+	for _, parallel := range []int{1, 2} {
+		t.Run(fmt.Sprintf("parallel %d", parallel), func(t *testing.T) {
+			var files []parser.FileInfo
+			for i := 0; i < 6; i++ {
+				name := fmt.Sprintf("file%d.zip", i)
+				files = append(files, parser.FileInfo{Name: name, URL: server.URL + "/" + name, Size: 100})
+			}
+
+			var dl *Downloader
+			dl = New(Config{
+				OutputDir:     t.TempDir(),
+				Parallel:      parallel,
+				RetryAttempts: 1,
+				OnEvent: func(e Event) {
+					if e.Type == EventStarted {
+						dl.Drain() // Like an interrupt arriving mid-download
+					}
+				},
+			})
+
+			err := dl.DownloadAll(context.Background(), files)
+			if !errors.Is(err, ErrStopped) {
+				t.Fatalf("expected ErrStopped, got %v", err)
+			}
+			summary := dl.Summary()
+			if summary.Failed != 0 || summary.Completed < 1 || summary.Completed > parallel {
+				t.Errorf("expected in-flight files to finish and no new ones to start, got %+v", summary)
+			}
+		})
+	}
+}
+
+func TestDownloader_Rejected(t *testing.T) {
+	var gets atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "5")
+		if r.Method == http.MethodGet {
+			gets.Add(1)
+			_, _ = w.Write([]byte("hello"))
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	var events []EventType
+	var mu sync.Mutex
+	dl := New(Config{
+		OutputDir:     dir,
+		Parallel:      1,
+		RetryAttempts: 3,
+		VerifyRetries: 2,
+		Verifier: VerifierFunc(func(file parser.FileInfo, _ string) error {
+			if file.Name == "bad.zip" {
+				return fmt.Errorf("%w: blocklisted", ErrRejected)
+			}
+			return nil
+		}),
+		OnEvent: func(e Event) {
+			mu.Lock()
+			events = append(events, e.Type)
+			mu.Unlock()
+		},
+	})
+
+	files := []parser.FileInfo{
+		{Name: "bad.zip", URL: server.URL + "/bad.zip"},
+		{Name: "good.zip", URL: server.URL + "/good.zip"},
+	}
+	if err := dl.DownloadAll(context.Background(), files); err != nil {
+		t.Fatalf("a rejected file should not fail the run: %v", err)
+	}
+
+	summary := dl.Summary()
+	if summary.Rejected != 1 || summary.Completed != 1 || summary.Failed != 0 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+	if gets.Load() != 2 {
+		t.Errorf("expected rejected file not to be retried, got %d GETs", gets.Load())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "bad.zip")); !os.IsNotExist(err) {
+		t.Error("expected rejected file to be discarded")
+	}
+	if len(events) != 4 || events[1] != EventRejected || events[3] != EventCompleted {
+		t.Errorf("unexpected events %v", events)
+	}
+}
+
+func TestDownloader_IgnoreMissing(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/removed.zip" {
+			requests.Add(1)
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Length", "5")
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte("hello"))
+		}
+	}))
+	defer server.Close()
+
+	files := []parser.FileInfo{
+		{Name: "removed.zip", URL: server.URL + "/removed.zip"},
+		{Name: "kept.zip", URL: server.URL + "/kept.zip"},
+	}
+
+	t.Run("ignored", func(t *testing.T) {
+		requests.Store(0)
+		var events []EventType
+		dl := New(Config{
+			OutputDir:     t.TempDir(),
+			Parallel:      1,
+			RetryAttempts: 3,
+			BackoffBase:   time.Millisecond,
+			IgnoreMissing: true,
+			OnEvent:       func(e Event) { events = append(events, e.Type) },
+		})
+		if err := dl.DownloadAll(context.Background(), files); err != nil {
+			t.Fatalf("a missing file should not fail the run: %v", err)
+		}
+		summary := dl.Summary()
+		if summary.Gone != 1 || summary.Completed != 1 || summary.Failed != 0 {
+			t.Errorf("unexpected summary %+v", summary)
+		}
+		if requests.Load() != 1 {
+			t.Errorf("expected a missing file not to be retried, got %d requests", requests.Load())
+		}
+		if len(events) != 4 || events[1] != EventGone || events[3] != EventCompleted {
+			t.Errorf("unexpected events %v", events)
+		}
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		requests.Store(0)
+		dl := New(Config{OutputDir: t.TempDir(), Parallel: 1, RetryAttempts: 3, BackoffBase: time.Millisecond})
+		err := dl.DownloadAll(context.Background(), files)
+		if !errors.Is(err, ErrGone) {
+			t.Fatalf("expected ErrGone without IgnoreMissing, got %v", err)
+		}
+		if requests.Load() != 3 {
+			t.Errorf("expected a missing file to be retried, got %d requests", requests.Load())
+		}
+	})
+}
+
+func TestDownloader_Stall(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		speed   int64
+		wantErr string
+	}{
+		{
+			name: "quiet connection",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", "100")
+				if r.Method == http.MethodGet {
+					_, _ = w.Write([]byte("he"))
+					w.(http.Flusher).Flush()
+					<-r.Context().Done()
+				}
+			},
+			wantErr: "transfer stalled",
+		},
+		{
+			name: "too slow",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", "100")
+				if r.Method != http.MethodGet {
+					return
+				}
+				for i := 0; i < 100; i++ {
+					if _, err := w.Write([]byte("x")); err != nil {
+						return
+					}
+					w.(http.Flusher).Flush()
+					select {
+					case <-time.After(20 * time.Millisecond):
+					case <-r.Context().Done():
+						return
+					}
+				}
+			},
+			speed:   1000,
+			wantErr: "under 1000 bytes/s",
+		},
+		{
+			name: "no response",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			},
+			wantErr: "timeout awaiting response headers",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			dl := New(Config{OutputDir: t.TempDir(), Parallel: 1, RetryAttempts: 1, StallTimeout: 200 * time.Millisecond, StallSpeed: tt.speed})
+			start := time.Now()
+			_, err := dl.downloadFileWithRetry(context.Background(), parser.FileInfo{Name: "slow.bin", URL: server.URL + "/slow.bin"})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("took %v to notice the stall", elapsed)
+			}
+		})
+	}
+
+	// A steady transfer longer than the stall timeout is not cut off
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "10")
+		if r.Method != http.MethodGet {
+			return
+		}
+		for i := 0; i < 10; i++ {
+			_, _ = w.Write([]byte("x"))
+			w.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+	dl := New(Config{OutputDir: t.TempDir(), Parallel: 1, RetryAttempts: 1, StallTimeout: 200 * time.Millisecond})
+	if _, err := dl.downloadFileWithRetry(context.Background(), parser.FileInfo{Name: "steady.bin", URL: server.URL + "/steady.bin"}); err != nil {
+		t.Errorf("a steady download was cut off: %v", err)
+	}
+
+	// Time held by the bandwidth cap doesn't count, even with a minimum speed above the cap
+	capped := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "4000")
+		if r.Method != http.MethodGet {
+			return
+		}
+		for i := 0; i < 4; i++ {
+			if _, err := w.Write(bytes.Repeat([]byte("x"), 1000)); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+			time.Sleep(10 * time.Millisecond)
+		}
+	}))
+	defer capped.Close()
+	dl = New(Config{OutputDir: t.TempDir(), Parallel: 1, RetryAttempts: 1, StallTimeout: 100 * time.Millisecond, StallSpeed: 100000, RateLimit: 2000})
+	if _, err := dl.downloadFileWithRetry(context.Background(), parser.FileInfo{Name: "capped.bin", URL: capped.URL + "/capped.bin"}); err != nil {
+		t.Errorf("a download held by the bandwidth cap was taken for a stall: %v", err)
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	w, err := ParseWindow("22:30-06:00")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.Start != 22*time.Hour+30*time.Minute || w.End != 6*time.Hour || w.String() != "22:30-06:00" {
+		t.Errorf("unexpected window %+v", w)
+	}
+
+	for _, bad := range []string{"", "01:00", "1am-7am", "25:00-07:00", "01:00-01:00"} {
+		if _, err := ParseWindow(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestWindow_Until(t *testing.T) {
+	day := func(h, m int) time.Time { return time.Date(2024, 5, 1, h, m, 0, 0, time.Local) }
+	night := Window{Start: 1 * time.Hour, End: 7 * time.Hour}
+	overnight := Window{Start: 22 * time.Hour, End: 6 * time.Hour}
+
+	tests := []struct {
+		name   string
+		window Window
+		now    time.Time
+		want   time.Duration
+	}{
+		{"inside", night, day(3, 0), 0},
+		{"at start", night, day(1, 0), 0},
+		{"at end", night, day(7, 0), 18 * time.Hour},
+		{"before", night, day(0, 30), 30 * time.Minute},
+		{"after", night, day(12, 0), 13 * time.Hour},
+		{"overnight late", overnight, day(23, 0), 0},
+		{"overnight early", overnight, day(5, 0), 0},
+		{"overnight closed", overnight, day(12, 0), 10 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.window.Until(tt.now); got != tt.want {
+				t.Errorf("Until(%s) = %v, want %v", tt.now.Format("15:04"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindow_Remaining(t *testing.T) {
+	day := func(h, m int) time.Time { return time.Date(2024, 5, 1, h, m, 0, 0, time.Local) }
+	night := Window{Start: 1 * time.Hour, End: 7 * time.Hour}
+	overnight := Window{Start: 22 * time.Hour, End: 6 * time.Hour}
+
+	tests := []struct {
+		name   string
+		window Window
+		now    time.Time
+		want   time.Duration
+	}{
+		{"inside", night, day(3, 0), 4 * time.Hour},
+		{"closed", night, day(12, 0), 0},
+		{"overnight late", overnight, day(23, 0), 7 * time.Hour},
+		{"overnight early", overnight, day(5, 30), 30 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.window.Remaining(tt.now); got != tt.want {
+				t.Errorf("Remaining(%s) = %v, want %v", tt.now.Format("15:04"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloader_Suspend(t *testing.T) {
+	// Windows an hour either side of now, wrapping past midnight as needed
+	now := time.Now()
+	offset := now.Sub(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()))
+	at := func(d time.Duration) time.Duration { return (offset + d + 24*time.Hour) % (24 * time.Hour) }
+	open := &Window{Start: at(-time.Hour), End: at(time.Hour)}
+	closed := &Window{Start: at(time.Hour), End: at(2 * time.Hour)}
+
+	body := &windowBody{ReadCloser: io.NopCloser(strings.NewReader("data")), window: open}
+	if data, err := io.ReadAll(body); err != nil || string(data) != "data" {
+		t.Errorf("expected reads to pass through an open window, got %q, %v", data, err)
+	}
+	body = &windowBody{ReadCloser: io.NopCloser(strings.NewReader("data")), window: closed}
+	if _, err := body.Read(make([]byte, 4)); !errors.Is(err, errSuspended) {
+		t.Errorf("expected a closed window to suspend reads, got %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Length", "4")
+		_, _ = w.Write([]byte("data"))
+	}))
+	defer server.Close()
+
+	// A suspended download waits for the window, or stops when drained
+	d := New(Config{OutputDir: t.TempDir(), RetryAttempts: 1, Window: closed, Suspend: true})
+	d.Drain()
+	_, err := d.downloadFileWithRetry(context.Background(), parser.FileInfo{Name: "a.bin", URL: server.URL + "/a.bin"})
+	if !errors.Is(err, ErrStopped) {
+		t.Errorf("expected a drained suspended download to stop, got %v", err)
+	}
+
+	d = New(Config{OutputDir: t.TempDir(), RetryAttempts: 1, Window: open, Suspend: true})
+	if _, err := d.downloadFileWithRetry(context.Background(), parser.FileInfo{Name: "a.bin", URL: server.URL + "/a.bin"}); err != nil {
+		t.Errorf("expected a download inside the window to finish, got %v", err)
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	night := &Window{Start: 1 * time.Hour, End: 7 * time.Hour}
+	const gb = 1_000_000_000
+
+	tests := []struct {
+		name      string
+		bytes     int64
+		speed     float64
+		rateLimit int64
+		window    *Window
+		want      time.Duration
+		wantOK    bool
+	}{
+		{"unknown speed", gb, 0, 0, nil, 0, false},
+		{"nothing left", 0, 0, 0, nil, 0, true},
+		{"observed speed", gb, gb / 100, 0, nil, 100 * time.Second, true},
+		{"capped", gb, gb / 10, gb / 100, nil, 100 * time.Second, true},
+		{"cap before any data", gb, 0, gb / 100, nil, 100 * time.Second, true},
+		// Waits 13h for the window, then 2h of work
+		{"window", 2 * 3600 * (gb / 1000), gb / 1000, 0, night, 15 * time.Hour, true},
+		// 6h tonight, then 2h the next night after 18h closed
+		{"several nights", 8 * 3600 * (gb / 1000), gb / 1000, 0, night, 13*time.Hour + 6*time.Hour + 18*time.Hour + 2*time.Hour, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Estimate(tt.bytes, tt.speed, tt.rateLimit, tt.window, now)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("Estimate() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestFormatETA(t *testing.T) {
+	tests := map[time.Duration]string{
+		42 * time.Second:                 "42s",
+		5*time.Hour + 20*time.Minute + 9: "5h20m",
+		90 * time.Second:                 "2m",
+	}
+	for eta, want := range tests {
+		if got := FormatETA(eta); got != want {
+			t.Errorf("FormatETA(%v) = %q, want %q", eta, got, want)
+		}
+	}
+}
+
+func TestDownloader_Throttling(t *testing.T) {
+	body := bytes.Repeat([]byte("x"), 150_000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(body)
+		}
+	}))
+	defer server.Close()
+
+	t.Run("rate limit", func(t *testing.T) {
+		dl := New(Config{OutputDir: t.TempDir(), Parallel: 1, RetryAttempts: 1, RateLimit: 100_000})
+		start := time.Now()
+		if err := dl.DownloadAll(context.Background(), []parser.FileInfo{{Name: "a.zip", URL: server.URL + "/a.zip"}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// One second of burst, then 50 KB at 100 KB/s
+		if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+			t.Errorf("expected the rate limit to slow the download, took %v", elapsed)
+		}
+	})
+
+	t.Run("request interval", func(t *testing.T) {
+		dl := New(Config{OutputDir: t.TempDir(), Parallel: 2, RetryAttempts: 1, RequestInterval: 50 * time.Millisecond})
+		files := []parser.FileInfo{
+			{Name: "a.zip", URL: server.URL + "/a.zip"},
+			{Name: "b.zip", URL: server.URL + "/b.zip"},
+			{Name: "c.zip", URL: server.URL + "/c.zip"},
+		}
+		start := time.Now()
+		if err := dl.DownloadAll(context.Background(), files); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// A HEAD and a GET per file, spaced across workers
+		if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+			t.Errorf("expected requests to be paced, took %v", elapsed)
+		}
+	})
+}
+
+func TestDownloader_Mirrors(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "5")
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte("hello"))
+		}
+	}))
+	defer mirror.Close()
+
+	dir := t.TempDir()
+	var served string
+	dl := New(Config{
+		OutputDir:     dir,
+		Parallel:      1,
+		RetryAttempts: 1,
+		Mirrors: func(fileURL string) []string {
+			return []string{strings.Replace(fileURL, primary.URL, mirror.URL, 1)}
+		},
+		OnEvent: func(e Event) {
+			if e.Type == EventCompleted {
+				served = e.Mirror
+			}
+		},
+	})
+
+	files := []parser.FileInfo{{Name: "a.zip", URL: primary.URL + "/files/a.zip"}}
+	if err := dl.DownloadAll(context.Background(), files); err != nil {
+		t.Fatalf("expected the mirror to serve the file: %v", err)
+	}
+	if served != mirror.URL+"/files/a.zip" {
+		t.Errorf("expected the event to name the mirror, got %q", served)
+	}
+	if summary := dl.Summary(); summary.Mirrored != 1 || summary.Completed != 1 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "a.zip")); err != nil || string(data) != "hello" {
+		t.Errorf("expected file from mirror, got %q (%v)", data, err)
+	}
+
+	// Without a working mirror the original failure stands
+	dl = New(Config{OutputDir: t.TempDir(), Parallel: 1, RetryAttempts: 1, Mirrors: func(string) []string { return nil }})
+	if err := dl.DownloadAll(context.Background(), files); err == nil {
+		t.Error("expected failure without mirrors")
+	}
+}
+
+func TestDownloader_ScopedAuth(t *testing.T) {
+	var leaked atomic.Int64
+	serve := func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "" {
+			leaked.Add(1)
+		}
+		w.Header().Set("Content-Length", "5")
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte("hello"))
+		}
+	}
+	cdn := httptest.NewServer(http.HandlerFunc(serve))
+	defer cdn.Close()
+	mirror := httptest.NewServer(http.HandlerFunc(serve))
+	defer mirror.Close()
+
+	var keyed atomic.Int64
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") == "secret" {
+			keyed.Add(1)
+		}
+		if strings.HasPrefix(r.URL.Path, "/down/") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		http.Redirect(w, r, cdn.URL+r.URL.Path, http.StatusFound)
+	}))
+	defer origin.Close()
+
+	dl := New(Config{
+		OutputDir:     t.TempDir(),
+		Parallel:      1,
+		RetryAttempts: 1,
+		Auth:          auth.NewScoped(auth.Static{Name: "X-Api-Key", Value: "secret"}, origin.URL),
+		Mirrors: func(fileURL string) []string {
+			return []string{strings.Replace(fileURL, origin.URL, mirror.URL, 1)}
+		},
+	})
+	files := []parser.FileInfo{
+		{Name: "a.zip", URL: origin.URL + "/files/a.zip"}, // Redirected to the CDN
+		{Name: "b.zip", URL: origin.URL + "/files/b.zip"}, // Rewritten to the CDN
+		{Name: "c.zip", URL: origin.URL + "/down/c.zip"},  // Served by the mirror
+	}
+	if err := dl.DownloadAll(context.Background(), files); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if keyed.Load() == 0 {
+		t.Error("expected the origin to get the credentials")
+	}
+	if n := leaked.Load(); n != 0 {
+		t.Errorf("expected no credentials at the CDN or mirror, got %d requests with them", n)
+	}
+}
+
+func TestDownloader_TempJournal(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 10000) // 160,000 bytes
+	modTime := time.Now()
+	const confirmed = 100000
+
+	sum := func(b []byte) string {
+		h := sha256.Sum256(b)
+		return hex.EncodeToString(h[:])
+	}
+
+	tests := []struct {
+		name         string
+		ranges       bool
+		temp         []byte
+		journal      *journal // URL and Size are filled in when zero
+		expectServed int64
+		continued    int
+	}{
+		{
+			name:         "resumes from confirmed offset",
+			ranges:       true,
+			temp:         append(append([]byte{}, content[:confirmed]...), "unconfirmed"...),
+			journal:      &journal{Offset: confirmed, SHA256: sum(content[:confirmed])},
+			expectServed: int64(len(content)) - confirmed,
+			continued:    1,
+		},
+		{
+			name:         "corrupted temp file is discarded",
+			ranges:       true,
+			temp:         bytes.Repeat([]byte("x"), confirmed),
+			journal:      &journal{Offset: confirmed, SHA256: sum(content[:confirmed])},
+			expectServed: int64(len(content)),
+		},
+		{
+			name:         "journal for a different size is discarded",
+			ranges:       true,
+			temp:         content[:confirmed],
+			journal:      &journal{Size: 42, Offset: confirmed, SHA256: sum(content[:confirmed])},
+			expectServed: int64(len(content)),
+		},
+		{
+			name:         "temp file without journal is discarded",
+			ranges:       true,
+			temp:         content[:confirmed],
+			expectServed: int64(len(content)),
+		},
+		{
+			name:         "server ignoring range restarts from scratch",
+			ranges:       false,
+			temp:         content[:confirmed],
+			journal:      &journal{Offset: confirmed, SHA256: sum(content[:confirmed])},
+			expectServed: int64(len(content)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var served atomic.Int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !tt.ranges {
+					r.Header.Del("Range")
+				}
+				cw := &countingWriter{ResponseWriter: w}
+				http.ServeContent(cw, r, "game.zip", modTime, bytes.NewReader(content))
+				if r.Method == http.MethodGet {
+					served.Add(cw.n)
+				}
+			}))
+
+			dir := t.TempDir()
+			file := parser.FileInfo{Name: "game.zip", URL: server.URL + "/game.zip"}
+			tempPath := filepath.Join(dir, "game.zip"+cleanup.TempSuffix)
+			if err := os.WriteFile(tempPath, tt.temp, 0600); err != nil {
+				t.Fatal(err)
+			}
+			if tt.journal != nil {
+				j := *tt.journal
+				j.URL = file.URL
+				if j.Size == 0 {
+					j.Size = int64(len(content))
+				}
+				if err := j.save(journalPath(tempPath)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			dl := New(Config{OutputDir: dir, Parallel: 1, RetryAttempts: 1})
+			err := dl.DownloadAll(context.Background(), []parser.FileInfo{file})
+			server.Close() // Waits for handlers so the served count is final
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got, err := os.ReadFile(filepath.Join(dir, "game.zip")) //nolint:gosec // Test file path is safe (from t.TempDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("file content mismatch (got %d bytes)", len(got))
+			}
+			if served.Load() != tt.expectServed {
+				t.Errorf("expected %d bytes served, got %d", tt.expectServed, served.Load())
+			}
+			if dl.Summary().Continued != tt.continued {
+				t.Errorf("expected %d continued files, got %d", tt.continued, dl.Summary().Continued)
+			}
+			for _, leftover := range []string{tempPath, journalPath(tempPath)} {
+				if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+					t.Errorf("expected %s to be removed", filepath.Base(leftover))
+				}
+			}
+		})
+	}
+}
+
+func TestDownloader_TempJournalInterrupted(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), (journalInterval+journalInterval/2)/16)
+	modTime := time.Now()
+
+	var gets atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && gets.Add(1) == 1 {
+			// Send a little over one checkpoint's worth, then drop the connection
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			_, _ = w.Write(content[:journalInterval+1000])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "game.zip", modTime, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	var digest string
+	var onDisk []int64
+	dl := New(Config{
+		OutputDir: dir, Parallel: 1, RetryAttempts: 2, BackoffBase: time.Millisecond, BackoffMax: time.Millisecond,
+		OnEvent: func(e Event) {
+			if e.Type == EventCompleted {
+				digest = e.SHA256
+			}
+		},
+		OnProgress: func(_ parser.FileInfo, n int64) { onDisk = append(onDisk, n) },
+	})
+	file := parser.FileInfo{Name: "game.zip", URL: server.URL + "/game.zip"}
+	if err := dl.DownloadAll(context.Background(), []parser.FileInfo{file}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, "game.zip")) //nolint:gosec // Test file path is safe (from t.TempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("file content mismatch (got %d bytes)", len(got))
+	}
+	if dl.Summary().Continued != 1 {
+		t.Errorf("expected the retry to resume the temp file, got %d continued", dl.Summary().Continued)
+	}
+	if reused := dl.Summary().ReusedBytes; reused < journalInterval {
+		t.Errorf("expected at least %d reused bytes, got %d", journalInterval, reused)
+	}
+	// The digest covers the resumed prefix as well as the rest
+	if sum := sha256.Sum256(content); digest != hex.EncodeToString(sum[:]) {
+		t.Errorf("expected the event to carry the whole file's SHA-256, got %q", digest)
+	}
+	// Progress is reported as checkpoints record what arrived before the interruption
+	if len(onDisk) == 0 || onDisk[len(onDisk)-1] != journalInterval+1000 {
+		t.Errorf("unexpected progress reports %v", onDisk)
+	}
+}
+
+func TestDownloader_TempJournalMirror(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), (journalInterval+journalInterval/2)/16)
+	modTime := time.Now()
+
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			// Send a little over one checkpoint's worth, then drop the connection
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			_, _ = w.Write(content[:journalInterval+1000])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		w.Header().Set("ETag", `"primary"`)
+		http.ServeContent(w, r, "game.zip", modTime, bytes.NewReader(content))
+	}))
+	defer primary.Close()
+
+	var served atomic.Int64
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"mirror"`)
+		cw := &countingWriter{ResponseWriter: w}
+		http.ServeContent(cw, r, "game.zip", modTime, bytes.NewReader(content))
+		if r.Method == http.MethodGet {
+			served.Add(cw.n)
+		}
+	}))
+
+	dir := t.TempDir()
+	dl := New(Config{
+		OutputDir: dir, Parallel: 1, RetryAttempts: 1,
+		Mirrors: func(fileURL string) []string {
+			return []string{strings.Replace(fileURL, primary.URL, mirror.URL, 1)}
+		},
+	})
+	file := parser.FileInfo{Name: "game.zip", URL: primary.URL + "/game.zip"}
+	err := dl.DownloadAll(context.Background(), []parser.FileInfo{file})
+	mirror.Close() // Waits for handlers so the served count is final
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, "game.zip")) //nolint:gosec // Test file path is safe (from t.TempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("file content mismatch (got %d bytes)", len(got))
+	}
+	// The mirror continues the temp file the primary left instead of starting over
+	if want := int64(len(content) - journalInterval - 1000); served.Load() != want {
+		t.Errorf("expected the mirror to serve %d bytes, got %d", want, served.Load())
+	}
+	if summary := dl.Summary(); summary.Continued != 1 || summary.Mirrored != 1 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+}
+
+func TestDownloader_Carried(t *testing.T) {
+	files := []parser.FileInfo{
+		{Name: "a.zip", Size: 1000},
+		{Name: "b.zip", Size: 500},
+		{Name: "c.zip"},
+	}
+	carried := map[string]int64{"a.zip": 400, "b.zip": 900, "c.zip": 10}
+	d := New(Config{Carried: func(f parser.FileInfo) int64 { return carried[f.Name] }})
+
+	expected := []int64{600, 0, 0}
+	for i, f := range files {
+		if got := d.remaining(f); got != expected[i] {
+			t.Errorf("%s: expected %d bytes to go, got %d", f.Name, expected[i], got)
+		}
+	}
+}
+
+func TestDownloader_Mismatches(t *testing.T) {
+	content := []byte("the remote file content")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "game.zip", time.Now(), bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	local := []byte("stale")
+	tests := []struct {
+		name     string
+		policy   MismatchPolicy
+		answer   MismatchPolicy // Returned by AskMismatch
+		expected map[string][]byte
+		skipped  int
+	}{
+		{"overwrite", MismatchOverwrite, "", map[string][]byte{"game.zip": content}, 0},
+		{"default overwrites", "", "", map[string][]byte{"game.zip": content}, 0},
+		{"skip", MismatchSkip, "", map[string][]byte{"game.zip": local}, 1},
+		{"rename", MismatchRename, "", map[string][]byte{"game.zip": local, "game (2).zip": content}, 0},
+		{"ask", MismatchAsk, MismatchSkip, map[string][]byte{"game.zip": local}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "game.zip"), local, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			asked := 0
+			dl := New(Config{
+				OutputDir:     dir,
+				Parallel:      1,
+				RetryAttempts: 1,
+				Mismatches:    tt.policy,
+				AskMismatch: func(file parser.FileInfo, localSize, remoteSize int64) MismatchPolicy {
+					asked++
+					if localSize != int64(len(local)) || remoteSize != int64(len(content)) {
+						t.Errorf("unexpected sizes %d and %d", localSize, remoteSize)
+					}
+					return tt.answer
+				},
+			})
+			file := parser.FileInfo{Name: "game.zip", URL: server.URL + "/game.zip"}
+			if err := dl.DownloadAll(context.Background(), []parser.FileInfo{file}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for name, want := range tt.expected {
+				got, err := os.ReadFile(filepath.Join(dir, name)) //nolint:gosec // Test file path is safe (from t.TempDir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("%s: expected %q, got %q", name, want, got)
+				}
+			}
+			if (tt.policy == MismatchAsk) != (asked == 1) {
+				t.Errorf("expected AskMismatch to be called only for ask, called %d times", asked)
+			}
+			if dl.Summary().Skipped != tt.skipped {
+				t.Errorf("expected %d skipped, got %d", tt.skipped, dl.Summary().Skipped)
+			}
+		})
+	}
+}
+
+func TestDownloader_Probe(t *testing.T) {
+	content := bytes.Repeat([]byte("x"), 5000)
+	tests := []struct {
+		name   string
+		ranges bool
+		etag   string
+	}{
+		{"range support", true, `"v1"`},
+		{"no range support", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !tt.ranges {
+					r.Header.Del("Range")
+				}
+				if tt.etag != "" {
+					w.Header().Set("ETag", tt.etag)
+				}
+				http.ServeContent(w, r, "game.zip", time.Now(), bytes.NewReader(content))
+			}))
+			defer server.Close()
+
+			probe, err := New(Config{}).Probe(context.Background(), server.URL+"/game.zip")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if probe.Size != int64(len(content)) || probe.ETag != tt.etag || probe.Ranges != tt.ranges {
+				t.Errorf("unexpected probe %+v", probe)
+			}
+			if probe.Redirected {
+				t.Error("expected no redirect")
+			}
+		})
+	}
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	if _, err := New(Config{}).Probe(context.Background(), server.URL+"/missing.zip"); err == nil {
+		t.Error("expected error for a missing file")
+	}
+}
+
+func TestDownloader_RemoteFile(t *testing.T) {
+	content := []byte("0123456789abcdef")
+	for _, ranges := range []bool{true, false} {
+		t.Run(fmt.Sprintf("ranges=%v", ranges), func(t *testing.T) {
+			var requests atomic.Int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				if !ranges {
+					r.Header.Del("Range")
+				}
+				http.ServeContent(w, r, "game.zip", time.Now(), bytes.NewReader(content))
+			}))
+			defer server.Close()
+
+			dl := New(Config{})
+			r, size, err := dl.RemoteFile(context.Background(), server.URL+"/game.zip")
+			if err != nil || size != int64(len(content)) {
+				t.Fatalf("RemoteFile = %d, %v", size, err)
+			}
+			buf := make([]byte, 4)
+			n, err := r.ReadAt(buf, 10)
+			if !ranges {
+				if !errors.Is(err, ErrNoRanges) {
+					t.Fatalf("expected ErrNoRanges, got %v", err)
+				}
+				// The host is remembered, so later files aren't downloaded whole either
+				if _, _, err := dl.RemoteFile(context.Background(), server.URL+"/other.zip"); !errors.Is(err, ErrNoRanges) {
+					t.Errorf("expected ErrNoRanges for the next file, got %v", err)
+				}
+				if requests.Load() != 3 {
+					t.Errorf("expected a HEAD and a GET, then one HEAD; got %d requests", requests.Load())
+				}
+				return
+			}
+			if err != nil || string(buf[:n]) != "abcd" {
+				t.Fatalf("ReadAt = %q, %v", buf[:n], err)
+			}
+
+			// A read past the end is cut short
+			n, err = r.ReadAt(buf, 14)
+			if string(buf[:n]) != "ef" || err != io.EOF {
+				t.Errorf("expected a short read at the end, got %q, %v", buf[:n], err)
+			}
+		})
+	}
+}
+
+func TestSplitSegments(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int64
+		n        int
+		expected int
+	}{
+		{"disabled", 10 * minSegmentSize, 1, 0},
+		{"too small", 2*minSegmentSize - 1, 4, 0},
+		{"capped by size", 3*minSegmentSize + 5, 8, 3},
+		{"requested", 100 * minSegmentSize, 4, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments := splitSegments(tt.size, tt.n)
+			if len(segments) != tt.expected {
+				t.Fatalf("expected %d segments, got %d", tt.expected, len(segments))
+			}
+			// Segments must cover the file exactly, in order
+			var next int64
+			for _, s := range segments {
+				if s.start != next || s.size() < minSegmentSize {
+					t.Errorf("unexpected segment %+v in %+v", s, segments)
+				}
+				next = s.end + 1
+			}
+			if segments != nil && next != tt.size {
+				t.Errorf("segments end at %d, expected %d", next, tt.size)
+			}
+		})
+	}
+}
+
+func TestDownloader_SizeClasses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size := 10
+		if strings.HasPrefix(r.URL.Path, "/large") {
+			size = 1000
+			if r.Method == http.MethodGet {
+				time.Sleep(150 * time.Millisecond)
+			}
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(size))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(bytes.Repeat([]byte("x"), size))
+		}
+	}))
+	defer server.Close()
+
+	var files []parser.FileInfo
+	for _, name := range []string{"large1.iso", "large2.iso", "small1.zip", "small2.zip", "small3.zip"} {
+		size := int64(10)
+		if strings.HasPrefix(name, "large") {
+			size = 1000
+		}
+		files = append(files, parser.FileInfo{Name: name, URL: server.URL + "/" + name, Size: size})
+	}
+
+	var mu sync.Mutex
+	var order []string
+	dl := New(Config{
+		OutputDir:     t.TempDir(),
+		Parallel:      2,
+		RetryAttempts: 1,
+		SmallSlots:    1,
+		LargeFile:     100,
+		OnEvent: func(e Event) {
+			if e.Type == EventCompleted {
+				mu.Lock()
+				order = append(order, e.File.Name)
+				mu.Unlock()
+			}
+		},
+	})
+	if err := dl.DownloadAll(context.Background(), files); err != nil {
+		t.Fatalf("DownloadAll failed: %v", err)
+	}
+
+	// The large files are listed first, but only one may hold a worker while
+	// small files wait, so the small ones all finish during the first
+	if len(order) != len(files) {
+		t.Fatalf("expected %d files, got %v", len(files), order)
+	}
+	for _, name := range order[:3] {
+		if !strings.HasPrefix(name, "small") {
+			t.Fatalf("expected the small files to finish first, got %v", order)
+		}
+	}
+}
+
+func TestDownloader_Segments(t *testing.T) {
+	content := make([]byte, 2*minSegmentSize+12345)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+	sum := sha256.Sum256(content)
+	modTime := time.Now()
+
+	tests := []struct {
+		name        string
+		honorRanges bool
+	}{
+		{"ranges honored", true},
+		{"ranges ignored", false}, // Falls back to one stream
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ranges atomic.Int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet && r.Header.Get("Range") != "" {
+					ranges.Add(1)
+					if !tt.honorRanges {
+						r.Header.Del("Range")
+					}
+				}
+				http.ServeContent(w, r, "disc.bin", modTime, bytes.NewReader(content))
+			}))
+			defer server.Close()
+
+			tmpDir := t.TempDir()
+			dl := New(Config{OutputDir: tmpDir, RetryAttempts: 1, Segments: 4})
+			res, err := dl.fetch(context.Background(), parser.FileInfo{Name: "disc.bin", URL: server.URL + "/disc.bin"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got, err := os.ReadFile(filepath.Join(tmpDir, "disc.bin")) //nolint:gosec // Test file path is safe (from t.TempDir)
+			if err != nil || !bytes.Equal(got, content) {
+				t.Fatalf("downloaded file differs from the remote one (err %v)", err)
+			}
+			if res.sha256 != hex.EncodeToString(sum[:]) {
+				t.Errorf("expected the file's SHA-256, got %q", res.sha256)
+			}
+			if n := ranges.Load(); tt.honorRanges && n != 2 {
+				t.Errorf("expected one range request per segment, got %d", n)
+			}
+			if _, err := os.Stat(filepath.Join(tmpDir, "disc.bin"+cleanup.TempSuffix)); !os.IsNotExist(err) {
+				t.Error("expected no temp file to remain")
+			}
+		})
+	}
+}
+
+func TestDownloader_SegmentsResume(t *testing.T) {
+	content := make([]byte, 2*minSegmentSize+12345)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+	modTime := time.Now()
+	segments := splitSegments(int64(len(content)), 2)
+	second := fmt.Sprintf("bytes=%d-", segments[1].start)
+
+	var interrupt atomic.Bool
+	interrupt.Store(true)
+	var mu sync.Mutex
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			mu.Lock()
+			requested = append(requested, r.Header.Get("Range"))
+			mu.Unlock()
+		}
+		if r.Method == http.MethodGet && interrupt.Load() && strings.HasPrefix(r.Header.Get("Range"), second) {
+			// Send part of the second segment, then drop the connection
+			s := segments[1]
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", s.start, s.end, len(content)))
+			w.Header().Set("Content-Length", strconv.FormatInt(s.size(), 10))
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write(content[s.start : s.start+1<<20])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "disc.bin", modTime, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	tempPath := filepath.Join(tmpDir, "disc.bin"+cleanup.TempSuffix)
+	file := parser.FileInfo{Name: "disc.bin", URL: server.URL + "/disc.bin"}
+	dl := New(Config{OutputDir: tmpDir, RetryAttempts: 1, Segments: 2})
+	if _, err := dl.fetch(context.Background(), file); err == nil {
+		t.Fatal("expected the interrupted download to fail")
+	}
+	j, err := loadJournal(journalPath(tempPath))
+	if err != nil || len(j.Segments) != 2 || j.Segments[1].Done == 0 {
+		t.Fatalf("expected each segment's progress to be journaled, got %+v (%v)", j, err)
+	}
+
+	interrupt.Store(false)
+	mu.Lock()
+	requested = nil
+	mu.Unlock()
+	res, err := dl.fetch(context.Background(), file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(tmpDir, "disc.bin")) //nolint:gosec // Test file path is safe (from t.TempDir)
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("downloaded file differs from the remote one (err %v)", err)
+	}
+	if res.outcome != outcomeContinued || res.transferred >= int64(len(content)) {
+		t.Errorf("expected the segments to continue, got outcome %v after %d bytes", res.outcome, res.transferred)
+	}
+	want := fmt.Sprintf("bytes=%d-%d", segments[1].start+j.Segments[1].Done, segments[1].end)
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Contains(requested, want) {
+		t.Errorf("expected the second segment to continue with %q, got %v", want, requested)
+	}
+	for _, leftover := range []string{tempPath, journalPath(tempPath)} {
+		if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed", leftover)
+		}
+	}
+}
+
+func TestSegmentError(t *testing.T) {
+	cause := errors.New("connection reset")
+	if err := segmentError([]error{context.Canceled, cause, nil}); !errors.Is(err, cause) {
+		t.Errorf("expected the root cause, got %v", err)
+	}
+	if err := segmentError([]error{cause, fmt.Errorf("%w: status 200", errCannotSegment)}); !errors.Is(err, errCannotSegment) {
+		t.Errorf("expected a range refusal to win so the download falls back, got %v", err)
+	}
+	if err := segmentError([]error{nil, nil}); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package downloader
+
+import (
+	"strings"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// Estimate returns how long downloading bytes will take at speed bytes per
+// second, capped by rateLimit and spread over the openings of window. A zero
+// speed falls back to the rate limit; ok is false when neither is known.
+func Estimate(bytes int64, speed float64, rateLimit int64, window *Window, now time.Time) (eta time.Duration, ok bool) {
+	if rateLimit > 0 && (speed <= 0 || speed > float64(rateLimit)) {
+		speed = float64(rateLimit)
+	}
+	if bytes <= 0 {
+		return 0, true
+	}
+	if speed <= 0 {
+		return 0, false
+	}
+
+	work := time.Duration(float64(bytes) / speed * float64(time.Second))
+	if window == nil {
+		return work, true
+	}
+	t := now
+	for {
+		t = t.Add(window.Until(t))
+		open := window.Remaining(t)
+		if open >= work {
+			return t.Add(work).Sub(now), true
+		}
+		work -= open
+		t = t.Add(open)
+	}
+}
+
+// ETA estimates the time left in the current run from the combined speed of
+// the workers so far, the bandwidth cap, and the download window
+func (d *Downloader) ETA() (time.Duration, bool) {
+	d.mu.Lock()
+	var speed float64
+	for _, w := range d.summary.Workers {
+		speed += w.Speed()
+	}
+	pending := d.pending
+	d.mu.Unlock()
+
+	return Estimate(pending, speed, d.config.RateLimit, d.config.Window, time.Now())
+}
+
+// remaining is a file's listed size less what an earlier run already got
+func (d *Downloader) remaining(file parser.FileInfo) int64 {
+	size := max(file.Size, 0)
+	if d.config.Carried != nil {
+		size -= min(d.config.Carried(file), size)
+	}
+	return size
+}
+
+// settle takes a finished file's remaining size off the bytes still to go
+func (d *Downloader) settle(size int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pending = max(d.pending-size, 0)
+}
+
+// etaNote formats the run's ETA for the "Downloading" line, or "" if unknown
+func (d *Downloader) etaNote() string {
+	eta, ok := d.ETA()
+	if !ok || eta < time.Second {
+		return ""
+	}
+	note := " (about " + FormatETA(eta) + " left"
+	if eta >= time.Hour {
+		note += ", done around " + time.Now().Add(eta).Format("Mon 15:04")
+	}
+	return note + ")"
+}
+
+// FormatETA formats an estimate to the minute, or to the second under a minute
+func FormatETA(eta time.Duration) string {
+	if eta < time.Minute {
+		return eta.Round(time.Second).String()
+	}
+	return strings.TrimSuffix(eta.Round(time.Minute).String(), "0s")
+}
@@ -0,0 +1,62 @@
+package downloader
+
+import (
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// EventType identifies a step in a file's download lifecycle
+type EventType string
+
+// Download lifecycle events
+const (
+	EventStarted     EventType = "started"
+	EventCompleted   EventType = "completed"   // Downloaded (or completed from a partial file)
+	EventSkipped     EventType = "skipped"     // Already present locally
+	EventPlaceholder EventType = "placeholder" // Zero-byte or placeholder file, saved or not per policy
+	EventRejected    EventType = "rejected"    // Downloaded, then discarded by the Verifier with ErrRejected
+	EventGone        EventType = "gone"        // Missing upstream (404 or 410), with Config.IgnoreMissing
+	EventFailed      EventType = "failed"
+)
+
+// Event reports progress of a single file to Config.OnEvent
+type Event struct {
+	Type     EventType
+	File     parser.FileInfo
+	Path     string // Local path relative to the output directory; empty for failures
+	Bytes    int64  // Remote size of the file; 0 when unknown
+	Duration time.Duration
+	Err      error
+	Mirror   string // URL that served the file when it wasn't the listed one
+	SHA256   string // Hex digest of a file downloaded in full or resumed from a temp file; empty otherwise
+}
+
+// outcome describes how a successful download attempt ended
+type outcome int
+
+const (
+	outcomeDownloaded outcome = iota
+	outcomeSkipped
+	outcomeContinued
+)
+
+// result is what a single download attempt produced
+type result struct {
+	outcome     outcome
+	size        int64
+	name        string // Local name relative to the output directory
+	transferred int64  // Bytes received from the server
+	placeholder string // Why the file looks like a placeholder; empty for real files
+	rejected    string // Why the Verifier rejected the file; empty if it was kept
+	gone        string // Why the file counts as gone upstream; empty if it was found
+	mirror      string // Alternate URL that served the file; empty for the listed URL
+	sha256      string // Hex digest computed while writing the file, if it was
+}
+
+// emit delivers an event to the configured handler, if any
+func (d *Downloader) emit(e Event) {
+	if d.config.OnEvent != nil {
+		d.config.OnEvent(e)
+	}
+}
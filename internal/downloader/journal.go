@@ -0,0 +1,362 @@
+package downloader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/nchapman/myrient-dl/internal/cleanup"
+)
+
+// journalInterval is how many bytes are written to a temp file between checkpoints
+const journalInterval = 8 << 20
+
+// journal records how much of a temp file is known to be on disk intact, so an
+// interrupted download can pick up where it stopped instead of starting over.
+// It is found by its temp file's path, the output path, so another URL for the
+// same file, e.g. a mirror, continues it as long as the size matches.
+type journal struct {
+	URL    string `json:"url"`            // Where the bytes so far came from
+	Size   int64  `json:"size"`           // Remote size when the download started
+	ETag   string `json:"etag,omitempty"` // Only compared for the same URL; mirrors tag files differently
+	Offset int64  `json:"offset"`         // Bytes synced to disk
+	SHA256 string `json:"sha256"`         // Of the first Offset bytes
+
+	// Segments is set instead of Offset for a segmented download
+	Segments []segmentProgress `json:"segments,omitempty"`
+}
+
+// segmentProgress records how much of one segment of a segmented download is
+// on disk intact
+type segmentProgress struct {
+	Start  int64  `json:"start"`
+	End    int64  `json:"end"`
+	Done   int64  `json:"done"`   // Bytes synced to disk from Start
+	SHA256 string `json:"sha256"` // Of those bytes
+}
+
+// journalPath returns where the journal for a temp file lives
+func journalPath(tempPath string) string {
+	return strings.TrimSuffix(tempPath, cleanup.TempSuffix) + cleanup.JournalSuffix
+}
+
+// loadJournal reads a temp file's journal
+func loadJournal(path string) (journal, error) {
+	var j journal
+	data, err := os.ReadFile(path) //nolint:gosec // Path is derived from the output directory
+	if err != nil {
+		return j, err
+	}
+	if err := json.Unmarshal(data, &j); err != nil {
+		return j, fmt.Errorf("failed to parse journal: %w", err)
+	}
+	return j, nil
+}
+
+// save writes the journal. A torn write leaves unparseable JSON, which only
+// costs the partial data, never trusts bad data.
+func (j journal) save(path string) error {
+	data, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// journalWriter writes a temp file while hashing it, checkpointing the journal
+// every journalInterval bytes once the data has been synced to disk
+type journalWriter struct {
+	file    *os.File
+	path    string
+	hash    hash.Hash
+	journal journal
+	pending int64 // Bytes written since the last checkpoint
+
+	onCheckpoint func(onDisk int64) // Called after each checkpoint, if set
+}
+
+func (w *journalWriter) Write(p []byte) (int, error) {
+	n, err := w.file.Write(p)
+	w.hash.Write(p[:n])
+	w.journal.Offset += int64(n)
+	w.pending += int64(n)
+	if err != nil {
+		return n, err
+	}
+	if w.pending >= journalInterval {
+		if err := w.checkpoint(); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// checkpoint syncs the temp file and records everything written so far
+func (w *journalWriter) checkpoint() error {
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	w.journal.SHA256 = hex.EncodeToString(w.hash.Sum(nil))
+	w.pending = 0
+	if err := w.journal.save(w.path); err != nil {
+		return err
+	}
+	if w.onCheckpoint != nil {
+		w.onCheckpoint(w.journal.Offset)
+	}
+	return nil
+}
+
+// segmentWriter writes the segments of a download into place in a temp file,
+// hashing each one and checkpointing the journal every journalInterval bytes
+// across all of them. Each segment is written by its own goroutine.
+type segmentWriter struct {
+	file     *os.File
+	path     string
+	journal  journal
+	segments []segment
+	hashes   []hash.Hash // Of each segment's written bytes
+	written  []int64     // Bytes written from each segment's start
+
+	onCheckpoint func(onDisk int64) // Called after each checkpoint, if set
+
+	mu      sync.Mutex
+	pending int64 // Bytes written since the last checkpoint
+}
+
+func newSegmentWriter(j journal, segments []segment) *segmentWriter {
+	w := &segmentWriter{
+		journal:  j,
+		segments: segments,
+		hashes:   make([]hash.Hash, len(segments)),
+		written:  make([]int64, len(segments)),
+	}
+	for i := range w.hashes {
+		w.hashes[i] = sha256.New()
+	}
+	return w
+}
+
+// open opens the temp file at its full size, keeping the bytes of a resumed
+// download and starting empty otherwise
+func (w *segmentWriter) open(tempPath string) error {
+	flags := os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	if w.done() > 0 {
+		flags = os.O_RDWR
+	}
+	f, err := os.OpenFile(tempPath, flags, 0666) //nolint:gosec // File path is controlled by config and filename from server
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(w.journal.Size); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to allocate file: %w", err)
+	}
+	w.file = f
+	return nil
+}
+
+// done returns the bytes written across all segments
+func (w *segmentWriter) done() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var total int64
+	for _, n := range w.written {
+		total += n
+	}
+	return total
+}
+
+// segment returns a writer that continues segment i where it stopped
+func (w *segmentWriter) segment(i int) io.Writer {
+	return segmentPart{w: w, i: i}
+}
+
+type segmentPart struct {
+	w *segmentWriter
+	i int
+}
+
+func (p segmentPart) Write(b []byte) (int, error) {
+	w, s := p.w, p.w.segments[p.i]
+	if int64(len(b)) > s.size()-w.written[p.i] {
+		return 0, fmt.Errorf("%w: segment received more than %d bytes", ErrCorrupt, s.size())
+	}
+	n, err := w.file.WriteAt(b, s.start+w.written[p.i])
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.hashes[p.i].Write(b[:n])
+	w.written[p.i] += int64(n)
+	w.pending += int64(n)
+	if err != nil {
+		return n, err
+	}
+	if w.pending >= journalInterval {
+		if err := w.checkpointLocked(); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// checkpoint syncs the temp file and records every segment's progress
+func (w *segmentWriter) checkpoint() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.checkpointLocked()
+}
+
+func (w *segmentWriter) checkpointLocked() error {
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	var onDisk int64
+	w.journal.Segments = make([]segmentProgress, len(w.segments))
+	for i, s := range w.segments {
+		w.journal.Segments[i] = segmentProgress{
+			Start:  s.start,
+			End:    s.end,
+			Done:   w.written[i],
+			SHA256: hex.EncodeToString(w.hashes[i].Sum(nil)),
+		}
+		onDisk += w.written[i]
+	}
+	w.pending = 0
+	if err := w.journal.save(w.path); err != nil {
+		return err
+	}
+	if w.onCheckpoint != nil {
+		w.onCheckpoint(onDisk)
+	}
+	return nil
+}
+
+// removeTemp deletes a temp file and its journal
+func removeTemp(tempPath string) {
+	_ = os.Remove(tempPath)
+	_ = os.Remove(journalPath(tempPath))
+}
+
+// moveTemp moves a temp file and its journal to another temp path, discarding
+// them if that path is taken or the move fails
+func moveTemp(from, to string) {
+	if _, err := os.Stat(to); err == nil {
+		removeTemp(from)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil { //nolint:gosec // 0755 is appropriate for download directories
+		removeTemp(from)
+		return
+	}
+	if err := os.Rename(from, to); err != nil {
+		removeTemp(from)
+		return
+	}
+	if err := os.Rename(journalPath(from), journalPath(to)); err != nil {
+		removeTemp(to)
+	}
+}
+
+// errJournalMismatch means a temp file's journal doesn't describe the download
+var errJournalMismatch = errors.New("journal does not match")
+
+// errSegmentedTemp means a temp file was left by a segmented download, which
+// resumeSegments continues rather than resumeTemp
+var errSegmentedTemp = errors.New("temp file holds a segmented download")
+
+// resumeTemp checks whether a temp file left by an earlier attempt can be
+// trusted for this download. On success the temp file is truncated to the last
+// confirmed offset and the returned hash holds the state for those bytes.
+func (d *Downloader) resumeTemp(tempPath, url string, remote remoteFile) (int64, hash.Hash, error) {
+	j, err := loadJournal(journalPath(tempPath))
+	if err != nil {
+		return 0, nil, err
+	}
+	switch {
+	case len(j.Segments) > 0:
+		return 0, nil, errSegmentedTemp
+	case j.Size != remote.size:
+		return 0, nil, fmt.Errorf("%w: remote file changed", errJournalMismatch)
+	case j.URL == url && j.ETag != "" && remote.etag != "" && j.ETag != remote.etag:
+		return 0, nil, fmt.Errorf("%w: ETag changed", errJournalMismatch)
+	case j.Offset <= 0 || j.Offset >= j.Size:
+		return 0, nil, fmt.Errorf("%w: nothing to resume", errJournalMismatch)
+	case d.ranges.support(remote.host) == rangesUnsupported:
+		return 0, nil, fmt.Errorf("%w: %s does not support range requests", errJournalMismatch, remote.host)
+	}
+
+	f, err := os.OpenFile(tempPath, os.O_RDWR, 0) //nolint:gosec // Path is derived from the output directory
+	if err != nil {
+		return 0, nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	h := sha256.New()
+	if _, err := io.CopyN(h, f, j.Offset); err != nil {
+		return 0, nil, fmt.Errorf("%w: temp file is shorter than journal", errJournalMismatch)
+	}
+	if hex.EncodeToString(h.Sum(nil)) != j.SHA256 {
+		return 0, nil, fmt.Errorf("%w: temp file hash mismatch", errJournalMismatch)
+	}
+	// Anything past the last checkpoint may not have reached the disk intact
+	if err := f.Truncate(j.Offset); err != nil {
+		return 0, nil, err
+	}
+	return j.Offset, h, nil
+}
+
+// resumeSegments checks whether a temp file left by a segmented download can be
+// continued with the same segments. On success the returned writer holds each
+// segment's confirmed progress; bytes past a segment's last checkpoint are
+// fetched again.
+func (d *Downloader) resumeSegments(tempPath, url string, remote remoteFile, segments []segment) (*segmentWriter, error) {
+	j, err := loadJournal(journalPath(tempPath))
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(j.Segments) == 0:
+		return nil, fmt.Errorf("%w: not a segmented download", errJournalMismatch)
+	case j.Size != remote.size:
+		return nil, fmt.Errorf("%w: remote file changed", errJournalMismatch)
+	case j.URL == url && j.ETag != "" && remote.etag != "" && j.ETag != remote.etag:
+		return nil, fmt.Errorf("%w: ETag changed", errJournalMismatch)
+	case len(j.Segments) != len(segments):
+		return nil, fmt.Errorf("%w: segment count changed", errJournalMismatch)
+	}
+
+	f, err := os.Open(tempPath) //nolint:gosec // Path is derived from the output directory
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	w := newSegmentWriter(j, segments)
+	for i, p := range j.Segments {
+		s := segments[i]
+		if p.Start != s.start || p.End != s.end || p.Done < 0 || p.Done > s.size() {
+			return nil, fmt.Errorf("%w: segments changed", errJournalMismatch)
+		}
+		if _, err := io.CopyN(w.hashes[i], io.NewSectionReader(f, p.Start, p.Done), p.Done); err != nil {
+			return nil, fmt.Errorf("%w: temp file is shorter than journal", errJournalMismatch)
+		}
+		if hex.EncodeToString(w.hashes[i].Sum(nil)) != p.SHA256 {
+			return nil, fmt.Errorf("%w: segment %d hash mismatch", errJournalMismatch, i+1)
+		}
+		w.written[i] = p.Done
+	}
+	return w, nil
+}
@@ -0,0 +1,57 @@
+package downloader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// MismatchPolicy decides what happens to an existing local file whose size
+// differs from the remote file
+type MismatchPolicy string
+
+// Supported mismatch policies. The zero value behaves like MismatchOverwrite.
+const (
+	MismatchOverwrite MismatchPolicy = "overwrite" // Download over it
+	MismatchSkip      MismatchPolicy = "skip"      // Keep it and move on
+	MismatchRename    MismatchPolicy = "rename"    // Keep it and save the download as "name (2).ext"
+	MismatchAsk       MismatchPolicy = "ask"       // Let Config.AskMismatch decide per file
+)
+
+// ParseMismatchPolicy parses a mismatch policy name as accepted on the command line
+func ParseMismatchPolicy(s string) (MismatchPolicy, error) {
+	switch p := MismatchPolicy(strings.ToLower(s)); p {
+	case MismatchOverwrite, MismatchSkip, MismatchRename, MismatchAsk:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown mismatch policy %q (expected overwrite, skip, rename, or ask)", s)
+	}
+}
+
+// mismatchPolicy returns the policy for an existing file of the wrong size
+func (d *Downloader) mismatchPolicy(file parser.FileInfo, localSize, remoteSize int64) MismatchPolicy {
+	policy := d.config.Mismatches
+	if policy == MismatchAsk {
+		policy = MismatchOverwrite
+		if d.config.AskMismatch != nil {
+			policy = d.config.AskMismatch(file, localSize, remoteSize)
+		}
+	}
+	return policy
+}
+
+// freeName suffixes a local name with " (n)" before its extension until nothing
+// exists at that path in the output directory
+func (d *Downloader) freeName(name string) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, n, ext)
+		if _, err := os.Stat(filepath.Join(d.config.OutputDir, candidate)); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
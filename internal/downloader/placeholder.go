@@ -0,0 +1,84 @@
+package downloader
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PlaceholderPolicy decides what happens to zero-byte and placeholder files
+type PlaceholderPolicy string
+
+// Supported placeholder policies. The zero value behaves like PlaceholderWarn.
+const (
+	PlaceholderWarn     PlaceholderPolicy = "warn"     // Download it, with a warning
+	PlaceholderSkip     PlaceholderPolicy = "skip"     // Don't save it
+	PlaceholderDownload PlaceholderPolicy = "download" // Download it quietly
+)
+
+// ParsePlaceholderPolicy parses a placeholder policy name as accepted on the command line
+func ParsePlaceholderPolicy(s string) (PlaceholderPolicy, error) {
+	switch p := PlaceholderPolicy(strings.ToLower(s)); p {
+	case PlaceholderWarn, PlaceholderSkip, PlaceholderDownload:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown placeholder policy %q (expected skip, warn, or download)", s)
+	}
+}
+
+// maxPlaceholderPage is the largest HTML body treated as a placeholder page
+// rather than a real file
+const maxPlaceholderPage = 64 * 1024
+
+// placeholderPage reports whether a GET response looks like a small HTML page
+// served in place of the listed file, such as an error or "file moved" notice
+func placeholderPage(name string, resp *http.Response) bool {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "text/html" {
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".htm", ".html":
+		return false
+	}
+	return resp.ContentLength >= 0 && resp.ContentLength <= maxPlaceholderPage
+}
+
+// placeholderFile reports whether an existing local file is a saved placeholder page
+func placeholderFile(path, name string, size int64) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".htm", ".html":
+		return false
+	}
+	if size == 0 || size > maxPlaceholderPage {
+		return false
+	}
+
+	f, err := os.Open(path) //nolint:gosec // File path is controlled by config and filename from server
+	if err != nil {
+		return false
+	}
+	defer func() { _ = f.Close() }()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	return strings.HasPrefix(http.DetectContentType(head[:n]), "text/html")
+}
+
+// notePlaceholder reports a detected placeholder and whether it should be skipped
+func (d *Downloader) notePlaceholder(reason string) (skip bool) {
+	switch d.config.Placeholders {
+	case PlaceholderSkip:
+		fmt.Printf("  ⚠ Placeholder (%s), skipping\n", reason)
+		return true
+	case PlaceholderDownload:
+		return false
+	default:
+		fmt.Printf("  ⚠ Placeholder (%s), downloading anyway\n", reason)
+		return false
+	}
+}
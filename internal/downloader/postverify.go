@@ -0,0 +1,85 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// downloaded remembers what the server said about a file when it was fetched
+type downloaded struct {
+	file   parser.FileInfo
+	name   string // Local name relative to the output directory
+	remote remoteFile
+}
+
+// Mismatch is a downloaded file whose remote copy changed after it was fetched,
+// or could not be checked again
+type Mismatch struct {
+	File   parser.FileInfo
+	Path   string // Local path relative to the output directory
+	Reason string
+	Err    error // Set when the re-check itself failed; the file may be fine
+}
+
+// rememberDownload records a fetched file for PostVerify
+func (d *Downloader) rememberDownload(file parser.FileInfo, res result) {
+	d.headMu.Lock()
+	remote, ok := d.heads[file.URL]
+	d.headMu.Unlock()
+	if !ok {
+		return // The size changed during the GET; nothing trustworthy to compare against
+	}
+
+	d.mu.Lock()
+	d.downloads = append(d.downloads, downloaded{file: file, name: res.name, remote: remote})
+	d.mu.Unlock()
+}
+
+// PostVerify re-checks files downloaded by this Downloader with a fresh HEAD request
+// and reports those whose size or ETag changed since they were fetched. A sample
+// of 0 checks every file; otherwise that many files are picked at random.
+func (d *Downloader) PostVerify(ctx context.Context, sample int) ([]Mismatch, int, error) {
+	d.mu.Lock()
+	candidates := append([]downloaded(nil), d.downloads...)
+	d.mu.Unlock()
+
+	if sample > 0 && sample < len(candidates) {
+		rand.Shuffle(len(candidates), func(i, j int) { //nolint:gosec // Sampling doesn't need cryptographic randomness
+			candidates[i], candidates[j] = candidates[j], candidates[i]
+		})
+		candidates = candidates[:sample]
+	}
+
+	var mismatches []Mismatch
+	for _, c := range candidates {
+		if ctx.Err() != nil {
+			return mismatches, len(candidates), ctx.Err()
+		}
+
+		current, err := d.headFile(ctx, c.file.URL)
+		if err != nil {
+			mismatches = append(mismatches, Mismatch{File: c.file, Path: c.name, Reason: "could not re-check", Err: err})
+			continue
+		}
+
+		switch {
+		case current.size != c.remote.size:
+			mismatches = append(mismatches, Mismatch{
+				File:   c.file,
+				Path:   c.name,
+				Reason: fmt.Sprintf("size changed from %d to %d bytes", c.remote.size, current.size),
+			})
+		case c.remote.etag != "" && current.etag != "" && current.etag != c.remote.etag:
+			mismatches = append(mismatches, Mismatch{
+				File:   c.file,
+				Path:   c.name,
+				Reason: fmt.Sprintf("ETag changed from %s to %s", c.remote.etag, current.etag),
+			})
+		}
+	}
+
+	return mismatches, len(candidates), nil
+}
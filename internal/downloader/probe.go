@@ -0,0 +1,68 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Probe is what a preflight check learned about how a server serves one file
+type Probe struct {
+	Size         int64 // From the HEAD response; -1 when not reported
+	ETag         string
+	Host         string // Host that answered, after redirects
+	Redirected   bool
+	HeadLatency  time.Duration
+	AcceptRanges string // Accept-Ranges header of the HEAD response
+	// Ranges reports whether a one-byte Range request was answered with 206
+	Ranges       bool
+	RangeLatency time.Duration
+}
+
+// Probe checks how the server answers HEAD and Range requests for a file
+// without downloading it
+func (d *Downloader) Probe(ctx context.Context, fileURL string) (Probe, error) {
+	req, err := d.newRequest(ctx, http.MethodHead, fileURL)
+	if err != nil {
+		return Probe{}, err
+	}
+	start := time.Now()
+	resp, err := d.do(req)
+	if err != nil {
+		return Probe{}, fmt.Errorf("HEAD failed: %w", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Probe{}, fmt.Errorf("HEAD returned status %d", resp.StatusCode)
+	}
+
+	p := Probe{
+		Size:         resp.ContentLength,
+		ETag:         resp.Header.Get("ETag"),
+		Host:         resp.Request.URL.Host,
+		Redirected:   resp.Request.URL.String() != req.URL.String(),
+		HeadLatency:  time.Since(start),
+		AcceptRanges: resp.Header.Get("Accept-Ranges"),
+	}
+
+	req, err = d.newRequest(ctx, http.MethodGet, fileURL)
+	if err != nil {
+		return p, err
+	}
+	req.Header.Set("Range", "bytes=0-0")
+	start = time.Now()
+	resp, err = d.do(req)
+	if err != nil {
+		return p, fmt.Errorf("range request failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	p.RangeLatency = time.Since(start)
+	// Only the first byte is wanted; a server ignoring the range would send everything
+	_, _ = io.CopyN(io.Discard, resp.Body, 1)
+	p.Ranges = resp.StatusCode == http.StatusPartialContent
+	return p, nil
+}
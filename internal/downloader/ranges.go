@@ -0,0 +1,88 @@
+package downloader
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// rangeSupport is what we know about a host's support for Range requests
+type rangeSupport int
+
+const (
+	rangesUnknown rangeSupport = iota // Not advertised; worth trying
+	rangesSupported
+	rangesUnsupported
+)
+
+func (r rangeSupport) String() string {
+	switch r {
+	case rangesSupported:
+		return "supports range requests"
+	case rangesUnsupported:
+		return "does not support range requests"
+	default:
+		return "does not advertise range support"
+	}
+}
+
+// advertisedRanges reads the Accept-Ranges header of a response
+func advertisedRanges(h http.Header) rangeSupport {
+	switch strings.ToLower(strings.TrimSpace(h.Get("Accept-Ranges"))) {
+	case "bytes":
+		return rangesSupported
+	case "none":
+		return rangesUnsupported
+	default:
+		return rangesUnknown
+	}
+}
+
+// hostRanges tracks range support per host, probed once from the first HEAD
+// and corrected when a Range request isn't honored
+type hostRanges struct {
+	mu    sync.Mutex
+	hosts map[string]rangeSupport
+}
+
+// probe records what a host advertised the first time it is seen and reports
+// whether this was the first observation
+func (h *hostRanges) probe(host string, support rangeSupport) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, seen := h.hosts[host]; seen {
+		return false
+	}
+	if h.hosts == nil {
+		h.hosts = make(map[string]rangeSupport)
+	}
+	h.hosts[host] = support
+	return true
+}
+
+// refuse marks a host as not honoring range requests
+func (h *hostRanges) refuse(host string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.hosts == nil {
+		h.hosts = make(map[string]rangeSupport)
+	}
+	h.hosts[host] = rangesUnsupported
+}
+
+// support returns what is known about a host
+func (h *hostRanges) support(host string) rangeSupport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.hosts[host]
+}
+
+// noteRanges records a host's advertised range support from a HEAD response
+func (d *Downloader) noteRanges(resp *http.Response) {
+	host := resp.Request.URL.Host
+	support := advertisedRanges(resp.Header)
+	if d.ranges.probe(host, support) && d.config.Verbose {
+		fmt.Printf("  %s %s\n", host, support)
+	}
+}
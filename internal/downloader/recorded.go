@@ -0,0 +1,31 @@
+package downloader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nchapman/myrient-dl/internal/fsutil"
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// recordedCopy skips a file Config.Recorded says an earlier run completed, if
+// it is still on disk at the recorded size, without a HEAD request
+func (d *Downloader) recordedCopy(file parser.FileInfo) (result, bool) {
+	if d.config.Recorded == nil {
+		return result{}, false
+	}
+	name, size, ok := d.config.Recorded(file)
+	if !ok || size <= 0 || !file.SizeMatches(size) {
+		return result{}, false
+	}
+	local, err := fsutil.Within(d.config.OutputDir, name)
+	if err != nil {
+		return result{}, false
+	}
+	if info, err := os.Stat(local); err != nil || !info.Mode().IsRegular() || info.Size() != size {
+		return result{}, false
+	}
+	fmt.Printf("  ✓ Already downloaded (recorded in the manifest, skipping)\n")
+	return result{outcome: outcomeSkipped, size: size, name: filepath.FromSlash(name)}, true
+}
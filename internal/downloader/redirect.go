@@ -0,0 +1,129 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/auth"
+)
+
+// redirects remembers where file URLs on one origin were redirected to, so later
+// files can go straight to the CDN host instead of paying a redirect round trip each
+type redirects struct {
+	mu    sync.Mutex
+	rules map[string]string // Origin ("https://host") -> replacement ("https://cdn/prefix")
+}
+
+// rewrite returns raw with its origin replaced by a learned redirect target, if any
+func (r *redirects) rewrite(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+
+	r.mu.Lock()
+	target, ok := r.rules[origin(u)]
+	r.mu.Unlock()
+	if !ok {
+		return raw
+	}
+	return target + strings.TrimPrefix(raw, origin(u))
+}
+
+// learn records the redirect from requested to final when it only swaps the host
+// (and optionally adds a path prefix). Redirects that change the rest of the path or
+// carry a query, such as signed URLs, are specific to one file and aren't reused.
+// It reports whether a new rule was learned.
+func (r *redirects) learn(requested, final *url.URL) bool {
+	if origin(requested) == origin(final) || final.RawQuery != "" {
+		return false
+	}
+
+	requestedPath, finalPath := requested.EscapedPath(), final.EscapedPath()
+	if !strings.HasSuffix(finalPath, requestedPath) {
+		return false
+	}
+	target := origin(final) + strings.TrimSuffix(finalPath, requestedPath)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.rules == nil {
+		r.rules = make(map[string]string)
+	}
+	if r.rules[origin(requested)] == target {
+		return false
+	}
+	r.rules[origin(requested)] = target
+	return true
+}
+
+// forget drops the rule for raw's origin, sending later requests back to the origin
+func (r *redirects) forget(raw string) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	delete(r.rules, origin(u))
+	r.mu.Unlock()
+}
+
+func origin(u *url.URL) string {
+	return u.Scheme + "://" + u.Host
+}
+
+// newRequest builds a request for a file URL, going straight to a learned CDN host when possible
+func (d *Downloader) newRequest(ctx context.Context, method, rawURL string) (*http.Request, error) {
+	return d.config.Identity.NewRequest(ctx, method, d.redirects.rewrite(rawURL))
+}
+
+// do sends a request built by newRequest, paced and throttled per Config, and learns
+// from any redirect it followed
+func (d *Downloader) do(req *http.Request) (*http.Response, error) {
+	if err := d.pacer.wait(req.Context(), d.config.RequestInterval); err != nil {
+		return nil, err
+	}
+
+	// The stall watchdog cancels this context to abort a body that went quiet
+	stall := d.config.stallTimeout()
+	cancel := context.CancelFunc(func() {})
+	if stall > 0 {
+		req, cancel = cancellable(req)
+	}
+
+	start := time.Now()
+	resp, err := auth.Do(d.client, req, d.config.Auth)
+	if err != nil {
+		cancel()
+		d.log().Debug("request failed", "method", req.Method, "url", req.URL.String(), "range", req.Header.Get("Range"), "error", err.Error())
+		return nil, err
+	}
+	d.log().Debug("request", "method", req.Method, "url", req.URL.String(), "range", req.Header.Get("Range"),
+		"status", resp.StatusCode, "served_by", resp.Request.URL.Host, "elapsed", time.Since(start).Round(time.Millisecond))
+
+	if resp.StatusCode < 400 && d.redirects.learn(req.URL, resp.Request.URL) && d.config.Verbose {
+		fmt.Printf("  Redirected to %s, sending later requests there directly\n", resp.Request.URL.Host)
+	}
+
+	resp.Body = &countingBody{ReadCloser: resp.Body, add: d.traffic.counter(resp.Request.URL.Host)}
+	// The stall watchdog leaves out time spent waiting on the bandwidth cap
+	var held *holdClock
+	if d.config.RateLimit > 0 {
+		if stall > 0 {
+			held = &holdClock{}
+		}
+		resp.Body = &throttledBody{ReadCloser: resp.Body, ctx: req.Context(), rate: d.config.RateLimit, limit: &d.bandwidth, held: held}
+	}
+	if d.config.Suspend && d.config.Window != nil {
+		resp.Body = &windowBody{ReadCloser: resp.Body, window: d.config.Window}
+	}
+	if stall > 0 {
+		resp.Body = watchStall(resp.Body, cancel, stall, d.config.StallSpeed, held)
+	}
+	return resp, nil
+}
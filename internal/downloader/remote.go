@@ -0,0 +1,94 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrNoRanges means a server answered a Range request with the whole file
+var ErrNoRanges = errors.New("server does not support range requests")
+
+// rangeReader reads parts of a remote file with Range requests
+type rangeReader struct {
+	d    *Downloader
+	ctx  context.Context
+	url  string
+	size int64
+}
+
+// RemoteFile returns a reader for parts of a file on the server and its size,
+// e.g. to read a zip's central directory without downloading it. The size
+// comes from a HEAD request, as listings round it; each read is one Range
+// request, paced and authenticated like downloads.
+func (d *Downloader) RemoteFile(ctx context.Context, fileURL string) (io.ReaderAt, int64, error) {
+	remote, err := d.cachedHead(ctx, fileURL)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get file size: %w", err)
+	}
+	if remote.size < 0 {
+		return nil, 0, errors.New("server did not report the file size")
+	}
+	if d.ranges.support(remote.host) == rangesUnsupported {
+		return nil, 0, ErrNoRanges
+	}
+	return &rangeReader{d: d, ctx: ctx, url: fileURL, size: remote.size}, remote.size, nil
+}
+
+func (f *rangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= f.size {
+		return 0, io.EOF
+	}
+	want := min(int64(len(p)), f.size-off)
+	if want == 0 {
+		return 0, nil
+	}
+
+	body, err := f.d.OpenRange(f.ctx, f.url, off, want)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = body.Close()
+	}()
+
+	n, err := io.ReadFull(body, p[:want])
+	if err != nil {
+		return n, fmt.Errorf("failed to read range: %w", err)
+	}
+	if want < int64(len(p)) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// OpenRange streams length bytes of a remote file starting at offset with one
+// Range request
+func (d *Downloader) OpenRange(ctx context.Context, fileURL string, offset, length int64) (io.ReadCloser, error) {
+	req, err := d.newRequest(ctx, http.MethodGet, fileURL)
+	if err != nil {
+		return nil, err
+	}
+	if d.ranges.support(req.URL.Host) == rangesUnsupported {
+		return nil, ErrNoRanges
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	resp, err := d.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return resp.Body, nil
+	case http.StatusOK:
+		_ = resp.Body.Close()
+		d.ranges.refuse(resp.Request.URL.Host)
+		return nil, ErrNoRanges
+	default:
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("server returned status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,129 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/nchapman/myrient-dl/internal/cleanup"
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// overlapSize is how many bytes at the end of a partial file are re-fetched and
+// compared before appending, to make sure the local data belongs to the remote file
+const overlapSize = 64 * 1024
+
+// errCannotContinue means an existing partial file can't be safely completed and
+// should be downloaded from scratch instead
+var errCannotContinue = errors.New("cannot continue partial file")
+
+// seedTemp prepares to complete a partial file. After the tail of the file is
+// compared with the server's bytes, its contents are copied into the temp file
+// with a journal, so the download carries on there like an interrupted one:
+// the partial file itself is only replaced once the completed copy verifies,
+// and an interruption leaves it as it was. A temp file an earlier attempt
+// journaled is used as it is. Returns how many bytes were fetched to check
+// the tail.
+func (d *Downloader) seedTemp(ctx context.Context, file parser.FileInfo, remote remoteFile, outputPath string, localSize int64) (int64, error) {
+	tempPath := outputPath + cleanup.TempSuffix
+	if j, err := loadJournal(journalPath(tempPath)); err == nil && j.URL == file.URL && j.Offset > 0 {
+		return 0, nil
+	}
+
+	overlap := min(int64(overlapSize), localSize)
+	start := localSize - overlap
+
+	req, err := d.newRequest(ctx, http.MethodGet, file.URL)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, localSize-1))
+
+	resp, err := d.do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	expectedRange := fmt.Sprintf("bytes %d-%d/%d", start, localSize-1, remote.size)
+	if resp.StatusCode != http.StatusPartialContent || resp.Header.Get("Content-Range") != expectedRange {
+		if resp.StatusCode == http.StatusOK {
+			d.ranges.refuse(resp.Request.URL.Host) // Ignored the Range header entirely
+		}
+		return 0, fmt.Errorf("%w: server did not honor range request (status %d)", errCannotContinue, resp.StatusCode)
+	}
+
+	remoteTail := make([]byte, overlap)
+	if _, err := io.ReadFull(resp.Body, remoteTail); err != nil {
+		return 0, err
+	}
+
+	src, err := os.Open(outputPath) //nolint:gosec // File path is controlled by config and filename from server
+	if err != nil {
+		return overlap, err
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+
+	localTail := make([]byte, overlap)
+	if _, err := src.ReadAt(localTail, start); err != nil {
+		return overlap, err
+	}
+	if !bytes.Equal(localTail, remoteTail) {
+		return overlap, fmt.Errorf("%w: local data does not match remote", errCannotContinue)
+	}
+
+	if d.config.Verbose {
+		fmt.Printf("  ↻ Copying %d bytes already on disk to continue them\n", localSize)
+	}
+	out, err := os.OpenFile(tempPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666) //nolint:gosec // File path is controlled by config and filename from server
+	if err != nil {
+		return overlap, err
+	}
+	w := &journalWriter{
+		file:    out,
+		path:    journalPath(tempPath),
+		hash:    sha256.New(),
+		journal: journal{URL: file.URL, Size: remote.size, ETag: remote.etag},
+	}
+	_, err = io.CopyN(w, src, localSize)
+	if err == nil {
+		err = w.checkpoint()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		removeTemp(tempPath)
+		return overlap, fmt.Errorf("failed to copy partial file: %w", err)
+	}
+	return overlap, nil
+}
+
+// distrustPartial makes later attempts at url download from scratch instead
+// of continuing the partial file on disk, after a continued copy failed
+// verification. The partial file is left alone until a fresh download
+// verifies and replaces it.
+func (d *Downloader) distrustPartial(url string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.distrusted == nil {
+		d.distrusted = make(map[string]bool)
+	}
+	d.distrusted[url] = true
+}
+
+// continuable reports whether a partial file of url may be continued
+func (d *Downloader) continuable(url string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return !d.distrusted[url]
+}
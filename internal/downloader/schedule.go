@@ -0,0 +1,129 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// errSuspended means a transfer stopped because the download window closed
+var errSuspended = errors.New("download window closed")
+
+// Window is a daily time range, in local time, during which new files may start.
+// A window whose end is before its start spans midnight.
+type Window struct {
+	Start time.Duration // Offset from midnight
+	End   time.Duration
+}
+
+// ParseWindow parses a window like "01:00-07:00" or "22:30-06:00"
+func ParseWindow(s string) (Window, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return Window{}, fmt.Errorf("invalid window %q: expected HH:MM-HH:MM", s)
+	}
+
+	var w Window
+	for i, part := range []string{from, to} {
+		t, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return Window{}, fmt.Errorf("invalid window %q: expected HH:MM-HH:MM", s)
+		}
+		offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+		if i == 0 {
+			w.Start = offset
+		} else {
+			w.End = offset
+		}
+	}
+	if w.Start == w.End {
+		return Window{}, fmt.Errorf("invalid window %q: start and end are the same", s)
+	}
+	return w, nil
+}
+
+// String formats the window as HH:MM-HH:MM
+func (w Window) String() string {
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return clock(w.Start) + "-" + clock(w.End)
+}
+
+// Until returns how long to wait from now until the window opens; 0 if it is open
+func (w Window) Until(now time.Time) time.Duration {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	offset := now.Sub(midnight)
+
+	open := offset >= w.Start && offset < w.End
+	if w.End < w.Start {
+		open = offset >= w.Start || offset < w.End
+	}
+	if open {
+		return 0
+	}
+
+	next := midnight.Add(w.Start)
+	if !next.After(now) {
+		next = time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location()).Add(w.Start)
+	}
+	return next.Sub(now)
+}
+
+// Remaining returns how long the window stays open from now; 0 if it is closed
+func (w Window) Remaining(now time.Time) time.Duration {
+	if w.Until(now) > 0 {
+		return 0
+	}
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	end := midnight.Add(w.End)
+	if !end.After(now) {
+		end = time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location()).Add(w.End)
+	}
+	return end.Sub(now)
+}
+
+// waitForWindow blocks until Config.Window is open, Drain is called, or ctx is cancelled
+func (d *Downloader) waitForWindow(ctx context.Context) error {
+	if d.config.Window == nil {
+		return nil
+	}
+	wait := d.config.Window.Until(time.Now())
+	if wait <= 0 {
+		return nil
+	}
+
+	d.mu.Lock()
+	resume := time.Now().Add(wait).Truncate(time.Minute)
+	if !d.windowNotice.Equal(resume) {
+		d.windowNotice = resume
+		fmt.Printf("\nOutside the download window (%s), waiting until %s...\n", d.config.Window, resume.Format("15:04"))
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-time.After(wait):
+		return nil
+	case <-d.drain:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// windowBody ends reads from a response body once the download window closes,
+// so the transfer can be suspended and continued from its temp file
+type windowBody struct {
+	io.ReadCloser
+	window *Window
+}
+
+func (b *windowBody) Read(p []byte) (int, error) {
+	if b.window.Until(time.Now()) > 0 {
+		return 0, errSuspended
+	}
+	return b.ReadCloser.Read(p)
+}
@@ -0,0 +1,230 @@
+package downloader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// minSegmentSize keeps segments large enough that the extra requests pay off,
+// so only files of at least twice this size are split
+const minSegmentSize = 16 * 1024 * 1024
+
+// errCannotSegment means a file can't be fetched in byte ranges and should be
+// downloaded over one connection instead
+var errCannotSegment = errors.New("cannot download in segments")
+
+// segment is an inclusive byte range of a file
+type segment struct {
+	start, end int64
+}
+
+func (s segment) size() int64 {
+	return s.end - s.start + 1
+}
+
+// splitSegments partitions size bytes into at most n contiguous segments of at
+// least minSegmentSize, or returns nil when the file isn't worth splitting
+func splitSegments(size int64, n int) []segment {
+	n = int(min(int64(n), size/minSegmentSize))
+	if n < 2 {
+		return nil
+	}
+
+	step := size / int64(n)
+	segments := make([]segment, n)
+	for i := range segments {
+		segments[i] = segment{start: int64(i) * step, end: int64(i+1)*step - 1}
+	}
+	segments[n-1].end = size - 1
+	return segments
+}
+
+// fetchSegmented downloads a file over one connection per segment, writing each
+// range into place in the temp file, then verifies and renames it like fetch.
+// Each segment's progress is journaled, so a suspended or interrupted download
+// continues every segment from its last checkpoint. If the server doesn't honor
+// range requests, errCannotSegment is returned and the temp file removed so the
+// caller can fall back to a single stream.
+func (d *Downloader) fetchSegmented(ctx context.Context, file parser.FileInfo, res result, remote remoteFile, tempPath, outputPath string, segments []segment) (result, error) {
+	w, err := d.resumeSegments(tempPath, file.URL, remote, segments)
+	if err != nil {
+		if d.config.Verbose && !errors.Is(err, os.ErrNotExist) {
+			fmt.Printf("  ⚠ Discarding partial temp file: %v\n", err)
+		}
+		w = newSegmentWriter(journal{URL: file.URL, Size: res.size, ETag: remote.etag}, segments)
+	}
+	w.path = journalPath(tempPath)
+	if d.config.OnProgress != nil {
+		w.onCheckpoint = func(onDisk int64) { d.config.OnProgress(file, onDisk) }
+	}
+	resumed := w.done()
+	if resumed > 0 {
+		fmt.Printf("  ↻ Resuming %d segments from %d of %d bytes\n", len(segments), resumed, res.size)
+	}
+
+	if err := w.open(tempPath); err != nil {
+		removeTemp(tempPath)
+		return result{}, err
+	}
+	written, err := d.fetchSegments(ctx, file, w)
+	if err != nil {
+		// Interrupted mid-transfer: keep what arrived for a retry or later run,
+		// unless the data itself was bad or segments aren't possible
+		keep := !errors.Is(err, ErrCorrupt) && !errors.Is(err, errCannotSegment) && w.done() > 0 && w.checkpoint() == nil
+		_ = w.file.Close()
+		if !keep {
+			removeTemp(tempPath)
+		}
+		return result{transferred: written}, err
+	}
+	if err := w.file.Close(); err != nil {
+		removeTemp(tempPath)
+		return result{transferred: written}, err
+	}
+
+	if err := d.verifyChecksum(file, tempPath, nil); err != nil {
+		removeTemp(tempPath)
+		return result{transferred: written}, err
+	}
+	if d.config.Verifier != nil {
+		if err := d.config.Verifier.Verify(file, tempPath); err != nil {
+			removeTemp(tempPath)
+			return result{transferred: written}, err
+		}
+	}
+
+	// Segments arrive out of order, so the digest is taken from the finished file
+	sum, err := fileSHA256(tempPath)
+	if err != nil {
+		removeTemp(tempPath)
+		return result{}, err
+	}
+
+	if err := os.Rename(tempPath, outputPath); err != nil {
+		removeTemp(tempPath)
+		return result{}, err
+	}
+	_ = os.Remove(w.path)
+
+	fmt.Println() // New line after progress bar
+	if resumed > 0 {
+		res.outcome = outcomeContinued
+	}
+	res.transferred = written
+	res.sha256 = sum
+	return res, nil
+}
+
+// fetchSegments downloads every unfinished segment concurrently and returns
+// the number of bytes received
+func (d *Downloader) fetchSegments(ctx context.Context, file parser.FileInfo, w *segmentWriter) (int64, error) {
+	// One failed segment stops the others
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	bar := d.progressBar(w.journal.Size-w.done(), fmt.Sprintf("  downloading (%d segments)", len(w.segments)))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var written int64
+	errs := make([]error, len(w.segments))
+	for i := range w.segments {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := d.fetchSegment(ctx, file.URL, w, i, bar)
+			mu.Lock()
+			written += n
+			mu.Unlock()
+			if err != nil {
+				errs[i] = err
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return written, segmentError(errs)
+}
+
+// segmentError picks the error that stopped a segmented download, preferring
+// the root cause over the cancellations it triggered in other segments
+func segmentError(errs []error) error {
+	var first error
+	for _, err := range errs {
+		switch {
+		case err == nil:
+		case errors.Is(err, errCannotSegment):
+			return err
+		case first == nil || errors.Is(first, context.Canceled):
+			first = err
+		}
+	}
+	return first
+}
+
+// fetchSegment downloads the rest of one segment into place
+func (d *Downloader) fetchSegment(ctx context.Context, url string, w *segmentWriter, i int, bar io.Writer) (int64, error) {
+	s := w.segments[i]
+	start := s.start + w.written[i]
+	if start > s.end {
+		return 0, nil // Finished before the download was interrupted
+	}
+
+	req, err := d.newRequest(ctx, http.MethodGet, url)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, s.end))
+
+	resp, err := d.do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	expectedRange := fmt.Sprintf("bytes %d-%d/%d", start, s.end, w.journal.Size)
+	if resp.StatusCode != http.StatusPartialContent || resp.Header.Get("Content-Range") != expectedRange {
+		if resp.StatusCode == http.StatusOK {
+			d.ranges.refuse(resp.Request.URL.Host) // Ignored the Range header entirely
+		}
+		return 0, fmt.Errorf("%w: server did not honor range request (status %d)", errCannotSegment, resp.StatusCode)
+	}
+
+	n, err := io.Copy(io.MultiWriter(w.segment(i), bar), resp.Body)
+	if err != nil {
+		return n, err
+	}
+	if want := s.end - start + 1; n != want {
+		return n, fmt.Errorf("%w: segment received %d of %d bytes", ErrCorrupt, n, want)
+	}
+	return n, nil
+}
+
+// fileSHA256 returns the hex SHA-256 digest of a file
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path) //nolint:gosec // Path is derived from the output directory
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
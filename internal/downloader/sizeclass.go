@@ -0,0 +1,83 @@
+package downloader
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// sizeClasses keeps Config.SmallSlots workers for small files while large ones
+// download, so a disc image occupying a connection for hours doesn't stall
+// thousands of small ROMs behind it. Large files take a slot before competing
+// for a worker; once no small file is waiting, the limit is lifted so a tail
+// of large files still uses every worker.
+type sizeClasses struct {
+	threshold int64
+	slots     chan struct{} // One per large file running; nil when nothing is reserved
+	open      chan struct{} // Closed once no small file is waiting
+	openOnce  sync.Once
+	waiting   atomic.Int64 // Small files not yet started
+}
+
+func newSizeClasses(files []parser.FileInfo, c Config) *sizeClasses {
+	s := &sizeClasses{threshold: c.LargeFile, open: make(chan struct{})}
+	if c.LargeFile <= 0 || c.SmallSlots <= 0 || c.SmallSlots >= c.Parallel {
+		s.threshold = 0
+		return s
+	}
+	s.slots = make(chan struct{}, c.Parallel-c.SmallSlots)
+	for _, f := range files {
+		if !s.large(f) {
+			s.waiting.Add(1)
+		}
+	}
+	if s.waiting.Load() == 0 {
+		s.lift()
+	}
+	return s
+}
+
+// large reports whether a file is limited to the large file slots
+func (s *sizeClasses) large(f parser.FileInfo) bool {
+	return s.threshold > 0 && f.Size >= s.threshold
+}
+
+// acquire waits for a large file slot, or until the limit is lifted. It returns
+// whether a slot is held, to release after the download, and false for ok if
+// the run was cancelled or drained first.
+func (s *sizeClasses) acquire(ctx context.Context, drain <-chan struct{}) (held, ok bool) {
+	select {
+	case <-s.open:
+		return false, true
+	default:
+	}
+	select {
+	case s.slots <- struct{}{}:
+		return true, true
+	case <-s.open:
+		return false, true
+	case <-ctx.Done():
+		return false, false
+	case <-drain:
+		return false, false
+	}
+}
+
+// release frees a slot taken by acquire
+func (s *sizeClasses) release() {
+	<-s.slots
+}
+
+// started records that a small file got a worker
+func (s *sizeClasses) started() {
+	if s.slots != nil && s.waiting.Add(-1) == 0 {
+		s.lift()
+	}
+}
+
+// lift lets large files use every worker
+func (s *sizeClasses) lift() {
+	s.openOnce.Do(func() { close(s.open) })
+}
@@ -0,0 +1,130 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Timeouts used when Config leaves them at zero
+const (
+	defaultConnectTimeout = 30 * time.Second
+	defaultStallTimeout   = time.Minute
+)
+
+// NewClient returns an HTTP client without an overall deadline, which would
+// cut off huge files on slow links. Connections that can't be set up within
+// the connect timeout fail, and ones that stop delivering are caught by
+// stallBody instead. Only ConnectTimeout and StallTimeout are used, so
+// listings can be fetched with the same timeouts as downloads.
+func NewClient(config Config) *http.Client {
+	connect := config.ConnectTimeout
+	if connect <= 0 {
+		connect = defaultConnectTimeout
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: connect, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = connect
+	if stall := config.stallTimeout(); stall > 0 {
+		transport.ResponseHeaderTimeout = stall
+	}
+	return &http.Client{Transport: transport}
+}
+
+// stallTimeout returns Config.StallTimeout with its default; 0 when disabled
+func (c Config) stallTimeout() time.Duration {
+	switch {
+	case c.StallTimeout < 0:
+		return 0
+	case c.StallTimeout == 0:
+		return defaultStallTimeout
+	default:
+		return c.StallTimeout
+	}
+}
+
+// cancellable gives req a context of its own for the stall watchdog to cancel
+func cancellable(req *http.Request) (*http.Request, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(req.Context())
+	return req.WithContext(ctx), cancel
+}
+
+// stallBody aborts a response body whose transfer falls below a minimum
+// speed for a whole stall period. Time its reads spend held by the bandwidth
+// cap doesn't count toward the period, so a capped download isn't taken for
+// a stalled one.
+type stallBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+	read   atomic.Int64
+	held   *holdClock // Time reads were held by the bandwidth cap, if capped
+	err    atomic.Pointer[error]
+	done   chan struct{}
+	once   sync.Once
+}
+
+// watchStall wraps body so the request is cancelled, and its reads fail, once
+// fewer than speed bytes per second (any bytes at all for 0) arrive during a
+// period. held, if set, counts the time reads were held by the bandwidth cap.
+func watchStall(body io.ReadCloser, cancel context.CancelFunc, period time.Duration, speed int64, held *holdClock) *stallBody {
+	b := &stallBody{ReadCloser: body, cancel: cancel, held: held, done: make(chan struct{})}
+	go b.watch(period, speed)
+	return b
+}
+
+func (b *stallBody) watch(period time.Duration, speed int64) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	minimum := max(int64(period.Seconds()*float64(speed)), 1)
+
+	var last int64
+	var lastHeld, active time.Duration // Time since last not held by the cap
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+		}
+		held := b.held.held()
+		active += period - (held - lastHeld)
+		lastHeld = held
+		if active < period {
+			continue // Mostly waiting on the bandwidth cap; keep measuring
+		}
+		read := b.read.Load()
+		if read-last < minimum {
+			err := fmt.Errorf("transfer stalled: %d bytes in %v", read-last, period)
+			if speed > 0 {
+				err = fmt.Errorf("transfer stalled: %d bytes in %v, under %d bytes/s", read-last, period, speed)
+			}
+			b.err.Store(&err)
+			b.cancel()
+			return
+		}
+		last, active = read, 0
+	}
+}
+
+func (b *stallBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read.Add(int64(n))
+	if err != nil && err != io.EOF {
+		if stalled := b.err.Load(); stalled != nil {
+			return n, *stalled
+		}
+	}
+	return n, err
+}
+
+// Close stops the watchdog and releases the request's context
+func (b *stallBody) Close() error {
+	b.once.Do(func() { close(b.done) })
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,142 @@
+package downloader
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// maxThrottledRead bounds each read of a throttled body so waits stay short and smooth
+const maxThrottledRead = 32 * 1024
+
+// sleep waits for d or until ctx is cancelled
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pacer spaces requests at least an interval apart across all workers
+type pacer struct {
+	mu   sync.Mutex
+	next time.Time
+}
+
+// wait blocks until the next request may be sent
+func (p *pacer) wait(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return nil
+	}
+
+	p.mu.Lock()
+	start := p.next
+	if now := time.Now(); start.Before(now) {
+		start = now
+	}
+	p.next = start.Add(interval)
+	p.mu.Unlock()
+
+	return sleep(ctx, time.Until(start))
+}
+
+// bandwidth is a token bucket shared by all downloads, refilled at rate bytes
+// per second with at most one second of burst
+type bandwidth struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// take accounts for n bytes received and blocks until they fit within rate
+func (b *bandwidth) take(ctx context.Context, rate int64, n int) error {
+	b.mu.Lock()
+	now := time.Now()
+	if b.last.IsZero() {
+		b.tokens = float64(rate)
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * float64(rate)
+		if b.tokens > float64(rate) {
+			b.tokens = float64(rate)
+		}
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	var wait time.Duration
+	if b.tokens < 0 {
+		wait = time.Duration(-b.tokens / float64(rate) * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	return sleep(ctx, wait)
+}
+
+// throttledBody holds reads from a response body to the Downloader's bandwidth cap
+type throttledBody struct {
+	io.ReadCloser
+	ctx   context.Context
+	rate  int64
+	limit *bandwidth
+	held  *holdClock // Time spent waiting on the cap, if set
+}
+
+func (b *throttledBody) Read(p []byte) (int, error) {
+	if len(p) > maxThrottledRead {
+		p = p[:maxThrottledRead]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.held.start()
+		if werr := b.limit.take(b.ctx, b.rate, n); werr != nil && err == nil {
+			err = werr
+		}
+		b.held.stop()
+	}
+	return n, err
+}
+
+// holdClock adds up the time a body's reads are held by the bandwidth cap,
+// including a hold still in progress. Its methods do nothing on a nil clock.
+type holdClock struct {
+	mu    sync.Mutex
+	total time.Duration
+	since time.Time // Start of the hold in progress, if any
+}
+
+func (c *holdClock) start() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.since = time.Now()
+	c.mu.Unlock()
+}
+
+func (c *holdClock) stop() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.total += time.Since(c.since)
+	c.since = time.Time{}
+	c.mu.Unlock()
+}
+
+// held returns the time held so far
+func (c *holdClock) held() time.Duration {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.since.IsZero() {
+		return c.total
+	}
+	return c.total + time.Since(c.since)
+}
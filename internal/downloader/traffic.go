@@ -0,0 +1,57 @@
+package downloader
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// traffic counts response body bytes received per host, including failed attempts
+type traffic struct {
+	mu    sync.Mutex
+	hosts map[string]*atomic.Int64
+}
+
+// counter returns the byte counter for a host
+func (t *traffic) counter(host string) *atomic.Int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.hosts == nil {
+		t.hosts = make(map[string]*atomic.Int64)
+	}
+	c, ok := t.hosts[host]
+	if !ok {
+		c = new(atomic.Int64)
+		t.hosts[host] = c
+	}
+	return c
+}
+
+// snapshot returns the bytes received so far by host
+func (t *traffic) snapshot() map[string]int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	bytes := make(map[string]int64, len(t.hosts))
+	for host, c := range t.hosts {
+		bytes[host] = c.Load()
+	}
+	return bytes
+}
+
+// countingBody adds every byte read from a response body to a counter
+type countingBody struct {
+	io.ReadCloser
+	add *atomic.Int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.add.Add(int64(n))
+	return n, err
+}
+
+// Traffic returns the bytes received from each host over the Downloader's
+// lifetime, including retries and failed attempts
+func (d *Downloader) Traffic() map[string]int64 {
+	return d.traffic.snapshot()
+}
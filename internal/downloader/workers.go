@@ -0,0 +1,60 @@
+package downloader
+
+import "time"
+
+// WorkerStats describes how one parallel worker spent a DownloadAll call. A worker
+// that spends most of its time waiting had nothing to do; workers that are busy but
+// each slower than a single download was suggest the server is throttling.
+type WorkerStats struct {
+	Files       int
+	Bytes       int64         // Bytes transferred, excluding skipped and reused data
+	Downloading time.Duration // Time spent on files, including retries and backoff
+	Waiting     time.Duration // Time idle: the startup ramp and after the queue ran dry
+}
+
+// Speed returns the worker's average transfer rate in bytes per second while downloading
+func (w WorkerStats) Speed() float64 {
+	if w.Downloading <= 0 {
+		return 0
+	}
+	return float64(w.Bytes) / w.Downloading.Seconds()
+}
+
+// startWorkers resets the per-worker statistics for a run with n workers.
+// Callers must hold d.mu.
+func (d *Downloader) startWorkers(n int) {
+	now := time.Now()
+	d.summary.Workers = make([]WorkerStats, n)
+	d.idleSince = make([]time.Time, n)
+	for i := range d.idleSince {
+		d.idleSince[i] = now
+	}
+}
+
+// workerBusy records that a worker picked up a file after waiting since its last one
+func (d *Downloader) workerBusy(worker int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.summary.Workers[worker].Waiting += time.Since(d.idleSince[worker])
+}
+
+// workerIdle records the file a worker just finished
+func (d *Downloader) workerIdle(worker int, res result, elapsed time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	w := &d.summary.Workers[worker]
+	w.Files++
+	w.Bytes += res.transferred
+	w.Downloading += elapsed
+	d.idleSince[worker] = time.Now()
+}
+
+// stopWorkers counts the time workers sat idle at the end of the run
+func (d *Downloader) stopWorkers() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range d.summary.Workers {
+		d.summary.Workers[i].Waiting += time.Since(d.idleSince[i])
+		d.idleSince[i] = time.Now()
+	}
+}
@@ -0,0 +1,170 @@
+// Package export writes a collection's contents in formats ROM managers read:
+// ClrMamePro have-lists and Logiqx XML DATs, which RomVault and ClrMamePro
+// both audit against.
+package export
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/nchapman/myrient-dl/internal/hashing"
+)
+
+// Format is an export file format
+type Format string
+
+// Supported export formats
+const (
+	FormatHave Format = "have" // ClrMamePro have-list: one set name per line
+	FormatDAT  Format = "dat"  // Logiqx XML DAT of the sets and their ROMs
+)
+
+// ParseFormat parses a format name as accepted on the command line, including
+// the names of the tools that read each format
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "have", "clrmamepro", "cmpro":
+		return FormatHave, nil
+	case "dat", "romvault", "logiqx":
+		return FormatDAT, nil
+	default:
+		return "", fmt.Errorf("unknown export format %q (expected have or dat)", s)
+	}
+}
+
+// Set is one game: a zip's members, or a single loose file
+type Set struct {
+	Name string
+	ROMs []ROM
+}
+
+// ROM is one file of a set. Digests that aren't known are left empty.
+type ROM struct {
+	Name string `xml:"name,attr"`
+	Size int64  `xml:"size,attr"`
+	CRC  string `xml:"crc,attr,omitempty"`
+	MD5  string `xml:"md5,attr,omitempty"`
+	SHA1 string `xml:"sha1,attr,omitempty"`
+}
+
+// SetName returns the set name of a file: its slash-separated path relative to
+// the collection without the extension
+func SetName(rel string) string {
+	ext := path.Ext(rel)
+	if strings.ContainsAny(ext, " ()[]") {
+		return rel // A dot inside the title, like "v1.0 (USA)", not an extension
+	}
+	return strings.TrimSuffix(rel, ext)
+}
+
+// IsArchive reports whether a file is a zip whose members make up its set
+func IsArchive(rel string) bool {
+	return strings.EqualFold(path.Ext(rel), ".zip")
+}
+
+// ZipSet reads the members of a zip from its central directory. Zips store each
+// member's CRC32, so nothing is decompressed.
+func ZipSet(filePath, rel string) (Set, error) {
+	r, err := zip.OpenReader(filePath)
+	if err != nil {
+		return Set{}, fmt.Errorf("failed to open %s: %w", rel, err)
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	set := Set{Name: SetName(rel)}
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		set.ROMs = append(set.ROMs, ROM{
+			Name: f.Name,
+			Size: int64(f.UncompressedSize64), //nolint:gosec // Member sizes fit in int64
+			CRC:  fmt.Sprintf("%08x", f.CRC32),
+		})
+	}
+	return set, nil
+}
+
+// FileSet describes a loose file as a set holding just that file. The digest is
+// kept when the algorithm is one DATs use.
+func FileSet(rel string, size int64, a hashing.Algorithm, digest string) Set {
+	rom := ROM{Name: path.Base(rel), Size: size}
+	switch a {
+	case hashing.CRC32:
+		rom.CRC = digest
+	case hashing.MD5:
+		rom.MD5 = digest
+	case hashing.SHA1:
+		rom.SHA1 = digest
+	}
+	return Set{Name: SetName(rel), ROMs: []ROM{rom}}
+}
+
+// WriteHaveList writes the set names sorted one per line, as ClrMamePro's
+// "have" list does
+func WriteHaveList(w io.Writer, sets []Set) error {
+	names := make([]string, len(sets))
+	for i, s := range sets {
+		names[i] = s.Name
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := fmt.Fprintln(w, name); err != nil {
+			return fmt.Errorf("failed to write have list: %w", err)
+		}
+	}
+	return nil
+}
+
+// Header describes the collection in an exported DAT
+type Header struct {
+	Name        string `xml:"name"`
+	Description string `xml:"description"`
+	Version     string `xml:"version,omitempty"`
+	Author      string `xml:"author,omitempty"`
+	URL         string `xml:"url,omitempty"`
+}
+
+type datafile struct {
+	XMLName xml.Name  `xml:"datafile"`
+	Header  Header    `xml:"header"`
+	Games   []datGame `xml:"game"`
+}
+
+type datGame struct {
+	Name        string `xml:"name,attr"`
+	Description string `xml:"description"`
+	ROMs        []ROM  `xml:"rom"`
+}
+
+// logiqxDoctype is the document type declaration ROM managers expect
+const logiqxDoctype = `<!DOCTYPE datafile PUBLIC "-//Logiqx//DTD ROM Management Datafile//EN" "http://www.logiqx.com/Dats/datafile.dtd">`
+
+// WriteDAT writes the sets, sorted by name, as a Logiqx XML DAT
+func WriteDAT(w io.Writer, header Header, sets []Set) error {
+	doc := datafile{Header: header, Games: make([]datGame, 0, len(sets))}
+	for _, s := range sets {
+		doc.Games = append(doc.Games, datGame{Name: s.Name, Description: s.Name, ROMs: s.ROMs})
+	}
+	sort.Slice(doc.Games, func(i, j int) bool { return doc.Games[i].Name < doc.Games[j].Name })
+
+	if _, err := io.WriteString(w, xml.Header+logiqxDoctype+"\n"); err != nil {
+		return fmt.Errorf("failed to write DAT: %w", err)
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write DAT: %w", err)
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("failed to write DAT: %w", err)
+	}
+	return nil
+}
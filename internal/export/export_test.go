@@ -0,0 +1,143 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nchapman/myrient-dl/internal/hashing"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Format
+		wantErr bool
+	}{
+		{"have", FormatHave, false},
+		{"ClrMamePro", FormatHave, false},
+		{"dat", FormatDAT, false},
+		{"romvault", FormatDAT, false},
+		{"csv", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseFormat(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFormat(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseFormat(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetName(t *testing.T) {
+	tests := []struct {
+		rel  string
+		want string
+	}{
+		{"Game (USA).zip", "Game (USA)"},
+		{"sub/Game (Rev 1).7z", "sub/Game (Rev 1)"},
+		{"Game v1.0 (USA)", "Game v1.0 (USA)"},
+	}
+
+	for _, tt := range tests {
+		if got := SetName(tt.rel); got != tt.want {
+			t.Errorf("SetName(%q) = %q, want %q", tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestZipSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Game (USA).zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	if _, err := w.Create("dir/"); err != nil {
+		t.Fatal(err)
+	}
+	member, err := w.Create("Game (USA).nes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := member.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := ZipSet(path, "Game (USA).zip")
+	if err != nil {
+		t.Fatalf("ZipSet failed: %v", err)
+	}
+	want := ROM{Name: "Game (USA).nes", Size: 5, CRC: "3610a686"}
+	if set.Name != "Game (USA)" || len(set.ROMs) != 1 || set.ROMs[0] != want {
+		t.Errorf("unexpected set %+v", set)
+	}
+
+	if _, err := ZipSet(filepath.Join(t.TempDir(), "missing.zip"), "missing.zip"); err == nil {
+		t.Error("expected an error for a missing zip")
+	}
+}
+
+func TestFileSet(t *testing.T) {
+	set := FileSet("sub/Game.iso", 10, hashing.SHA1, "abc")
+	if set.Name != "sub/Game" || set.ROMs[0] != (ROM{Name: "Game.iso", Size: 10, SHA1: "abc"}) {
+		t.Errorf("unexpected set %+v", set)
+	}
+	// Digests DATs can't hold are dropped rather than misfiled
+	set = FileSet("Game.iso", 10, hashing.XXH64, "abc")
+	if set.ROMs[0] != (ROM{Name: "Game.iso", Size: 10}) {
+		t.Errorf("unexpected set %+v", set)
+	}
+}
+
+func TestWriteHaveList(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteHaveList(&buf, []Set{{Name: "b"}, {Name: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "a\nb\n" {
+		t.Errorf("unexpected have list %q", buf.String())
+	}
+}
+
+func TestWriteDAT(t *testing.T) {
+	sets := []Set{
+		{Name: "Zelda & Link", ROMs: []ROM{{Name: "Zelda.nes", Size: 5, CRC: "3610a686"}}},
+		{Name: "Alpha", ROMs: []ROM{{Name: "Alpha.iso", Size: 3, SHA1: "abc"}}},
+	}
+	var buf bytes.Buffer
+	if err := WriteDAT(&buf, Header{Name: "NES", Description: "NES (have)"}, sets); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		`<?xml version="1.0" encoding="UTF-8"?>`,
+		"<!DOCTYPE datafile",
+		"<name>NES</name>",
+		`<game name="Zelda &amp; Link">`,
+		`<rom name="Zelda.nes" size="5" crc="3610a686"></rom>`,
+		`<rom name="Alpha.iso" size="3" sha1="abc"></rom>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DAT missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Index(out, `"Alpha"`) > strings.Index(out, `"Zelda &amp; Link"`) {
+		t.Errorf("expected games sorted by name:\n%s", out)
+	}
+}
@@ -0,0 +1,106 @@
+// Package feed maintains an Atom feed of files appearing in watched listings.
+package feed
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/cleanup"
+)
+
+// MaxEntries is the number of entries kept in a feed; older ones are dropped
+const MaxEntries = 100
+
+// Feed is an Atom feed document
+type Feed struct {
+	XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string   `xml:"title"`
+	ID      string   `xml:"id"`
+	Updated string   `xml:"updated"`
+	Entries []Entry  `xml:"entry"`
+}
+
+// Entry is one batch of new files in a listing
+type Entry struct {
+	Title   string  `xml:"title"`
+	ID      string  `xml:"id"`
+	Updated string  `xml:"updated"`
+	Link    Link    `xml:"link"`
+	Content Content `xml:"content"`
+}
+
+// Link points an entry at its listing
+type Link struct {
+	Href string `xml:"href,attr"`
+}
+
+// Content is the plain text body of an entry
+type Content struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// Load reads the feed at path, or returns an empty feed if it doesn't exist yet
+func Load(path string) (*Feed, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // Path is chosen by the user
+	if os.IsNotExist(err) {
+		return &Feed{Title: "myrient-dl: new files", ID: "urn:myrient-dl:watch"}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed: %w", err)
+	}
+
+	var f Feed
+	if err := xml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse feed: %w", err)
+	}
+	return &f, nil
+}
+
+// Add puts an entry for files newly listed at listingURL at the top of the feed
+func (f *Feed) Add(listingURL, title string, names []string, at time.Time) {
+	stamp := at.UTC().Format(time.RFC3339)
+	body := ""
+	for _, name := range names {
+		body += name + "\n"
+	}
+
+	summary := fmt.Sprintf("%d new files", len(names))
+	if len(names) == 1 {
+		summary = "1 new file"
+	}
+
+	entry := Entry{
+		Title:   title + ": " + summary,
+		ID:      fmt.Sprintf("%s#%s", listingURL, stamp),
+		Updated: stamp,
+		Link:    Link{Href: listingURL},
+		Content: Content{Type: "text", Body: body},
+	}
+
+	f.Entries = append([]Entry{entry}, f.Entries...)
+	if len(f.Entries) > MaxEntries {
+		f.Entries = f.Entries[:MaxEntries]
+	}
+	f.Updated = stamp
+}
+
+// Save writes the feed to path, replacing it atomically so readers never see a partial file
+func (f *Feed) Save(path string) error {
+	data, err := xml.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tempPath := path + cleanup.TempSuffix
+	if err := os.WriteFile(tempPath, append([]byte(xml.Header), append(data, '\n')...), 0644); err != nil { //nolint:gosec // Feeds are meant to be read
+		return fmt.Errorf("failed to write feed: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to write feed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package feed
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFeed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feed.xml")
+
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("failed to load missing feed: %v", err)
+	}
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	f.Add("https://myrient.example/files/NES/", "NES", []string{"a.zip", "b & c.zip"}, at)
+	if err := f.Save(path); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `<feed xmlns="http://www.w3.org/2005/Atom">`) || !strings.Contains(string(data), "b &amp; c.zip") {
+		t.Errorf("unexpected feed:\n%s", data)
+	}
+
+	f, err = Load(path)
+	if err != nil {
+		t.Fatalf("failed to reload: %v", err)
+	}
+	f.Add("https://myrient.example/files/SNES/", "SNES", []string{"d.zip"}, at.Add(time.Hour))
+	if len(f.Entries) != 2 || f.Entries[0].Title != "SNES: 1 new file" || f.Entries[1].Link.Href != "https://myrient.example/files/NES/" {
+		t.Errorf("expected newest entry first, got %+v", f.Entries)
+	}
+	if f.Updated != "2024-05-01T13:00:00Z" {
+		t.Errorf("unexpected updated %s", f.Updated)
+	}
+
+	for i := 0; i < MaxEntries; i++ {
+		f.Add("u", "t", []string{"x"}, at)
+	}
+	if len(f.Entries) != MaxEntries {
+		t.Errorf("expected feed capped at %d entries, got %d", MaxEntries, len(f.Entries))
+	}
+}
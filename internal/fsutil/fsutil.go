@@ -0,0 +1,95 @@
+// Package fsutil provides helpers for mapping remote names onto the local filesystem.
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// ErrUnsafePath marks a remote name that would be saved outside the download directory
+var ErrUnsafePath = errors.New("unsafe path")
+
+// SanitizeFilename removes or replaces characters that are problematic for filenames
+func SanitizeFilename(name string) string {
+	// Replace problematic characters with underscores
+	replacer := strings.NewReplacer(
+		":", "_",
+		"|", "_",
+		"<", "_",
+		">", "_",
+		"\"", "_",
+		"?", "_",
+		"*", "_",
+		"/", "_",
+		"\\", "_",
+		"\x00", "_", // null byte
+	)
+	result := replacer.Replace(name)
+
+	// Remove leading dots to prevent hidden files
+	result = strings.TrimLeft(result, ".")
+
+	// Prevent directory traversal
+	if strings.Contains(result, "..") {
+		result = strings.ReplaceAll(result, "..", "_")
+	}
+
+	return result
+}
+
+// SafeName reports whether a name from a listing can be used as a file name
+// as it is: a single path component other than "." and "..", without
+// separators or control characters
+func SafeName(name string) bool {
+	if name == "" || name == "." || name == ".." {
+		return false
+	}
+	for _, r := range name {
+		if r == '/' || r == '\\' || unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Within joins a relative name, slash- or OS-separated, onto dir, refusing
+// names that are absolute or climb out of dir with ".."
+func Within(dir, name string) (string, error) {
+	local := filepath.FromSlash(name)
+	if filepath.IsAbs(local) || filepath.VolumeName(local) != "" || strings.HasPrefix(name, "/") {
+		return "", fmt.Errorf("%w: %q is absolute", ErrUnsafePath, name)
+	}
+	clean := filepath.Clean(local)
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %q leaves the download directory", ErrUnsafePath, name)
+	}
+	return filepath.Join(dir, clean), nil
+}
+
+// CollisionKey returns the key under which two names would land on the same file
+// on a case-insensitive filesystem once sanitized
+func CollisionKey(name string) string {
+	return strings.ToLower(SanitizeFilename(name))
+}
+
+// Slugify turns a name into a lowercase, dash-separated form such as
+// "nintendo-nes" that is safe and convenient on any filesystem
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return b.String()
+}
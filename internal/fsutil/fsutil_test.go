@@ -0,0 +1,125 @@
+package fsutil
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Nintendo - NES", "Nintendo - NES"},
+		{"Game: Subtitle", "Game_ Subtitle"},
+		{"a/b\\c", "a_b_c"},
+		{"What?*", "What__"},
+		{"null\x00byte", "null_byte"},
+		{".hidden", "hidden"},
+		{"...", ""},
+		{"a..b", "a_b"},
+	}
+
+	for _, tt := range tests {
+		if got := SanitizeFilename(tt.input); got != tt.expected {
+			t.Errorf("SanitizeFilename(%q) = %q, expected %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestSafeName(t *testing.T) {
+	tests := []struct {
+		name string
+		safe bool
+	}{
+		{"Sonic (USA).zip", true},
+		{"Game..Special (USA).zip", true},
+		{".hidden", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"../../etc/cron.d/x", false},
+		{"sub/game.zip", false},
+		{"..\\..\\Windows\\x.dll", false},
+		{"/etc/passwd", false},
+		{"line\nbreak.zip", false},
+		{"null\x00byte", false},
+	}
+
+	for _, tt := range tests {
+		if got := SafeName(tt.name); got != tt.safe {
+			t.Errorf("SafeName(%q) = %v, expected %v", tt.name, got, tt.safe)
+		}
+	}
+}
+
+func TestWithin(t *testing.T) {
+	dir := filepath.Join("downloads", "nes")
+	tests := []struct {
+		name     string
+		expected string // "" for an unsafe name
+	}{
+		{"game.zip", filepath.Join(dir, "game.zip")},
+		{"Disc 1/game.zip", filepath.Join(dir, "Disc 1", "game.zip")},
+		{"a/../game.zip", filepath.Join(dir, "game.zip")},
+		{"..game.zip", filepath.Join(dir, "..game.zip")},
+		{"../game.zip", ""},
+		{"../../etc/cron.d/x", ""},
+		{"sub/../../x", ""},
+		{"..", ""},
+		{".", ""},
+		{"/etc/passwd", ""},
+	}
+
+	for _, tt := range tests {
+		got, err := Within(dir, tt.name)
+		if tt.expected == "" {
+			if !errors.Is(err, ErrUnsafePath) {
+				t.Errorf("Within(%q) = %q, %v; expected ErrUnsafePath", tt.name, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.expected {
+			t.Errorf("Within(%q) = %q, %v; expected %q", tt.name, got, err, tt.expected)
+		}
+	}
+}
+
+func TestCollisionKey(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		collide bool
+	}{
+		{"Game (USA).zip", "game (usa).zip", true},
+		{"Game: One.zip", "Game_ One.zip", true},
+		{"Game? (USA).zip", "Game* (USA).zip", true},
+		{"Game (USA).zip", "Game (Europe).zip", false},
+	}
+
+	for _, tt := range tests {
+		if got := CollisionKey(tt.a) == CollisionKey(tt.b); got != tt.collide {
+			t.Errorf("CollisionKey(%q) == CollisionKey(%q) is %v, expected %v", tt.a, tt.b, got, tt.collide)
+		}
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Nintendo - NES", "nintendo-nes"},
+		{"Sony - PlayStation 2", "sony-playstation-2"},
+		{"No-Intro", "no-intro"},
+		{"  ..Weird__Name!! ", "weird-name"},
+		{"Pokémon", "pokémon"},
+		{"...", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Slugify(tt.input); got != tt.expected {
+			t.Errorf("Slugify(%q) = %q, expected %q", tt.input, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package hashing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hello.bin")
+	if err := os.WriteFile(path, []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[Algorithm]string{
+		CRC32:  "3610a686",
+		MD5:    "5d41402abc4b2a76b9719d911017c592",
+		SHA1:   "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d",
+		SHA256: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		XXH64:  "26c7827d889f6da3",
+		BLAKE3: "ea8f163db38682925e4491c5e58d4bb3506ef8c14eb78a86e908c5624a67200f",
+	}
+	for _, a := range Algorithms {
+		t.Run(string(a), func(t *testing.T) {
+			digest, err := File(path, a)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if digest != expected[a] {
+				t.Errorf("expected %s, got %s", expected[a], digest)
+			}
+		})
+	}
+
+	if _, err := File(filepath.Join(t.TempDir(), "missing"), SHA1); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Algorithm
+		wantErr  bool
+	}{
+		{"sha1", SHA1, false},
+		{"SHA-256", SHA256, false},
+		{"xxhash", XXH64, false},
+		{"xxh64", XXH64, false},
+		{"BLAKE3", BLAKE3, false},
+		{"whirlpool", "", true},
+	}
+
+	for _, tt := range tests {
+		a, err := Parse(tt.input)
+		if (err != nil) != tt.wantErr || a != tt.expected {
+			t.Errorf("Parse(%q) = %q, %v", tt.input, a, err)
+		}
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for i := range 20 {
+		path := filepath.Join(dir, fmt.Sprintf("file%02d", i))
+		if err := os.WriteFile(path, []byte(fmt.Sprintf("content %d", i)), 0600); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	paths = append(paths, filepath.Join(dir, "missing"))
+
+	results, err := Files(context.Background(), paths, XXH64, 4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != len(paths) {
+		t.Fatalf("expected %d results, got %d", len(paths), len(results))
+	}
+	for i, r := range results[:20] {
+		want, _ := File(paths[i], XXH64)
+		if r.Path != paths[i] || r.Digest != want || r.Err != nil {
+			t.Errorf("result %d out of order or wrong: %+v", i, r)
+		}
+	}
+	if results[20].Err == nil {
+		t.Error("expected an error for the missing file")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Files(ctx, paths, XXH64, 2); err == nil {
+		t.Error("expected cancellation to be reported")
+	}
+}
@@ -0,0 +1,172 @@
+// Package history keeps an append-only log of every download attempt across runs.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Result is the outcome of a logged download
+type Result string
+
+// Logged outcomes
+const (
+	ResultCompleted Result = "completed"
+	ResultFailed    Result = "failed"
+	// ResultFinished marks a whole run that downloaded every file of its plan,
+	// rather than a single download
+	ResultFinished Result = "finished"
+)
+
+// Entry is a single line of the history log
+type Entry struct {
+	Time     time.Time     `json:"time"`
+	Source   string        `json:"source"` // Listing URL the run was started from
+	Name     string        `json:"name"`
+	URL      string        `json:"url"`
+	Mirror   string        `json:"mirror,omitempty"` // URL that served the file when the listed one failed
+	Path     string        `json:"path"`             // Local destination
+	Bytes    int64         `json:"bytes"`
+	Duration time.Duration `json:"duration_ns"`
+	Result   Result        `json:"result"`
+	Error    string        `json:"error,omitempty"`
+	Plan     string        `json:"plan,omitempty"` // Hash of a finished run's selection
+}
+
+// DefaultPath returns the history log location in the user's config directory
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+	return filepath.Join(dir, "myrient-dl", "history.jsonl"), nil
+}
+
+// Log appends entries to a history file
+type Log struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// Open opens the history log at path for appending, creating it if needed
+func Open(path string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil { //nolint:gosec // Config directory permissions
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644) //nolint:gosec // History is not sensitive
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	return &Log{file: file}, nil
+}
+
+// Append writes an entry as a single JSON line. It is safe for concurrent use.
+func (l *Log) Append(e Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// Sync commits the entries written so far to stable storage
+func (l *Log) Sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("failed to flush history: %w", err)
+	}
+	return nil
+}
+
+// Close closes the underlying file
+func (l *Log) Close() error {
+	return l.file.Close()
+}
+
+// Read returns the entries logged at or after since, oldest first.
+// A missing log reads as empty; malformed lines are skipped.
+func Read(path string, since time.Time) ([]Entry, error) {
+	file, err := os.Open(path) //nolint:gosec // Path is the user's history log
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue // A crash mid-write can leave a partial last line
+		}
+		if !e.Time.Before(since) {
+			entries = append(entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	return entries, nil
+}
+
+// LastRun returns the most recent finished run of the plan with the given hash
+func LastRun(entries []Entry, plan string) (Entry, bool) {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if e := entries[i]; e.Result == ResultFinished && e.Plan == plan {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// sinceLayouts are the date forms ParseSince accepts, tried in order
+var sinceLayouts = []string{"2006-01-02", "2006-01-02 15:04", "2006-01-02T15:04", time.RFC3339}
+
+// ParseSince parses a lookback such as "7d", "2w", or "36h", or a date such as
+// "2024-05-01" (optionally with a time, "2024-05-01 15:04", or RFC 3339 with a
+// zone) in UTC unless a zone is given, as listings and the history log are,
+// into the earliest time to include
+func ParseSince(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range sinceLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil || count < 0 {
+				return time.Time{}, fmt.Errorf("invalid time %q", s)
+			}
+			return now.Add(-time.Duration(count) * unit), nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("invalid time %q (expected an age such as 7d, 2w, or 36h, or a date such as 2024-05-01 or 2024-05-01 15:04)", s)
+	}
+	return now.Add(-d), nil
+}
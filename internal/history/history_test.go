@@ -0,0 +1,129 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLog_AppendRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "history.jsonl")
+	now := time.Now().UTC()
+
+	log, err := Open(path)
+	if err != nil {
+		t.Fatalf("failed to open log: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i, name := range []string{"a.zip", "b.zip", "c.zip"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			entry := Entry{Time: now.Add(-time.Duration(i) * 24 * time.Hour), Name: name, Bytes: 100, Result: ResultCompleted}
+			if err := log.Append(entry); err != nil {
+				t.Errorf("failed to append: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := log.Sync(); err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+	if err := log.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := Read(path, time.Time{})
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(all))
+	}
+
+	recent, err := Read(path, now.Add(-36*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != 2 {
+		t.Errorf("expected 2 entries in the last 36h, got %d", len(recent))
+	}
+}
+
+func TestRead_MissingAndPartial(t *testing.T) {
+	dir := t.TempDir()
+
+	entries, err := Read(filepath.Join(dir, "missing.jsonl"), time.Time{})
+	if err != nil || len(entries) != 0 {
+		t.Errorf("expected empty history, got %v (%v)", entries, err)
+	}
+
+	path := filepath.Join(dir, "history.jsonl")
+	data := `{"time":"2024-05-01T10:00:00Z","name":"ok.zip","result":"completed"}` + "\n" + `{"time":"2024-05-01T11:00`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	entries, err = Read(path, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name != "ok.zip" {
+		t.Errorf("expected partial line to be skipped, got %+v", entries)
+	}
+}
+
+func TestLastRun(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+	entries := []Entry{
+		{Time: day(1), Path: "/roms/a", Result: ResultFinished, Plan: "abc"},
+		{Time: day(2), Name: "x.zip", Result: ResultCompleted, Plan: "abc"},
+		{Time: day(3), Path: "/roms/b", Result: ResultFinished, Plan: "abc"},
+		{Time: day(4), Path: "/roms/c", Result: ResultFinished, Plan: "def"},
+	}
+
+	run, ok := LastRun(entries, "abc")
+	if !ok || run.Path != "/roms/b" {
+		t.Errorf("expected the latest finished run in /roms/b, got %+v (%v)", run, ok)
+	}
+	if _, ok := LastRun(entries, "xyz"); ok {
+		t.Error("expected no run for an unknown plan")
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		input    string
+		expected time.Time
+		wantErr  bool
+	}{
+		{"7d", now.AddDate(0, 0, -7), false},
+		{"2w", now.AddDate(0, 0, -14), false},
+		{"36h", now.Add(-36 * time.Hour), false},
+		{"2024-05-01", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), false},
+		{"2024-05-01 15:04", time.Date(2024, 5, 1, 15, 4, 0, 0, time.UTC), false},
+		{"2024-05-01T15:04", time.Date(2024, 5, 1, 15, 4, 0, 0, time.UTC), false},
+		{"2024-05-01T15:04:00+02:00", time.Date(2024, 5, 1, 13, 4, 0, 0, time.UTC), false},
+		{" 7d ", now.AddDate(0, 0, -7), false},
+		{"2024-13-01", time.Time{}, true},
+		{"xd", time.Time{}, true},
+		{"-3d", time.Time{}, true},
+		{"soon", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseSince(tt.input, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error state: %v", err)
+			}
+			if !tt.wantErr && !got.Equal(tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
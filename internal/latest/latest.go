@@ -0,0 +1,155 @@
+// Package latest maintains a latest/ directory inside a download directory with
+// one symlink per release pointing at its newest revision, so emulator
+// frontends see a single copy of each title.
+package latest
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/nchapman/myrient-dl/internal/naming"
+)
+
+// Dir is the name of the links directory inside a download directory
+const Dir = "latest"
+
+// View tracks the newest revision of each release in a download directory and
+// keeps the links in latest/ pointing at them. It is safe for concurrent use.
+type View struct {
+	dir    string
+	mu     sync.Mutex
+	newest map[string]string // Variant key to slash-separated relative path
+}
+
+// Open builds the view of the files at the given slash-separated paths relative
+// to dir, creating missing links and removing links that are no longer newest
+func Open(dir string, paths []string) (*View, error) {
+	v := &View{dir: dir, newest: make(map[string]string)}
+	for _, p := range paths {
+		v.consider(p)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, Dir), 0755); err != nil { //nolint:gosec // 0755 is appropriate for download directories
+		return nil, fmt.Errorf("failed to create %s directory: %w", Dir, err)
+	}
+	if err := v.prune(); err != nil {
+		return nil, err
+	}
+	for _, p := range v.newest {
+		if err := v.link(p); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// Len returns the number of releases linked
+func (v *View) Len() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return len(v.newest)
+}
+
+// Add records a newly arrived file, pointing its release's link at it if it is
+// the newest revision. It reports whether the link changed.
+func (v *View) Add(p string) (bool, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	old, had := v.newest[naming.VariantKey(path.Base(p))]
+	if !v.consider(p) {
+		return false, nil
+	}
+	if had && old != p {
+		if err := v.unlink(old); err != nil {
+			return false, err
+		}
+	}
+	return true, v.link(p)
+}
+
+// consider makes p the newest revision of its release if it is newer than the
+// current one, reporting whether it did
+func (v *View) consider(p string) bool {
+	key := naming.VariantKey(path.Base(p))
+	current, ok := v.newest[key]
+	if ok && (current == p || naming.CompareRevisions(path.Base(p), path.Base(current)) <= 0) {
+		return false
+	}
+	v.newest[key] = p
+	return true
+}
+
+// linkPath returns where the link for a file lives
+func (v *View) linkPath(p string) string {
+	return filepath.Join(v.dir, Dir, filepath.FromSlash(p))
+}
+
+// link points the link for p at the file, replacing whatever was there
+func (v *View) link(p string) error {
+	linkPath := v.linkPath(p)
+	target, err := filepath.Rel(filepath.Dir(linkPath), filepath.Join(v.dir, filepath.FromSlash(p)))
+	if err != nil {
+		return fmt.Errorf("failed to link %s: %w", p, err)
+	}
+	if existing, err := os.Readlink(linkPath); err == nil && existing == target {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(linkPath), 0755); err != nil { //nolint:gosec // 0755 is appropriate for download directories
+		return fmt.Errorf("failed to link %s: %w", p, err)
+	}
+	if err := v.unlink(p); err != nil {
+		return err
+	}
+	if err := os.Symlink(target, linkPath); err != nil {
+		return fmt.Errorf("failed to link %s: %w", p, err)
+	}
+	return nil
+}
+
+// unlink removes the link for p if there is one. Anything else at that path is
+// left alone.
+func (v *View) unlink(p string) error {
+	linkPath := v.linkPath(p)
+	info, err := os.Lstat(linkPath)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return nil
+	}
+	if err := os.Remove(linkPath); err != nil {
+		return fmt.Errorf("failed to remove old link for %s: %w", p, err)
+	}
+	return nil
+}
+
+// prune removes links in latest/ that don't point at a newest revision
+func (v *View) prune() error {
+	keep := make(map[string]bool, len(v.newest))
+	for _, p := range v.newest {
+		keep[p] = true
+	}
+
+	root := filepath.Join(v.dir, Dir)
+	return filepath.WalkDir(root, func(linkPath string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type()&os.ModeSymlink == 0 {
+			return nil
+		}
+		rel, err := filepath.Rel(root, linkPath)
+		if err != nil {
+			return err
+		}
+		if keep[filepath.ToSlash(rel)] {
+			return nil
+		}
+		if err := os.Remove(linkPath); err != nil {
+			return fmt.Errorf("failed to remove stale link %s: %w", rel, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,104 @@
+package latest
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func touch(t *testing.T, dir, name string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// links returns the link names in latest/ and the files they resolve to
+func links(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(filepath.Join(dir, Dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, Dir, e.Name()))
+		if err != nil {
+			t.Fatalf("link %s doesn't resolve: %v", e.Name(), err)
+		}
+		got[e.Name()] = string(data)
+	}
+	return got
+}
+
+func keys(m map[string]string) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestView(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"Sonic (USA).zip", "Sonic (USA) (Rev 1).zip", "Sonic (Europe).zip"} {
+		touch(t, dir, name)
+	}
+	// A stale link from an earlier run
+	if err := os.MkdirAll(filepath.Join(dir, Dir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../Sonic (USA).zip", filepath.Join(dir, Dir, "Sonic (USA).zip")); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := Open(dir, []string{"Sonic (USA).zip", "Sonic (USA) (Rev 1).zip", "Sonic (Europe).zip"})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	got := links(t, dir)
+	if want := []string{"Sonic (Europe).zip", "Sonic (USA) (Rev 1).zip"}; !reflect.DeepEqual(keys(got), want) {
+		t.Fatalf("expected links %v, got %v", want, keys(got))
+	}
+	if got["Sonic (USA) (Rev 1).zip"] != "Sonic (USA) (Rev 1).zip" {
+		t.Errorf("link points at the wrong file: %q", got["Sonic (USA) (Rev 1).zip"])
+	}
+	if v.Len() != 2 {
+		t.Errorf("expected 2 releases, got %d", v.Len())
+	}
+
+	// An older revision arriving late changes nothing
+	changed, err := v.Add("Sonic (USA).zip")
+	if err != nil || changed {
+		t.Errorf("expected no change for an older revision, got %v, %v", changed, err)
+	}
+
+	// A newer one replaces the link
+	touch(t, dir, "Sonic (USA) (Rev 2).zip")
+	changed, err = v.Add("Sonic (USA) (Rev 2).zip")
+	if err != nil || !changed {
+		t.Fatalf("expected the link to change, got %v, %v", changed, err)
+	}
+	if want := []string{"Sonic (Europe).zip", "Sonic (USA) (Rev 2).zip"}; !reflect.DeepEqual(keys(links(t, dir)), want) {
+		t.Errorf("expected links %v, got %v", want, keys(links(t, dir)))
+	}
+}
+
+func TestView_LeavesRegularFiles(t *testing.T) {
+	dir := t.TempDir()
+	touch(t, dir, "Game (USA).zip")
+	if err := os.MkdirAll(filepath.Join(dir, Dir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	touch(t, filepath.Join(dir, Dir), "notes.txt")
+
+	if _, err := Open(dir, []string{"Game (USA).zip"}); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, Dir, "notes.txt")); err != nil {
+		t.Errorf("expected regular files in %s to be kept: %v", Dir, err)
+	}
+}
@@ -0,0 +1,201 @@
+// Package layout arranges the files of a download directory by a path template
+// such as "{letter}/{name}", and moves an existing library into a new one.
+package layout
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/nchapman/myrient-dl/internal/fsutil"
+	"github.com/nchapman/myrient-dl/internal/naming"
+)
+
+// Flat is the layout of a plain download: files where the listing puts them
+const Flat = "{dir}/{name}"
+
+// Presets are named templates for common layouts
+var Presets = map[string]string{
+	"flat":   Flat,
+	"letter": "{letter}/{name}",
+	"region": "{region}/{name}",
+	"title":  "{title}/{name}",
+}
+
+// fields are the placeholders a template may use
+var fields = map[string]func(rel string) string{
+	"name":   func(rel string) string { return path.Base(rel) },
+	"title":  func(rel string) string { return naming.Title(path.Base(rel)) },
+	"letter": func(rel string) string { return Letter(path.Base(rel)) },
+	"region": func(rel string) string { return Region(path.Base(rel)) },
+	"dir":    func(rel string) string { return path.Dir(rel) },
+}
+
+// Template maps a file's listed path to its path in a layout
+type Template struct {
+	source   string
+	segments [][]piece // One per path component
+}
+
+// piece is literal text or, if field is set, a placeholder
+type piece struct {
+	text  string
+	field string
+}
+
+// Parse compiles a template or the name of a preset. Placeholders are {name},
+// {title}, {letter}, {region}, and {dir}; the last path component must contain
+// {name} so every file keeps its own name and extension.
+func Parse(s string) (*Template, error) {
+	if preset, ok := Presets[s]; ok {
+		s = preset
+	}
+	if s == "" || strings.HasPrefix(s, "/") {
+		return nil, fmt.Errorf("template %q must be a relative path", s)
+	}
+
+	t := &Template{source: s}
+	components := strings.Split(s, "/")
+	for i, component := range components {
+		if component == "" || component == "." || component == ".." {
+			return nil, fmt.Errorf("template %q has an empty, . or .. path component", s)
+		}
+		seg, err := parseSegment(component)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template %q: %w", s, err)
+		}
+		hasName := false
+		for _, p := range seg {
+			hasName = hasName || p.field == "name"
+			if p.field == "dir" && len(seg) != 1 {
+				return nil, fmt.Errorf("invalid template %q: {dir} must be a whole path component", s)
+			}
+		}
+		if i == len(components)-1 && !hasName {
+			return nil, fmt.Errorf("invalid template %q: the last path component must contain {name}", s)
+		}
+		t.segments = append(t.segments, seg)
+	}
+	return t, nil
+}
+
+func parseSegment(component string) ([]piece, error) {
+	var seg []piece
+	for component != "" {
+		open := strings.IndexByte(component, '{')
+		if open < 0 {
+			seg = append(seg, piece{text: component})
+			break
+		}
+		if open > 0 {
+			seg = append(seg, piece{text: component[:open]})
+		}
+		end := strings.IndexByte(component[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed placeholder in %q", component)
+		}
+		field := component[open+1 : open+end]
+		if _, ok := fields[field]; !ok {
+			return nil, fmt.Errorf("unknown placeholder {%s} (expected name, title, letter, region, or dir)", field)
+		}
+		seg = append(seg, piece{field: field})
+		component = component[open+end+1:]
+	}
+	return seg, nil
+}
+
+// String returns the template as written, with presets expanded
+func (t *Template) String() string {
+	return t.source
+}
+
+// Path returns where a file belongs in the layout, given its slash-separated
+// path relative to the download directory. Values derived from the name are
+// sanitized; components that come out empty, such as {dir} for a file at the
+// top, are dropped.
+func (t *Template) Path(rel string) string {
+	var out []string
+	for _, seg := range t.segments {
+		var b strings.Builder
+		for _, p := range seg {
+			switch {
+			case p.field == "dir":
+				if dir := fields["dir"](rel); dir != "." {
+					b.WriteString(dir)
+				}
+			case p.field == "name":
+				b.WriteString(fields["name"](rel))
+			case p.field != "":
+				b.WriteString(fsutil.SanitizeFilename(fields[p.field](rel)))
+			default:
+				b.WriteString(p.text)
+			}
+		}
+		if b.Len() > 0 {
+			out = append(out, b.String())
+		}
+	}
+	return strings.Join(out, "/")
+}
+
+// Listed reverses Path as far as it can, returning the listed path of a file
+// at rel in the layout. Only {dir} carries the listed directory, so templates
+// without it give just the file name.
+func (t *Template) Listed(rel string) string {
+	parts := strings.Split(rel, "/")
+	name := parts[len(parts)-1]
+	for i, seg := range t.segments {
+		if len(seg) != 1 || seg[0].field != "dir" {
+			continue
+		}
+		// The components around {dir} are the ones the template adds
+		after := len(t.segments) - i - 1
+		if len(parts)-after <= i {
+			return name
+		}
+		return path.Join(path.Join(parts[i:len(parts)-after]...), name)
+	}
+	return name
+}
+
+// Letter returns the folder a name sorts under: the uppercased first letter of
+// its title, or "#" for titles starting with anything else
+func Letter(name string) string {
+	for _, r := range naming.Title(name) {
+		if r < unicode.MaxASCII && unicode.IsLetter(r) {
+			return string(unicode.ToUpper(r))
+		}
+		return "#"
+	}
+	return "#"
+}
+
+// Region returns a name's first parenthesized tag, which No-Intro and Redump
+// use for the region, e.g. "USA, Europe", or "Unknown" if it has none
+func Region(name string) string {
+	name = strings.TrimSuffix(name, path.Ext(name))
+	depth := 0
+	start := -1
+	for i, r := range name {
+		switch r {
+		case '(', '[':
+			if depth == 0 && r == '(' {
+				start = i + 1
+			}
+			depth++
+		case ')', ']':
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth == 0 && start >= 0 {
+				if region := strings.TrimSpace(name[start:i]); region != "" {
+					return region
+				}
+				start = -1
+			}
+		}
+	}
+	return "Unknown"
+}
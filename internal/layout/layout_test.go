@@ -0,0 +1,252 @@
+package layout
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/nchapman/myrient-dl/internal/manifest"
+	"github.com/nchapman/myrient-dl/internal/sidecar"
+)
+
+func TestTemplate_Path(t *testing.T) {
+	tests := []struct {
+		template string
+		rel      string
+		want     string
+	}{
+		{"letter", "Zelda (USA).zip", "Z/Zelda (USA).zip"},
+		{"letter", "3 Ninjas (USA).zip", "#/3 Ninjas (USA).zip"},
+		{"letter", "Élan (France).zip", "#/Élan (France).zip"},
+		{"region", "[BIOS] Sys (USA, Europe) (Rev 1).zip", "USA, Europe/[BIOS] Sys (USA, Europe) (Rev 1).zip"},
+		{"region", "Homebrew.zip", "Unknown/Homebrew.zip"},
+		{"title", "Sonic (Europe) (Rev 1).zip", "Sonic/Sonic (Europe) (Rev 1).zip"},
+		{"flat", "Disc 1/Game (USA).cue", "Disc 1/Game (USA).cue"},
+		{"flat", "Game (USA).zip", "Game (USA).zip"},
+		{"{region}/{letter}/{name}", "Disc 1/Game (Japan).cue", "Japan/G/Game (Japan).cue"},
+		{"{dir}/{letter}/{name}", "Game (Japan).zip", "G/Game (Japan).zip"},
+		{"roms/{letter}-{region}/{name}", "Mario: Special (USA).zip", "roms/M-USA/Mario: Special (USA).zip"},
+		{"{title}/{name}", "What? (USA).zip", "What_/What? (USA).zip"},
+	}
+	for _, tt := range tests {
+		tmpl, err := Parse(tt.template)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.template, err)
+		}
+		if got := tmpl.Path(tt.rel); got != tt.want {
+			t.Errorf("%s: Path(%q) = %q, want %q", tt.template, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"/abs/{name}",
+		"{letter}",
+		"{name}/{letter}",
+		"../{name}",
+		"a//{name}",
+		"{year}/{name}",
+		"{letter/{name}",
+		"x{dir}/{name}",
+	} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Parse(%q): expected an error", s)
+		}
+	}
+}
+
+func TestTemplate_Listed(t *testing.T) {
+	tests := []struct {
+		template string
+		rel      string
+		want     string
+	}{
+		{"flat", "Disc 1/Game (USA).cue", "Disc 1/Game (USA).cue"},
+		{"flat", "Game (USA).zip", "Game (USA).zip"},
+		{"letter", "G/Game (USA).zip", "Game (USA).zip"},
+		{"{region}/{dir}/{letter}/{name}", "USA/Disc 1/G/Game (USA).cue", "Disc 1/Game (USA).cue"},
+		{"{region}/{dir}/{letter}/{name}", "USA/G/Game (USA).cue", "Game (USA).cue"},
+		{"{region}/{dir}/{letter}/{name}", "Game (USA).cue", "Game (USA).cue"},
+	}
+	for _, tt := range tests {
+		tmpl, err := Parse(tt.template)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.template, err)
+		}
+		if got := tmpl.Listed(tt.rel); got != tt.want {
+			t.Errorf("%s: Listed(%q) = %q, want %q", tt.template, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	flat, err := Parse("flat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	letter, err := Parse("letter")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moves, err := Plan(flat, letter, []string{"Alpha.zip", "B/Beta.zip", "Gamma.zip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Move{{From: "Alpha.zip", To: "A/Alpha.zip"}, {From: "Gamma.zip", To: "G/Gamma.zip"}}
+	if !reflect.DeepEqual(moves, want) {
+		t.Errorf("expected %v, got %v", want, moves)
+	}
+
+	// Going back undoes the layout
+	moves, err = Plan(letter, flat, []string{"A/Alpha.zip", "B/Beta.zip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []Move{{From: "A/Alpha.zip", To: "Alpha.zip"}, {From: "B/Beta.zip", To: "Beta.zip"}}
+	if !reflect.DeepEqual(moves, want) {
+		t.Errorf("expected %v, got %v", want, moves)
+	}
+
+	if _, err := Plan(flat, flat, []string{"x/Alpha.zip", "x/alpha.zip"}); err == nil {
+		t.Error("expected an error for two files landing on the same path")
+	}
+	if _, err := Plan(letter, flat, []string{"x/Alpha.zip", "y/Alpha.zip"}); err == nil {
+		t.Error("expected an error for two files landing on the same path")
+	}
+}
+
+func write(t *testing.T, dir, rel, content string) {
+	t.Helper()
+	p := filepath.Join(dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func read(t *testing.T, dir, rel string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rel)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestApply(t *testing.T) {
+	dir := t.TempDir()
+	write(t, dir, "old/Alpha.zip", "alpha")
+	write(t, dir, "old/Alpha.zip"+sidecar.Suffix, "{}")
+	write(t, dir, "Beta.zip", "beta")
+
+	m, err := manifest.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Put(manifest.Entry{Path: "old/Alpha.zip", Size: 5})
+	m.Put(manifest.Entry{Path: "Beta.zip", Size: 4})
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Swapping two paths works because every file is staged first
+	moves := []Move{
+		{From: "old/Alpha.zip", To: "Beta.zip"},
+		{From: "Beta.zip", To: "B/Beta.zip"},
+	}
+	if err := Apply(dir, "custom", moves); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := read(t, dir, "Beta.zip"); got != "alpha" {
+		t.Errorf("expected Alpha's content at Beta.zip, got %q", got)
+	}
+	if got := read(t, dir, "B/Beta.zip"); got != "beta" {
+		t.Errorf("expected Beta's content at B/Beta.zip, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Beta.zip"+sidecar.Suffix)); err != nil {
+		t.Errorf("expected the sidecar to move with its file: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old")); !os.IsNotExist(err) {
+		t.Error("expected the emptied directory to be removed")
+	}
+	if _, err := Pending(dir); !os.IsNotExist(err) {
+		t.Errorf("expected the journal to be removed, got %v", err)
+	}
+
+	m, err = manifest.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Layout != "custom" {
+		t.Errorf("expected the layout to be recorded, got %q", m.Layout)
+	}
+	if e, ok := m.Get("Beta.zip"); !ok || e.Size != 5 {
+		t.Errorf("unexpected entry for Beta.zip: %+v", e)
+	}
+	if e, ok := m.Get("B/Beta.zip"); !ok || e.Size != 4 {
+		t.Errorf("unexpected entry for B/Beta.zip: %+v", e)
+	}
+}
+
+func TestApply_Conflict(t *testing.T) {
+	dir := t.TempDir()
+	write(t, dir, "Alpha.zip", "alpha")
+	write(t, dir, "A/Alpha.zip", "other")
+
+	err := Apply(dir, "letter", []Move{{From: "Alpha.zip", To: "A/Alpha.zip"}})
+	if err == nil {
+		t.Fatal("expected an error for an existing destination")
+	}
+	if got := read(t, dir, "Alpha.zip"); got != "alpha" {
+		t.Errorf("expected the file to stay put, got %q", got)
+	}
+	if _, err := Pending(dir); !os.IsNotExist(err) {
+		t.Errorf("expected no journal, got %v", err)
+	}
+}
+
+func TestJournal_Finish(t *testing.T) {
+	dir := t.TempDir()
+	write(t, dir, "Alpha.zip", "alpha")
+	write(t, dir, "Beta.zip", "beta")
+
+	// Simulate a run interrupted after staging Alpha but not Beta
+	j := &Journal{
+		Layout: Presets["letter"],
+		Moves:  []Move{{From: "Alpha.zip", To: "A/Alpha.zip"}, {From: "Beta.zip", To: "B/Beta.zip"}},
+	}
+	if err := j.save(dir); err != nil {
+		t.Fatal(err)
+	}
+	staging := filepath.Join(dir, ".myrient-dl", stagingName)
+	if err := os.MkdirAll(staging, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "Alpha.zip"), filepath.Join(staging, "0")); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := Pending(dir)
+	if err != nil {
+		t.Fatalf("expected a pending journal, got %v", err)
+	}
+	if err := pending.Finish(dir); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, dir, "A/Alpha.zip"); got != "alpha" {
+		t.Errorf("unexpected A/Alpha.zip %q", got)
+	}
+	if got := read(t, dir, "B/Beta.zip"); got != "beta" {
+		t.Errorf("unexpected B/Beta.zip %q", got)
+	}
+	if _, err := os.Stat(staging); !os.IsNotExist(err) {
+		t.Error("expected the staging directory to be removed")
+	}
+}
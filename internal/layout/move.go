@@ -0,0 +1,240 @@
+package layout
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nchapman/myrient-dl/internal/cleanup"
+	"github.com/nchapman/myrient-dl/internal/manifest"
+	"github.com/nchapman/myrient-dl/internal/sidecar"
+)
+
+// Locations of an in-progress reorganization inside the state directory
+const (
+	journalName = "reorganize.json"
+	stagingName = "reorganize"
+)
+
+// Move relocates one file, by slash-separated paths relative to the directory
+type Move struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Journal records a reorganization before any file moves, so one that's
+// interrupted can be finished by running it again
+type Journal struct {
+	Layout string `json:"layout"`
+	Moves  []Move `json:"moves"`
+	Staged bool   `json:"staged"` // Every file has been moved into the staging directory
+
+	// Manifest holds the manifest entries with their new paths, written as a
+	// whole once the files are in place
+	Manifest []manifest.Entry `json:"manifest"`
+}
+
+// Plan returns the moves that take the files at the given relative paths from
+// one layout to another. Files already in place are left out. It fails if two
+// files would end up at the same path, ignoring case.
+func Plan(current, target *Template, paths []string) ([]Move, error) {
+	claimed := make(map[string]string, len(paths))
+	var moves []Move
+	for _, p := range paths {
+		p = filepath.ToSlash(p)
+		to := target.Path(current.Listed(p))
+		key := strings.ToLower(to)
+		if other, ok := claimed[key]; ok {
+			return nil, fmt.Errorf("%s and %s would both be moved to %s", other, p, to)
+		}
+		claimed[key] = p
+		if to != p {
+			moves = append(moves, Move{From: p, To: to})
+		}
+	}
+	return moves, nil
+}
+
+// Apply moves files in dir and updates its manifest, recording the layout
+// there. The moves are journaled first, so an interrupted run is completed by
+// Finish. Every file is moved into a staging directory before any is moved to
+// its destination, so moves may swap or chain paths safely.
+func Apply(dir, layout string, moves []Move) error {
+	if _, err := Pending(dir); err == nil {
+		return errors.New("an earlier reorganization was interrupted; finish it first")
+	}
+	from := make(map[string]bool, len(moves))
+	for _, m := range moves {
+		from[m.From] = true
+	}
+	for _, m := range moves {
+		if from[m.To] {
+			continue
+		}
+		if _, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(m.To))); err == nil {
+			return fmt.Errorf("cannot move %s: %s already exists", m.From, m.To)
+		}
+	}
+
+	man, err := manifest.Load(dir)
+	if err != nil {
+		return err
+	}
+	j := &Journal{Layout: layout, Moves: moves, Manifest: relocate(man.Entries(), moves)}
+	if err := j.save(dir); err != nil {
+		return err
+	}
+	return j.Finish(dir)
+}
+
+// Pending returns the journal of an interrupted reorganization in dir, or an
+// error satisfying os.IsNotExist if there is none
+func Pending(dir string) (*Journal, error) {
+	data, err := os.ReadFile(journalPath(dir)) //nolint:gosec // Path is inside the user's download directory
+	if err != nil {
+		return nil, err
+	}
+	var j Journal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", journalName, err)
+	}
+	return &j, nil
+}
+
+// Finish carries out the journaled moves that haven't happened yet, updates
+// the manifest, and removes the journal and directories left empty. It is
+// safe to call again after an interruption.
+func (j *Journal) Finish(dir string) error {
+	staging := filepath.Join(dir, filepath.FromSlash(cleanup.StateDir), stagingName)
+	if !j.Staged {
+		if err := os.MkdirAll(staging, 0755); err != nil { //nolint:gosec // 0755 is appropriate for download directories
+			return fmt.Errorf("failed to create staging directory: %w", err)
+		}
+		for i, m := range j.Moves {
+			src := filepath.Join(dir, filepath.FromSlash(m.From))
+			staged := filepath.Join(staging, strconv.Itoa(i))
+			if err := stage(src, staged); err != nil {
+				return err
+			}
+			if err := stage(sidecar.Path(src), sidecar.Path(staged)); err != nil {
+				return err
+			}
+		}
+		j.Staged = true
+		if err := j.save(dir); err != nil {
+			return err
+		}
+	}
+
+	for i, m := range j.Moves {
+		staged := filepath.Join(staging, strconv.Itoa(i))
+		dst := filepath.Join(dir, filepath.FromSlash(m.To))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil { //nolint:gosec // 0755 is appropriate for download directories
+			return fmt.Errorf("failed to move %s: %w", m.From, err)
+		}
+		if err := stage(staged, dst); err != nil {
+			return err
+		}
+		if err := stage(sidecar.Path(staged), sidecar.Path(dst)); err != nil {
+			return err
+		}
+	}
+
+	man, err := manifest.Load(dir)
+	if err != nil {
+		return err
+	}
+	man.Replace(j.Manifest)
+	man.Layout = j.Layout
+	if j.Layout == Flat {
+		man.Layout = ""
+	}
+	if err := man.Save(); err != nil {
+		return err
+	}
+
+	if err := os.Remove(journalPath(dir)); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", journalName, err)
+	}
+	_ = os.RemoveAll(staging)
+	for _, m := range j.Moves {
+		removeEmptyParents(dir, m.From)
+	}
+	return nil
+}
+
+// relocate returns manifest entries with moved files at their new paths. An
+// entry left at a path another file moves to is dropped.
+func relocate(entries []manifest.Entry, moves []Move) []manifest.Entry {
+	to := make(map[string]string, len(moves))
+	taken := make(map[string]bool, len(moves))
+	for _, m := range moves {
+		to[m.From] = m.To
+		taken[m.To] = true
+	}
+	var out []manifest.Entry
+	for _, e := range entries {
+		if dst, ok := to[e.Path]; ok {
+			e.Path = dst
+		} else if taken[e.Path] {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// stage renames src to dst unless src is already gone, which means an earlier
+// attempt moved it
+func stage(src, dst string) error {
+	if _, err := os.Lstat(src); os.IsNotExist(err) {
+		return nil
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("cannot move %s: %s already exists", src, dst)
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return fmt.Errorf("failed to move %s: %w", src, err)
+	}
+	return nil
+}
+
+// removeEmptyParents removes the directories above a moved file that it left
+// empty, stopping at dir
+func removeEmptyParents(dir, rel string) {
+	for p := path.Dir(rel); p != "." && p != "/"; p = path.Dir(p) {
+		if os.Remove(filepath.Join(dir, filepath.FromSlash(p))) != nil {
+			return
+		}
+	}
+}
+
+func journalPath(dir string) string {
+	return filepath.Join(dir, filepath.FromSlash(cleanup.StateDir), journalName)
+}
+
+// save writes the journal atomically
+func (j *Journal) save(dir string) error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	p := journalPath(dir)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil { //nolint:gosec // 0755 is appropriate for download directories
+		return fmt.Errorf("failed to write %s: %w", journalName, err)
+	}
+	tmp := p + cleanup.TempSuffix
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil { //nolint:gosec // State files are readable like the downloads
+		return fmt.Errorf("failed to write %s: %w", journalName, err)
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %w", journalName, err)
+	}
+	return nil
+}
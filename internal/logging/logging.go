@@ -0,0 +1,70 @@
+// Package logging writes the structured log file of --log-file: requests,
+// retries, skips, and failures with timestamps, as JSON lines or logfmt,
+// independent of what the console shows.
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Format is how log records are written
+type Format string
+
+// Supported formats
+const (
+	FormatJSON   Format = "json"   // One JSON object per line
+	FormatLogfmt Format = "logfmt" // key=value pairs, one record per line
+)
+
+// ParseFormat parses a --log-format value
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatJSON, FormatLogfmt:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown log format %q (expected json or logfmt)", s)
+	}
+}
+
+// ParseLevel parses a --log-level value: debug logs every request, info each
+// file's outcome, warn retries and discarded files, and error failures only
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q (expected debug, info, warn, or error)", s)
+	}
+	return level, nil
+}
+
+// File is a log file open for appending
+type File struct {
+	*slog.Logger
+	file *os.File
+}
+
+// Open opens the log file at path for appending, creating it if needed
+func Open(path string, level slog.Level, format Format) (*File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil { //nolint:gosec // Log directory permissions
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644) //nolint:gosec // Path is provided by the user
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler = slog.NewJSONHandler(file, opts)
+	if format == FormatLogfmt {
+		handler = slog.NewTextHandler(file, opts)
+	}
+	return &File{Logger: slog.New(handler), file: file}, nil
+}
+
+// Close closes the underlying file
+func (f *File) Close() error {
+	return f.file.Close()
+}
@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    slog.Level
+		wantErr bool
+	}{
+		{"debug", slog.LevelDebug, false},
+		{"INFO", slog.LevelInfo, false},
+		{"warn", slog.LevelWarn, false},
+		{"error", slog.LevelError, false},
+		{"loud", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLevel(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v, expected %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, err := ParseFormat("JSON"); err != nil || f != FormatJSON {
+		t.Errorf("expected json, got %q (%v)", f, err)
+	}
+	if f, err := ParseFormat("logfmt"); err != nil || f != FormatLogfmt {
+		t.Errorf("expected logfmt, got %q (%v)", f, err)
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		format Format
+		check  func(t *testing.T, line string)
+	}{
+		{FormatJSON, func(t *testing.T, line string) {
+			var record map[string]any
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Fatalf("expected a JSON line, got %q: %v", line, err)
+			}
+			if record["msg"] != "failed" || record["file"] != "Game (USA).zip" || record["time"] == nil {
+				t.Errorf("unexpected record %v", record)
+			}
+		}},
+		{FormatLogfmt, func(t *testing.T, line string) {
+			if !strings.Contains(line, "level=ERROR msg=failed") || !strings.Contains(line, `file="Game (USA).zip"`) {
+				t.Errorf("unexpected record %q", line)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			path := filepath.Join(dir, "logs", string(tt.format)+".log")
+			log, err := Open(path, slog.LevelWarn, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			log.Info("completed", "file", "Other (USA).zip") // Below the level
+			log.Error("failed", "file", "Game (USA).zip")
+			if err := log.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+			if len(lines) != 1 {
+				t.Fatalf("expected 1 record at warn and above, got %q", data)
+			}
+			tt.check(t, lines[0])
+		})
+	}
+}
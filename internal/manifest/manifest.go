@@ -0,0 +1,174 @@
+// Package manifest records the files in a download directory, where they came
+// from, and their hashes, in .myrient-dl.json next to the files.
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/cleanup"
+	"github.com/nchapman/myrient-dl/internal/hashing"
+)
+
+// FileName is the manifest's name inside a download directory
+const FileName = ".myrient-dl.json"
+
+// FormatVersion is the current manifest format
+const FormatVersion = 1
+
+// Entry describes one file in the download directory
+type Entry struct {
+	Path        string            `json:"path"` // Slash-separated, relative to the directory
+	URL         string            `json:"url,omitempty"`
+	Size        int64             `json:"size"`
+	Algorithm   hashing.Algorithm `json:"algorithm,omitempty"`
+	Hash        string            `json:"hash,omitempty"`
+	CompletedAt time.Time         `json:"completed_at"`
+	Imported    bool              `json:"imported,omitempty"` // Found on disk by import rather than downloaded
+	// Extracted lists the files unpacked from the archive by --extract, slash-
+	// separated and relative to the directory unless they were put elsewhere
+	Extracted      []string `json:"extracted,omitempty"`
+	ArchiveDeleted bool     `json:"archive_deleted,omitempty"` // Removed after extraction
+}
+
+// Manifest is the set of recorded files in a download directory. It is safe
+// for concurrent use.
+type Manifest struct {
+	Version int     `json:"version"`
+	Source  string  `json:"source,omitempty"` // Listing the directory was last filled from
+	Layout  string  `json:"layout,omitempty"` // Path template set by reorganize, applied to later downloads
+	Files   []Entry `json:"files"`
+
+	mu    sync.Mutex
+	dir   string
+	index map[string]int
+}
+
+// Path returns the manifest location for a download directory
+func Path(dir string) string {
+	return filepath.Join(dir, FileName)
+}
+
+// Load reads the manifest of a download directory, returning an empty one if
+// there is none yet
+func Load(dir string) (*Manifest, error) {
+	m := &Manifest{Version: FormatVersion, dir: dir}
+	data, err := os.ReadFile(Path(dir)) //nolint:gosec // Path is derived from the user's output directory
+	if os.IsNotExist(err) {
+		m.buildIndex()
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if m.Version > FormatVersion {
+		return nil, fmt.Errorf("manifest format %d is newer than this version of myrient-dl supports", m.Version)
+	}
+	m.buildIndex()
+	return m, nil
+}
+
+func (m *Manifest) buildIndex() {
+	m.index = make(map[string]int, len(m.Files))
+	for i, e := range m.Files {
+		m.index[e.Path] = i
+	}
+}
+
+// Get returns the entry recorded for a relative path
+func (m *Manifest) Get(path string) (Entry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i, ok := m.index[filepath.ToSlash(path)]
+	if !ok {
+		return Entry{}, false
+	}
+	return m.Files[i], true
+}
+
+// Put records an entry, replacing any entry for the same path
+func (m *Manifest) Put(e Entry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e.Path = filepath.ToSlash(e.Path)
+	if i, ok := m.index[e.Path]; ok {
+		m.Files[i] = e
+		return
+	}
+	m.index[e.Path] = len(m.Files)
+	m.Files = append(m.Files, e)
+}
+
+// Remove drops the entry recorded for a relative path, reporting whether
+// there was one
+func (m *Manifest) Remove(path string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i, ok := m.index[filepath.ToSlash(path)]
+	if !ok {
+		return false
+	}
+	m.Files = append(m.Files[:i], m.Files[i+1:]...)
+	m.buildIndex()
+	return true
+}
+
+// Replace swaps every recorded entry for the given ones
+func (m *Manifest) Replace(entries []Entry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Files = make([]Entry, len(entries))
+	copy(m.Files, entries)
+	m.buildIndex()
+}
+
+// Entries returns a copy of the recorded entries sorted by path
+func (m *Manifest) Entries() []Entry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entries := make([]Entry, len(m.Files))
+	copy(entries, m.Files)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries
+}
+
+// Len returns the number of recorded entries
+func (m *Manifest) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.Files)
+}
+
+// Save writes the manifest atomically, sorted by path
+func (m *Manifest) Save() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+	m.buildIndex()
+	m.Version = FormatVersion
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := Path(m.dir)
+	tempPath := path + cleanup.TempSuffix
+	if err := os.WriteFile(tempPath, append(data, '\n'), 0644); err != nil { //nolint:gosec // The manifest is meant to be read by other tools
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
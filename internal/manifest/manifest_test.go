@@ -0,0 +1,122 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/hashing"
+)
+
+func TestManifest_SaveLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	m, err := Load(dir)
+	if err != nil {
+		t.Fatalf("expected an empty manifest for a new directory, got %v", err)
+	}
+	if m.Len() != 0 {
+		t.Fatalf("expected no entries, got %d", m.Len())
+	}
+
+	done := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	m.Source = "https://example.com/files/"
+	m.Put(Entry{Path: "zelda.zip", URL: "https://example.com/files/zelda.zip", Size: 3000, CompletedAt: done})
+	m.Put(Entry{Path: filepath.Join("sub", "mario.zip"), Size: 1000, Algorithm: hashing.SHA1, Hash: "abc", Imported: true})
+	m.Put(Entry{Path: "zelda.zip", URL: "https://example.com/files/zelda.zip", Size: 3100, CompletedAt: done,
+		Extracted: []string{"zelda.nes"}, ArchiveDeleted: true})
+	if err := m.Save(); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	if _, err := os.Stat(Path(dir) + ".tmp"); !os.IsNotExist(err) {
+		t.Error("expected temp file to be renamed away")
+	}
+
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+	if loaded.Source != m.Source || loaded.Len() != 2 {
+		t.Fatalf("unexpected manifest %+v", loaded)
+	}
+	entries := loaded.Entries()
+	if entries[0].Path != "sub/mario.zip" || entries[1].Path != "zelda.zip" {
+		t.Errorf("expected entries sorted by slash-separated path, got %s and %s", entries[0].Path, entries[1].Path)
+	}
+	if e, ok := loaded.Get("zelda.zip"); !ok || e.Size != 3100 || !e.CompletedAt.Equal(done) ||
+		len(e.Extracted) != 1 || e.Extracted[0] != "zelda.nes" || !e.ArchiveDeleted {
+		t.Errorf("expected the replaced entry, got %+v", e)
+	}
+	if e, ok := loaded.Get(filepath.Join("sub", "mario.zip")); !ok || e.Hash != "abc" || !e.Imported {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if _, ok := loaded.Get("missing.zip"); ok {
+		t.Error("expected no entry for an unknown path")
+	}
+}
+
+func TestManifest_Replace(t *testing.T) {
+	m, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Put(Entry{Path: "a.zip", Size: 1})
+	m.Put(Entry{Path: "b.zip", Size: 2})
+
+	m.Replace([]Entry{{Path: "A/a.zip", Size: 1}})
+	if _, ok := m.Get("a.zip"); ok {
+		t.Error("expected the old entries to be gone")
+	}
+	if e, ok := m.Get("A/a.zip"); !ok || e.Size != 1 {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if m.Len() != 1 {
+		t.Errorf("expected 1 entry, got %d", m.Len())
+	}
+}
+
+func TestManifest_Remove(t *testing.T) {
+	m, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Put(Entry{Path: "a.zip", Size: 1})
+	m.Put(Entry{Path: "sub/b.zip", Size: 2})
+	m.Put(Entry{Path: "c.zip", Size: 3})
+
+	if !m.Remove("sub/b.zip") {
+		t.Error("expected sub/b.zip to be removed")
+	}
+	if m.Remove("sub/b.zip") {
+		t.Error("expected a second remove to find nothing")
+	}
+	if e, ok := m.Get("c.zip"); !ok || e.Size != 3 {
+		t.Errorf("expected c.zip to stay indexed, got %+v", e)
+	}
+	if m.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", m.Len())
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"malformed", "{"},
+		{"newer format", `{"version": 99, "files": []}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(Path(dir), []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Load(dir); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
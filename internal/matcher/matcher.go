@@ -2,31 +2,134 @@
 package matcher
 
 import (
+	"fmt"
+	"path"
 	"path/filepath"
+	"sort"
+	"time"
 
+	"github.com/nchapman/myrient-dl/internal/naming"
 	"github.com/nchapman/myrient-dl/internal/parser"
 )
 
-// Matcher handles include/exclude pattern matching
+// Scope selects what part of a file's name patterns are matched against
+type Scope int
+
+// Matching scopes
+const (
+	ScopeName Scope = iota // The base name, so "*.zip" matches in any directory
+	ScopePath              // The slash-separated path relative to the listing, e.g. "SNES/*.zip"
+)
+
+// ParseScope parses a --match-scope value: name or path
+func ParseScope(value string) (Scope, error) {
+	switch value {
+	case "name":
+		return ScopeName, nil
+	case "path":
+		return ScopePath, nil
+	default:
+		return ScopeName, fmt.Errorf("invalid match scope %q (expected name or path)", value)
+	}
+}
+
+// Matcher handles include/exclude pattern matching. Patterns are compiled
+// once, so filtering six-figure listings doesn't re-parse them per file.
 type Matcher struct {
-	includePatterns []string
-	excludePatterns []string
+	include []pattern
+	exclude []pattern
+	scope   Scope
+
+	minSize, maxSize int64
+	after, before    time.Time
+	without          []naming.Kind
 }
 
-// New creates a new Matcher with the given patterns
+// New creates a new Matcher with the given patterns, matching base names.
+// Invalid patterns never match; check user input with Validate first.
 func New(include, exclude []string) *Matcher {
-	return &Matcher{
-		includePatterns: include,
-		excludePatterns: exclude,
+	m := &Matcher{
+		include: make([]pattern, len(include)),
+		exclude: make([]pattern, 0, len(exclude)),
+	}
+	for i, p := range include {
+		m.include[i] = compile(p)
+	}
+	for _, p := range exclude {
+		if p != "" {
+			m.exclude = append(m.exclude, compile(p))
+		}
+	}
+	return m
+}
+
+// WithScope sets what part of a file's name the patterns are matched against
+func (m *Matcher) WithScope(scope Scope) *Matcher {
+	m.scope = scope
+	return m
+}
+
+// WithSizes only matches files of at least minSize and at most maxSize bytes;
+// zero leaves a bound off. Files of unknown size always pass, so a listing
+// without sizes isn't filtered away entirely.
+func (m *Matcher) WithSizes(minSize, maxSize int64) *Matcher {
+	m.minSize, m.maxSize = minSize, maxSize
+	return m
+}
+
+// WithModTimes only matches files the listing dates at or after after and
+// before before; a zero time leaves a bound off. Files without a date always
+// pass, like files of unknown size.
+func (m *Matcher) WithModTimes(after, before time.Time) *Matcher {
+	m.after, m.before = after, before
+	return m
+}
+
+// WithoutKinds excludes files whose name tags mark them as any of kinds, e.g.
+// betas and prototypes. Tags are read from the base name whatever the scope.
+func (m *Matcher) WithoutKinds(kinds []naming.Kind) *Matcher {
+	m.without = kinds
+	return m
+}
+
+// WithRegexps adds regular expression include and exclude patterns, matched
+// anywhere in the same part of the name as the globs unless anchored with ^ and
+// $. A name is included if it matches any glob or expression. An invalid
+// expression is an error.
+func (m *Matcher) WithRegexps(include, exclude []string) (*Matcher, error) {
+	for _, expr := range include {
+		p, err := compileRegexp(expr)
+		if err != nil {
+			return nil, err
+		}
+		m.include = append(m.include, p)
+	}
+	for _, expr := range exclude {
+		p, err := compileRegexp(expr)
+		if err != nil {
+			return nil, err
+		}
+		m.exclude = append(m.exclude, p)
 	}
+	return m, nil
 }
 
-// Filter applies include/exclude patterns to a list of files
+// subject returns the part of a file name that patterns are matched against
+func (m *Matcher) subject(name string) string {
+	name = filepath.ToSlash(name)
+	if m.scope == ScopeName {
+		return path.Base(name)
+	}
+	return name
+}
+
+// Filter applies include/exclude patterns, excluded kinds, and size and date
+// bounds to a list of files
 func (m *Matcher) Filter(files []parser.FileInfo) []parser.FileInfo {
 	var filtered []parser.FileInfo
 
 	for _, file := range files {
-		if m.matches(file.Name) {
+		if m.Match(file) {
 			filtered = append(filtered, file)
 		}
 	}
@@ -34,43 +137,118 @@ func (m *Matcher) Filter(files []parser.FileInfo) []parser.FileInfo {
 	return filtered
 }
 
+// Match reports whether a single file passes the include/exclude patterns,
+// excluded kinds, and size and date bounds, for filtering a listing as it
+// streams in
+func (m *Matcher) Match(file parser.FileInfo) bool {
+	return m.sized(file.Size) && m.dated(file.ModTime) && m.wanted(file.Name) && m.matches(m.subject(file.Name))
+}
+
+// wanted checks a file's name tags against the excluded kinds
+func (m *Matcher) wanted(name string) bool {
+	base := path.Base(filepath.ToSlash(name))
+	for _, k := range m.without {
+		if k.Is(base) {
+			return false
+		}
+	}
+	return true
+}
+
+// dated checks a file's listing date against the date bounds
+func (m *Matcher) dated(modTime time.Time) bool {
+	if modTime.IsZero() {
+		return true
+	}
+	return (m.after.IsZero() || !modTime.Before(m.after)) && (m.before.IsZero() || modTime.Before(m.before))
+}
+
+// sized checks a file's size against the size bounds
+func (m *Matcher) sized(size int64) bool {
+	if size <= 0 {
+		return true
+	}
+	return (m.minSize == 0 || size >= m.minSize) && (m.maxSize == 0 || size <= m.maxSize)
+}
+
 // matches checks if a filename matches the include/exclude criteria
 func (m *Matcher) matches(filename string) bool {
 	// Check include patterns (OR logic - must match at least one)
-	if len(m.includePatterns) > 0 {
-		matchedAny := false
-		for _, pattern := range m.includePatterns {
-			if pattern == "" || pattern == "*" {
-				matchedAny = true
-				break
-			}
-			matched, err := filepath.Match(pattern, filename)
-			if err != nil {
-				continue // Skip invalid patterns
-			}
-			if matched {
-				matchedAny = true
-				break
-			}
-		}
-		if !matchedAny {
-			return false
-		}
+	if len(m.include) > 0 && m.firstInclude(filename) == len(m.include) {
+		return false
 	}
 
 	// Check exclude patterns (OR logic - excluded if matches any)
-	for _, pattern := range m.excludePatterns {
-		if pattern == "" {
-			continue
+	for _, p := range m.exclude {
+		if p.match(filename) {
+			return false
 		}
-		matched, err := filepath.Match(pattern, filename)
-		if err != nil {
-			continue // Skip invalid patterns
+	}
+
+	return true
+}
+
+// firstInclude returns the index of the first include pattern matching
+// filename, or len(m.include) if none does
+func (m *Matcher) firstInclude(filename string) int {
+	for i, p := range m.include {
+		if p.match(filename) {
+			return i
 		}
-		if matched {
-			return false // Exclude if any pattern matches
+	}
+	return len(m.include)
+}
+
+// Priority returns the index of the first include pattern that matches filename,
+// so lower values mean higher priority. Names matching no include pattern rank last.
+func (m *Matcher) Priority(filename string) int {
+	return m.firstInclude(m.subject(filename))
+}
+
+// Prioritize orders files by the include pattern they matched, keeping listing
+// order among files of equal priority
+func (m *Matcher) Prioritize(files []parser.FileInfo) []parser.FileInfo {
+	// Rank each file once rather than on every comparison
+	ranked := make([]struct {
+		file     parser.FileInfo
+		priority int
+	}, len(files))
+	for i, f := range files {
+		ranked[i].file, ranked[i].priority = f, m.Priority(f.Name)
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].priority < ranked[j].priority
+	})
+
+	sorted := make([]parser.FileInfo, len(files))
+	for i, r := range ranked {
+		sorted[i] = r.file
+	}
+	return sorted
+}
+
+// Budget keeps files in order until maxFiles or maxBytes would be exceeded and
+// returns the kept files along with the rest. Zero disables a limit.
+func Budget(files []parser.FileInfo, maxFiles int, maxBytes int64) (kept, dropped []parser.FileInfo) {
+	var total int64
+	for i, f := range files {
+		if (maxFiles > 0 && i >= maxFiles) || (maxBytes > 0 && total+f.Size > maxBytes) {
+			return files[:i], files[i:]
 		}
+		total += f.Size
 	}
+	return files, nil
+}
 
-	return true
+// SplitOver separates files larger than maxBytes from the rest, keeping their order.
+// Files of unknown size are never considered too large.
+func SplitOver(files []parser.FileInfo, maxBytes int64) (within, over []parser.FileInfo) {
+	for _, f := range files {
+		if f.Size > maxBytes {
+			over = append(over, f)
+		} else {
+			within = append(within, f)
+		}
+	}
+	return within, over
 }
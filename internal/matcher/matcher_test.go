@@ -1,8 +1,15 @@
 package matcher
 
 import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/nchapman/myrient-dl/internal/naming"
 	"github.com/nchapman/myrient-dl/internal/parser"
 )
 
@@ -223,3 +230,394 @@ func TestMatcher_FilesWithCommas(t *testing.T) {
 		})
 	}
 }
+
+func TestMatcher_Prioritize(t *testing.T) {
+	files := []parser.FileInfo{
+		{Name: "a.zip", Size: 100},
+		{Name: "zelda.zip", Size: 200},
+		{Name: "b.zip", Size: 300},
+		{Name: "mario.zip", Size: 400},
+	}
+
+	m := New([]string{"mario*", "zelda*", "*.zip"}, nil)
+	result := m.Prioritize(files)
+
+	expected := []string{"mario.zip", "zelda.zip", "a.zip", "b.zip"}
+	for i, name := range expected {
+		if result[i].Name != name {
+			t.Errorf("position %d: expected %s, got %s", i, name, result[i].Name)
+		}
+	}
+	if files[0].Name != "a.zip" {
+		t.Error("expected input slice to be left untouched")
+	}
+}
+
+func TestMatcher_Scope(t *testing.T) {
+	files := []parser.FileInfo{
+		{Name: "SNES/mario.zip"},
+		{Name: "SNES/docs/manual.txt"},
+		{Name: "NES/mario.zip"},
+		{Name: "readme.txt"},
+	}
+
+	tests := []struct {
+		name     string
+		scope    Scope
+		include  []string
+		exclude  []string
+		expected []string
+	}{
+		{"name scope ignores directories", ScopeName, []string{"*.zip"}, nil, []string{"SNES/mario.zip", "NES/mario.zip"}},
+		{"name scope cannot see directories", ScopeName, []string{"SNES/*"}, nil, nil},
+		{"path scope matches one directory", ScopePath, []string{"SNES/*.zip"}, nil, []string{"SNES/mario.zip"}},
+		{"path scope star stops at slashes", ScopePath, []string{"*.txt"}, nil, []string{"readme.txt"}},
+		{"path scope excludes a subtree", ScopePath, []string{"*", "*/*", "*/*/*"}, []string{"SNES/docs/*"}, []string{"SNES/mario.zip", "NES/mario.zip", "readme.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, f := range New(tt.include, tt.exclude).WithScope(tt.scope).Filter(files) {
+				got = append(got, f.Name)
+			}
+			if len(got) != len(tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, got)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("expected %v, got %v", tt.expected, got)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestMatcher_Regexps(t *testing.T) {
+	files := []parser.FileInfo{
+		{Name: "Game (USA) (Rev 2) (En,Fr).zip"},
+		{Name: "Game (USA) (En,Fr,De).zip"},
+		{Name: "Game (Europe) (Rev 1) (En,Fr).zip"},
+		{Name: "Game (USA) (Beta 3).zip"},
+		{Name: "Disc/Game (USA).cue"},
+	}
+
+	tests := []struct {
+		name         string
+		include      []string
+		includeRegex []string
+		excludeRegex []string
+		scope        Scope
+		expected     []string
+	}{
+		{"revision numbers", nil, []string{`\(Rev [2-9]\)`}, nil, ScopeName, []string{"Game (USA) (Rev 2) (En,Fr).zip"}},
+		{"exact language set", nil, []string{`\(En,Fr\)\.zip$`}, nil, ScopeName, []string{"Game (USA) (Rev 2) (En,Fr).zip", "Game (Europe) (Rev 1) (En,Fr).zip"}},
+		{"exclude numbered betas", []string{"*(USA)*"}, nil, []string{`\(Beta \d+\)`}, ScopeName, []string{"Game (USA) (Rev 2) (En,Fr).zip", "Game (USA) (En,Fr,De).zip", "Disc/Game (USA).cue"}},
+		{"globs and expressions combine", []string{"*(Europe)*"}, []string{`De\)`}, nil, ScopeName, []string{"Game (USA) (En,Fr,De).zip", "Game (Europe) (Rev 1) (En,Fr).zip"}},
+		{"anchored in path scope", nil, []string{`^Disc/`}, nil, ScopePath, []string{"Disc/Game (USA).cue"}},
+		{"anchored in name scope", nil, []string{`^Disc/`}, nil, ScopeName, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(tt.include, nil).WithScope(tt.scope).WithRegexps(tt.includeRegex, tt.excludeRegex)
+			if err != nil {
+				t.Fatalf("WithRegexps failed: %v", err)
+			}
+			var got []string
+			for _, f := range m.Filter(files) {
+				got = append(got, f.Name)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestMatcher_WithSizes(t *testing.T) {
+	files := []parser.FileInfo{
+		{Name: "stub.zip", Size: 512},
+		{Name: "game.zip", Size: 2 << 20},
+		{Name: "disc.iso", Size: 5 << 30},
+		{Name: "unsized.zip"},
+		{Name: "edge.zip", Size: 1 << 20},
+	}
+	tests := []struct {
+		name     string
+		min, max int64
+		want     []string
+	}{
+		{"no bounds", 0, 0, []string{"stub.zip", "game.zip", "disc.iso", "unsized.zip", "edge.zip"}},
+		{"minimum", 1 << 20, 0, []string{"game.zip", "disc.iso", "unsized.zip", "edge.zip"}},
+		{"maximum", 0, 4 << 30, []string{"stub.zip", "game.zip", "unsized.zip", "edge.zip"}},
+		{"both", 1 << 20, 1 << 20, []string{"unsized.zip", "edge.zip"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, f := range New([]string{"*"}, nil).WithSizes(tt.min, tt.max).Filter(files) {
+				got = append(got, f.Name)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcher_WithModTimes(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, time.January, d, 0, 0, 0, 0, time.UTC) }
+	files := []parser.FileInfo{
+		{Name: "old.zip", ModTime: day(1)},
+		{Name: "new.zip", ModTime: day(20)},
+		{Name: "undated.zip"},
+	}
+	tests := []struct {
+		name          string
+		after, before time.Time
+		want          []string
+	}{
+		{"no bounds", time.Time{}, time.Time{}, []string{"old.zip", "new.zip", "undated.zip"}},
+		{"newer than", day(20), time.Time{}, []string{"new.zip", "undated.zip"}},
+		{"older than", time.Time{}, day(20), []string{"old.zip", "undated.zip"}},
+		{"between", day(2), day(19), []string{"undated.zip"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, f := range New([]string{"*"}, nil).WithModTimes(tt.after, tt.before).Filter(files) {
+				got = append(got, f.Name)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcher_WithoutKinds(t *testing.T) {
+	files := []parser.FileInfo{
+		{Name: "Game (USA).zip"},
+		{Name: "Game (USA) (Beta).zip"},
+		{Name: "Proto/Game (Japan) (Proto).zip"},
+		{Name: "Beta/Game (Europe) (Demo).zip"},
+	}
+	tests := []struct {
+		name  string
+		kinds []naming.Kind
+		want  []string
+	}{
+		{"none", nil, []string{"Game (USA).zip", "Game (USA) (Beta).zip", "Proto/Game (Japan) (Proto).zip", "Beta/Game (Europe) (Demo).zip"}},
+		{"beta", []naming.Kind{naming.Beta}, []string{"Game (USA).zip", "Proto/Game (Japan) (Proto).zip", "Beta/Game (Europe) (Demo).zip"}},
+		{"proto and demo", []naming.Kind{naming.Proto, naming.Demo}, []string{"Game (USA).zip", "Game (USA) (Beta).zip"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, f := range New([]string{"*"}, nil).WithScope(ScopePath).WithoutKinds(tt.kinds).Filter(files) {
+				got = append(got, f.Name)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcher_InvalidRegexp(t *testing.T) {
+	for _, tt := range []struct{ include, exclude []string }{
+		{[]string{`(Rev [0-9]`}, nil},
+		{nil, []string{`\(Beta\`}},
+	} {
+		if _, err := New(nil, nil).WithRegexps(tt.include, tt.exclude); err == nil {
+			t.Errorf("expected an error for %q %q", tt.include, tt.exclude)
+		}
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"*.zip", []string{"*.zip"}},
+		{"*.zip,*.7z", []string{"*.zip", "*.7z"}},
+		{"*(USA)*, *(Europe)* ,", []string{"*(USA)*", "*(Europe)*"}},
+		{"*(En,Fr,De)*", []string{"*(En,Fr,De)*"}},
+		{"*[,_]*,*.7z", []string{"*[,_]*", "*.7z"}},
+		{`3\, 2\, 1*,*En\,Fr*`, []string{"3, 2, 1*", "*En,Fr*"}},
+		{`*\(Beta*,*.zip`, []string{`*\(Beta*`, "*.zip"}},
+		{"", nil},
+		{" , ", nil},
+	}
+
+	for _, tt := range tests {
+		got := SplitList(tt.value)
+		if len(got) != len(tt.want) {
+			t.Errorf("SplitList(%q) = %q, expected %q", tt.value, got, tt.want)
+			continue
+		}
+		for i := range tt.want {
+			if got[i] != tt.want[i] {
+				t.Errorf("SplitList(%q) = %q, expected %q", tt.value, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate("--include", []string{"*", "*(USA)*", "[a-c]*.zip", ""}); err != nil {
+		t.Errorf("expected valid globs to pass, got %v", err)
+	}
+	if err := ValidateRegexps("--include-regex", []string{`\(Rev [0-9]\)`}); err != nil {
+		t.Errorf("expected a valid expression to pass, got %v", err)
+	}
+
+	err := Validate("--exclude", []string{"*(Beta)*", "[Beta", "*\\"})
+	var perr *PatternError
+	if !errors.As(err, &perr) || perr.Flag != "--exclude" || perr.Pattern != "[Beta" {
+		t.Fatalf("expected a PatternError for [Beta, got %v", err)
+	}
+	if !errors.Is(err, filepath.ErrBadPattern) {
+		t.Errorf("expected the error to wrap ErrBadPattern, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `invalid --exclude pattern "[Beta"`) || !strings.Contains(err.Error(), `"*\\"`) {
+		t.Errorf("expected both malformed patterns with the flag, got %q", err)
+	}
+
+	err = ValidateRegexps("--exclude-regex", []string{`(Rev`})
+	if !errors.As(err, &perr) || perr.Flag != "--exclude-regex" || perr.Pattern != "(Rev" {
+		t.Errorf("expected a PatternError for (Rev, got %v", err)
+	}
+}
+
+func TestParseScope(t *testing.T) {
+	for value, expected := range map[string]Scope{"name": ScopeName, "path": ScopePath} {
+		if scope, err := ParseScope(value); err != nil || scope != expected {
+			t.Errorf("ParseScope(%q) = %v, %v", value, scope, err)
+		}
+	}
+	if _, err := ParseScope("basename"); err == nil {
+		t.Error("expected error for unknown scope")
+	}
+}
+
+func TestBudget(t *testing.T) {
+	files := []parser.FileInfo{
+		{Name: "a.zip", Size: 100},
+		{Name: "b.zip", Size: 200},
+		{Name: "c.zip", Size: 300},
+	}
+
+	tests := []struct {
+		name     string
+		maxFiles int
+		maxBytes int64
+		kept     int
+	}{
+		{"no limits", 0, 0, 3},
+		{"file limit", 2, 0, 2},
+		{"byte budget", 0, 350, 2},
+		{"budget stops at first file that doesn't fit", 0, 50, 0},
+		{"tighter limit wins", 1, 1000, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, dropped := Budget(files, tt.maxFiles, tt.maxBytes)
+			if len(kept) != tt.kept || len(kept)+len(dropped) != len(files) {
+				t.Errorf("expected %d kept of %d, got %d kept and %d dropped", tt.kept, len(files), len(kept), len(dropped))
+			}
+		})
+	}
+}
+
+func TestSplitOver(t *testing.T) {
+	files := []parser.FileInfo{
+		{Name: "small.zip", Size: 100},
+		{Name: "huge.zip", Size: 5000},
+		{Name: "unknown.zip", Size: 0},
+		{Name: "exact.zip", Size: 1000},
+		{Name: "big.zip", Size: 1001},
+	}
+
+	within, over := SplitOver(files, 1000)
+	if len(within) != 3 || within[0].Name != "small.zip" || within[2].Name != "exact.zip" {
+		t.Errorf("unexpected files within the limit: %+v", within)
+	}
+	if len(over) != 2 || over[0].Name != "huge.zip" || over[1].Name != "big.zip" {
+		t.Errorf("unexpected files over the limit: %+v", over)
+	}
+}
+
+func TestCompile_AgreesWithGlob(t *testing.T) {
+	patterns := []string{
+		"*(USA)*", "*.zip", "Sonic*", "Sonic (USA).zip", "SNES/*", "*/Sonic*", "*SNES/Sonic*",
+		"*(USA)*(Rev ?)*", "[A-C]*", `\*literal*`, "**", "*", "",
+	}
+	names := []string{
+		"Sonic (USA).zip", "Sonic (Europe).zip", "Sonic (USA) (Rev 1).zip", "Alpha (USA).7z",
+		"SNES/Sonic (USA).zip", "Genesis/SNES/Sonic (USA).zip", "SNES/Sub/Sonic (USA).zip",
+		"(USA)", "*literal.zip", "", "Sonic",
+	}
+
+	for _, p := range patterns {
+		compiled := compile(p)
+		for _, name := range names {
+			want, err := filepath.Match(p, name)
+			if err != nil || p == "" || p == "*" {
+				want = p == "" || p == "*" // Both mean "everything" to the matcher, even across directories
+			}
+			if got := compiled.match(name); got != want {
+				t.Errorf("pattern %q on %q: compiled match %v, filepath.Match %v", p, name, got, want)
+			}
+		}
+	}
+}
+
+// listing returns n file names spread across regions, revisions, and systems,
+// the size of the largest Myrient directories
+func listing(n int) []parser.FileInfo {
+	regions := []string{"USA", "Europe", "Japan", "World", "USA, Europe", "Korea"}
+	files := make([]parser.FileInfo, n)
+	for i := range files {
+		name := fmt.Sprintf("Title %d (%s)", i, regions[i%len(regions)])
+		if i%7 == 0 {
+			name += " (Rev 1)"
+		}
+		if i%11 == 0 {
+			name += " (Beta)"
+		}
+		files[i] = parser.FileInfo{Name: fmt.Sprintf("System %d/%s.zip", i%5, name), Size: int64(i)}
+	}
+	return files
+}
+
+func BenchmarkMatcher_Filter(b *testing.B) {
+	files := listing(100_000)
+	m := New([]string{"*(USA)*", "*(Europe)*", "*(World)*"}, []string{"*(Beta)*", "*(Rev ?)*", "*[[]b]*"})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Filter(files)
+	}
+}
+
+func BenchmarkMatcher_FilterPath(b *testing.B) {
+	files := listing(100_000)
+	m := New([]string{"System 1/*", "System 3/*(USA)*"}, nil).WithScope(ScopePath)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Filter(files)
+	}
+}
+
+func BenchmarkMatcher_Prioritize(b *testing.B) {
+	files := listing(100_000)
+	m := New([]string{"*(World)*", "*(USA)*", "*(Europe)*", "*"}, nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Prioritize(files)
+	}
+}
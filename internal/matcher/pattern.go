@@ -0,0 +1,180 @@
+package matcher
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// patternKind is how a compiled pattern is matched
+type patternKind int
+
+const (
+	kindAll      patternKind = iota // "" or "*": everything
+	kindLiteral                     // No wildcards: equality
+	kindPrefix                      // "abc*"
+	kindSuffix                      // "*abc"
+	kindContains                    // "*abc*"
+	kindGlob                        // Anything else: filepath.Match
+	kindInvalid                     // Malformed: never matches
+	kindRegexp                      // Regular expression, matched anywhere in the name
+)
+
+// pattern is a glob compiled once. The common shapes used to filter ROM sets,
+// like "*(USA)*" or "*.zip", are matched with plain string operations.
+type pattern struct {
+	kind patternKind
+	text string         // The literal part, or the whole pattern for kindGlob
+	re   *regexp.Regexp // For kindRegexp
+}
+
+// PatternError is a malformed pattern, with the flag it was given to
+type PatternError struct {
+	Flag    string // Such as "--include"
+	Pattern string
+	Err     error
+}
+
+func (e *PatternError) Error() string {
+	return fmt.Sprintf("invalid %s pattern %q: %v", e.Flag, e.Pattern, e.Err)
+}
+
+func (e *PatternError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks the globs given to flag, returning a *PatternError for each
+// malformed one (joined), so a typo fails up front instead of matching nothing
+func Validate(flag string, globs []string) error {
+	var errs []error
+	for _, p := range globs {
+		if _, err := filepath.Match(p, ""); err != nil {
+			errs = append(errs, &PatternError{Flag: flag, Pattern: p, Err: err})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateRegexps checks the regular expressions given to flag like Validate
+func ValidateRegexps(flag string, exprs []string) error {
+	var errs []error
+	for _, expr := range exprs {
+		if _, err := regexp.Compile(expr); err != nil {
+			errs = append(errs, &PatternError{Flag: flag, Pattern: expr, Err: err})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SplitList splits a comma-separated list of patterns, as given to --include
+// and --exclude. Commas inside parentheses or brackets don't split, so
+// "*(En,Fr)*" stays one pattern, and "\," is a literal comma anywhere.
+// Patterns are trimmed of surrounding spaces and empty ones are dropped.
+func SplitList(value string) []string {
+	var patterns []string
+	var b strings.Builder
+	flush := func() {
+		if p := strings.TrimSpace(b.String()); p != "" {
+			patterns = append(patterns, p)
+		}
+		b.Reset()
+	}
+
+	depth, inBracket := 0, false
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '\\' && i+1 < len(value):
+			// An escaped comma loses its backslash; other escapes are the glob's
+			if value[i+1] != ',' {
+				b.WriteByte(c)
+			}
+			b.WriteByte(value[i+1])
+			i++
+			continue
+		case c == '[':
+			inBracket = true
+		case c == ']':
+			inBracket = false
+		case c == '(' && !inBracket:
+			depth++
+		case c == ')' && !inBracket && depth > 0:
+			depth--
+		case c == ',' && depth == 0 && !inBracket:
+			flush()
+			continue
+		}
+		b.WriteByte(c)
+	}
+	flush()
+	return patterns
+}
+
+// compile classifies a glob pattern
+func compile(p string) pattern {
+	if p == "" || p == "*" {
+		return pattern{kind: kindAll}
+	}
+	if _, err := filepath.Match(p, ""); err != nil {
+		return pattern{kind: kindInvalid}
+	}
+
+	inner := strings.TrimPrefix(strings.TrimSuffix(p, "*"), "*")
+	if strings.ContainsAny(inner, `*?[\`) {
+		return pattern{kind: kindGlob, text: p}
+	}
+	leading, trailing := strings.HasPrefix(p, "*"), strings.HasSuffix(p, "*")
+	switch {
+	case leading && trailing:
+		return pattern{kind: kindContains, text: inner}
+	case leading:
+		return pattern{kind: kindSuffix, text: inner}
+	case trailing:
+		return pattern{kind: kindPrefix, text: inner}
+	default:
+		return pattern{kind: kindLiteral, text: p}
+	}
+}
+
+// compileRegexp compiles a regular expression pattern
+func compileRegexp(expr string) (pattern, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return pattern{}, fmt.Errorf("invalid regular expression %q: %w", expr, err)
+	}
+	return pattern{kind: kindRegexp, text: expr, re: re}, nil
+}
+
+// match reports whether name matches the pattern with filepath.Match semantics.
+// A "*" never crosses a separator, so a name matches a wildcard pattern only if
+// all its separators are in the literal part.
+func (p pattern) match(name string) bool {
+	switch p.kind {
+	case kindAll:
+		return true
+	case kindLiteral:
+		return name == p.text
+	case kindPrefix:
+		return strings.HasPrefix(name, p.text) && p.separatorsMatch(name)
+	case kindSuffix:
+		return strings.HasSuffix(name, p.text) && p.separatorsMatch(name)
+	case kindContains:
+		return strings.Contains(name, p.text) && p.separatorsMatch(name)
+	case kindGlob:
+		matched, err := filepath.Match(p.text, name)
+		return err == nil && matched
+	case kindRegexp:
+		return p.re.MatchString(name)
+	default:
+		return false
+	}
+}
+
+// separatorsMatch reports whether name has no separators beyond those in the
+// pattern's literal part
+func (p pattern) separatorsMatch(name string) bool {
+	sep := string(filepath.Separator)
+	return strings.Count(name, sep) == strings.Count(p.text, sep)
+}
@@ -0,0 +1,149 @@
+// Package mirror finds and removes the files of a download directory that its
+// listing no longer has, so the directory can be kept as a mirror.
+package mirror
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nchapman/myrient-dl/internal/cleanup"
+	"github.com/nchapman/myrient-dl/internal/latest"
+	"github.com/nchapman/myrient-dl/internal/manifest"
+	"github.com/nchapman/myrient-dl/internal/sidecar"
+)
+
+// File is a local file the listing doesn't have
+type File struct {
+	Path string // Slash-separated, relative to the directory
+	Size int64
+}
+
+// Extraneous returns the files in dir that aren't at one of the expected
+// paths (slash-separated and relative to dir, compared ignoring case), sorted
+// by path. myrient-dl's own files, sidecars, and latest/ links are never
+// reported, and neither are files in subdirectories unless subdirs is set.
+func Extraneous(dir string, expected []string, subdirs bool) ([]File, error) {
+	keep := make(map[string]bool, len(expected))
+	for _, p := range expected {
+		keep[strings.ToLower(p)] = true
+	}
+
+	var files []File
+	err := filepath.WalkDir(dir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if entry.IsDir() {
+			if rel == cleanup.StateDir || rel == latest.Dir || (rel != "." && !subdirs) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		name := entry.Name()
+		if strings.HasSuffix(name, cleanup.TempSuffix) || strings.HasSuffix(name, cleanup.LockSuffix) ||
+			rel == manifest.FileName || sidecar.IsSidecar(name) || keep[strings.ToLower(rel)] {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			files = append(files, File{Path: rel, Size: info.Size()})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// Recorded returns the paths the manifest in dir records for files still at
+// one of the listed URLs: where each was saved, which differs from its listed
+// name after a mismatch rename or under a server-provided name, and what
+// --extract unpacked from it
+func Recorded(dir string, listedURLs []string) ([]string, error) {
+	man, err := manifest.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	listed := make(map[string]bool, len(listedURLs))
+	for _, u := range listedURLs {
+		listed[u] = true
+	}
+	var paths []string
+	for _, e := range man.Entries() {
+		if e.URL != "" && listed[e.URL] {
+			paths = append(paths, e.Path)
+			paths = append(paths, e.Extracted...)
+		}
+	}
+	return paths, nil
+}
+
+// TotalSize returns the combined size of the files
+func TotalSize(files []File) int64 {
+	var total int64
+	for _, f := range files {
+		total += f.Size
+	}
+	return total
+}
+
+// Remove deletes the files with their sidecars, drops them from the manifest,
+// and removes directories left empty. It returns the number of files removed
+// and the first error encountered.
+func Remove(dir string, files []File) (int, error) {
+	man, err := manifest.Load(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var firstErr error
+	removed, recorded := 0, false
+	for _, f := range files {
+		local := filepath.Join(dir, filepath.FromSlash(f.Path))
+		if err := os.Remove(local); err != nil && !os.IsNotExist(err) {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to remove %s: %w", f.Path, err)
+			}
+			continue
+		}
+		_ = os.Remove(sidecar.Path(local))
+		if man.Remove(f.Path) {
+			recorded = true
+		}
+		removeEmptyParents(dir, f.Path)
+		removed++
+	}
+
+	if recorded {
+		if err := man.Save(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return removed, firstErr
+}
+
+// removeEmptyParents removes the directories above a removed file that it
+// left empty, stopping at dir
+func removeEmptyParents(dir, rel string) {
+	for p := path.Dir(rel); p != "." && p != "/"; p = path.Dir(p) {
+		if os.Remove(filepath.Join(dir, filepath.FromSlash(p))) != nil {
+			return
+		}
+	}
+}
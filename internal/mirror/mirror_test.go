@@ -0,0 +1,154 @@
+package mirror
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nchapman/myrient-dl/internal/manifest"
+)
+
+func writeFile(t *testing.T, dir, rel string, size int) {
+	t.Helper()
+	p := filepath.Join(dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, make([]byte, size), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func setup(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeFile(t, dir, "Kept (USA).zip", 10)
+	writeFile(t, dir, "Gone (USA).zip", 20)
+	writeFile(t, dir, "Gone (USA).zip.meta.json", 5)
+	writeFile(t, dir, "partial.zip.tmp", 30)
+	writeFile(t, dir, "sub/Old (Japan).zip", 40)
+	writeFile(t, dir, "latest/Kept.zip", 10)
+	writeFile(t, dir, ".myrient-dl/queue.json", 50)
+	writeFile(t, dir, manifest.FileName, 60)
+	return dir
+}
+
+func paths(files []File) []string {
+	var got []string
+	for _, f := range files {
+		got = append(got, f.Path)
+	}
+	return got
+}
+
+func TestExtraneous(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected []string
+		subdirs  bool
+		want     []string
+	}{
+		{"top level only", []string{"Kept (USA).zip"}, false, []string{"Gone (USA).zip"}},
+		{"with subdirectories", []string{"Kept (USA).zip"}, true, []string{"Gone (USA).zip", "sub/Old (Japan).zip"}},
+		{"case-insensitive", []string{"kept (usa).ZIP", "Gone (USA).zip", "SUB/old (japan).zip"}, true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := Extraneous(setup(t), tt.expected, tt.subdirs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := paths(files)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("expected %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRecorded(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "Game (USA).zip", 10)
+	writeFile(t, dir, "Game (USA) (2).zip", 12)
+	writeFile(t, dir, "Game (USA) (Rev 1).zip", 14)
+	writeFile(t, dir, "Game (USA).bin", 20)
+	writeFile(t, dir, "Gone (USA) (2).zip", 30)
+
+	man, err := manifest.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A download kept alongside a mismatched file, one saved under the
+	// server's name and unpacked, and one whose URL is no longer listed
+	man.Put(manifest.Entry{Path: "Game (USA) (2).zip", URL: "https://example.com/Game%20(USA).zip", Size: 12})
+	man.Put(manifest.Entry{Path: "Game (USA) (Rev 1).zip", URL: "https://example.com/game-rev1", Size: 14, Extracted: []string{"Game (USA).bin"}})
+	man.Put(manifest.Entry{Path: "Gone (USA) (2).zip", URL: "https://example.com/Gone%20(USA).zip", Size: 30})
+	if err := man.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	recorded, err := Recorded(dir, []string{"https://example.com/Game%20(USA).zip", "https://example.com/game-rev1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := Extraneous(dir, append([]string{"Game (USA).zip"}, recorded...), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := paths(files); len(got) != 1 || got[0] != "Gone (USA) (2).zip" {
+		t.Errorf("expected only the unlisted download to be extraneous, got %v", got)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "Kept (USA).zip", 10)
+	writeFile(t, dir, "Gone (USA).zip", 20)
+	writeFile(t, dir, "Gone (USA).zip.meta.json", 5)
+	writeFile(t, dir, "sub/deep/Old (Japan).zip", 40)
+
+	man, err := manifest.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	man.Put(manifest.Entry{Path: "Kept (USA).zip", Size: 10})
+	man.Put(manifest.Entry{Path: "Gone (USA).zip", Size: 20})
+	if err := man.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	files := []File{{Path: "Gone (USA).zip", Size: 20}, {Path: "sub/deep/Old (Japan).zip", Size: 40}}
+	if TotalSize(files) != 60 {
+		t.Errorf("expected 60 bytes, got %d", TotalSize(files))
+	}
+	removed, err := Remove(dir, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Errorf("expected 2 files removed, got %d", removed)
+	}
+
+	for _, rel := range []string{"Gone (USA).zip", "Gone (USA).zip.meta.json", "sub"} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(rel))); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed", rel)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Kept (USA).zip")); err != nil {
+		t.Errorf("expected the kept file to remain: %v", err)
+	}
+
+	man, err = manifest.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := man.Get("Gone (USA).zip"); ok || man.Len() != 1 {
+		t.Errorf("expected only the kept file in the manifest, got %+v", man.Entries())
+	}
+}
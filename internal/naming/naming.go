@@ -0,0 +1,216 @@
+// Package naming understands No-Intro/Redump style file names such as
+// "Title (Region) (Rev 1) [b].zip".
+package naming
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Title returns the base title of a file name with its extension and all
+// parenthesized and bracketed tags removed
+func Title(name string) string {
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+
+	var b strings.Builder
+	depth := 0
+	for _, r := range name {
+		switch {
+		case r == '(' || r == '[':
+			depth++
+		case (r == ')' || r == ']') && depth > 0:
+			depth--
+		case depth == 0:
+			b.WriteRune(r)
+		}
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// Tags returns the contents of the top-level parenthesized and bracketed groups
+// in a file name, in order, e.g. ["USA", "Rev 1", "b"]
+func Tags(name string) []string {
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+
+	var tags []string
+	var current strings.Builder
+	depth := 0
+	for _, r := range name {
+		switch {
+		case r == '(' || r == '[':
+			if depth > 0 {
+				current.WriteRune(r)
+			}
+			depth++
+		case (r == ')' || r == ']') && depth > 0:
+			depth--
+			if depth == 0 {
+				tags = append(tags, strings.TrimSpace(current.String()))
+				current.Reset()
+			} else {
+				current.WriteRune(r)
+			}
+		case depth > 0:
+			current.WriteRune(r)
+		}
+	}
+
+	return tags
+}
+
+// Labels returns the individual values of a file name's tags, splitting comma
+// separated lists, e.g. "Sonic (USA, Europe) (En,Fr).zip" gives
+// ["USA", "Europe", "En", "Fr"]. Each value appears once.
+func Labels(name string) []string {
+	var labels []string
+	seen := make(map[string]bool)
+	for _, tag := range Tags(name) {
+		for _, label := range strings.Split(tag, ",") {
+			label = strings.TrimSpace(label)
+			if label != "" && !seen[label] {
+				seen[label] = true
+				labels = append(labels, label)
+			}
+		}
+	}
+	return labels
+}
+
+// TitleKey returns a case-insensitive key for grouping files by title
+func TitleKey(name string) string {
+	return strings.ToLower(Title(name))
+}
+
+// Revision returns the revision of a file name as comparable numbers: "Rev 2"
+// gives [2], "Rev B" gives [2], "v1.1" gives [1 1]. Unrevised names give nil.
+func Revision(name string) []int {
+	for _, tag := range Tags(name) {
+		if rev := tagRevision(tag); rev != nil {
+			return rev
+		}
+	}
+	return nil
+}
+
+// tagRevision parses a single "Rev 1", "Rev A", or "v1.1" tag
+func tagRevision(tag string) []int {
+	lower := strings.ToLower(tag)
+	var rev string
+	switch {
+	case strings.HasPrefix(lower, "rev "):
+		rev = strings.TrimSpace(lower[len("rev "):])
+	case len(lower) > 1 && lower[0] == 'v' && lower[1] >= '0' && lower[1] <= '9':
+		rev = lower[1:]
+	default:
+		return nil
+	}
+	if len(rev) == 1 && rev[0] >= 'a' && rev[0] <= 'z' {
+		return []int{int(rev[0]-'a') + 1}
+	}
+
+	var parts []int
+	for _, field := range strings.Split(rev, ".") {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return nil
+		}
+		parts = append(parts, n)
+	}
+	return parts
+}
+
+// CompareRevisions orders two file names of the same title by revision,
+// returning -1, 0, or 1 as a is older than, the same as, or newer than b
+func CompareRevisions(a, b string) int {
+	ra, rb := Revision(a), Revision(b)
+	for i := 0; i < len(ra) || i < len(rb); i++ {
+		var x, y int
+		if i < len(ra) {
+			x = ra[i]
+		}
+		if i < len(rb) {
+			y = rb[i]
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+// VariantKey returns a case-insensitive key shared by the revisions of one
+// release: the title and its tags except the revision, so "Sonic (USA)" and
+// "Sonic (USA) (Rev 1)" match but "Sonic (Europe)" doesn't
+func VariantKey(name string) string {
+	parts := []string{TitleKey(name)}
+	for _, tag := range Tags(name) {
+		if tagRevision(tag) == nil {
+			parts = append(parts, strings.ToLower(tag))
+		}
+	}
+	return strings.Join(parts, "|")
+}
+
+// Metadata is everything derived from a file name's title and tags, parsed once
+// so large listings can be grouped and summarized without re-parsing
+type Metadata struct {
+	Title      string   `json:"title"`
+	TitleKey   string   `json:"title_key"`
+	VariantKey string   `json:"variant_key"`
+	Labels     []string `json:"labels,omitempty"`
+	Revision   []int    `json:"revision,omitempty"`
+}
+
+// Describe parses a file name's metadata
+func Describe(name string) Metadata {
+	return Metadata{
+		Title:      Title(name),
+		TitleKey:   TitleKey(name),
+		VariantKey: VariantKey(name),
+		Labels:     Labels(name),
+		Revision:   Revision(name),
+	}
+}
+
+// Kind is a class of release that file names mark with a tag, such as betas
+// marked "(Beta)" or "(Beta 2)"
+type Kind string
+
+// Release kinds
+const (
+	Beta       Kind = "beta"
+	Proto      Kind = "proto"
+	Demo       Kind = "demo"
+	Unlicensed Kind = "unlicensed"
+	BIOS       Kind = "bios"
+)
+
+// kindWords are the tag words marking each kind across No-Intro, Redump,
+// and TOSEC style names, matched case-insensitively
+var kindWords = map[Kind][]string{
+	Beta:       {"beta"},
+	Proto:      {"proto", "prototype"},
+	Demo:       {"demo", "sample", "kiosk", "trial"},
+	Unlicensed: {"unl", "unlicensed"},
+	BIOS:       {"bios"},
+}
+
+// Is reports whether a file name has a tag marking it as kind k, e.g. "(Beta)",
+// "(Beta 2)", or "(USA, Proto)". Words in the title itself don't count, so
+// "Demolition Man (USA).zip" isn't a demo.
+func (k Kind) Is(name string) bool {
+	for _, label := range Labels(name) {
+		label = strings.ToLower(label)
+		for _, word := range kindWords[k] {
+			if label == word || strings.HasPrefix(label, word+" ") {
+				return true
+			}
+		}
+	}
+	return false
+}
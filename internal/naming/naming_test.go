@@ -0,0 +1,173 @@
+package naming
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTitle(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"Super Mario Bros. (World).zip", "Super Mario Bros."},
+		{"Sonic (USA, Europe) (Rev 1) [b].zip", "Sonic"},
+		{"[BIOS] PlayStation (USA) (v3.0).zip", "PlayStation"},
+		{"Game (Beta (2)) Extra.7z", "Game Extra"},
+		{"No Tags.zip", "No Tags"},
+		{"3, 2, 1, Smurf! (Europe) (En,Fr).zip", "3, 2, 1, Smurf!"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Title(tt.name); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected []string
+	}{
+		{"Sonic (USA, Europe) (Rev 1) [b].zip", []string{"USA, Europe", "Rev 1", "b"}},
+		{"[BIOS] PlayStation (USA).zip", []string{"BIOS", "USA"}},
+		{"Game (Beta (2)).zip", []string{"Beta (2)"}},
+		{"No Tags.zip", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Tags(tt.name); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestLabels(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected []string
+	}{
+		{"Sonic (USA, Europe) (En,Fr,De) (Rev 1).zip", []string{"USA", "Europe", "En", "Fr", "De", "Rev 1"}},
+		{"Game (USA) (Beta) (USA).zip", []string{"USA", "Beta"}},
+		{"No Tags.zip", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Labels(tt.name); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTitleKey(t *testing.T) {
+	if TitleKey("Sonic (USA).zip") != TitleKey("SONIC (Europe).zip") {
+		t.Error("expected regional variants to share a title key")
+	}
+}
+
+func TestRevision(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected []int
+	}{
+		{"Sonic (USA).zip", nil},
+		{"Sonic (USA) (Rev 1).zip", []int{1}},
+		{"Sonic (USA) (Rev B).zip", []int{2}},
+		{"Sonic (Japan) (v1.10).zip", []int{1, 10}},
+		{"Sonic (USA) (Rev 1.2) [b].zip", []int{1, 2}},
+		{"Sonic (Virtual Console).zip", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Revision(tt.name); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCompareRevisions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"Sonic (USA).zip", "Sonic (USA) (Rev 1).zip", -1},
+		{"Sonic (USA) (Rev 2).zip", "Sonic (USA) (Rev 1).zip", 1},
+		{"Sonic (Japan) (v1.9).zip", "Sonic (Japan) (v1.10).zip", -1},
+		{"Sonic (Japan) (v1.0).zip", "Sonic (Japan) (v1).zip", 0},
+		{"Sonic (USA).zip", "Sonic (USA).7z", 0},
+	}
+
+	for _, tt := range tests {
+		if got := CompareRevisions(tt.a, tt.b); got != tt.expected {
+			t.Errorf("CompareRevisions(%q, %q) = %d, expected %d", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
+
+func TestVariantKey(t *testing.T) {
+	if VariantKey("Sonic (USA).zip") != VariantKey("Sonic (USA) (Rev 1).zip") {
+		t.Error("expected revisions to share a variant key")
+	}
+	if VariantKey("Sonic (USA).zip") == VariantKey("Sonic (Europe).zip") {
+		t.Error("expected regions to have different variant keys")
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	got := Describe("Sonic (USA, Europe) (Rev 1).zip")
+	expected := Metadata{
+		Title:      "Sonic",
+		TitleKey:   "sonic",
+		VariantKey: VariantKey("Sonic (USA, Europe).zip"),
+		Labels:     []string{"USA", "Europe", "Rev 1"},
+		Revision:   []int{1},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestKind_Is(t *testing.T) {
+	tests := []struct {
+		kind     Kind
+		name     string
+		expected bool
+	}{
+		{Beta, "Sonic (USA) (Beta).zip", true},
+		{Beta, "Sonic (USA) (Beta 2).zip", true},
+		{Beta, "Sonic (Beta (2)).zip", true},
+		{Beta, "Sonic (USA, Beta).zip", true},
+		{Beta, "Sonic (beta).zip", true},
+		{Beta, "Betamax Story (USA).zip", false},
+		{Beta, "Sonic (USA) (Betaversion).zip", false},
+		{Proto, "Game (Japan) (Proto).zip", true},
+		{Proto, "Game (Japan) (Proto 1).zip", true},
+		{Proto, "Game (Prototype).zip", true},
+		{Demo, "Game (Europe) (Demo).zip", true},
+		{Demo, "Game (Europe) (Sample).zip", true},
+		{Demo, "Game (USA) (Kiosk).zip", true},
+		{Demo, "Demolition Man (USA).zip", false},
+		{Unlicensed, "Game (USA) (Unl).zip", true},
+		{Unlicensed, "Game (Unlicensed).zip", true},
+		{BIOS, "[BIOS] PlayStation (USA) (v3.0).zip", true},
+		{BIOS, "PlayStation (USA).zip", false},
+		{Beta, "No Tags.zip", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.kind)+"/"+tt.name, func(t *testing.T) {
+			if got := tt.kind.Is(tt.name); got != tt.expected {
+				t.Errorf("expected %t, got %t", tt.expected, got)
+			}
+		})
+	}
+}
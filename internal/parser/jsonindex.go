@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// acceptListing asks for a JSON listing where the server can send one, such
+// as Caddy's file browser, and HTML otherwise
+const acceptListing = "application/json, text/html;q=0.9, */*;q=0.8"
+
+// jsonEntry is an entry of a JSON directory listing, either nginx's
+// autoindex_format json or Caddy's browse output
+type jsonEntry struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+
+	// nginx: "file" or "directory", and an HTTP date
+	Type  string `json:"type"`
+	MTime string `json:"mtime"`
+
+	// Caddy: a relative URL, and an RFC 3339 date
+	URL     string    `json:"url"`
+	IsDir   bool      `json:"is_dir"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// isJSON reports whether a listing response is a JSON listing rather than HTML
+func isJSON(resp *http.Response) bool {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// parseJSON extracts file information and subdirectory URLs from a JSON
+// directory listing, keeping only links within scope. Its sizes are byte
+// counts, so the files are marked exact.
+func parseJSON(r io.Reader, baseURL string, scope Scope) ([]FileInfo, []string, error) {
+	var entries []jsonEntry
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse JSON listing: %w", err)
+	}
+
+	var files []FileInfo
+	var dirs []string
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name, "/")
+		if name == "" || name == "." || name == ".." {
+			continue
+		}
+		dir := e.IsDir || e.Type == "directory" || strings.HasSuffix(e.Name, "/")
+
+		href := e.URL
+		if href == "" {
+			href = (&url.URL{Path: name}).EscapedPath()
+			if strings.Contains(name, ":") {
+				href = "./" + href // Not a scheme
+			}
+		}
+		if dir && !strings.HasSuffix(href, "/") {
+			href += "/"
+		}
+
+		fileURL, err := buildAbsoluteURL(baseURL, href)
+		if err != nil {
+			continue
+		}
+		if u, err := url.Parse(fileURL); err != nil || !scope.Contains(u) {
+			continue
+		}
+		if dir {
+			dirs = append(dirs, fileURL)
+			continue
+		}
+
+		modTime := e.ModTime.UTC()
+		if t, err := http.ParseTime(e.MTime); err == nil {
+			modTime = t.UTC()
+		}
+		files = append(files, FileInfo{
+			Name:    name,
+			URL:     fileURL,
+			Size:    e.Size,
+			Exact:   true,
+			ModTime: modTime,
+		})
+	}
+
+	return files, dirs, nil
+}
@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/nchapman/myrient-dl/internal/units"
+	"golang.org/x/net/html"
+)
+
+// layouts are the ways a listing page can lay out its links, tried in order.
+// Myrient's directories use the fancy table#list; its miscellaneous pages,
+// such as firmware and tool directories, list loose files in a preformatted
+// block, a plain table, or a bare list.
+var layouts = []struct {
+	links string // Selector for the file and directory links
+	flat  bool   // Each link is followed on its line by its date and size
+}{
+	{"table#list a", false},
+	{"pre a", true},
+	{"table a", false},
+	{"ul li a", true},
+}
+
+// listingLinks returns the links of the first layout the page uses, and
+// whether it's a flat one
+func listingLinks(doc *goquery.Document) (*goquery.Selection, bool) {
+	for _, l := range layouts {
+		if links := doc.Find(l.links); links.Length() > 0 {
+			return links, l.flat
+		}
+	}
+	return doc.Find("table#list a"), false
+}
+
+// flatName returns a flat listing link's file name from its href, since the
+// link text may be cut short, e.g. "Super Long Firmware Na..>"
+func flatName(href, text string) string {
+	u, err := url.Parse(href)
+	if err != nil || u.Path == "" {
+		return text
+	}
+	if name := path.Base(u.Path); name != "." && name != "/" {
+		return name
+	}
+	return text
+}
+
+// flatLine returns the text after a flat listing link, up to the end of its line
+func flatLine(s *goquery.Selection) string {
+	next := s.Nodes[0].NextSibling
+	if next == nil || next.Type != html.TextNode {
+		return ""
+	}
+	line, _, _ := strings.Cut(next.Data, "\n")
+	return line
+}
+
+// flatSize reads the size from the text after a flat listing link, up to the
+// end of its line: bytes as nginx shows them, e.g. "11-Sep-2023 09:52  72192",
+// or a short unit as Apache does, e.g. "2023-09-11 09:52  70K"
+func flatSize(s *goquery.Selection) int64 {
+	line := flatLine(s)
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return 0
+	}
+	if n, err := strconv.ParseInt(fields[len(fields)-1], 10, 64); err == nil && n >= 0 {
+		return n
+	}
+	size, _ := units.FindSize(line)
+	return size
+}
@@ -0,0 +1,54 @@
+package parser
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// listedTimes are the date formats listings show in their "Last modified"
+// column, each with a pattern to find it in a cell or line. Listings don't
+// say which time zone they use, so times are read as UTC.
+var listedTimes = []struct {
+	pattern *regexp.Regexp
+	layout  string
+}{
+	{regexp.MustCompile(`\d{2}-[A-Z][a-z]{2}-\d{4} \d{2}:\d{2}:\d{2}`), "02-Jan-2006 15:04:05"}, // nginx, Myrient
+	{regexp.MustCompile(`\d{2}-[A-Z][a-z]{2}-\d{4} \d{2}:\d{2}`), "02-Jan-2006 15:04"},
+	{regexp.MustCompile(`\d{4}-[A-Z][a-z]{2}-\d{2} \d{2}:\d{2}:\d{2}`), "2006-Jan-02 15:04:05"}, // lighttpd
+	{regexp.MustCompile(`\d{4}-[A-Z][a-z]{2}-\d{2} \d{2}:\d{2}`), "2006-Jan-02 15:04"},
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}`), "2006-01-02 15:04:05"}, // Apache
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2} \d{2}:\d{2}`), "2006-01-02 15:04"},
+}
+
+// findModTime finds a listing date in text; the zero time if there is none
+func findModTime(text string) time.Time {
+	for _, t := range listedTimes {
+		if match := t.pattern.FindString(text); match != "" {
+			if parsed, err := time.Parse(t.layout, match); err == nil {
+				return parsed
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// extractModTime finds the date in a table link's row: in the cells after
+// the link's, or anywhere in the row
+func extractModTime(s *goquery.Selection) time.Time {
+	var modTime time.Time
+	if td := s.Parent(); td.Is("td") {
+		td.NextAllFiltered("td").EachWithBreak(func(_ int, next *goquery.Selection) bool {
+			modTime = findModTime(next.Text())
+			return modTime.IsZero()
+		})
+		if !modTime.IsZero() {
+			return modTime
+		}
+	}
+	if row := s.Closest("tr"); row.Length() > 0 {
+		return findModTime(row.Text())
+	}
+	return modTime
+}
@@ -1,17 +1,25 @@
-// Package parser provides HTML parsing for Apache-style directory listings.
+// Package parser provides HTML parsing for Apache-style directory listings,
+// including the flat pages some directories use instead of the usual table,
+// and reads the JSON listings nginx and Caddy can serve instead.
 package parser
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"regexp"
-	"strconv"
+	"path"
 	"strings"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/nchapman/myrient-dl/internal/auth"
+	"github.com/nchapman/myrient-dl/internal/catalog"
+	"github.com/nchapman/myrient-dl/internal/fsutil"
+	"github.com/nchapman/myrient-dl/internal/retry"
+	"github.com/nchapman/myrient-dl/internal/units"
 )
 
 // FileInfo represents a file in the directory listing
@@ -19,46 +27,318 @@ type FileInfo struct {
 	Name string
 	URL  string
 	Size int64
+	// Exact is set when Size is the byte count the server reported for the
+	// file (--exact-sizes, or a JSON listing) rather than a listing's rounded size
+	Exact bool `json:",omitempty"`
+	// ModTime is the listing's "Last modified" date, read as UTC; zero when
+	// the listing has none
+	ModTime time.Time `json:",omitzero"`
+
+	// Collection and System are inferred from a Myrient listing's path, e.g.
+	// "No-Intro" and "Nintendo - Game Boy"; empty for other layouts
+	Collection string `json:",omitempty"`
+	System     string `json:",omitempty"`
 }
 
-// ParseDirectoryListing fetches and parses an Apache-style directory listing
-func ParseDirectoryListing(ctx context.Context, directoryURL string) ([]FileInfo, error) {
-	// Fetch the directory listing
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, directoryURL, nil)
+// SystemLabel returns a "Collection / System" label, or "" when neither is known
+func (f FileInfo) SystemLabel() string {
+	switch {
+	case f.System == "":
+		return f.Collection
+	case f.Collection == "":
+		return f.System
+	default:
+		return f.Collection + " / " + f.System
+	}
+}
+
+// SizeMatches reports whether a file of actual bytes has this file's size:
+// exactly when the size is exact, otherwise allowing for listing rounding
+func (f FileInfo) SizeMatches(actual int64) bool {
+	if f.Exact {
+		return f.Size == actual
+	}
+	return units.Plausible(f.Size, actual)
+}
+
+var (
+	// ErrUnreachable wraps the error of a listing page that couldn't be
+	// fetched, after any retries
+	ErrUnreachable = errors.New("listing unreachable")
+	// ErrEmpty is returned for a listing that was fetched but has no files
+	ErrEmpty = errors.New("no files found in directory listing")
+)
+
+// statusError is an unexpected response status for a listing page
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("server returned status %d", e.code)
+}
+
+// Listing is a fetched directory listing with the validators the server sent
+// for it, so data derived from the listing can be cached
+type Listing struct {
+	URL          string // After redirects
+	ETag         string
+	LastModified string
+	Files        []FileInfo
+	// Unsafe lists the link names left out because saving them as named
+	// would escape the download directory, e.g. "../../etc/cron.d/x"
+	Unsafe []string
+}
+
+// ParseDirectoryListing fetches and parses an Apache-style directory listing.
+// Links off the listing's host or above its path are dropped unless opts allow them.
+func ParseDirectoryListing(ctx context.Context, directoryURL string, opts Options) ([]FileInfo, error) {
+	listing, err := FetchListing(ctx, directoryURL, opts)
+	if err != nil {
+		return nil, err
+	}
+	return listing.Files, nil
+}
+
+// FetchListing is ParseDirectoryListing that also returns the listing's ETag
+// and Last-Modified headers. With opts.Recursive, files in subdirectories are
+// included and named by their path below the listing, e.g. "Disc 2/Game.zip";
+// the validators are those of the starting listing.
+//
+// A listing that can't be fetched fails with an error wrapping
+// ErrUnreachable, and one without any files with ErrEmpty, so callers such
+// as watch can tell a server hiccup from a directory that emptied.
+func FetchListing(ctx context.Context, directoryURL string, opts Options) (*Listing, error) {
+	scope, err := NewScope(directoryURL, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	listing, dirs, err := fetchPageWithRetry(ctx, directoryURL, opts, scope)
+	if err != nil {
+		return nil, err
+	}
+	if opts.OnListing != nil {
+		opts.OnListing(listing.URL, len(listing.Files))
+	}
+	if opts.Recursive {
+		if err := walk(ctx, listing, dirs, opts, scope); err != nil {
+			return nil, err
+		}
+	}
+	if len(listing.Files) == 0 {
+		return nil, ErrEmpty
+	}
+	return listing, nil
+}
+
+// subdirectory is a directory link waiting to be listed
+type subdirectory struct {
+	url   string
+	depth int // 1 for the starting listing's own subdirectories
+}
+
+// walk lists the subdirectories below a fetched listing breadth-first, adding
+// their files to it. Only directories on the same host and below the
+// listing's path are entered, even with AllowCrossHost, and each only once.
+func walk(ctx context.Context, listing *Listing, dirs []string, opts Options, scope Scope) error {
+	root, err := url.Parse(listing.URL)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	below, err := NewScope(listing.URL, Options{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return err
 	}
 
-	// Set User-Agent for polite web scraping
-	req.Header.Set("User-Agent", "myrient-dl/1.0 (https://github.com/nchapman/myrient-dl)")
+	queue := make([]subdirectory, 0, len(dirs))
+	for _, d := range dirs {
+		queue = append(queue, subdirectory{url: d, depth: 1})
+	}
+	seen := map[string]bool{listing.URL: true}
+
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+		u, err := url.Parse(dir.url)
+		if err != nil || seen[u.String()] || !below.Contains(u) {
+			continue
+		}
+		seen[u.String()] = true
 
-	resp, err := http.DefaultClient.Do(req)
+		page, subdirs, err := fetchPageWithRetry(ctx, dir.url, opts, scope)
+		if err != nil {
+			return fmt.Errorf("failed to list %s: %w", dir.url, err)
+		}
+		if opts.OnListing != nil {
+			opts.OnListing(page.URL, len(page.Files))
+		}
+
+		prefix := relativeDir(root, u)
+		for _, f := range page.Files {
+			f.Name = path.Join(prefix, f.Name)
+			listing.Files = append(listing.Files, f)
+		}
+		for _, name := range page.Unsafe {
+			listing.Unsafe = append(listing.Unsafe, prefix+"/"+name) // Not joined, which would clean away the ".."
+		}
+		if opts.MaxDepth == 0 || dir.depth < opts.MaxDepth {
+			for _, d := range subdirs {
+				queue = append(queue, subdirectory{url: d, depth: dir.depth + 1})
+			}
+		}
+	}
+	return nil
+}
+
+// relativeDir returns the slash-separated local directory for a subdirectory
+// URL below root, with every component sanitized so a remote name can't
+// climb out of the output directory
+func relativeDir(root, dir *url.URL) string {
+	rel := strings.TrimPrefix(path.Clean("/"+dir.Path), path.Clean("/"+root.Path))
+	var parts []string
+	for _, part := range strings.Split(rel, "/") {
+		if part = fsutil.SanitizeFilename(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// fetchPage fetches and parses a single listing page, returning its files and
+// the URLs of its subdirectories
+func fetchPage(ctx context.Context, directoryURL string, opts Options, scope Scope) (*Listing, []string, error) {
+	ctx, idle, cancel := watchIdle(ctx, opts.stallTimeout())
+	defer cancel()
+
+	// Fetch the directory listing
+	// Identify ourselves for polite web scraping
+	req, err := opts.Identity.NewRequest(ctx, http.MethodGet, directoryURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch directory: %w", err)
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", acceptListing)
+
+	resp, err := auth.Do(opts.client(), req, opts.Auth)
+	if idle.stalled() {
+		return nil, nil, fmt.Errorf("failed to fetch directory: %w: no response in %v", errStalled, idle.period)
 	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch directory: %w", err)
+	}
+	idle.progress()
 	defer func() {
 		_ = resp.Body.Close()
 	}()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server returned status %d", resp.StatusCode)
+		return nil, nil, &statusError{code: resp.StatusCode}
+	}
+
+	// Trailing-slash redirects are expected; a redirect elsewhere is only followed on request
+	if !scope.Contains(resp.Request.URL) {
+		return nil, nil, fmt.Errorf("listing redirected outside %s to %s (use --allow-cross-host to follow it)", directoryURL, resp.Request.URL)
 	}
 
-	return parseHTML(resp.Body, directoryURL)
+	parse := parseHTML
+	if isJSON(resp) {
+		parse = parseJSON
+	}
+	parsed, dirs, err := parse(idle.body(resp.Body), resp.Request.URL.String(), scope)
+	if idle.stalled() {
+		return nil, nil, fmt.Errorf("failed to read directory: %w: no data for %v", errStalled, idle.period)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	// Names become local paths, so ones that could climb out are never listed
+	files := parsed[:0]
+	var unsafe []string
+	for _, f := range parsed {
+		if fsutil.SafeName(f.Name) {
+			files = append(files, f)
+		} else {
+			unsafe = append(unsafe, f.Name)
+		}
+	}
+	if sys, ok := catalog.Detect(resp.Request.URL.String()); ok {
+		for i := range files {
+			files[i].Collection = sys.Collection
+			files[i].System = sys.Name
+		}
+	}
+	return &Listing{
+		URL:          resp.Request.URL.String(),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Files:        files,
+		Unsafe:       unsafe,
+	}, dirs, nil
 }
 
-// parseHTML extracts file information from the HTML directory listing
-func parseHTML(r io.Reader, baseURL string) ([]FileInfo, error) {
+// fetchPageWithRetry is fetchPage, trying again per opts.Retry while the page
+// fails in a way that may pass. Failures to get the page at all are wrapped
+// in ErrUnreachable.
+func fetchPageWithRetry(ctx context.Context, directoryURL string, opts Options, scope Scope) (*Listing, []string, error) {
+	for attempt := 1; ; attempt++ {
+		listing, dirs, err := fetchPage(ctx, directoryURL, opts, scope)
+		if err == nil {
+			return listing, dirs, nil
+		}
+		if !unreachable(err) || ctx.Err() != nil {
+			return nil, nil, err
+		}
+		if !transient(err) || attempt >= opts.Retry.Attempts {
+			if attempt > 1 {
+				return nil, nil, fmt.Errorf("%w after %d attempts: %w", ErrUnreachable, attempt, err)
+			}
+			return nil, nil, fmt.Errorf("%w: %w", ErrUnreachable, err)
+		}
+
+		delay := opts.Retry.Delay(attempt)
+		if opts.OnRetry != nil {
+			opts.OnRetry(directoryURL, attempt, delay, err)
+		}
+		if err := retry.Wait(ctx, delay); err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// unreachable reports whether err means the page couldn't be fetched, as
+// opposed to being refused by scope or failing to parse
+func unreachable(err error) bool {
+	var status *statusError
+	var network *url.Error
+	return errors.As(err, &status) || errors.As(err, &network) || errors.Is(err, errStalled)
+}
+
+// transient reports whether a fetch error may go away on its own: a network
+// error, a stalled page, or a timeout, rate limit, or server error status
+func transient(err error) bool {
+	var status *statusError
+	if errors.As(err, &status) {
+		return status.code == http.StatusRequestTimeout || status.code == http.StatusTooManyRequests || status.code >= 500
+	}
+	return true
+}
+
+// parseHTML extracts file information and subdirectory URLs from the HTML
+// directory listing, keeping only links within scope
+func parseHTML(r io.Reader, baseURL string, scope Scope) ([]FileInfo, []string, error) {
 	doc, err := goquery.NewDocumentFromReader(r)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+		return nil, nil, fmt.Errorf("failed to parse HTML: %w", err)
 	}
 
 	var files []FileInfo
+	var dirs []string
 
 	// Apache directory listings use <a> tags for file links within table#list
-	// We constrain to table#list to avoid picking up navigation links
-	doc.Find("table#list a").Each(func(_ int, s *goquery.Selection) {
+	// We constrain to table#list to avoid picking up navigation links, unless
+	// the page lists its files another way
+	links, flat := listingLinks(doc)
+	links.Each(func(_ int, s *goquery.Selection) {
 		href, exists := s.Attr("href")
 		if !exists {
 			return
@@ -69,40 +349,61 @@ func parseHTML(r io.Reader, baseURL string) ([]FileInfo, error) {
 			return
 		}
 
+		// Skip icons linking to the file next to them
+		if strings.TrimSpace(s.Text()) == "" && s.Find("img").Length() > 0 {
+			return
+		}
+
 		// Skip query parameters (sorting links)
 		if strings.Contains(href, "?C=") {
 			return
 		}
 
-		// Skip directories (end with /)
+		// Build absolute URL
+		fileURL, err := buildAbsoluteURL(baseURL, href)
+		if err != nil {
+			return
+		}
+
+		// Never leave the starting host or climb above the starting path
+		if u, err := url.Parse(fileURL); err != nil || !scope.Contains(u) {
+			return
+		}
+
+		// Directories (ending with /) are only followed by a recursive listing
 		if strings.HasSuffix(href, "/") {
+			dirs = append(dirs, fileURL)
 			return
 		}
 
 		// Get the filename (text content of the link)
 		name := strings.TrimSpace(s.Text())
+		if flat {
+			name = flatName(href, name)
+		}
 		if name == "" {
 			name = href
 		}
 
-		// Build absolute URL
-		fileURL, err := buildAbsoluteURL(baseURL, href)
-		if err != nil {
-			return
-		}
-
 		// Try to extract size from the HTML
 		// Apache listings typically show size in the same row
-		size := extractSize(s)
+		var size int64
+		var modTime time.Time
+		if flat {
+			size, modTime = flatSize(s), findModTime(flatLine(s))
+		} else {
+			size, modTime = extractSize(s), extractModTime(s)
+		}
 
 		files = append(files, FileInfo{
-			Name: name,
-			URL:  fileURL,
-			Size: size,
+			Name:    name,
+			URL:     fileURL,
+			Size:    size,
+			ModTime: modTime,
 		})
 	})
 
-	return files, nil
+	return files, dirs, nil
 }
 
 // buildAbsoluteURL constructs an absolute URL from a base and relative path
@@ -128,13 +429,15 @@ func extractSize(s *goquery.Selection) int64 {
 	// Strategy 1: Look in parent table cell (td)
 	td := s.Parent()
 	if td.Is("td") {
-		// Look at the next sibling(s) for size
-		nextTd := td.Next()
-		if nextTd.Length() > 0 {
-			text := nextTd.Text()
-			if size := parseSizeString(text); size > 0 {
-				return size
-			}
+		// Look at the next sibling(s) for size; a "-" cell means there is none
+		var size int64
+		var found bool
+		td.NextAllFiltered("td").EachWithBreak(func(_ int, next *goquery.Selection) bool {
+			size, found = units.FindSize(next.Text())
+			return !found
+		})
+		if found {
+			return size
 		}
 	}
 
@@ -142,45 +445,12 @@ func extractSize(s *goquery.Selection) int64 {
 	row := s.Closest("tr")
 	if row.Length() > 0 {
 		text := row.Text()
-		if size := parseSizeString(text); size > 0 {
+		if size, _ := units.FindSize(text); size > 0 {
 			return size
 		}
 	}
 
 	// Strategy 3: Look at parent element's text (for non-table layouts)
-	text := s.Parent().Text()
-	return parseSizeString(text)
-}
-
-// parseSizeString extracts size from a string like "70.5 KiB"
-func parseSizeString(text string) int64 {
-	// Try to find size patterns like "70.5 KiB", "1.2 MiB", "500 B"
-	sizeRegex := regexp.MustCompile(`(\d+(?:\.\d+)?)\s*(B|KiB|MiB|GiB|TiB|K|M|G|T)(?:\s|$)`)
-	matches := sizeRegex.FindStringSubmatch(text)
-
-	if len(matches) < 3 {
-		return 0
-	}
-
-	value, err := strconv.ParseFloat(matches[1], 64)
-	if err != nil {
-		return 0
-	}
-
-	unit := matches[2]
-
-	// Convert to bytes
-	multiplier := int64(1)
-	switch unit {
-	case "K", "KiB":
-		multiplier = 1024
-	case "M", "MiB":
-		multiplier = 1024 * 1024
-	case "G", "GiB":
-		multiplier = 1024 * 1024 * 1024
-	case "T", "TiB":
-		multiplier = 1024 * 1024 * 1024 * 1024
-	}
-
-	return int64(value * float64(multiplier))
+	size, _ := units.FindSize(s.Parent().Text())
+	return size
 }
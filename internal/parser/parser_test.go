@@ -2,10 +2,19 @@ package parser
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/retry"
+	"github.com/nchapman/myrient-dl/internal/useragent"
 )
 
 func TestParseDirectoryListing(t *testing.T) {
@@ -39,7 +48,7 @@ func TestParseDirectoryListing(t *testing.T) {
 	defer server.Close()
 
 	// Parse the directory listing
-	files, err := ParseDirectoryListing(context.Background(), server.URL)
+	files, err := ParseDirectoryListing(context.Background(), server.URL, Options{})
 	if err != nil {
 		t.Fatalf("failed to parse directory listing: %v", err)
 	}
@@ -85,15 +94,52 @@ func TestParseDirectoryListing(t *testing.T) {
 	}
 }
 
+func TestParseDirectoryListing_SystemMetadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<table id="list"><tr><td><a href="Tetris%20(World).zip">Tetris (World).zip</a></td><td>1 KiB</td></tr></table>`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		path       string
+		collection string
+		system     string
+		label      string
+	}{
+		{"system listing", "/files/No-Intro/Nintendo%20-%20Game%20Boy/", "No-Intro", "Nintendo - Game Boy", "No-Intro / Nintendo - Game Boy"},
+		{"collection listing", "/files/TOSEC/", "TOSEC", "", "TOSEC"},
+		{"other layout", "/roms/", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := ParseDirectoryListing(context.Background(), server.URL+tt.path, Options{})
+			if err != nil {
+				t.Fatalf("failed to parse directory listing: %v", err)
+			}
+			if len(files) != 1 {
+				t.Fatalf("expected 1 file, got %d", len(files))
+			}
+			f := files[0]
+			if f.Collection != tt.collection || f.System != tt.system || f.SystemLabel() != tt.label {
+				t.Errorf("expected %q / %q (%q), got %q / %q (%q)",
+					tt.collection, tt.system, tt.label, f.Collection, f.System, f.SystemLabel())
+			}
+		})
+	}
+}
+
 func TestParseDirectoryListing_ServerError(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 	}))
 	defer server.Close()
 
-	_, err := ParseDirectoryListing(context.Background(), server.URL)
-	if err == nil {
-		t.Error("expected error for server error response, got nil")
+	_, err := ParseDirectoryListing(context.Background(), server.URL, Options{})
+	if !errors.Is(err, ErrUnreachable) {
+		t.Errorf("expected ErrUnreachable for server error response, got %v", err)
 	}
 }
 
@@ -115,9 +161,9 @@ func TestParseDirectoryListing_EmptyListing(t *testing.T) {
 	}))
 	defer server.Close()
 
-	files, err := ParseDirectoryListing(context.Background(), server.URL)
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
+	files, err := ParseDirectoryListing(context.Background(), server.URL, Options{})
+	if !errors.Is(err, ErrEmpty) || errors.Is(err, ErrUnreachable) {
+		t.Fatalf("expected ErrEmpty, got %v", err)
 	}
 
 	if len(files) != 0 {
@@ -125,33 +171,171 @@ func TestParseDirectoryListing_EmptyListing(t *testing.T) {
 	}
 }
 
-func TestParseSizeString(t *testing.T) {
+func TestFetchListing_Retry(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		switch {
+		case r.URL.Path == "/missing/":
+			w.WriteHeader(http.StatusNotFound)
+		case r.URL.Path == "/down/" || n < 3:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			_, _ = w.Write([]byte(`<table id="list"><tr><td><a href="a.zip">a.zip</a></td></tr></table>`))
+		}
+	}))
+	defer server.Close()
+
+	var retries []int
+	opts := Options{
+		Retry:   retry.Policy{Attempts: 3, Base: time.Millisecond},
+		OnRetry: func(_ string, attempt int, _ time.Duration, _ error) { retries = append(retries, attempt) },
+	}
+	files, err := ParseDirectoryListing(context.Background(), server.URL+"/", opts)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected the third attempt to succeed, got %v (%v)", files, err)
+	}
+	if len(retries) != 2 || retries[1] != 2 {
+		t.Errorf("expected 2 retries, got %v", retries)
+	}
+
+	requests.Store(10)
+	_, err = ParseDirectoryListing(context.Background(), server.URL+"/down/", opts)
+	if !errors.Is(err, ErrUnreachable) || requests.Load() != 13 {
+		t.Errorf("expected ErrUnreachable after 3 requests, got %v after %d", err, requests.Load()-10)
+	}
+
+	requests.Store(10)
+	_, err = ParseDirectoryListing(context.Background(), server.URL+"/missing/", opts)
+	if !errors.Is(err, ErrUnreachable) || requests.Load() != 11 {
+		t.Errorf("expected a 404 to fail without retries, got %v after %d requests", err, requests.Load()-10)
+	}
+}
+
+func TestFetchListing_Stalled(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		if r.URL.Path == "/body/" {
+			// Headers and part of the page, then nothing
+			_, _ = w.Write([]byte(`<table id="list"><tr><td><a href="a.zip">a.zip</a>`))
+			w.(http.Flusher).Flush()
+		} else if n > 2 {
+			_, _ = w.Write([]byte(`<table id="list"><tr><td><a href="a.zip">a.zip</a></td></tr></table>`))
+			return
+		}
+		<-r.Context().Done() // Accept the connection and never answer
+	}))
+	defer server.Close()
+
+	var retries int
+	opts := Options{
+		StallTimeout: 50 * time.Millisecond,
+		Retry:        retry.Policy{Attempts: 3, Base: time.Millisecond},
+		OnRetry:      func(string, int, time.Duration, error) { retries++ },
+	}
+	files, err := ParseDirectoryListing(context.Background(), server.URL+"/", opts)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected the third attempt to succeed, got %v (%v)", files, err)
+	}
+	if retries != 2 {
+		t.Errorf("expected 2 retries of the hanging page, got %d", retries)
+	}
+
+	start := time.Now()
+	_, err = ParseDirectoryListing(context.Background(), server.URL+"/body/", opts)
+	if !errors.Is(err, ErrUnreachable) || !errors.Is(err, errStalled) {
+		t.Errorf("expected a stalled body to be unreachable, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the stall to be caught quickly, took %v", elapsed)
+	}
+}
+
+func TestParseDirectoryListing_Sizes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`<html><body><table id="list">
+<tr><td><a href="a.zip">a.zip</a></td><td>70.5 KiB</td></tr>
+<tr><td><a href="b.zip">b.zip</a></td><td>70,5 KiB</td></tr>
+<tr><td><a href="c.zip">c.zip</a></td><td>72.2 kB</td></tr>
+<tr><td><a href="d.zip">d.zip</a></td><td>-</td><td>Fits a 4 GB card</td></tr>
+</table></body></html>`))
+	}))
+	defer server.Close()
+
+	files, err := ParseDirectoryListing(context.Background(), server.URL, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []int64{72192, 72192, 72200, 0}
+	if len(files) != len(want) {
+		t.Fatalf("expected %d files, got %+v", len(want), files)
+	}
+	for i, size := range want {
+		if files[i].Size != size {
+			t.Errorf("%s: expected %d bytes, got %d", files[i].Name, size, files[i].Size)
+		}
+	}
+}
+
+func TestFindModTime(t *testing.T) {
+	want := time.Date(2023, time.September, 11, 9, 52, 0, 0, time.UTC)
 	tests := []struct {
-		input    string
-		expected int64
+		text string
+		want time.Time
 	}{
-		{"70.5 KiB", 72192},
-		{"1.2 MiB", 1258291},
-		{"500 B", 500},
-		{"2.5 GiB", 2684354560},
-		{"1 TiB", 1099511627776},
-		{"invalid", 0},
-		{"", 0},
-		{"123", 0}, // no unit
+		{"11-Sep-2023 09:52", want},
+		{"  11-Sep-2023 09:52   355278412", want},
+		{"2023-Sep-11 09:52:00", want},
+		{"2023-09-11 09:52  1.2M", want},
+		{"2023-09-11 09:52:30", want.Add(30 * time.Second)},
+		{"-", time.Time{}},
+		{"Game (2023).zip", time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := findModTime(tt.text); !got.Equal(tt.want) {
+			t.Errorf("findModTime(%q) = %v, want %v", tt.text, got, tt.want)
+		}
 	}
+}
 
+func TestParseDirectoryListing_ModTimes(t *testing.T) {
+	tests := []struct {
+		fixture string
+		name    string
+		want    time.Time
+	}{
+		{"table.html", "Zelda (USA).pdf", time.Date(2023, time.September, 11, 9, 52, 0, 0, time.UTC)},
+		{"pre_nginx.html", "PS3UPDAT (4.91).PUP", time.Date(2024, time.February, 4, 10, 2, 0, 0, time.UTC)},
+		{"pre_apache.html", "igir.zip", time.Date(2023, time.September, 11, 10, 30, 0, 0, time.UTC)},
+	}
 	for _, tt := range tests {
-		t.Run(tt.input, func(t *testing.T) {
-			result := parseSizeString(tt.input)
-			// Allow 1% tolerance for floating point math
-			tolerance := int64(float64(tt.expected) * 0.01)
-			if tt.expected == 0 {
-				tolerance = 0
+		fixture := tt.fixture
+		page, err := os.ReadFile(filepath.Join("testdata", fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write(page)
+		}))
+		files, err := ParseDirectoryListing(context.Background(), server.URL+"/", Options{})
+		server.Close()
+		if err != nil || len(files) == 0 {
+			t.Fatalf("%s: %v", fixture, err)
+		}
+		var found bool
+		for _, f := range files {
+			if f.Name != tt.name {
+				continue
 			}
-			if result < tt.expected-tolerance || result > tt.expected+tolerance {
-				t.Errorf("parseSizeString(%q) = %d, want ~%d", tt.input, result, tt.expected)
+			found = true
+			if !f.ModTime.Equal(tt.want) {
+				t.Errorf("%s: %s dated %v, want %v", fixture, f.Name, f.ModTime, tt.want)
 			}
-		})
+		}
+		if !found {
+			t.Errorf("%s: %s not listed", fixture, tt.name)
+		}
 	}
 }
 
@@ -237,7 +421,7 @@ func TestParseDirectoryListing_IgnoresLinksOutsideTable(t *testing.T) {
 	}))
 	defer server.Close()
 
-	files, err := ParseDirectoryListing(context.Background(), server.URL)
+	files, err := ParseDirectoryListing(context.Background(), server.URL, Options{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -251,3 +435,387 @@ func TestParseDirectoryListing_IgnoresLinksOutsideTable(t *testing.T) {
 		t.Errorf("expected file1.zip, got %s", files[0].Name)
 	}
 }
+
+func TestParseDirectoryListing_FlatLayouts(t *testing.T) {
+	type file struct {
+		name string
+		size int64
+	}
+	tests := []struct {
+		fixture string
+		want    []file
+	}{
+		{"pre_nginx.html", []file{
+			{"PS3UPDAT (4.91).PUP", 208742400},
+			{"Nintendo Switch Firmware 17.0.1 (Global).zip", 355278412}, // Link text is cut short
+			{"readme.txt", 1234},
+		}},
+		{"pre_apache.html", []file{
+			{"dat-tools.7z", 1258291},
+			{"igir.zip", 71680},
+			{"checksums.sha1", 500},
+		}},
+		{"table.html", []file{
+			{"Super Mario Bros. (USA).pdf", 2621440},
+			{"Zelda (USA).pdf", 819200},
+		}},
+		{"ul.html", []file{
+			{"scph1001.bin", 0},
+			{"gba_bios.bin", 0},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			page, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				_, _ = w.Write(page)
+			}))
+			defer server.Close()
+
+			files, err := ParseDirectoryListing(context.Background(), server.URL+"/files/Miscellaneous/Page/", Options{})
+			if err != nil {
+				t.Fatalf("failed to parse directory listing: %v", err)
+			}
+			if len(files) != len(tt.want) {
+				t.Fatalf("expected %d files, got %+v", len(tt.want), files)
+			}
+			for i, want := range tt.want {
+				got := files[i]
+				if got.Name != want.name || got.Size != want.size {
+					t.Errorf("expected %q (%d bytes), got %q (%d bytes)", want.name, want.size, got.Name, got.Size)
+				}
+				if !strings.HasPrefix(got.URL, server.URL+"/files/Miscellaneous/Page/") {
+					t.Errorf("expected %s to be below the listing", got.URL)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchListing_JSON(t *testing.T) {
+	want := []FileInfo{
+		{Name: "PS3UPDAT (4.91).PUP", Size: 208742400, ModTime: time.Date(2024, time.February, 4, 10, 2, 0, 0, time.UTC)},
+		{Name: "readme.txt", Size: 1234, ModTime: time.Date(2023, time.September, 11, 9, 52, 0, 0, time.UTC)},
+	}
+	for _, fixture := range []string{"nginx.json", "caddy.json"} {
+		t.Run(fixture, func(t *testing.T) {
+			page, err := os.ReadFile(filepath.Join("testdata", fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var accept string
+			var requests []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				accept = r.Header.Get("Accept")
+				requests = append(requests, r.URL.Path)
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				if r.URL.Path == "/fw/Updates/" {
+					_, _ = w.Write([]byte(`[{"name":"next.pup","type":"file","size":5}]`))
+					return
+				}
+				_, _ = w.Write(page)
+			}))
+			defer server.Close()
+
+			listing, err := FetchListing(context.Background(), server.URL+"/fw/", Options{Recursive: true})
+			if err != nil {
+				t.Fatalf("FetchListing failed: %v", err)
+			}
+			if !strings.HasPrefix(accept, "application/json") {
+				t.Errorf("expected JSON to be asked for first, got Accept %q", accept)
+			}
+			if len(listing.Files) != len(want)+1 {
+				t.Fatalf("expected %d files, got %+v", len(want)+1, listing.Files)
+			}
+			for i, w := range want {
+				got := listing.Files[i]
+				if got.Name != w.Name || got.Size != w.Size || !got.Exact || !got.ModTime.Equal(w.ModTime) {
+					t.Errorf("expected %q (%d exact bytes, %v), got %+v", w.Name, w.Size, w.ModTime, got)
+				}
+			}
+			if got := listing.Files[0].URL; got != server.URL+"/fw/PS3UPDAT%20%284.91%29.PUP" {
+				t.Errorf("unexpected URL %s", got)
+			}
+			if got := listing.Files[2].Name; got != "Updates/next.pup" {
+				t.Errorf("expected the subdirectory's file, got %q (requests %v)", got, requests)
+			}
+		})
+	}
+}
+
+func TestScope_Contains(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		crossHost bool
+		want      bool
+	}{
+		{name: "file in start directory", url: "https://myrient.example/files/No-Intro/NES/a.zip", want: true},
+		{name: "file in subdirectory", url: "https://myrient.example/files/No-Intro/NES/sub/a.zip", want: true},
+		{name: "start directory itself", url: "https://myrient.example/files/No-Intro/NES", want: true},
+		{name: "host case differs", url: "https://MYRIENT.example/files/No-Intro/NES/a.zip", want: true},
+		{name: "parent directory", url: "https://myrient.example/files/No-Intro/a.zip", want: false},
+		{name: "sibling with shared prefix", url: "https://myrient.example/files/No-Intro/NES2/a.zip", want: false},
+		{name: "dot segments", url: "https://myrient.example/files/No-Intro/NES/sub/../../a.zip", want: false},
+		{name: "escaped dot segments", url: "https://myrient.example/files/No-Intro/NES/%2e%2e/a.zip", want: false},
+		{name: "other host", url: "https://mirror.example/files/No-Intro/NES/a.zip", want: false},
+		{name: "other host allowed", url: "https://mirror.example/anywhere/a.zip", crossHost: true, want: true},
+		{name: "cross-host still can't climb", url: "https://myrient.example/files/a.zip", crossHost: true, want: false},
+		{name: "other port", url: "https://myrient.example:8080/files/No-Intro/NES/a.zip", want: false},
+		{name: "other scheme", url: "ftp://myrient.example/files/No-Intro/NES/a.zip", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scope, err := NewScope("https://myrient.example/files/No-Intro/NES/", Options{AllowCrossHost: tt.crossHost})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := scope.Contains(u); got != tt.want {
+				t.Errorf("Contains(%s) = %v, want %v", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDirectoryListing_StaysInScope(t *testing.T) {
+	html := `<table id="list">
+  <tr><td><a href="../">Parent Directory</a></td><td>-</td></tr>
+  <tr><td><a href="keep.zip">keep.zip</a></td><td>1.0 MiB</td></tr>
+  <tr><td><a href="../up.zip">up.zip</a></td><td>1.0 MiB</td></tr>
+  <tr><td><a href="/root.zip">root.zip</a></td><td>1.0 MiB</td></tr>
+  <tr><td><a href="https://mirror.example/files/Sys/mirror.zip">mirror.zip</a></td><td>1.0 MiB</td></tr>
+</table>`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/files/Sys/" {
+			http.Redirect(w, r, "/files/Sys/", http.StatusMovedPermanently)
+			return
+		}
+		_, _ = w.Write([]byte(html))
+	}))
+	defer server.Close()
+
+	names := func(files []FileInfo) string {
+		var out []string
+		for _, f := range files {
+			out = append(out, f.Name)
+		}
+		return strings.Join(out, ",")
+	}
+
+	// A missing trailing slash is fixed by the server's redirect
+	files, err := ParseDirectoryListing(context.Background(), server.URL+"/files/Sys", Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := names(files); got != "keep.zip" {
+		t.Errorf("expected only keep.zip, got %s", got)
+	}
+
+	files, err = ParseDirectoryListing(context.Background(), server.URL+"/files/Sys/", Options{AllowCrossHost: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := names(files); got != "keep.zip,mirror.zip" {
+		t.Errorf("expected keep.zip and mirror.zip, got %s", got)
+	}
+}
+
+func TestParseDirectoryListing_RedirectOffHost(t *testing.T) {
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`<table id="list"><tr><td><a href="a.zip">a.zip</a></td><td>1 KiB</td></tr></table>`))
+	}))
+	defer mirror.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, mirror.URL+"/files/Sys/", http.StatusFound)
+	}))
+	defer server.Close()
+
+	if _, err := ParseDirectoryListing(context.Background(), server.URL+"/files/Sys/", Options{}); err == nil {
+		t.Error("expected error for a listing redirected to another host")
+	}
+
+	files, err := ParseDirectoryListing(context.Background(), server.URL+"/files/Sys/", Options{AllowCrossHost: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 1 || files[0].URL != mirror.URL+"/files/Sys/a.zip" {
+		t.Errorf("expected a.zip from the mirror, got %+v", files)
+	}
+}
+
+func TestFetchListing_Validators(t *testing.T) {
+	html := `<html><body><table id="list">
+<tr><td><a href="a.zip">a.zip</a></td><td>1.0 KiB</td></tr>
+</table></body></html>`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("ETag", `"abc123"`)
+		w.Header().Set("Last-Modified", "Wed, 01 May 2024 12:00:00 GMT")
+		_, _ = w.Write([]byte(html))
+	}))
+	defer server.Close()
+
+	listing, err := FetchListing(context.Background(), server.URL+"/", Options{})
+	if err != nil {
+		t.Fatalf("FetchListing failed: %v", err)
+	}
+	if listing.ETag != `"abc123"` || listing.LastModified != "Wed, 01 May 2024 12:00:00 GMT" || listing.URL != server.URL+"/" {
+		t.Errorf("unexpected listing validators %+v", listing)
+	}
+	if len(listing.Files) != 1 || listing.Files[0].Name != "a.zip" {
+		t.Errorf("unexpected files %+v", listing.Files)
+	}
+}
+
+func TestFetchListing_Identity(t *testing.T) {
+	var userAgent, from string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent, from = r.Header.Get("User-Agent"), r.Header.Get("From")
+		_, _ = w.Write([]byte(`<table id="list"><tr><td><a href="a.zip">a.zip</a></td></tr></table>`))
+	}))
+	defer server.Close()
+
+	id := useragent.Identity{Contact: "you@example.com"}
+	if _, err := FetchListing(context.Background(), server.URL+"/", Options{Identity: id}); err != nil {
+		t.Fatalf("FetchListing failed: %v", err)
+	}
+	if userAgent != id.UserAgent() || from != "you@example.com" {
+		t.Errorf("expected User-Agent %q and From you@example.com, got %q and %q", id.UserAgent(), userAgent, from)
+	}
+}
+
+func TestFetchListing_Recursive(t *testing.T) {
+	pages := map[string]string{
+		"/root/":                 `<a href="../">Parent</a><a href="a.zip">a.zip</a><a href="Disc%201/">Disc 1/</a><a href="/root/">loop</a><a href="/other/">other/</a>`,
+		"/root/Disc%201/":        `<a href="b.zip">b.zip</a><a href="deeper/">deeper/</a>`,
+		"/root/Disc%201/deeper/": `<a href="c.zip">c.zip</a>`,
+		"/other/":                `<a href="d.zip">d.zip</a>`,
+	}
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.EscapedPath())
+		page, ok := pages[r.URL.EscapedPath()]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`<table id="list"><tr><td>` + page + `</td></tr></table>`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		opts     Options
+		expected []string
+	}{
+		{"flat", Options{}, []string{"a.zip"}},
+		{"recursive", Options{Recursive: true}, []string{"a.zip", "Disc 1/b.zip", "Disc 1/deeper/c.zip"}},
+		{"max depth", Options{Recursive: true, MaxDepth: 1}, []string{"a.zip", "Disc 1/b.zip"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = nil
+			listing, err := FetchListing(context.Background(), server.URL+"/root/", tt.opts)
+			if err != nil {
+				t.Fatalf("FetchListing failed: %v", err)
+			}
+			var names []string
+			for _, f := range listing.Files {
+				names = append(names, f.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("expected %v, got %v", tt.expected, names)
+			}
+			for _, r := range requests {
+				if r == "/other/" {
+					t.Error("expected directories outside the listing not to be entered")
+				}
+			}
+		})
+	}
+}
+
+func TestFetchListing_UnsafeNames(t *testing.T) {
+	// A hostile listing whose link texts would be saved outside the output directory
+	pages := map[string]string{
+		"/root/": `<a href="good.zip">good.zip</a>` +
+			`<a href="x">../../etc/cron.d/x</a>` +
+			`<a href="y">..\..\Windows\evil.dll</a>` +
+			`<a href="z">/etc/passwd</a>` +
+			`<a href="dots">..</a>` +
+			`<a href="Sub/">Sub/</a>`,
+		"/root/Sub/": `<a href="ok.zip">ok.zip</a><a href="w">../../w</a>`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`<table id="list"><tr><td>` + page + `</td></tr></table>`))
+	}))
+	defer server.Close()
+
+	listing, err := FetchListing(context.Background(), server.URL+"/root/", Options{Recursive: true})
+	if err != nil {
+		t.Fatalf("FetchListing failed: %v", err)
+	}
+	var names []string
+	for _, f := range listing.Files {
+		names = append(names, f.Name)
+	}
+	if got := strings.Join(names, ","); got != "good.zip,Sub/ok.zip" {
+		t.Errorf("expected only the safe names, got %s", got)
+	}
+	expected := []string{"../../etc/cron.d/x", `..\..\Windows\evil.dll`, "/etc/passwd", "..", "Sub/../../w"}
+	if strings.Join(listing.Unsafe, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected unsafe names %v, got %v", expected, listing.Unsafe)
+	}
+}
+
+func TestFetchListing_RecursiveError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/root/" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`<table id="list"><tr><td><a href="sub/">sub/</a></td></tr></table>`))
+	}))
+	defer server.Close()
+
+	_, err := FetchListing(context.Background(), server.URL+"/root/", Options{Recursive: true})
+	if err == nil || !strings.Contains(err.Error(), "/root/sub/") {
+		t.Errorf("expected an error naming the failed subdirectory, got %v", err)
+	}
+}
+
+func TestRelativeDir(t *testing.T) {
+	root, _ := url.Parse("https://example.com/files/No-Intro/")
+	tests := []struct {
+		dir      string
+		expected string
+	}{
+		{"https://example.com/files/No-Intro/Nintendo%20-%20NES/", "Nintendo - NES"},
+		{"https://example.com/files/No-Intro/a/b/", "a/b"},
+		{"https://example.com/files/No-Intro/.hidden/", "hidden"},
+		{"https://example.com/files/No-Intro/a%3Ab/", "a_b"},
+	}
+	for _, tt := range tests {
+		dir, _ := url.Parse(tt.dir)
+		if got := relativeDir(root, dir); got != tt.expected {
+			t.Errorf("relativeDir(%q) = %q, want %q", tt.dir, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/auth"
+	"github.com/nchapman/myrient-dl/internal/retry"
+	"github.com/nchapman/myrient-dl/internal/useragent"
+)
+
+// Options controls how far listing links may be followed
+type Options struct {
+	// AllowCrossHost keeps links to other hosts, e.g. mirror redirect pages.
+	// Links on the starting host must still stay below the starting path.
+	AllowCrossHost bool
+	// Auth, if set, adds credentials to the listing request
+	Auth auth.Provider
+	// Client fetches the listing pages, e.g. one with the downloader's
+	// connect timeout; nil uses http.DefaultClient
+	Client *http.Client
+	// StallTimeout fails a page that makes no progress, neither a response
+	// nor body bytes, for this long, so a server that accepts the connection
+	// and never answers is retried; 0 uses a minute and a negative value
+	// disables it
+	StallTimeout time.Duration
+	// Identity sets the listing request's User-Agent, From, and extra headers
+	Identity useragent.Identity
+	// Recursive also lists subdirectories, down to MaxDepth levels below the
+	// starting listing (0 for no limit)
+	Recursive bool
+	MaxDepth  int
+	// OnListing, if set, is called after each listing page is parsed
+	OnListing func(pageURL string, files int)
+	// Retry is how pages failing with a network error or a 408, 429, or 5xx
+	// status are tried again; the zero value tries each page once
+	Retry retry.Policy
+	// OnRetry, if set, is called before each retry with the error and the wait
+	OnRetry func(pageURL string, attempt int, delay time.Duration, err error)
+}
+
+// Scope decides whether a URL lies within a crawl started at a listing URL
+type Scope struct {
+	host           string
+	prefix         string
+	allowCrossHost bool
+}
+
+// NewScope returns the scope of a crawl starting at startURL: its host and
+// everything at or below its directory
+func NewScope(startURL string, opts Options) (Scope, error) {
+	u, err := url.Parse(startURL)
+	if err != nil {
+		return Scope{}, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	prefix := path.Clean("/" + u.Path)
+	if prefix != "/" {
+		prefix += "/"
+	}
+	return Scope{host: strings.ToLower(u.Host), prefix: prefix, allowCrossHost: opts.AllowCrossHost}, nil
+}
+
+// Contains reports whether u may be followed
+func (s Scope) Contains(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	if !strings.EqualFold(u.Host, s.host) {
+		return s.allowCrossHost
+	}
+
+	// Compare the cleaned, unescaped path so "sub/../../" or "%2e%2e/" can't climb above the prefix
+	p := path.Clean("/" + u.Path)
+	return p+"/" == s.prefix || strings.HasPrefix(p, s.prefix)
+}
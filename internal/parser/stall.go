@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// defaultStallTimeout is how long a listing page may go without progress
+// when Options leaves StallTimeout at zero
+const defaultStallTimeout = time.Minute
+
+// errStalled is a listing page that stopped arriving; it's retried like a
+// network error
+var errStalled = errors.New("listing stalled")
+
+// client returns Options.Client, or the default client without one
+func (o Options) client() *http.Client {
+	if o.Client != nil {
+		return o.Client
+	}
+	return http.DefaultClient
+}
+
+// stallTimeout returns Options.StallTimeout with its default; 0 when disabled
+func (o Options) stallTimeout() time.Duration {
+	switch {
+	case o.StallTimeout < 0:
+		return 0
+	case o.StallTimeout == 0:
+		return defaultStallTimeout
+	default:
+		return o.StallTimeout
+	}
+}
+
+// idleTimer cancels a page request once it has gone a stall period without
+// progress: no response yet, or no body bytes since the last read. Its
+// methods do nothing on a nil timer, for a disabled stall timeout.
+type idleTimer struct {
+	timer  *time.Timer
+	period time.Duration
+	fired  atomic.Bool
+}
+
+// watchIdle returns a context for a page request that is cancelled once the
+// request goes idle for period, and the timer to report progress to
+func watchIdle(ctx context.Context, period time.Duration) (context.Context, *idleTimer, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	if period <= 0 {
+		return ctx, nil, cancel
+	}
+	t := &idleTimer{period: period}
+	t.timer = time.AfterFunc(period, func() {
+		t.fired.Store(true)
+		cancel()
+	})
+	return ctx, t, func() {
+		t.timer.Stop()
+		cancel()
+	}
+}
+
+// progress restarts the stall period
+func (t *idleTimer) progress() {
+	if t != nil {
+		t.timer.Reset(t.period)
+	}
+}
+
+// stalled reports whether the request was cancelled for going idle
+func (t *idleTimer) stalled() bool {
+	return t != nil && t.fired.Load()
+}
+
+// body wraps a response body so each read that delivers bytes counts as progress
+func (t *idleTimer) body(r io.Reader) io.Reader {
+	if t == nil {
+		return r
+	}
+	return &idleReader{Reader: r, timer: t}
+}
+
+type idleReader struct {
+	io.Reader
+	timer *idleTimer
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.timer.progress()
+	}
+	return n, err
+}
@@ -0,0 +1,97 @@
+package picker
+
+import "unicode/utf8"
+
+// KeyCode identifies a key press
+type KeyCode int
+
+// Keys the picker responds to
+const (
+	KeyRune      KeyCode = iota // A printable character, in Key.Rune
+	KeyUp                       // Up arrow, ctrl-p
+	KeyDown                     // Down arrow, ctrl-n
+	KeyPageUp                   // Page up
+	KeyPageDown                 // Page down
+	KeyHome                     // Home
+	KeyEnd                      // End
+	KeyToggle                   // Space, tab
+	KeyToggleAll                // Ctrl-a
+	KeyBackspace                // Backspace
+	KeyEnter                    // Enter
+	KeyEscape                   // Esc
+	KeyInterrupt                // Ctrl-c, ctrl-d
+	keyUnknown
+)
+
+// Key is a decoded key press
+type Key struct {
+	Code KeyCode
+	Rune rune
+}
+
+// escapes are the escape sequences terminals send for special keys
+var escapes = map[string]KeyCode{
+	"\x1b[A": KeyUp, "\x1bOA": KeyUp,
+	"\x1b[B": KeyDown, "\x1bOB": KeyDown,
+	"\x1b[5~": KeyPageUp, "\x1b[6~": KeyPageDown,
+	"\x1b[H": KeyHome, "\x1bOH": KeyHome, "\x1b[1~": KeyHome, "\x1b[7~": KeyHome,
+	"\x1b[F": KeyEnd, "\x1bOF": KeyEnd, "\x1b[4~": KeyEnd, "\x1b[8~": KeyEnd,
+}
+
+// DecodeKeys splits raw terminal input into key presses. A lone escape byte
+// at the end of the input is the Esc key; unrecognized sequences are dropped.
+func DecodeKeys(input []byte) []Key {
+	var keys []Key
+	for len(input) > 0 {
+		if input[0] == 0x1b {
+			n, code := decodeEscape(input)
+			if code != keyUnknown {
+				keys = append(keys, Key{Code: code})
+			}
+			input = input[n:]
+			continue
+		}
+
+		r, size := utf8.DecodeRune(input)
+		input = input[size:]
+		switch r {
+		case '\r', '\n':
+			keys = append(keys, Key{Code: KeyEnter})
+		case ' ', '\t':
+			keys = append(keys, Key{Code: KeyToggle})
+		case 0x7f, 0x08:
+			keys = append(keys, Key{Code: KeyBackspace})
+		case 0x01:
+			keys = append(keys, Key{Code: KeyToggleAll})
+		case 0x03, 0x04:
+			keys = append(keys, Key{Code: KeyInterrupt})
+		case 0x10:
+			keys = append(keys, Key{Code: KeyUp})
+		case 0x0e:
+			keys = append(keys, Key{Code: KeyDown})
+		default:
+			if r >= 0x20 && r != utf8.RuneError {
+				keys = append(keys, Key{Code: KeyRune, Rune: r})
+			}
+		}
+	}
+	return keys
+}
+
+// decodeEscape decodes the escape sequence at the start of input, returning
+// its length
+func decodeEscape(input []byte) (int, KeyCode) {
+	if len(input) == 1 || (input[1] != '[' && input[1] != 'O') {
+		return 1, KeyEscape
+	}
+	// CSI and SS3 sequences end with a byte in 0x40-0x7e
+	for n := 2; n < len(input); n++ {
+		if input[n] >= 0x40 && input[n] <= 0x7e {
+			if code, ok := escapes[string(input[:n+1])]; ok {
+				return n + 1, code
+			}
+			return n + 1, keyUnknown
+		}
+	}
+	return len(input), keyUnknown
+}
@@ -0,0 +1,233 @@
+// Package picker is a terminal checkbox list with fuzzy search, for choosing
+// files by hand. The list state is a plain Model updated by decoded keys, so
+// it can be tested without a terminal; Run drives it on a raw-mode terminal.
+package picker
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrCancelled means the picker was closed without confirming
+var ErrCancelled = errors.New("selection cancelled")
+
+// Item is one entry of the list
+type Item struct {
+	Label  string // Matched by the search, e.g. a file name
+	Detail string // Shown after the label, e.g. its size
+	Weight int64  // Summed over selected items in the status line, e.g. bytes
+}
+
+// Model is the picker's state
+type Model struct {
+	Title   string
+	Items   []Item
+	Checked []bool
+	Query   string
+	// FormatWeight formats the total weight of the checked items; nil hides it
+	FormatWeight func(int64) string
+
+	visible []int // Indexes of the items matching Query, in order
+	cursor  int   // Position in visible
+	offset  int   // First visible row drawn
+	done    bool
+	err     error
+}
+
+// NewModel returns a model with every item shown and none checked
+func NewModel(title string, items []Item) *Model {
+	m := &Model{Title: title, Items: items, Checked: make([]bool, len(items))}
+	m.filter()
+	return m
+}
+
+// Selected returns the indexes of the checked items, in order
+func (m *Model) Selected() []int {
+	var picked []int
+	for i, c := range m.Checked {
+		if c {
+			picked = append(picked, i)
+		}
+	}
+	return picked
+}
+
+// Done reports whether the picker was confirmed or cancelled, and the error
+// if it was cancelled
+func (m *Model) Done() (bool, error) {
+	return m.done, m.err
+}
+
+// Update applies a key press
+func (m *Model) Update(k Key) {
+	switch k.Code {
+	case KeyUp:
+		m.move(-1)
+	case KeyDown:
+		m.move(1)
+	case KeyPageUp:
+		m.move(-pageSize)
+	case KeyPageDown:
+		m.move(pageSize)
+	case KeyHome:
+		m.move(-len(m.visible))
+	case KeyEnd:
+		m.move(len(m.visible))
+	case KeyToggle:
+		if len(m.visible) > 0 {
+			i := m.visible[m.cursor]
+			m.Checked[i] = !m.Checked[i]
+			m.move(1)
+		}
+	case KeyToggleAll:
+		// Check all shown items, or uncheck them if they already are
+		all := true
+		for _, i := range m.visible {
+			all = all && m.Checked[i]
+		}
+		for _, i := range m.visible {
+			m.Checked[i] = !all
+		}
+	case KeyBackspace:
+		if m.Query != "" {
+			_, size := utf8.DecodeLastRuneInString(m.Query)
+			m.Query = m.Query[:len(m.Query)-size]
+			m.filter()
+		}
+	case KeyRune:
+		m.Query += string(k.Rune)
+		m.filter()
+	case KeyEnter:
+		m.done = true
+	case KeyEscape:
+		if m.Query != "" {
+			m.Query = ""
+			m.filter()
+			return
+		}
+		m.done, m.err = true, ErrCancelled
+	case KeyInterrupt:
+		m.done, m.err = true, ErrCancelled
+	}
+}
+
+// pageSize is how far page up and page down move
+const pageSize = 10
+
+func (m *Model) move(delta int) {
+	m.cursor = max(0, min(m.cursor+delta, len(m.visible)-1))
+}
+
+// filter recomputes the items matching the query, keeping the cursor on the
+// same item when it's still shown
+func (m *Model) filter() {
+	current := -1
+	if m.cursor < len(m.visible) {
+		current = m.visible[m.cursor]
+	}
+	m.visible = m.visible[:0]
+	m.cursor = 0
+	for i, item := range m.Items {
+		if fuzzyMatch(m.Query, item.Label) {
+			if i == current {
+				m.cursor = len(m.visible)
+			}
+			m.visible = append(m.visible, i)
+		}
+	}
+}
+
+// fuzzyMatch reports whether the query's characters appear in label in order,
+// ignoring case and spaces, so "smw" finds "Super Mario World"
+func fuzzyMatch(query, label string) bool {
+	rest := strings.ToLower(label)
+	for _, r := range strings.ToLower(query) {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		i := strings.IndexRune(rest, r)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+utf8.RuneLen(r):]
+	}
+	return true
+}
+
+// View renders the picker for a terminal of the given size, one string per row
+func (m *Model) View(width, height int) []string {
+	rows := []string{
+		m.Title,
+		"space/tab toggle · ctrl-a all shown · enter confirm · esc clear/cancel",
+		"> " + m.Query,
+	}
+	listHeight := max(height-len(rows)-1, 1)
+
+	// Keep the cursor in view
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+listHeight {
+		m.offset = m.cursor - listHeight + 1
+	}
+	m.offset = max(0, min(m.offset, len(m.visible)-listHeight))
+
+	for row := m.offset; row < m.offset+listHeight && row < len(m.visible); row++ {
+		i := m.visible[row]
+		pointer, box := "  ", "[ ]"
+		if row == m.cursor {
+			pointer = "> "
+		}
+		if m.Checked[i] {
+			box = "[x]"
+		}
+		line := fmt.Sprintf("%s%s %s", pointer, box, m.Items[i].Label)
+		if m.Items[i].Detail != "" {
+			line += "  " + m.Items[i].Detail
+		}
+		rows = append(rows, line)
+	}
+	for len(rows) < height-1 {
+		rows = append(rows, "")
+	}
+	rows = append(rows, m.status())
+
+	for i, row := range rows {
+		rows[i] = truncate(row, width)
+	}
+	return rows
+}
+
+// status is the bottom line: how many items are shown and checked
+func (m *Model) status() string {
+	picked := m.Selected()
+	status := fmt.Sprintf("%d/%d shown · %d selected", len(m.visible), len(m.Items), len(picked))
+	if m.FormatWeight != nil {
+		var total int64
+		for _, i := range picked {
+			total += m.Items[i].Weight
+		}
+		status += " (" + m.FormatWeight(total) + ")"
+	}
+	return status
+}
+
+// truncate cuts a row to fit the terminal, leaving the last column free since
+// writing it wraps on some terminals
+func truncate(s string, width int) string {
+	limit := width - 1
+	if limit < 1 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	runes := []rune(s)
+	if limit == 1 {
+		return string(runes[:1])
+	}
+	return string(runes[:limit-1]) + "…"
+}
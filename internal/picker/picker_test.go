@@ -0,0 +1,129 @@
+package picker
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func items(labels ...string) []Item {
+	var list []Item
+	for _, l := range labels {
+		list = append(list, Item{Label: l, Weight: 10})
+	}
+	return list
+}
+
+// press applies raw terminal input to a model
+func press(m *Model, input string) {
+	for _, k := range DecodeKeys([]byte(input)) {
+		m.Update(k)
+	}
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		query, label string
+		want         bool
+	}{
+		{"", "anything", true},
+		{"smw", "Super Mario World (USA).zip", true},
+		{"mario usa", "Super Mario World (USA).zip", true},
+		{"USA", "super mario world (usa).zip", true},
+		{"wms", "Super Mario World (USA).zip", false},
+		{"zelda", "Super Mario World (USA).zip", false},
+		{"pokémon", "Pokémon Red (USA).zip", true},
+	}
+	for _, tt := range tests {
+		if got := fuzzyMatch(tt.query, tt.label); got != tt.want {
+			t.Errorf("fuzzyMatch(%q, %q) = %v, want %v", tt.query, tt.label, got, tt.want)
+		}
+	}
+}
+
+func TestModel(t *testing.T) {
+	labels := []string{"Super Mario World (USA).zip", "Super Mario World (Europe).zip", "Zelda (USA).zip", "Metroid (USA).zip"}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []int
+		wantErr bool
+	}{
+		{"nothing checked", "\r", nil, false},
+		{"toggle moves down", "  \r", []int{0, 1}, false},
+		{"navigate with arrows", "\x1b[B\x1b[B\t\x1b[A\x1b[A\t\r", []int{1, 2}, false},
+		{"toggle twice unchecks", " \x1b[A \r", nil, false},
+		{"search then check all shown", "usa\x01\r", []int{0, 2, 3}, false},
+		{"check all twice unchecks", "\x01\x01\r", nil, false},
+		{"checks survive a new search", "zelda \x7f\x7f\x7f\x7f\x7fmetroid \r", []int{2, 3}, false},
+		{"escape clears the search, keeping the cursor", "zelda\x1b \r", []int{2}, false},
+		{"escape cancels", " \x1b", nil, true},
+		{"ctrl-c cancels", " \x03", nil, true},
+		{"end and home", "\x1b[F \x1b[H \r", []int{0, 3}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewModel("Pick", items(labels...))
+			press(m, tt.input)
+			done, err := m.Done()
+			if !done {
+				t.Fatal("expected the picker to be done")
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := m.Selected(); !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v checked, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestModel_View(t *testing.T) {
+	var labels []string
+	for i := range 30 {
+		labels = append(labels, fmt.Sprintf("Game %02d (USA).zip", i))
+	}
+	m := NewModel("Pick files", items(labels...))
+	m.FormatWeight = func(n int64) string { return fmt.Sprintf("%d B", n) }
+	press(m, strings.Repeat("\x1b[B", 20)+" ")
+
+	rows := m.View(30, 10)
+	if len(rows) != 10 {
+		t.Fatalf("expected 10 rows, got %d", len(rows))
+	}
+	for _, row := range rows {
+		if len([]rune(row)) > 29 {
+			t.Errorf("row %q is wider than the terminal", row)
+		}
+	}
+	// The cursor follows the toggle down to row 21, which scrolls into view
+	if !strings.Contains(strings.Join(rows, "\n"), "> [ ] Game 21") {
+		t.Errorf("expected the cursor row in view, got\n%s", strings.Join(rows, "\n"))
+	}
+	if got := rows[len(rows)-1]; got != "30/30 shown · 1 selected (10…" {
+		t.Errorf("unexpected status %q", got)
+	}
+}
+
+func TestDecodeKeys(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []Key
+	}{
+		{"ab", []Key{{Code: KeyRune, Rune: 'a'}, {Code: KeyRune, Rune: 'b'}}},
+		{"\x1b[A\x1bOB", []Key{{Code: KeyUp}, {Code: KeyDown}}},
+		{"\x1b[5~\x1b[6~", []Key{{Code: KeyPageUp}, {Code: KeyPageDown}}},
+		{"\x1b", []Key{{Code: KeyEscape}}},
+		{"\x1b[1;5C", nil}, // Ctrl-right: dropped
+		{"é\r", []Key{{Code: KeyRune, Rune: 'é'}, {Code: KeyEnter}}},
+		{"\x7f\x03", []Key{{Code: KeyBackspace}, {Code: KeyInterrupt}}},
+	}
+	for _, tt := range tests {
+		if got := DecodeKeys([]byte(tt.input)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("DecodeKeys(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
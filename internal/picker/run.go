@@ -0,0 +1,79 @@
+package picker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// ErrNotTerminal means the picker can't run because input or output isn't a terminal
+var ErrNotTerminal = errors.New("the picker needs an interactive terminal")
+
+// CheckTerminal reports ErrNotTerminal unless both in and out are terminals
+func CheckTerminal(in, out *os.File) error {
+	if !term.IsTerminal(int(in.Fd())) || !term.IsTerminal(int(out.Fd())) { //nolint:gosec // File descriptors fit in an int
+		return ErrNotTerminal
+	}
+	return nil
+}
+
+// Run shows the picker on the terminal until it's confirmed, returning the
+// indexes of the checked items, or ErrCancelled. The terminal is restored
+// when it returns.
+func Run(m *Model, in, out *os.File) ([]int, error) {
+	if err := CheckTerminal(in, out); err != nil {
+		return nil, err
+	}
+	inFd, outFd := int(in.Fd()), int(out.Fd()) //nolint:gosec // File descriptors fit in an int
+	state, err := term.MakeRaw(inFd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up terminal: %w", err)
+	}
+	defer func() { _ = term.Restore(inFd, state) }()
+
+	// Draw on the alternate screen so the list doesn't linger in scrollback
+	_, _ = io.WriteString(out, "\x1b[?1049h")
+	defer func() { _, _ = io.WriteString(out, "\x1b[?1049l") }()
+
+	buf := make([]byte, 256)
+	for {
+		width, height, err := term.GetSize(outFd)
+		if err != nil || width <= 0 || height <= 0 {
+			width, height = 80, 24 // Not reported, e.g. by some serial consoles
+		}
+		draw(out, m.View(width, height))
+
+		n, err := in.Read(buf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read from terminal: %w", err)
+		}
+		for _, k := range DecodeKeys(buf[:n]) {
+			m.Update(k)
+			if done, err := m.Done(); done {
+				if err != nil {
+					return nil, err
+				}
+				return m.Selected(), nil
+			}
+		}
+	}
+}
+
+// draw repaints the screen; raw mode needs explicit carriage returns
+func draw(out io.Writer, rows []string) {
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, row := range rows {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(row)
+		b.WriteString("\x1b[K")
+	}
+	b.WriteString("\x1b[J")
+	_, _ = io.WriteString(out, b.String())
+}
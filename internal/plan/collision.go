@@ -0,0 +1,140 @@
+package plan
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/nchapman/myrient-dl/internal/fsutil"
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// CollisionPolicy decides what happens when several remote files map to the same local path
+type CollisionPolicy string
+
+// Supported collision policies
+const (
+	CollisionRename    CollisionPolicy = "rename"    // Keep every file, suffixing later ones with " (2)", " (3)", ...
+	CollisionSkip      CollisionPolicy = "skip"      // Keep the first file and drop the rest
+	CollisionOverwrite CollisionPolicy = "overwrite" // Keep the last file and drop the earlier ones
+	CollisionError     CollisionPolicy = "error"     // Refuse to continue
+	CollisionAsk       CollisionPolicy = "ask"       // Let the user decide per collision
+)
+
+// ParseCollisionPolicy parses a collision policy name as accepted on the command line
+func ParseCollisionPolicy(s string) (CollisionPolicy, error) {
+	switch p := CollisionPolicy(strings.ToLower(s)); p {
+	case CollisionRename, CollisionSkip, CollisionOverwrite, CollisionError, CollisionAsk:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown collision policy %q (expected rename, skip, overwrite, error, or ask)", s)
+	}
+}
+
+// Collision records remote files that would overwrite each other locally
+type Collision struct {
+	Names    []string // Remote names in listing order
+	Resolved []string // Local names after applying the policy (empty for dropped files)
+}
+
+// CollisionChooser decides what happens to incoming, a file whose local name is
+// already held by kept. It returns rename, skip (drop incoming), overwrite (drop
+// kept), or error.
+type CollisionChooser func(kept, incoming parser.FileInfo) CollisionPolicy
+
+// ResolveCollisions finds files whose names collide once sanitized and folded for
+// case-insensitive filesystems, and applies the policy to them. CollisionAsk
+// needs a chooser; use ResolveCollisionsFunc.
+func ResolveCollisions(files []parser.FileInfo, policy CollisionPolicy) ([]parser.FileInfo, []Collision, error) {
+	return ResolveCollisionsFunc(files, func(parser.FileInfo, parser.FileInfo) CollisionPolicy { return policy })
+}
+
+// ResolveCollisionsFunc is ResolveCollisions with the policy chosen per colliding file
+func ResolveCollisionsFunc(files []parser.FileInfo, choose CollisionChooser) ([]parser.FileInfo, []Collision, error) {
+	groups := make(map[string][]int)
+	var order []string
+	for i, f := range files {
+		key := fsutil.CollisionKey(f.Name)
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	var collisions []Collision
+	drop := make(map[int]bool)
+	renamed := make(map[int]string)
+	taken := make(map[string]bool, len(groups))
+	for key := range groups {
+		taken[key] = true
+	}
+
+	refused := false
+	for _, key := range order {
+		indexes := groups[key]
+		if len(indexes) < 2 {
+			continue
+		}
+
+		holder := indexes[0] // The file that currently gets the name
+		for _, i := range indexes[1:] {
+			switch choose(files[holder], files[i]) {
+			case CollisionRename:
+				renamed[i] = uniqueName(files[i].Name, taken)
+			case CollisionOverwrite:
+				drop[holder] = true
+				holder = i
+			case CollisionError:
+				refused = true
+				drop[i] = true
+			default:
+				drop[i] = true
+			}
+		}
+
+		c := Collision{}
+		for _, i := range indexes {
+			c.Names = append(c.Names, files[i].Name)
+			switch {
+			case drop[i]:
+				c.Resolved = append(c.Resolved, "")
+			case renamed[i] != "":
+				c.Resolved = append(c.Resolved, renamed[i])
+			default:
+				c.Resolved = append(c.Resolved, files[i].Name)
+			}
+		}
+		collisions = append(collisions, c)
+	}
+
+	if refused {
+		return nil, collisions, fmt.Errorf("%d local filename collisions detected", len(collisions))
+	}
+
+	resolved := make([]parser.FileInfo, 0, len(files))
+	for i, f := range files {
+		if drop[i] {
+			continue
+		}
+		if name, ok := renamed[i]; ok {
+			f.Name = name
+		}
+		resolved = append(resolved, f)
+	}
+
+	return resolved, collisions, nil
+}
+
+// uniqueName suffixes a name with " (n)" before its extension until it no longer collides
+func uniqueName(name string, taken map[string]bool) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, n, ext)
+		key := fsutil.CollisionKey(candidate)
+		if !taken[key] {
+			taken[key] = true
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package plan
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nchapman/myrient-dl/internal/naming"
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// DuplicatePolicy decides what happens when several files share a base title
+type DuplicatePolicy string
+
+// Supported duplicate policies
+const (
+	DuplicateAll   DuplicatePolicy = "all"   // Keep every variant
+	DuplicateFirst DuplicatePolicy = "first" // Keep the first variant in listing order
+	DuplicateAsk   DuplicatePolicy = "ask"   // Let the user choose per title
+)
+
+// ParseDuplicatePolicy parses a duplicate policy name as accepted on the command line
+func ParseDuplicatePolicy(s string) (DuplicatePolicy, error) {
+	switch p := DuplicatePolicy(strings.ToLower(s)); p {
+	case DuplicateAll, DuplicateFirst, DuplicateAsk:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown duplicate policy %q (expected all, first, or ask)", s)
+	}
+}
+
+// Duplicate is a group of files that share a base title, such as regional variants
+type Duplicate struct {
+	Title string
+	Files []parser.FileInfo // In listing order
+}
+
+// FindDuplicates groups files by base title and returns the groups with more than one file
+func FindDuplicates(files []parser.FileInfo) []Duplicate {
+	return FindDuplicatesFunc(files, naming.Describe)
+}
+
+// FindDuplicatesFunc is FindDuplicates with the name metadata supplied by
+// describe, such as a cache of an already parsed listing
+func FindDuplicatesFunc(files []parser.FileInfo, describe func(string) naming.Metadata) []Duplicate {
+	groups := make(map[string]int)
+	var all []Duplicate
+	for _, f := range files {
+		meta := describe(f.Name)
+		i, ok := groups[meta.TitleKey]
+		if !ok {
+			i = len(all)
+			groups[meta.TitleKey] = i
+			all = append(all, Duplicate{Title: meta.Title})
+		}
+		all[i].Files = append(all[i].Files, f)
+	}
+
+	var duplicates []Duplicate
+	for _, d := range all {
+		if len(d.Files) > 1 {
+			duplicates = append(duplicates, d)
+		}
+	}
+	return duplicates
+}
+
+// ResolveDuplicates keeps, for every duplicate group, only the files choose returns.
+// Files without duplicates are kept untouched and listing order is preserved.
+func ResolveDuplicates(files []parser.FileInfo, choose func(Duplicate) []parser.FileInfo) []parser.FileInfo {
+	return ResolveDuplicatesFunc(files, naming.Describe, choose)
+}
+
+// ResolveDuplicatesFunc is ResolveDuplicates with the name metadata supplied by describe
+func ResolveDuplicatesFunc(files []parser.FileInfo, describe func(string) naming.Metadata, choose func(Duplicate) []parser.FileInfo) []parser.FileInfo {
+	drop := make(map[string]bool)
+	for _, d := range FindDuplicatesFunc(files, describe) {
+		keep := make(map[string]bool)
+		for _, f := range choose(d) {
+			keep[f.URL] = true
+		}
+		for _, f := range d.Files {
+			if !keep[f.URL] {
+				drop[f.URL] = true
+			}
+		}
+	}
+
+	resolved := make([]parser.FileInfo, 0, len(files))
+	for _, f := range files {
+		if !drop[f.URL] {
+			resolved = append(resolved, f)
+		}
+	}
+	return resolved
+}
+
+// KeepFirst is a duplicate chooser that keeps the first variant of each title
+func KeepFirst(d Duplicate) []parser.FileInfo {
+	return d.Files[:1]
+}
@@ -0,0 +1,47 @@
+package plan
+
+import (
+	"os"
+
+	"github.com/nchapman/myrient-dl/internal/cleanup"
+	"github.com/nchapman/myrient-dl/internal/fsutil"
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// Local is how much of a planned file the output directory already has
+type Local string
+
+// Local file states
+const (
+	LocalMissing   Local = "missing"
+	LocalPartial   Local = "partial"   // Only an interrupted download's temp file
+	LocalComplete  Local = "complete"  // At about the listed size (exactly it when exact), or any size when it isn't listed
+	LocalDifferent Local = "different" // At another size than listed
+)
+
+// CheckLocal reports what dir already has of each file, by name and listed
+// size alone, allowing for listed sizes being rounded unless they are exact. It tells what a
+// download would skip without asking the server, which the downloader still
+// checks each file's size with.
+func CheckLocal(dir string, files []parser.FileInfo) []Local {
+	states := make([]Local, len(files))
+	for i, f := range files {
+		states[i] = LocalMissing
+		path, err := fsutil.Within(dir, f.Name)
+		if err != nil {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			if f.SizeMatches(info.Size()) {
+				states[i] = LocalComplete
+			} else {
+				states[i] = LocalDifferent
+			}
+			continue
+		}
+		if _, err := os.Stat(path + cleanup.TempSuffix); err == nil {
+			states[i] = LocalPartial
+		}
+	}
+	return states
+}
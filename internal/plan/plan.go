@@ -0,0 +1,254 @@
+// Package plan freezes a resolved file selection so it can be executed later.
+package plan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/checksums"
+	"github.com/nchapman/myrient-dl/internal/fsutil"
+	"github.com/nchapman/myrient-dl/internal/hashing"
+	"github.com/nchapman/myrient-dl/internal/parser"
+	"github.com/nchapman/myrient-dl/internal/units"
+)
+
+// FormatVersion is the current plan file format version
+const FormatVersion = 1
+
+// Plan is a frozen selection of files to download
+type Plan struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Source    string    `json:"source"`
+	OutputDir string    `json:"output_dir"`
+	Files     []Entry   `json:"files"`
+
+	// Variants lists the titles --prefer-smallest chose a format for
+	Variants []FormatVariant `json:"variants,omitempty"`
+}
+
+// Entry is a single planned download
+type Entry struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	Size int64  `json:"size"`
+	Path string `json:"path"` // Destination relative to the output directory
+	// SHA1 is the digest the listing published when the plan was made; apply
+	// verifies the download against it
+	SHA1 string `json:"sha1,omitempty"`
+	// Exact is set when Size came from the server rather than the listing
+	Exact bool `json:"exact,omitempty"`
+
+	Collection string `json:"collection,omitempty"`
+	System     string `json:"system,omitempty"`
+}
+
+// Change describes how a planned file differs from the current remote listing
+type Change struct {
+	Name   string
+	Reason string
+}
+
+// New creates a plan for downloading files from the source listing into outputDir
+func New(source, outputDir string, files []parser.FileInfo) *Plan {
+	p := &Plan{
+		Version:   FormatVersion,
+		CreatedAt: time.Now().UTC(),
+		Source:    source,
+		OutputDir: outputDir,
+		Files:     make([]Entry, 0, len(files)),
+	}
+
+	for _, f := range files {
+		p.Files = append(p.Files, Entry{
+			Name: f.Name,
+			URL:  f.URL,
+			Size: f.Size,
+			Path: f.Name,
+
+			Exact:      f.Exact,
+			Collection: f.Collection,
+			System:     f.System,
+		})
+	}
+
+	return p
+}
+
+// Load reads a plan file from disk
+func Load(path string) (*Plan, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // Plan path is provided by the user
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+
+	var p Plan
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %w", err)
+	}
+
+	if p.Version > FormatVersion {
+		return nil, fmt.Errorf("plan format version %d is newer than supported version %d", p.Version, FormatVersion)
+	}
+	for _, e := range p.Files {
+		if _, err := fsutil.Within(p.OutputDir, e.Path); err != nil {
+			return nil, fmt.Errorf("invalid plan: %w", err)
+		}
+		if _, err := hex.DecodeString(e.SHA1); err != nil || e.SHA1 != "" && len(e.SHA1) != 40 {
+			return nil, fmt.Errorf("invalid plan: %s has a malformed sha1 %q", e.Name, e.SHA1)
+		}
+	}
+
+	return &p, nil
+}
+
+// Save writes the plan to disk atomically
+func (p *Plan) Save(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to temp file and rename so a crash never leaves a truncated plan
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, append(data, '\n'), 0644); err != nil { //nolint:gosec // Plan files are not sensitive
+		return fmt.Errorf("failed to write plan: %w", err)
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to write plan: %w", err)
+	}
+
+	return nil
+}
+
+// FileInfos returns the planned files in the form the downloader consumes
+func (p *Plan) FileInfos() []parser.FileInfo {
+	files := make([]parser.FileInfo, 0, len(p.Files))
+	for _, e := range p.Files {
+		files = append(files, parser.FileInfo{
+			Name: filepath.FromSlash(e.Path),
+			URL:  e.URL,
+			Size: e.Size,
+
+			Exact:      e.Exact,
+			Collection: e.Collection,
+			System:     e.System,
+		})
+	}
+	return files
+}
+
+// Pin records the published SHA-1 digest of each planned file that has one,
+// returning how many were pinned. Other algorithms aren't recorded.
+func (p *Plan) Pin(sums *checksums.Set) int {
+	pinned := 0
+	for i, e := range p.Files {
+		sum, ok := sums.Lookup(e.Name)
+		if !ok || sum.Algorithm != hashing.SHA1 {
+			continue
+		}
+		p.Files[i].SHA1 = sum.Digest
+		pinned++
+	}
+	return pinned
+}
+
+// Checksums returns the pinned digests keyed by the names FileInfos gives the
+// files, or nil if nothing is pinned
+func (p *Plan) Checksums() *checksums.Set {
+	var set *checksums.Set
+	for _, e := range p.Files {
+		if e.SHA1 == "" {
+			continue
+		}
+		if set == nil {
+			set = checksums.NewSet()
+		}
+		set.Put(filepath.FromSlash(e.Path), checksums.Sum{Algorithm: hashing.SHA1, Digest: strings.ToLower(e.SHA1), Source: "plan"})
+	}
+	return set
+}
+
+// Hash identifies a resolved selection: the listing it came from, the filters
+// that chose it, and its files in any order. Runs with the same hash would
+// download the same files.
+func Hash(source string, filters []string, files []parser.FileInfo) string {
+	lines := make([]string, 0, len(files))
+	for _, f := range files {
+		lines = append(lines, fmt.Sprintf("%s\t%s\t%d", f.URL, filepath.ToSlash(f.Name), f.Size))
+	}
+	slices.Sort(lines)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", source, strings.Join(filters, "\x00"))
+	for _, line := range lines {
+		fmt.Fprintln(h, line)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// TotalSize returns the sum of all planned file sizes
+func (p *Plan) TotalSize() int64 {
+	var total int64
+	for _, e := range p.Files {
+		total += e.Size
+	}
+	return total
+}
+
+// Listings returns the distinct directory listing URLs the planned files come from
+func (p *Plan) Listings() []string {
+	var listings []string
+	seen := make(map[string]bool)
+	for _, e := range p.Files {
+		u, err := url.Parse(e.URL)
+		if err != nil {
+			continue
+		}
+		u.Path = path.Dir(u.Path) + "/"
+		u.RawPath = ""
+		listing := u.String()
+		if !seen[listing] {
+			seen[listing] = true
+			listings = append(listings, listing)
+		}
+	}
+	return listings
+}
+
+// Drift compares the plan against the current remote listing and reports files
+// that disappeared or changed size since the plan was made. Exact sizes only
+// count as changed when the listing's rounded size no longer fits them.
+func (p *Plan) Drift(current []parser.FileInfo) []Change {
+	byURL := make(map[string]parser.FileInfo, len(current))
+	for _, f := range current {
+		byURL[f.URL] = f
+	}
+
+	var changes []Change
+	for _, e := range p.Files {
+		f, ok := byURL[e.URL]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Name: e.Name, Reason: "no longer listed"})
+		case e.Exact && !units.Plausible(f.Size, e.Size), !e.Exact && f.Size != e.Size:
+			changes = append(changes, Change{
+				Name:   e.Name,
+				Reason: fmt.Sprintf("size changed from %d to %d bytes", e.Size, f.Size),
+			})
+		}
+	}
+
+	return changes
+}
@@ -0,0 +1,533 @@
+package plan
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nchapman/myrient-dl/internal/checksums"
+	"github.com/nchapman/myrient-dl/internal/fsutil"
+	"github.com/nchapman/myrient-dl/internal/hashing"
+	"github.com/nchapman/myrient-dl/internal/naming"
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+func testFiles() []parser.FileInfo {
+	return []parser.FileInfo{
+		{Name: "mario.zip", URL: "https://example.com/files/mario.zip", Size: 1000, Collection: "No-Intro", System: "Nintendo - NES"},
+		{Name: "sonic.zip", URL: "https://example.com/files/sonic.zip", Size: 2000},
+		{Name: "zelda.zip", URL: "https://example.com/files/zelda.zip", Size: 3000},
+	}
+}
+
+func TestNew(t *testing.T) {
+	p := New("https://example.com/files/", "./files", testFiles())
+
+	if p.Version != FormatVersion {
+		t.Errorf("expected version %d, got %d", FormatVersion, p.Version)
+	}
+	if len(p.Files) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(p.Files))
+	}
+	if p.Files[1].Path != "sonic.zip" {
+		t.Errorf("expected destination sonic.zip, got %s", p.Files[1].Path)
+	}
+	if p.TotalSize() != 6000 {
+		t.Errorf("expected total size 6000, got %d", p.TotalSize())
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plan.json")
+
+	original := New("https://example.com/files/", "./files", testFiles())
+	if err := original.Save(path); err != nil {
+		t.Fatalf("failed to save plan: %v", err)
+	}
+
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Error("expected temp file to be renamed away")
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("failed to load plan: %v", err)
+	}
+
+	if loaded.Source != original.Source || loaded.OutputDir != original.OutputDir {
+		t.Errorf("expected %s -> %s, got %s -> %s", original.Source, original.OutputDir, loaded.Source, loaded.OutputDir)
+	}
+	if len(loaded.Files) != len(original.Files) {
+		t.Fatalf("expected %d files, got %d", len(original.Files), len(loaded.Files))
+	}
+	for i := range loaded.Files {
+		if loaded.Files[i] != original.Files[i] {
+			t.Errorf("entry %d: expected %+v, got %+v", i, original.Files[i], loaded.Files[i])
+		}
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := Load(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("expected error for missing plan")
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(invalid); err == nil {
+		t.Error("expected error for invalid plan")
+	}
+
+	future := filepath.Join(dir, "future.json")
+	if err := os.WriteFile(future, []byte(`{"version": 99}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(future); err == nil {
+		t.Error("expected error for newer plan version")
+	}
+
+	escaping := filepath.Join(dir, "escaping.json")
+	if err := os.WriteFile(escaping, []byte(`{"version": 1, "files": [{"name": "x", "url": "https://example.com/x", "path": "../../etc/cron.d/x"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(escaping); !errors.Is(err, fsutil.ErrUnsafePath) {
+		t.Errorf("expected ErrUnsafePath for a path outside the output directory, got %v", err)
+	}
+
+	malformed := filepath.Join(dir, "malformed.json")
+	if err := os.WriteFile(malformed, []byte(`{"version": 1, "files": [{"name": "x", "url": "https://example.com/x", "path": "x", "sha1": "abc"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(malformed); err == nil {
+		t.Error("expected error for a malformed sha1")
+	}
+}
+
+func TestPin(t *testing.T) {
+	sums := checksums.NewSet()
+	sums.Put("mario.zip", checksums.Sum{Algorithm: hashing.SHA1, Digest: "da39a3ee5e6b4b0d3255bfef95601890afd80709"})
+	sums.Put("sonic.zip", checksums.Sum{Algorithm: hashing.MD5, Digest: "d41d8cd98f00b204e9800998ecf8427e"})
+
+	p := New("https://example.com/files/", "./files", testFiles())
+	if p.Checksums() != nil {
+		t.Error("expected no checksums before pinning")
+	}
+	if n := p.Pin(sums); n != 1 {
+		t.Fatalf("expected 1 pinned digest, got %d", n)
+	}
+	if p.Files[0].SHA1 != "da39a3ee5e6b4b0d3255bfef95601890afd80709" || p.Files[1].SHA1 != "" {
+		t.Errorf("expected only the SHA-1 digest pinned, got %+v", p.Files)
+	}
+	if p.Pin(nil) != 0 {
+		t.Error("expected nothing pinned without published checksums")
+	}
+
+	path := filepath.Join(t.TempDir(), "plan.json")
+	if err := p.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, ok := loaded.Checksums().Lookup(loaded.FileInfos()[0].Name)
+	if !ok || sum.Algorithm != hashing.SHA1 || sum.Digest != p.Files[0].SHA1 {
+		t.Errorf("expected the pinned digest for the planned file, got %+v", sum)
+	}
+	if _, ok := loaded.Checksums().Lookup("sonic.zip"); ok {
+		t.Error("expected no digest for an unpinned file")
+	}
+}
+
+func TestFileInfos(t *testing.T) {
+	p := New("https://example.com/files/", "./files", testFiles())
+	files := p.FileInfos()
+
+	if len(files) != 3 {
+		t.Fatalf("expected 3 files, got %d", len(files))
+	}
+	if files[2].URL != "https://example.com/files/zelda.zip" || files[2].Size != 3000 {
+		t.Errorf("unexpected file %+v", files[2])
+	}
+	if files[0].Exact {
+		t.Error("expected listed sizes not to be marked exact")
+	}
+	exact := testFiles()
+	exact[1].Exact = true
+	if !New("https://example.com/files/", "./files", exact).FileInfos()[1].Exact {
+		t.Error("expected an exact size to survive the plan")
+	}
+	if files[0].SystemLabel() != "No-Intro / Nintendo - NES" {
+		t.Errorf("expected system metadata to survive the plan, got %q", files[0].SystemLabel())
+	}
+}
+
+func TestHash(t *testing.T) {
+	source := "https://example.com/files/"
+	filters := []string{"--include=*.zip"}
+	base := Hash(source, filters, testFiles())
+
+	reordered := testFiles()
+	reordered[0], reordered[2] = reordered[2], reordered[0]
+	if got := Hash(source, filters, reordered); got != base {
+		t.Errorf("expected file order not to matter, got %s and %s", got, base)
+	}
+
+	resized := testFiles()
+	resized[1].Size++
+	tests := []struct {
+		name    string
+		source  string
+		filters []string
+		files   []parser.FileInfo
+	}{
+		{"other source", "https://example.com/other/", filters, testFiles()},
+		{"other filters", source, []string{"--include=*.7z"}, testFiles()},
+		{"fewer files", source, filters, testFiles()[:2]},
+		{"changed size", source, filters, resized},
+	}
+	for _, tt := range tests {
+		if Hash(tt.source, tt.filters, tt.files) == base {
+			t.Errorf("%s: expected a different hash", tt.name)
+		}
+	}
+}
+
+func TestDrift(t *testing.T) {
+	p := New("https://example.com/files/", "./files", testFiles())
+
+	t.Run("unchanged", func(t *testing.T) {
+		if changes := p.Drift(testFiles()); len(changes) != 0 {
+			t.Errorf("expected no drift, got %+v", changes)
+		}
+	})
+
+	t.Run("removed and resized", func(t *testing.T) {
+		current := []parser.FileInfo{
+			{Name: "mario.zip", URL: "https://example.com/files/mario.zip", Size: 1000},
+			{Name: "sonic.zip", URL: "https://example.com/files/sonic.zip", Size: 2500},
+			{Name: "new.zip", URL: "https://example.com/files/new.zip", Size: 10},
+		}
+
+		changes := p.Drift(current)
+		if len(changes) != 2 {
+			t.Fatalf("expected 2 changes, got %+v", changes)
+		}
+		if changes[0].Name != "sonic.zip" || changes[1].Name != "zelda.zip" {
+			t.Errorf("unexpected changes %+v", changes)
+		}
+		if changes[1].Reason != "no longer listed" {
+			t.Errorf("unexpected reason %q", changes[1].Reason)
+		}
+	})
+
+	t.Run("exact sizes", func(t *testing.T) {
+		exact := testFiles()
+		exact[0].Size, exact[0].Exact = 1013, true
+		exact[1].Size, exact[1].Exact = 9000, true
+		changes := New("https://example.com/files/", "./files", exact).Drift(testFiles())
+		if len(changes) != 1 || changes[0].Name != "sonic.zip" {
+			t.Errorf("expected only the exact size the listing no longer fits to drift, got %+v", changes)
+		}
+	})
+}
+
+func TestListings(t *testing.T) {
+	files := append(testFiles(), parser.FileInfo{
+		Name: "[BIOS] PS1.zip",
+		URL:  "https://example.com/files/Sony%20-%20BIOS%20Images/%5BBIOS%5D%20PS1.zip",
+	})
+	p := New("https://example.com/files/", "./files", files)
+
+	listings := p.Listings()
+	expected := []string{
+		"https://example.com/files/",
+		"https://example.com/files/Sony%20-%20BIOS%20Images/",
+	}
+	if len(listings) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, listings)
+	}
+	for i := range expected {
+		if listings[i] != expected[i] {
+			t.Errorf("expected %s, got %s", expected[i], listings[i])
+		}
+	}
+}
+
+func TestCheckLocal(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, size int) {
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("done.zip", 10)
+	write("changed.zip", 5000)
+	write("unsized.zip", 3)
+	write("partial.zip.tmp", 4)
+
+	files := []parser.FileInfo{
+		{Name: "done.zip", Size: 10},
+		{Name: "changed.zip", Size: 10000},
+		{Name: "unsized.zip"},
+		{Name: "partial.zip", Size: 10},
+		{Name: "new.zip", Size: 10},
+		{Name: "../escape.zip", Size: 10},
+		{Name: "done.zip", Size: 12, Exact: true}, // Within rounding, but exact sizes aren't rounded
+	}
+	want := []Local{LocalComplete, LocalDifferent, LocalComplete, LocalPartial, LocalMissing, LocalMissing, LocalDifferent}
+	got := CheckLocal(dir, files)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s: got %s, want %s", files[i].Name, got[i], want[i])
+		}
+	}
+}
+
+func TestParseCollisionPolicy(t *testing.T) {
+	if p, err := ParseCollisionPolicy("SKIP"); err != nil || p != CollisionSkip {
+		t.Errorf("expected skip, got %s (err %v)", p, err)
+	}
+	if p, err := ParseCollisionPolicy("ask"); err != nil || p != CollisionAsk {
+		t.Errorf("expected ask, got %s (err %v)", p, err)
+	}
+	if _, err := ParseCollisionPolicy("clobber"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
+
+func collidingFiles() []parser.FileInfo {
+	return []parser.FileInfo{
+		{Name: "Game (USA).zip", URL: "https://example.com/a"},
+		{Name: "game (usa).zip", URL: "https://example.com/b"},
+		{Name: "Other: Game.zip", URL: "https://example.com/c"},
+		{Name: "Other_ Game.zip", URL: "https://example.com/d"},
+		{Name: "Unique.zip", URL: "https://example.com/e"},
+		{Name: "Game (USA) (2).zip", URL: "https://example.com/f"},
+	}
+}
+
+func TestResolveCollisions(t *testing.T) {
+	t.Run("rename", func(t *testing.T) {
+		files, collisions, err := ResolveCollisions(collidingFiles(), CollisionRename)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(collisions) != 2 {
+			t.Fatalf("expected 2 collisions, got %+v", collisions)
+		}
+		if len(files) != 6 {
+			t.Fatalf("expected all 6 files to be kept, got %d", len(files))
+		}
+		// "Game (USA) (2).zip" is already taken by a listed file, so the rename skips it
+		if files[1].Name != "game (usa) (3).zip" {
+			t.Errorf("expected rename to game (usa) (3).zip, got %s", files[1].Name)
+		}
+		if files[3].Name != "Other_ Game (2).zip" {
+			t.Errorf("expected rename to Other_ Game (2).zip, got %s", files[3].Name)
+		}
+		if files[1].URL != "https://example.com/b" {
+			t.Errorf("expected URL to be preserved, got %s", files[1].URL)
+		}
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		files, collisions, err := ResolveCollisions(collidingFiles(), CollisionSkip)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(collisions) != 2 || len(files) != 4 {
+			t.Fatalf("expected 2 collisions and 4 files, got %d and %d", len(collisions), len(files))
+		}
+		if collisions[0].Resolved[1] != "" {
+			t.Errorf("expected dropped file to have no resolved name, got %q", collisions[0].Resolved[1])
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, collisions, err := ResolveCollisions(collidingFiles(), CollisionError)
+		if err == nil {
+			t.Fatal("expected error for collisions")
+		}
+		if len(collisions) != 2 {
+			t.Errorf("expected collisions to be reported, got %+v", collisions)
+		}
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		files, collisions, err := ResolveCollisions(collidingFiles(), CollisionOverwrite)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(collisions) != 2 || len(files) != 4 {
+			t.Fatalf("expected 2 collisions and 4 files, got %d and %d", len(collisions), len(files))
+		}
+		if files[0].URL != "https://example.com/b" {
+			t.Errorf("expected the later file to win, got %s", files[0].URL)
+		}
+		if collisions[0].Resolved[0] != "" || collisions[0].Resolved[1] != "game (usa).zip" {
+			t.Errorf("unexpected resolution %+v", collisions[0].Resolved)
+		}
+	})
+
+	t.Run("chooser", func(t *testing.T) {
+		var asked []string
+		files, _, err := ResolveCollisionsFunc(collidingFiles(), func(kept, incoming parser.FileInfo) CollisionPolicy {
+			asked = append(asked, kept.Name+" <- "+incoming.Name)
+			if strings.HasPrefix(incoming.Name, "game") {
+				return CollisionRename
+			}
+			return CollisionSkip
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(asked) != 2 || asked[1] != "Other: Game.zip <- Other_ Game.zip" {
+			t.Errorf("unexpected questions %v", asked)
+		}
+		if len(files) != 5 || files[1].Name != "game (usa) (3).zip" {
+			t.Errorf("expected one rename and one skip, got %+v", files)
+		}
+	})
+
+	t.Run("no collisions", func(t *testing.T) {
+		files, collisions, err := ResolveCollisions(testFiles(), CollisionError)
+		if err != nil || len(collisions) != 0 || len(files) != 3 {
+			t.Errorf("expected files to pass through untouched, got %v %v %v", files, collisions, err)
+		}
+	})
+}
+
+func variantFiles() []parser.FileInfo {
+	return []parser.FileInfo{
+		{Name: "Sonic (USA).zip", URL: "https://example.com/1"},
+		{Name: "Mario (World).zip", URL: "https://example.com/2"},
+		{Name: "Sonic (Europe).zip", URL: "https://example.com/3"},
+		{Name: "sonic (Japan) (Rev 1).zip", URL: "https://example.com/4"},
+		{Name: "Zelda (USA).zip", URL: "https://example.com/5"},
+		{Name: "Zelda (Europe).zip", URL: "https://example.com/6"},
+	}
+}
+
+func TestParseDuplicatePolicy(t *testing.T) {
+	if p, err := ParseDuplicatePolicy("Ask"); err != nil || p != DuplicateAsk {
+		t.Errorf("expected ask, got %s (err %v)", p, err)
+	}
+	if _, err := ParseDuplicatePolicy("newest"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	duplicates := FindDuplicates(variantFiles())
+	if len(duplicates) != 2 {
+		t.Fatalf("expected 2 duplicate groups, got %+v", duplicates)
+	}
+	if duplicates[0].Title != "Sonic" || len(duplicates[0].Files) != 3 {
+		t.Errorf("unexpected first group %+v", duplicates[0])
+	}
+	if duplicates[1].Title != "Zelda" || len(duplicates[1].Files) != 2 {
+		t.Errorf("unexpected second group %+v", duplicates[1])
+	}
+}
+
+func TestFindDuplicatesFunc(t *testing.T) {
+	// Metadata comes from describe only, e.g. a cache built for the listing
+	calls := 0
+	describe := func(name string) naming.Metadata {
+		calls++
+		return naming.Metadata{Title: "All", TitleKey: "all"}
+	}
+	duplicates := FindDuplicatesFunc(variantFiles(), describe)
+	if len(duplicates) != 1 || len(duplicates[0].Files) != len(variantFiles()) {
+		t.Errorf("expected one group of every file, got %+v", duplicates)
+	}
+	if calls != len(variantFiles()) {
+		t.Errorf("expected describe once per file, got %d calls", calls)
+	}
+}
+
+func TestResolveDuplicates(t *testing.T) {
+	t.Run("first", func(t *testing.T) {
+		files := ResolveDuplicates(variantFiles(), KeepFirst)
+		got := make([]string, 0, len(files))
+		for _, f := range files {
+			got = append(got, f.Name)
+		}
+		expected := []string{"Sonic (USA).zip", "Mario (World).zip", "Zelda (USA).zip"}
+		if len(got) != len(expected) {
+			t.Fatalf("expected %v, got %v", expected, got)
+		}
+		for i := range expected {
+			if got[i] != expected[i] {
+				t.Errorf("expected %v, got %v", expected, got)
+			}
+		}
+	})
+
+	t.Run("custom chooser", func(t *testing.T) {
+		files := ResolveDuplicates(variantFiles(), func(d Duplicate) []parser.FileInfo {
+			if d.Title == "Sonic" {
+				return d.Files[1:] // Europe and Japan
+			}
+			return d.Files
+		})
+		if len(files) != 5 || files[0].Name != "Mario (World).zip" {
+			t.Errorf("unexpected result %+v", files)
+		}
+	})
+}
+
+func TestPreferSmallest(t *testing.T) {
+	files := []parser.FileInfo{
+		{Name: "Sonic (USA).zip", URL: "http://x/zip/Sonic (USA).zip", Size: 300},
+		{Name: "Mario (World).zip", URL: "http://x/zip/Mario (World).zip", Size: 200},
+		{Name: "sonic (usa).7z", URL: "http://x/7z/sonic (usa).7z", Size: 250},
+		{Name: "Zelda (USA).zip", URL: "http://x/zip/Zelda (USA).zip", Size: 100},
+		{Name: "Zelda (USA).7z", URL: "http://x/7z/Zelda (USA).7z", Size: 100},
+		{Name: "Metroid (USA).zip", URL: "http://x/zip/Metroid (USA).zip", Size: 0},
+		{Name: "Metroid (USA).7z", URL: "http://x/7z/Metroid (USA).7z", Size: 50},
+		{Name: "Readme.txt", URL: "http://x/zip/Readme.txt", Size: 10},
+		{Name: "Readme.txt", URL: "http://x/7z/Readme.txt", Size: 5},
+	}
+
+	kept, variants := PreferSmallest(files)
+	var got []string
+	for _, f := range kept {
+		got = append(got, f.URL)
+	}
+	expected := []string{
+		"http://x/zip/Mario (World).zip",
+		"http://x/7z/sonic (usa).7z",
+		"http://x/zip/Zelda (USA).zip", // Ties keep the earlier file
+		"http://x/zip/Metroid (USA).zip",
+		"http://x/7z/Metroid (USA).7z", // An unknown size leaves the group alone
+		"http://x/zip/Readme.txt",
+		"http://x/7z/Readme.txt", // Not compressed
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, got)
+			break
+		}
+	}
+
+	if len(variants) != 2 {
+		t.Fatalf("expected 2 variants, got %+v", variants)
+	}
+	if v := variants[0]; v.Kept != "http://x/7z/sonic (usa).7z" || len(v.Dropped) != 1 || v.Dropped[0] != "http://x/zip/Sonic (USA).zip" || v.Saved != 50 {
+		t.Errorf("unexpected first variant %+v", v)
+	}
+	if v := variants[1]; v.Kept != "http://x/zip/Zelda (USA).zip" || v.Saved != 0 {
+		t.Errorf("unexpected second variant %+v", v)
+	}
+}
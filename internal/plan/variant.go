@@ -0,0 +1,97 @@
+package plan
+
+import (
+	"path"
+	"strings"
+
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// compressedExtensions are the container formats the same dump is offered in
+var compressedExtensions = []string{".zip", ".7z", ".rar", ".gz", ".xz", ".bz2", ".zst"}
+
+// FormatVariant records a title offered in several compressed formats and the
+// smallest one that was kept
+type FormatVariant struct {
+	Kept    string   `json:"kept"`    // URL of the file kept
+	Dropped []string `json:"dropped"` // URLs of the larger formats left out
+	Saved   int64    `json:"saved"`   // Bytes saved against the largest format
+}
+
+// variantKey is a file's name without its compression extension, ignoring
+// case, or "" if it isn't a compressed file
+func variantKey(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	for _, e := range compressedExtensions {
+		if ext == e {
+			return strings.ToLower(strings.TrimSuffix(name, path.Ext(name)))
+		}
+	}
+	return ""
+}
+
+// PreferSmallest keeps only the smallest of the files that are the same title
+// in different compressed formats, such as "Game.zip" and "Game.7z" from
+// sibling directories; ties go to the earlier file. Groups with a file of
+// unknown size are left alone. It returns the files kept, in order, and the
+// choices made.
+func PreferSmallest(files []parser.FileInfo) ([]parser.FileInfo, []FormatVariant) {
+	groups := make(map[string][]int)
+	var keys []string
+	for i, f := range files {
+		key := variantKey(slashName(f.Name))
+		if key == "" {
+			continue
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	drop := make(map[int]bool)
+	var variants []FormatVariant
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) < 2 || !knownSizes(files, group) {
+			continue
+		}
+		best, largest := group[0], files[group[0]].Size
+		for _, i := range group[1:] {
+			if files[i].Size < files[best].Size {
+				best = i
+			}
+			largest = max(largest, files[i].Size)
+		}
+		v := FormatVariant{Kept: files[best].URL, Saved: largest - files[best].Size}
+		for _, i := range group {
+			if i != best {
+				drop[i] = true
+				v.Dropped = append(v.Dropped, files[i].URL)
+			}
+		}
+		variants = append(variants, v)
+	}
+
+	kept := make([]parser.FileInfo, 0, len(files)-len(drop))
+	for i, f := range files {
+		if !drop[i] {
+			kept = append(kept, f)
+		}
+	}
+	return kept, variants
+}
+
+// slashName turns a local name into the slash-separated form used for grouping
+func slashName(name string) string {
+	return strings.ReplaceAll(name, "\\", "/")
+}
+
+func knownSizes(files []parser.FileInfo, group []int) bool {
+	for _, i := range group {
+		if files[i].Size <= 0 {
+			return false
+		}
+	}
+	return true
+}
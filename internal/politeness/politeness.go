@@ -0,0 +1,93 @@
+// Package politeness holds per-host limits on how hard myrient-dl may press a
+// server: how many connections at once, how often requests start, and how
+// long to back off after errors. Profiles for well-known archives are built
+// in and apply automatically; the config file can replace them or add more.
+package politeness
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Profile limits requests to one host. Zero fields don't limit anything.
+type Profile struct {
+	// MaxParallel caps simultaneous connections: parallel downloads times segments
+	MaxParallel int `yaml:"max_parallel"`
+	// RequestInterval is the least time between the start of two requests
+	RequestInterval time.Duration `yaml:"request_interval"`
+	// BackoffBase and BackoffMax are the least backoff after a failed request
+	// and the least it may grow to
+	BackoffBase time.Duration `yaml:"backoff_base"`
+	BackoffMax  time.Duration `yaml:"backoff_max"`
+}
+
+// Builtin are the profiles shipped for known archives, keyed by host. They are
+// conservative: a handful of connections and unhurried requests, well inside
+// what each archive asks of bulk downloaders.
+var Builtin = map[string]Profile{
+	"myrient.erista.me": {MaxParallel: 4, RequestInterval: 250 * time.Millisecond, BackoffBase: 2 * time.Second, BackoffMax: time.Minute},
+	"archive.org":       {MaxParallel: 3, RequestInterval: time.Second, BackoffBase: 5 * time.Second, BackoffMax: 5 * time.Minute},
+}
+
+// Validate checks that no limit is negative
+func (p Profile) Validate() error {
+	if p.MaxParallel < 0 || p.RequestInterval < 0 || p.BackoffBase < 0 || p.BackoffMax < 0 {
+		return fmt.Errorf("limits can't be negative")
+	}
+	return nil
+}
+
+// String describes the limits, e.g. for verbose output
+func (p Profile) String() string {
+	var parts []string
+	if p.MaxParallel > 0 {
+		parts = append(parts, fmt.Sprintf("at most %d connections", p.MaxParallel))
+	}
+	if p.RequestInterval > 0 {
+		parts = append(parts, fmt.Sprintf("requests %v apart", p.RequestInterval))
+	}
+	if p.BackoffBase > 0 || p.BackoffMax > 0 {
+		parts = append(parts, fmt.Sprintf("backoff from %v up to %v", p.BackoffBase, p.BackoffMax))
+	}
+	if len(parts) == 0 {
+		return "no limits"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// For returns the profile for host (without a port) and the name it is
+// listed under: a configured override if one matches, otherwise a built-in
+// profile. A profile for "archive.org" also covers subdomains such as
+// "ia800.us.archive.org"; the most specific name wins.
+func For(host string, overrides map[string]Profile) (Profile, string, bool) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, profiles := range []map[string]Profile{overrides, Builtin} {
+		var best string
+		var found Profile
+		for name, p := range profiles {
+			name = strings.ToLower(name)
+			if (host == name || strings.HasSuffix(host, "."+name)) && len(name) > len(best) {
+				best, found = name, p
+			}
+		}
+		if best != "" {
+			return found, best, true
+		}
+	}
+	return Profile{}, "", false
+}
+
+// Strictest combines profiles into one that satisfies all of them
+func Strictest(profiles ...Profile) Profile {
+	var s Profile
+	for _, p := range profiles {
+		if p.MaxParallel > 0 && (s.MaxParallel == 0 || p.MaxParallel < s.MaxParallel) {
+			s.MaxParallel = p.MaxParallel
+		}
+		s.RequestInterval = max(s.RequestInterval, p.RequestInterval)
+		s.BackoffBase = max(s.BackoffBase, p.BackoffBase)
+		s.BackoffMax = max(s.BackoffMax, p.BackoffMax)
+	}
+	return s
+}
@@ -0,0 +1,57 @@
+package politeness
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFor(t *testing.T) {
+	overrides := map[string]Profile{
+		"Mirror.Example.org": {MaxParallel: 8},
+		"archive.org":        {MaxParallel: 6},
+	}
+
+	tests := []struct {
+		host     string
+		name     string
+		parallel int
+		found    bool
+	}{
+		{"myrient.erista.me", "myrient.erista.me", 4, true},
+		{"MYRIENT.erista.me.", "myrient.erista.me", 4, true},
+		{"ia800.us.archive.org", "archive.org", 6, true}, // Overridden
+		{"mirror.example.org", "mirror.example.org", 8, true},
+		{"example.org", "", 0, false},
+		{"notarchive.org", "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			p, name, found := For(tt.host, overrides)
+			if found != tt.found || name != tt.name || p.MaxParallel != tt.parallel {
+				t.Errorf("For(%q) = %+v, %q, %v; want %d connections from %q", tt.host, p, name, found, tt.parallel, tt.name)
+			}
+		})
+	}
+
+	if p, _, _ := For("ia800.us.archive.org", nil); p != Builtin["archive.org"] {
+		t.Errorf("expected the built-in profile without overrides, got %+v", p)
+	}
+}
+
+func TestStrictest(t *testing.T) {
+	got := Strictest(
+		Profile{MaxParallel: 4, RequestInterval: time.Second},
+		Profile{BackoffBase: 5 * time.Second, BackoffMax: time.Minute},
+		Profile{MaxParallel: 2, RequestInterval: 100 * time.Millisecond, BackoffMax: 5 * time.Minute},
+	)
+	want := Profile{MaxParallel: 2, RequestInterval: time.Second, BackoffBase: 5 * time.Second, BackoffMax: 5 * time.Minute}
+	if got != want {
+		t.Errorf("Strictest = %+v, want %+v", got, want)
+	}
+	if (Profile{MaxParallel: -1}).Validate() == nil {
+		t.Error("expected a negative limit to be invalid")
+	}
+	if s := (Profile{}).String(); s != "no limits" {
+		t.Errorf("String() = %q", s)
+	}
+}
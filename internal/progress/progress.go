@@ -0,0 +1,222 @@
+// Package progress draws download progress bars. The full bar needs a UTF-8
+// terminal of reasonable width; on narrow terminals and non-UTF-8 locales a
+// plain ASCII line is drawn instead, sized to the terminal as it is resized,
+// so long runs don't wrap garbage across lines. When stderr isn't a terminal,
+// as under cron, no bars are drawn at all.
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+	"golang.org/x/term"
+)
+
+// MinWidth is the narrowest terminal the full progress bar is drawn on
+const MinWidth = 60
+
+// fallbackWidth is assumed when the terminal size can't be read
+const fallbackWidth = 80
+
+// Bar tracks the bytes of one download
+type Bar interface {
+	io.Writer
+	// Set64 sets the progress, e.g. to the bytes already on disk
+	Set64(n int64) error
+}
+
+// Reporter makes the bars downloads report their progress to
+type Reporter interface {
+	// New returns a bar for a download of total bytes, or of unknown size
+	// when total is negative
+	New(total int64, description string) Bar
+}
+
+// Terminal draws bars on stderr with New
+type Terminal struct{}
+
+// New returns a bar drawn on stderr
+func (Terminal) New(total int64, description string) Bar {
+	return New(total, description)
+}
+
+// Silent draws nothing, for --quiet runs and output that isn't a terminal
+type Silent struct{}
+
+// New returns a bar that discards its progress
+func (Silent) New(int64, string) Bar {
+	return silentBar{}
+}
+
+type silentBar struct{}
+
+func (silentBar) Write(p []byte) (int, error) { return len(p), nil }
+func (silentBar) Set64(int64) error           { return nil }
+
+// Default returns Terminal when stderr is a terminal, and Silent when it's
+// redirected to a file or pipe, where carriage-return redraws pile up
+func Default() Reporter {
+	if !term.IsTerminal(int(os.Stderr.Fd())) { //nolint:gosec // File descriptors fit in an int
+		return Silent{}
+	}
+	return Terminal{}
+}
+
+var (
+	width     atomic.Int64 // Current terminal width, kept up to date on resize
+	watchOnce sync.Once
+	utf8      = utf8Locale(os.Getenv, runtime.GOOS)
+)
+
+// New returns a bar for a download of total bytes, or of unknown size when
+// total is negative. Bars are drawn to stderr.
+func New(total int64, description string) Bar {
+	watchOnce.Do(func() {
+		width.Store(int64(measure()))
+		watchResize(func() { width.Store(int64(measure())) })
+	})
+	if !Simple() {
+		return progressbar.DefaultBytes(total, description)
+	}
+	return newASCIIBar(os.Stderr, total, description, func() int { return int(width.Load()) })
+}
+
+// Simple reports whether progress is drawn as a plain ASCII line
+func Simple() bool {
+	return !utf8 || int(width.Load()) < MinWidth
+}
+
+// measure returns the width of the terminal progress is drawn on
+func measure() int {
+	w, _, err := term.GetSize(int(os.Stderr.Fd())) //nolint:gosec // File descriptors fit in an int
+	if err != nil || w <= 0 {
+		return fallbackWidth
+	}
+	return w
+}
+
+// utf8Locale reports whether the locale in the environment uses UTF-8. The
+// first of LC_ALL, LC_CTYPE, and LANG that is set decides, as in setlocale;
+// with none set, only Windows (whose consoles handle UTF-8) is assumed to.
+func utf8Locale(getenv func(string) string, goos string) bool {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if v := getenv(name); v != "" {
+			v = strings.ToLower(v)
+			return strings.Contains(v, "utf-8") || strings.Contains(v, "utf8")
+		}
+	}
+	return goos == "windows"
+}
+
+// asciiBar draws progress as one line of plain ASCII, e.g.
+// "  downloading  42% 12.3M/29.1M 1.2M/s", cut to fit the terminal
+type asciiBar struct {
+	mu          sync.Mutex // Segmented downloads write from several goroutines
+	out         io.Writer
+	total       int64
+	current     int64
+	description string
+	width       func() int
+	start       time.Time
+	drawn       time.Time
+	done        bool
+}
+
+// asciiInterval is how often the ASCII line is redrawn
+const asciiInterval = 250 * time.Millisecond
+
+func newASCIIBar(out io.Writer, total int64, description string, width func() int) *asciiBar {
+	b := &asciiBar{out: out, total: total, description: description, width: width, start: time.Now()}
+	b.draw()
+	return b
+}
+
+func (b *asciiBar) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.current += int64(len(p))
+	b.update()
+	return len(p), nil
+}
+
+// Set64 sets the progress
+func (b *asciiBar) Set64(n int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.current = n
+	b.update()
+	return nil
+}
+
+// update redraws the line if it's due, and ends it once the total is reached;
+// callers must hold b.mu
+func (b *asciiBar) update() {
+	if b.done {
+		return
+	}
+	finished := b.total >= 0 && b.current >= b.total
+	if !finished && time.Since(b.drawn) < asciiInterval {
+		return
+	}
+	b.draw()
+	if finished {
+		b.done = true
+		_, _ = fmt.Fprint(b.out, "\n")
+	}
+}
+
+// draw writes the line over the previous one; callers must hold b.mu
+func (b *asciiBar) draw() {
+	b.drawn = time.Now()
+	_, _ = fmt.Fprint(b.out, "\r"+fit(b.line(), b.width()))
+}
+
+// line is the progress line at its full length
+func (b *asciiBar) line() string {
+	var rate string
+	if elapsed := time.Since(b.start).Seconds(); elapsed > 0 && b.current > 0 {
+		rate = " " + compactBytes(int64(float64(b.current)/elapsed)) + "/s"
+	}
+	if b.total < 0 {
+		return fmt.Sprintf("%s %s%s", b.description, compactBytes(b.current), rate)
+	}
+	percent := 100
+	if b.total > 0 {
+		percent = int(b.current * 100 / b.total)
+	}
+	return fmt.Sprintf("%s %3d%% %s/%s%s", b.description, percent, compactBytes(b.current), compactBytes(b.total), rate)
+}
+
+// fit cuts a line to the terminal width, keeping the numbers at its end over
+// the description, and pads it to clear the rest of a longer previous line
+func fit(line string, width int) string {
+	limit := width - 1 // Writing the last column wraps on some terminals
+	if limit < 1 {
+		return ""
+	}
+	if len(line) > limit {
+		line = line[len(line)-limit:]
+	}
+	return line + strings.Repeat(" ", limit-len(line))
+}
+
+// compactBytes formats a byte count in at most six characters, e.g. "12.3M"
+func compactBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%c", float64(n)/float64(div), "KMGTPE"[exp])
+}
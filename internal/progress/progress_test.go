@@ -0,0 +1,103 @@
+package progress
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestUTF8Locale(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		goos string
+		want bool
+	}{
+		{"UTF-8 LANG", map[string]string{"LANG": "en_US.UTF-8"}, "linux", true},
+		{"utf8 spelling", map[string]string{"LANG": "de_DE.utf8"}, "linux", true},
+		{"C locale", map[string]string{"LANG": "C"}, "linux", false},
+		{"LC_ALL wins", map[string]string{"LC_ALL": "POSIX", "LANG": "en_US.UTF-8"}, "linux", false},
+		{"LC_CTYPE over LANG", map[string]string{"LC_CTYPE": "en_US.UTF-8", "LANG": "C"}, "linux", true},
+		{"unset", map[string]string{}, "linux", false},
+		{"unset on Windows", map[string]string{}, "windows", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(name string) string { return tt.env[name] }
+			if got := utf8Locale(getenv, tt.goos); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		line  string
+		width int
+		want  string
+	}{
+		{"  downloading 42%", 20, "  downloading 42%  "},
+		{"  downloading 42% 1.0M/2.0M", 12, "% 1.0M/2.0M"},
+		{"anything", 1, ""},
+	}
+	for _, tt := range tests {
+		if got := fit(tt.line, tt.width); got != tt.want {
+			t.Errorf("fit(%q, %d) = %q, want %q", tt.line, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestASCIIBar(t *testing.T) {
+	var out bytes.Buffer
+	width := 40
+	b := newASCIIBar(&out, 2048, "  downloading", func() int { return width })
+
+	// Narrowing the terminal takes effect on the next redraw
+	width = 20
+	if _, err := b.Write(make([]byte, 2048)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Write([]byte("x")); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(out.String(), "\r")
+	last := lines[len(lines)-1]
+	if !strings.HasSuffix(last, "\n") || strings.Count(out.String(), "\n") != 1 {
+		t.Errorf("expected the line to end once when complete, got %q", out.String())
+	}
+	if len(strings.TrimSuffix(last, "\n")) != 19 || !strings.Contains(last, "2.0K/2.0K") {
+		t.Errorf("expected a 19 column line with the totals, got %q", last)
+	}
+	for _, r := range out.String() {
+		if r > 127 {
+			t.Fatalf("expected ASCII output, got %q", out.String())
+		}
+	}
+}
+
+func TestCompactBytes(t *testing.T) {
+	tests := map[int64]string{
+		512:             "512B",
+		1536:            "1.5K",
+		12_900_000:      "12.3M",
+		5 * (1 << 40):   "5.0T",
+		1<<30 - 1<<20*5: "1019.0M",
+	}
+	for n, want := range tests {
+		if got := compactBytes(n); got != want {
+			t.Errorf("compactBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestSilent(t *testing.T) {
+	bar := Silent{}.New(100, "  downloading")
+	if n, err := bar.Write(make([]byte, 40)); n != 40 || err != nil {
+		t.Errorf("expected writes to be accepted, got %d, %v", n, err)
+	}
+	if err := bar.Set64(100); err != nil {
+		t.Errorf("expected Set64 to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !unix
+
+package progress
+
+// watchResize does nothing where there is no resize signal; the width
+// measured at startup is kept
+func watchResize(func()) {}
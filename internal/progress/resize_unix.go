@@ -0,0 +1,20 @@
+//go:build unix
+
+package progress
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchResize calls resized whenever the terminal is resized
+func watchResize(resized func()) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGWINCH)
+	go func() {
+		for range sigCh {
+			resized()
+		}
+	}()
+}
@@ -0,0 +1,58 @@
+// Package retry holds the backoff policy shared by downloads and listing
+// fetches: exponential delays with jitter, so many clients retrying at once
+// don't hit a struggling server in lockstep.
+package retry
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Defaults used when Policy leaves them at zero
+const (
+	DefaultBase = time.Second
+	DefaultMax  = 30 * time.Second
+)
+
+// Policy is how often and how patiently failed requests are tried again
+type Policy struct {
+	// Attempts is how many times a request is tried in all; 0 or 1 means once
+	Attempts int
+	// Base is the delay before the first retry, doubling for each one after;
+	// Max caps it
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Delay returns how long to wait after the given failed attempt (1 for the
+// first), randomized by up to 25% either way and capped at Max
+func (p Policy) Delay(attempt int) time.Duration {
+	base, limit := p.Base, p.Max
+	if base <= 0 {
+		base = DefaultBase
+	}
+	if limit <= 0 {
+		limit = DefaultMax
+	}
+	delay := limit
+	if shift := max(attempt-1, 0); shift < 32 {
+		if d := base << shift; d > 0 && d < limit {
+			delay = d
+		}
+	}
+	jitter := time.Duration(float64(delay) * 0.25 * (2*rand.Float64() - 1)) //nolint:gosec // Non-cryptographic random for backoff jitter is acceptable
+	return min(delay+jitter, limit)
+}
+
+// Wait sleeps for d, returning early with the context's error if it's done first
+func Wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
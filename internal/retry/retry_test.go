@@ -0,0 +1,46 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPolicy_Delay(t *testing.T) {
+	tests := []struct {
+		policy  Policy
+		attempt int
+		want    time.Duration // Before jitter
+	}{
+		{Policy{}, 1, time.Second},
+		{Policy{}, 3, 4 * time.Second},
+		{Policy{}, 10, 30 * time.Second},
+		{Policy{}, 100, 30 * time.Second}, // No overflow
+		{Policy{}, 0, time.Second},
+		{Policy{Base: 10 * time.Second, Max: 5 * time.Minute}, 2, 20 * time.Second},
+	}
+	for _, tt := range tests {
+		for range 20 {
+			got := tt.policy.Delay(tt.attempt)
+			limit := tt.policy.Max
+			if limit == 0 {
+				limit = DefaultMax
+			}
+			if got < tt.want*3/4 || got > min(tt.want*5/4, limit) {
+				t.Fatalf("%+v.Delay(%d) = %v, want %v ±25%%", tt.policy, tt.attempt, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestWait(t *testing.T) {
+	if err := Wait(context.Background(), time.Millisecond); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Wait(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+// Package searches stores named selections: a listing URL with the filters and
+// options to download it with, saved once and re-run by name.
+package searches
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Search is a saved selection
+type Search struct {
+	URL     string    `yaml:"url"`
+	Flags   []string  `yaml:"flags,omitempty"` // Command-line flags, as typed
+	SavedAt time.Time `yaml:"saved_at"`
+	LastRun time.Time `yaml:"last_run,omitempty"`
+}
+
+// Args returns the command line the search runs with
+func (s Search) Args() []string {
+	return append([]string{s.URL}, s.Flags...)
+}
+
+// Store is the set of saved searches in one file
+type Store struct {
+	path     string
+	Searches map[string]Search `yaml:"searches"`
+}
+
+// DefaultPath returns the saved searches location in the user's config directory
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+	return filepath.Join(dir, "myrient-dl", "searches.yaml"), nil
+}
+
+// Load reads saved searches. A missing file yields an empty store.
+func Load(path string) (*Store, error) {
+	s := &Store{path: path, Searches: map[string]Search{}}
+	data, err := os.ReadFile(path) //nolint:gosec // Path is the user's own file
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read saved searches: %w", err)
+	}
+	if err := yaml.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse saved searches %s: %w", path, err)
+	}
+	if s.Searches == nil {
+		s.Searches = map[string]Search{}
+	}
+	return s, nil
+}
+
+// Save writes the store atomically
+func (s *Store) Save() error {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to encode saved searches: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil { //nolint:gosec // Config directory permissions
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	tempPath := s.path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0644); err != nil { //nolint:gosec // Saved searches are not sensitive
+		return fmt.Errorf("failed to write saved searches: %w", err)
+	}
+	if err := os.Rename(tempPath, s.path); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to write saved searches: %w", err)
+	}
+	return nil
+}
+
+// validName keeps names easy to type and safe to use as shell words
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidateName checks that a name can be saved
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid search name %q (use letters, digits, '.', '_', and '-')", name)
+	}
+	return nil
+}
+
+// Put saves a search under name, reporting whether it replaced an existing one
+func (s *Store) Put(name string, search Search) (bool, error) {
+	if err := ValidateName(name); err != nil {
+		return false, err
+	}
+	_, replaced := s.Searches[name]
+	s.Searches[name] = search
+	return replaced, nil
+}
+
+// Get returns the search saved under name
+func (s *Store) Get(name string) (Search, bool) {
+	search, ok := s.Searches[name]
+	return search, ok
+}
+
+// Delete removes a saved search, reporting whether it existed
+func (s *Store) Delete(name string) bool {
+	_, ok := s.Searches[name]
+	delete(s.Searches, name)
+	return ok
+}
+
+// Names returns the saved search names in order
+func (s *Store) Names() []string {
+	names := make([]string, 0, len(s.Searches))
+	for name := range s.Searches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
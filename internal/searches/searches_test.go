@@ -0,0 +1,73 @@
+package searches
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStore_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "myrient-dl", "searches.yaml")
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load of a missing file failed: %v", err)
+	}
+	if len(s.Names()) != 0 {
+		t.Fatalf("expected no searches, got %v", s.Names())
+	}
+
+	saved := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	nes := Search{URL: "https://myrient.example/files/No-Intro/NES/", Flags: []string{"-i", "*(USA)*", "--latest"}, SavedAt: saved}
+	if replaced, err := s.Put("nes-usa", nes); err != nil || replaced {
+		t.Fatalf("Put = %v, %v; expected a new search", replaced, err)
+	}
+	if _, err := s.Put("gb", Search{URL: "https://myrient.example/files/No-Intro/GB/", SavedAt: saved}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	s, err = Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got := s.Names(); !reflect.DeepEqual(got, []string{"gb", "nes-usa"}) {
+		t.Errorf("expected sorted names, got %v", got)
+	}
+	got, ok := s.Get("nes-usa")
+	if !ok || !reflect.DeepEqual(got, nes) {
+		t.Errorf("expected %+v, got %+v", nes, got)
+	}
+	if want := []string{nes.URL, "-i", "*(USA)*", "--latest"}; !reflect.DeepEqual(got.Args(), want) {
+		t.Errorf("expected args %q, got %q", want, got.Args())
+	}
+
+	if replaced, err := s.Put("gb", Search{URL: "https://myrient.example/files/No-Intro/GBC/"}); err != nil || !replaced {
+		t.Errorf("Put = %v, %v; expected a replacement", replaced, err)
+	}
+	if !s.Delete("gb") || s.Delete("gb") {
+		t.Error("expected Delete to report the search once")
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"nes-usa", false},
+		{"Redump_PS1.v2", false},
+		{"", true},
+		{"-latest", true},
+		{"nes usa", true},
+		{"../nes", true},
+	}
+	for _, tt := range tests {
+		if err := ValidateName(tt.name); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateName(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
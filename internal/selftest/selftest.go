@@ -0,0 +1,246 @@
+// Package selftest runs myrient-dl end to end against a built-in server with a
+// synthetic listing, so problems can be pinned on the local environment or on
+// the real server.
+package selftest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/downloader"
+	"github.com/nchapman/myrient-dl/internal/matcher"
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// ListingPath is where the synthetic listing is served
+const ListingPath = "/files/No-Intro/Self Test/"
+
+// Patterns used for the matching step
+var (
+	includePatterns = []string{"*USA*"}
+	excludePatterns = []string{"*(Beta)*"}
+)
+
+// Step is the outcome of one stage of the self test
+type Step struct {
+	Name     string
+	Detail   string // What was checked, on success
+	Err      error
+	Duration time.Duration
+}
+
+// file is a file in the synthetic listing
+type file struct {
+	name    string // Path below the listing
+	content []byte
+	want    bool // Selected by the test patterns
+}
+
+// files returns the synthetic listing with deterministic contents
+func files() []file {
+	rng := rand.New(rand.NewPCG(1, 2)) //nolint:gosec // Test data, not security sensitive
+	gen := func(name string, size int, want bool) file {
+		content := make([]byte, size)
+		for i := range content {
+			content[i] = byte(rng.UintN(256))
+		}
+		return file{name: name, content: content, want: want}
+	}
+	return []file{
+		gen("Game A (USA).zip", 48*1024, true),
+		gen("Game B (Europe).zip", 20*1024, false),
+		gen("Game C (USA, Europe).zip", 150*1024, true),
+		gen("Game D (USA) (Beta).zip", 12*1024, false),
+		gen("Extras/Manual (USA).pdf", 64*1024, true),
+	}
+}
+
+// server serves files as Apache-style listings, with range support
+func server(files []file) *httptest.Server {
+	byPath := make(map[string]file, len(files))
+	for _, f := range files {
+		byPath[ListingPath+f.name] = f
+	}
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if f, ok := byPath[r.URL.Path]; ok {
+			w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sha256.Sum256(f.content)))
+			http.ServeContent(w, r, path.Base(f.name), modTime, strings.NewReader(string(f.content)))
+			return
+		}
+		if !strings.HasSuffix(r.URL.Path, "/") || !strings.HasPrefix(r.URL.Path, ListingPath) {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(listingHTML(r.URL.Path, byPath)))
+	}))
+}
+
+// listingHTML renders the files and subdirectories directly below dir
+func listingHTML(dir string, byPath map[string]file) string {
+	var rows []string
+	dirs := make(map[string]bool)
+	for p, f := range byPath {
+		rest, ok := strings.CutPrefix(p, dir)
+		if !ok {
+			continue
+		}
+		if sub, _, nested := strings.Cut(rest, "/"); nested {
+			dirs[sub] = true
+			continue
+		}
+		rows = append(rows, fmt.Sprintf(`<tr><td class="link"><a href="%s">%s</a></td><td class="size">%.1f KiB</td></tr>`,
+			url.PathEscape(rest), html.EscapeString(rest), float64(len(f.content))/1024))
+	}
+	for sub := range dirs {
+		rows = append(rows, fmt.Sprintf(`<tr><td class="link"><a href="%s/">%s/</a></td><td class="size">-</td></tr>`,
+			url.PathEscape(sub), html.EscapeString(sub)))
+	}
+	sort.Strings(rows)
+	return `<html><body><h1>Index of ` + html.EscapeString(dir) + `</h1><table id="list">` +
+		`<tr><td><a href="../">Parent directory/</a></td><td>-</td></tr>` + strings.Join(rows, "") + `</table></body></html>`
+}
+
+// Run starts the built-in server and runs its listing through parsing,
+// matching, downloading into dir, verification, and a second pass that must
+// skip everything. Each step is reported as it finishes; the first failure
+// stops the run and is returned.
+func Run(ctx context.Context, dir string, report func(Step)) error {
+	all := files()
+	srv := server(all)
+	defer srv.Close()
+
+	var listing []parser.FileInfo
+	var selected []parser.FileInfo
+	digests := make(map[string]string)
+
+	steps := []struct {
+		name string
+		run  func() (string, error)
+	}{
+		{"Write to the output directory", func() (string, error) {
+			probe := filepath.Join(dir, ".selftest-probe")
+			if err := os.WriteFile(probe, []byte("ok"), 0600); err != nil {
+				return "", err
+			}
+			return dir, os.Remove(probe)
+		}},
+		{"Parse a directory listing", func() (string, error) {
+			l, err := parser.FetchListing(ctx, srv.URL+ListingPath, parser.Options{Recursive: true})
+			if err != nil {
+				return "", err
+			}
+			listing = l.Files
+			if len(listing) != len(all) {
+				return "", fmt.Errorf("found %d files, expected %d", len(listing), len(all))
+			}
+			for _, f := range listing {
+				if f.System != "Self Test" {
+					return "", fmt.Errorf("%s: detected system %q, expected \"Self Test\"", f.Name, f.System)
+				}
+			}
+			return fmt.Sprintf("%d files, including a subdirectory", len(listing)), nil
+		}},
+		{"Match include/exclude patterns", func() (string, error) {
+			selected = matcher.New(includePatterns, excludePatterns).Filter(listing)
+			var want []string
+			for _, f := range all {
+				if f.want {
+					want = append(want, f.name)
+				}
+			}
+			var got []string
+			for _, f := range selected {
+				got = append(got, f.Name)
+			}
+			sort.Strings(want)
+			sort.Strings(got)
+			if strings.Join(got, "|") != strings.Join(want, "|") {
+				return "", fmt.Errorf("selected %q, expected %q", got, want)
+			}
+			return fmt.Sprintf("%d of %d files selected", len(selected), len(listing)), nil
+		}},
+		{"Download files", func() (string, error) {
+			var mu sync.Mutex
+			dl := downloader.New(downloader.Config{
+				OutputDir:     dir,
+				Parallel:      2,
+				RetryAttempts: 1,
+				OnEvent: func(e downloader.Event) {
+					if e.Type == downloader.EventCompleted {
+						mu.Lock()
+						digests[e.File.Name] = e.SHA256
+						mu.Unlock()
+					}
+				},
+			})
+			if err := dl.DownloadAll(ctx, selected); err != nil {
+				return "", err
+			}
+			s := dl.Summary()
+			if s.Completed != len(selected) || s.Skipped != 0 {
+				return "", fmt.Errorf("completed %d of %d files (%d skipped)", s.Completed, len(selected), s.Skipped)
+			}
+			return fmt.Sprintf("%d files, %d bytes", s.Completed, s.DownloadedBytes), nil
+		}},
+		{"Verify downloaded files", func() (string, error) {
+			for _, f := range all {
+				if !f.want {
+					continue
+				}
+				sum := sha256.Sum256(f.content)
+				want := hex.EncodeToString(sum[:])
+				data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(f.name))) //nolint:gosec // Path is below the self-test directory
+				if err != nil {
+					return "", err
+				}
+				if got := sha256.Sum256(data); hex.EncodeToString(got[:]) != want {
+					return "", fmt.Errorf("%s: contents differ from the server's", f.name)
+				}
+				if digests[f.name] != want {
+					return "", fmt.Errorf("%s: downloader reported SHA-256 %q, expected %s", f.name, digests[f.name], want)
+				}
+			}
+			return "SHA-256 of every file matches", nil
+		}},
+		{"Skip files already downloaded", func() (string, error) {
+			dl := downloader.New(downloader.Config{OutputDir: dir, Parallel: 1, RetryAttempts: 1})
+			if err := dl.DownloadAll(ctx, selected); err != nil {
+				return "", err
+			}
+			if s := dl.Summary(); s.Skipped != len(selected) || s.DownloadedBytes != 0 {
+				return "", fmt.Errorf("skipped %d of %d files, downloaded %d bytes", s.Skipped, len(selected), s.DownloadedBytes)
+			}
+			return "nothing downloaded twice", nil
+		}},
+	}
+
+	for _, s := range steps {
+		start := time.Now()
+		detail, err := s.run()
+		step := Step{Name: s.name, Detail: detail, Err: err, Duration: time.Since(start)}
+		if report != nil {
+			report(step)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.name, err)
+		}
+	}
+	return nil
+}
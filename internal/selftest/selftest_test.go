@@ -0,0 +1,58 @@
+package selftest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	var steps []Step
+	if err := Run(context.Background(), t.TempDir(), func(s Step) { steps = append(steps, s) }); err != nil {
+		t.Fatalf("self test failed: %v", err)
+	}
+	if len(steps) != 6 {
+		t.Errorf("expected 6 steps, got %d", len(steps))
+	}
+	for _, s := range steps {
+		if s.Err != nil || s.Detail == "" {
+			t.Errorf("unexpected step %+v", s)
+		}
+	}
+}
+
+func TestRun_Failure(t *testing.T) {
+	// An output directory that can't be written to fails the first step
+	dir := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(dir, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var steps []Step
+	err := Run(context.Background(), dir, func(s Step) { steps = append(steps, s) })
+	if err == nil || !strings.HasPrefix(err.Error(), "Write to the output directory") {
+		t.Errorf("expected the write step to fail, got %v", err)
+	}
+	if len(steps) != 1 {
+		t.Errorf("expected the run to stop after the failed step, got %d steps", len(steps))
+	}
+}
+
+func TestListingHTML(t *testing.T) {
+	byPath := map[string]file{
+		ListingPath + "a (USA).zip":   {content: make([]byte, 2048)},
+		ListingPath + "Sub/b.zip":     {content: make([]byte, 10)},
+		ListingPath + "Sub/Deep/c.7z": {content: make([]byte, 10)},
+	}
+	got := listingHTML(ListingPath, byPath)
+	for _, want := range []string{`href="a%20%28USA%29.zip"`, "2.0 KiB", `href="Sub/"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected listing to contain %s:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "b.zip") || strings.Contains(got, "Deep") {
+		t.Errorf("expected only direct children in the listing:\n%s", got)
+	}
+}
@@ -0,0 +1,71 @@
+// Package sidecar writes a small JSON file next to each downloaded file
+// recording where it came from, for provenance that travels with the file.
+package sidecar
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/cleanup"
+)
+
+// Suffix is appended to a file's name to get its sidecar's name
+const Suffix = ".meta.json"
+
+// Sidecar is the provenance of one downloaded file
+type Sidecar struct {
+	Name         string    `json:"name"`
+	URL          string    `json:"url"`
+	Mirror       string    `json:"mirror,omitempty"` // URL that actually served the file, if not URL
+	Source       string    `json:"source,omitempty"` // Listing the file was selected from
+	Size         int64     `json:"size"`
+	SHA256       string    `json:"sha256,omitempty"`
+	DownloadedAt time.Time `json:"downloaded_at"`
+	Collection   string    `json:"collection,omitempty"`
+	System       string    `json:"system,omitempty"`
+}
+
+// Path returns the sidecar location for a file
+func Path(filePath string) string {
+	return filePath + Suffix
+}
+
+// IsSidecar reports whether a file name is a sidecar
+func IsSidecar(name string) bool {
+	return strings.HasSuffix(name, Suffix)
+}
+
+// Write saves the sidecar for the file at filePath, replacing any earlier one
+func Write(filePath string, s Sidecar) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode sidecar: %w", err)
+	}
+	data = append(data, '\n')
+
+	tmp := Path(filePath) + cleanup.TempSuffix
+	if err := os.WriteFile(tmp, data, 0644); err != nil { //nolint:gosec // Sidecars are meant to be readable like the files they describe
+		return fmt.Errorf("failed to write sidecar: %w", err)
+	}
+	if err := os.Rename(tmp, Path(filePath)); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write sidecar: %w", err)
+	}
+	return nil
+}
+
+// Read loads the sidecar of the file at filePath
+func Read(filePath string) (Sidecar, error) {
+	var s Sidecar
+	data, err := os.ReadFile(Path(filePath)) //nolint:gosec // Path is derived from the user's download directory
+	if err != nil {
+		return s, fmt.Errorf("failed to read sidecar: %w", err)
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("failed to parse sidecar: %w", err)
+	}
+	return s, nil
+}
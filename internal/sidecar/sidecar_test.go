@@ -0,0 +1,53 @@
+package sidecar
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteRead(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "Game (USA).zip")
+	want := Sidecar{
+		Name:         "Game (USA).zip",
+		URL:          "https://myrient.example/files/No-Intro/NES/Game%20(USA).zip",
+		Source:       "https://myrient.example/files/No-Intro/NES/",
+		Size:         5,
+		SHA256:       "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		DownloadedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Collection:   "No-Intro",
+		System:       "NES",
+	}
+	if err := Write(file, want); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if _, err := os.Stat(file + Suffix); err != nil {
+		t.Fatalf("expected the sidecar next to the file: %v", err)
+	}
+
+	got, err := Read(file)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	if _, err := Read(filepath.Join(t.TempDir(), "missing.zip")); err == nil {
+		t.Error("expected an error for a missing sidecar")
+	}
+}
+
+func TestIsSidecar(t *testing.T) {
+	tests := map[string]bool{
+		"Game (USA).zip.meta.json": true,
+		"Game (USA).zip":           false,
+		"data.json":                false,
+	}
+	for name, want := range tests {
+		if got := IsSidecar(name); got != want {
+			t.Errorf("IsSidecar(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
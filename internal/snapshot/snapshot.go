@@ -0,0 +1,130 @@
+// Package snapshot caches directory listings so later fetches can be compared against them.
+package snapshot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// Snapshot is a listing as it was seen at a point in time
+type Snapshot struct {
+	URL   string            `json:"url"`
+	Taken time.Time         `json:"taken"`
+	Files []parser.FileInfo `json:"files"`
+}
+
+// Changes is the difference between two listings
+type Changes struct {
+	Added   []parser.FileInfo
+	Removed []parser.FileInfo
+	Changed []Change
+}
+
+// Change is a file listed in both snapshots with a different size
+type Change struct {
+	Old parser.FileInfo
+	New parser.FileInfo
+}
+
+// DefaultDir returns the snapshot cache location in the user's cache directory
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return filepath.Join(dir, "myrient-dl", "snapshots"), nil
+}
+
+// path returns the cache file for a listing URL
+func path(dir, listingURL string) string {
+	sum := sha256.Sum256([]byte(listingURL))
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// Load returns the cached snapshot of a listing, or nil if it was never saved
+func Load(dir, listingURL string) (*Snapshot, error) {
+	data, err := os.ReadFile(path(dir, listingURL)) //nolint:gosec // Path is derived from the cache directory
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot: %w", err)
+	}
+	return &s, nil
+}
+
+// Save replaces the cached snapshot of a listing with files
+func Save(dir, listingURL string, files []parser.FileInfo) error {
+	if err := os.MkdirAll(dir, 0755); err != nil { //nolint:gosec // Cache directory permissions
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(Snapshot{URL: listingURL, Taken: time.Now().UTC(), Files: files}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	target := path(dir, listingURL)
+	tempPath := target + ".tmp"
+	if err := os.WriteFile(tempPath, append(data, '\n'), 0644); err != nil { //nolint:gosec // Snapshots are not sensitive
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(tempPath, target); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// Diff reports files added, removed, or resized between two listings, sorted by name
+func Diff(old, current []parser.FileInfo) Changes {
+	before := make(map[string]parser.FileInfo, len(old))
+	for _, f := range old {
+		before[f.Name] = f
+	}
+
+	var c Changes
+	seen := make(map[string]bool, len(current))
+	for _, f := range current {
+		seen[f.Name] = true
+		prev, ok := before[f.Name]
+		switch {
+		case !ok:
+			c.Added = append(c.Added, f)
+		case prev.Size != f.Size:
+			c.Changed = append(c.Changed, Change{Old: prev, New: f})
+		}
+	}
+	for _, f := range old {
+		if !seen[f.Name] {
+			c.Removed = append(c.Removed, f)
+		}
+	}
+
+	sortFiles(c.Added)
+	sortFiles(c.Removed)
+	sort.Slice(c.Changed, func(i, j int) bool { return c.Changed[i].New.Name < c.Changed[j].New.Name })
+	return c
+}
+
+// Empty reports whether the listings were identical
+func (c Changes) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Changed) == 0
+}
+
+func sortFiles(files []parser.FileInfo) {
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+}
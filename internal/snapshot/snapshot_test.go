@@ -0,0 +1,66 @@
+package snapshot
+
+import (
+	"testing"
+
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+func TestSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	url := "https://myrient.example/files/No-Intro/NES/"
+
+	s, err := Load(dir, url)
+	if err != nil || s != nil {
+		t.Fatalf("expected no snapshot before saving, got %+v (%v)", s, err)
+	}
+
+	files := []parser.FileInfo{{Name: "a.zip", URL: url + "a.zip", Size: 10}}
+	if err := Save(dir, url, files); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+
+	s, err = Load(dir, url)
+	if err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+	if s.URL != url || len(s.Files) != 1 || s.Files[0] != files[0] || s.Taken.IsZero() {
+		t.Errorf("unexpected snapshot %+v", s)
+	}
+
+	if other, _ := Load(dir, url+"other/"); other != nil {
+		t.Error("expected snapshots to be keyed by URL")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := []parser.FileInfo{
+		{Name: "same.zip", Size: 1},
+		{Name: "resized.zip", Size: 2},
+		{Name: "gone.zip", Size: 3},
+	}
+	current := []parser.FileInfo{
+		{Name: "new.zip", Size: 4},
+		{Name: "same.zip", Size: 1},
+		{Name: "resized.zip", Size: 5},
+		{Name: "another.zip", Size: 6},
+	}
+
+	c := Diff(old, current)
+	if len(c.Added) != 2 || c.Added[0].Name != "another.zip" || c.Added[1].Name != "new.zip" {
+		t.Errorf("unexpected added %+v", c.Added)
+	}
+	if len(c.Removed) != 1 || c.Removed[0].Name != "gone.zip" {
+		t.Errorf("unexpected removed %+v", c.Removed)
+	}
+	if len(c.Changed) != 1 || c.Changed[0].Old.Size != 2 || c.Changed[0].New.Size != 5 {
+		t.Errorf("unexpected changed %+v", c.Changed)
+	}
+	if c.Empty() {
+		t.Error("expected changes")
+	}
+
+	if !Diff(current, current).Empty() {
+		t.Error("expected identical listings to have no changes")
+	}
+}
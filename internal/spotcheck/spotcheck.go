@@ -0,0 +1,60 @@
+// Package spotcheck picks a random sample of downloaded archives to test and
+// says how far the result can be trusted for the whole set.
+package spotcheck
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+// z is the normal quantile for 95% confidence
+const z = 1.96
+
+// ParsePercent parses a --spot-check rate such as "5%" or "0.5"
+func ParsePercent(value string) (float64, error) {
+	p, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "%")), 64)
+	if err != nil || math.IsNaN(p) || p <= 0 || p > 100 {
+		return 0, fmt.Errorf("invalid percentage %q (expected e.g. 5%%)", value)
+	}
+	return p, nil
+}
+
+// SampleSize returns how many of population items percent covers, at least one
+func SampleSize(population int, percent float64) int {
+	if population <= 0 {
+		return 0
+	}
+	n := int(math.Ceil(float64(population) * percent / 100))
+	return min(max(n, 1), population)
+}
+
+// Sample returns n items chosen at random, in random order
+func Sample[T any](items []T, n int) []T {
+	sample := make([]T, len(items))
+	copy(sample, items)
+	rand.Shuffle(len(sample), func(i, j int) { //nolint:gosec // Sampling doesn't need cryptographic randomness
+		sample[i], sample[j] = sample[j], sample[i]
+	})
+	return sample[:min(max(n, 0), len(sample))]
+}
+
+// UpperBound returns the fraction of the population that, with 95%
+// confidence, is at most bad, given failed of sampled items failed. It uses
+// the Wilson score interval, which stays sensible when nothing failed; a
+// sample of the whole population is exact.
+func UpperBound(sampled, failed, population int) float64 {
+	if sampled <= 0 {
+		return 1
+	}
+	if sampled >= population {
+		return float64(failed) / float64(sampled)
+	}
+	n := float64(sampled)
+	p := float64(failed) / n
+	center := p + z*z/(2*n)
+	margin := z * math.Sqrt(p*(1-p)/n+z*z/(4*n*n))
+	return math.Min((center+margin)/(1+z*z/n), 1)
+}
@@ -0,0 +1,98 @@
+package spotcheck
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParsePercent(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{"5%", 5, false},
+		{"0.5", 0.5, false},
+		{" 100 %", 100, false},
+		{"0%", 0, true},
+		{"150%", 0, true},
+		{"-1", 0, true},
+		{"five", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePercent(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePercent(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParsePercent(%q) = %v, expected %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSampleSize(t *testing.T) {
+	tests := []struct {
+		population int
+		percent    float64
+		want       int
+	}{
+		{1000, 5, 50},
+		{999, 5, 50}, // Rounded up
+		{10, 1, 1},   // Never nothing
+		{3, 100, 3},
+		{0, 5, 0},
+	}
+
+	for _, tt := range tests {
+		if got := SampleSize(tt.population, tt.percent); got != tt.want {
+			t.Errorf("SampleSize(%d, %v) = %d, expected %d", tt.population, tt.percent, got, tt.want)
+		}
+	}
+}
+
+func TestSample(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5, 6, 7, 8}
+	sample := Sample(items, 3)
+	if len(sample) != 3 {
+		t.Fatalf("expected 3 items, got %v", sample)
+	}
+	seen := map[int]bool{}
+	for _, v := range sample {
+		if v < 1 || v > 8 || seen[v] {
+			t.Errorf("expected distinct items from the input, got %v", sample)
+		}
+		seen[v] = true
+	}
+	if items[0] != 1 || items[7] != 8 {
+		t.Errorf("expected the input to be left alone, got %v", items)
+	}
+	if got := Sample(items, 20); len(got) != len(items) {
+		t.Errorf("expected at most every item, got %v", got)
+	}
+}
+
+func TestUpperBound(t *testing.T) {
+	tests := []struct {
+		name                        string
+		sampled, failed, population int
+		want                        float64
+	}{
+		{"nothing failed", 50, 0, 1000, 0.0714},
+		{"some failed", 50, 2, 1000, 0.1346},
+		{"larger sample is tighter", 500, 0, 100000, 0.0076},
+		{"whole population", 20, 1, 20, 0.05},
+		{"nothing sampled", 0, 0, 100, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := UpperBound(tt.sampled, tt.failed, tt.population)
+			if math.Abs(got-tt.want) > 0.0005 {
+				t.Errorf("UpperBound(%d, %d, %d) = %.4f, expected %.4f", tt.sampled, tt.failed, tt.population, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,454 @@
+// Package state persists the download queue of a run so it can be inspected
+// and resumed later.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+// DirName is the state directory kept inside each output directory
+const DirName = ".myrient-dl"
+
+// File names inside the state directory
+const (
+	QueueFile = "queue.json"
+	LockFile  = "run.lock"
+)
+
+// Heartbeat timing for the run lock. A lock that has not been touched for
+// StaleAfter is assumed to belong to a run that crashed or was killed.
+const (
+	HeartbeatInterval = 30 * time.Second
+	StaleAfter        = 2 * time.Minute
+)
+
+// Status is the state of a single queued file
+type Status string
+
+// Queue item states
+const (
+	StatusPending     Status = "pending"
+	StatusInProgress  Status = "in-progress"
+	StatusCompleted   Status = "completed"
+	StatusSkipped     Status = "skipped" // Already present locally
+	StatusPlaceholder Status = "placeholder"
+	StatusRejected    Status = "rejected" // Discarded after download, e.g. blocklisted
+	StatusGone        Status = "gone"     // Missing upstream, with --ignore-missing
+	StatusFailed      Status = "failed"
+)
+
+// Statuses lists every item state in display order
+var Statuses = []Status{StatusPending, StatusInProgress, StatusCompleted, StatusSkipped, StatusPlaceholder, StatusRejected, StatusGone, StatusFailed}
+
+// Item is a single file in the queue
+type Item struct {
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	Size      int64     `json:"size"`
+	Status    Status    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Mirror    string    `json:"mirror,omitempty"` // URL that served the file when the listed one failed
+	UpdatedAt time.Time `json:"updated_at"`
+	// Transferred is how much of the file is on disk: all of it once done, or
+	// what its partial download had synced when progress was last saved
+	Transferred int64 `json:"transferred,omitempty"`
+}
+
+// Queue is the persisted list of files a run is working through
+type Queue struct {
+	Source    string    `json:"source"`
+	StartedAt time.Time `json:"started_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Items     []Item    `json:"items"`
+
+	mu    sync.Mutex
+	path  string
+	index map[string]int
+	dirty bool // Changes recorded since the last save
+}
+
+// Tally counts the items and bytes in one state
+type Tally struct {
+	Count int
+	Bytes int64
+}
+
+// Dir returns the state directory inside an output directory
+func Dir(outputDir string) string {
+	return filepath.Join(outputDir, DirName)
+}
+
+// Create starts a new queue for files in outputDir, replacing any previous queue
+func Create(outputDir, source string, files []parser.FileInfo) (*Queue, error) {
+	now := time.Now().UTC()
+	q := &Queue{
+		Source:    source,
+		StartedAt: now,
+		UpdatedAt: now,
+		Items:     make([]Item, 0, len(files)),
+		path:      filepath.Join(Dir(outputDir), QueueFile),
+	}
+	for _, f := range files {
+		q.Items = append(q.Items, Item{Name: f.Name, URL: f.URL, Size: f.Size, Status: StatusPending, UpdatedAt: now})
+	}
+	q.buildIndex()
+
+	if err := os.MkdirAll(Dir(outputDir), 0755); err != nil { //nolint:gosec // State lives alongside downloads
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := q.save(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Load reads the queue stored in outputDir
+func Load(outputDir string) (*Queue, error) {
+	path := filepath.Join(Dir(outputDir), QueueFile)
+	data, err := os.ReadFile(path) //nolint:gosec // Path is derived from the user's output directory
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue: %w", err)
+	}
+
+	q := &Queue{path: path}
+	if err := json.Unmarshal(data, q); err != nil {
+		return nil, fmt.Errorf("failed to parse queue: %w", err)
+	}
+	q.buildIndex()
+	return q, nil
+}
+
+func (q *Queue) buildIndex() {
+	q.index = make(map[string]int, len(q.Items))
+	for i, item := range q.Items {
+		q.index[item.Name] = i
+	}
+}
+
+// Update records a new state for the named file. Like Progress it is kept in
+// memory until the next SaveProgress or Flush, so a run of thousands of files
+// doesn't rewrite the queue for every event. It is safe for concurrent use.
+func (q *Queue) Update(name string, status Status, err error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i, ok := q.index[name]
+	if !ok {
+		return fmt.Errorf("%s is not in the queue", name)
+	}
+
+	now := time.Now().UTC()
+	q.Items[i].Status = status
+	q.Items[i].Error = ""
+	if err != nil {
+		q.Items[i].Error = err.Error()
+	}
+	q.Items[i].UpdatedAt = now
+	q.UpdatedAt = now
+	q.dirty = true
+	return nil
+}
+
+// Flush saves the queue and commits it to stable storage, for the end of a run
+// and checkpoints in long runs where losing the last few updates to a crash matters
+func (q *Queue) Flush() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.save(); err != nil {
+		return err
+	}
+	f, err := os.Open(q.path) //nolint:gosec // Path is derived from the user's output directory
+	if err != nil {
+		return fmt.Errorf("failed to flush queue: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to flush queue: %w", err)
+	}
+	return nil
+}
+
+// SetMirror records the mirror that served the named file; it is saved with the next Update
+func (q *Queue) SetMirror(name, mirrorURL string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if i, ok := q.index[name]; ok {
+		q.Items[i].Mirror = mirrorURL
+	}
+}
+
+// Progress records how many bytes of the named file are on disk. It is kept in
+// memory until the next Update or SaveProgress, so it can be called often.
+func (q *Queue) Progress(name string, bytes int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if i, ok := q.index[name]; ok && q.Items[i].Transferred != bytes {
+		q.Items[i].Transferred = bytes
+		q.dirty = true
+	}
+}
+
+// SaveProgress saves the queue if states or progress were recorded since it was
+// last saved
+func (q *Queue) SaveProgress() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.dirty {
+		return nil
+	}
+	return q.save()
+}
+
+// Carry copies the progress an earlier queue recorded for the same files, so a
+// resumed run knows what was already transferred
+func (q *Queue) Carry(previous *Queue) {
+	previous.mu.Lock()
+	defer previous.mu.Unlock()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, item := range q.Items {
+		if j, ok := previous.index[item.Name]; ok && previous.Items[j].URL == item.URL && previous.Items[j].Transferred > 0 {
+			q.Items[i].Transferred = previous.Items[j].Transferred
+			q.dirty = true
+		}
+	}
+}
+
+// Transferred returns how many bytes of the named file are on disk as far as
+// the queue knows
+func (q *Queue) Transferred(name string) int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if i, ok := q.index[name]; ok {
+		return q.Items[i].Transferred
+	}
+	return 0
+}
+
+// TotalTransferred returns how many bytes of the queued files are on disk as
+// far as the queue knows
+func (q *Queue) TotalTransferred() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var total int64
+	for _, item := range q.Items {
+		total += item.Transferred
+	}
+	return total
+}
+
+// Files returns the queued files in queue order, for picking the run up again
+func (q *Queue) Files() []parser.FileInfo {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	files := make([]parser.FileInfo, len(q.Items))
+	for i, item := range q.Items {
+		files[i] = parser.FileInfo{Name: item.Name, URL: item.URL, Size: item.Size}
+	}
+	return files
+}
+
+// Tallies counts items and bytes by state
+func (q *Queue) Tallies() map[Status]Tally {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	tallies := make(map[Status]Tally, len(Statuses))
+	for _, item := range q.Items {
+		t := tallies[item.Status]
+		t.Count++
+		t.Bytes += item.Size
+		tallies[item.Status] = t
+	}
+	return tallies
+}
+
+// save writes the queue atomically; callers must hold q.mu or own q exclusively
+func (q *Queue) save() error {
+	data, err := json.MarshalIndent(q, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tempPath := q.path + ".tmp"
+	if err := os.WriteFile(tempPath, append(data, '\n'), 0644); err != nil { //nolint:gosec // Queue files are not sensitive
+		return fmt.Errorf("failed to write queue: %w", err)
+	}
+	if err := os.Rename(tempPath, q.path); err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("failed to write queue: %w", err)
+	}
+	q.dirty = false
+	return nil
+}
+
+// RunLock marks an output directory as having an active run
+type RunLock struct {
+	path string
+	stop chan struct{}
+	done chan struct{}
+}
+
+// acquireAttempts bounds how often Acquire retries after taking over a stale lock
+const acquireAttempts = 3
+
+// Acquire creates the run lock for outputDir and keeps it fresh until Release.
+// The lock is created exclusively, so of two runs started together only one
+// gets it; a lock whose heartbeat stopped is taken over.
+func Acquire(outputDir string) (*RunLock, error) {
+	path := filepath.Join(Dir(outputDir), LockFile)
+	if err := os.MkdirAll(Dir(outputDir), 0755); err != nil { //nolint:gosec // State lives alongside downloads
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	for range acquireAttempts {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644) //nolint:gosec // Lock files are not sensitive
+		if err == nil {
+			_, err = f.WriteString(strconv.Itoa(os.Getpid()) + "\n")
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				_ = os.Remove(path)
+				return nil, fmt.Errorf("failed to write run lock: %w", err)
+			}
+			l := &RunLock{path: path, stop: make(chan struct{}), done: make(chan struct{})}
+			go l.heartbeat()
+			return l, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("failed to write run lock: %w", err)
+		}
+		if pid, ok := lockHolder(path); ok {
+			return nil, fmt.Errorf("another download (pid %d) is already running in %s", pid, outputDir)
+		}
+		if err := takeOver(path); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("failed to take the run lock in %s: another run keeps taking it", outputDir)
+}
+
+// takeOver removes a stale lock left by a run that died. The lock is renamed
+// aside and checked again first, so a lock another run took in the meantime is
+// put back rather than removed.
+func takeOver(path string) error {
+	aside := fmt.Sprintf("%s.%d.stale", path, os.Getpid())
+	if err := os.Rename(path, aside); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil // Another run cleared it first; try again
+		}
+		return fmt.Errorf("failed to take over run lock: %w", err)
+	}
+	if _, ok := lockHolder(aside); ok {
+		_ = os.Rename(aside, path)
+		return nil
+	}
+	if err := os.Remove(aside); err != nil {
+		return fmt.Errorf("failed to take over run lock: %w", err)
+	}
+	return nil
+}
+
+func (l *RunLock) heartbeat() {
+	defer close(l.done)
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			_ = os.Chtimes(l.path, now, now)
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// Release stops the heartbeat and removes the lock
+func (l *RunLock) Release() {
+	close(l.stop)
+	<-l.done
+	_ = os.Remove(l.path)
+}
+
+// Running reports whether a live run holds the lock in outputDir, and its pid
+func Running(outputDir string) (int, bool) {
+	return lockHolder(filepath.Join(Dir(outputDir), LockFile))
+}
+
+// lockHolder reports the pid in a lock file whose heartbeat is still fresh
+func lockHolder(path string) (int, bool) {
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > StaleAfter {
+		return 0, false
+	}
+
+	data, err := os.ReadFile(path) //nolint:gosec // Path is derived from the user's output directory
+	if err != nil {
+		return 0, false
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid, true
+}
+
+// ResumeOrder orders files for a run that picks up where this queue left off.
+// Files that failed or were interrupted come first, in their previous order, so
+// problems surface early. Files not yet attempted follow, alternating between the
+// smallest and largest so small files show progress quickly while large ones keep
+// moving. Files already finished come last, since they are only checked and
+// skipped. It also reports how many files were retried and not yet attempted.
+func (q *Queue) ResumeOrder(files []parser.FileInfo) (ordered []parser.FileInfo, retried, untouched int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var retry, fresh, done []parser.FileInfo
+	for _, f := range files {
+		status := StatusPending
+		if i, ok := q.index[f.Name]; ok {
+			status = q.Items[i].Status
+		}
+		switch status {
+		case StatusFailed, StatusInProgress:
+			retry = append(retry, f)
+		case StatusPending:
+			fresh = append(fresh, f)
+		default:
+			done = append(done, f)
+		}
+	}
+
+	ordered = make([]parser.FileInfo, 0, len(files))
+	ordered = append(ordered, retry...)
+	ordered = append(ordered, interleaveBySize(fresh)...)
+	ordered = append(ordered, done...)
+	return ordered, len(retry), len(fresh)
+}
+
+// interleaveBySize alternates between the smallest and largest remaining files
+func interleaveBySize(files []parser.FileInfo) []parser.FileInfo {
+	sorted := make([]parser.FileInfo, len(files))
+	copy(sorted, files)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Size < sorted[j].Size })
+
+	result := make([]parser.FileInfo, 0, len(sorted))
+	for lo, hi := 0, len(sorted)-1; lo <= hi; lo, hi = lo+1, hi-1 {
+		result = append(result, sorted[lo])
+		if hi != lo {
+			result = append(result, sorted[hi])
+		}
+	}
+	return result
+}
@@ -0,0 +1,267 @@
+package state
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/parser"
+)
+
+func testFiles() []parser.FileInfo {
+	return []parser.FileInfo{
+		{Name: "mario.zip", URL: "https://example.com/files/mario.zip", Size: 1000},
+		{Name: "sonic.zip", URL: "https://example.com/files/sonic.zip", Size: 2000},
+		{Name: "zelda.zip", URL: "https://example.com/files/zelda.zip", Size: 3000},
+	}
+}
+
+func TestQueue_CreateUpdateLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	q, err := Create(dir, "https://example.com/files/", testFiles())
+	if err != nil {
+		t.Fatalf("failed to create queue: %v", err)
+	}
+
+	if err := q.Update("mario.zip", StatusCompleted, nil); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+	if err := q.Update("sonic.zip", StatusFailed, errors.New("connection reset")); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+	if err := q.Update("missing.zip", StatusCompleted, nil); err == nil {
+		t.Error("expected error for file not in the queue")
+	}
+	if loaded, err := Load(dir); err != nil || loaded.Items[0].Status != StatusPending {
+		t.Errorf("expected updates to stay in memory until saved, got %+v (%v)", loaded.Items[0], err)
+	}
+	if err := q.Flush(); err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatalf("failed to load queue: %v", err)
+	}
+	if loaded.Source != "https://example.com/files/" {
+		t.Errorf("unexpected source %s", loaded.Source)
+	}
+	if loaded.Items[1].Status != StatusFailed || loaded.Items[1].Error != "connection reset" {
+		t.Errorf("unexpected item %+v", loaded.Items[1])
+	}
+
+	tallies := loaded.Tallies()
+	expected := map[Status]Tally{
+		StatusPending:   {Count: 1, Bytes: 3000},
+		StatusCompleted: {Count: 1, Bytes: 1000},
+		StatusFailed:    {Count: 1, Bytes: 2000},
+	}
+	for status, want := range expected {
+		if tallies[status] != want {
+			t.Errorf("%s: expected %+v, got %+v", status, want, tallies[status])
+		}
+	}
+}
+
+func TestQueue_ResumeOrder(t *testing.T) {
+	previous := []parser.FileInfo{
+		{Name: "done.zip", Size: 10},
+		{Name: "big.zip", Size: 9000},
+		{Name: "failed.zip", Size: 500},
+		{Name: "tiny.zip", Size: 1},
+		{Name: "mid.zip", Size: 300},
+		{Name: "cut.zip", Size: 700},
+	}
+	q, err := Create(t.TempDir(), "https://example.com/files/", previous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, status := range map[string]Status{"done.zip": StatusCompleted, "failed.zip": StatusFailed, "cut.zip": StatusInProgress} {
+		if err := q.Update(name, status, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A file new to this run counts as not yet attempted
+	files := append(previous, parser.FileInfo{Name: "new.zip", Size: 5000})
+	ordered, retried, untouched := q.ResumeOrder(files)
+
+	expected := []string{"failed.zip", "cut.zip", "tiny.zip", "big.zip", "mid.zip", "new.zip", "done.zip"}
+	if len(ordered) != len(expected) {
+		t.Fatalf("expected %d files, got %d", len(expected), len(ordered))
+	}
+	for i, name := range expected {
+		if ordered[i].Name != name {
+			t.Errorf("position %d: expected %s, got %s", i, name, ordered[i].Name)
+		}
+	}
+	if retried != 2 || untouched != 4 {
+		t.Errorf("expected 2 retried and 4 untouched, got %d and %d", retried, untouched)
+	}
+}
+
+func TestQueue_Files(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Create(dir, "https://example.com/files/", testFiles())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Update("sonic.zip", StatusCompleted, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.SaveProgress(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A run that died is picked up from what it saved
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := loaded.Files()
+	want := testFiles()
+	if len(files) != len(want) {
+		t.Fatalf("expected %d files, got %d", len(want), len(files))
+	}
+	for i := range want {
+		if files[i] != want[i] {
+			t.Errorf("expected %+v, got %+v", want[i], files[i])
+		}
+	}
+
+	ordered, retried, untouched := loaded.ResumeOrder(files)
+	if retried != 0 || untouched != 2 || ordered[len(ordered)-1].Name != "sonic.zip" {
+		t.Errorf("expected the completed file last, got %v (%d retried, %d untouched)", ordered, retried, untouched)
+	}
+}
+
+func TestQueue_Progress(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Create(dir, "https://example.com/files/", testFiles())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q.Progress("mario.zip", 1000)
+	q.Progress("sonic.zip", 512)
+	q.Progress("missing.zip", 99)
+	if loaded, err := Load(dir); err != nil || loaded.TotalTransferred() != 0 {
+		t.Errorf("expected progress to stay in memory until saved, got %d (%v)", loaded.TotalTransferred(), err)
+	}
+	if err := q.SaveProgress(); err != nil {
+		t.Fatal(err)
+	}
+
+	previous, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous.Transferred("sonic.zip") != 512 || previous.TotalTransferred() != 1512 {
+		t.Errorf("unexpected saved progress %+v", previous.Items)
+	}
+
+	// A resumed run keeps the progress of files that are still the same
+	files := testFiles()
+	files[0].URL = "https://example.com/other/mario.zip"
+	resumed, err := Create(dir, "https://example.com/files/", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed.Carry(previous)
+	if resumed.Transferred("mario.zip") != 0 || resumed.Transferred("sonic.zip") != 512 {
+		t.Errorf("unexpected carried progress %+v", resumed.Items)
+	}
+	if err := resumed.SaveProgress(); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := Load(dir); err != nil || loaded.TotalTransferred() != 512 {
+		t.Errorf("expected carried progress to be saved, got %d (%v)", loaded.TotalTransferred(), err)
+	}
+}
+
+func TestLoad_Missing(t *testing.T) {
+	if _, err := Load(t.TempDir()); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected not-exist error, got %v", err)
+	}
+}
+
+func TestRunLock(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, ok := Running(dir); ok {
+		t.Fatal("expected no run before acquiring the lock")
+	}
+
+	lock, err := Acquire(dir)
+	if err != nil {
+		t.Fatalf("failed to acquire lock: %v", err)
+	}
+	pid, ok := Running(dir)
+	if !ok || pid != os.Getpid() {
+		t.Errorf("expected running with pid %d, got %d (%v)", os.Getpid(), pid, ok)
+	}
+
+	lock.Release()
+	if _, ok := Running(dir); ok {
+		t.Error("expected no run after release")
+	}
+}
+
+func TestRunLock_OtherProcess(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(Dir(dir), LockFile)
+	if err := os.MkdirAll(Dir(dir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid()+1)), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Acquire(dir); err == nil {
+		t.Error("expected error while another run holds a fresh lock")
+	}
+
+	// A lock that stopped receiving heartbeats belongs to a dead run
+	old := time.Now().Add(-2 * StaleAfter)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	lock, err := Acquire(dir)
+	if err != nil {
+		t.Fatalf("expected stale lock to be taken over, got %v", err)
+	}
+	lock.Release()
+}
+
+func TestRunLock_Concurrent(t *testing.T) {
+	dir := t.TempDir()
+
+	// Runs starting together race to create the lock; exactly one wins
+	const runs = 8
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		locks []*RunLock
+	)
+	for range runs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if lock, err := Acquire(dir); err == nil {
+				mu.Lock()
+				locks = append(locks, lock)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(locks) != 1 {
+		t.Fatalf("expected exactly one run to acquire the lock, got %d", len(locks))
+	}
+	locks[0].Release()
+}
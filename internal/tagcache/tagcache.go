@@ -0,0 +1,156 @@
+// Package tagcache keeps the metadata parsed from a listing's file names
+// (titles, tags, revisions) keyed by the names themselves, so re-planning the
+// same listing with different priorities doesn't parse every name again.
+package tagcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/nchapman/myrient-dl/internal/cleanup"
+	"github.com/nchapman/myrient-dl/internal/naming"
+)
+
+// Version identifies the cache format and what naming.Describe returns; bump
+// it whenever either changes so older cache files are parsed afresh
+const Version = 1
+
+// Cache is the parsed metadata of one listing's names. A nil Cache parses
+// every name on demand. It is safe for concurrent use.
+type Cache struct {
+	Version int                        `json:"version"`
+	URL     string                     `json:"url"`
+	Names   map[string]naming.Metadata `json:"names"`
+
+	mu     sync.Mutex
+	path   string
+	dirty  bool
+	reused bool
+}
+
+// DefaultDir returns the cache location in the user's cache directory
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return filepath.Join(dir, "myrient-dl", "tags"), nil
+}
+
+// cachePath returns the cache file for a listing URL
+func cachePath(dir, listingURL string) string {
+	sum := sha256.Sum256([]byte(listingURL))
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// Load returns the cached metadata of a listing's names, or an empty cache if
+// none was saved by this Version. A name's metadata depends only on the name,
+// so entries stay valid however the listing changes. An unreadable cache file
+// is treated as missing, since it only saves work.
+func Load(dir, listingURL string) *Cache {
+	c := &Cache{Version: Version, URL: listingURL, Names: make(map[string]naming.Metadata), path: cachePath(dir, listingURL)}
+
+	data, err := os.ReadFile(c.path) //nolint:gosec // Path is derived from the cache directory
+	if err != nil {
+		return c
+	}
+	var saved Cache
+	if err := json.Unmarshal(data, &saved); err != nil || saved.Version != Version || saved.URL != listingURL {
+		return c
+	}
+	if saved.Names != nil {
+		c.Names, c.reused = saved.Names, true
+	}
+	return c
+}
+
+// Reused reports whether the metadata came from the cache file
+func (c *Cache) Reused() bool {
+	return c != nil && c.reused
+}
+
+// Describe returns the metadata of a file name, parsing it if it isn't cached
+func (c *Cache) Describe(name string) naming.Metadata {
+	if c == nil {
+		return naming.Describe(name)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if m, ok := c.Names[name]; ok {
+		return m
+	}
+	m := naming.Describe(name)
+	c.Names[name] = m
+	c.dirty = true
+	return m
+}
+
+// Fill parses every name of the listing not cached yet and drops cached names
+// it no longer has, so the cache doesn't grow with every rename upstream. It
+// returns how many names were already cached.
+func (c *Cache) Fill(names []string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	listed := make(map[string]bool, len(names))
+	cached := 0
+	for _, name := range names {
+		listed[name] = true
+		if _, ok := c.Names[name]; ok {
+			cached++
+			continue
+		}
+		c.Names[name] = naming.Describe(name)
+		c.dirty = true
+	}
+	for name := range c.Names {
+		if !listed[name] {
+			delete(c.Names, name)
+			c.dirty = true
+		}
+	}
+	return cached
+}
+
+// Save writes the cache if anything was parsed or dropped since it was loaded
+func (c *Cache) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to encode tag cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil { //nolint:gosec // Cache directory is not sensitive
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	tmp := c.path + cleanup.TempSuffix
+	if err := os.WriteFile(tmp, data, 0644); err != nil { //nolint:gosec // Cache file is not sensitive
+		return fmt.Errorf("failed to write tag cache: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write tag cache: %w", err)
+	}
+	c.dirty = false
+	return nil
+}
+
+// Invalidate drops the cached metadata of a listing, e.g. once some of its
+// files turned out to be gone. A missing cache file is not an error.
+func Invalidate(dir, listingURL string) error {
+	if err := os.Remove(cachePath(dir, listingURL)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove tag cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package tagcache
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nchapman/myrient-dl/internal/naming"
+)
+
+const listingURL = "https://myrient.example/files/No-Intro/NES/"
+
+func TestCache_SaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{"Sonic (USA).zip", "Sonic (USA) (Rev 1).zip"}
+
+	c := Load(dir, listingURL)
+	if c.Reused() {
+		t.Fatal("expected a fresh cache")
+	}
+	if cached := c.Fill(names); cached != 0 {
+		t.Errorf("expected nothing cached yet, got %d", cached)
+	}
+	if err := c.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	c = Load(dir, listingURL)
+	if !c.Reused() || len(c.Names) != 2 {
+		t.Fatalf("expected the saved metadata to be reused, got %d names", len(c.Names))
+	}
+	if got := c.Describe(names[1]); !reflect.DeepEqual(got, naming.Describe(names[1])) {
+		t.Errorf("cached metadata %+v differs from parsed", got)
+	}
+
+	// A changed listing keeps the names it still has and drops the rest
+	changed := []string{names[1], "Sonic (USA) (Rev 2).zip"}
+	if cached := c.Fill(changed); cached != 1 {
+		t.Errorf("expected 1 name reused from the cache, got %d", cached)
+	}
+	if err := c.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	c = Load(dir, listingURL)
+	if _, ok := c.Names[names[0]]; ok || len(c.Names) != 2 {
+		t.Errorf("expected only the listed names to be saved, got %v", c.Names)
+	}
+
+	// A different listing has its own cache
+	if c := Load(dir, listingURL+"other/"); c.Reused() {
+		t.Error("expected another listing not to reuse the cache")
+	}
+}
+
+func TestCache_Version(t *testing.T) {
+	dir := t.TempDir()
+	c := Load(dir, listingURL)
+	c.Fill([]string{"Sonic (USA).zip"})
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Metadata parsed by another version may differ, so it's a miss
+	saved, err := os.ReadFile(cachePath(dir, listingURL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := strings.Replace(string(saved), fmt.Sprintf(`"version":%d`, Version), `"version":0`, 1)
+	if old == string(saved) {
+		t.Fatalf("expected the version in %s", saved)
+	}
+	if err := os.WriteFile(cachePath(dir, listingURL), []byte(old), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if c := Load(dir, listingURL); c.Reused() || len(c.Names) != 0 {
+		t.Errorf("expected a cache from another version to be ignored, got %d names", len(c.Names))
+	}
+}
+
+func TestCache_Corrupt(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(cachePath(dir, listingURL), []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if c := Load(dir, listingURL); c.Reused() {
+		t.Error("expected a corrupt cache to be ignored")
+	}
+}
+
+func TestCache_Nil(t *testing.T) {
+	var c *Cache
+	if got := c.Describe("Sonic (USA).zip"); got.Title != "Sonic" {
+		t.Errorf("expected a nil cache to parse names, got %+v", got)
+	}
+	if err := c.Save(); err != nil {
+		t.Errorf("expected saving a nil cache to do nothing, got %v", err)
+	}
+}
+
+func BenchmarkCache_Describe(b *testing.B) {
+	c := Load(b.TempDir(), listingURL)
+	names := make([]string, 100_000)
+	for i := range names {
+		names[i] = "Title (USA, Europe) (En,Fr,De) (Rev 1)" + string(rune('a'+i%26)) + ".zip"
+	}
+	c.Fill(names)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, name := range names {
+			c.Describe(name)
+		}
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	dir := t.TempDir()
+	c := Load(dir, listingURL)
+	c.Fill([]string{"Game (USA).zip"})
+	if err := c.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := Invalidate(dir, listingURL); err != nil {
+		t.Fatalf("failed to invalidate: %v", err)
+	}
+	if Load(dir, listingURL).Reused() {
+		t.Error("expected the cache to be gone after Invalidate")
+	}
+	if err := Invalidate(dir, listingURL); err != nil {
+		t.Errorf("invalidating a missing cache should succeed, got %v", err)
+	}
+}
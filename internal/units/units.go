@@ -0,0 +1,153 @@
+// Package units parses human-friendly quantities, both given on the command
+// line and shown in directory listings: sizes and rates.
+package units
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// sizeUnits maps unit suffixes to byte multipliers. Bare letters and IEC suffixes
+// are binary to match Myrient's listings; SI suffixes (KB, MB, ...) are decimal.
+var sizeUnits = map[string]int64{
+	"":    1,
+	"B":   1,
+	"K":   1 << 10,
+	"KIB": 1 << 10,
+	"KB":  1000,
+	"M":   1 << 20,
+	"MIB": 1 << 20,
+	"MB":  1000 * 1000,
+	"G":   1 << 30,
+	"GIB": 1 << 30,
+	"GB":  1000 * 1000 * 1000,
+	"T":   1 << 40,
+	"TIB": 1 << 40,
+	"TB":  1000 * 1000 * 1000 * 1000,
+}
+
+// number is a number's syntax: digits, optionally grouped and with a
+// fraction after a point or comma, e.g. "1,234,567" or "70,5"
+const number = `\d+(?:[.,]\d+)*|[.,]\d+`
+
+var (
+	// validNumber matches a whole number
+	validNumber = regexp.MustCompile(`^(?:` + number + `)$`)
+	// listedSize matches a size in listing text, e.g. "70.5 KiB", "70K", "72.2 kB",
+	// or "70,5 KiB"; units are case-sensitive so words in file names aren't sizes
+	listedSize = regexp.MustCompile(`(` + number + `)\s*(KiB|MiB|GiB|TiB|kB|KB|MB|GB|TB|K|M|G|T|B)(?:\s|$)`)
+)
+
+// ParseSize parses a size such as "500M", "1.5GiB", "20GB", "1,5G", or "1024" into bytes
+func ParseSize(s string) (int64, error) {
+	trimmed := strings.TrimSpace(s)
+	split := strings.IndexFunc(trimmed, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.' && r != ','
+	})
+	number, unit := trimmed, ""
+	if split >= 0 {
+		number, unit = trimmed[:split], strings.TrimSpace(trimmed[split:])
+	}
+
+	size, err := toBytes(number, unit)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q (expected e.g. 500M, 1.5GiB, or 20GB)", s)
+	}
+	return size, nil
+}
+
+// ParseRate parses a transfer rate such as "2M" or "500KB/s" into bytes per second
+func ParseRate(s string) (int64, error) {
+	return ParseSize(strings.TrimSuffix(strings.TrimSpace(s), "/s"))
+}
+
+// FindSize finds a size with a unit in listing text, such as a table cell or
+// the line after a link. A "-", which listings show for entries without a
+// size, is found as 0. Bare numbers aren't taken as sizes, since dates and
+// times would be.
+func FindSize(text string) (int64, bool) {
+	if strings.TrimSpace(text) == "-" {
+		return 0, true
+	}
+	m := listedSize.FindStringSubmatch(text)
+	if m == nil {
+		return 0, false
+	}
+	size, err := toBytes(m[1], m[2])
+	return size, err == nil
+}
+
+// toBytes multiplies a number by a unit from sizeUnits
+func toBytes(number, unit string) (int64, error) {
+	multiplier, ok := sizeUnits[strings.ToUpper(unit)]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", unit)
+	}
+	value, err := parseNumber(number)
+	if err != nil {
+		return 0, err
+	}
+	bytes := value * float64(multiplier)
+	if bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("%s%s is too large", number, unit)
+	}
+	return int64(bytes), nil
+}
+
+// parseNumber reads a non-negative decimal number written with a point or a
+// comma as the decimal separator, e.g. "1.5" or "1,5". The other separator,
+// a repeated one, or a lone comma before three digits groups thousands and is
+// dropped: "1,234,567", "1.234,5", and "1,500" are all whole thousands.
+func parseNumber(s string) (float64, error) {
+	if !validNumber.MatchString(s) {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	dots, commas := strings.Count(s, "."), strings.Count(s, ",")
+	decimal := ""
+	switch {
+	case dots > 0 && commas > 0:
+		decimal = "."
+		if strings.LastIndex(s, ",") > strings.LastIndex(s, ".") {
+			decimal = ","
+		}
+	case dots == 1:
+		decimal = "."
+	case commas == 1 && len(s)-strings.Index(s, ",")-1 != 3:
+		decimal = ","
+	}
+	if decimal != "" && strings.Count(s, decimal) > 1 {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+
+	whole, fraction := s, ""
+	if decimal != "" {
+		whole, fraction, _ = strings.Cut(s, decimal)
+	}
+	whole = strings.NewReplacer(".", "", ",", "").Replace(whole)
+	if whole+fraction == "" || strings.ContainsAny(fraction, ".,") {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	value, err := strconv.ParseFloat(cmp.Or(whole, "0")+"."+cmp.Or(fraction, "0"), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return value, nil
+}
+
+// Plausible reports whether a size from a listing, which is rounded to a
+// few significant digits, could describe a file of actual bytes. Unknown
+// listed sizes are taken as plausible.
+func Plausible(listed, actual int64) bool {
+	if listed <= 0 {
+		return true
+	}
+	diff := listed - actual
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= listed/20+1024
+}
@@ -0,0 +1,174 @@
+package units
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+		wantErr  bool
+	}{
+		{"1024", 1024, false},
+		{"500B", 500, false},
+		{"500M", 500 << 20, false},
+		{"1.5GiB", 3 << 29, false},
+		{"20GB", 20_000_000_000, false},
+		{"2 kb", 2000, false},
+		{" 1T ", 1 << 40, false},
+		{"", 0, true},
+		{"GB", 0, true},
+		{"10 parsecs", 0, true},
+		{"-5M", 0, true},
+		{"1,5G", 3 << 29, false},
+		{"99999999999T", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseSize(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error state: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	for _, input := range []string{"2M", "2M/s", " 2MiB/s "} {
+		if got, err := ParseRate(input); err != nil || got != 2<<20 {
+			t.Errorf("ParseRate(%q) = %d, %v", input, got, err)
+		}
+	}
+	if _, err := ParseRate("/s"); err == nil {
+		t.Error("expected an error for a rate without a number")
+	}
+}
+
+// corpusEntry is a line of testdata/sizes.txt; -1 stands for "none"
+type corpusEntry struct {
+	text         string
+	listed, flag int64
+}
+
+// loadCorpus reads the size corpus shared by the table and fuzz tests
+func loadCorpus(t testing.TB) []corpusEntry {
+	t.Helper()
+	file, err := os.Open("testdata/sizes.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+
+	value := func(s string) int64 {
+		if s == "none" {
+			return -1
+		}
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			t.Fatalf("bad corpus value %q", s)
+		}
+		return n
+	}
+	var entries []corpusEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" && !strings.HasPrefix(line, "#") {
+			fields := strings.Split(line, " | ")
+			if len(fields) != 3 {
+				t.Fatalf("bad corpus line %q", line)
+			}
+			entries = append(entries, corpusEntry{fields[0], value(fields[1]), value(fields[2])})
+		}
+	}
+	return entries
+}
+
+func TestCorpus(t *testing.T) {
+	for _, e := range loadCorpus(t) {
+		listed, found := FindSize(e.text)
+		if (e.listed < 0 && found) || (e.listed >= 0 && (!found || listed != e.listed)) {
+			t.Errorf("FindSize(%q) = %d, %v; want %d", e.text, listed, found, e.listed)
+		}
+		flag, err := ParseSize(e.text)
+		if (e.flag < 0 && err == nil) || (e.flag >= 0 && (err != nil || flag != e.flag)) {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", e.text, flag, err, e.flag)
+		}
+	}
+}
+
+func TestFindSize_RoundTrip(t *testing.T) {
+	binary := []string{"B", "K", "M", "G", "T"}
+	iec := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	si := []string{"B", "kB", "MB", "GB", "TB"}
+	for _, size := range []int64{500, 72191, 1258291, 355278412, 2684354560, 1<<40 + 12345} {
+		b, bi := float64(size), 0
+		for b >= 1024 && bi < len(binary)-1 {
+			b, bi = b/1024, bi+1
+		}
+		d, di := float64(size), 0
+		for d >= 1000 && di < len(si)-1 {
+			d, di = d/1000, di+1
+		}
+		renderings := []string{
+			fmt.Sprintf("%.1f%s", b, binary[bi]),                             // Apache
+			fmt.Sprintf("%.1f %s", b, iec[bi]),                               // Myrient
+			fmt.Sprintf("%.1f %s", d, si[di]),                                // Decimal units
+			strings.Replace(fmt.Sprintf("%.1f %s", b, iec[bi]), ".", ",", 1), // Decimal comma
+			fmt.Sprintf("11-Sep-2023 09:52  %.1f%s", b, binary[bi]),          // Apache flat listing
+		}
+		for _, text := range renderings {
+			got, found := FindSize(text)
+			if !found || !Plausible(got, size) {
+				t.Errorf("FindSize(%q) = %d, %v; want about %d", text, got, found, size)
+			}
+		}
+	}
+}
+
+func FuzzFindSize(f *testing.F) {
+	for _, e := range loadCorpus(f) {
+		f.Add(e.text)
+	}
+	f.Fuzz(func(t *testing.T, text string) {
+		listed, found := FindSize(text)
+		if listed < 0 || (!found && listed != 0) {
+			t.Fatalf("FindSize(%q) = %d, %v", text, listed, found)
+		}
+		flag, err := ParseSize(text)
+		if err == nil && flag < 0 {
+			t.Fatalf("ParseSize(%q) = %d", text, flag)
+		}
+		if err == nil && found && strings.TrimSpace(text) != "-" && listed != flag {
+			t.Fatalf("FindSize(%q) = %d but ParseSize = %d", text, listed, flag)
+		}
+	})
+}
+
+func TestPlausible(t *testing.T) {
+	tests := []struct {
+		listed, actual int64
+		plausible      bool
+	}{
+		{0, 12345, true},
+		{1000, 1000, true},
+		{1288490188, 1290000000, true}, // "1.2 GiB"
+		{1288490188, 1400000000, false},
+		{2048, 2900, true}, // Small files get a fixed margin
+		{10 << 20, 18, false},
+	}
+	for _, tt := range tests {
+		if got := Plausible(tt.listed, tt.actual); got != tt.plausible {
+			t.Errorf("Plausible(%d, %d) = %v, expected %v", tt.listed, tt.actual, got, tt.plausible)
+		}
+	}
+}
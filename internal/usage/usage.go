@@ -0,0 +1,140 @@
+// Package usage records how many bytes each run pulled from each host.
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Record is the traffic of one run against one host
+type Record struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Source string    `json:"source"` // Listing URL the run was started from
+	Host   string    `json:"host"`
+	Bytes  int64     `json:"bytes"`
+}
+
+// DefaultPath returns the usage log location in the user's config directory
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+	return filepath.Join(dir, "myrient-dl", "usage.jsonl"), nil
+}
+
+// Append adds the records of a run to the usage log at path
+func Append(path string, records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil { //nolint:gosec // Config directory permissions
+		return fmt.Errorf("failed to create usage directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644) //nolint:gosec // Usage is not sensitive
+	if err != nil {
+		return fmt.Errorf("failed to open usage log: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	w := bufio.NewWriter(file)
+	for _, r := range records {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		_, _ = w.Write(append(data, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write usage log: %w", err)
+	}
+	return nil
+}
+
+// Records builds the records for a run from its per-host byte counts, skipping idle hosts
+func Records(start, end time.Time, source string, traffic map[string]int64) []Record {
+	var records []Record
+	for host, bytes := range traffic {
+		if bytes > 0 {
+			records = append(records, Record{Start: start, End: end, Source: source, Host: host, Bytes: bytes})
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Host < records[j].Host })
+	return records
+}
+
+// Read returns every record in the usage log. A missing log reads as empty;
+// malformed lines are skipped.
+func Read(path string) ([]Record, error) {
+	file, err := os.Open(path) //nolint:gosec // Path is the user's usage log
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage log: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err == nil {
+			records = append(records, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read usage log: %w", err)
+	}
+	return records, nil
+}
+
+// Window is a labeled period ending now
+type Window struct {
+	Label string
+	Since time.Time
+}
+
+// HostTotals is a host's traffic within each window, in window order
+type HostTotals struct {
+	Host   string
+	Totals []int64
+}
+
+// Summarize totals records per host for each window, by the time each run ended.
+// Hosts are sorted by their traffic in the last window, largest first.
+func Summarize(records []Record, windows []Window) []HostTotals {
+	byHost := make(map[string][]int64)
+	for _, r := range records {
+		totals, ok := byHost[r.Host]
+		if !ok {
+			totals = make([]int64, len(windows))
+			byHost[r.Host] = totals
+		}
+		for i, w := range windows {
+			if !r.End.Before(w.Since) {
+				totals[i] += r.Bytes
+			}
+		}
+	}
+
+	summary := make([]HostTotals, 0, len(byHost))
+	for host, totals := range byHost {
+		summary = append(summary, HostTotals{Host: host, Totals: totals})
+	}
+	last := len(windows) - 1
+	sort.Slice(summary, func(i, j int) bool {
+		if last >= 0 && summary[i].Totals[last] != summary[j].Totals[last] {
+			return summary[i].Totals[last] > summary[j].Totals[last]
+		}
+		return summary[i].Host < summary[j].Host
+	})
+	return summary
+}
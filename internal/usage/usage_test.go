@@ -0,0 +1,71 @@
+package usage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAppendRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "usage.jsonl")
+	start := time.Now().Add(-time.Minute).UTC()
+	end := time.Now().UTC()
+
+	records := Records(start, end, "https://example.com/files/", map[string]int64{
+		"b.example.com": 200,
+		"a.example.com": 100,
+		"idle.example":  0,
+	})
+	if len(records) != 2 || records[0].Host != "a.example.com" {
+		t.Fatalf("expected two records sorted by host, got %+v", records)
+	}
+
+	if err := Append(path, records); err != nil {
+		t.Fatalf("failed to append: %v", err)
+	}
+	if err := Append(path, records[:1]); err != nil {
+		t.Fatalf("failed to append: %v", err)
+	}
+
+	read, err := Read(path)
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	if len(read) != 3 || read[2].Bytes != 100 {
+		t.Errorf("unexpected records %+v", read)
+	}
+
+	missing, err := Read(filepath.Join(t.TempDir(), "missing.jsonl"))
+	if err != nil || len(missing) != 0 {
+		t.Errorf("expected empty log, got %v (%v)", missing, err)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	now := time.Date(2024, 5, 31, 12, 0, 0, 0, time.UTC)
+	records := []Record{
+		{End: now.AddDate(0, 0, -1), Host: "cdn", Bytes: 10},
+		{End: now.AddDate(0, 0, -10), Host: "cdn", Bytes: 20},
+		{End: now.AddDate(0, 0, -60), Host: "cdn", Bytes: 40},
+		{End: now.AddDate(0, 0, -2), Host: "origin", Bytes: 1000},
+	}
+	windows := []Window{
+		{Label: "7 days", Since: now.AddDate(0, 0, -7)},
+		{Label: "30 days", Since: now.AddDate(0, 0, -30)},
+		{Label: "total"},
+	}
+
+	summary := Summarize(records, windows)
+	if len(summary) != 2 {
+		t.Fatalf("expected 2 hosts, got %+v", summary)
+	}
+	if summary[0].Host != "origin" {
+		t.Errorf("expected busiest host first, got %s", summary[0].Host)
+	}
+	expected := []int64{10, 30, 70}
+	for i, want := range expected {
+		if summary[1].Totals[i] != want {
+			t.Errorf("%s: expected %d, got %d", windows[i].Label, want, summary[1].Totals[i])
+		}
+	}
+}
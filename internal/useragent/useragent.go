@@ -0,0 +1,143 @@
+// Package useragent builds the identification sent with every request, so
+// archive operators can tell who is crawling and how to reach them.
+package useragent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// Default is the User-Agent sent when no contact is configured
+const Default = "myrient-dl/1.0 (https://github.com/nchapman/myrient-dl)"
+
+// Identity is how requests identify themselves. The zero value sends Default.
+type Identity struct {
+	// Contact is an email address or URL appended to the User-Agent. An email
+	// address is also sent as the From header.
+	Contact string
+	// Agent, if set, replaces the User-Agent, contact and all
+	Agent string
+	// Headers are added to every request after the identification, so they
+	// can override it
+	Headers http.Header
+}
+
+// New validates a contact, which must be an email address or an http(s) URL
+func New(contact string) (Identity, error) {
+	contact = strings.TrimSpace(contact)
+	if contact == "" {
+		return Identity{}, nil
+	}
+	if isEmail(contact) {
+		return Identity{Contact: contact}, nil
+	}
+	if u, err := url.Parse(contact); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+		return Identity{Contact: contact}, nil
+	}
+	return Identity{}, fmt.Errorf("invalid contact %q (expected an email address or http(s) URL)", contact)
+}
+
+// isEmail reports whether s is a bare email address
+func isEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
+
+// ParseHeader parses a "Name: value" header given on the command line
+func ParseHeader(spec string) (name, value string, err error) {
+	name, value, ok := strings.Cut(spec, ":")
+	name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+	if !ok || !httpguts.ValidHeaderFieldName(name) || !httpguts.ValidHeaderFieldValue(value) {
+		return "", "", fmt.Errorf("invalid header %q (expected \"Name: value\")", spec)
+	}
+	return http.CanonicalHeaderKey(name), value, nil
+}
+
+// SetAgent replaces the User-Agent; an empty one restores the default
+func (i *Identity) SetAgent(agent string) error {
+	agent = strings.TrimSpace(agent)
+	if !httpguts.ValidHeaderFieldValue(agent) {
+		return fmt.Errorf("invalid User-Agent %q", agent)
+	}
+	i.Agent = agent
+	return nil
+}
+
+// AddHeader adds a "Name: value" header to send with every request
+func (i *Identity) AddHeader(spec string) error {
+	name, value, err := ParseHeader(spec)
+	if err != nil {
+		return err
+	}
+	if i.Headers == nil {
+		i.Headers = make(http.Header)
+	}
+	i.Headers.Add(name, value)
+	return nil
+}
+
+// UserAgent returns the User-Agent header value
+func (i Identity) UserAgent() string {
+	if i.Agent != "" {
+		return i.Agent
+	}
+	if i.Contact == "" {
+		return Default
+	}
+	return strings.TrimSuffix(Default, ")") + "; " + i.Contact + ")"
+}
+
+// From returns the From header value, or "" when the contact isn't an email address
+func (i Identity) From() string {
+	if isEmail(i.Contact) {
+		return i.Contact
+	}
+	return ""
+}
+
+// Apply sets the identification and extra headers on a request
+func (i Identity) Apply(req *http.Request) {
+	req.Header.Set("User-Agent", i.UserAgent())
+	if from := i.From(); from != "" {
+		req.Header.Set("From", from)
+	}
+	for name, values := range i.Headers {
+		req.Header[name] = append([]string(nil), values...)
+	}
+}
+
+// NewRequest builds a request carrying the identification and extra headers;
+// listing and download requests are both made with it
+func (i Identity) NewRequest(ctx context.Context, method, rawURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	i.Apply(req)
+	return req, nil
+}
+
+// String describes the identification for verbose output. Extra headers are
+// only named, since they may carry credentials.
+func (i Identity) String() string {
+	s := i.UserAgent()
+	if from := i.From(); from != "" {
+		s += ", From: " + from
+	}
+	if len(i.Headers) > 0 {
+		names := make([]string, 0, len(i.Headers))
+		for name := range i.Headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		s += ", headers: " + strings.Join(names, ", ")
+	}
+	return s
+}
@@ -0,0 +1,118 @@
+package useragent
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		contact   string
+		userAgent string
+		from      string
+		wantErr   bool
+	}{
+		{"", Default, "", false},
+		{"you@example.com", "myrient-dl/1.0 (https://github.com/nchapman/myrient-dl; you@example.com)", "you@example.com", false},
+		{" https://example.com/bot ", "myrient-dl/1.0 (https://github.com/nchapman/myrient-dl; https://example.com/bot)", "", false},
+		{"You <you@example.com>", "", "", true},
+		{"ftp://example.com", "", "", true},
+		{"just a name", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.contact, func(t *testing.T) {
+			id, err := New(tt.contact)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New(%q) error = %v, wantErr %v", tt.contact, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := id.UserAgent(); got != tt.userAgent {
+				t.Errorf("UserAgent() = %q, want %q", got, tt.userAgent)
+			}
+			if got := id.From(); got != tt.from {
+				t.Errorf("From() = %q, want %q", got, tt.from)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://mirror.example/", nil)
+	Identity{}.Apply(req)
+	if req.Header.Get("User-Agent") != Default || req.Header.Get("From") != "" {
+		t.Errorf("unexpected default headers %v", req.Header)
+	}
+
+	req = httptest.NewRequest("GET", "http://mirror.example/", nil)
+	Identity{Contact: "you@example.com"}.Apply(req)
+	if req.Header.Get("From") != "you@example.com" {
+		t.Errorf("expected a From header, got %v", req.Header)
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	tests := []struct {
+		spec    string
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{"X-Token: abc", "X-Token", "abc", false},
+		{"x-forwarded-for:10.0.0.1", "X-Forwarded-For", "10.0.0.1", false},
+		{"Accept:", "Accept", "", false},
+		{"X-Token abc", "", "", true},
+		{"Bad Name: abc", "", "", true},
+		{": abc", "", "", true},
+		{"X-Token: a\nb", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			name, value, err := ParseHeader(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseHeader(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if name != tt.name || value != tt.value {
+				t.Errorf("ParseHeader(%q) = %q, %q, want %q, %q", tt.spec, name, value, tt.name, tt.value)
+			}
+		})
+	}
+}
+
+func TestNewRequest_Overrides(t *testing.T) {
+	id := Identity{Contact: "you@example.com"}
+	if err := id.SetAgent("Mozilla/5.0 (compatible)"); err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range []string{"X-Token: abc", "Accept: text/html", "X-Token: def"} {
+		if err := id.AddHeader(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := id.SetAgent("bad\r\nagent"); err == nil {
+		t.Error("expected a User-Agent with a line break to be refused")
+	}
+
+	req, err := id.NewRequest(context.Background(), http.MethodGet, "http://mirror.example/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := req.Header.Get("User-Agent"); got != "Mozilla/5.0 (compatible)" {
+		t.Errorf("User-Agent = %q", got)
+	}
+	if req.Header.Get("From") != "you@example.com" || req.Header.Get("Accept") != "text/html" {
+		t.Errorf("unexpected headers %v", req.Header)
+	}
+	if got := req.Header.Values("X-Token"); len(got) != 2 {
+		t.Errorf("expected both X-Token values, got %v", got)
+	}
+	if s := id.String(); strings.Contains(s, "abc") || !strings.Contains(s, "headers: Accept, X-Token") {
+		t.Errorf("String() = %q, want header names without values", s)
+	}
+}
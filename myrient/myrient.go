@@ -0,0 +1,305 @@
+// Package myrient plans and runs downloads from Myrient-style directory
+// listings for programs other than the myrient-dl command. NewPlan fetches a
+// listing, filters it, and checks what the output directory already has,
+// without downloading anything; Execute downloads what a plan still needs.
+//
+// Plans are saved in the same format as "myrient-dl plan", so a plan made
+// here can be run with "myrient-dl apply" and one made there with Execute.
+package myrient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nchapman/myrient-dl/internal/downloader"
+	"github.com/nchapman/myrient-dl/internal/hashing"
+	"github.com/nchapman/myrient-dl/internal/matcher"
+	"github.com/nchapman/myrient-dl/internal/parser"
+	"github.com/nchapman/myrient-dl/internal/plan"
+	"github.com/nchapman/myrient-dl/internal/progress"
+	"github.com/nchapman/myrient-dl/internal/useragent"
+)
+
+// Options chooses files from a listing and where they are saved
+type Options struct {
+	// OutputDir is the directory the files are downloaded into
+	OutputDir string
+	// Include and Exclude are glob patterns matched against file names, e.g.
+	// "*(USA)*"; no Include patterns selects every file
+	Include []string
+	Exclude []string
+	// Recursive also lists subdirectories, down to MaxDepth levels (0 for no limit)
+	Recursive bool
+	MaxDepth  int
+	// UserAgent replaces the default User-Agent; Headers are added to every request
+	UserAgent string
+	Headers   http.Header
+}
+
+// Local is how much of a file the output directory already has
+type Local string
+
+// Local file states
+const (
+	LocalMissing   Local = Local(plan.LocalMissing)
+	LocalPartial   Local = Local(plan.LocalPartial)   // Only an interrupted download's temp file
+	LocalComplete  Local = Local(plan.LocalComplete)  // At about the listed size; Execute skips it
+	LocalDifferent Local = Local(plan.LocalDifferent) // At another size; Execute downloads it again
+)
+
+// File is a planned download
+type File struct {
+	Name  string // Path below the output directory, slash-separated
+	URL   string
+	Size  int64 // Listed size; 0 when the listing has none
+	Local Local
+	// Exact is set when Size came from the server rather than the listing
+	Exact bool
+	// SHA1 is the digest pinned when the plan was made, lowercase hex; Execute
+	// verifies the download against it
+	SHA1 string
+}
+
+// Plan is a resolved selection: the files to download from a listing into a directory
+type Plan struct {
+	Source    string
+	OutputDir string
+	Created   time.Time
+	Files     []File
+}
+
+// NewPlan fetches the listing at listingURL and plans downloading the files
+// opts select into opts.OutputDir. Nothing is downloaded.
+func NewPlan(ctx context.Context, listingURL string, opts Options) (*Plan, error) {
+	if opts.OutputDir == "" {
+		return nil, errors.New("an output directory is required")
+	}
+	if err := matcher.Validate("include", opts.Include); err != nil {
+		return nil, err
+	}
+	if err := matcher.Validate("exclude", opts.Exclude); err != nil {
+		return nil, err
+	}
+	identity, err := newIdentity(opts.UserAgent, opts.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	listing, err := parser.FetchListing(ctx, listingURL, parser.Options{
+		Identity:  identity,
+		Recursive: opts.Recursive,
+		MaxDepth:  opts.MaxDepth,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch listing: %w", err)
+	}
+	files := matcher.New(opts.Include, opts.Exclude).Filter(listing.Files)
+	return fromFiles(listingURL, opts.OutputDir, time.Now().UTC(), files), nil
+}
+
+// LoadPlan reads a plan file saved by Save or "myrient-dl plan" and checks
+// what its output directory already has
+func LoadPlan(path string) (*Plan, error) {
+	p, err := plan.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	loaded := fromFiles(p.Source, p.OutputDir, p.CreatedAt, p.FileInfos())
+	for i, e := range p.Files {
+		loaded.Files[i].SHA1 = strings.ToLower(e.SHA1)
+	}
+	return loaded, nil
+}
+
+// fromFiles builds a plan and fills in each file's local state
+func fromFiles(source, dir string, created time.Time, files []parser.FileInfo) *Plan {
+	p := &Plan{Source: source, OutputDir: dir, Created: created, Files: make([]File, len(files))}
+	states := plan.CheckLocal(dir, files)
+	for i, f := range files {
+		p.Files[i] = File{Name: filepath.ToSlash(f.Name), URL: f.URL, Size: f.Size, Local: Local(states[i]), Exact: f.Exact}
+	}
+	return p
+}
+
+// Refresh checks again what the output directory already has
+func (p *Plan) Refresh() {
+	states := plan.CheckLocal(p.OutputDir, p.fileInfos())
+	for i := range p.Files {
+		p.Files[i].Local = Local(states[i])
+	}
+}
+
+// Save writes the plan as a plan file that "myrient-dl apply" can run
+func (p *Plan) Save(path string) error {
+	saved := plan.New(p.Source, p.OutputDir, p.fileInfos())
+	for i, f := range p.Files {
+		saved.Files[i].SHA1 = f.SHA1
+	}
+	if !p.Created.IsZero() {
+		saved.CreatedAt = p.Created
+	}
+	return saved.Save(path)
+}
+
+// TotalSize returns the listed size of every planned file
+func (p *Plan) TotalSize() int64 {
+	var total int64
+	for _, f := range p.Files {
+		total += f.Size
+	}
+	return total
+}
+
+// Pending returns the files a download would fetch: all but those already complete
+func (p *Plan) Pending() []File {
+	var pending []File
+	for _, f := range p.Files {
+		if f.Local != LocalComplete {
+			pending = append(pending, f)
+		}
+	}
+	return pending
+}
+
+// PendingSize returns the listed size of the pending files
+func (p *Plan) PendingSize() int64 {
+	var total int64
+	for _, f := range p.Pending() {
+		total += f.Size
+	}
+	return total
+}
+
+// fileInfos returns the planned files in the form the downloader consumes
+func (p *Plan) fileInfos() []parser.FileInfo {
+	files := make([]parser.FileInfo, len(p.Files))
+	for i, f := range p.Files {
+		files[i] = parser.FileInfo{Name: filepath.FromSlash(f.Name), URL: f.URL, Size: f.Size, Exact: f.Exact}
+	}
+	return files
+}
+
+// ExecuteOptions controls how a plan is downloaded
+type ExecuteOptions struct {
+	// Parallel is how many files download at once; 0 means 1
+	Parallel int
+	// Retries is how many times a failed download is attempted; 0 means 3
+	Retries int
+	// UserAgent replaces the default User-Agent; Headers are added to every request
+	UserAgent string
+	Headers   http.Header
+	// OnEvent, if set, is called as each file starts, completes, is skipped,
+	// or fails. With Parallel over 1 it is called from several goroutines.
+	OnEvent func(Event)
+}
+
+// EventType identifies a step in a file's download
+type EventType string
+
+// Download events
+const (
+	EventStarted   EventType = EventType(downloader.EventStarted)
+	EventCompleted EventType = EventType(downloader.EventCompleted)
+	EventSkipped   EventType = EventType(downloader.EventSkipped) // Already complete locally
+	EventFailed    EventType = EventType(downloader.EventFailed)
+)
+
+// Event reports a step in one file's download
+type Event struct {
+	Type     EventType
+	File     File
+	Bytes    int64
+	Duration time.Duration
+	Err      error // Set for EventFailed
+}
+
+// Summary is how a plan's execution went
+type Summary struct {
+	Total           int
+	Completed       int // Includes skipped files
+	Skipped         int // Already complete locally
+	Failed          int
+	DownloadedBytes int64
+}
+
+// Execute downloads the plan's files into its output directory. Files
+// already there are checked with the server and skipped if complete, and a
+// download that doesn't match its pinned SHA-1 fails. Downloads run in the
+// plan's order and stop at the first file that keeps failing; with Parallel
+// over 1 every file is tried. Progress bars are not drawn, but per-file
+// messages go to standard output as with the command.
+func Execute(ctx context.Context, p *Plan, opts ExecuteOptions) (Summary, error) {
+	identity, err := newIdentity(opts.UserAgent, opts.Headers)
+	if err != nil {
+		return Summary{}, err
+	}
+	retries := opts.Retries
+	if retries <= 0 {
+		retries = 3
+	}
+
+	byName := make(map[string]File, len(p.Files))
+	for _, f := range p.Files {
+		byName[f.Name] = f
+	}
+	config := downloader.Config{
+		OutputDir:     p.OutputDir,
+		Parallel:      max(opts.Parallel, 1),
+		RetryAttempts: retries,
+		VerifyRetries: 2,
+		Identity:      identity,
+		Progress:      progress.Silent{},
+		Checksums: func(file parser.FileInfo) (downloader.Checksum, bool) {
+			f := byName[filepath.ToSlash(file.Name)]
+			return downloader.Checksum{Algorithm: hashing.SHA1, Digest: strings.ToLower(f.SHA1)}, f.SHA1 != ""
+		},
+	}
+	if opts.OnEvent != nil {
+		config.OnEvent = func(e downloader.Event) {
+			switch e.Type {
+			case downloader.EventStarted, downloader.EventCompleted, downloader.EventSkipped, downloader.EventFailed:
+			default:
+				return
+			}
+			opts.OnEvent(Event{
+				Type:     EventType(e.Type),
+				File:     byName[filepath.ToSlash(e.File.Name)],
+				Bytes:    e.Bytes,
+				Duration: e.Duration,
+				Err:      e.Err,
+			})
+		}
+	}
+
+	dl := downloader.New(config)
+	err = dl.DownloadAll(ctx, p.fileInfos())
+	s := dl.Summary()
+	return Summary{
+		Total:           s.Total,
+		Completed:       s.Completed,
+		Skipped:         s.Skipped,
+		Failed:          s.Failed,
+		DownloadedBytes: s.DownloadedBytes,
+	}, err
+}
+
+// newIdentity builds the request identification from a User-Agent and headers
+func newIdentity(agent string, headers http.Header) (useragent.Identity, error) {
+	var identity useragent.Identity
+	if err := identity.SetAgent(agent); err != nil {
+		return identity, err
+	}
+	for name, values := range headers {
+		for _, value := range values {
+			if err := identity.AddHeader(name + ": " + value); err != nil {
+				return identity, err
+			}
+		}
+	}
+	return identity, nil
+}
@@ -0,0 +1,178 @@
+package myrient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+const listingHTML = `<html><body><table id="list">
+<tr><td><a href="a.zip">a.zip</a></td><td>5 B</td></tr>
+<tr><td><a href="b.zip">b.zip</a></td><td>5 B</td></tr>
+<tr><td><a href="c.txt">c.txt</a></td><td>5 B</td></tr>
+</table></body></html>`
+
+func testServer(t *testing.T) (*httptest.Server, *sync.Map) {
+	t.Helper()
+	agents := &sync.Map{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents.Store(r.Method+" "+r.URL.Path, r.Header.Get("User-Agent")+"|"+r.Header.Get("X-Token"))
+		if r.URL.Path == "/files/" {
+			_, _ = w.Write([]byte(listingHTML))
+			return
+		}
+		w.Header().Set("Content-Length", "5")
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte("hello"))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, agents
+}
+
+func TestNewPlan(t *testing.T) {
+	server, agents := testServer(t)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.zip"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewPlan(context.Background(), server.URL+"/files/", Options{
+		OutputDir: dir,
+		Include:   []string{"*.zip"},
+		UserAgent: "tool/2.0",
+		Headers:   http.Header{"X-Token": {"abc"}},
+	})
+	if err != nil {
+		t.Fatalf("NewPlan failed: %v", err)
+	}
+	if len(p.Files) != 2 || p.Files[0].Local != LocalComplete || p.Files[1].Local != LocalMissing {
+		t.Fatalf("unexpected plan files %+v", p.Files)
+	}
+	if p.TotalSize() != 10 || p.PendingSize() != 5 || len(p.Pending()) != 1 {
+		t.Errorf("unexpected totals: %d total, %d pending", p.TotalSize(), p.PendingSize())
+	}
+	if got, _ := agents.Load("GET /files/"); got != "tool/2.0|abc" {
+		t.Errorf("listing request sent %q", got)
+	}
+
+	if _, err := NewPlan(context.Background(), server.URL+"/files/", Options{}); err == nil {
+		t.Error("expected an error without an output directory")
+	}
+	if _, err := NewPlan(context.Background(), server.URL+"/files/", Options{OutputDir: dir, Include: []string{"[a"}}); err == nil {
+		t.Error("expected an error for a malformed pattern")
+	}
+}
+
+func TestPlan_SaveLoad(t *testing.T) {
+	server, _ := testServer(t)
+	dir := t.TempDir()
+	p, err := NewPlan(context.Background(), server.URL+"/files/", Options{OutputDir: dir, Exclude: []string{"*.txt"}})
+	if err != nil {
+		t.Fatalf("NewPlan failed: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "plan.json")
+	if err := p.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := LoadPlan(path)
+	if err != nil {
+		t.Fatalf("LoadPlan failed: %v", err)
+	}
+	if loaded.Source != p.Source || loaded.OutputDir != dir || len(loaded.Files) != 2 || !loaded.Created.Equal(p.Created) {
+		t.Errorf("loaded plan %+v differs from saved %+v", loaded, p)
+	}
+}
+
+func TestPlan_PinnedDigests(t *testing.T) {
+	server, _ := testServer(t)
+	dir := t.TempDir()
+	path := filepath.Join(t.TempDir(), "plan.json")
+	saved := `{"version": 1, "source": "` + server.URL + `/files/", "output_dir": "` + filepath.ToSlash(dir) + `", "files": [
+		{"name": "a.zip", "url": "` + server.URL + `/files/a.zip", "size": 5, "path": "a.zip", "sha1": "AAF4C61DDCC5E8A2DABEDE0F3B482CD9AEA9434D", "exact": true},
+		{"name": "b.zip", "url": "` + server.URL + `/files/b.zip", "size": 5, "path": "b.zip", "sha1": "0000000000000000000000000000000000000000"}
+	]}`
+	if err := os.WriteFile(path, []byte(saved), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pinned digests and exact sizes survive a load, save, and reload
+	p, err := LoadPlan(path)
+	if err != nil {
+		t.Fatalf("LoadPlan failed: %v", err)
+	}
+	if err := p.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if p, err = LoadPlan(path); err != nil {
+		t.Fatalf("LoadPlan failed: %v", err)
+	}
+	if f := p.Files[0]; f.SHA1 != "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d" || !f.Exact {
+		t.Errorf("unexpected reloaded file %+v", f)
+	}
+	if f := p.Files[1]; f.SHA1 != "0000000000000000000000000000000000000000" || f.Exact {
+		t.Errorf("unexpected reloaded file %+v", f)
+	}
+
+	// The download whose digest doesn't match fails
+	summary, err := Execute(context.Background(), p, ExecuteOptions{Parallel: 2, Retries: 1})
+	if err == nil || summary.Completed != 1 || summary.Failed != 1 {
+		t.Errorf("expected one verified download and one failure, got %+v (%v)", summary, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b.zip")); !os.IsNotExist(err) {
+		t.Errorf("expected the mismatched download to be discarded, got %v", err)
+	}
+}
+
+func TestExecute(t *testing.T) {
+	server, agents := testServer(t)
+	dir := t.TempDir()
+	p, err := NewPlan(context.Background(), server.URL+"/files/", Options{OutputDir: dir, Include: []string{"*.zip"}})
+	if err != nil {
+		t.Fatalf("NewPlan failed: %v", err)
+	}
+
+	var mu sync.Mutex
+	var events []Event
+	summary, err := Execute(context.Background(), p, ExecuteOptions{
+		Parallel:  2,
+		UserAgent: "tool/2.0",
+		OnEvent: func(e Event) {
+			mu.Lock()
+			events = append(events, e)
+			mu.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if summary.Total != 2 || summary.Completed != 2 || summary.DownloadedBytes != 10 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+	completed := 0
+	for _, e := range events {
+		if e.Type == EventCompleted && e.File.URL != "" {
+			completed++
+		}
+	}
+	if completed != 2 {
+		t.Errorf("expected 2 completed events with their files, got %+v", events)
+	}
+	if got, _ := agents.Load("GET /files/b.zip"); got != "tool/2.0|" {
+		t.Errorf("download request sent %q", got)
+	}
+
+	p.Refresh()
+	if len(p.Pending()) != 0 {
+		t.Errorf("expected nothing pending after Execute, got %+v", p.Pending())
+	}
+	summary, err = Execute(context.Background(), p, ExecuteOptions{})
+	if err != nil || summary.Skipped != 2 {
+		t.Errorf("expected a second run to skip both files, got %+v (%v)", summary, err)
+	}
+}